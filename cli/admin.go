@@ -0,0 +1,118 @@
+// Package cli implements the administrative subcommands of the main binary.
+// They run against the configured database without starting the HTTP server,
+// so an operator can recover a deployment without writing SQL by hand.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx *commandContext) error
+}
+
+// commandContext is handed to every command. A command registers its flags on
+// ctx.flags and then calls ctx.ready, which parses the arguments and only then
+// initializes the database, so usage errors never touch the deployment.
+type commandContext struct {
+	flags         *flag.FlagSet
+	args          []string
+	out           io.Writer
+	initResources func() error
+}
+
+func (ctx *commandContext) ready() error {
+	return ctx.readyWith(nil)
+}
+
+// readyWith is ready with a hook that runs after the flags are parsed and
+// before the database is initialized, for commands whose flags change how the
+// database is opened.
+func (ctx *commandContext) readyWith(prepare func()) error {
+	if err := ctx.flags.Parse(ctx.args); err != nil {
+		return err
+	}
+	if ctx.flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", ctx.flags.Args())
+	}
+	if prepare != nil {
+		prepare()
+	}
+	return ctx.initResources()
+}
+
+func (ctx *commandContext) printf(format string, args ...any) {
+	fmt.Fprintf(ctx.out, format, args...)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"create-admin", "create an administrator account", runCreateAdmin},
+		{"reset-admin", "reset a user's password and re-enable the account", runResetAdmin},
+		{"reset-2fa", "remove two-factor authentication from a user", runResetTwoFA},
+		{"rotate-token", "replace the key of an API token", runRotateToken},
+		{"channel-enable", "enable channels by id or tag", runChannelEnable},
+		{"channel-disable", "disable channels by id or tag", runChannelDisable},
+		{"fix-ability", "rebuild the abilities table from channels", runFixAbility},
+		{"clean-logs", "delete logs older than the given age", runCleanLogs},
+		{"recompute-quota", "recompute used quota and request count from consume logs", runRecomputeQuota},
+		{"config-export", "export channels, groups, ratios and options", runConfigExport},
+		{"config-import", "apply a document written by config-export", runConfigImport},
+		{"migrate", "apply pending schema migrations", runMigrate},
+		{"migrate-down", "roll back schema migrations", runMigrateDown},
+		{"migrate-status", "list schema migrations and whether they are applied", runMigrateStatus},
+	}
+}
+
+// IsAdminCommand reports whether the positional arguments select the admin
+// subcommands.
+func IsAdminCommand(args []string) bool {
+	return len(args) > 0 && args[0] == "admin"
+}
+
+// PrintUsage writes the list of admin subcommands.
+func PrintUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: newapi admin <command> [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Run 'newapi admin <command> -h' to list the flags of a command.")
+}
+
+// RunAdmin runs the admin subcommand named by args[0]. initResources connects
+// to the configured databases and is called after flags have been validated.
+func RunAdmin(args []string, out io.Writer, initResources func() error) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		PrintUsage(out)
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		flags := flag.NewFlagSet("admin "+cmd.name, flag.ContinueOnError)
+		flags.SetOutput(out)
+		err := cmd.run(&commandContext{
+			flags:         flags,
+			args:          args[1:],
+			out:           out,
+			initResources: initResources,
+		})
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	PrintUsage(out)
+	return fmt.Errorf("unknown command %q", args[0])
+}
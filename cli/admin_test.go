@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failInit() error {
+	return errors.New("database must not be initialized")
+}
+
+func TestRunAdminUsageDoesNotInitialize(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, RunAdmin(nil, &out, failInit))
+	assert.Contains(t, out.String(), "create-admin")
+
+	out.Reset()
+	err := RunAdmin([]string{"drop-everything"}, &out, failInit)
+	assert.ErrorContains(t, err, "unknown command")
+
+	out.Reset()
+	require.NoError(t, RunAdmin([]string{"rotate-token", "-h"}, &out, failInit))
+	assert.Contains(t, out.String(), "-id")
+}
+
+func TestRunAdminRejectsBadFlagsBeforeInitializing(t *testing.T) {
+	var out bytes.Buffer
+	err := RunAdmin([]string{"channel-enable", "-unknown"}, &out, failInit)
+	assert.ErrorContains(t, err, "flag provided but not defined")
+
+	err = RunAdmin([]string{"fix-ability", "extra"}, &out, failInit)
+	assert.ErrorContains(t, err, "unexpected arguments")
+}
+
+func TestParseIds(t *testing.T) {
+	ids, err := parseIds("1, 2,,3")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, ids)
+
+	_, err = parseIds("1,x")
+	assert.Error(t, err)
+	_, err = parseIds(" , ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+)
+
+// PassphraseEnv holds the passphrase for encrypted secrets in config
+// documents. It is read from the environment so it never shows up in ps.
+const PassphraseEnv = "CONFIG_PASSPHRASE"
+
+const logCleanupBatchSize = 1000
+
+func runCreateAdmin(ctx *commandContext) error {
+	username := ctx.flags.String("username", "", "username of the new account (required)")
+	password := ctx.flags.String("password", "", "password; a random one is generated and printed when empty")
+	root := ctx.flags.Bool("root", false, "create a root user instead of an administrator")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+	if _, err := model.GetUserByUsername(*username); err == nil {
+		return fmt.Errorf("user %s already exists, use reset-admin instead", *username)
+	}
+	generated := *password == ""
+	if generated {
+		*password = common.GetRandomString(16)
+	}
+	role := common.RoleAdminUser
+	if *root {
+		role = common.RoleRootUser
+	}
+	user := &model.User{
+		Username:    *username,
+		Password:    *password,
+		DisplayName: *username,
+		Role:        role,
+		Status:      common.UserStatusEnabled,
+	}
+	if err := user.Insert(0); err != nil {
+		return err
+	}
+	ctx.printf("created user %s (id %d, role %d)\n", user.Username, user.Id, user.Role)
+	if generated {
+		ctx.printf("password: %s\n", *password)
+	}
+	return nil
+}
+
+func runResetAdmin(ctx *commandContext) error {
+	username := ctx.flags.String("username", "", "username of the account to reset (required)")
+	password := ctx.flags.String("password", "", "new password; a random one is generated and printed when empty")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	user, err := model.GetUserByUsername(*username)
+	if err != nil {
+		return err
+	}
+	generated := *password == ""
+	if generated {
+		*password = common.GetRandomString(16)
+	}
+	if err := model.ResetUserPassword(user.Id, *password); err != nil {
+		return err
+	}
+	ctx.printf("reset password of %s (id %d); existing sessions were revoked\n", user.Username, user.Id)
+	if generated {
+		ctx.printf("password: %s\n", *password)
+	}
+	return nil
+}
+
+func runResetTwoFA(ctx *commandContext) error {
+	username := ctx.flags.String("username", "", "username of the account (required)")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	user, err := model.GetUserByUsername(*username)
+	if err != nil {
+		return err
+	}
+	if err := model.DisableTwoFAWithAuthVersion(user.Id); err != nil {
+		if errors.Is(err, model.ErrTwoFANotEnabled) {
+			ctx.printf("two-factor authentication is not enabled for %s\n", user.Username)
+			return nil
+		}
+		return err
+	}
+	ctx.printf("removed two-factor authentication from %s (id %d)\n", user.Username, user.Id)
+	return nil
+}
+
+func runRotateToken(ctx *commandContext) error {
+	id := ctx.flags.Int("id", 0, "token id (required)")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	token, err := model.RotateTokenKey(*id)
+	if err != nil {
+		return err
+	}
+	ctx.printf("rotated token %s (id %d); the old key no longer works\n", token.Name, token.Id)
+	ctx.printf("key: %s\n", token.GetFullKey())
+	return nil
+}
+
+func runChannelEnable(ctx *commandContext) error {
+	return runChannelStatus(ctx, true)
+}
+
+func runChannelDisable(ctx *commandContext) error {
+	return runChannelStatus(ctx, false)
+}
+
+func runChannelStatus(ctx *commandContext, enabled bool) error {
+	idList := ctx.flags.String("ids", "", "comma separated channel ids")
+	tag := ctx.flags.String("tag", "", "channel tag")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	if (*idList == "") == (*tag == "") {
+		return errors.New("exactly one of -ids and -tag is required")
+	}
+	action := "disabled"
+	if enabled {
+		action = "enabled"
+	}
+	if *tag != "" {
+		var err error
+		if enabled {
+			err = model.EnableChannelByTag(*tag)
+		} else {
+			err = model.DisableChannelByTag(*tag)
+		}
+		if err != nil {
+			return err
+		}
+		ctx.printf("%s channels tagged %s\n", action, *tag)
+		return nil
+	}
+	ids, err := parseIds(*idList)
+	if err != nil {
+		return err
+	}
+	count, err := model.UpdateChannelStatusByIds(ids, enabled)
+	if err != nil {
+		return err
+	}
+	ctx.printf("%s %d of %d channels\n", action, count, len(ids))
+	return nil
+}
+
+func runFixAbility(ctx *commandContext) error {
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	success, fails, err := model.FixAbility()
+	if err != nil {
+		return err
+	}
+	ctx.printf("fixed abilities: %d channels succeeded, %d failed\n", success, fails)
+	return nil
+}
+
+func runCleanLogs(ctx *commandContext) error {
+	days := ctx.flags.Int("days", 0, "delete logs older than this many days")
+	before := ctx.flags.Int64("before", 0, "delete logs created before this unix timestamp")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	if (*days > 0) == (*before > 0) {
+		return errors.New("exactly one of -days and -before is required")
+	}
+	target := *before
+	if *days > 0 {
+		target = time.Now().AddDate(0, 0, -*days).Unix()
+	}
+	var total int64
+	for {
+		deleted, err := model.DeleteOldLogBatch(context.Background(), target, logCleanupBatchSize)
+		if err != nil {
+			return err
+		}
+		total += deleted
+		if deleted == 0 {
+			break
+		}
+		ctx.printf("deleted %d logs\r", total)
+	}
+	ctx.printf("deleted %d logs created before %s\n", total, time.Unix(target, 0).Format(time.RFC3339))
+	return nil
+}
+
+func runRecomputeQuota(ctx *commandContext) error {
+	userId := ctx.flags.Int("user", 0, "only recompute this user id; all users when 0")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	if !common.LogConsumeEnabled {
+		ctx.printf("warning: consume logging is disabled, recent usage may be missing from logs\n")
+	}
+	if cutoff, err := model.GetLogCleanupCutoff(); err == nil && cutoff > 0 {
+		ctx.printf("warning: logs before %s were cleaned up, usage of users registered earlier will only be raised\n", time.Unix(cutoff, 0).Format(time.RFC3339))
+	}
+	count, err := model.RecomputeUserUsedQuota(*userId)
+	if err != nil {
+		return err
+	}
+	ctx.printf("recomputed used quota of %d users\n", count)
+	return nil
+}
+
+func runConfigExport(ctx *commandContext) error {
+	output := ctx.flags.String("o", "", "output file; stdout when empty")
+	format := ctx.flags.String("format", "yaml", "yaml or json")
+	secrets := ctx.flags.String("secrets", "reference", "reference, or encrypted with $"+PassphraseEnv)
+	options := ctx.flags.String("options", "", "comma separated extra option keys to export")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	opts := service.ConfigBundleExportOptions{
+		Secrets:    *secrets,
+		Passphrase: os.Getenv(PassphraseEnv),
+	}
+	if *options != "" {
+		opts.Options = strings.Split(*options, ",")
+	}
+	bundle, err := service.ExportConfigBundle(opts)
+	if err != nil {
+		return err
+	}
+	data, err := service.MarshalConfigBundle(bundle, *format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = ctx.out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		return err
+	}
+	ctx.printf("exported %d channels to %s\n", len(bundle.Channels), *output)
+	return nil
+}
+
+func runConfigImport(ctx *commandContext) error {
+	input := ctx.flags.String("f", "", "config document to apply (required)")
+	prune := ctx.flags.Bool("prune", false, "delete channels that are not in the document")
+	dryRun := ctx.flags.Bool("dry-run", false, "print the changes without applying them")
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	if *input == "" {
+		return errors.New("-f is required")
+	}
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+	bundle, err := service.ParseConfigBundle(data)
+	if err != nil {
+		return err
+	}
+	opts := service.ConfigBundleApplyOptions{
+		Passphrase: os.Getenv(PassphraseEnv),
+		Prune:      *prune,
+	}
+	var plan *service.ConfigBundlePlan
+	if *dryRun {
+		plan, err = service.PlanConfigBundle(bundle, opts)
+	} else {
+		plan, err = service.ApplyConfigBundle(bundle, opts)
+	}
+	if err != nil {
+		return err
+	}
+	for _, change := range plan.Changes {
+		line := fmt.Sprintf("%-6s %-7s %s", change.Action, change.Kind, change.Name)
+		if len(change.Fields) > 0 {
+			line += " (" + strings.Join(change.Fields, ", ") + ")"
+		}
+		ctx.printf("%s\n", line)
+	}
+	verb := "applied"
+	if *dryRun {
+		verb = "planned"
+	}
+	ctx.printf("%s %d created, %d updated, %d deleted\n", verb, plan.Creates, plan.Updates, plan.Deletes)
+	return nil
+}
+
+func parseIds(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no channel ids given")
+	}
+	return ids, nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+)
+
+func migrationTargets(target string) ([]model.MigrationTarget, error) {
+	switch target {
+	case "all":
+		return []model.MigrationTarget{model.MigrationTargetMain, model.MigrationTargetLog}, nil
+	case string(model.MigrationTargetMain), string(model.MigrationTargetLog):
+		return []model.MigrationTarget{model.MigrationTarget(target)}, nil
+	default:
+		return nil, fmt.Errorf("unknown target %q, expected main, log or all", target)
+	}
+}
+
+func runMigrate(ctx *commandContext) error {
+	target := ctx.flags.String("target", "all", "main, log or all")
+	dryRun := ctx.flags.Bool("dry-run", false, "print pending SQL without executing it")
+	// 由本命令决定是否执行迁移，初始化数据库时不自动执行；dry-run 连 AutoMigrate 也跳过
+	model.SkipAutoSchemaMigrations = true
+	if err := ctx.readyWith(func() { model.SkipAutoMigrate = *dryRun }); err != nil {
+		return err
+	}
+	if *dryRun {
+		ctx.printf("-- AutoMigrate was skipped; it creates missing tables and columns before these migrations run\n")
+	}
+	targets, err := migrationTargets(*target)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		migrations, err := model.RunSchemaMigrations(t, *dryRun, ctx.out)
+		if err != nil {
+			return err
+		}
+		if !*dryRun {
+			ctx.printf("applied %d %s migrations\n", len(migrations), t)
+		} else if len(migrations) == 0 {
+			ctx.printf("-- no pending %s migrations\n", t)
+		}
+	}
+	return nil
+}
+
+func runMigrateDown(ctx *commandContext) error {
+	target := ctx.flags.String("target", "main", "main or log")
+	to := ctx.flags.Int("to", -1, "roll back every migration with a version greater than this (required)")
+	dryRun := ctx.flags.Bool("dry-run", false, "print rollback SQL without executing it")
+	model.SkipAutoSchemaMigrations = true
+	if err := ctx.readyWith(func() { model.SkipAutoMigrate = *dryRun }); err != nil {
+		return err
+	}
+	if *to < 0 {
+		return fmt.Errorf("-to is required")
+	}
+	if *target == "all" {
+		return fmt.Errorf("roll back one target at a time")
+	}
+	targets, err := migrationTargets(*target)
+	if err != nil {
+		return err
+	}
+	migrations, err := model.RollbackSchemaMigrations(targets[0], *to, *dryRun, ctx.out)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		ctx.printf("rolled back %d %s migrations\n", len(migrations), targets[0])
+	}
+	return nil
+}
+
+func runMigrateStatus(ctx *commandContext) error {
+	model.SkipAutoMigrate = true
+	if err := ctx.ready(); err != nil {
+		return err
+	}
+	for _, t := range []model.MigrationTarget{model.MigrationTargetMain, model.MigrationTargetLog} {
+		statuses, err := model.GetSchemaMigrationStatus(t)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + time.Unix(status.AppliedAt, 0).Format(time.RFC3339)
+			}
+			ctx.printf("%-4s %4d  %-45s %s\n", status.Target, status.Version, status.Name, state)
+		}
+	}
+	return nil
+}
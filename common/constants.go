@@ -34,6 +34,10 @@ var DefaultCollapseSidebar = false // default value of collapse sidebar
 
 var SessionSecret = uuid.New().String()
 var CryptoSecret = uuid.New().String()
+
+// CryptoSecretConfigured reports whether CryptoSecret comes from SESSION_SECRET or
+// CRYPTO_SECRET; otherwise it is a random value that changes on every restart.
+var CryptoSecretConfigured = false
 var SessionCookieSecure = false
 var SessionCookieTrustedURLs []string
 
@@ -66,6 +70,7 @@ var GitHubOAuthEnabled = false
 var LinuxDOOAuthEnabled = false
 var WeChatAuthEnabled = false
 var TelegramOAuthEnabled = false
+var SCIMEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 
@@ -121,6 +126,9 @@ var TurnstileSecretKey = ""
 var TelegramBotToken = ""
 var TelegramBotName = ""
 
+// SCIMBearerToken 是身份源调用 SCIM 接口时使用的 Bearer 令牌
+var SCIMBearerToken = ""
+
 var QuotaForNewUser = 0
 var QuotaForInviter = 0
 var QuotaForInvitee = 0
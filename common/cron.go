@@ -0,0 +1,163 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed standard 5-field cron expression
+// (minute hour day-of-month month day-of-week). Each field supports "*",
+// lists ("1,3,5"), ranges ("9-17") and steps ("*/15", "0-30/10"). The two day
+// fields also accept "?" as a synonym for "*". Day-of-week accepts 0-7 where
+// both 0 and 7 mean Sunday.
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	// domStar / dowStar follow the classic cron rule: when both day fields are
+	// restricted, a time matches if either of them matches. A field counts as
+	// unrestricted when it starts with "*" (including steps such as "*/2") or is "?".
+	domStar bool
+	dowStar bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week
+}
+
+// ParseCronExpression parses a 5-field cron expression.
+func ParseCronExpression(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+	schedule := &CronSchedule{
+		domStar: isCronDayWildcard(fields[2]),
+		dowStar: isCronDayWildcard(fields[4]),
+	}
+	for i, field := range fields {
+		if field == "?" {
+			if i != 2 && i != 4 {
+				return nil, fmt.Errorf("invalid cron field %q: \"?\" is only allowed in day fields", field)
+			}
+			field = "*"
+		}
+		values, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		for _, v := range values {
+			switch i {
+			case 0:
+				schedule.minutes[v] = true
+			case 1:
+				schedule.hours[v] = true
+			case 2:
+				schedule.days[v] = true
+			case 3:
+				schedule.months[v] = true
+			case 4:
+				schedule.weekdays[v%7] = true
+			}
+		}
+	}
+	return schedule, nil
+}
+
+func isCronDayWildcard(field string) bool {
+	return field == "?" || strings.HasPrefix(field, "*")
+}
+
+func parseCronField(field string, bounds cronField) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return nil, fmt.Errorf("empty list item")
+		}
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			parsedStep, err := strconv.Atoi(part[idx+1:])
+			if err != nil || parsedStep <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part[idx+1:])
+			}
+			step = parsedStep
+		}
+		start, end := bounds.min, bounds.max
+		if rangePart != "*" {
+			if idx := strings.Index(rangePart, "-"); idx >= 0 {
+				var err error
+				if start, err = strconv.Atoi(rangePart[:idx]); err != nil {
+					return nil, err
+				}
+				if end, err = strconv.Atoi(rangePart[idx+1:]); err != nil {
+					return nil, err
+				}
+			} else {
+				v, err := strconv.Atoi(rangePart)
+				if err != nil {
+					return nil, err
+				}
+				start = v
+				end = v
+				if step > 1 {
+					end = bounds.max
+				}
+			}
+		}
+		if start < bounds.min || end > bounds.max || start > end {
+			return nil, fmt.Errorf("value out of range %d-%d", bounds.min, bounds.max)
+		}
+		for v := start; v <= end; v += step {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// Matches reports whether t (in its own location) matches the schedule at
+// minute granularity.
+func (s *CronSchedule) Matches(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+	domMatch := s.days[t.Day()]
+	dowMatch := s.weekdays[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// ActiveWithin reports whether a window of the given length that opens on
+// every schedule match covers t, i.e. whether the schedule matched at some
+// minute in (t-window, t].
+func (s *CronSchedule) ActiveWithin(t time.Time, window time.Duration) bool {
+	if s == nil || window <= 0 {
+		return false
+	}
+	current := t.Truncate(time.Minute)
+	steps := int((window + time.Minute - 1) / time.Minute)
+	for i := 0; i < steps; i++ {
+		if s.Matches(current) {
+			return true
+		}
+		current = current.Add(-time.Minute)
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronExpression(t *testing.T) {
+	schedule, err := ParseCronExpression("*/15 9-17 * * 1-5")
+	require.NoError(t, err)
+
+	loc := time.UTC
+	// Monday 2026-10-19 09:30
+	assert.True(t, schedule.Matches(time.Date(2026, 10, 19, 9, 30, 0, 0, loc)))
+	assert.False(t, schedule.Matches(time.Date(2026, 10, 19, 9, 31, 0, 0, loc)))
+	assert.False(t, schedule.Matches(time.Date(2026, 10, 19, 18, 0, 0, 0, loc)))
+	// Sunday
+	assert.False(t, schedule.Matches(time.Date(2026, 10, 18, 9, 30, 0, 0, loc)))
+
+	sunday, err := ParseCronExpression("0 0 * * 7")
+	require.NoError(t, err)
+	assert.True(t, sunday.Matches(time.Date(2026, 10, 18, 0, 0, 0, 0, loc)))
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := ParseCronExpression(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronScheduleActiveWithin(t *testing.T) {
+	schedule, err := ParseCronExpression("0 22 * * *")
+	require.NoError(t, err)
+
+	loc := time.FixedZone("UTC+8", 8*3600)
+	window := 8 * time.Hour
+	assert.True(t, schedule.ActiveWithin(time.Date(2026, 10, 18, 22, 0, 0, 0, loc), window))
+	assert.True(t, schedule.ActiveWithin(time.Date(2026, 10, 19, 5, 59, 0, 0, loc), window))
+	assert.False(t, schedule.ActiveWithin(time.Date(2026, 10, 19, 6, 0, 0, 0, loc), window))
+	assert.False(t, schedule.ActiveWithin(time.Date(2026, 10, 18, 21, 59, 0, 0, loc), window))
+}
+
+func TestParseCronExpressionDayWildcards(t *testing.T) {
+	loc := time.UTC
+	// "*/2" in day-of-month is unrestricted for the OR rule, so both day fields must match
+	everyOtherDayMonday, err := ParseCronExpression("0 0 */2 * 1")
+	require.NoError(t, err)
+	// Monday 2026-10-19 (odd day)
+	assert.True(t, everyOtherDayMonday.Matches(time.Date(2026, 10, 19, 0, 0, 0, 0, loc)))
+	// Monday 2026-10-26 (even day)
+	assert.False(t, everyOtherDayMonday.Matches(time.Date(2026, 10, 26, 0, 0, 0, 0, loc)))
+	// Tuesday 2026-10-21 (odd day)
+	assert.False(t, everyOtherDayMonday.Matches(time.Date(2026, 10, 21, 0, 0, 0, 0, loc)))
+
+	question, err := ParseCronExpression("30 8 ? * 1-5")
+	require.NoError(t, err)
+	assert.True(t, question.Matches(time.Date(2026, 10, 19, 8, 30, 0, 0, loc)))
+	assert.False(t, question.Matches(time.Date(2026, 10, 18, 8, 30, 0, 0, loc)))
+
+	_, err = ParseCronExpression("? * * * *")
+	assert.Error(t, err)
+}
@@ -1,11 +1,18 @@
 package common
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
 )
 
 func GenerateHMACWithKey(key []byte, data string) string {
@@ -30,3 +37,74 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// PassphraseCipher encrypts with AES-256-GCM using a key derived from a
+// passphrase by scrypt. The salt is shared by every value encrypted with the
+// same cipher so the expensive derivation runs once per document.
+type PassphraseCipher struct {
+	salt []byte
+	aead cipher.AEAD
+}
+
+// NewPassphraseCipher derives the key from passphrase and the base64 salt;
+// an empty salt generates a new random one.
+func NewPassphraseCipher(passphrase string, salt string) (*PassphraseCipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	var saltBytes []byte
+	if salt == "" {
+		saltBytes = make([]byte, 16)
+		if _, err := rand.Read(saltBytes); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if saltBytes, err = base64.StdEncoding.DecodeString(salt); err != nil {
+			return nil, fmt.Errorf("invalid salt: %w", err)
+		}
+	}
+	key, err := scrypt.Key([]byte(passphrase), saltBytes, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PassphraseCipher{salt: saltBytes, aead: aead}, nil
+}
+
+// Salt returns the base64 salt needed to derive the same key again.
+func (p *PassphraseCipher) Salt() string {
+	return base64.StdEncoding.EncodeToString(p.salt)
+}
+
+// Encrypt returns base64(nonce|ciphertext).
+func (p *PassphraseCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(p.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// Decrypt reverses Encrypt.
+func (p *PassphraseCipher) Decrypt(ciphertext string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(blob) < p.aead.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plaintext, err := p.aead.Open(nil, blob[:p.aead.NonceSize()], blob[p.aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt: wrong passphrase or corrupted data")
+	}
+	return string(plaintext), nil
+}
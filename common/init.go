@@ -27,6 +27,7 @@ func printHelp() {
 	fmt.Println("Original Project: OneAPI by JustSong - https://github.com/songquanpeng/one-api")
 	fmt.Println("Maintainer: QuantumNous - https://github.com/QuantumNous/new-api")
 	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("       newapi admin <command> [flags]   run an administrative command, see 'newapi admin help'")
 }
 
 func InitEnv() {
@@ -55,10 +56,12 @@ func InitEnv() {
 			log.Fatal("Please set SESSION_SECRET to a random string.")
 		} else {
 			SessionSecret = ss
+			CryptoSecretConfigured = true
 		}
 	}
 	if os.Getenv("CRYPTO_SECRET") != "" {
 		CryptoSecret = os.Getenv("CRYPTO_SECRET")
+		CryptoSecretConfigured = true
 	} else {
 		CryptoSecret = SessionSecret
 	}
@@ -3,6 +3,13 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom   MultiKeyMode = "random"   // 随机
+	MultiKeyModePolling  MultiKeyMode = "polling"  // 轮询
+	MultiKeyModeHeadroom MultiKeyMode = "headroom" // 限流感知：优先剩余额度最多的 Key
+	MultiKeyModeLRU      MultiKeyMode = "lru"      // 限流感知：优先最久未使用的 Key
 )
+
+// IsRateLimitAware 返回该模式是否跟踪每个 Key 的 RPM/TPM 用量并在 429 后冷却 Key
+func (m MultiKeyMode) IsRateLimitAware() bool {
+	return m == MultiKeyModeHeadroom || m == MultiKeyModeLRU
+}
@@ -16,19 +16,35 @@ import (
 // action 的 params 填充。本地化展示文案在前端 i18n 模板中维护，本表是语言中立的
 // 英文基线——调用方因此无需在每个埋点处手写句子（避免与 params 重复书写同一份值）。
 var auditContentTemplates = map[string]string{
-	"user.create":           "Created user ${username} (role ${role})",
-	"user.update":           "Updated user ${username} (ID: ${id})",
-	"user.delete":           "Deleted user ${username} (ID: ${id})",
-	"user.manage":           "Performed ${action} on user ${username} (ID: ${id})",
-	"user.quota_add":        "Increased user quota by ${quota}",
-	"user.quota_subtract":   "Decreased user quota by ${quota}",
-	"user.quota_override":   "Overrode user quota from ${from} to ${to}",
-	"user.binding_clear":    "Cleared ${bindingType} binding for user ${username}",
-	"user.2fa_disable":      "Force-disabled two-factor authentication for the user",
-	"user.passkey_register": "Registered a passkey",
-	"user.passkey_delete":   "Deleted a passkey",
-	"user.reset_passkey":    "Reset the user passkey",
-	"option.update":         "Updated system setting ${key}",
+	"user.create":             "Created user ${username} (role ${role})",
+	"user.update":             "Updated user ${username} (ID: ${id})",
+	"user.delete":             "Deleted user ${username} (ID: ${id})",
+	"user.manage":             "Performed ${action} on user ${username} (ID: ${id})",
+	"user.quota_add":          "Increased user quota by ${quota}",
+	"user.quota_subtract":     "Decreased user quota by ${quota}",
+	"user.quota_override":     "Overrode user quota from ${from} to ${to}",
+	"user.binding_clear":      "Cleared ${bindingType} binding for user ${username}",
+	"user.2fa_disable":        "Force-disabled two-factor authentication for the user",
+	"user.passkey_register":   "Registered a passkey",
+	"user.passkey_delete":     "Deleted a passkey",
+	"user.reset_passkey":      "Reset the user passkey",
+	"token.delete":            "Deleted token ${id} of user ${username}",
+	"authz.role_create":       "Created role ${key}",
+	"authz.role_update":       "Updated role ${key}",
+	"authz.role_delete":       "Deleted role ${key}",
+	"authz.user_roles":        "Set roles of user ${username} to ${roles}",
+	"scim.user_create":        "SCIM provisioned user ${username} (ID: ${id})",
+	"scim.user_update":        "SCIM updated user ${username} (ID: ${id})",
+	"scim.user_suspend":       "SCIM suspended user ${username} (ID: ${id})",
+	"scim.user_activate":      "SCIM reactivated user ${username} (ID: ${id})",
+	"scim.user_delete":        "SCIM deleted user ${username} (ID: ${id})",
+	"scim.user_group":         "SCIM moved user ${username} from group ${from} to ${to}",
+	"oauth.claim_mapping":     "Claim mappings of ${provider} updated entitlements of user ${username} (ID: ${id})",
+	"option.update":           "Updated system setting ${key}",
+	"option.rollback":         "Rolled back system settings to revision ${id} (${count} settings)",
+	"option.change_set.apply": "Applied system setting change set ${id} (${count} settings)",
+	"config.export":           "Exported configuration (${channels} channels)",
+	"config.apply":            "Applied configuration (${creates} created, ${updates} updated, ${deletes} deleted)",
 
 	"channel.create":             "Created channel ${name} (type ${type}, count ${count})",
 	"channel.update":             "Updated channel ${name} (ID: ${id})",
@@ -44,6 +60,10 @@ var auditContentTemplates = map[string]string{
 	"channel.multi_key_manage":   "Multi-key management ${action} on channel (ID: ${id})",
 	"channel.upstream_apply":     "Applied upstream model changes to channel (ID: ${id})",
 	"channel.upstream_apply_all": "Applied upstream model changes to ${count} channels",
+	"channel.schedule_create":    "Created channel schedule ${name} (ID: ${id}) on ${target}",
+	"channel.schedule_update":    "Updated channel schedule ${name} (ID: ${id}) on ${target}",
+	"channel.schedule_delete":    "Deleted channel schedule ${name} (ID: ${id})",
+	"channel.maintenance_create": "Scheduled maintenance for channel ${name} (ID: ${id})",
 
 	"redemption.create": "Created ${count} redemption codes named ${name} (${quota} each)",
 
@@ -2,7 +2,11 @@ package controller
 
 import (
 	"net/http"
+	"strings"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/service/authz"
 
 	"github.com/gin-gonic/gin"
@@ -11,8 +15,13 @@ import (
 // GetPermissionCatalog returns the permission schema used by the client to
 // render the permission editor: the registry of resources with their actions
 // and display label keys, plus the roles with their baseline grant matrices.
-// Defining it in the authz package keeps the schema in a single place.
+// Defining it in the authz package keeps the schema in a single place. Only
+// subjects that can edit roles or user permissions may read it.
 func GetPermissionCatalog(c *gin.Context) {
+	if !middleware.CanAccess(c, authz.RoleManage) && !middleware.CanAccess(c, authz.UserManage) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -22,3 +31,92 @@ func GetPermissionCatalog(c *gin.Context) {
 		},
 	})
 }
+
+func GetAuthzRoles(c *gin.Context) {
+	common.ApiSuccess(c, authz.Roles())
+}
+
+func CreateAuthzRole(c *gin.Context) {
+	var role authz.CustomRole
+	if err := common.DecodeJson(c.Request.Body, &role); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := authz.CreateCustomRole(role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_create", map[string]interface{}{
+		"key": role.Key,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateAuthzRole(c *gin.Context) {
+	var role authz.CustomRole
+	if err := common.DecodeJson(c.Request.Body, &role); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	role.Key = c.Param("key")
+	if err := authz.UpdateCustomRole(role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_update", map[string]interface{}{
+		"key": role.Key,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func DeleteAuthzRole(c *gin.Context) {
+	key := c.Param("key")
+	if err := authz.DeleteCustomRole(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_delete", map[string]interface{}{
+		"key": key,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// GetUserAuthzRoles returns the custom roles assigned to a user together with
+// the permissions the user ends up with.
+func GetUserAuthzRoles(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"roles":        authz.UserRoleKeys(user.Id),
+		"capabilities": authz.Capabilities(user.Id, user.Role),
+	})
+}
+
+type userAuthzRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// UpdateUserAuthzRoles replaces the custom roles of a user. Operators cannot
+// change their own roles.
+func UpdateUserAuthzRoles(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	var req userAuthzRolesRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := authz.SetUserRoles(user.Id, req.Roles); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, user.Id, "authz.user_roles", map[string]interface{}{
+		"username": user.Username,
+		"roles":    strings.Join(req.Roles, ", "),
+	})
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type billingExprSimulateParams struct {
+	P    float64 `json:"p"`
+	C    float64 `json:"c"`
+	Len  float64 `json:"len"`
+	CR   float64 `json:"cr"`
+	CC   float64 `json:"cc"`
+	CC1h float64 `json:"cc1h"`
+	Img  float64 `json:"img"`
+	ImgO float64 `json:"img_o"`
+	AI   float64 `json:"ai"`
+	AO   float64 `json:"ao"`
+}
+
+type billingExprSimulateRequest struct {
+	Expr    string                    `json:"expr"`  // 为空时使用 model 当前配置的表达式
+	Model   string                    `json:"model"` // 仅在 expr 为空时使用
+	Group   string                    `json:"group"` // 为空时分组倍率按 1 计算
+	Params  billingExprSimulateParams `json:"params"`
+	Headers map[string]string         `json:"headers"`
+	Body    json.RawMessage           `json:"body"`
+}
+
+// SimulateBillingExpr 使用给定的 token 数与请求头/请求体试算计费表达式，返回完整的执行轨迹，
+// 便于管理员在保存表达式之前验证分段与条件是否符合预期
+func SimulateBillingExpr(c *gin.Context) {
+	var req billingExprSimulateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	exprStr := strings.TrimSpace(req.Expr)
+	if exprStr == "" && req.Model != "" {
+		exprStr, _ = billing_setting.GetBillingExpr(req.Model)
+	}
+	if exprStr == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "expr is required",
+		})
+		return
+	}
+	groupRatio := 1.0
+	if req.Group != "" {
+		groupRatio = ratio_setting.GetGroupRatio(req.Group)
+	}
+	// len 未填写时与 p 相同，和非 Claude 请求的实际取值一致
+	if req.Params.Len == 0 {
+		req.Params.Len = req.Params.P
+	}
+
+	result, err := billingexpr.Simulate(exprStr, billingexpr.TokenParams(req.Params), billingexpr.RequestInput{
+		Headers: req.Headers,
+		Body:    req.Body,
+	}, common.QuotaPerUnit, groupRatio)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    result,
+		})
+		return
+	}
+	common.ApiSuccess(c, result)
+}
+
+// CreateBillingRepriceSystemTask 创建历史日志重新计价任务：使用候选表达式或倍率重算时间窗口内的消费日志，
+// 按模型与用户汇总收入变化。结果通过系统任务接口查询
+func CreateBillingRepriceSystemTask(c *gin.Context) {
+	var payload service.BillingRepricePayload
+	if err := common.DecodeJson(c.Request.Body, &payload); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	task, created, err := service.StartBillingRepriceTask(payload)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "已有重新计价任务正在运行或等待中",
+			"data": gin.H{
+				"task_id": task.TaskID,
+				"status":  task.Status,
+				"type":    task.Type,
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    task.ToResponse(),
+	})
+}
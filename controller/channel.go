@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	relaychannel "github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/ollama"
@@ -407,6 +408,7 @@ func GetChannel(c *gin.Context) {
 	}
 	if channel != nil {
 		clearChannelInfo(channel)
+		channel.UpstreamRateLimit = model.GetChannelUpstreamRateLimit(channel)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -470,74 +472,6 @@ func validateTwoFactorAuth(twoFA *model.TwoFA, code string) bool {
 	return false
 }
 
-// validateChannel 通用的渠道校验函数
-func validateChannel(channel *model.Channel, isAdd bool) error {
-	if channel == nil {
-		return fmt.Errorf("channel cannot be empty")
-	}
-
-	// 校验 channel settings
-	if err := channel.ValidateSettings(); err != nil {
-		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
-	}
-
-	if channel.Type == constant.ChannelTypeNewAPI && strings.TrimSpace(channel.GetBaseURL()) == "" {
-		return fmt.Errorf("New API channel base URL cannot be empty")
-	}
-
-	// 如果是添加操作，检查 channel 和 key 是否为空
-	if isAdd {
-		if channel.Key == "" {
-			return fmt.Errorf("channel cannot be empty")
-		}
-
-		// 检查模型名称长度是否超过 255
-		for _, m := range channel.GetModels() {
-			if len(m) > 255 {
-				return fmt.Errorf("模型名称过长: %s", m)
-			}
-		}
-	}
-
-	// VertexAI 特殊校验
-	if channel.Type == constant.ChannelTypeVertexAi {
-		if channel.Other == "" {
-			return fmt.Errorf("部署地区不能为空")
-		}
-
-		regionMap, err := common.StrToMap(channel.Other)
-		if err != nil {
-			return fmt.Errorf("部署地区必须是标准的Json格式，例如{\"default\": \"us-central1\", \"region2\": \"us-east1\"}")
-		}
-
-		if regionMap["default"] == nil {
-			return fmt.Errorf("部署地区必须包含default字段")
-		}
-	}
-
-	// Codex OAuth key validation (optional, only when JSON object is provided)
-	if channel.Type == constant.ChannelTypeCodex {
-		trimmedKey := strings.TrimSpace(channel.Key)
-		if isAdd || trimmedKey != "" {
-			if !strings.HasPrefix(trimmedKey, "{") {
-				return fmt.Errorf("Codex key must be a valid JSON object")
-			}
-			var keyMap map[string]any
-			if err := common.Unmarshal([]byte(trimmedKey), &keyMap); err != nil {
-				return fmt.Errorf("Codex key must be a valid JSON object")
-			}
-			if v, ok := keyMap["access_token"]; !ok || v == nil || strings.TrimSpace(fmt.Sprintf("%v", v)) == "" {
-				return fmt.Errorf("Codex key JSON must include access_token")
-			}
-			if v, ok := keyMap["account_id"]; !ok || v == nil || strings.TrimSpace(fmt.Sprintf("%v", v)) == "" {
-				return fmt.Errorf("Codex key JSON must include account_id")
-			}
-		}
-	}
-
-	return nil
-}
-
 func RefreshCodexChannelCredential(c *gin.Context) {
 	channelId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -573,6 +507,8 @@ func RefreshCodexChannelCredential(c *gin.Context) {
 type AddChannelRequest struct {
 	Mode                      string                `json:"mode"`
 	MultiKeyMode              constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyRPMLimit          int                   `json:"multi_key_rpm_limit"`
+	MultiKeyTPMLimit          int                   `json:"multi_key_tpm_limit"`
 	BatchAddSetKeyPrefix2Name bool                  `json:"batch_add_set_key_prefix_2_name"`
 	Channel                   *model.Channel        `json:"channel"`
 }
@@ -618,7 +554,7 @@ func AddChannel(c *gin.Context) {
 	}
 
 	// 使用统一的校验函数
-	if err := validateChannel(addChannelRequest.Channel, true); err != nil {
+	if err := service.ValidateChannel(addChannelRequest.Channel, true); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -632,6 +568,8 @@ func AddChannel(c *gin.Context) {
 	case "multi_to_single":
 		addChannelRequest.Channel.ChannelInfo.IsMultiKey = true
 		addChannelRequest.Channel.ChannelInfo.MultiKeyMode = addChannelRequest.MultiKeyMode
+		addChannelRequest.Channel.ChannelInfo.MultiKeyRPMLimit = max(addChannelRequest.MultiKeyRPMLimit, 0)
+		addChannelRequest.Channel.ChannelInfo.MultiKeyTPMLimit = max(addChannelRequest.MultiKeyTPMLimit, 0)
 		if addChannelRequest.Channel.Type == constant.ChannelTypeVertexAi && addChannelRequest.Channel.GetOtherSettings().VertexKeyType != dto.VertexKeyTypeAPIKey {
 			array, err := getVertexArrayKeys(addChannelRequest.Channel.Key)
 			if err != nil {
@@ -850,7 +788,7 @@ func EditTagChannels(c *gin.Context) {
 		return
 	}
 	if (channelTag.ParamOverride != nil || channelTag.HeaderOverride != nil) &&
-		!authz.Can(c.GetInt("id"), c.GetInt("role"), authz.ChannelSensitiveWrite) {
+		!middleware.CanAccess(c, authz.ChannelSensitiveWrite) {
 		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
 		return
 	}
@@ -929,8 +867,10 @@ func DeleteChannelBatch(c *gin.Context) {
 
 type PatchChannel struct {
 	model.Channel
-	MultiKeyMode *string `json:"multi_key_mode"`
-	KeyMode      *string `json:"key_mode"` // 多key模式下密钥覆盖或者追加
+	MultiKeyMode     *string `json:"multi_key_mode"`
+	MultiKeyRPMLimit *int    `json:"multi_key_rpm_limit"`
+	MultiKeyTPMLimit *int    `json:"multi_key_tpm_limit"`
+	KeyMode          *string `json:"key_mode"` // 多key模式下密钥覆盖或者追加
 }
 
 type ChannelStatusRequest struct {
@@ -965,7 +905,7 @@ func UpdateChannel(c *gin.Context) {
 	clearChannelReadOnlyFields(&channel, requestData)
 
 	// 使用统一的校验函数
-	if err := validateChannel(&channel.Channel, false); err != nil {
+	if err := service.ValidateChannel(&channel.Channel, false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -993,7 +933,7 @@ func UpdateChannel(c *gin.Context) {
 	channel.ChannelInfo = originChannel.ChannelInfo
 
 	if channelHasSensitiveChanges(&channel, originChannel, requestData) &&
-		!authz.Can(c.GetInt("id"), c.GetInt("role"), authz.ChannelSensitiveWrite) {
+		!middleware.CanAccess(c, authz.ChannelSensitiveWrite) {
 		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
 		return
 	}
@@ -1002,6 +942,12 @@ func UpdateChannel(c *gin.Context) {
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
 		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(*channel.MultiKeyMode)
 	}
+	if channel.MultiKeyRPMLimit != nil {
+		channel.ChannelInfo.MultiKeyRPMLimit = max(*channel.MultiKeyRPMLimit, 0)
+	}
+	if channel.MultiKeyTPMLimit != nil {
+		channel.ChannelInfo.MultiKeyTPMLimit = max(*channel.MultiKeyTPMLimit, 0)
+	}
 
 	// 处理多key模式下的密钥追加/覆盖逻辑
 	if channel.KeyMode != nil && channel.ChannelInfo.IsMultiKey {
@@ -1131,7 +1077,7 @@ func UpdateChannelStatus(c *gin.Context) {
 		return
 	}
 	req := ChannelStatusRequest{}
-	if err := c.ShouldBindJSON(&req); err != nil || !isManageableChannelStatus(req.Status) {
+	if err := c.ShouldBindJSON(&req); err != nil || !service.IsManageableChannelStatus(req.Status) {
 		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
 		return
 	}
@@ -1153,7 +1099,7 @@ func UpdateChannelStatus(c *gin.Context) {
 
 func BatchUpdateChannelStatus(c *gin.Context) {
 	req := ChannelStatusBatchRequest{}
-	if err := c.ShouldBindJSON(&req); err != nil || len(req.Ids) == 0 || !isManageableChannelStatus(req.Status) {
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Ids) == 0 || !service.IsManageableChannelStatus(req.Status) {
 		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
 		return
 	}
@@ -1178,10 +1124,6 @@ func BatchUpdateChannelStatus(c *gin.Context) {
 	})
 }
 
-func isManageableChannelStatus(status int) bool {
-	return status == common.ChannelStatusEnabled || status == common.ChannelStatusManuallyDisabled
-}
-
 // equalStringPtr 比较两个 *string 是否相等（均为 nil 视为相等）。
 func equalStringPtr(a, b *string) bool {
 	if a == nil && b == nil {
@@ -1265,7 +1207,7 @@ func buildAdvancedCustomModelPreviewChannel(req fetchModelsRequest) (*model.Chan
 		channel.SetSetting(channelSettings)
 	}
 
-	if err := validateChannel(channel, false); err != nil {
+	if err := service.ValidateChannel(channel, false); err != nil {
 		return nil, err
 	}
 	return channel, nil
@@ -1462,8 +1404,8 @@ func CopyChannel(c *gin.Context) {
 // MultiKeyManageRequest represents the request for multi-key management operations
 type MultiKeyManageRequest struct {
 	ChannelId int    `json:"channel_id"`
-	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status"
-	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, and delete_key actions
+	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status", "clear_key_cooldown"
+	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, delete_key and clear_key_cooldown (nil = all keys) actions
 	Page      int    `json:"page,omitempty"`      // for get_key_status pagination
 	PageSize  int    `json:"page_size,omitempty"` // for get_key_status pagination
 	Status    *int   `json:"status,omitempty"`    // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
@@ -1480,6 +1422,11 @@ type MultiKeyStatusResponse struct {
 	EnabledCount        int `json:"enabled_count"`
 	ManualDisabledCount int `json:"manual_disabled_count"`
 	AutoDisabledCount   int `json:"auto_disabled_count"`
+	CoolingDownCount    int `json:"cooling_down_count"`
+	// Rate-limit-aware scheduling (headroom / lru modes)
+	RateLimitAware bool `json:"rate_limit_aware"`
+	RPMLimit       int  `json:"rpm_limit,omitempty"`
+	TPMLimit       int  `json:"tpm_limit,omitempty"`
 }
 
 type KeyStatus struct {
@@ -1488,6 +1435,8 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	// Runtime scheduling state, shared across nodes when Redis is enabled
+	*model.MultiKeyRuntimeState
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1516,7 +1465,7 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 	}
 	if multiKeyActionRequiresSensitiveWrite(request.Action) &&
-		!authz.Can(c.GetInt("id"), c.GetInt("role"), authz.ChannelSensitiveWrite) {
+		!middleware.CanAccess(c, authz.ChannelSensitiveWrite) {
 		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
 		return
 	}
@@ -1550,7 +1499,9 @@ func ManageMultiKeys(c *gin.Context) {
 		}
 
 		// Statistics for all keys (unchanged by filtering)
-		var enabledCount, manualDisabledCount, autoDisabledCount int
+		var enabledCount, manualDisabledCount, autoDisabledCount, coolingDownCount int
+		runtimeStates := model.GetMultiKeyRuntimeStates(channel.Id)
+		now := time.Now()
 
 		// Build all key status data first
 		var allKeyStatusList []KeyStatus
@@ -1590,12 +1541,21 @@ func ManageMultiKeys(c *gin.Context) {
 				keyPreview = key[:10] + "..."
 			}
 
+			runtimeState := runtimeStates[i]
+			if runtimeState.CoolingDown(now) {
+				coolingDownCount++
+			} else if runtimeState != nil {
+				runtimeState.CooldownUntil = 0
+				runtimeState.CooldownReason = ""
+			}
+
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
-				Index:        i,
-				Status:       status,
-				DisabledTime: disabledTime,
-				Reason:       reason,
-				KeyPreview:   keyPreview,
+				Index:                i,
+				Status:               status,
+				DisabledTime:         disabledTime,
+				Reason:               reason,
+				KeyPreview:           keyPreview,
+				MultiKeyRuntimeState: runtimeState,
 			})
 		}
 
@@ -1646,6 +1606,10 @@ func ManageMultiKeys(c *gin.Context) {
 				EnabledCount:        enabledCount,        // Overall statistics
 				ManualDisabledCount: manualDisabledCount, // Overall statistics
 				AutoDisabledCount:   autoDisabledCount,   // Overall statistics
+				CoolingDownCount:    coolingDownCount,
+				RateLimitAware:      channel.ChannelInfo.MultiKeyMode.IsRateLimitAware(),
+				RPMLimit:            channel.ChannelInfo.MultiKeyRPMLimit,
+				TPMLimit:            channel.ChannelInfo.MultiKeyTPMLimit,
 			},
 		})
 		return
@@ -1879,6 +1843,8 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		model.ResetMultiKeyRuntimeStates(channel.Id)
+		model.ClearUpstreamRateLimit(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1947,6 +1913,8 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		model.ResetMultiKeyRuntimeStates(channel.Id)
+		model.ClearUpstreamRateLimit(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1955,6 +1923,25 @@ func ManageMultiKeys(c *gin.Context) {
 		})
 		return
 
+	case "clear_key_cooldown":
+		// 解除 429 冷却，Key 立即重新参与调度；不指定 key_index 时解除所有 Key
+		if request.KeyIndex != nil && (*request.KeyIndex < 0 || *request.KeyIndex >= channel.ChannelInfo.MultiKeySize) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "密钥索引超出范围",
+			})
+			return
+		}
+		if err := model.ClearMultiKeyCooldown(channel.Id, request.KeyIndex); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "密钥冷却已解除",
+		})
+		return
+
 	default:
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -88,6 +88,7 @@ var channelReadOnlyFields = map[string]struct{}{
 	"balance":              {},
 	"balance_updated_time": {},
 	"used_quota":           {},
+	"upstream_rate_limit":  {},
 }
 
 func clearChannelReadOnlyFields(channel *PatchChannel, requestData map[string]any) {
@@ -109,6 +110,9 @@ func clearChannelReadOnlyFields(channel *PatchChannel, requestData map[string]an
 	if _, ok := requestData["used_quota"]; ok {
 		channel.UsedQuota = 0
 	}
+	if _, ok := requestData["upstream_rate_limit"]; ok {
+		channel.UpstreamRateLimit = nil
+	}
 }
 
 // channelNonSensitiveFields lists routing / server-managed channel
@@ -133,4 +137,6 @@ var channelNonSensitiveFields = map[string]struct{}{
 	"remark":              {},
 	"channel_info":        {},
 	"multi_key_mode":      {},
+	"multi_key_rpm_limit": {},
+	"multi_key_tpm_limit": {},
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -152,10 +153,10 @@ func TestUpdateChannelRejectsStatusField(t *testing.T) {
 }
 
 func TestChannelStatusValidation(t *testing.T) {
-	assert.True(t, isManageableChannelStatus(common.ChannelStatusEnabled))
-	assert.True(t, isManageableChannelStatus(common.ChannelStatusManuallyDisabled))
-	assert.False(t, isManageableChannelStatus(common.ChannelStatusAutoDisabled))
-	assert.False(t, isManageableChannelStatus(0))
+	assert.True(t, service.IsManageableChannelStatus(common.ChannelStatusEnabled))
+	assert.True(t, service.IsManageableChannelStatus(common.ChannelStatusManuallyDisabled))
+	assert.False(t, service.IsManageableChannelStatus(common.ChannelStatusAutoDisabled))
+	assert.False(t, service.IsManageableChannelStatus(0))
 }
 
 // TestChannelFieldsAreClassified guards the fail-closed sensitivity check: every
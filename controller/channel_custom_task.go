@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/customtask"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCustomTaskChannel 校验自定义任务渠道的配置并渲染示例提交请求体。
+// 配置了 test_task_id 时查询该上游任务并按映射解析，不会提交新的（计费）任务
+func testCustomTaskChannel(channel *model.Channel, testModel string) testResult {
+	settings := channel.GetOtherSettings()
+	if err := settings.CustomTask.Validate(); err != nil {
+		return testResult{localErr: err}
+	}
+	key, _, newAPIError := channel.GetNextEnabledKey()
+	if newAPIError != nil {
+		return testResult{localErr: newAPIError}
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyChannelKey, key)
+
+	adaptor := &customtask.TaskAdaptor{}
+	adaptor.Init(&relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{
+		ChannelType:          channel.Type,
+		ChannelId:            channel.Id,
+		ChannelBaseUrl:       channel.GetBaseURL(),
+		ApiKey:               key,
+		ChannelOtherSettings: settings,
+	}})
+	if _, err := adaptor.RenderSubmitBody(testModel, relaycommon.TaskSubmitReq{Prompt: "channel test", Duration: 5}); err != nil {
+		return testResult{context: c, localErr: fmt.Errorf("failed to render submit body: %w", err)}
+	}
+	testTaskId := settings.CustomTask.TestTaskId
+	if testTaskId == "" {
+		return testResult{context: c, message: "custom_task settings are valid; set test_task_id to also test fetching"}
+	}
+
+	resp, err := adaptor.FetchTask(channel.GetBaseURL(), key, map[string]any{"task_id": testTaskId}, channel.GetSetting().Proxy)
+	if err != nil {
+		return testResult{context: c, localErr: err, newAPIError: types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return testResult{context: c, localErr: err, newAPIError: types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("fetch returned status %d: %s", resp.StatusCode, body)
+		return testResult{context: c, localErr: err, newAPIError: types.NewOpenAIError(err, types.ErrorCodeBadResponseStatusCode, resp.StatusCode)}
+	}
+	taskInfo, err := adaptor.ParseTaskResult(body)
+	if err != nil {
+		return testResult{context: c, localErr: fmt.Errorf("failed to map fetch response: %w", err)}
+	}
+	return testResult{context: c, message: fmt.Sprintf("task %s: status=%s progress=%s url=%s reason=%s",
+		testTaskId, taskInfo.Status, taskInfo.Progress, taskInfo.Url, taskInfo.Reason)}
+}
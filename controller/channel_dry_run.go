@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+type channelDryRunRequest struct {
+	RelayFormat types.RelayFormat `json:"relay_format"`
+	Path        string            `json:"path"`  // 入站路径，为空时按 relay_format 取默认路径
+	Model       string            `json:"model"` // 为空时取请求体中的 model
+	Group       string            `json:"group"` // 为空时使用当前用户的分组
+	Headers     map[string]string `json:"headers"`
+	Body        json.RawMessage   `json:"body"`
+}
+
+type channelDryRunResult struct {
+	OriginModel        string              `json:"origin_model"`
+	UpstreamModel      string              `json:"upstream_model"`
+	Conversions        []types.RelayFormat `json:"conversions"`
+	Method             string              `json:"method"`
+	URL                string              `json:"url"`
+	Headers            map[string]string   `json:"headers"`
+	Body               string              `json:"body"`
+	ParamOverrideAudit []string            `json:"param_override_audit"`
+}
+
+var channelDryRunPaths = map[types.RelayFormat]string{
+	types.RelayFormatOpenAI:                    "/v1/chat/completions",
+	types.RelayFormatClaude:                    "/v1/messages",
+	types.RelayFormatGemini:                    "/v1beta/models/{model}:generateContent",
+	types.RelayFormatOpenAIResponses:           "/v1/responses",
+	types.RelayFormatOpenAIResponsesCompaction: "/v1/responses/compact",
+	types.RelayFormatOpenAIImage:               "/v1/images/generations",
+	types.RelayFormatEmbedding:                 "/v1/embeddings",
+	types.RelayFormatRerank:                    "/v1/rerank",
+}
+
+// 这些请求头的值整体视为凭据，只保留认证方案与首尾少量字符
+var channelDryRunSecretHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"Api-Key",
+	"X-Goog-Api-Key",
+	"X-Amz-Security-Token",
+	"Cookie",
+}
+
+// DryRunChannel 使用示例请求预演渠道的完整转发流程（模型映射、格式转换、参数与请求头覆盖、
+// 系统提示词等），在发往上游之前停止，返回最终的 URL、请求头（凭据已脱敏）、请求体与覆盖审计记录
+func DryRunChannel(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgChannelIdFormatError)
+		return
+	}
+	var req channelDryRunRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || len(req.Body) == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if _, ok := channelDryRunPaths[req.RelayFormat]; !ok {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgChannelNotExists)
+		return
+	}
+	userId, err := resolveChannelTestUserID(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	result, err := dryRunChannel(c.Request.Context(), channel, userId, req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
+
+func dryRunChannel(ctx context.Context, channel *model.Channel, userId int, req channelDryRunRequest) (*channelDryRunResult, error) {
+	modelName := strings.TrimSpace(req.Model)
+	if modelName == "" {
+		modelName = gjson.GetBytes(req.Body, "model").String()
+	}
+	if modelName == "" {
+		return nil, errors.New("model is required")
+	}
+	requestPath := strings.TrimSpace(req.Path)
+	if requestPath == "" {
+		requestPath = strings.ReplaceAll(channelDryRunPaths[req.RelayFormat], "{model}", modelName)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequestWithContext(ctx, http.MethodPost, requestPath, bytes.NewReader(req.Body))
+	for name, value := range req.Headers {
+		c.Request.Header.Set(name, value)
+	}
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	cache, err := model.GetUserCache(userId)
+	if err != nil {
+		return nil, err
+	}
+	cache.WriteContext(c)
+	c.Set("id", userId)
+	group := strings.TrimSpace(req.Group)
+	if group == "" {
+		group, _ = model.GetUserGroup(userId, false)
+	}
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, group)
+
+	// 命中渠道亲和规则时，规则的参数覆盖模板会在 SetupContextForSelectedChannel 中合并
+	service.GetPreferredChannelByAffinity(c, modelName, group)
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, modelName); newAPIError != nil {
+		return nil, newAPIError
+	}
+
+	request, err := helper.GetAndValidateRequest(c, req.RelayFormat)
+	if err != nil {
+		return nil, err
+	}
+	info, err := relaycommon.GenRelayInfo(c, req.RelayFormat, request, nil)
+	if err != nil {
+		return nil, err
+	}
+	info.DryRun = &relaycommon.DryRunCapture{}
+
+	var newAPIError *types.NewAPIError
+	switch req.RelayFormat {
+	case types.RelayFormatClaude:
+		newAPIError = relay.ClaudeHelper(c, info)
+	case types.RelayFormatGemini:
+		newAPIError = geminiRelayHandler(c, info)
+	default:
+		newAPIError = relayHandler(c, info)
+	}
+	if !info.DryRun.Captured {
+		if newAPIError != nil {
+			return nil, newAPIError
+		}
+		return nil, errors.New("dry run finished without building an upstream request")
+	}
+
+	secrets := channelDryRunSecrets(info.ApiKey)
+	headers := make(map[string]string, len(info.DryRun.Header))
+	for name := range info.DryRun.Header {
+		value := maskChannelDryRunSecrets(info.DryRun.Header.Get(name), secrets)
+		for _, secretHeader := range channelDryRunSecretHeaders {
+			if strings.EqualFold(name, secretHeader) {
+				value = maskChannelDryRunHeader(value)
+				break
+			}
+		}
+		headers[name] = value
+	}
+	return &channelDryRunResult{
+		OriginModel:        info.OriginModelName,
+		UpstreamModel:      info.UpstreamModelName,
+		Conversions:        info.RequestConversionChain,
+		Method:             info.DryRun.Method,
+		URL:                maskChannelDryRunSecrets(info.DryRun.URL, secrets),
+		Headers:            headers,
+		Body:               maskChannelDryRunSecrets(string(info.DryRun.Body), secrets),
+		ParamOverrideAudit: info.ParamOverrideAudit,
+	}, nil
+}
+
+// channelDryRunSecrets 返回需要在输出中遮盖的渠道密钥，复合密钥（如 ak|sk|region）按段遮盖
+func channelDryRunSecrets(apiKey string) []string {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return nil
+	}
+	secrets := []string{apiKey}
+	for _, part := range strings.Split(apiKey, "|") {
+		if part = strings.TrimSpace(part); len(part) >= 8 && part != apiKey {
+			secrets = append(secrets, part)
+		}
+	}
+	return secrets
+}
+
+func maskChannelDryRunSecrets(value string, secrets []string) string {
+	for _, secret := range secrets {
+		value = strings.ReplaceAll(value, secret, maskChannelDryRunSecret(secret))
+	}
+	return value
+}
+
+// maskChannelDryRunHeader 保留认证方案（如 Bearer），遮盖凭据本身
+func maskChannelDryRunHeader(value string) string {
+	if scheme, credential, ok := strings.Cut(value, " "); ok && !strings.Contains(credential, "***") {
+		return scheme + " " + maskChannelDryRunSecret(credential)
+	}
+	if strings.Contains(value, "***") {
+		return value
+	}
+	return maskChannelDryRunSecret(value)
+}
+
+func maskChannelDryRunSecret(secret string) string {
+	if len(secret) <= 8 {
+		return "***"
+	}
+	return fmt.Sprintf("%s***%s", secret[:4], secret[len(secret)-4:])
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestMaskChannelDryRunSecrets(t *testing.T) {
+	secrets := channelDryRunSecrets("AKIAEXAMPLE123|secretkey-abcdef|us-east-1")
+	assert.Equal(t, []string{"AKIAEXAMPLE123|secretkey-abcdef|us-east-1", "AKIAEXAMPLE123", "secretkey-abcdef", "us-east-1"}, secrets)
+
+	assert.Equal(t,
+		"https://example.com/v1?key=AKIA***E123&sig=secr***cdef",
+		maskChannelDryRunSecrets("https://example.com/v1?key=AKIAEXAMPLE123&sig=secretkey-abcdef", secrets))
+	assert.Empty(t, channelDryRunSecrets("  "))
+}
+
+func TestMaskChannelDryRunHeader(t *testing.T) {
+	assert.Equal(t, "Bearer sk-1***wxyz", maskChannelDryRunHeader("Bearer sk-1234567890wxyz"))
+	assert.Equal(t, "sk-1***wxyz", maskChannelDryRunHeader("sk-1234567890wxyz"))
+	assert.Equal(t, "***", maskChannelDryRunHeader("short"))
+	// 已按渠道密钥遮盖过的值不再重复处理
+	assert.Equal(t, "Bearer sk-1***wxyz", maskChannelDryRunHeader("Bearer sk-1***wxyz"))
+}
+
+func TestDryRunChannelPreviewsWithoutCallingUpstream(t *testing.T) {
+	db := setupManageUserTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.Channel{}, &model.Ability{}))
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	user := &model.User{Username: "dry-run-admin", Password: "password", Role: common.RoleRootUser,
+		Status: common.UserStatusEnabled, Group: "default", AffCode: "dry-run-admin"}
+	require.NoError(t, db.Create(user).Error)
+	const channelKey = "sk-dryrunchannelsecret123456"
+	paramOverride := `{"temperature": 0.25}`
+	headerOverride := `{"X-Trace-Tag": "dry-run"}`
+	baseURL := upstream.URL
+	channel := &model.Channel{
+		Type: constant.ChannelTypeOpenAI, Key: channelKey, Name: "dry-run", Status: common.ChannelStatusEnabled,
+		BaseURL: &baseURL, Models: "gpt-4o", Group: "default",
+		ParamOverride: &paramOverride, HeaderOverride: &headerOverride,
+	}
+	require.NoError(t, db.Create(channel).Error)
+
+	body := `{"relay_format": "openai", "body": {"model": "gpt-4o", "messages": [{"role": "user", "content": "hi"}]}}`
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/channel/dry_run/"+strconv.Itoa(channel.Id), strings.NewReader(body))
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(channel.Id)}}
+	c.Set("id", user.Id)
+	DryRunChannel(c)
+
+	var response struct {
+		Success bool                `json:"success"`
+		Message string              `json:"message"`
+		Data    channelDryRunResult `json:"data"`
+	}
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	require.True(t, response.Success, response.Message)
+	assert.Zero(t, upstreamCalls.Load(), "dry run must not reach the upstream")
+
+	result := response.Data
+	assert.Equal(t, upstream.URL+"/v1/chat/completions", result.URL)
+	assert.Equal(t, 0.25, gjson.Get(result.Body, "temperature").Float())
+	assert.Equal(t, "dry-run", result.Headers["X-Trace-Tag"])
+	assert.Equal(t, "Bearer sk-d***3456", result.Headers["Authorization"])
+	assert.NotContains(t, recorder.Body.String(), channelKey)
+}
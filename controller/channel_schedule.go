@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChannelMaintenanceRequest struct {
+	StartTime       int64   `json:"start_time"`
+	EndTime         int64   `json:"end_time"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Name            string  `json:"name"`
+	Remark          *string `json:"remark"`
+}
+
+func GetChannelSchedules(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	tag := strings.TrimSpace(c.Query("tag"))
+	schedules, err := model.GetChannelSchedules(channelId, tag)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, schedules)
+}
+
+func CreateChannelSchedule(c *gin.Context) {
+	schedule := model.ChannelSchedule{}
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	schedule.Id = 0
+	if err := service.ValidateChannelSchedule(&schedule); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := schedule.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 立即评估一次，避免窗口已开始时需要等待下一轮调度
+	service.RunChannelSchedulesOnce(c.Request.Context())
+	recordManageAudit(c, "channel.schedule_create", map[string]interface{}{
+		"id":     schedule.Id,
+		"name":   schedule.Name,
+		"target": service.ChannelScheduleTargetString(&schedule),
+		"action": schedule.Action,
+	})
+	common.ApiSuccess(c, schedule)
+}
+
+func UpdateChannelSchedule(c *gin.Context) {
+	schedule := model.ChannelSchedule{}
+	if err := c.ShouldBindJSON(&schedule); err != nil || schedule.Id <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	origin, err := model.GetChannelScheduleById(schedule.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	schedule.Kind = origin.Kind
+	if err := service.ValidateChannelSchedule(&schedule); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 规则定义变化后先按旧定义恢复渠道，再由调度按新定义重新生效
+	if err := service.DeactivateChannelSchedule(origin); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := schedule.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RunChannelSchedulesOnce(c.Request.Context())
+	recordManageAudit(c, "channel.schedule_update", map[string]interface{}{
+		"id":     schedule.Id,
+		"name":   schedule.Name,
+		"target": service.ChannelScheduleTargetString(&schedule),
+		"action": schedule.Action,
+	})
+	updated, err := model.GetChannelScheduleById(schedule.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, updated)
+}
+
+func DeleteChannelSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	schedule, err := model.GetChannelScheduleById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := service.DeactivateChannelSchedule(schedule); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteChannelScheduleById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "channel.schedule_delete", map[string]interface{}{
+		"id":   id,
+		"name": schedule.Name,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// CreateChannelMaintenance 为单个渠道创建一次性维护窗口：窗口内禁用渠道，结束后自动恢复。
+// 未指定 start_time 时立即开始；end_time 与 duration_minutes 二选一。
+func CreateChannelMaintenance(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	req := ChannelMaintenanceRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	channel, err := model.GetChannelById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.StartTime <= 0 {
+		req.StartTime = common.GetTimestamp()
+	}
+	if req.EndTime <= 0 && req.DurationMinutes > 0 {
+		req.EndTime = req.StartTime + int64(req.DurationMinutes)*60
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "maintenance: " + channel.Name
+	}
+	schedule := model.ChannelSchedule{
+		Name:       name,
+		Kind:       model.ChannelScheduleKindMaintenance,
+		TargetType: model.ChannelScheduleTargetChannel,
+		ChannelId:  channel.Id,
+		Action:     model.ChannelScheduleActionDisable,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		Enabled:    true,
+		Remark:     req.Remark,
+	}
+	if err := service.ValidateChannelSchedule(&schedule); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := schedule.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.RunChannelSchedulesOnce(c.Request.Context())
+	recordManageAudit(c, "channel.maintenance_create", map[string]interface{}{
+		"id":         channel.Id,
+		"name":       channel.Name,
+		"start_time": schedule.StartTime,
+		"end_time":   schedule.EndTime,
+	})
+	updated, err := model.GetChannelScheduleById(schedule.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, updated)
+}
@@ -45,7 +45,7 @@ func TestValidateChannelProxy(t *testing.T) {
 				Setting: common.GetPointer(string(setting)),
 			}
 
-			err = validateChannel(channel, false)
+			err = service.ValidateChannel(channel, false)
 
 			if test.wantErr {
 				require.ErrorContains(t, err, "invalid channel proxy")
@@ -74,7 +74,7 @@ func TestValidateChannelRequiresNewAPIBaseURL(t *testing.T) {
 				BaseURL: test.baseURL,
 			}
 
-			err := validateChannel(channel, false)
+			err := service.ValidateChannel(channel, false)
 
 			if test.wantErr {
 				require.ErrorContains(t, err, "New API channel base URL cannot be empty")
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 声明式配置文档的导出、预览与应用接口；文档结构与 plan/apply 逻辑见 service/config_bundle.go
+
+type configExportRequest struct {
+	service.ConfigBundleExportOptions
+	Format string `json:"format"`
+}
+
+type configApplyRequest struct {
+	service.ConfigBundleApplyOptions
+	Content string `json:"content"`
+}
+
+// ExportConfig 导出配置文档；以加密形式导出密钥需要通过安全验证
+func ExportConfig(c *gin.Context) {
+	var req configExportRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Secrets == service.ConfigSecretModeEncrypted &&
+		!middleware.RequireSecurityProof(c, "channel.key.read", []string{"2fa", "passkey"}) {
+		return
+	}
+	bundle, err := service.ExportConfigBundle(req.ConfigBundleExportOptions)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	content, err := service.MarshalConfigBundle(bundle, req.Format)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "config.export", map[string]interface{}{
+		"secrets":  bundle.SecretSalt != "",
+		"channels": len(bundle.Channels),
+	})
+	common.ApiSuccess(c, string(content))
+}
+
+func parseConfigApplyRequest(c *gin.Context) (*configApplyRequest, *service.ConfigBundle, bool) {
+	var req configApplyRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	bundle, err := service.ParseConfigBundle([]byte(req.Content))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	return &req, bundle, true
+}
+
+// DiffConfig 预览应用配置文档会产生的变更
+func DiffConfig(c *gin.Context) {
+	req, bundle, ok := parseConfigApplyRequest(c)
+	if !ok {
+		return
+	}
+	plan, err := service.PlanConfigBundle(bundle, req.ConfigBundleApplyOptions)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+// ApplyConfig 原子地应用配置文档
+func ApplyConfig(c *gin.Context) {
+	req, bundle, ok := parseConfigApplyRequest(c)
+	if !ok {
+		return
+	}
+	req.OperatorId = c.GetInt("id")
+	plan, err := service.ApplyConfigBundle(bundle, req.ConfigBundleApplyOptions)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "config.apply", map[string]interface{}{
+		"creates": plan.Creates,
+		"updates": plan.Updates,
+		"deletes": plan.Deletes,
+	})
+	common.ApiSuccess(c, plan)
+}
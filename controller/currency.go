@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshCurrencyRatesSystemTask 手动触发一次汇率刷新任务，仅更新汇率来源为 auto 的货币
+func RefreshCurrencyRatesSystemTask(c *gin.Context) {
+	task, created, err := service.EnqueueSystemTask(model.SystemTaskTypeCurrencyRate, nil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "已有汇率刷新任务正在运行或等待中",
+			"data": gin.H{
+				"task_id": task.TaskID,
+				"status":  task.Status,
+				"type":    task.Type,
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    task.ToResponse(),
+	})
+}
+
+// paymentCurrencySnapshot 返回订单的支付币种与下单时的汇率快照，币种未配置时汇率记为 0
+func paymentCurrencySnapshot(code string) (string, float64) {
+	code = operation_setting.NormalizeCurrencyCode(code)
+	if currency, ok := operation_setting.GetCurrency(code); ok {
+		return currency.Code, currency.Rate
+	}
+	return code, 0
+}
+
+// subscriptionOrderPrice 返回套餐在结算货币下的价格：配置了该货币价格时使用之，否则沿用基础价格
+func subscriptionOrderPrice(plan *model.SubscriptionPlan, currency string) float64 {
+	if price, ok := plan.CurrencyPrice(currency); ok {
+		return price
+	}
+	return plan.PriceAmount
+}
+
+// payMoneyResponse 构造充值试算响应：data 为结算货币金额，display 为换算到用户货币后的参考金额
+func payMoneyResponse(userId int, requested string, payMoney float64, chargeCurrency string) gin.H {
+	resp := gin.H{
+		"message":  "success",
+		"data":     strconv.FormatFloat(payMoney, 'f', 2, 64),
+		"currency": chargeCurrency,
+	}
+	currency := service.ResolveUserCurrency(userId, requested)
+	if currency.Code == chargeCurrency {
+		return resp
+	}
+	if displayMoney, ok := operation_setting.ConvertCurrency(payMoney, chargeCurrency, currency.Code); ok {
+		resp["display"] = gin.H{
+			"currency": currency.Code,
+			"symbol":   currency.Symbol,
+			"amount":   strconv.FormatFloat(displayMoney, 'f', 2, 64),
+		}
+	}
+	return resp
+}
@@ -19,6 +19,7 @@ import (
 // It excludes sensitive fields like client_secret
 type CustomOAuthProviderResponse struct {
 	Id                    int    `json:"id"`
+	Kind                  string `json:"kind"`
 	Name                  string `json:"name"`
 	Slug                  string `json:"slug"`
 	Icon                  string `json:"icon"`
@@ -35,7 +36,13 @@ type CustomOAuthProviderResponse struct {
 	WellKnown             string `json:"well_known"`
 	AuthStyle             int    `json:"auth_style"`
 	AccessPolicy          string `json:"access_policy"`
+	ClaimMappings         string `json:"claim_mappings"`
 	AccessDeniedMessage   string `json:"access_denied_message"`
+	SamlIdpMetadataUrl    string `json:"saml_idp_metadata_url"`
+	SamlIdpMetadata       string `json:"saml_idp_metadata"`
+	SamlSpCertificate     string `json:"saml_sp_certificate"`
+	SamlSpMetadataUrl     string `json:"saml_sp_metadata_url,omitempty"` // Where the IdP can fetch the SP metadata
+	SamlSpAcsUrl          string `json:"saml_sp_acs_url,omitempty"`      // Assertion consumer service URL to register at the IdP
 }
 
 type UserOAuthBindingResponse struct {
@@ -47,8 +54,9 @@ type UserOAuthBindingResponse struct {
 }
 
 func toCustomOAuthProviderResponse(p *model.CustomOAuthProvider) *CustomOAuthProviderResponse {
-	return &CustomOAuthProviderResponse{
+	response := &CustomOAuthProviderResponse{
 		Id:                    p.Id,
+		Kind:                  p.Kind,
 		Name:                  p.Name,
 		Slug:                  p.Slug,
 		Icon:                  p.Icon,
@@ -65,8 +73,18 @@ func toCustomOAuthProviderResponse(p *model.CustomOAuthProvider) *CustomOAuthPro
 		WellKnown:             p.WellKnown,
 		AuthStyle:             p.AuthStyle,
 		AccessPolicy:          p.AccessPolicy,
+		ClaimMappings:         p.ClaimMappings,
 		AccessDeniedMessage:   p.AccessDeniedMessage,
+		SamlIdpMetadataUrl:    p.SamlIdpMetadataUrl,
+		SamlIdpMetadata:       p.SamlIdpMetadata,
+		SamlSpCertificate:     p.SamlSpCertificate,
 	}
+	if p.IsSAML() {
+		samlProvider := oauth.NewSamlProvider(p)
+		response.SamlSpMetadataUrl = samlProvider.MetadataURL()
+		response.SamlSpAcsUrl = samlProvider.AcsURL()
+	}
+	return response
 }
 
 // GetCustomOAuthProviders returns all custom OAuth providers
@@ -112,16 +130,19 @@ func GetCustomOAuthProvider(c *gin.Context) {
 }
 
 // CreateCustomOAuthProviderRequest is the request structure for creating a custom OAuth provider
+// OAuth endpoints and credentials are required for kind "oauth" and validated by the model,
+// SAML providers need IdP metadata instead.
 type CreateCustomOAuthProviderRequest struct {
+	Kind                  string `json:"kind"` // "oauth" (default) or "saml"
 	Name                  string `json:"name" binding:"required"`
 	Slug                  string `json:"slug" binding:"required"`
 	Icon                  string `json:"icon"`
 	Enabled               bool   `json:"enabled"`
-	ClientId              string `json:"client_id" binding:"required"`
-	ClientSecret          string `json:"client_secret" binding:"required"`
-	AuthorizationEndpoint string `json:"authorization_endpoint" binding:"required"`
-	TokenEndpoint         string `json:"token_endpoint" binding:"required"`
-	UserInfoEndpoint      string `json:"user_info_endpoint" binding:"required"`
+	ClientId              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"user_info_endpoint"`
 	Scopes                string `json:"scopes"`
 	UserIdField           string `json:"user_id_field"`
 	UsernameField         string `json:"username_field"`
@@ -130,7 +151,12 @@ type CreateCustomOAuthProviderRequest struct {
 	WellKnown             string `json:"well_known"`
 	AuthStyle             int    `json:"auth_style"`
 	AccessPolicy          string `json:"access_policy"`
+	ClaimMappings         string `json:"claim_mappings"`
 	AccessDeniedMessage   string `json:"access_denied_message"`
+	SamlIdpMetadataUrl    string `json:"saml_idp_metadata_url"`
+	SamlIdpMetadata       string `json:"saml_idp_metadata"`
+	SamlSpCertificate     string `json:"saml_sp_certificate"` // Optional: generated when empty
+	SamlSpPrivateKey      string `json:"saml_sp_private_key"` // Required together with saml_sp_certificate
 }
 
 type FetchCustomOAuthDiscoveryRequest struct {
@@ -218,6 +244,23 @@ func CreateCustomOAuthProvider(c *gin.Context) {
 		return
 	}
 
+	isSAML := strings.EqualFold(strings.TrimSpace(req.Kind), model.CustomOAuthProviderKindSAML)
+	if !isSAML && req.ClientSecret == "" {
+		common.ApiErrorMsg(c, "无效的请求参数: client_secret 不能为空")
+		return
+	}
+	if isSAML && strings.TrimSpace(req.SamlIdpMetadata) != "" {
+		if _, err := oauth.ParseSamlIdpMetadata([]byte(req.SamlIdpMetadata)); err != nil {
+			common.ApiErrorMsg(c, "SAML IdP 元数据无效: "+err.Error())
+			return
+		}
+	}
+
+	if err := validateClaimMappingReferences(req.ClaimMappings); err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+
 	// Check if slug is already taken
 	if model.IsSlugTaken(req.Slug, 0) {
 		common.ApiErrorMsg(c, "该 Slug 已被使用")
@@ -231,6 +274,7 @@ func CreateCustomOAuthProvider(c *gin.Context) {
 	}
 
 	provider := &model.CustomOAuthProvider{
+		Kind:                  req.Kind,
 		Name:                  req.Name,
 		Slug:                  req.Slug,
 		Icon:                  req.Icon,
@@ -248,7 +292,12 @@ func CreateCustomOAuthProvider(c *gin.Context) {
 		WellKnown:             req.WellKnown,
 		AuthStyle:             req.AuthStyle,
 		AccessPolicy:          req.AccessPolicy,
+		ClaimMappings:         req.ClaimMappings,
 		AccessDeniedMessage:   req.AccessDeniedMessage,
+		SamlIdpMetadataUrl:    req.SamlIdpMetadataUrl,
+		SamlIdpMetadata:       req.SamlIdpMetadata,
+		SamlSpCertificate:     req.SamlSpCertificate,
+		SamlSpPrivateKey:      req.SamlSpPrivateKey,
 	}
 
 	if err := model.CreateCustomOAuthProvider(provider); err != nil {
@@ -285,7 +334,12 @@ type UpdateCustomOAuthProviderRequest struct {
 	WellKnown             *string `json:"well_known"`            // Optional: if nil, keep existing
 	AuthStyle             *int    `json:"auth_style"`            // Optional: if nil, keep existing
 	AccessPolicy          *string `json:"access_policy"`         // Optional: if nil, keep existing
+	ClaimMappings         *string `json:"claim_mappings"`        // Optional: if nil, keep existing
 	AccessDeniedMessage   *string `json:"access_denied_message"` // Optional: if nil, keep existing
+	SamlIdpMetadataUrl    *string `json:"saml_idp_metadata_url"` // Optional: if nil, keep existing
+	SamlIdpMetadata       *string `json:"saml_idp_metadata"`     // Optional: if nil, keep existing
+	SamlSpCertificate     string  `json:"saml_sp_certificate"`   // Only applied together with saml_sp_private_key
+	SamlSpPrivateKey      string  `json:"saml_sp_private_key"`   // Optional: if empty, keep the existing pair
 }
 
 // UpdateCustomOAuthProvider updates an existing custom OAuth provider
@@ -380,6 +434,31 @@ func UpdateCustomOAuthProvider(c *gin.Context) {
 	if req.AccessDeniedMessage != nil {
 		provider.AccessDeniedMessage = *req.AccessDeniedMessage
 	}
+	if req.ClaimMappings != nil {
+		if err := validateClaimMappingReferences(*req.ClaimMappings); err != nil {
+			common.ApiErrorMsg(c, err.Error())
+			return
+		}
+		provider.ClaimMappings = *req.ClaimMappings
+	}
+	if req.SamlIdpMetadataUrl != nil {
+		provider.SamlIdpMetadataUrl = *req.SamlIdpMetadataUrl
+	}
+	if req.SamlIdpMetadata != nil {
+		if strings.TrimSpace(*req.SamlIdpMetadata) != "" {
+			if _, err := oauth.ParseSamlIdpMetadata([]byte(*req.SamlIdpMetadata)); err != nil {
+				common.ApiErrorMsg(c, "SAML IdP 元数据无效: "+err.Error())
+				return
+			}
+		}
+		provider.SamlIdpMetadata = *req.SamlIdpMetadata
+	}
+	// 私钥不会返回给前端，编辑时回传的证书不带私钥；只有提供了新私钥时才替换整对证书，
+	// 新的证书与私钥是否匹配由 model 层校验
+	if strings.TrimSpace(req.SamlSpPrivateKey) != "" {
+		provider.SamlSpCertificate = req.SamlSpCertificate
+		provider.SamlSpPrivateKey = req.SamlSpPrivateKey
+	}
 
 	if err := model.UpdateCustomOAuthProvider(provider); err != nil {
 		common.ApiError(c, err)
@@ -500,8 +579,7 @@ func GetUserOAuthBindingsByAdmin(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, targetUser.Role) {
+	if !canManageTargetUser(c, targetUser.Id, targetUser.Role) {
 		common.ApiErrorMsg(c, "no permission")
 		return
 	}
@@ -559,8 +637,7 @@ func UnbindCustomOAuthByAdmin(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, targetUser.Role) {
+	if !canManageTargetUser(c, targetUser.Id, targetUser.Role) {
 		common.ApiErrorMsg(c, "no permission")
 		return
 	}
@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 同一套处理逻辑服务两类订阅：用户在 /api/webhook 下管理自己的订阅（ownerId 为用户 ID），
+// 管理员在 /api/webhook/system 下管理系统订阅（ownerId 为 0）。
+
+type webhookSubscriptionRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// WebhookSubscriptionView 返回给前端的订阅，事件类型以数组形式展示，密钥不返回
+type WebhookSubscriptionView struct {
+	*model.WebhookSubscription
+	EventTypes []string `json:"event_types"`
+}
+
+func newWebhookSubscriptionView(subscription *model.WebhookSubscription) *WebhookSubscriptionView {
+	return &WebhookSubscriptionView{
+		WebhookSubscription: subscription,
+		EventTypes:          subscription.GetEventTypeList(),
+	}
+}
+
+// userWebhookAllowed 检查用户订阅是否开放，未开放时已写入错误响应
+func userWebhookAllowed(c *gin.Context) bool {
+	if !system_setting.GetEventWebhookSettings().UserSubscriptionsEnabled {
+		common.ApiErrorI18n(c, i18n.MsgWebhookUserDisabled)
+		return false
+	}
+	return true
+}
+
+func GetWebhookEvents(c *gin.Context) {
+	common.ApiSuccess(c, service.SubscribableWebhookEvents(false))
+}
+
+func GetSystemWebhookEvents(c *gin.Context) {
+	common.ApiSuccess(c, service.SubscribableWebhookEvents(true))
+}
+
+func GetWebhookSubscriptions(c *gin.Context) {
+	listWebhookSubscriptions(c, c.GetInt("id"))
+}
+
+func GetSystemWebhookSubscriptions(c *gin.Context) {
+	listWebhookSubscriptions(c, 0)
+}
+
+func listWebhookSubscriptions(c *gin.Context, ownerId int) {
+	subscriptions, err := model.GetWebhookSubscriptions(ownerId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	views := make([]*WebhookSubscriptionView, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		views = append(views, newWebhookSubscriptionView(subscription))
+	}
+	common.ApiSuccess(c, views)
+}
+
+func CreateWebhookSubscription(c *gin.Context) {
+	if !userWebhookAllowed(c) {
+		return
+	}
+	userId := c.GetInt("id")
+	maxCount := system_setting.GetEventWebhookSettings().MaxSubscriptionsPerUser
+	count, err := model.CountWebhookSubscriptions(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if maxCount > 0 && count >= int64(maxCount) {
+		common.ApiErrorI18n(c, i18n.MsgWebhookLimitReached, map[string]any{"Max": maxCount})
+		return
+	}
+	createWebhookSubscription(c, userId)
+}
+
+func CreateSystemWebhookSubscription(c *gin.Context) {
+	createWebhookSubscription(c, 0)
+}
+
+// createWebhookSubscription 创建订阅，签名密钥只在响应中返回这一次
+func createWebhookSubscription(c *gin.Context, ownerId int) {
+	var req webhookSubscriptionRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	subscription := &model.WebhookSubscription{
+		UserId:  ownerId,
+		Name:    req.Name,
+		URL:     req.URL,
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+	if err := service.ValidateWebhookSubscription(subscription, req.EventTypes); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	secret, err := service.GenerateWebhookSecret()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	subscription.Secret = secret
+	if err := subscription.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"subscription": newWebhookSubscriptionView(subscription),
+		"secret":       secret,
+	})
+}
+
+func UpdateWebhookSubscription(c *gin.Context) {
+	if !userWebhookAllowed(c) {
+		return
+	}
+	updateWebhookSubscription(c, c.GetInt("id"))
+}
+
+func UpdateSystemWebhookSubscription(c *gin.Context) {
+	updateWebhookSubscription(c, 0)
+}
+
+func updateWebhookSubscription(c *gin.Context, ownerId int) {
+	subscription, ok := loadWebhookSubscription(c, ownerId)
+	if !ok {
+		return
+	}
+	var req webhookSubscriptionRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	subscription.Name = req.Name
+	subscription.URL = req.URL
+	if req.Enabled != nil {
+		subscription.Enabled = *req.Enabled
+	}
+	if err := service.ValidateWebhookSubscription(subscription, req.EventTypes); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := subscription.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, newWebhookSubscriptionView(subscription))
+}
+
+func RotateWebhookSubscriptionSecret(c *gin.Context) {
+	rotateWebhookSubscriptionSecret(c, c.GetInt("id"))
+}
+
+func RotateSystemWebhookSubscriptionSecret(c *gin.Context) {
+	rotateWebhookSubscriptionSecret(c, 0)
+}
+
+// rotateWebhookSubscriptionSecret 轮换签名密钥，尚未发出的投递使用新密钥签名
+func rotateWebhookSubscriptionSecret(c *gin.Context, ownerId int) {
+	subscription, ok := loadWebhookSubscription(c, ownerId)
+	if !ok {
+		return
+	}
+	secret, err := service.GenerateWebhookSecret()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	subscription.Secret = secret
+	if err := subscription.UpdateSecret(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"secret": secret})
+}
+
+func DeleteWebhookSubscription(c *gin.Context) {
+	deleteWebhookSubscription(c, c.GetInt("id"))
+}
+
+func DeleteSystemWebhookSubscription(c *gin.Context) {
+	deleteWebhookSubscription(c, 0)
+}
+
+func deleteWebhookSubscription(c *gin.Context, ownerId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteWebhookSubscription(id, ownerId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	listWebhookDeliveries(c, c.GetInt("id"))
+}
+
+func GetSystemWebhookDeliveries(c *gin.Context) {
+	listWebhookDeliveries(c, 0)
+}
+
+// listWebhookDeliveries 分页返回投递记录，列表不含请求体，详情接口返回完整内容
+func listWebhookDeliveries(c *gin.Context, ownerId int) {
+	pageInfo := common.GetPageQuery(c)
+	subscriptionId, _ := strconv.Atoi(c.Query("subscription_id"))
+	deliveries, total, err := model.GetWebhookDeliveries(ownerId, subscriptionId, c.Query("event_type"), c.Query("status"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetWebhookDelivery(c *gin.Context) {
+	getWebhookDelivery(c, c.GetInt("id"))
+}
+
+func GetSystemWebhookDelivery(c *gin.Context) {
+	getWebhookDelivery(c, 0)
+}
+
+func getWebhookDelivery(c *gin.Context, ownerId int) {
+	delivery, ok := loadWebhookDelivery(c, ownerId)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
+
+func RedeliverWebhookDelivery(c *gin.Context) {
+	if !userWebhookAllowed(c) {
+		return
+	}
+	redeliverWebhookDelivery(c, c.GetInt("id"))
+}
+
+func RedeliverSystemWebhookDelivery(c *gin.Context) {
+	redeliverWebhookDelivery(c, 0)
+}
+
+func redeliverWebhookDelivery(c *gin.Context, ownerId int) {
+	delivery, ok := loadWebhookDelivery(c, ownerId)
+	if !ok {
+		return
+	}
+	redelivery, err := service.RedeliverWebhook(delivery)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, redelivery)
+}
+
+func loadWebhookSubscription(c *gin.Context, ownerId int) (*model.WebhookSubscription, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	subscription, err := model.GetWebhookSubscription(id, ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+			return nil, false
+		}
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return subscription, true
+}
+
+func loadWebhookDelivery(c *gin.Context, ownerId int) (*model.WebhookDelivery, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	delivery, err := model.GetWebhookDelivery(id, ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWebhookDeliveryNotFound)
+			return nil, false
+		}
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return delivery, true
+}
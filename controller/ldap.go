@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service/ldapauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ldapUsernamePrefix 用于目录用户名已被占用时生成的本地用户名
+const ldapUsernamePrefix = "ldap_"
+
+var (
+	errLDAPRegistrationDisabled = errors.New("ldap auto registration is disabled")
+	errLDAPEmailTaken           = errors.New("ldap email is already taken")
+	errLDAPUserDeleted          = errors.New("ldap linked user has been deleted")
+)
+
+type LDAPLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LDAPLogin 使用目录账户登录：服务账号查找条目、以用户身份绑定校验密码、
+// 校验用户组后映射到本地账户，未关联时按配置自动创建。2FA 与会话建立沿用密码登录。
+func LDAPLogin(c *gin.Context) {
+	settings := *system_setting.GetLDAPSettings()
+	if !settings.Enabled {
+		common.ApiErrorI18n(c, i18n.MsgLDAPNotEnabled)
+		return
+	}
+	var loginRequest LDAPLoginRequest
+	if err := common.DecodeJson(c.Request.Body, &loginRequest); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if strings.TrimSpace(loginRequest.Username) == "" || loginRequest.Password == "" {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+
+	entry, err := ldapauth.Authenticate(c.Request.Context(), settings, loginRequest.Username, loginRequest.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, ldapauth.ErrInvalidCredentials):
+			common.ApiErrorI18n(c, i18n.MsgUserUsernameOrPasswordError)
+		case errors.Is(err, ldapauth.ErrGroupDenied):
+			common.ApiErrorI18n(c, i18n.MsgLDAPGroupDenied)
+		default:
+			common.SysError("LDAP login failed for " + loginRequest.Username + ": " + err.Error())
+			common.ApiErrorI18n(c, i18n.MsgLDAPUnavailable)
+		}
+		return
+	}
+
+	user, err := findOrCreateLDAPUser(entry, settings)
+	if err != nil {
+		switch {
+		case errors.Is(err, errLDAPRegistrationDisabled):
+			common.ApiErrorI18n(c, i18n.MsgLDAPRegistrationDisabled)
+		case errors.Is(err, errLDAPEmailTaken):
+			common.ApiErrorI18n(c, i18n.MsgLDAPEmailTaken)
+		case errors.Is(err, errLDAPUserDeleted):
+			common.ApiErrorI18n(c, i18n.MsgOAuthUserDeleted)
+		default:
+			common.ApiError(c, err)
+		}
+		return
+	}
+	if user.Status != common.UserStatusEnabled {
+		common.ApiErrorI18n(c, i18n.MsgAuthUserBanned)
+		return
+	}
+	completeCredentialLogin(c, user)
+}
+
+// findOrCreateLDAPUser 按目录条目的稳定标识查找已关联的本地账户，
+// 未关联时在开启自动注册的情况下创建账户并登记关联。
+func findOrCreateLDAPUser(entry *ldapauth.Entry, settings system_setting.LDAPSettings) (*model.User, error) {
+	user, err := getLinkedLDAPUser(entry.Subject)
+	if user != nil || err != nil {
+		return user, err
+	}
+	if !settings.AutoRegister {
+		return nil, errLDAPRegistrationDisabled
+	}
+
+	user = &model.User{
+		Username:    ldapUsernamePrefix + strconv.Itoa(model.GetMaxUserId()+1),
+		DisplayName: entry.DisplayName,
+		Role:        common.RoleCommonUser,
+		Status:      common.UserStatusEnabled,
+	}
+	if exists, err := model.CheckUserExistOrDeleted(entry.Username, ""); err == nil && !exists {
+		// 防止索引退化
+		if len(entry.Username) <= model.UserNameMaxLength {
+			user.Username = entry.Username
+		}
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = entry.Username
+	}
+	if entry.Email != "" {
+		user.Email = model.NormalizeEmail(entry.Email)
+		if err := model.EnsureEmailAvailable(user.Email, 0); err != nil {
+			if errors.Is(err, model.ErrEmailAlreadyTaken) {
+				return nil, errLDAPEmailTaken
+			}
+			return nil, err
+		}
+	}
+
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.InsertWithTx(tx, 0); err != nil {
+			return err
+		}
+		return model.ClaimExternalIdentityWithTx(tx, model.ExternalIdentityProviderLDAP, entry.Subject, user.Id)
+	})
+	if errors.Is(err, model.ErrExternalIdentityAlreadyClaimed) {
+		// 并发的首次登录已经创建了账户，回滚本次创建并使用已关联的账户
+		linked, lookupErr := getLinkedLDAPUser(entry.Subject)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if linked != nil {
+			return linked, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.FinalizeOAuthUserCreation(0)
+	common.SysLog("created user " + user.Username + " from LDAP entry " + entry.DN)
+	return user, nil
+}
+
+func getLinkedLDAPUser(subject string) (*model.User, error) {
+	userId, err := model.GetUserIdByExternalIdentity(model.ExternalIdentityProviderLDAP, subject)
+	if err != nil || userId == 0 {
+		return nil, err
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errLDAPUserDeleted
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+type ldapTestRequest struct {
+	// Settings 为空时测试已保存的配置；绑定密码留空且服务器与绑定 DN 未变化时沿用已保存的密码
+	Settings *system_setting.LDAPSettings `json:"settings"`
+	Username string                       `json:"username"`
+}
+
+// TestLDAPConnection 测试目录连接：建立连接（含 StartTLS）、服务账号绑定并读取 Base DN，
+// 提供用户名时按登录流程查找该用户并返回映射后的属性、用户组以及是否允许登录。
+func TestLDAPConnection(c *gin.Context) {
+	var req ldapTestRequest
+	rawBody, err := c.GetRawData()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(bytes.TrimSpace(rawBody)) > 0 {
+		if err := common.Unmarshal(rawBody, &req); err != nil {
+			common.ApiErrorMsg(c, "invalid request payload")
+			return
+		}
+	}
+
+	stored := *system_setting.GetLDAPSettings()
+	settings := stored
+	if req.Settings != nil {
+		settings = *req.Settings
+		if settings.BindPassword == "" &&
+			strings.TrimSpace(settings.ServerURL) == strings.TrimSpace(stored.ServerURL) &&
+			strings.TrimSpace(settings.BindDN) == strings.TrimSpace(stored.BindDN) {
+			settings.BindPassword = stored.BindPassword
+		}
+	}
+	if strings.TrimSpace(settings.ServerURL) == "" || strings.TrimSpace(settings.BaseDN) == "" {
+		common.ApiErrorMsg(c, "server_url and base_dn are required")
+		return
+	}
+
+	entry, err := ldapauth.TestConnection(c.Request.Context(), settings, req.Username)
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	if entry == nil {
+		common.ApiSuccess(c, gin.H{})
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"entry":   entry,
+		"allowed": ldapauth.GroupsAllowed(settings, entry.Groups),
+	})
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLDAPTest(t *testing.T) (*gin.Engine, *system_setting.LDAPSettings) {
+	t.Helper()
+	require.NoError(t, i18n.Init())
+	db := setupManageUserTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.ExternalIdentityClaim{}, &model.AuthFlow{}, &model.TwoFA{}))
+
+	users := testdirectory.NewUsers(t, []string{"alice", "bob"}, testdirectory.WithMembersOf(t, "staff"))
+	users = append(users, testdirectory.NewUsers(t, []string{"carol"}, testdirectory.WithMembersOf(t, "contractors"))...)
+	directory := testdirectory.Start(t, testdirectory.WithDefaults(t, &testdirectory.Defaults{
+		AllowAnonymousBind: true,
+		Users:              users,
+	}))
+
+	settings := system_setting.GetLDAPSettings()
+	previous := *settings
+	*settings = system_setting.LDAPSettings{
+		Enabled:           true,
+		ServerURL:         fmt.Sprintf("ldaps://%s:%d", directory.Host(), directory.Port()),
+		CACertificate:     directory.Cert(),
+		TimeoutSeconds:    5,
+		BaseDN:            testdirectory.DefaultUserDN,
+		UserFilter:        "(cn={username})",
+		UsernameAttribute: "name",
+		EmailAttribute:    "email",
+		GroupAttribute:    "memberOf",
+		RequiredGroups:    []string{"staff"},
+		AutoRegister:      true,
+	}
+	t.Cleanup(func() { *settings = previous })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/user/login/ldap", LDAPLogin)
+	router.POST("/api/option/ldap/test", TestLDAPConnection)
+	return router, settings
+}
+
+func postLDAPTestJSON(router *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestLDAPLoginProvisionsAndLinksUser(t *testing.T) {
+	router, _ := setupLDAPTest(t)
+
+	recorder := postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"password"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"access_token"`, recorder.Body.String())
+
+	userId, err := model.GetUserIdByExternalIdentity(model.ExternalIdentityProviderLDAP, "cn=bob,ou=people,dc=example,dc=org")
+	require.NoError(t, err)
+	user, err := model.GetUserById(userId, false)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", user.Username)
+	assert.Equal(t, "bob@example.com", user.Email)
+
+	// The second login reuses the linked account.
+	recorder = postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"password"}`)
+	require.Contains(t, recorder.Body.String(), `"success":true`, recorder.Body.String())
+	var count int64
+	require.NoError(t, model.DB.Model(&model.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	recorder = postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"wrong"}`)
+	assert.Contains(t, recorder.Body.String(), `"success":false`)
+	assert.NotContains(t, recorder.Body.String(), `"access_token"`)
+}
+
+func TestLDAPLoginFallsBackWhenUsernameTaken(t *testing.T) {
+	router, _ := setupLDAPTest(t)
+	require.NoError(t, model.DB.Create(&model.User{Username: "alice", Password: "local-password", AffCode: "alice"}).Error)
+
+	recorder := postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"alice","password":"password"}`)
+	require.Contains(t, recorder.Body.String(), `"success":true`, recorder.Body.String())
+
+	userId, err := model.GetUserIdByExternalIdentity(model.ExternalIdentityProviderLDAP, "cn=alice,ou=people,dc=example,dc=org")
+	require.NoError(t, err)
+	user, err := model.GetUserById(userId, false)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.Username, ldapUsernamePrefix), user.Username)
+}
+
+func TestLDAPLoginGatesAccess(t *testing.T) {
+	router, settings := setupLDAPTest(t)
+
+	recorder := postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"carol","password":"password"}`)
+	assert.Contains(t, recorder.Body.String(), i18n.T(nil, i18n.MsgLDAPGroupDenied))
+
+	settings.AutoRegister = false
+	recorder = postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"password"}`)
+	assert.Contains(t, recorder.Body.String(), i18n.T(nil, i18n.MsgLDAPRegistrationDisabled))
+
+	settings.Enabled = false
+	recorder = postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"password"}`)
+	assert.Contains(t, recorder.Body.String(), i18n.T(nil, i18n.MsgLDAPNotEnabled))
+
+	var count int64
+	require.NoError(t, model.DB.Model(&model.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestLDAPLoginRejectsDisabledLinkedUser(t *testing.T) {
+	router, _ := setupLDAPTest(t)
+	user := model.User{Username: "bob-local", Password: "local-password", AffCode: "bob-local", Status: common.UserStatusDisabled}
+	require.NoError(t, model.DB.Create(&user).Error)
+	require.NoError(t, model.ClaimExternalIdentityWithTx(model.DB, model.ExternalIdentityProviderLDAP, "cn=bob,ou=people,dc=example,dc=org", user.Id))
+
+	recorder := postLDAPTestJSON(router, "/api/user/login/ldap", `{"username":"bob","password":"password"}`)
+	assert.Contains(t, recorder.Body.String(), `"success":false`)
+	assert.NotContains(t, recorder.Body.String(), `"access_token"`)
+}
+
+func TestLDAPConnectionTestUsesSubmittedSettings(t *testing.T) {
+	router, settings := setupLDAPTest(t)
+
+	// Unsaved settings from the form are tested instead of the stored ones.
+	override := *settings
+	override.ServerURL = "ldap://127.0.0.1:1"
+	payload, err := common.Marshal(gin.H{"settings": override})
+	require.NoError(t, err)
+	recorder := postLDAPTestJSON(router, "/api/option/ldap/test", string(payload))
+	assert.Contains(t, recorder.Body.String(), `"success":false`)
+
+	override = *settings
+	override.BaseDN = ""
+	payload, err = common.Marshal(gin.H{"settings": override})
+	require.NoError(t, err)
+	recorder = postLDAPTestJSON(router, "/api/option/ldap/test", string(payload))
+	assert.Contains(t, recorder.Body.String(), "base_dn are required")
+}
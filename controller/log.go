@@ -122,6 +122,28 @@ func GetLogsStat(c *gin.Context) {
 	return
 }
 
+// GetMarginReport 按渠道 / 模型 / 分组 / 用户统计收入、上游成本与毛利
+func GetMarginReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	groupBy := c.DefaultQuery("group_by", model.MarginGroupByChannel)
+	items, err := model.GetMarginReport(model.MarginReportParams{
+		GroupBy:        groupBy,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		ModelName:      c.Query("model_name"),
+		Username:       c.Query("username"),
+		Channel:        channel,
+		Group:          c.Query("group"),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, items)
+}
+
 func GetLogsSelfStat(c *gin.Context) {
 	username := c.GetString("username")
 	logType, _ := strconv.Atoi(c.Query("type"))
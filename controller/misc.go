@@ -94,6 +94,10 @@ func GetStatus(c *gin.Context) {
 		"password_register_enabled":     common.PasswordRegisterEnabled,
 		"default_use_auto_group":        setting.DefaultUseAutoGroup,
 
+		// 多币种：可选货币及汇率（1 USD = rate），用户未设置时使用默认货币
+		"currencies":       operation_setting.GetCurrencies(),
+		"default_currency": operation_setting.ResolveCurrency("").Code,
+
 		"usd_exchange_rate": operation_setting.USDExchangeRate,
 		"price":             operation_setting.Price,
 		"stripe_unit_price": setting.StripeUnitPrice,
@@ -112,6 +116,8 @@ func GetStatus(c *gin.Context) {
 		"oidc_client_id":              system_setting.GetOIDCSettings().ClientId,
 		"oidc_authorization_endpoint": system_setting.GetOIDCSettings().AuthorizationEndpoint,
 		"oidc_display_name":           system_setting.GetOIDCSettings().GetEffectiveDisplayName(),
+		"ldap_enabled":                system_setting.GetLDAPSettings().Enabled,
+		"ldap_display_name":           system_setting.GetLDAPSettings().GetEffectiveDisplayName(),
 		"passkey_login":               passkeySetting.Enabled,
 		"passkey_display_name":        passkeySetting.RPDisplayName,
 		"passkey_rp_id":               passkeySetting.RPID,
@@ -156,8 +162,8 @@ func GetStatus(c *gin.Context) {
 				Name:                  config.Name,
 				Slug:                  config.Slug,
 				Icon:                  config.Icon,
-				ClientId:              config.ClientId,
-				AuthorizationEndpoint: config.AuthorizationEndpoint,
+				ClientId:              p.GetClientId(),
+				AuthorizationEndpoint: p.GetAuthorizationEndpoint(),
 				Scopes:                config.Scopes,
 			})
 		}
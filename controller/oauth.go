@@ -10,6 +10,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
@@ -218,7 +219,16 @@ func HandleOAuth(c *gin.Context) {
 		return
 	}
 
-	// 9. Setup login
+	// 9. Sync entitlements from claim mappings
+	if customProvider, ok := provider.(oauth.CustomProvider); ok {
+		if err := applyOAuthClaimMappings(c, customProvider, user.Id, oauthUser); err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("[OAuth-%s] failed to apply claim mappings for user %d: %s", providerName, user.Id, err.Error()))
+			common.ApiErrorI18n(c, i18n.MsgOAuthGetUserErr)
+			return
+		}
+	}
+
+	// 10. Setup login
 	setupLogin(user, c)
 }
 
@@ -271,9 +281,9 @@ func handleOAuthBind(c *gin.Context, provider oauth.Provider, pendingFlow *model
 	}
 
 	// Handle binding based on provider type
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
+	if customProvider, ok := provider.(oauth.CustomProvider); ok {
 		// Custom provider: use user_oauth_bindings table
-		err = model.UpdateUserOAuthBinding(user.Id, genericProvider.GetProviderId(), oauthUser.ProviderUserID)
+		err = model.UpdateUserOAuthBinding(user.Id, customProvider.GetProviderId(), oauthUser.ProviderUserID)
 		if err != nil {
 			common.ApiError(c, err)
 			return
@@ -373,7 +383,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	}
 
 	// Use transaction to ensure user creation and OAuth binding are atomic
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
+	if customProvider, ok := provider.(oauth.CustomProvider); ok {
 		// Custom provider: create user and binding in a transaction
 		err := model.DB.Transaction(func(tx *gorm.DB) error {
 			// Create user
@@ -384,7 +394,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 			// Create OAuth binding
 			binding := &model.UserOAuthBinding{
 				UserId:         user.Id,
-				ProviderId:     genericProvider.GetProviderId(),
+				ProviderId:     customProvider.GetProviderId(),
 				ProviderUserId: oauthUser.ProviderUserID,
 			}
 			if err := model.CreateUserOAuthBindingWithTx(tx, binding); err != nil {
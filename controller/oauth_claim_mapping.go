@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/service/authz"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// applyOAuthClaimMappings 在每次通过自定义提供商登录时，按声明映射同步用户的分组、
+// 系统角色、自定义授权角色并发放额度奖励。映射授予的内容记录在 OAuthClaimGrant 中，
+// 声明消失后只回收映射授予的部分；管理员手动设置的值保持不变，额度奖励不回收。
+func applyOAuthClaimMappings(c *gin.Context, provider oauth.CustomProvider, userId int, oauthUser *oauth.OAuthUser) error {
+	config := provider.GetConfig()
+	entitlements, err := oauth.EvaluateClaimMappings(config.ClaimMappings, oauthUser.Claims)
+	if err != nil {
+		return err
+	}
+	grant, err := model.GetOAuthClaimGrant(userId, config.Id)
+	if err != nil {
+		return err
+	}
+	if grant.Id == 0 && entitlements.IsEmpty() {
+		return nil
+	}
+	user, err := model.GetUserById(userId, true)
+	if err != nil {
+		return err
+	}
+
+	recorded := *grant
+	changes := map[string]interface{}{}
+	previousGroup, previousRole := user.Group, user.Role
+	applyClaimGroup(user, grant, entitlements.Group, config.Slug)
+	applyClaimRole(user, grant, entitlements.Role)
+	finalRoles, addedRoles, removedRoles := planClaimAuthzRoles(user.Id, grant, entitlements.AuthzRoles, config.Slug)
+
+	grantedRules := grant.GetQuotaRuleList()
+	var pendingQuotaRules []string
+	for _, name := range entitlements.QuotaRuleNames() {
+		if !slices.Contains(grantedRules, name) {
+			pendingQuotaRules = append(pendingQuotaRules, name)
+		}
+	}
+	grant.SetQuotaRuleList(append(grantedRules, pendingQuotaRules...))
+	// 先保存授予记录再修改用户：并发登录赢得版本竞争时由它完成同步，
+	// 本次不修改分组、角色与额度，避免按过期的记录授予或回收
+	if *grant != recorded {
+		saved, err := model.SaveOAuthClaimGrant(grant)
+		if err != nil {
+			return err
+		}
+		if !saved {
+			return nil
+		}
+	}
+
+	if user.Group != previousGroup {
+		changes["group"] = user.Group
+	}
+	if user.Role != previousRole {
+		changes["role"] = user.Role
+	}
+	if len(changes) > 0 {
+		if err := saveClaimMappedUser(user, previousRole); err != nil {
+			return err
+		}
+	}
+	if len(addedRoles) > 0 || len(removedRoles) > 0 {
+		if err := authz.SetUserRoles(user.Id, finalRoles); err != nil {
+			return err
+		}
+	}
+	if len(addedRoles) > 0 {
+		changes["authz_roles_added"] = addedRoles
+	}
+	if len(removedRoles) > 0 {
+		changes["authz_roles_removed"] = removedRoles
+	}
+
+	grantedQuota := 0
+	for _, name := range pendingQuotaRules {
+		quota := entitlements.QuotaGrants[name]
+		if err := model.IncreaseUserQuota(user.Id, quota, true); err != nil {
+			common.SysError(fmt.Sprintf("failed to grant claim mapping quota to user %d: %s", user.Id, err.Error()))
+			continue
+		}
+		model.RecordLog(user.Id, model.LogTypeSystem, fmt.Sprintf("%s 声明映射规则 %s 奖励额度 %s", config.Name, name, logger.LogQuota(quota)))
+		grantedQuota += quota
+	}
+	if grantedQuota > 0 {
+		changes["quota"] = logger.LogQuota(grantedQuota)
+	}
+
+	if len(changes) > 0 {
+		changes["provider"] = config.Name
+		changes["username"] = user.Username
+		changes["id"] = user.Id
+		changes["rules"] = entitlements.MatchedRules
+		model.RecordOperationAuditLog(user.Id, auditContentEN("oauth.claim_mapping", changes), c.ClientIP(), "oauth.claim_mapping", changes,
+			map[string]interface{}{"auth_method": "oauth_claim_mapping"}, nil)
+	}
+	return nil
+}
+
+// applyClaimGroup 映射命中时设置分组并记住原分组；映射不再命中且分组仍是映射设置的值时恢复原分组
+func applyClaimGroup(user *model.User, grant *model.OAuthClaimGrant, group string, slug string) {
+	if group != "" && !ratio_setting.ContainsGroupRatio(group) {
+		common.SysError(fmt.Sprintf("claim mapping of provider %s references unknown group %s", slug, group))
+		group = ""
+	}
+	if group != "" {
+		if grant.GrantedGroup == "" {
+			grant.PreviousGroup = user.Group
+		}
+		grant.GrantedGroup = group
+		user.Group = group
+		return
+	}
+	if grant.GrantedGroup == "" {
+		return
+	}
+	if user.Group == grant.GrantedGroup {
+		user.Group = grant.PreviousGroup
+		if user.Group == "" {
+			user.Group = "default"
+		}
+	}
+	grant.GrantedGroup = ""
+	grant.PreviousGroup = ""
+}
+
+// applyClaimRole 与分组相同的授予/回收规则，root 用户的角色从不受映射影响。
+// 映射只能降低此前由映射授予的角色，不会把手动提升的管理员降为普通用户。
+func applyClaimRole(user *model.User, grant *model.OAuthClaimGrant, role int) {
+	if user.Role >= common.RoleRootUser {
+		return
+	}
+	if role != 0 {
+		if role < user.Role && (grant.GrantedRole == 0 || user.Role != grant.GrantedRole) {
+			return
+		}
+		if grant.GrantedRole == 0 {
+			grant.PreviousRole = user.Role
+		}
+		grant.GrantedRole = role
+		user.Role = role
+		return
+	}
+	if grant.GrantedRole == 0 {
+		return
+	}
+	if user.Role == grant.GrantedRole {
+		user.Role = grant.PreviousRole
+		if user.Role == 0 {
+			user.Role = common.RoleCommonUser
+		}
+	}
+	grant.GrantedRole = 0
+	grant.PreviousRole = 0
+}
+
+// saveClaimMappedUser 保存分组/角色变更；从管理员降级时与管理员手动降级一致，
+// 同时清除只对管理员有意义的授权覆盖与自定义角色。
+func saveClaimMappedUser(user *model.User, previousRole int) error {
+	demoted := previousRole >= common.RoleAdminUser && user.Role < common.RoleAdminUser
+	if !demoted {
+		if err := user.Update(false); err != nil {
+			return err
+		}
+	} else {
+		if err := model.DB.Transaction(func(tx *gorm.DB) error {
+			if err := user.UpdateWithTx(tx, false); err != nil {
+				return err
+			}
+			return authz.ClearUserAuthorizationInTx(tx, user.Id)
+		}); err != nil {
+			return err
+		}
+		if err := authz.ReloadPolicy(); err != nil {
+			return err
+		}
+		if err := model.PublishUserAuthCache(user.Id); err != nil {
+			return err
+		}
+		if _, err := model.RevokeAllUserSessions(user.Id, "oauth_claim_mapping"); err != nil {
+			return err
+		}
+	}
+	if err := model.InvalidateUserTokensCache(user.Id); err != nil {
+		common.SysLog(fmt.Sprintf("failed to invalidate tokens cache for user %d: %s", user.Id, err.Error()))
+	}
+	return nil
+}
+
+// planClaimAuthzRoles 计算同步后的自定义角色：添加映射要求的角色，只移除此前由映射添加、
+// 现在不再要求的角色；用户原本手动分配的角色不会记入映射授予记录，因此也不会被回收。
+// 授予记录在这里更新，角色由调用方在记录保存成功后写入。
+func planClaimAuthzRoles(userId int, grant *model.OAuthClaimGrant, desired []string, slug string) (final []string, added []string, removed []string) {
+	knownRoles := map[string]bool{}
+	for _, role := range authz.Roles() {
+		if !role.BuiltIn {
+			knownRoles[role.Key] = true
+		}
+	}
+	current := authz.UserRoleKeys(userId)
+	previouslyGranted := grant.GetAuthzRoleList()
+
+	var granted []string
+	for _, role := range desired {
+		if !knownRoles[role] {
+			common.SysError(fmt.Sprintf("claim mapping of provider %s references unknown authz role %s", slug, role))
+			continue
+		}
+		if !slices.Contains(current, role) {
+			added = append(added, role)
+			granted = append(granted, role)
+		} else if slices.Contains(previouslyGranted, role) {
+			granted = append(granted, role)
+		}
+	}
+	final = make([]string, 0, len(current)+len(added))
+	for _, role := range current {
+		if slices.Contains(previouslyGranted, role) && !slices.Contains(granted, role) {
+			removed = append(removed, role)
+			continue
+		}
+		final = append(final, role)
+	}
+	final = append(final, added...)
+	grant.SetAuthzRoleList(granted)
+	return final, added, removed
+}
+
+// validateClaimMappingReferences 保存提供商前检查映射引用的分组与自定义角色是否存在，
+// 规则本身的结构由模型层校验。
+func validateClaimMappingReferences(raw string) error {
+	rules, err := oauth.ParseClaimMappings(raw)
+	if err != nil {
+		return fmt.Errorf("claim_mappings is invalid: %w", err)
+	}
+	knownRoles := map[string]bool{}
+	for _, role := range authz.Roles() {
+		if !role.BuiltIn {
+			knownRoles[role.Key] = true
+		}
+	}
+	for _, rule := range rules {
+		if rule.Group != "" && !ratio_setting.ContainsGroupRatio(rule.Group) {
+			return fmt.Errorf("声明映射 %s 引用的分组 %s 不存在", rule.Name, rule.Group)
+		}
+		for _, role := range rule.AuthzRoles {
+			if !knownRoles[role] {
+				return fmt.Errorf("声明映射 %s 引用的角色 %s 不存在", rule.Name, role)
+			}
+		}
+	}
+	return nil
+}
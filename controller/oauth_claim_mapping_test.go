@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/service/authz"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupClaimMappingTest(t *testing.T, mappings string) (oauth.CustomProvider, *model.User) {
+	t.Helper()
+	db := setupManageUserTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.OAuthClaimGrant{}))
+	previousMaster := common.IsMasterNode
+	common.IsMasterNode = false
+	t.Cleanup(func() { common.IsMasterNode = previousMaster })
+	require.NoError(t, authz.Init(db))
+	for _, key := range []string{"channel-operator", "auditor"} {
+		require.NoError(t, authz.CreateCustomRole(authz.CustomRole{Key: key, Name: key}))
+	}
+
+	user := &model.User{
+		Username: "claim-user", Password: "password", Role: common.RoleCommonUser,
+		Status: common.UserStatusEnabled, Group: "default", AffCode: "claim-user",
+	}
+	require.NoError(t, db.Create(user).Error)
+	provider := oauth.NewGenericOAuthProvider(&model.CustomOAuthProvider{
+		Id: 1, Name: "Acme", Slug: "acme", ClaimMappings: mappings,
+	})
+	return provider, user
+}
+
+func loginWithClaims(t *testing.T, provider oauth.CustomProvider, userId int, claims string) *model.User {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/oauth/acme", nil)
+	require.NoError(t, applyOAuthClaimMappings(c, provider, userId, &oauth.OAuthUser{Claims: claims}))
+	user, err := model.GetUserById(userId, false)
+	require.NoError(t, err)
+	return user
+}
+
+func TestClaimMappingsGrantAndRevokeOnLogin(t *testing.T) {
+	provider, user := setupClaimMappingTest(t, `[{"name": "ml",
+		"when": {"conditions": [{"field": "groups", "op": "contains", "value": "ml-team"}]},
+		"group": "vip", "authz_roles": ["channel-operator", "auditor"], "quota": 500}]`)
+	// Manually assigned roles are not taken over by the mapping
+	require.NoError(t, authz.SetUserRoles(user.Id, []string{"auditor"}))
+
+	updated := loginWithClaims(t, provider, user.Id, `{"groups": ["ml-team"]}`)
+	assert.Equal(t, "vip", updated.Group)
+	assert.Equal(t, 500, updated.Quota)
+	assert.ElementsMatch(t, []string{"auditor", "channel-operator"}, authz.UserRoleKeys(user.Id))
+
+	// Quota is granted once per rule
+	updated = loginWithClaims(t, provider, user.Id, `{"groups": ["ml-team"]}`)
+	assert.Equal(t, 500, updated.Quota)
+
+	updated = loginWithClaims(t, provider, user.Id, `{"groups": []}`)
+	assert.Equal(t, "default", updated.Group)
+	assert.Equal(t, 500, updated.Quota)
+	assert.Equal(t, []string{"auditor"}, authz.UserRoleKeys(user.Id))
+}
+
+func TestClaimMappingsKeepManualGroupChanges(t *testing.T) {
+	provider, user := setupClaimMappingTest(t, `[{"name": "ml",
+		"when": {"conditions": [{"field": "groups", "op": "contains", "value": "ml-team"}]}, "group": "vip"}]`)
+
+	loginWithClaims(t, provider, user.Id, `{"groups": ["ml-team"]}`)
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", user.Id).Update("group", "svip").Error)
+
+	updated := loginWithClaims(t, provider, user.Id, `{"groups": []}`)
+	assert.Equal(t, "svip", updated.Group)
+}
+
+func TestClaimMappingsRoleFollowsClaims(t *testing.T) {
+	provider, user := setupClaimMappingTest(t, `[{"name": "platform",
+		"when": {"conditions": [{"field": "groups", "op": "contains", "value": "platform"}]}, "role": "admin"}]`)
+
+	updated := loginWithClaims(t, provider, user.Id, `{"groups": ["platform"]}`)
+	assert.Equal(t, common.RoleAdminUser, updated.Role)
+	require.NoError(t, authz.SetUserRoles(user.Id, []string{"auditor"}))
+
+	// Demotion follows the admin demote path and clears admin-only grants
+	updated = loginWithClaims(t, provider, user.Id, `{"groups": []}`)
+	assert.Equal(t, common.RoleCommonUser, updated.Role)
+	assert.Empty(t, authz.UserRoleKeys(user.Id))
+
+	// Root is never changed by a mapping
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", user.Id).Update("role", common.RoleRootUser).Error)
+	updated = loginWithClaims(t, provider, user.Id, `{"groups": []}`)
+	assert.Equal(t, common.RoleRootUser, updated.Role)
+}
+
+func TestClaimMappingsKeepManualAdmins(t *testing.T) {
+	provider, user := setupClaimMappingTest(t, `[{"name": "everyone",
+		"when": {"conditions": [{"field": "sub", "op": "exists"}]}, "role": "user"}]`)
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", user.Id).Update("role", common.RoleAdminUser).Error)
+
+	updated := loginWithClaims(t, provider, user.Id, `{"sub": "u-1"}`)
+	assert.Equal(t, common.RoleAdminUser, updated.Role)
+}
@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const redactedOptionValue = "******"
+
+// OptionRevisionView 是返回给前端的修改记录，敏感设置的取值会被隐藏
+type OptionRevisionView struct {
+	*model.OptionRevision
+	Redacted bool `json:"redacted"`
+}
+
+// OptionValueDiff 描述单个设置项的旧值、新值以及 JSON 设置中发生变化的字段
+type OptionValueDiff struct {
+	Key          string   `json:"key"`
+	OldValue     string   `json:"old_value"`
+	NewValue     string   `json:"new_value"`
+	CurrentValue string   `json:"current_value"`
+	ChangedKeys  []string `json:"changed_keys,omitempty"`
+	Redacted     bool     `json:"redacted"`
+}
+
+type optionChangeSetRequest struct {
+	Description string            `json:"description"`
+	Values      map[string]string `json:"values"`
+}
+
+func newOptionRevisionView(revision *model.OptionRevision) *OptionRevisionView {
+	if !isSensitiveOptionKey(revision.OptionKey) {
+		return &OptionRevisionView{OptionRevision: revision}
+	}
+	redacted := *revision
+	redacted.OldValue = redactedOptionValue
+	redacted.NewValue = redactedOptionValue
+	return &OptionRevisionView{OptionRevision: &redacted, Redacted: true}
+}
+
+// currentOptionValue 返回当前节点内存中生效的设置值
+func currentOptionValue(key string) string {
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	return common.OptionMap[key]
+}
+
+func newOptionValueDiff(key string, oldValue string, newValue string) OptionValueDiff {
+	diff := OptionValueDiff{
+		Key:          key,
+		OldValue:     oldValue,
+		NewValue:     newValue,
+		CurrentValue: currentOptionValue(key),
+		ChangedKeys:  service.ChangedJSONKeys(oldValue, newValue),
+	}
+	if isSensitiveOptionKey(key) {
+		diff.OldValue = redactedOptionValue
+		diff.NewValue = redactedOptionValue
+		diff.CurrentValue = redactedOptionValue
+		diff.ChangedKeys = nil
+		diff.Redacted = true
+	}
+	return diff
+}
+
+// validateOptionChange 校验通过历史回滚或变更集写入的设置项，规则与 UpdateOption 一致
+func validateOptionChange(key string, value string) error {
+	if service.IsPaymentComplianceOptionKey(key) || key == "theme.frontend" {
+		return fmt.Errorf("option %s cannot be changed here", key)
+	}
+	if !service.OptionExists(key) {
+		return fmt.Errorf("unknown option %s", key)
+	}
+	if err := service.ValidateOptionUpdate(key, value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func GetOptionRevisions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	revisions, total, err := model.GetOptionRevisions(c.Query("key"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	views := make([]*OptionRevisionView, 0, len(revisions))
+	for _, revision := range revisions {
+		views = append(views, newOptionRevisionView(revision))
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(views)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetOptionRevision(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	revision, err := model.GetOptionRevisionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"revision": newOptionRevisionView(revision),
+		"diff":     newOptionValueDiff(revision.OptionKey, revision.OldValue, revision.NewValue),
+	})
+}
+
+// RollbackOptionRevision 把设置恢复到该修改之前的值；batch=true 时回滚同一次提交中的全部修改
+func RollbackOptionRevision(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	revision, err := model.GetOptionRevisionById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	revisions := []*model.OptionRevision{revision}
+	if c.Query("batch") == "true" {
+		if revisions, err = model.GetOptionRevisionsByBatch(revision.Batch); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	values := make(map[string]string, len(revisions))
+	for _, item := range revisions {
+		// 同一批次中同一设置项只取最早一次修改前的值
+		if _, ok := values[item.OptionKey]; ok {
+			continue
+		}
+		oldValue, _, err := item.PlainValues()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		values[item.OptionKey] = oldValue
+	}
+	for key, value := range values {
+		if err := validateOptionChange(key, value); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	err = model.UpdateOptionsBulkBy(values, model.OptionChangeMeta{
+		OperatorId: c.GetInt("id"),
+		Source:     model.OptionChangeSourceRollback,
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "option.rollback", map[string]interface{}{
+		"id":    revision.Id,
+		"count": len(values),
+	})
+	common.ApiSuccess(c, gin.H{"keys": len(values)})
+}
+
+func bindOptionChangeSetRequest(c *gin.Context) (*optionChangeSetRequest, error) {
+	var req optionChangeSetRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Values) == 0 {
+		return nil, errors.New("change set is empty")
+	}
+	for key, value := range req.Values {
+		if err := validateOptionChange(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
+
+func optionChangeSetDiff(changeSet *model.OptionChangeSet) ([]OptionValueDiff, error) {
+	values, err := changeSet.GetValues()
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]OptionValueDiff, 0, len(values))
+	for key, value := range values {
+		diffs = append(diffs, newOptionValueDiff(key, currentOptionValue(key), value))
+	}
+	return diffs, nil
+}
+
+func redactOptionChangeSet(changeSet *model.OptionChangeSet) *model.OptionChangeSet {
+	values, err := changeSet.GetValues()
+	if err != nil {
+		return changeSet
+	}
+	redacted := *changeSet
+	for key := range values {
+		if isSensitiveOptionKey(key) {
+			values[key] = redactedOptionValue
+		}
+	}
+	// 直接序列化，SetValues 会把占位符当作新的密文写入
+	data, err := common.Marshal(values)
+	if err != nil {
+		return changeSet
+	}
+	redacted.Values = string(data)
+	return &redacted
+}
+
+func CreateOptionChangeSet(c *gin.Context) {
+	req, err := bindOptionChangeSetRequest(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changeSet := &model.OptionChangeSet{
+		Description: req.Description,
+		CreatedBy:   c.GetInt("id"),
+	}
+	if err := changeSet.SetValues(req.Values); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.CreateOptionChangeSet(changeSet); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, redactOptionChangeSet(changeSet))
+}
+
+func UpdateOptionChangeSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req, err := bindOptionChangeSetRequest(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changeSet := &model.OptionChangeSet{Id: id, Description: req.Description}
+	if err := changeSet.SetValues(req.Values); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.UpdateOptionChangeSetDraft(changeSet); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOptionChangeSets(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	changeSets, total, err := model.GetOptionChangeSets(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]*model.OptionChangeSet, 0, len(changeSets))
+	for _, changeSet := range changeSets {
+		items = append(items, redactOptionChangeSet(changeSet))
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetOptionChangeSet 返回变更集以及与当前生效设置的对比
+func GetOptionChangeSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changeSet, err := model.GetOptionChangeSetById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	diffs, err := optionChangeSetDiff(changeSet)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"change_set": redactOptionChangeSet(changeSet),
+		"diff":       diffs,
+	})
+}
+
+func ApplyOptionChangeSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	changeSet, err := model.GetOptionChangeSetById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 应用前按当前规则重新校验，草稿创建后校验规则可能已变化
+	values, err := changeSet.GetValues()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	for key, value := range values {
+		if err := validateOptionChange(key, value); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	changeSet, err = model.ApplyOptionChangeSet(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "option.change_set.apply", map[string]interface{}{
+		"id":    changeSet.Id,
+		"count": len(values),
+	})
+	common.ApiSuccess(c, redactOptionChangeSet(changeSet))
+}
+
+func DiscardOptionChangeSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DiscardOptionChangeSet(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
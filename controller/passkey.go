@@ -447,8 +447,7 @@ func AdminResetPasskey(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, user.Role) {
+	if !canManageTargetUser(c, user.Id, user.Role) {
 		common.ApiErrorMsg(c, "no permission")
 		return
 	}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service/authz"
+
+	"github.com/gin-gonic/gin"
+)
+
+type personalAccessTokenRequest struct {
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	AllowIps    string   `json:"allow_ips"`
+	ExpiredTime int64    `json:"expired_time"`
+}
+
+// PersonalAccessTokenView 是返回给前端的管理令牌，权限范围以数组形式展示
+type PersonalAccessTokenView struct {
+	*model.PersonalAccessToken
+	Scopes  []string `json:"scopes"`
+	Expired bool     `json:"expired"`
+}
+
+func newPersonalAccessTokenView(token *model.PersonalAccessToken) *PersonalAccessTokenView {
+	return &PersonalAccessTokenView{
+		PersonalAccessToken: token,
+		Scopes:              token.GetScopes(),
+		Expired:             token.IsExpired(),
+	}
+}
+
+// validatePersonalAccessTokenRequest 校验令牌参数，并返回规范化后的权限范围。
+// 令牌只能包含创建者当前拥有的权限。
+func validatePersonalAccessTokenRequest(c *gin.Context, req *personalAccessTokenRequest) ([]string, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		return nil, errors.New("token name must be 1 to 64 characters")
+	}
+	if req.ExpiredTime != -1 && req.ExpiredTime <= common.GetTimestamp() {
+		return nil, errors.New("expired_time must be -1 or a future unix timestamp")
+	}
+	for _, ip := range (&model.PersonalAccessToken{AllowIps: req.AllowIps}).GetIpLimits() {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid ip or cidr %q", ip)
+		}
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	seen := make(map[string]bool, len(req.Scopes))
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		permission, err := authz.ParsePermission(scope)
+		if err != nil {
+			return nil, err
+		}
+		if !authz.Can(c.GetInt("id"), c.GetInt("role"), permission) {
+			return nil, fmt.Errorf("you do not have the %s permission", permission)
+		}
+		if !seen[permission.String()] {
+			seen[permission.String()] = true
+			scopes = append(scopes, permission.String())
+		}
+	}
+	return scopes, nil
+}
+
+func GetPersonalAccessTokens(c *gin.Context) {
+	tokens, err := model.GetUserPersonalAccessTokens(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	views := make([]*PersonalAccessTokenView, 0, len(tokens))
+	for _, token := range tokens {
+		views = append(views, newPersonalAccessTokenView(token))
+	}
+	common.ApiSuccess(c, views)
+}
+
+// CreatePersonalAccessToken 创建管理令牌，明文密钥只在响应中返回这一次
+func CreatePersonalAccessToken(c *gin.Context) {
+	var req personalAccessTokenRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	scopes, err := validatePersonalAccessTokenRequest(c, &req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token := &model.PersonalAccessToken{
+		UserId:      c.GetInt("id"),
+		Name:        req.Name,
+		Scopes:      strings.Join(scopes, ","),
+		AllowIps:    strings.TrimSpace(req.AllowIps),
+		ExpiredTime: req.ExpiredTime,
+	}
+	key, err := model.CreatePersonalAccessToken(token)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"token": newPersonalAccessTokenView(token),
+		"key":   key,
+	})
+}
+
+func DeletePersonalAccessToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeletePersonalAccessToken(id, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -64,9 +64,14 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	// 按请求参数、用户设置或站点默认货币换算价格，原有字段仍以 USD 计
+	currency := service.ResolveUserCurrency(c.GetInt("id"), c.Query("currency"))
+
 	c.JSON(200, gin.H{
 		"success":            true,
 		"data":               pricing,
+		"currency":           currency,
+		"currency_prices":    service.BuildPricingCurrencyPrices(pricing, currency),
 		"vendors":            model.GetVendors(),
 		"group_ratio":        groupRatio,
 		"usable_group":       usableGroup,
@@ -93,10 +93,15 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		if newAPIError != nil {
 			logger.LogError(c, fmt.Sprintf("relay error: %s", common.LocalLogPreview(newAPIError.Error())))
 			newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
-			switch relayFormat {
-			case types.RelayFormatOpenAIRealtime:
+			switch {
+			case relayFormat == types.RelayFormatOpenAIRealtime:
 				helper.WssError(c, ws, newAPIError.ToOpenAIError())
-			case types.RelayFormatClaude:
+			case c.Writer.Written() && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream"):
+				// 流式响应已开始输出，不能再写入 JSON 响应体，改为发送 SSE 错误事件
+				_ = helper.ObjectData(c, gin.H{
+					"error": newAPIError.ToOpenAIError(),
+				})
+			case relayFormat == types.RelayFormatClaude:
 				c.JSON(newAPIError.StatusCode, gin.H{
 					"type":  "error",
 					"error": newAPIError.ToClaudeError(),
@@ -174,7 +179,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		// Only return quota if downstream failed and quota was actually pre-consumed
 		if newAPIError != nil {
 			newAPIError = service.NormalizeViolationFeeError(newAPIError)
-			if relayInfo.Billing != nil {
+			if relayInfo.StreamContinuation.Active() {
+				// 续写失败时客户端已收到部分输出，按已输出部分结算而非全额退款
+				helper.SettleInterruptedStream(c, relayInfo)
+			} else if relayInfo.Billing != nil {
 				relayInfo.Billing.Refund(c)
 			}
 			service.ChargeViolationFeeIfNeeded(c, relayInfo, newAPIError)
@@ -197,6 +205,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		if channelErr != nil {
 			logger.LogError(c, channelErr.Error())
 			newAPIError = channelErr
+			// 多 Key 渠道的 Key 全部处于冷却中：记为已尝试并换用其他渠道
+			if channel != nil && model.IsMultiKeyRateLimitedError(channelErr) &&
+				shouldRetry(c, channelErr, common.RetryTimes-retryParam.GetRetry()) {
+				addUsedChannel(c, channel.Id)
+				continue
+			}
 			break
 		}
 		addUsedChannel(c, channel.Id)
@@ -298,18 +312,24 @@ func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 }
 
 func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam) (*model.Channel, *types.NewAPIError) {
-	if info.ChannelMeta == nil {
+	setupErr, _ := common.GetContextKeyType[*types.NewAPIError](c, constant.ContextKeyChannelSetupError)
+	// 分发阶段选中的渠道 Key 全部处于冷却中时，重试需要重新选择渠道
+	if info.ChannelMeta == nil && (setupErr == nil || retryParam.GetRetry() == 0) {
 		autoBan := c.GetBool("auto_ban")
 		autoBanInt := 1
 		if !autoBan {
 			autoBanInt = 0
 		}
-		return &model.Channel{
+		channel := &model.Channel{
 			Id:      c.GetInt("channel_id"),
 			Type:    c.GetInt("channel_type"),
 			Name:    c.GetString("channel_name"),
 			AutoBan: &autoBanInt,
-		}, nil
+		}
+		if setupErr != nil {
+			return channel, setupErr
+		}
+		return channel, nil
 	}
 	channel, selectGroup, err := service.CacheGetRandomSatisfiedChannel(retryParam)
 	if err != nil {
@@ -323,6 +343,9 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 
 	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, info.OriginModelName)
 	if newAPIError != nil {
+		if model.IsMultiKeyRateLimitedError(newAPIError) {
+			return channel, newAPIError
+		}
 		return nil, newAPIError
 	}
 	return channel, nil
@@ -347,6 +370,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 首 token 超时或流中断续写：与状态码重试规则无关，始终换渠道
+	if helper.IsStreamFailoverError(openaiErr) {
+		return true
+	}
 	code := openaiErr.StatusCode
 	if code >= 200 && code < 300 {
 		return false
@@ -364,7 +391,8 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, common.LocalLogPreview(err.Error())))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
-	if service.ShouldDisableChannel(err) && channelError.AutoBan {
+	// 限流感知的多 Key 渠道遇到 429 时已冷却对应 Key，不再禁用
+	if service.ShouldDisableChannel(err) && channelError.AutoBan && !service.ShouldParkInsteadOfDisable(c, err) {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.ErrorWithStatusCode())
 		})
@@ -590,6 +618,7 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.NodeName = common.NodeName
+		task.PrivateData.CallbackURL = result.CallbackURL
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
 			GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
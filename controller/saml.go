@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// samlRequestPayload 保存 AuthnRequest 的 ID 与发起登录的 OAuth state，
+// 以 RelayState 令牌的形式在 IdP 往返期间传递
+type samlRequestPayload struct {
+	RequestId string `json:"request_id"`
+	State     string `json:"state"`
+}
+
+func samlProviderOrAbort(c *gin.Context) *oauth.SamlProvider {
+	provider := oauth.GetSamlProvider(c.Param("slug"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgOAuthUnknownProvider),
+		})
+		return nil
+	}
+	return provider
+}
+
+// redirectSamlCallback 把浏览器带回前端的 OAuth 回调页，由 HandleOAuth 完成登录或展示错误
+func redirectSamlCallback(c *gin.Context, slug string, query url.Values) {
+	target := strings.TrimRight(system_setting.ServerAddress, "/") + "/oauth/" + slug + "?" + query.Encode()
+	c.Redirect(http.StatusSeeOther, target)
+}
+
+func redirectSamlError(c *gin.Context, slug string, state string, message string) {
+	redirectSamlCallback(c, slug, url.Values{
+		"error":             {"saml_error"},
+		"error_description": {message},
+		"state":             {state},
+	})
+}
+
+// SamlMetadata 输出 SP 元数据，供管理员在 IdP 侧登记；未启用的提供商同样可以获取
+func SamlMetadata(c *gin.Context) {
+	provider := samlProviderOrAbort(c)
+	if provider == nil {
+		return
+	}
+	metadata, err := provider.Metadata()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SamlLogin 是 SAML 提供商的授权入口。前端与 OAuth 一样携带 state 跳转到这里，
+// 校验 state 后生成签名的 AuthnRequest 并重定向到 IdP。
+func SamlLogin(c *gin.Context) {
+	provider := samlProviderOrAbort(c)
+	if provider == nil {
+		return
+	}
+	slug := provider.GetConfig().Slug
+	state := c.Query("state")
+	pendingFlow, err := model.GetAuthFlow(state, model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeOAuth,
+		Provider: slug,
+	})
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgOAuthStateInvalid),
+		})
+		return
+	}
+	if !provider.IsEnabled() {
+		redirectSamlError(c, slug, state, i18n.T(c, i18n.MsgOAuthNotEnabled, providerParams(provider.GetName())))
+		return
+	}
+
+	requestId, redirect, err := provider.MakeAuthnRequest(c.Request.Context())
+	if err != nil {
+		common.SysError("failed to create SAML AuthnRequest for " + slug + ": " + err.Error())
+		redirectSamlError(c, slug, state, i18n.T(c, i18n.MsgOAuthSamlIdpErr, providerParams(provider.GetName())))
+		return
+	}
+	payload, err := common.Marshal(samlRequestPayload{RequestId: requestId, State: state})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	relayState, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose:   model.AuthFlowPurposeSamlRequest,
+		Provider:  slug,
+		Payload:   string(payload),
+		ExpiresAt: pendingFlow.ExpiresAt,
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	target, err := redirect(relayState)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// SamlAssertionConsumer 接收 IdP 通过 HTTP-POST 绑定返回的响应。
+// 校验签名、受众、有效期与 InResponseTo 后登记断言 ID 防止重放，
+// 再签发一次性票据交给前端回调页，后续登录、绑定与 JIT 建号沿用 OAuth 流程。
+func SamlAssertionConsumer(c *gin.Context) {
+	provider := samlProviderOrAbort(c)
+	if provider == nil {
+		return
+	}
+	slug := provider.GetConfig().Slug
+	relayState := c.PostForm("RelayState")
+	requestMatch := model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeSamlRequest,
+		Provider: slug,
+	}
+	requestFlow, err := model.GetAuthFlow(relayState, requestMatch)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgOAuthStateInvalid),
+		})
+		return
+	}
+	var payload samlRequestPayload
+	if err := common.UnmarshalJsonStr(requestFlow.Payload, &payload); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !provider.IsEnabled() {
+		redirectSamlError(c, slug, payload.State, i18n.T(c, i18n.MsgOAuthNotEnabled, providerParams(provider.GetName())))
+		return
+	}
+
+	assertion, err := provider.ParseResponse(c.Request.Context(), c.PostForm("SAMLResponse"), payload.RequestId)
+	if err != nil {
+		redirectSamlError(c, slug, payload.State, i18n.T(c, i18n.MsgOAuthSamlResponseErr, providerParams(provider.GetName())))
+		return
+	}
+
+	// 断言在 NotOnOrAfter（含时钟偏差）之前都可能被接受，重放记录至少保留到那时
+	replayUntil := time.Now().Add(oauthAuthFlowTTL)
+	if assertion.Conditions != nil {
+		if notOnOrAfter := assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew); notOnOrAfter.After(replayUntil) {
+			replayUntil = notOnOrAfter
+		}
+	}
+	if _, err := model.ConsumeAuthFlowWithAction(relayState, requestMatch, func(tx *gorm.DB, _ *model.AuthFlow) error {
+		return model.ClaimExternalAuthAssertionWithTx(tx, model.AuthFlowPurposeSamlAssertion, slug+":"+assertion.ID, replayUntil)
+	}); err != nil {
+		if errors.Is(err, model.ErrAuthFlowConsumed) || errors.Is(err, model.ErrAuthFlowInvalid) || errors.Is(err, model.ErrAuthFlowExpired) {
+			redirectSamlError(c, slug, payload.State, i18n.T(c, i18n.MsgOAuthStateInvalid))
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+
+	ticket, err := provider.IssueAssertionTicket(assertion, payload.State, requestFlow.ExpiresAt)
+	if err != nil {
+		redirectSamlError(c, slug, payload.State, i18n.T(c, i18n.MsgOAuthStateInvalid))
+		return
+	}
+	redirectSamlCallback(c, slug, url.Values{
+		"code":  {ticket},
+		"state": {payload.State},
+	})
+}
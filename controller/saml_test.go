@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type samlTestServiceProviders struct {
+	metadata *saml.EntityDescriptor
+}
+
+func (providers samlTestServiceProviders) GetServiceProvider(*http.Request, string) (*saml.EntityDescriptor, error) {
+	return providers.metadata, nil
+}
+
+func newSamlTestIdentityProvider(t *testing.T) *saml.IdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	return &saml.IdentityProvider{
+		Key:         key,
+		Certificate: certificate,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+}
+
+func setupSamlTest(t *testing.T) (*gin.Engine, *saml.IdentityProvider, *oauth.SamlProvider) {
+	t.Helper()
+	require.NoError(t, i18n.Init())
+	db := setupManageUserTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.AuthFlow{}, &model.CustomOAuthProvider{}, &model.UserOAuthBinding{}, &model.OAuthClaimGrant{}))
+	previousServerAddress := system_setting.ServerAddress
+	previousRegisterEnabled := common.RegisterEnabled
+	system_setting.ServerAddress = "https://gateway.example.com"
+	common.RegisterEnabled = true
+
+	idp := newSamlTestIdentityProvider(t)
+	idpMetadata, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+	config := &model.CustomOAuthProvider{
+		Kind:            model.CustomOAuthProviderKindSAML,
+		Name:            "Corp SSO",
+		Slug:            "corp-sso",
+		Enabled:         true,
+		SamlIdpMetadata: string(idpMetadata),
+		EmailField:      "mail",
+	}
+	require.NoError(t, model.CreateCustomOAuthProvider(config))
+	oauth.RegisterOrUpdateCustomProvider(config)
+	t.Cleanup(func() {
+		oauth.UnregisterCustomProvider(config.Slug)
+		system_setting.ServerAddress = previousServerAddress
+		common.RegisterEnabled = previousRegisterEnabled
+	})
+
+	provider := oauth.GetSamlProvider("corp-sso")
+	require.NotNil(t, provider)
+	spMetadata, err := provider.Metadata()
+	require.NoError(t, err)
+	var descriptor saml.EntityDescriptor
+	require.NoError(t, xml.Unmarshal(spMetadata, &descriptor))
+	idp.ServiceProviderProvider = samlTestServiceProviders{metadata: &descriptor}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/saml/:slug/metadata", SamlMetadata)
+	router.GET("/api/saml/:slug/login", SamlLogin)
+	router.POST("/api/saml/:slug/acs", SamlAssertionConsumer)
+	router.GET("/api/oauth/:provider", HandleOAuth)
+	return router, idp, provider
+}
+
+// verifySamlRedirectSignature checks the HTTP-Redirect binding signature over
+// the raw query, in the order mandated by the SAML bindings specification.
+func verifySamlRedirectSignature(t *testing.T, location *url.URL, certificatePEM string) {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certificatePEM))
+	require.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	raw := location.RawQuery
+	signatureAt := strings.Index(raw, "&Signature=")
+	require.Positive(t, signatureAt)
+	signature, err := url.QueryUnescape(raw[signatureAt+len("&Signature="):])
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(raw[:signatureAt]))
+	require.NoError(t, rsa.VerifyPKCS1v15(certificate.PublicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], decoded))
+}
+
+func samlIdpResponse(t *testing.T, idp *saml.IdentityProvider, location string, session *saml.Session) url.Values {
+	t.Helper()
+	request, err := saml.NewIdpAuthnRequest(idp, httptest.NewRequest(http.MethodGet, location, nil))
+	require.NoError(t, err)
+	require.NoError(t, request.Validate())
+	require.NoError(t, saml.DefaultAssertionMaker{}.MakeAssertion(request, session))
+	form, err := request.PostBinding()
+	require.NoError(t, err)
+	assert.Equal(t, "https://gateway.example.com/api/saml/corp-sso/acs", form.URL)
+	return url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}
+}
+
+func TestSamlMetadataPublishesSigningKeyAndPostAcs(t *testing.T) {
+	router, _, provider := setupSamlTest(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/saml/corp-sso/metadata", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/samlmetadata+xml", recorder.Header().Get("Content-Type"))
+	var descriptor saml.EntityDescriptor
+	require.NoError(t, xml.Unmarshal(recorder.Body.Bytes(), &descriptor))
+	assert.Equal(t, "https://gateway.example.com/api/saml/corp-sso/metadata", descriptor.EntityID)
+	require.Len(t, descriptor.SPSSODescriptors, 1)
+	sp := descriptor.SPSSODescriptors[0]
+	require.NotNil(t, sp.AuthnRequestsSigned)
+	assert.True(t, *sp.AuthnRequestsSigned)
+	require.Len(t, sp.AssertionConsumerServices, 1)
+	assert.Equal(t, saml.HTTPPostBinding, sp.AssertionConsumerServices[0].Binding)
+	assert.Equal(t, provider.AcsURL(), sp.AssertionConsumerServices[0].Location)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/saml/unknown/metadata", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestSamlLoginCreatesUserAndLinksBinding(t *testing.T) {
+	router, idp, provider := setupSamlTest(t)
+
+	state, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose: model.AuthFlowPurposeOAuth, Provider: "corp-sso", Intent: model.AuthFlowIntentLogin,
+		Payload: `{}`, ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	// The frontend appends OAuth parameters to the authorization endpoint; only state matters.
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+		provider.GetAuthorizationEndpoint()[len("https://gateway.example.com"):]+"?client_id=x&response_type=code&state="+state, nil))
+	require.Equal(t, http.StatusFound, recorder.Code, recorder.Body.String())
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", location.Host)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", location.Query().Get("SigAlg"))
+	verifySamlRedirectSignature(t, location, provider.GetConfig().SamlSpCertificate)
+
+	form := samlIdpResponse(t, idp, location.String(), &saml.Session{
+		ID:             "idp-session",
+		CreateTime:     time.Now(),
+		ExpireTime:     time.Now().Add(time.Hour),
+		NameID:         "employee-1001",
+		UserName:       "alice",
+		UserEmail:      "Alice@Corp.Example",
+		UserCommonName: "Alice",
+		CustomAttributes: []saml.Attribute{{
+			Name:   "displayName",
+			Values: []saml.AttributeValue{{Type: "xs:string", Value: "Alice Corp"}},
+		}},
+	})
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/saml/corp-sso/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusSeeOther, recorder.Code, recorder.Body.String())
+	callback, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/corp-sso", callback.Path)
+	assert.Equal(t, state, callback.Query().Get("state"))
+	require.NotEmpty(t, callback.Query().Get("code"))
+
+	// Replaying the same response is rejected: the relay state has been consumed.
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/api/saml/corp-sso/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/oauth/corp-sso?"+callback.RawQuery, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"success":true`, recorder.Body.String())
+
+	user, err := model.GetUserByOAuthBinding(provider.GetProviderId(), "employee-1001")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "Alice Corp", user.DisplayName)
+	assert.Equal(t, "alice@corp.example", user.Email)
+
+	// The ticket is single use.
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/oauth/corp-sso?"+callback.RawQuery, nil))
+	assert.NotContains(t, recorder.Body.String(), `"success":true`)
+}
+
+func TestSamlAcsRejectsResponseForAnotherRequest(t *testing.T) {
+	router, idp, provider := setupSamlTest(t)
+
+	newLogin := func() (string, string) {
+		state, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+			Purpose: model.AuthFlowPurposeOAuth, Provider: "corp-sso", Intent: model.AuthFlowIntentLogin,
+			Payload: `{}`, ExpiresAt: time.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/saml/corp-sso/login?state="+state, nil))
+		require.Equal(t, http.StatusFound, recorder.Code)
+		return state, recorder.Header().Get("Location")
+	}
+	_, firstLocation := newLogin()
+	secondState, secondLocation := newLogin()
+
+	session := &saml.Session{ID: "s", CreateTime: time.Now(), ExpireTime: time.Now().Add(time.Hour), NameID: "employee-1002"}
+	form := samlIdpResponse(t, idp, firstLocation, session)
+	secondRelay, err := url.Parse(secondLocation)
+	require.NoError(t, err)
+	// Pair the response to the first AuthnRequest with the second login's relay state.
+	form.Set("RelayState", secondRelay.Query().Get("RelayState"))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/saml/corp-sso/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusSeeOther, recorder.Code)
+	callback, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "saml_error", callback.Query().Get("error"))
+	assert.Equal(t, secondState, callback.Query().Get("state"))
+	assert.Empty(t, callback.Query().Get("code"))
+	count, err := model.GetBindingCountByProviderId(provider.GetProviderId())
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestUpdateSamlProviderKeepsKeyPairWithoutPrivateKey(t *testing.T) {
+	router, _, provider := setupSamlTest(t)
+	router.PUT("/api/custom-oauth-provider/:id", UpdateCustomOAuthProvider)
+	stored := provider.GetConfig()
+
+	// 编辑页面回传证书但没有私钥，原有的证书与私钥保持不变
+	body := `{"name":"Corp SSO renamed","saml_sp_certificate":` + strconv.Quote(stored.SamlSpCertificate) + `}`
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/api/custom-oauth-provider/"+strconv.Itoa(stored.Id), strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	require.Contains(t, recorder.Body.String(), `"success":true`)
+
+	updated, err := model.GetCustomOAuthProviderById(stored.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Corp SSO renamed", updated.Name)
+	assert.Equal(t, strings.TrimSpace(stored.SamlSpCertificate), updated.SamlSpCertificate)
+	assert.Equal(t, strings.TrimSpace(stored.SamlSpPrivateKey), updated.SamlSpPrivateKey)
+}
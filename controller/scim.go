@@ -0,0 +1,725 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service/scim"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const scimDefaultGroup = "default"
+
+// scimUserColumns 将 SCIM 用户属性映射到可过滤的用户列
+var scimUserColumns = map[string]string{
+	"username":     "username",
+	"displayname":  "display_name",
+	"emails":       "email",
+	"emails.value": "email",
+}
+
+func scimJSON(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+// scimAbort 将错误转换为 SCIM 错误响应，内部错误只记录日志不外泄细节
+func scimAbort(c *gin.Context, err error) {
+	var scimErr *scim.Error
+	switch {
+	case errors.As(err, &scimErr):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		scimErr = scim.NotFound("resource not found")
+	case errors.Is(err, model.ErrEmailAlreadyTaken):
+		scimErr = scim.Conflict("email is already in use")
+	case errors.Is(err, model.ErrExternalIdentityAlreadyClaimed):
+		scimErr = scim.Conflict("externalId is already linked to another user")
+	case errors.Is(err, model.ErrScimFilterUnsupported):
+		scimErr = scim.NewError(http.StatusBadRequest, "invalidFilter", "unsupported filter attribute")
+	default:
+		common.SysError("scim request failed: " + err.Error())
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "internal server error")
+	}
+	c.Header("Content-Type", scim.ContentType)
+	c.AbortWithStatusJSON(scimErr.StatusCode(), scimErr)
+}
+
+func decodeScimBody(c *gin.Context, v any) error {
+	if err := common.DecodeJson(c.Request.Body, v); err != nil {
+		return scim.NewError(http.StatusBadRequest, "invalidSyntax", "invalid request body")
+	}
+	return nil
+}
+
+// recordScimAudit 记录身份源通过 SCIM 做出的变更，日志归属于被操作用户
+func recordScimAudit(c *gin.Context, userId int, action string, params map[string]interface{}) {
+	model.RecordOperationAuditLog(userId, auditContentEN(action, params), c.ClientIP(), action, params,
+		map[string]interface{}{"auth_method": "scim"}, nil)
+}
+
+func scimLocation(resource string, id string) string {
+	return "/scim/v2/" + resource + "/" + id
+}
+
+func scimUserResource(user *model.User, externalId string) *scim.User {
+	id := strconv.Itoa(user.Id)
+	active := user.Status == common.UserStatusEnabled
+	resource := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		Id:          id,
+		ExternalId:  externalId,
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Location:     scimLocation("Users", id),
+		},
+	}
+	if user.DisplayName != "" {
+		resource.Name = &scim.Name{Formatted: user.DisplayName}
+	}
+	if user.CreatedAt > 0 {
+		resource.Meta.Created = time.Unix(user.CreatedAt, 0).UTC().Format(time.RFC3339)
+	}
+	if user.Email != "" {
+		resource.Emails = []scim.Email{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Group != "" {
+		resource.Groups = []scim.GroupRef{{Value: user.Group, Display: user.Group}}
+	}
+	return resource
+}
+
+// validateScimUser 校验身份源提交的用户，约束与后台创建用户保持一致
+func validateScimUser(resource *scim.User) error {
+	resource.UserName = strings.TrimSpace(resource.UserName)
+	if resource.UserName == "" {
+		return scim.InvalidValue("userName is required")
+	}
+	if len(resource.UserName) > model.UserNameMaxLength {
+		return scim.InvalidValue("userName must not exceed %d characters", model.UserNameMaxLength)
+	}
+	if len(resource.PrimaryEmail()) > 50 {
+		return scim.InvalidValue("email must not exceed 50 characters")
+	}
+	if strings.TrimSpace(resource.ExternalId) == "" {
+		return scim.InvalidValue("externalId is required")
+	}
+	return nil
+}
+
+// applyScimUser 把 SCIM 资源写入用户；显示名超出长度时截断而不是拒绝
+func applyScimUser(user *model.User, resource *scim.User) {
+	user.Username = resource.UserName
+	displayName := resource.ResolvedDisplayName()
+	if displayName == "" {
+		displayName = resource.UserName
+	}
+	if runes := []rune(displayName); len(runes) > 20 {
+		displayName = string(runes[:20])
+	}
+	user.DisplayName = displayName
+	user.Email = resource.PrimaryEmail()
+	if resource.IsActive() {
+		user.Status = common.UserStatusEnabled
+	} else {
+		user.Status = common.UserStatusDisabled
+	}
+}
+
+func scimUserId(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, scim.NotFound("user %s not found", c.Param("id"))
+	}
+	return id, nil
+}
+
+// checkScimManageable 拒绝通过 SCIM 修改管理员：身份源只能管理普通用户，
+// 否则持有 SCIM 令牌即可停用、改名或调整管理员的分组
+func checkScimManageable(user *model.User) error {
+	if user.Role >= common.RoleAdminUser {
+		return scim.NewError(http.StatusForbidden, "", "administrators cannot be managed through SCIM")
+	}
+	return nil
+}
+
+// checkScimProvisioned 只允许修改由 SCIM 下发（登记了 externalId）的用户，
+// 自行注册或由其他方式创建的账号对身份源表现为不存在
+func checkScimProvisioned(user *model.User) error {
+	externalId, err := scimExternalId(user.Id)
+	if err != nil {
+		return err
+	}
+	if externalId == "" {
+		return scim.NotFound("user %d not found", user.Id)
+	}
+	return nil
+}
+
+// loadScimUser 读取可由 SCIM 修改的用户；管理员和未经 SCIM 下发的用户不接受身份源的变更
+func loadScimUser(c *gin.Context, forWrite bool) (*model.User, error) {
+	id, err := scimUserId(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.NotFound("user %d not found", id)
+		}
+		return nil, err
+	}
+	if forWrite {
+		if err := checkScimManageable(user); err != nil {
+			return nil, err
+		}
+		if err := checkScimProvisioned(user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func scimExternalId(userId int) (string, error) {
+	subjects, err := model.GetExternalIdentitySubjects(model.ExternalIdentityProviderSCIM, []int{userId})
+	if err != nil {
+		return "", err
+	}
+	return subjects[userId], nil
+}
+
+func ensureScimUserNameAvailable(username string, excludeUserId int) error {
+	taken, err := model.IsUsernameTakenByOthers(username, excludeUserId)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return scim.Conflict("userName %s is already in use", username)
+	}
+	return nil
+}
+
+func ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, scim.ServiceProviderConfig())
+}
+
+func ScimResourceTypes(c *gin.Context) {
+	resources := scim.ResourceTypes()
+	scimJSON(c, http.StatusOK, scim.NewListResponse(resources, int64(len(resources)), 1))
+}
+
+func ListScimUsers(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	startIndex, count := scim.ParsePagination(c.Query("startIndex"), c.Query("count"))
+	var users []*model.User
+	var total int64
+	if filter != nil && (filter.Attribute == "id" || filter.Attribute == "externalid") {
+		if filter.Operator != scim.OpEqual {
+			scimAbort(c, scim.NewError(http.StatusBadRequest, "invalidFilter", "%s only supports eq", filter.Attribute))
+			return
+		}
+		userId := 0
+		if filter.Attribute == "id" {
+			userId, _ = strconv.Atoi(filter.Value)
+		} else if userId, err = model.GetUserIdByExternalIdentity(model.ExternalIdentityProviderSCIM, filter.Value); err != nil {
+			scimAbort(c, err)
+			return
+		}
+		if userId > 0 {
+			user, err := model.GetUserById(userId, false)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				scimAbort(c, err)
+				return
+			}
+			if err == nil {
+				total = 1
+				if startIndex == 1 && count > 0 {
+					users = append(users, user)
+				}
+			}
+		}
+	} else {
+		var userFilter *model.ScimUserFilter
+		if filter != nil {
+			column, ok := scimUserColumns[filter.Attribute]
+			if !ok {
+				scimAbort(c, model.ErrScimFilterUnsupported)
+				return
+			}
+			userFilter = &model.ScimUserFilter{Column: column, Operator: filter.Operator, Value: filter.Value}
+		}
+		users, total, err = model.FindScimUsers(userFilter, startIndex-1, count)
+		if err != nil {
+			scimAbort(c, err)
+			return
+		}
+	}
+	userIds := make([]int, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.Id)
+	}
+	subjects, err := model.GetExternalIdentitySubjects(model.ExternalIdentityProviderSCIM, userIds)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	resources := make([]any, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, scimUserResource(user, subjects[user.Id]))
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex))
+}
+
+func GetScimUser(c *gin.Context) {
+	user, err := loadScimUser(c, false)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	externalId, err := scimExternalId(user.Id)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user, externalId))
+}
+
+// CreateScimUser 创建身份源下发的用户。身份源提交的密码会被忽略，
+// 用户获得随机密码，通常只通过单点登录进入控制台。
+func CreateScimUser(c *gin.Context) {
+	var resource scim.User
+	if err := decodeScimBody(c, &resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := validateScimUser(&resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := ensureScimUserNameAvailable(resource.UserName, 0); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	user := &model.User{
+		Role:     common.RoleCommonUser,
+		Group:    scimDefaultGroup,
+		Password: common.GetRandomString(20),
+	}
+	applyScimUser(user, &resource)
+	externalId := strings.TrimSpace(resource.ExternalId)
+	if err := model.CreateScimUser(user, &externalId); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	recordScimAudit(c, user.Id, "scim.user_create", map[string]interface{}{
+		"username": user.Username,
+		"id":       user.Id,
+	})
+	c.Header("Location", scimLocation("Users", strconv.Itoa(user.Id)))
+	scimJSON(c, http.StatusCreated, scimUserResource(user, externalId))
+}
+
+// saveScimUser 保存 PUT/PATCH 得到的完整资源并返回最新表示；
+// externalId 不能被清空，否则用户会脱离 SCIM 的管理
+func saveScimUser(c *gin.Context, user *model.User, resource *scim.User) {
+	if err := validateScimUser(resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := ensureScimUserNameAvailable(resource.UserName, user.Id); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	wasEnabled := user.Status == common.UserStatusEnabled
+	applyScimUser(user, resource)
+	externalId := strings.TrimSpace(resource.ExternalId)
+	if err := model.UpdateScimUser(user, &externalId); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	action := "scim.user_update"
+	if wasEnabled && user.Status != common.UserStatusEnabled {
+		action = "scim.user_suspend"
+	} else if !wasEnabled && user.Status == common.UserStatusEnabled {
+		action = "scim.user_activate"
+	}
+	recordScimAudit(c, user.Id, action, map[string]interface{}{
+		"username": user.Username,
+		"id":       user.Id,
+	})
+	scimJSON(c, http.StatusOK, scimUserResource(user, externalId))
+}
+
+func ReplaceScimUser(c *gin.Context) {
+	user, err := loadScimUser(c, true)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	var resource scim.User
+	if err := decodeScimBody(c, &resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	saveScimUser(c, user, &resource)
+}
+
+func PatchScimUser(c *gin.Context) {
+	user, err := loadScimUser(c, true)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	var request scim.PatchRequest
+	if err := decodeScimBody(c, &request); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	externalId, err := scimExternalId(user.Id)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	resource := scimUserResource(user, externalId)
+	if err := scim.ApplyUserPatch(resource, request.Operations); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	saveScimUser(c, user, resource)
+}
+
+func DeleteScimUser(c *gin.Context) {
+	user, err := loadScimUser(c, true)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := model.DeleteScimUser(user); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := model.InvalidateUserTokensCache(user.Id); err != nil {
+		common.SysLog(fmt.Sprintf("failed to invalidate tokens cache for user %d: %s", user.Id, err.Error()))
+	}
+	recordScimAudit(c, user.Id, "scim.user_delete", map[string]interface{}{
+		"username": user.Username,
+		"id":       user.Id,
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// scimGroupNames 返回可供身份源使用的分组，即已配置倍率的用户分组
+func scimGroupNames() []string {
+	ratios := ratio_setting.GetGroupRatioCopy()
+	names := make([]string, 0, len(ratios))
+	for name := range ratios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func scimGroupResource(name string, withMembers bool) (*scim.Group, error) {
+	group := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		Id:          name,
+		DisplayName: name,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Location:     scimLocation("Groups", name),
+		},
+	}
+	if !withMembers {
+		return group, nil
+	}
+	users, err := model.GetUsersByGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	group.Members = make([]scim.Member, 0, len(users))
+	for _, user := range users {
+		id := strconv.Itoa(user.Id)
+		group.Members = append(group.Members, scim.Member{
+			Value:   id,
+			Display: user.Username,
+			Ref:     scimLocation("Users", id),
+		})
+	}
+	return group, nil
+}
+
+func scimWithMembers(c *gin.Context) bool {
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if scim.NormalizeAttribute(attr) == "members" {
+			return false
+		}
+	}
+	return true
+}
+
+func loadScimGroupName(c *gin.Context) (string, error) {
+	name := c.Param("id")
+	if !ratio_setting.ContainsGroupRatio(name) {
+		return "", scim.NotFound("group %s not found", name)
+	}
+	return name, nil
+}
+
+// setScimUserGroup 修改用户分组；分组变化会推进 AuthVersion
+func setScimUserGroup(c *gin.Context, memberId string, group string) error {
+	id, err := strconv.Atoi(memberId)
+	if err != nil || id <= 0 {
+		return scim.InvalidValue("invalid member %q", memberId)
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return scim.InvalidValue("member %q does not exist", memberId)
+		}
+		return err
+	}
+	if user.Group == group {
+		return nil
+	}
+	if err := checkScimManageable(user); err != nil {
+		return err
+	}
+	externalId, err := scimExternalId(user.Id)
+	if err != nil {
+		return err
+	}
+	if externalId == "" {
+		return scim.InvalidValue("member %q does not exist", memberId)
+	}
+	previous := user.Group
+	user.Group = group
+	if err := model.UpdateScimUser(user, nil); err != nil {
+		return err
+	}
+	recordScimAudit(c, user.Id, "scim.user_group", map[string]interface{}{
+		"username": user.Username,
+		"from":     previous,
+		"to":       group,
+	})
+	return nil
+}
+
+// applyScimGroupMembers 让分组成员与 members 完全一致，移出的成员回到默认分组
+func applyScimGroupMembers(c *gin.Context, group string, members []string) error {
+	current, err := model.GetUsersByGroup(group)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(members))
+	for _, member := range members {
+		keep[member] = true
+	}
+	userIds := make([]int, 0, len(current))
+	for _, user := range current {
+		userIds = append(userIds, user.Id)
+	}
+	subjects, err := model.GetExternalIdentitySubjects(model.ExternalIdentityProviderSCIM, userIds)
+	if err != nil {
+		return err
+	}
+	for _, user := range current {
+		id := strconv.Itoa(user.Id)
+		// 管理员和未经 SCIM 下发的用户不由 SCIM 管理，保留其所在分组
+		if keep[id] || group == scimDefaultGroup || user.Role >= common.RoleAdminUser || subjects[user.Id] == "" {
+			continue
+		}
+		if err := setScimUserGroup(c, id, scimDefaultGroup); err != nil {
+			return err
+		}
+	}
+	for _, member := range members {
+		if err := setScimUserGroup(c, member, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeScimGroupMembers(c *gin.Context, group string, members []string) error {
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		user, err := model.GetUserById(id, false)
+		if err != nil || user.Group != group {
+			continue
+		}
+		if err := setScimUserGroup(c, member, scimDefaultGroup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func respondScimGroup(c *gin.Context, status int, name string) {
+	group, err := scimGroupResource(name, true)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	scimJSON(c, status, group)
+}
+
+func ListScimGroups(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if filter != nil && filter.Attribute != "displayname" && filter.Attribute != "id" {
+		scimAbort(c, scim.NewError(http.StatusBadRequest, "invalidFilter", "unsupported filter attribute %s", filter.Attribute))
+		return
+	}
+	startIndex, count := scim.ParsePagination(c.Query("startIndex"), c.Query("count"))
+	names := make([]string, 0)
+	for _, name := range scimGroupNames() {
+		if filter == nil || filter.Match(name) {
+			names = append(names, name)
+		}
+	}
+	withMembers := scimWithMembers(c)
+	resources := make([]any, 0)
+	for i := startIndex - 1; i < len(names) && len(resources) < count; i++ {
+		group, err := scimGroupResource(names[i], withMembers)
+		if err != nil {
+			scimAbort(c, err)
+			return
+		}
+		resources = append(resources, group)
+	}
+	scimJSON(c, http.StatusOK, scim.NewListResponse(resources, int64(len(names)), startIndex))
+}
+
+func GetScimGroup(c *gin.Context) {
+	name, err := loadScimGroupName(c)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	group, err := scimGroupResource(name, scimWithMembers(c))
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, group)
+}
+
+// CreateScimGroup 关联身份源的组与已配置的用户分组。分组本身由倍率设置定义，
+// 因此这里只接受已存在的分组名，并按请求设置成员。
+func CreateScimGroup(c *gin.Context) {
+	var resource scim.Group
+	if err := decodeScimBody(c, &resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	name := strings.TrimSpace(resource.DisplayName)
+	if !ratio_setting.ContainsGroupRatio(name) {
+		scimAbort(c, scim.InvalidValue("group %q must be configured in the group ratio settings first", name))
+		return
+	}
+	if len(resource.Members) > 0 {
+		if err := applyScimGroupMembers(c, name, resource.MemberIds()); err != nil {
+			scimAbort(c, err)
+			return
+		}
+	}
+	c.Header("Location", scimLocation("Groups", name))
+	respondScimGroup(c, http.StatusCreated, name)
+}
+
+func ReplaceScimGroup(c *gin.Context) {
+	name, err := loadScimGroupName(c)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	var resource scim.Group
+	if err := decodeScimBody(c, &resource); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if resource.DisplayName != "" && resource.DisplayName != name {
+		scimAbort(c, scim.NewError(http.StatusBadRequest, "mutability", "groups cannot be renamed"))
+		return
+	}
+	if err := applyScimGroupMembers(c, name, resource.MemberIds()); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	respondScimGroup(c, http.StatusOK, name)
+}
+
+func PatchScimGroup(c *gin.Context) {
+	name, err := loadScimGroupName(c)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	var request scim.PatchRequest
+	if err := decodeScimBody(c, &request); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	patch, err := scim.ParseGroupPatch(request.Operations)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if patch.DisplayName != "" && patch.DisplayName != name {
+		scimAbort(c, scim.NewError(http.StatusBadRequest, "mutability", "groups cannot be renamed"))
+		return
+	}
+	if patch.ReplaceMembers {
+		err = applyScimGroupMembers(c, name, patch.Members)
+	}
+	if err == nil {
+		err = removeScimGroupMembers(c, name, patch.Remove)
+	}
+	for _, member := range patch.Add {
+		if err != nil {
+			break
+		}
+		err = setScimUserGroup(c, member, name)
+	}
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	respondScimGroup(c, http.StatusOK, name)
+}
+
+// DeleteScimGroup 解除身份源组的关联：成员回到默认分组，分组配置保留
+func DeleteScimGroup(c *gin.Context) {
+	name, err := loadScimGroupName(c)
+	if err != nil {
+		scimAbort(c, err)
+		return
+	}
+	if err := applyScimGroupMembers(c, name, nil); err != nil {
+		scimAbort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
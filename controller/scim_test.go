@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupScimTest(t *testing.T) (*gorm.DB, *gin.Engine) {
+	t.Helper()
+	db := setupManageUserTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.ExternalIdentityClaim{}, &model.Token{}))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/scim/v2")
+	group.GET("/Users", ListScimUsers)
+	group.POST("/Users", CreateScimUser)
+	group.GET("/Users/:id", GetScimUser)
+	group.PATCH("/Users/:id", PatchScimUser)
+	group.DELETE("/Users/:id", DeleteScimUser)
+	group.GET("/Groups/:id", GetScimGroup)
+	group.PATCH("/Groups/:id", PatchScimGroup)
+	return db, router
+}
+
+func performScimRequest(router http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/scim+json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestScimUserLifecycle(t *testing.T) {
+	db, router := setupScimTest(t)
+
+	recorder := performScimRequest(router, http.MethodPost, "/scim/v2/Users", `{
+		"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName":"scim-alice","externalId":"idp-1001",
+		"name":{"givenName":"Alice","familyName":"Liddell"},
+		"emails":[{"value":"Alice@Example.com","primary":true}],"active":true}`)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "application/scim+json", recorder.Header().Get("Content-Type"))
+
+	var user model.User
+	require.NoError(t, db.Where("username = ?", "scim-alice").First(&user).Error)
+	assert.Equal(t, "Alice Liddell", user.DisplayName)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, common.UserStatusEnabled, user.Status)
+
+	recorder = performScimRequest(router, http.MethodPost, "/scim/v2/Users", `{"userName":"scim-alice","externalId":"idp-1002"}`)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	recorder = performScimRequest(router, http.MethodPost, "/scim/v2/Users", `{"userName":"scim-carol"}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "users must be provisioned with an externalId")
+
+	recorder = performScimRequest(router, http.MethodGet, `/scim/v2/Users?filter=externalId+eq+"idp-1001"`, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"totalResults":1`)
+	assert.Contains(t, recorder.Body.String(), `"userName":"scim-alice"`)
+
+	recorder = performScimRequest(router, http.MethodGet, `/scim/v2/Users?filter=userName+eq+"nobody"`, "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"totalResults":0`)
+
+	now := time.Now().Unix()
+	require.NoError(t, db.Create(&model.UserSession{
+		SID: "scim-alice-session", UserID: user.Id, Version: 1, UserAuthVersion: user.AuthVersion,
+		Status: model.UserSessionStatusActive, RefreshHash: "refresh-hash", LoginMethod: "password",
+		LastActiveAt: now, ExpiresAt: now + 3600,
+	}).Error)
+
+	recorder = performScimRequest(router, http.MethodPatch, fmt.Sprintf("/scim/v2/Users/%d", user.Id), `{
+		"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations":[{"op":"Replace","path":"active","value":"False"}]}`)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Body.String(), `"active":false`)
+
+	var suspended model.User
+	require.NoError(t, db.First(&suspended, user.Id).Error)
+	assert.Equal(t, common.UserStatusDisabled, suspended.Status)
+	assert.Greater(t, suspended.AuthVersion, user.AuthVersion)
+	var session model.UserSession
+	require.NoError(t, db.First(&session, "sid = ?", "scim-alice-session").Error)
+	assert.Equal(t, model.UserSessionStatusRevoked, session.Status)
+
+	recorder = performScimRequest(router, http.MethodDelete, fmt.Sprintf("/scim/v2/Users/%d", user.Id), "")
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	recorder = performScimRequest(router, http.MethodGet, fmt.Sprintf("/scim/v2/Users/%d", user.Id), "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	var claims int64
+	require.NoError(t, db.Model(&model.ExternalIdentityClaim{}).Where("provider = ?", model.ExternalIdentityProviderSCIM).Count(&claims).Error)
+	assert.Zero(t, claims)
+}
+
+func TestScimRefusesAdministrators(t *testing.T) {
+	db, router := setupScimTest(t)
+	root := model.User{Username: "scim-root", Password: "password", Role: common.RoleRootUser, Status: common.UserStatusEnabled, AuthVersion: 1}
+	require.NoError(t, db.Create(&root).Error)
+	admin := model.User{Username: "scim-admin", Password: "password", Role: common.RoleAdminUser, Status: common.UserStatusEnabled, Group: "default", AffCode: "scim-admin", AuthVersion: 1}
+	require.NoError(t, db.Create(&admin).Error)
+
+	for _, id := range []int{root.Id, admin.Id} {
+		recorder := performScimRequest(router, http.MethodPatch, fmt.Sprintf("/scim/v2/Users/%d", id),
+			`{"Operations":[{"op":"replace","path":"active","value":false}]}`)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		recorder = performScimRequest(router, http.MethodDelete, fmt.Sprintf("/scim/v2/Users/%d", id), "")
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	}
+
+	recorder := performScimRequest(router, http.MethodPatch, "/scim/v2/Groups/vip", fmt.Sprintf(
+		`{"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, admin.Id))
+	assert.NotEqual(t, http.StatusOK, recorder.Code)
+	var stored model.User
+	require.NoError(t, db.First(&stored, admin.Id).Error)
+	assert.Equal(t, "default", stored.Group, "SCIM must not move administrators between groups")
+}
+
+func TestScimGroupMembershipUpdatesUserGroup(t *testing.T) {
+	db, router := setupScimTest(t)
+	user := model.User{Username: "scim-bob", Password: "password", Role: common.RoleCommonUser, Status: common.UserStatusEnabled, Group: "default", AuthVersion: 1}
+	require.NoError(t, db.Create(&user).Error)
+	require.NoError(t, model.ClaimExternalIdentityWithTx(db, model.ExternalIdentityProviderSCIM, "idp-bob", user.Id))
+
+	recorder := performScimRequest(router, http.MethodPatch, "/scim/v2/Groups/vip", fmt.Sprintf(
+		`{"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, user.Id))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Contains(t, recorder.Body.String(), fmt.Sprintf(`"value":"%d"`, user.Id))
+
+	var updated model.User
+	require.NoError(t, db.First(&updated, user.Id).Error)
+	assert.Equal(t, "vip", updated.Group)
+	assert.EqualValues(t, 2, updated.AuthVersion)
+
+	recorder = performScimRequest(router, http.MethodPatch, "/scim/v2/Groups/vip", fmt.Sprintf(
+		`{"Operations":[{"op":"remove","path":"members[value eq \"%d\"]"}]}`, user.Id))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, db.First(&updated, user.Id).Error)
+	assert.Equal(t, "default", updated.Group)
+
+	recorder = performScimRequest(router, http.MethodGet, "/scim/v2/Groups/unknown", "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestScimOnlyWritesProvisionedUsers(t *testing.T) {
+	db, router := setupScimTest(t)
+	local := model.User{Username: "local-dave", Password: "local-password", DisplayName: "Dave", Role: common.RoleCommonUser, Status: common.UserStatusEnabled, Group: "default", AuthVersion: 1}
+	require.NoError(t, db.Create(&local).Error)
+
+	recorder := performScimRequest(router, http.MethodPatch, fmt.Sprintf("/scim/v2/Users/%d", local.Id),
+		`{"Operations":[{"op":"replace","path":"password","value":"hijacked1"},{"op":"replace","path":"displayName","value":"Mallory"}]}`)
+	assert.Equal(t, http.StatusNotFound, recorder.Code, recorder.Body.String())
+	recorder = performScimRequest(router, http.MethodDelete, fmt.Sprintf("/scim/v2/Users/%d", local.Id), "")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder = performScimRequest(router, http.MethodPatch, "/scim/v2/Groups/vip", fmt.Sprintf(
+		`{"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, local.Id))
+	assert.NotEqual(t, http.StatusOK, recorder.Code)
+
+	var stored model.User
+	require.NoError(t, db.First(&stored, local.Id).Error)
+	assert.Equal(t, "local-password", stored.Password)
+	assert.Equal(t, "Dave", stored.DisplayName)
+	assert.Equal(t, "default", stored.Group)
+
+	recorder = performScimRequest(router, http.MethodPost, "/scim/v2/Users",
+		`{"userName":"scim-erin","externalId":"idp-erin","password":"provided-password"}`)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var provisioned model.User
+	require.NoError(t, db.Where("username = ?", "scim-erin").First(&provisioned).Error)
+	assert.False(t, common.ValidatePasswordAndHash("provided-password", provisioned.Password), "SCIM must not set local passwords")
+	passwordHash := provisioned.Password
+
+	recorder = performScimRequest(router, http.MethodPatch, fmt.Sprintf("/scim/v2/Users/%d", provisioned.Id),
+		`{"Operations":[{"op":"replace","path":"password","value":"changed-password"},{"op":"replace","path":"displayName","value":"Erin"}]}`)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, db.First(&provisioned, provisioned.Id).Error)
+	assert.Equal(t, "Erin", provisioned.DisplayName)
+	assert.Equal(t, passwordHash, provisioned.Password)
+
+	recorder = performScimRequest(router, http.MethodPatch, fmt.Sprintf("/scim/v2/Users/%d", provisioned.Id),
+		`{"Operations":[{"op":"remove","path":"externalId"}]}`)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code, "externalId cannot be cleared")
+}
@@ -138,6 +138,29 @@ type AdminUpsertSubscriptionPlanRequest struct {
 	Plan model.SubscriptionPlan `json:"plan"`
 }
 
+// normalizeSubscriptionCurrencyPrices upper-cases the currency codes of the
+// per-currency plan prices and rejects unknown currencies or invalid prices.
+func normalizeSubscriptionCurrencyPrices(prices model.CurrencyPrices) (model.CurrencyPrices, string) {
+	if len(prices) == 0 {
+		return nil, ""
+	}
+	normalized := make(model.CurrencyPrices, len(prices))
+	for code, price := range prices {
+		code = operation_setting.NormalizeCurrencyCode(code)
+		if _, ok := operation_setting.GetCurrency(code); !ok {
+			return nil, fmt.Sprintf("货币 %s 未配置", code)
+		}
+		if price < 0 {
+			return nil, "价格不能为负数"
+		}
+		if price > 9999 {
+			return nil, "价格不能超过9999"
+		}
+		normalized[code] = price
+	}
+	return normalized, ""
+}
+
 func AdminCreateSubscriptionPlan(c *gin.Context) {
 	if !requirePaymentCompliance(c) {
 		return
@@ -165,6 +188,12 @@ func AdminCreateSubscriptionPlan(c *gin.Context) {
 		req.Plan.Currency = "USD"
 	}
 	req.Plan.Currency = "USD"
+	currencyPrices, errMsg := normalizeSubscriptionCurrencyPrices(req.Plan.CurrencyPrices)
+	if errMsg != "" {
+		common.ApiErrorMsg(c, errMsg)
+		return
+	}
+	req.Plan.CurrencyPrices = currencyPrices
 	if req.Plan.AllowBalancePay == nil {
 		req.Plan.AllowBalancePay = common.GetPointer(true)
 	}
@@ -245,6 +274,12 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		req.Plan.Currency = "USD"
 	}
 	req.Plan.Currency = "USD"
+	currencyPrices, errMsg := normalizeSubscriptionCurrencyPrices(req.Plan.CurrencyPrices)
+	if errMsg != "" {
+		common.ApiErrorMsg(c, errMsg)
+		return
+	}
+	req.Plan.CurrencyPrices = currencyPrices
 	if req.Plan.DurationUnit == "" {
 		req.Plan.DurationUnit = model.SubscriptionDurationMonth
 	}
@@ -286,6 +321,7 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 			"subtitle":                   req.Plan.Subtitle,
 			"price_amount":               req.Plan.PriceAmount,
 			"currency":                   req.Plan.Currency,
+			"currency_prices":            req.Plan.CurrencyPrices,
 			"duration_unit":              req.Plan.DurationUnit,
 			"duration_value":             req.Plan.DurationValue,
 			"custom_seconds":             req.Plan.CustomSeconds,
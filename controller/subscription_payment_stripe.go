@@ -86,6 +86,9 @@ func SubscriptionRequestStripePay(c *gin.Context) {
 		return
 	}
 
+	// 订阅按 Stripe Price 固定扣款，Price 对应套餐的基础价格与货币，
+	// 订单据此记录，不使用实际不会被扣款的按货币配置的价格
+	currency, exchangeRate := paymentCurrencySnapshot(plan.Currency)
 	order := &model.SubscriptionOrder{
 		UserId:          userId,
 		PlanId:          plan.Id,
@@ -95,6 +98,8 @@ func SubscriptionRequestStripePay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderStripe,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	if err := order.Insert(); err != nil {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "创建订单失败"})
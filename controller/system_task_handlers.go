@@ -13,15 +13,19 @@ import (
 )
 
 // RegisterScheduledSystemTasks wires the periodic channel test, upstream model
-// update, and async task polling (Midjourney / Suno / video) jobs into the
-// system task framework so a DB lease dedups execution across multiple master
-// instances and each run is recorded as one task row. Call this before
+// update, channel schedule, async task polling (Midjourney / Suno / video),
+// event webhook delivery and exchange rate refresh jobs into the system task framework so a DB lease dedups execution across
+// multiple master instances and each run is recorded as one task row. Call this before
 // service.StartSystemTaskRunner.
 func RegisterScheduledSystemTasks() {
 	service.RegisterSystemTaskHandler(channelTestHandler{})
 	service.RegisterSystemTaskHandler(modelUpdateHandler{})
 	service.RegisterSystemTaskHandler(midjourneyPollHandler{})
 	service.RegisterSystemTaskHandler(asyncTaskPollHandler{})
+	service.RegisterSystemTaskHandler(channelScheduleHandler{})
+	service.RegisterSystemTaskHandler(webhookDeliveryHandler{})
+	service.RegisterSystemTaskHandler(taskArtifactHandler{})
+	service.RegisterSystemTaskHandler(currencyRateHandler{})
 }
 
 // channelTestHandler runs the scheduled "test all channels" job. Enablement and
@@ -152,6 +156,96 @@ func (asyncTaskPollHandler) Run(ctx context.Context, task *model.SystemTask, run
 	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
 }
 
+// channelScheduleHandler evaluates channel time-window schedules every minute
+// and applies or reverts the ones whose window state changed. Enabled() only
+// reports true while at least one schedule is enabled or still active.
+type channelScheduleHandler struct{}
+
+func (channelScheduleHandler) Type() string { return model.SystemTaskTypeChannelSchedule }
+
+func (channelScheduleHandler) Enabled() bool {
+	return model.HasSchedulableChannelSchedules()
+}
+
+func (channelScheduleHandler) Interval() time.Duration { return time.Minute }
+
+func (channelScheduleHandler) NewPayload() any { return nil }
+
+func (channelScheduleHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	summary := service.RunChannelSchedulesOnce(ctx)
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// webhookDeliveryHandler sends due event webhook deliveries, including retries
+// whose backoff elapsed. New events enqueue a run directly; the schedule only
+// picks up retries, so Enabled() reports true while a delivery is due.
+type webhookDeliveryHandler struct{}
+
+func (webhookDeliveryHandler) Type() string { return model.SystemTaskTypeWebhookDelivery }
+
+func (webhookDeliveryHandler) Enabled() bool {
+	return model.HasDueWebhookDeliveries(common.GetTimestamp())
+}
+
+func (webhookDeliveryHandler) Interval() time.Duration { return 15 * time.Second }
+
+func (webhookDeliveryHandler) NewPayload() any { return nil }
+
+func (webhookDeliveryHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	summary := service.RunWebhookDeliveriesOnce(ctx)
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// taskArtifactHandler re-hosts finished async task results and deletes
+// artifacts past their retention.
+type taskArtifactHandler struct{}
+
+func (taskArtifactHandler) Type() string { return model.SystemTaskTypeTaskArtifact }
+
+func (taskArtifactHandler) Enabled() bool {
+	return model.HasDueTaskArtifacts(common.GetTimestamp())
+}
+
+func (taskArtifactHandler) Interval() time.Duration { return time.Minute }
+
+func (taskArtifactHandler) NewPayload() any { return nil }
+
+func (taskArtifactHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	summary := service.RunTaskArtifactsOnce(ctx)
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// currencyRateHandler refreshes the exchange rates of currencies whose source
+// is "auto". Admins can also trigger a run manually via
+// RefreshCurrencyRatesSystemTask.
+type currencyRateHandler struct{}
+
+func (currencyRateHandler) Type() string { return model.SystemTaskTypeCurrencyRate }
+
+func (currencyRateHandler) Enabled() bool {
+	currencySetting := operation_setting.GetCurrencySetting()
+	return currencySetting.AutoRefreshEnabled && currencySetting.RefreshURL != ""
+}
+
+func (currencyRateHandler) Interval() time.Duration {
+	minutes := operation_setting.GetCurrencySetting().RefreshIntervalMinutes
+	if minutes <= 0 {
+		minutes = 360
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (currencyRateHandler) NewPayload() any { return nil }
+
+func (currencyRateHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	result, err := service.RefreshCurrencyRates(ctx)
+	if err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, nil, err)
+		return
+	}
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, result, nil)
+}
+
 func finishSystemTaskHandler(task *model.SystemTask, runnerID string, status model.SystemTaskStatus, result any, runErr error) {
 	errorMessage := ""
 	if runErr != nil {
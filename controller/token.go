@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -76,6 +77,19 @@ func getTokenRequestUserGroup(c *gin.Context) (string, error) {
 	return model.GetUserGroup(c.GetInt("id"), false)
 }
 
+// validateTokenCallbackUrl 校验令牌的默认任务回调地址，失败时已写入错误响应
+func validateTokenCallbackUrl(c *gin.Context, token *model.Token) bool {
+	token.CallbackUrl = strings.TrimSpace(token.CallbackUrl)
+	if token.CallbackUrl == "" {
+		return true
+	}
+	if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTokenCallbackUrlInvalid, map[string]any{"Error": err.Error()})
+		return false
+	}
+	return true
+}
+
 func setTokenAutoGroups(c *gin.Context, token *model.Token, groups []string) bool {
 	if len(groups) == 0 {
 		if err := token.SetAutoGroups(nil); err != nil {
@@ -191,6 +205,48 @@ func GetTokenKey(c *gin.Context) {
 	})
 }
 
+// GetTokenCallbackSecret 返回令牌的任务回调签名密钥，尚未生成时生成一个
+func GetTokenCallbackSecret(c *gin.Context) {
+	token, ok := loadOwnedToken(c)
+	if !ok {
+		return
+	}
+	secret, err := model.EnsureTokenCallbackSecret(token)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"secret": secret})
+}
+
+// RotateTokenCallbackSecret 轮换令牌的任务回调签名密钥
+func RotateTokenCallbackSecret(c *gin.Context) {
+	token, ok := loadOwnedToken(c)
+	if !ok {
+		return
+	}
+	secret, err := model.RotateTokenCallbackSecret(token)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{"secret": secret})
+}
+
+func loadOwnedToken(c *gin.Context) (*model.Token, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return token, true
+}
+
 func GetTokenStatus(c *gin.Context) {
 	tokenId := c.GetInt("token_id")
 	userId := c.GetInt("id")
@@ -285,6 +341,9 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if !validateTokenCallbackUrl(c, &token) {
+		return
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -328,12 +387,20 @@ func AddToken(c *gin.Context) {
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
 		AutoGroups:         token.AutoGroups,
+		CallbackUrl:        token.CallbackUrl,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	eventbus.Publish(cleanToken.UserId, eventbus.TokenCreatedPayload{
+		TokenId:        cleanToken.Id,
+		Name:           cleanToken.Name,
+		RemainQuota:    cleanToken.RemainQuota,
+		UnlimitedQuota: cleanToken.UnlimitedQuota,
+		ExpiredTime:    cleanToken.ExpiredTime,
+	})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -379,6 +446,9 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	if statusOnly == "" && !validateTokenCallbackUrl(c, &token) {
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -407,6 +477,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.CallbackUrl = token.CallbackUrl
 		if token.Group != "auto" {
 			cleanToken.CrossGroupRetry = false
 			_ = cleanToken.SetAutoGroups(nil)
@@ -473,3 +544,62 @@ func GetTokenKeysBatch(c *gin.Context) {
 	}
 	common.ApiSuccess(c, gin.H{"keys": keysMap})
 }
+
+// getManagedUser 读取路由参数 id 对应的用户并校验当前用户能否管理该用户
+func getManagedUser(c *gin.Context) (*model.User, bool) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	if !canManageTargetUser(c, user.Id, user.Role) {
+		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
+		return nil, false
+	}
+	return user, true
+}
+
+// GetUserTokensByAdmin 列出指定用户的令牌，密钥始终脱敏
+func GetUserTokensByAdmin(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	tokens, err := model.GetAllUserTokens(user.Id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	total, _ := model.CountUserTokens(user.Id)
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(buildMaskedTokenResponses(tokens))
+	common.ApiSuccess(c, pageInfo)
+}
+
+// DeleteUserTokenByAdmin 删除指定用户的令牌，用于吊销泄露的密钥
+func DeleteUserTokenByAdmin(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	tokenId, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteTokenById(tokenId, user.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, user.Id, "token.delete", map[string]interface{}{
+		"id":       tokenId,
+		"username": user.Username,
+	})
+	common.ApiSuccess(c, nil)
+}
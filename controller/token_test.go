@@ -578,3 +578,40 @@ func TestGetTokenKeyRequiresOwnershipAndReturnsFullKey(t *testing.T) {
 		t.Fatalf("unauthorized key response leaked raw token key: %s", unauthorizedRecorder.Body.String())
 	}
 }
+
+func TestTokenCallbackSecretIsSeparateFromKeyAndRotates(t *testing.T) {
+	db := setupTokenControllerTestDB(t)
+	token := seedToken(t, db, 1, "callback-token", "callback1234token5678")
+	path := "/api/token/" + strconv.Itoa(token.Id) + "/callback_secret"
+
+	fetch := func(handler gin.HandlerFunc, userId int) (string, bool) {
+		ctx, recorder := newAuthenticatedContext(t, http.MethodPost, path, nil, userId)
+		ctx.Params = gin.Params{{Key: "id", Value: strconv.Itoa(token.Id)}}
+		handler(ctx)
+		response := decodeAPIResponse(t, recorder)
+		var data struct {
+			Secret string `json:"secret"`
+		}
+		if response.Success {
+			if err := common.Unmarshal(response.Data, &data); err != nil {
+				t.Fatalf("failed to decode callback secret response: %v", err)
+			}
+		}
+		return data.Secret, response.Success
+	}
+
+	secret, ok := fetch(GetTokenCallbackSecret, 1)
+	if !ok || secret == "" || strings.Contains(secret, token.Key) {
+		t.Fatalf("expected a dedicated callback secret, got %q", secret)
+	}
+	if again, _ := fetch(GetTokenCallbackSecret, 1); again != secret {
+		t.Fatalf("expected the callback secret to be stable, got %q and %q", secret, again)
+	}
+	rotated, ok := fetch(RotateTokenCallbackSecret, 1)
+	if !ok || rotated == "" || rotated == secret {
+		t.Fatalf("expected a new callback secret after rotation, got %q", rotated)
+	}
+	if _, ok := fetch(GetTokenCallbackSecret, 2); ok {
+		t.Fatalf("expected another user to be refused the callback secret")
+	}
+}
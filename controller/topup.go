@@ -119,6 +119,14 @@ func GetTopUpInfo(c *gin.Context) {
 		"amount_options":          operation_setting.GetPaymentSetting().AmountOptions,
 		"discount":                operation_setting.GetPaymentSetting().AmountDiscount,
 		"topup_link":              common.TopUpLink,
+		// 用户的展示货币与各网关的结算货币，前端据此换算参考金额
+		"currency": service.ResolveUserCurrency(c.GetInt("id"), ""),
+		"provider_currencies": map[string]string{
+			model.PaymentProviderEpay:         operation_setting.GetProviderCurrency(model.PaymentProviderEpay),
+			model.PaymentProviderStripe:       operation_setting.GetProviderCurrency(model.PaymentProviderStripe),
+			model.PaymentProviderWaffo:        getWaffoCurrency(),
+			model.PaymentProviderWaffoPancake: operation_setting.GetProviderCurrency(model.PaymentProviderWaffoPancake),
+		},
 	}
 	common.ApiSuccess(c, data)
 }
@@ -129,7 +137,8 @@ type EpayRequest struct {
 }
 
 type AmountRequest struct {
-	Amount int64 `json:"amount"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"` // 参考金额的展示货币，为空时使用用户设置
 }
 
 func GetEpayClient() *epay.Client {
@@ -245,6 +254,7 @@ func RequestEpay(c *gin.Context) {
 		dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		amount = dAmount.Div(dQuotaPerUnit).IntPart()
 	}
+	currency, exchangeRate := paymentCurrencySnapshot(operation_setting.GetProviderCurrency(model.PaymentProviderEpay))
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          amount,
@@ -254,6 +264,8 @@ func RequestEpay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderEpay,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -405,6 +417,7 @@ func EpayNotify(c *gin.Context) {
 			}
 			logger.LogInfo(c.Request.Context(), fmt.Sprintf("易支付 充值成功 trade_no=%s user_id=%d client_ip=%s quota_to_add=%d money=%.2f topup=%q", topUp.TradeNo, topUp.UserId, c.ClientIP(), quotaToAdd, topUp.Money, common.GetJsonString(topUp)))
 			model.RecordTopupLog(topUp.UserId, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money), c.ClientIP(), topUp.PaymentMethod, "epay")
+			model.PublishTopUpCompleted(topUp, quotaToAdd)
 		}
 	} else {
 		logger.LogInfo(c.Request.Context(), fmt.Sprintf("易支付 webhook 忽略事件 trade_no=%s callback_type=%s trade_status=%s client_ip=%s verify_info=%q", verifyInfo.ServiceTradeNo, verifyInfo.Type, verifyInfo.TradeStatus, c.ClientIP(), common.GetJsonString(verifyInfo)))
@@ -434,7 +447,7 @@ func RequestAmount(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	c.JSON(http.StatusOK, payMoneyResponse(id, req.Currency, payMoney, operation_setting.GetProviderCurrency(model.PaymentProviderEpay)))
 }
 
 func GetUserTopUps(c *gin.Context) {
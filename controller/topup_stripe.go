@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
@@ -37,6 +39,9 @@ type StripePayRequest struct {
 	// CancelURL is the optional custom URL to redirect when payment is canceled.
 	// If empty, defaults to the server's console topup page.
 	CancelURL string `json:"cancel_url,omitempty"`
+	// Currency is the optional checkout currency. If empty, the user's saved
+	// currency is used, falling back to the currency of the configured Stripe price.
+	Currency string `json:"currency,omitempty"`
 }
 
 type StripeAdaptor struct {
@@ -53,12 +58,13 @@ func (*StripeAdaptor) RequestAmount(c *gin.Context, req *StripePayRequest) {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getStripePayMoney(float64(req.Amount), group)
+	currency := resolveStripeCurrency(id, req.Currency)
+	payMoney := getStripeQuote(req.Amount, group, currency)
 	if payMoney <= 0.01 {
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	c.JSON(http.StatusOK, payMoneyResponse(id, req.Currency, payMoney, currency))
 }
 
 func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
@@ -92,7 +98,11 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 	reference := fmt.Sprintf("new-api-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "ref_" + common.Sha1([]byte(reference))
 
-	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, req.SuccessURL, req.CancelURL)
+	currency, exchangeRate := paymentCurrencySnapshot(resolveStripeCurrency(id, req.Currency))
+	// 按单独设置的单价结算时，试算、Stripe 扣款与订单快照使用同一个金额
+	chargeAmount, _ := stripeCurrencyCharge(req.Amount, user.Group, currency)
+	currencyMoney := stripeMajorUnitAmount(chargeAmount, currency)
+	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, currency, chargeAmount, req.SuccessURL, req.CancelURL)
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Stripe 创建 Checkout Session 失败 user_id=%d trade_no=%s amount=%d error=%q", id, referenceId, req.Amount, err.Error()))
 		c.JSON(http.StatusOK, gin.H{"message": "error", "data": "拉起支付失败"})
@@ -108,6 +118,9 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		PaymentProvider: model.PaymentProviderStripe,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
+		CurrencyMoney:   currencyMoney,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -334,11 +347,15 @@ func sessionExpired(ctx context.Context, event stripe.Event) {
 //   - customerId: existing Stripe customer ID (empty string if new customer)
 //   - email: customer email address for new customer creation
 //   - amount: quantity of units to purchase
+//   - currency: checkout currency
+//   - chargeAmount: total in the smallest unit of currency, from
+//     stripeCurrencyCharge; when positive it is charged through inline price
+//     data, otherwise the configured Stripe price is charged amount times
 //   - successURL: custom URL to redirect after successful payment (empty for default)
 //   - cancelURL: custom URL to redirect when payment is canceled (empty for default)
 //
 // Returns the checkout session URL or an error if the session creation fails.
-func genStripeLink(referenceId string, customerId string, email string, amount int64, successURL string, cancelURL string) (string, error) {
+func genStripeLink(referenceId string, customerId string, email string, amount int64, currency string, chargeAmount int64, successURL string, cancelURL string) (string, error) {
 	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
 		return "", fmt.Errorf("无效的Stripe API密钥")
 	}
@@ -353,16 +370,13 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 		cancelURL = paymentReturnPath("/wallet")
 	}
 
+	lineItem := stripeTopUpLineItem(amount, currency, chargeAmount)
+
 	params := &stripe.CheckoutSessionParams{
-		ClientReferenceID: stripe.String(referenceId),
-		SuccessURL:        stripe.String(successURL),
-		CancelURL:         stripe.String(cancelURL),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				Price:    stripe.String(setting.StripePriceId),
-				Quantity: stripe.Int64(amount),
-			},
-		},
+		ClientReferenceID:   stripe.String(referenceId),
+		SuccessURL:          stripe.String(successURL),
+		CancelURL:           stripe.String(cancelURL),
+		LineItems:           []*stripe.CheckoutSessionLineItemParams{lineItem},
 		Mode:                stripe.String(string(stripe.CheckoutSessionModePayment)),
 		AllowPromotionCodes: stripe.Bool(setting.StripePromotionCodesEnabled),
 	}
@@ -395,6 +409,12 @@ func GetChargedAmount(count float64, user model.User) float64 {
 }
 
 func getStripePayMoney(amount float64, group string) float64 {
+	return getStripePayMoneyAt(amount, group, setting.StripeUnitPrice)
+}
+
+// getStripePayMoneyAt prices a top-up at unitPrice per unit, applying the
+// top-up group ratio and the configured amount discount.
+func getStripePayMoneyAt(amount float64, group string, unitPrice float64) float64 {
 	originalAmount := amount
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
 		amount = amount / common.QuotaPerUnit
@@ -411,10 +431,96 @@ func getStripePayMoney(amount float64, group string) float64 {
 			discount = ds
 		}
 	}
-	payMoney := amount * setting.StripeUnitPrice * topupGroupRatio * discount
+	payMoney := amount * unitPrice * topupGroupRatio * discount
 	return payMoney
 }
 
+// stripeZeroDecimalCurrencies are charged in whole units rather than cents.
+var stripeZeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// stripeMinorUnitAmount converts an amount to the smallest currency unit
+// Stripe charges in, rounded to a whole unit.
+func stripeMinorUnitAmount(money float64, currency string) int64 {
+	if !stripeZeroDecimalCurrencies[currency] {
+		money *= 100
+	}
+	return int64(math.Round(money))
+}
+
+// stripeMajorUnitAmount is the inverse of stripeMinorUnitAmount.
+func stripeMajorUnitAmount(amount int64, currency string) float64 {
+	if stripeZeroDecimalCurrencies[currency] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
+
+// stripeCurrencyCharge returns the total charged for a top-up in a currency
+// the admin set its own unit price for, in the smallest unit of currency. The
+// top-up group ratio and amount discount apply exactly as in the default
+// currency. It reports false for the currency of the configured Stripe price,
+// which is charged through the Price ID instead.
+func stripeCurrencyCharge(amount int64, group string, currency string) (int64, bool) {
+	if currency == operation_setting.GetProviderCurrency(model.PaymentProviderStripe) {
+		return 0, false
+	}
+	unitPrice, ok := setting.GetStripeCurrencyUnitPrice(currency)
+	if !ok {
+		return 0, false
+	}
+	return stripeMinorUnitAmount(getStripePayMoneyAt(float64(amount), group, unitPrice), currency), true
+}
+
+// getStripeQuote returns the top-up price shown before checkout. Currencies
+// with their own unit price are quoted at exactly the amount Stripe charges,
+// never converted through exchange rates.
+func getStripeQuote(amount int64, group string, currency string) float64 {
+	if chargeAmount, ok := stripeCurrencyCharge(amount, group, currency); ok {
+		return stripeMajorUnitAmount(chargeAmount, currency)
+	}
+	return getStripePayMoney(float64(amount), group)
+}
+
+// stripeTopUpLineItem builds the checkout line item: the configured Stripe
+// price times amount, or a single inline-priced item of chargeAmount.
+func stripeTopUpLineItem(amount int64, currency string, chargeAmount int64) *stripe.CheckoutSessionLineItemParams {
+	if chargeAmount <= 0 {
+		return &stripe.CheckoutSessionLineItemParams{
+			Price:    stripe.String(setting.StripePriceId),
+			Quantity: stripe.Int64(amount),
+		}
+	}
+	return &stripe.CheckoutSessionLineItemParams{
+		PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+			Currency: stripe.String(strings.ToLower(currency)),
+			ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+				Name: stripe.String(fmt.Sprintf("Top-up credits x %d", amount)),
+			},
+			UnitAmount: stripe.Int64(chargeAmount),
+		},
+		Quantity: stripe.Int64(1),
+	}
+}
+
+// resolveStripeCurrency returns the checkout currency: the currency the user
+// asked for or saved, when the admin set a Stripe unit price for it,
+// otherwise the currency of the configured Stripe price. Exchange rates never
+// decide what a real charge costs.
+func resolveStripeCurrency(userId int, requested string) string {
+	stripeCurrency := operation_setting.GetProviderCurrency(model.PaymentProviderStripe)
+	currency, ok := service.GetUserPreferredCurrency(userId, requested)
+	if !ok {
+		return stripeCurrency
+	}
+	if _, ok := setting.GetStripeCurrencyUnitPrice(currency.Code); !ok {
+		return stripeCurrency
+	}
+	return currency.Code
+}
+
 func getStripeMinTopup() int64 {
 	minTopup := setting.StripeMinTopUp
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
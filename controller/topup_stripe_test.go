@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveStripeCurrencyRequiresConfiguredUnitPrice(t *testing.T) {
+	original := setting.StripeCurrencyUnitPrices
+	t.Cleanup(func() { setting.StripeCurrencyUnitPrices = original })
+
+	// Exchange rates alone never move a charge off the Stripe price
+	setting.StripeCurrencyUnitPrices = "{}"
+	assert.Equal(t, "USD", resolveStripeCurrency(0, "CNY"))
+
+	setting.StripeCurrencyUnitPrices = `{"cny": 58}`
+	assert.Equal(t, "CNY", resolveStripeCurrency(0, "CNY"))
+	unitPrice, ok := setting.GetStripeCurrencyUnitPrice("CNY")
+	assert.True(t, ok)
+	assert.Equal(t, 58.0, unitPrice)
+
+	assert.Error(t, setting.ValidateStripeCurrencyUnitPrices(`{"CNY": 0}`))
+	assert.Error(t, setting.ValidateStripeCurrencyUnitPrices(`{"yuan": 58}`))
+}
+
+func TestStripeCurrencyChargeMatchesQuoteAndLineItem(t *testing.T) {
+	originalUnitPrices, originalUnitPrice := setting.StripeCurrencyUnitPrices, setting.StripeUnitPrice
+	originalTopupRatio := common.TopupGroupRatio2JSONString()
+	t.Cleanup(func() {
+		setting.StripeCurrencyUnitPrices, setting.StripeUnitPrice = originalUnitPrices, originalUnitPrice
+		require.NoError(t, common.UpdateTopupGroupRatioByJSONString(originalTopupRatio))
+	})
+	setting.StripeUnitPrice = 1
+	setting.StripeCurrencyUnitPrices = `{"CNY": 7.2}`
+	require.NoError(t, common.UpdateTopupGroupRatioByJSONString(`{"default": 1, "vip": 1.5}`))
+
+	user := model.User{Group: "vip"}
+
+	// 10 units at 7.2 CNY with a 1.5 top-up group ratio
+	chargeAmount, ok := stripeCurrencyCharge(10, user.Group, "CNY")
+	require.True(t, ok)
+	assert.EqualValues(t, 10800, chargeAmount)
+	assert.Equal(t, 108.0, stripeMajorUnitAmount(chargeAmount, "CNY"))
+
+	lineItem := stripeTopUpLineItem(10, "CNY", chargeAmount)
+	assert.Nil(t, lineItem.Price)
+	assert.Equal(t, "cny", *lineItem.PriceData.Currency)
+	assert.EqualValues(t, 10800, *lineItem.PriceData.UnitAmount)
+	assert.EqualValues(t, 1, *lineItem.Quantity)
+
+	assert.Equal(t, 108.0, getStripeQuote(10, user.Group, "CNY"), "the quote is the amount Stripe charges")
+
+	// The configured Stripe price currency keeps charging through the Price ID
+	_, ok = stripeCurrencyCharge(10, user.Group, "USD")
+	assert.False(t, ok)
+	lineItem = stripeTopUpLineItem(10, "USD", 0)
+	assert.Nil(t, lineItem.PriceData)
+	assert.EqualValues(t, 10, *lineItem.Quantity)
+	assert.Equal(t, 15.0, getStripeQuote(10, user.Group, "USD"))
+}
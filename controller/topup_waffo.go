@@ -106,9 +106,10 @@ func getWaffoPayMoney(amount float64, group string) float64 {
 
 type WaffoPayRequest struct {
 	Amount         int64  `json:"amount"`
-	PayMethodIndex *int   `json:"pay_method_index"` // 服务端支付方式列表的索引，nil 表示由 Waffo 自动选择
-	PayMethodType  string `json:"pay_method_type"`  // Deprecated: 兼容旧前端，优先使用 pay_method_index
-	PayMethodName  string `json:"pay_method_name"`  // Deprecated: 兼容旧前端，优先使用 pay_method_index
+	PayMethodIndex *int   `json:"pay_method_index"`   // 服务端支付方式列表的索引，nil 表示由 Waffo 自动选择
+	PayMethodType  string `json:"pay_method_type"`    // Deprecated: 兼容旧前端，优先使用 pay_method_index
+	PayMethodName  string `json:"pay_method_name"`    // Deprecated: 兼容旧前端，优先使用 pay_method_index
+	Currency       string `json:"currency,omitempty"` // 参考金额的展示货币，为空时使用用户设置
 }
 
 func RequestWaffoAmount(c *gin.Context) {
@@ -137,7 +138,7 @@ func RequestWaffoAmount(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	c.JSON(http.StatusOK, payMoneyResponse(id, req.Currency, payMoney, getWaffoCurrency()))
 }
 
 // RequestWaffoPay 创建 Waffo 支付订单
@@ -218,6 +219,7 @@ func RequestWaffoPay(c *gin.Context) {
 	}
 
 	// 创建本地订单
+	currency, exchangeRate := paymentCurrencySnapshot(getWaffoCurrency())
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          amount,
@@ -227,6 +229,8 @@ func RequestWaffoPay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderWaffo,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	if err := topUp.Insert(); err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Waffo 创建充值订单失败 user_id=%d trade_no=%s amount=%d error=%q", id, merchantOrderId, req.Amount, err.Error()))
@@ -253,7 +257,6 @@ func RequestWaffoPay(c *gin.Context) {
 		returnUrl = setting.WaffoReturnUrl
 	}
 
-	currency := getWaffoCurrency()
 	goodsInfo := buildWaffoTopUpGoodsInfo(req.Amount)
 	createParams := &order.CreateOrderParams{
 		PaymentRequestID: paymentRequestId,
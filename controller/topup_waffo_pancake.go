@@ -19,7 +19,8 @@ import (
 )
 
 type WaffoPancakePayRequest struct {
-	Amount int64 `json:"amount"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"` // 参考金额的展示货币，为空时使用用户设置
 }
 
 func RequestWaffoPancakeAmount(c *gin.Context) {
@@ -47,7 +48,7 @@ func RequestWaffoPancakeAmount(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "success", "data": fmt.Sprintf("%.2f", payMoney)})
+	c.JSON(http.StatusOK, payMoneyResponse(id, req.Currency, payMoney, operation_setting.GetProviderCurrency(model.PaymentProviderWaffoPancake)))
 }
 
 func getWaffoPancakePayMoney(amount int64, group string) float64 {
@@ -372,6 +373,7 @@ func RequestWaffoPancakePay(c *gin.Context) {
 	}
 
 	tradeNo := fmt.Sprintf("WAFFO_PANCAKE-%d-%d-%s", id, time.Now().UnixMilli(), randstr.String(6))
+	currency, exchangeRate := paymentCurrencySnapshot(operation_setting.GetProviderCurrency(model.PaymentProviderWaffoPancake))
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          normalizeWaffoPancakeTopUpAmount(req.Amount),
@@ -381,6 +383,8 @@ func RequestWaffoPancakePay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderWaffoPancake,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	if err := topUp.Insert(); err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Waffo Pancake 创建充值订单失败 user_id=%d trade_no=%s amount=%d error=%q", id, tradeNo, req.Amount, err.Error()))
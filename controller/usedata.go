@@ -91,7 +91,12 @@ func GetAllFlowQuotaDates(c *gin.Context) {
 		return
 	}
 	username := c.Query("username")
-	dates, err := model.GetFlowQuotaData(startTimestamp, endTimestamp, username, 0, c.GetInt("role"))
+	// 路由已校验日志读取权限，通过自定义角色访问的普通用户按管理员视角查询
+	role := c.GetInt("role")
+	if role < common.RoleAdminUser {
+		role = common.RoleAdminUser
+	}
+	dates, err := model.GetFlowQuotaData(startTimestamp, endTimestamp, username, 0, role)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -72,6 +72,12 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	completeCredentialLogin(c, &user)
+}
+
+// completeCredentialLogin 在用户名密码类凭据校验通过后完成登录：
+// 启用了 2FA 的用户先签发 2FA 流程令牌，否则直接建立会话。密码登录与 LDAP 登录共用。
+func completeCredentialLogin(c *gin.Context, user *model.User) {
 	// 检查是否启用2FA
 	twoFAEnabled, err := model.IsTwoFAEnabled(user.Id)
 	if err != nil {
@@ -109,7 +115,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	setupLogin(&user, c)
+	setupLogin(user, c)
 }
 
 // loginMethodFromContext 根据请求路径推导登录方式，用于登录审计日志。
@@ -117,6 +123,8 @@ func loginMethodFromContext(c *gin.Context) string {
 	switch c.FullPath() {
 	case "/api/user/login":
 		return "password"
+	case "/api/user/login/ldap":
+		return "ldap"
 	case "/api/user/login/2fa":
 		return "2fa"
 	case "/api/user/passkey/login/finish":
@@ -372,6 +380,65 @@ func canManageTargetRole(myRole int, targetRole int) bool {
 	return myRole == common.RoleRootUser || myRole > targetRole
 }
 
+// canManageTargetUser 在 canManageTargetRole 的基础上允许通过自定义角色获得
+// 用户管理权限的普通用户管理其他普通用户，但不能管理自己，
+// 也不能管理拥有自己所没有的权限的用户
+func canManageTargetUser(c *gin.Context, targetId int, targetRole int) bool {
+	myRole := c.GetInt("role")
+	if myRole >= common.RoleAdminUser {
+		return canManageTargetRole(myRole, targetRole)
+	}
+	myId := c.GetInt("id")
+	return targetRole < common.RoleAdminUser && targetId != myId &&
+		authz.CoversCapabilities(myId, myRole, targetId, targetRole)
+}
+
+// requireUserPermission 校验当前用户的权限，不满足时写入错误响应
+func requireUserPermission(c *gin.Context, permission authz.Permission) bool {
+	if middleware.CanAccess(c, permission) {
+		return true
+	}
+	common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+	return false
+}
+
+// reserveQuotaAdjustment 把本次额度调整计入当前用户当日的调整总量，超过角色上限时拒绝。
+// 返回的 release 用于在调整最终没有执行时撤销计数。
+func reserveQuotaAdjustment(c *gin.Context, delta int) (release func(), ok bool) {
+	release = func() {}
+	userId := c.GetInt("id")
+	limit, ok := authz.QuotaAdjustLimit(userId, c.GetInt("role"))
+	if !ok || !middleware.CanAccess(c, authz.UserQuota) {
+		common.ApiErrorI18n(c, i18n.MsgAuthInsufficientPrivilege)
+		return release, false
+	}
+	if limit == 0 {
+		return release, true
+	}
+	if delta < 0 {
+		delta = -delta
+	}
+	day := model.QuotaAdjustDay(time.Now())
+	reserved, err := model.ReserveQuotaAdjustment(userId, day, delta, limit)
+	if err != nil {
+		common.ApiError(c, err)
+		return release, false
+	}
+	if !reserved {
+		used, _ := model.GetQuotaAdjustUsage(userId, day)
+		common.ApiErrorI18n(c, i18n.MsgUserQuotaChangeExceedsLimit, map[string]any{
+			"Max":       logger.LogQuota(limit),
+			"Remaining": logger.LogQuota(max(limit-int(used), 0)),
+		})
+		return release, false
+	}
+	return func() {
+		if err := model.ReleaseQuotaAdjustment(userId, day, delta); err != nil {
+			common.SysError("failed to release quota adjustment: " + err.Error())
+		}
+	}, true
+}
+
 func GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -383,8 +450,7 @@ func GetUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, user.Role) {
+	if !canManageTargetUser(c, user.Id, user.Role) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
 		return
 	}
@@ -691,8 +757,7 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	updatedUser.Role = originUser.Role
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, originUser.Role) {
+	if !canManageTargetUser(c, originUser.Id, originUser.Role) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
@@ -758,8 +823,7 @@ func AdminClearUserBinding(c *gin.Context) {
 		return
 	}
 
-	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, user.Role) {
+	if !canManageTargetUser(c, user.Id, user.Role) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
 		return
 	}
@@ -839,6 +903,35 @@ func UpdateSelf(c *gin.Context) {
 		return
 	}
 
+	// 检查是否是货币偏好更新请求，空字符串表示使用站点默认货币
+	if currency, currencyExists := requestData["currency"]; currencyExists {
+		currencyStr, _ := currency.(string)
+		currencyStr = operation_setting.NormalizeCurrencyCode(currencyStr)
+		if currencyStr != "" {
+			if _, ok := operation_setting.GetCurrency(currencyStr); !ok {
+				common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+				return
+			}
+		}
+		userId := c.GetInt("id")
+		user, err := model.GetUserById(userId, false)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		currentSetting := user.GetSetting()
+		currentSetting.Currency = currencyStr
+
+		if err := model.UpdateUserSetting(user.Id, currentSetting); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgUpdateFailed)
+			return
+		}
+
+		common.ApiSuccessI18n(c, i18n.MsgUpdateSuccess, nil)
+		return
+	}
+
 	// 原有的用户信息更新逻辑
 	var user model.User
 	requestDataBytes, err := common.Marshal(requestData)
@@ -959,7 +1052,7 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if !canManageTargetUser(c, originUser.Id, originUser.Role) || (myRole >= common.RoleAdminUser && myRole <= originUser.Role) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
@@ -1016,7 +1109,8 @@ func CreateUser(c *gin.Context) {
 		user.DisplayName = user.Username
 	}
 	myRole := c.GetInt("role")
-	if user.Role >= myRole {
+	// 通过自定义角色获得管理权限的普通用户只能创建普通用户
+	if user.Role >= myRole && (myRole >= common.RoleAdminUser || user.Role > common.RoleCommonUser) {
 		common.ApiErrorI18n(c, i18n.MsgUserCannotCreateHigherLevel)
 		return
 	}
@@ -1061,7 +1155,7 @@ func CreateUser(c *gin.Context) {
 func updateAdminPermissionsForUserInTx(c *gin.Context, tx *gorm.DB, userID int, userRole int, permissions map[string]map[string]bool) (bool, error) {
 	if permissions == nil {
 		if userRole < common.RoleAdminUser && c.GetInt("role") == common.RoleRootUser {
-			return true, authz.ClearUserAuthorizationInTx(tx, userID)
+			return true, authz.ClearUserPermissionsInTx(tx, userID)
 		}
 		return false, nil
 	}
@@ -1069,7 +1163,7 @@ func updateAdminPermissionsForUserInTx(c *gin.Context, tx *gorm.DB, userID int,
 		return false, fmt.Errorf("only root can update admin permissions")
 	}
 	if userRole < common.RoleAdminUser {
-		return true, authz.ClearUserAuthorizationInTx(tx, userID)
+		return true, authz.ClearUserPermissionsInTx(tx, userID)
 	}
 	return true, authz.SetUserPermissionsInTx(tx, userID, permissions)
 }
@@ -1081,7 +1175,7 @@ type ManageRequest struct {
 	Mode   string `json:"mode"`
 }
 
-// ManageUser Only admin user can do this
+// ManageUser 按 action 校验对应权限，额度调整受角色额度上限约束
 func ManageUser(c *gin.Context) {
 	var req ManageRequest
 	err := common.DecodeJson(c.Request.Body, &req)
@@ -1100,10 +1194,37 @@ func ManageUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if !canManageTargetRole(myRole, user.Role) {
+	if !canManageTargetUser(c, user.Id, user.Role) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	// 额度调整没有成功执行时撤销已计入的当日调整量
+	quotaAdjusted := false
+	releaseQuotaAdjustment := func() {}
+	defer func() {
+		if !quotaAdjusted {
+			releaseQuotaAdjustment()
+		}
+	}()
+	switch req.Action {
+	case "disable", "enable":
+		if !requireUserPermission(c, authz.UserWrite) {
+			return
+		}
+	case "delete", "promote", "demote":
+		if !requireUserPermission(c, authz.UserManage) {
+			return
+		}
+	case "add_quota":
+		delta := req.Value
+		if req.Mode == "override" {
+			delta = req.Value - user.Quota
+		}
+		var ok bool
+		if releaseQuotaAdjustment, ok = reserveQuotaAdjustment(c, delta); !ok {
+			return
+		}
+	}
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -1171,6 +1292,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			quotaAdjusted = true
 			recordManageAuditFor(c, user.Id, "user.quota_add", map[string]interface{}{
 				"quota": logger.LogQuota(req.Value),
 			})
@@ -1183,6 +1305,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			quotaAdjusted = true
 			recordManageAuditFor(c, user.Id, "user.quota_subtract", map[string]interface{}{
 				"quota": logger.LogQuota(req.Value),
 			})
@@ -1192,6 +1315,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiError(c, err)
 				return
 			}
+			quotaAdjusted = true
 			recordManageAuditFor(c, user.Id, "user.quota_override", map[string]interface{}{
 				"from": logger.LogQuota(oldQuota),
 				"to":   logger.LogQuota(req.Value),
@@ -1395,6 +1519,12 @@ type UpdateUserSettingRequest struct {
 	GotifyUrl                        string  `json:"gotify_url,omitempty"`
 	GotifyToken                      string  `json:"gotify_token,omitempty"`
 	GotifyPriority                   int     `json:"gotify_priority,omitempty"`
+	SlackWebhookUrl                  string  `json:"slack_webhook_url,omitempty"`
+	FeishuWebhookUrl                 string  `json:"feishu_webhook_url,omitempty"`
+	FeishuSecret                     string  `json:"feishu_secret,omitempty"`
+	DingTalkWebhookUrl               string  `json:"dingtalk_webhook_url,omitempty"`
+	DingTalkSecret                   string  `json:"dingtalk_secret,omitempty"`
+	WeComWebhookUrl                  string  `json:"wecom_webhook_url,omitempty"`
 	UpstreamModelUpdateNotifyEnabled *bool   `json:"upstream_model_update_notify_enabled,omitempty"`
 	AcceptUnsetModelRatioModel       bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                      bool    `json:"record_ip_log"`
@@ -1408,7 +1538,10 @@ func UpdateUserSetting(c *gin.Context) {
 	}
 
 	// 验证预警类型
-	if req.QuotaWarningType != dto.NotifyTypeEmail && req.QuotaWarningType != dto.NotifyTypeWebhook && req.QuotaWarningType != dto.NotifyTypeBark && req.QuotaWarningType != dto.NotifyTypeGotify {
+	switch req.QuotaWarningType {
+	case dto.NotifyTypeEmail, dto.NotifyTypeWebhook, dto.NotifyTypeBark, dto.NotifyTypeGotify,
+		dto.NotifyTypeSlack, dto.NotifyTypeFeishu, dto.NotifyTypeDingTalk, dto.NotifyTypeWeCom, dto.NotifyTypeTelegram:
+	default:
 		common.ApiErrorI18n(c, i18n.MsgSettingInvalidType)
 		return
 	}
@@ -1481,12 +1614,31 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	// 如果是聊天机器人类型，验证机器人地址
+	if botWebhookUrl, ok := chatBotWebhookUrl(req); ok && !validateBotWebhookUrl(c, botWebhookUrl) {
+		return
+	}
+
 	userId := c.GetInt("id")
 	user, err := model.GetUserById(userId, true)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+
+	// 如果是Telegram类型，需要管理员已配置机器人，且用户已绑定Telegram；
+	// 通知只发送给绑定的账号，不接受任意会话ID，避免借机器人向他人发送消息
+	if req.QuotaWarningType == dto.NotifyTypeTelegram {
+		if common.TelegramBotToken == "" {
+			common.ApiErrorI18n(c, i18n.MsgSettingTelegramMissing)
+			return
+		}
+		if user.TelegramId == "" {
+			common.ApiErrorI18n(c, i18n.MsgSettingTelegramNoChat)
+			return
+		}
+	}
+
 	existingSettings := user.GetSetting()
 	upstreamModelUpdateNotifyEnabled := existingSettings.UpstreamModelUpdateNotifyEnabled
 	if user.Role >= common.RoleAdminUser && req.UpstreamModelUpdateNotifyEnabled != nil {
@@ -1500,6 +1652,7 @@ func UpdateUserSetting(c *gin.Context) {
 		UpstreamModelUpdateNotifyEnabled: upstreamModelUpdateNotifyEnabled,
 		AcceptUnsetRatioModel:            req.AcceptUnsetModelRatioModel,
 		RecordIpLog:                      req.RecordIpLog,
+		Currency:                         existingSettings.Currency,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
@@ -1532,6 +1685,20 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	// 聊天机器人类型只保存所选平台的配置
+	switch req.QuotaWarningType {
+	case dto.NotifyTypeSlack:
+		settings.SlackWebhookUrl = req.SlackWebhookUrl
+	case dto.NotifyTypeFeishu:
+		settings.FeishuWebhookUrl = req.FeishuWebhookUrl
+		settings.FeishuSecret = req.FeishuSecret
+	case dto.NotifyTypeDingTalk:
+		settings.DingTalkWebhookUrl = req.DingTalkWebhookUrl
+		settings.DingTalkSecret = req.DingTalkSecret
+	case dto.NotifyTypeWeCom:
+		settings.WeComWebhookUrl = req.WeComWebhookUrl
+	}
+
 	// 更新用户设置
 	if err := model.UpdateUserSetting(user.Id, settings); err != nil {
 		common.ApiErrorI18n(c, i18n.MsgUpdateFailed)
@@ -1540,3 +1707,35 @@ func UpdateUserSetting(c *gin.Context) {
 
 	common.ApiSuccessI18n(c, i18n.MsgSettingSaved, nil)
 }
+
+// chatBotWebhookUrl 返回所选聊天机器人类型对应的地址，非机器人类型返回 false
+func chatBotWebhookUrl(req UpdateUserSettingRequest) (string, bool) {
+	switch req.QuotaWarningType {
+	case dto.NotifyTypeSlack:
+		return req.SlackWebhookUrl, true
+	case dto.NotifyTypeFeishu:
+		return req.FeishuWebhookUrl, true
+	case dto.NotifyTypeDingTalk:
+		return req.DingTalkWebhookUrl, true
+	case dto.NotifyTypeWeCom:
+		return req.WeComWebhookUrl, true
+	}
+	return "", false
+}
+
+// validateBotWebhookUrl 验证机器人地址，失败时已写入错误响应
+func validateBotWebhookUrl(c *gin.Context, botWebhookUrl string) bool {
+	if botWebhookUrl == "" {
+		common.ApiErrorI18n(c, i18n.MsgSettingBotWebhookEmpty)
+		return false
+	}
+	if _, err := url.ParseRequestURI(botWebhookUrl); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgSettingBotWebhookBad)
+		return false
+	}
+	if !strings.HasPrefix(botWebhookUrl, "https://") && !strings.HasPrefix(botWebhookUrl, "http://") {
+		common.ApiErrorI18n(c, i18n.MsgSettingUrlMustHttp)
+		return false
+	}
+	return true
+}
@@ -3,10 +3,13 @@ package controller
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/system_setting"
 
@@ -55,6 +59,18 @@ func VideoProxy(c *gin.Context) {
 		return
 	}
 
+	// 结果已转存到本站存储时直接返回，转存被清理后回退到上游地址
+	if task.PrivateData.ArtifactId > 0 {
+		artifact, body, err := service.OpenTaskArtifact(c.Request.Context(), task.TaskID)
+		if err == nil {
+			writeTaskArtifact(c, artifact, body, "private, max-age=86400")
+			return
+		}
+		if !errors.Is(err, objectstore.ErrNotFound) {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to open artifact of task %s: %s", taskID, err.Error()))
+		}
+	}
+
 	channel, err := model.CacheGetChannel(task.ChannelId)
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to get channel for task %s: %s", taskID, err.Error()))
@@ -116,7 +132,7 @@ func VideoProxy(c *gin.Context) {
 		req.Header.Set("Authorization", "Bearer "+channel.Key)
 	default:
 		// Video URL is stored in PrivateData.ResultURL (fallback to FailReason for old data)
-		videoURL = task.GetResultURL()
+		videoURL = task.GetUpstreamResultURL()
 	}
 
 	videoURL = strings.TrimSpace(videoURL)
@@ -182,6 +198,57 @@ func VideoProxy(c *gin.Context) {
 	}
 }
 
+// VideoArtifact 通过签名地址返回转存到本站存储的任务结果，无需令牌
+func VideoArtifact(c *gin.Context) {
+	taskID := c.Param("task_id")
+	expires := c.Query("expires")
+	if err := model.VerifyTaskArtifactSignature(taskID, expires, c.Query("signature")); err != nil {
+		videoProxyError(c, http.StatusForbidden, "invalid_request_error", err.Error())
+		return
+	}
+
+	artifact, body, err := service.OpenTaskArtifact(c.Request.Context(), taskID)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrNotFound) {
+			videoProxyError(c, http.StatusNotFound, "invalid_request_error", "Artifact not found or expired")
+			return
+		}
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to open artifact of task %s: %s", taskID, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to open artifact")
+		return
+	}
+	// 缓存时间不超过签名地址的有效期
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	maxAge := max(expiresAt-time.Now().Unix(), 0)
+	writeTaskArtifact(c, artifact, body, fmt.Sprintf("private, max-age=%d", maxAge))
+}
+
+func writeTaskArtifact(c *gin.Context, artifact *model.TaskArtifact, body io.ReadCloser, cacheControl string) {
+	defer body.Close()
+	// 上游声明的类型不可信：只有视频和图片（不含可执行脚本的 SVG）按原类型内联展示，
+	// 其余一律作为附件下载，并禁止浏览器嗅探，避免在本站域名下渲染 HTML
+	contentType, disposition := taskArtifactContentType(artifact.ContentType)
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": artifact.TaskId}))
+	c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+	c.Writer.Header().Set("Content-Length", strconv.FormatInt(artifact.Size, 10))
+	c.Writer.Header().Set("Cache-Control", cacheControl)
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to stream artifact of task %s: %s", artifact.TaskId, err.Error()))
+	}
+}
+
+// taskArtifactContentType 返回转存结果的响应类型与 Content-Disposition 类型
+func taskArtifactContentType(contentType string) (string, string) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "image/svg+xml" ||
+		(!strings.HasPrefix(mediaType, "video/") && !strings.HasPrefix(mediaType, "image/")) {
+		return "application/octet-stream", "attachment"
+	}
+	return mediaType, "inline"
+}
+
 func writeVideoDataURL(c *gin.Context, dataURL string) error {
 	parts := strings.SplitN(dataURL, ",", 2)
 	if len(parts) != 2 {
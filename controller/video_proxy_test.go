@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTaskArtifactOnlyInlinesMedia(t *testing.T) {
+	tests := []struct {
+		contentType     string
+		wantType        string
+		wantDisposition string
+	}{
+		{contentType: "video/mp4", wantType: "video/mp4", wantDisposition: `inline; filename=task_1`},
+		{contentType: "image/png; charset=binary", wantType: "image/png", wantDisposition: `inline; filename=task_1`},
+		{contentType: "image/svg+xml", wantType: "application/octet-stream", wantDisposition: `attachment; filename=task_1`},
+		{contentType: "text/html; charset=utf-8", wantType: "application/octet-stream", wantDisposition: `attachment; filename=task_1`},
+		{contentType: "", wantType: "application/octet-stream", wantDisposition: `attachment; filename=task_1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/videos/task_1/artifact", nil)
+			artifact := &model.TaskArtifact{TaskId: "task_1", ContentType: tt.contentType, Size: 4}
+			writeTaskArtifact(c, artifact, io.NopCloser(strings.NewReader("data")), "private, max-age=60")
+
+			assert.Equal(t, tt.wantType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantDisposition, recorder.Header().Get("Content-Disposition"))
+			assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "data", recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/wasmplugin"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type wasmPluginRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     *bool  `json:"enabled"`
+	FailOpen    bool   `json:"fail_open"`
+}
+
+type wasmPluginTestRequest struct {
+	Version  int             `json:"version"` // 为 0 时测试生效版本
+	Hook     string          `json:"hook"`
+	Payload  json.RawMessage `json:"payload"`
+	Metadata map[string]any  `json:"metadata"`
+}
+
+// reloadWasmPlugins 插件变更后立即重载本节点，其他节点由定时同步生效
+func reloadWasmPlugins() {
+	if err := service.ReloadWasmPlugins(); err != nil {
+		common.SysError("failed to reload wasm plugins: " + err.Error())
+	}
+}
+
+func GetWasmPlugins(c *gin.Context) {
+	plugins, err := model.GetWasmPlugins()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"plugins": plugins,
+		"hooks":   wasmplugin.Hooks,
+	})
+}
+
+func CreateWasmPlugin(c *gin.Context) {
+	var req wasmPluginRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	plugin := &model.WasmPlugin{
+		Name:        req.Name,
+		Description: req.Description,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		FailOpen:    req.FailOpen,
+	}
+	if err := service.ValidateWasmPlugin(plugin); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plugin.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plugin)
+}
+
+func UpdateWasmPlugin(c *gin.Context) {
+	plugin, ok := loadWasmPlugin(c)
+	if !ok {
+		return
+	}
+	var req wasmPluginRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	plugin.Name = req.Name
+	plugin.Description = req.Description
+	plugin.FailOpen = req.FailOpen
+	if req.Enabled != nil {
+		plugin.Enabled = *req.Enabled
+	}
+	if err := service.ValidateWasmPlugin(plugin); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plugin.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	reloadWasmPlugins()
+	common.ApiSuccess(c, plugin)
+}
+
+func DeleteWasmPlugin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteWasmPlugin(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWasmPluginNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	reloadWasmPlugins()
+	common.ApiSuccess(c, nil)
+}
+
+func GetWasmPluginVersions(c *gin.Context) {
+	plugin, ok := loadWasmPlugin(c)
+	if !ok {
+		return
+	}
+	versions, err := model.GetWasmPluginVersions(plugin.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, versions)
+}
+
+// UploadWasmPluginVersion 以 multipart 表单上传模块（字段 file），校验通过后保存为新版本，
+// activate 默认为 true，传 false 时只保存不生效，便于先测试再发布
+func UploadWasmPluginVersion(c *gin.Context) {
+	plugin, ok := loadWasmPlugin(c)
+	if !ok {
+		return
+	}
+	maxSize := system_setting.GetWasmPluginSettings().MaxBinarySize()
+	// 为表单其余字段预留少量空间
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+64<<10)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			common.ApiErrorI18n(c, i18n.MsgWasmPluginFileTooLarge, map[string]any{"Max": maxSize >> 20})
+			return
+		}
+		common.ApiErrorI18n(c, i18n.MsgWasmPluginFileRequired)
+		return
+	}
+	if fileHeader.Size > maxSize {
+		common.ApiErrorI18n(c, i18n.MsgWasmPluginFileTooLarge, map[string]any{"Max": maxSize >> 20})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	defer file.Close()
+	binary, err := io.ReadAll(file)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	activate := c.PostForm("activate") != "false"
+	version, err := service.CreateWasmPluginVersion(c.Request.Context(), plugin.Id, binary, c.PostForm("note"), activate)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if activate {
+		reloadWasmPlugins()
+	}
+	common.ApiSuccess(c, version)
+}
+
+// ActivateWasmPluginVersion 切换生效版本，用于发布未生效的版本或回滚
+func ActivateWasmPluginVersion(c *gin.Context) {
+	plugin, ok := loadWasmPlugin(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.ActivateWasmPluginVersion(plugin.Id, version); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWasmPluginVersionNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	reloadWasmPlugins()
+	common.ApiSuccess(c, nil)
+}
+
+// TestWasmPlugin 使用给定的载荷与元数据调用插件的某个钩子，不影响线上请求
+func TestWasmPlugin(c *gin.Context) {
+	plugin, ok := loadWasmPlugin(c)
+	if !ok {
+		return
+	}
+	var req wasmPluginTestRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil || !slices.Contains(wasmplugin.Hooks, req.Hook) {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	version := req.Version
+	if version == 0 {
+		version = plugin.ActiveVersion
+	}
+	pluginVersion, err := model.GetWasmPluginVersion(plugin.Id, version)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWasmPluginVersionNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	result, err := service.TestWasmPluginVersion(c.Request.Context(), pluginVersion, req.Hook, req.Payload, req.Metadata)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
+
+func loadWasmPlugin(c *gin.Context) (*model.WasmPlugin, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	plugin, err := model.GetWasmPluginById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgWasmPluginNotFound)
+			return nil, false
+		}
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return plugin, true
+}
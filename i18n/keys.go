@@ -38,6 +38,8 @@ const (
 	MsgAuthUserIdMismatch        = "auth.user_id_mismatch"
 	MsgAuthUserBanned            = "auth.user_banned"
 	MsgAuthInsufficientPrivilege = "auth.insufficient_privilege"
+	MsgAuthIpNotAllowed          = "auth.ip_not_allowed"
+	MsgAuthTokenScopeDenied      = "auth.token_scope_denied"
 )
 
 // Token related messages
@@ -58,6 +60,7 @@ const (
 	MsgTokenAutoGroupsTooMany    = "token.auto_groups_too_many"
 	MsgTokenAutoGroupsDuplicate  = "token.auto_groups_duplicate"
 	MsgTokenAutoGroupsInvalid    = "token.auto_groups_invalid"
+	MsgTokenCallbackUrlInvalid   = "token.callback_url_invalid"
 )
 
 // Redemption related messages
@@ -120,6 +123,7 @@ const (
 	MsgUserTelegramNotBound          = "user.telegram_not_bound"
 	MsgUserLinuxDOIdEmpty            = "user.linux_do_id_empty"
 	MsgUserQuotaChangeZero           = "user.quota_change_zero"
+	MsgUserQuotaChangeExceedsLimit   = "user.quota_change_exceeds_limit"
 )
 
 // Quota related messages
@@ -254,6 +258,10 @@ const (
 	MsgSettingGotifyUrlInvalid = "setting.gotify_url_invalid"
 	MsgSettingUrlMustHttp      = "setting.url_must_http"
 	MsgSettingSaved            = "setting.saved"
+	MsgSettingBotWebhookEmpty  = "setting.bot_webhook_empty"
+	MsgSettingBotWebhookBad    = "setting.bot_webhook_invalid"
+	MsgSettingTelegramMissing  = "setting.telegram_bot_missing"
+	MsgSettingTelegramNoChat   = "setting.telegram_chat_empty"
 )
 
 // Deployment related messages (io.net)
@@ -299,6 +307,17 @@ const (
 	MsgOAuthTokenFailed     = "oauth.token_failed"
 	MsgOAuthUserInfoEmpty   = "oauth.user_info_empty"
 	MsgOAuthTrustLevelLow   = "oauth.trust_level_low"
+	MsgOAuthSamlResponseErr = "oauth.saml_response_invalid"
+	MsgOAuthSamlIdpErr      = "oauth.saml_idp_unavailable"
+)
+
+// LDAP related messages
+const (
+	MsgLDAPNotEnabled           = "ldap.not_enabled"
+	MsgLDAPUnavailable          = "ldap.unavailable"
+	MsgLDAPGroupDenied          = "ldap.group_denied"
+	MsgLDAPRegistrationDisabled = "ldap.registration_disabled"
+	MsgLDAPEmailTaken           = "ldap.email_taken"
 )
 
 // Model layer error messages (for translation in controller)
@@ -336,3 +355,19 @@ const (
 	MsgCustomOAuthBindingNotFound   = "custom_oauth.binding_not_found"
 	MsgCustomOAuthProviderIdInvalid = "custom_oauth.provider_id_field_invalid"
 )
+
+// Event webhook related messages
+const (
+	MsgWebhookUserDisabled     = "webhook.user_disabled"
+	MsgWebhookLimitReached     = "webhook.limit_reached"
+	MsgWebhookNotFound         = "webhook.not_found"
+	MsgWebhookDeliveryNotFound = "webhook.delivery_not_found"
+)
+
+// WASM plugin related messages
+const (
+	MsgWasmPluginNotFound        = "wasm_plugin.not_found"
+	MsgWasmPluginVersionNotFound = "wasm_plugin.version_not_found"
+	MsgWasmPluginFileRequired    = "wasm_plugin.file_required"
+	MsgWasmPluginFileTooLarge    = "wasm_plugin.file_too_large"
+)
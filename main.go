@@ -5,6 +5,7 @@ import (
 	"context"
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/QuantumNous/new-api/cli"
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
@@ -52,6 +54,15 @@ func main() {
 	})
 	kitutil.SetSystemErrorLogging(common.SysError)
 
+	InitEnvironment()
+	if args := flag.Args(); cli.IsAdminCommand(args) {
+		if err := cli.RunAdmin(args[1:], os.Stdout, InitAdminResources); err != nil {
+			fmt.Fprintln(os.Stderr, "error: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	err := InitResources()
 	if err != nil {
 		common.FatalLog("failed to initialize resources: " + err.Error())
@@ -99,6 +110,7 @@ func main() {
 		}()
 
 		go model.SyncChannelCache(common.SyncFrequency)
+		go model.SubscribeChannelCacheInvalidation()
 	}
 
 	// Warm pricing after channel cache initialization so Advanced Custom
@@ -111,6 +123,9 @@ func main() {
 	// 周期性重载授权策略，保证多节点/多 master 部署下权限变更能传播到每个实例
 	go authz.StartPolicySync(common.SyncFrequency)
 
+	// 周期性重载 WASM 插件，同步其他节点上的插件变更与沙箱限制
+	go service.StartWasmPluginSync(common.SyncFrequency)
+
 	// 数据看板
 	go model.UpdateQuotaData()
 
@@ -151,6 +166,10 @@ func main() {
 	controller.RegisterScheduledSystemTasks()
 	service.StartSystemTaskRunner()
 
+	// Record webhook deliveries for events emitted on this node; the runner
+	// above sends them through the webhook_delivery task.
+	service.StartEventWebhookDispatcher()
+
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -281,9 +300,8 @@ func InjectGoogleAnalytics() {
 	indexPage = bytes.ReplaceAll(indexPage, placeholder, analyticsInject)
 }
 
-func InitResources() error {
-	// Initialize resources here if needed
-	// This is a placeholder function for future resource initialization
+// InitEnvironment loads .env and parses command line flags.
+func InitEnvironment() {
 	err := godotenv.Load(".env")
 	if err != nil {
 		if common.DebugEnabled {
@@ -293,7 +311,26 @@ func InitResources() error {
 
 	// 加载环境变量
 	common.InitEnv()
+}
+
+// InitAdminResources connects the databases and Redis for the admin
+// subcommands without starting any background job.
+func InitAdminResources() error {
+	ratio_setting.InitRatioSettings()
 
+	service.InitHttpClient()
+
+	if err := model.InitDB(); err != nil {
+		return err
+	}
+	model.InitOptionMap()
+	if err := model.InitLogDB(); err != nil {
+		return err
+	}
+	return common.InitRedisClient()
+}
+
+func InitResources() error {
 	logger.SetupLogger()
 
 	// Initialize model settings
@@ -304,7 +341,7 @@ func InitResources() error {
 	service.InitTokenEncoders()
 
 	// Initialize SQL Database
-	err = model.InitDB()
+	err := model.InitDB()
 	if err != nil {
 		common.FatalLog("failed to initialize database: " + err.Error())
 		return err
@@ -94,6 +94,20 @@ var auditRouteActions = map[string]string{
 
 	// 日志
 	"POST /api/system-task/log-cleanup": "log.cleanup_start",
+
+	// 系统 Webhook
+	"POST /api/webhook/system/subscriptions":            "webhook.create",
+	"PUT /api/webhook/system/subscriptions/:id":         "webhook.update",
+	"POST /api/webhook/system/subscriptions/:id/secret": "webhook.rotate_secret",
+	"DELETE /api/webhook/system/subscriptions/:id":      "webhook.delete",
+	"POST /api/webhook/system/deliveries/:id/redeliver": "webhook.redeliver",
+
+	// WASM 插件
+	"POST /api/wasm-plugin/":                               "wasm_plugin.create",
+	"PUT /api/wasm-plugin/:id":                             "wasm_plugin.update",
+	"DELETE /api/wasm-plugin/:id":                          "wasm_plugin.delete",
+	"POST /api/wasm-plugin/:id/versions":                   "wasm_plugin.upload_version",
+	"POST /api/wasm-plugin/:id/versions/:version/activate": "wasm_plugin.activate_version",
 }
 
 // beginAdminAudit 在管理/root 写操作进入 handler 前包装 ResponseWriter，
@@ -21,7 +21,14 @@ import (
 	"gorm.io/gorm"
 )
 
-const authIdentityContextKey = "auth_identity"
+const (
+	authIdentityContextKey = "auth_identity"
+
+	// personalAccessTokenScopesKey 仅在使用带权限范围的管理令牌时设置
+	personalAccessTokenScopesKey = "personal_access_token_scopes"
+)
+
+var errPersonalAccessTokenIpDenied = errors.New("client ip is not allowed for this personal access token")
 
 type dashboardCredentialKind int
 
@@ -43,6 +50,13 @@ func validUserInfo(username string, role int) bool {
 }
 
 func authHelper(c *gin.Context, minRole int) {
+	authenticateDashboard(c, minRole, false)
+}
+
+// authenticateDashboard 校验管理端凭证。permissionGated 表示路由由
+// RequirePermission 逐个授权：这类路由对写操作做审计兜底，并且是带权限范围的
+// 管理令牌唯一可以访问的路由。
+func authenticateDashboard(c *gin.Context, minRole int, permissionGated bool) {
 	user, identity, useAccessToken, err := authenticateDashboardRequest(c)
 	if err != nil {
 		writeDashboardAuthError(c, err)
@@ -61,12 +75,16 @@ func authHelper(c *gin.Context, minRole int) {
 		return
 	}
 	setDashboardAuthContext(c, user, identity, useAccessToken)
+	if _, scoped := c.Get(personalAccessTokenScopesKey); scoped && !permissionGated {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "code": "AUTH_TOKEN_SCOPE_DENIED", "message": common.TranslateMessage(c, i18n.MsgAuthTokenScopeDenied)})
+		return
+	}
 
 	// 管理/root 写操作审计兜底：内聚在鉴权链路里，保证任何经过 AdminAuth/RootAuth
 	// 的写接口都会自动留痕（无需在路由上单独挂审计中间件，避免漏挂）。
 	// handler 内手动埋点者会设置 ContextKeyAuditLogged，finishAdminAudit 据此跳过。
 	var auditWriter *auditResponseWriter
-	if minRole >= common.RoleAdminUser {
+	if permissionGated || minRole >= common.RoleAdminUser {
 		auditWriter = beginAdminAudit(c)
 	}
 
@@ -107,6 +125,14 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// StaffAuth 允许任意已登录用户进入管理接口，具体权限由路由上的 RequirePermission
+// 判定；与 AdminAuth 一样对写操作做审计兜底，覆盖通过自定义角色获得管理权限的用户。
+func StaffAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authenticateDashboard(c, common.RoleCommonUser, true)
+	}
+}
+
 // GetAuthIdentity returns a dashboard session identity. PAT-authenticated
 // requests intentionally have no SessionID and cannot manage browser sessions.
 func GetAuthIdentity(c *gin.Context) (service.AuthIdentity, bool) {
@@ -163,6 +189,9 @@ func classifyDashboardCredential(c *gin.Context) (*model.UserBase, service.AuthI
 		}
 		return user, identity, dashboardCredentialInternal, nil
 	}
+	if strings.HasPrefix(raw, model.PersonalAccessTokenPrefix) {
+		return authenticatePersonalAccessToken(c, raw)
+	}
 	patUser, err := model.ValidateAccessToken(raw)
 	if err != nil {
 		return nil, service.AuthIdentity{}, dashboardCredentialPAT, err
@@ -177,6 +206,35 @@ func classifyDashboardCredential(c *gin.Context) (*model.UserBase, service.AuthI
 	return user, service.AuthIdentity{UserID: user.Id, UserAuthVersion: user.AuthVersion}, dashboardCredentialPAT, nil
 }
 
+// authenticatePersonalAccessToken 校验带权限范围的管理令牌，并把权限范围写入上下文
+func authenticatePersonalAccessToken(c *gin.Context, raw string) (*model.UserBase, service.AuthIdentity, dashboardCredentialKind, error) {
+	token, err := model.ValidatePersonalAccessToken(raw)
+	if errors.Is(err, model.ErrPersonalAccessTokenExpired) {
+		return nil, service.AuthIdentity{}, dashboardCredentialPAT, service.ErrAuthTokenExpired
+	}
+	if err != nil {
+		return nil, service.AuthIdentity{}, dashboardCredentialPAT, err
+	}
+	if token == nil {
+		return nil, service.AuthIdentity{}, dashboardCredentialPAT, service.ErrAuthTokenInvalid
+	}
+	clientIp := c.ClientIP()
+	if allowIps := token.GetIpLimits(); len(allowIps) > 0 {
+		ip := net.ParseIP(clientIp)
+		if ip == nil || !common.IsIpInCIDRList(ip, allowIps) {
+			return nil, service.AuthIdentity{}, dashboardCredentialPAT, errPersonalAccessTokenIpDenied
+		}
+	}
+	user, err := model.GetUserCache(token.UserId)
+	if err != nil {
+		return nil, service.AuthIdentity{}, dashboardCredentialPAT, err
+	}
+	token.TouchLastUsed(clientIp)
+	c.Set(personalAccessTokenScopesKey, token.GetScopes())
+	c.Set("personal_access_token_id", token.Id)
+	return user, service.AuthIdentity{UserID: user.Id, UserAuthVersion: user.AuthVersion}, dashboardCredentialPAT, nil
+}
+
 func authorizationToken(header string) (string, bool) {
 	header = strings.TrimSpace(header)
 	if header == "" {
@@ -215,6 +273,10 @@ func writeDashboardAuthError(c *gin.Context, err error) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "code": "AUTH_SESSION_REVOKED", "message": common.TranslateMessage(c, i18n.MsgAuthNotLoggedIn)})
 		return
 	}
+	if errors.Is(err, errPersonalAccessTokenIpDenied) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "code": "AUTH_IP_DENIED", "message": common.TranslateMessage(c, i18n.MsgAuthIpNotAllowed)})
+		return
+	}
 	if errors.Is(err, service.ErrAuthTokenInvalid) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "code": "AUTH_UNAUTHORIZED", "message": common.TranslateMessage(c, i18n.MsgAuthAccessTokenInvalid)})
 		return
@@ -223,11 +285,28 @@ func writeDashboardAuthError(c *gin.Context, err error) {
 	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "code": "AUTH_INTERNAL_ERROR", "message": common.TranslateMessage(c, i18n.MsgDatabaseError)})
 }
 
+// CanAccess 判断当前请求能否使用该权限：用户本身需要拥有该权限，使用管理令牌时
+// 令牌的权限范围也必须包含它。控制器内的细粒度权限判断应使用它而非 authz.Can。
+func CanAccess(c *gin.Context, permission authz.Permission) bool {
+	if !authz.Can(c.GetInt("id"), c.GetInt("role"), permission) {
+		return false
+	}
+	value, scoped := c.Get(personalAccessTokenScopesKey)
+	if !scoped {
+		return true
+	}
+	scopes, _ := value.([]string)
+	for _, scope := range scopes {
+		if scope == permission.String() {
+			return true
+		}
+	}
+	return false
+}
+
 func RequirePermission(permission authz.Permission) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		role := c.GetInt("role")
-		userID := c.GetInt("id")
-		if authz.Can(userID, role, permission) {
+		if CanAccess(c, permission) {
 			c.Next()
 			return
 		}
@@ -490,6 +569,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	c.Set("token_id", token.Id)
 	c.Set("token_key", token.Key)
 	c.Set("token_name", token.Name)
+	c.Set("token_callback_url", token.CallbackUrl)
 	c.Set("token_unlimited_quota", token.UnlimitedQuota)
 	if !token.UnlimitedQuota {
 		c.Set("token_quota", token.RemainQuota)
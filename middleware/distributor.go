@@ -162,7 +162,10 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if setupErr := SetupContextForSelectedChannel(c, channel, modelRequest.Model); model.IsMultiKeyRateLimitedError(setupErr) {
+			// 多 Key 渠道的 Key 全部处于冷却中，交给 relay 的重试逻辑换用其他渠道
+			common.SetContextKey(c, constant.ContextKeyChannelSetupError, setupErr)
+		}
 		c.Next()
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
 			service.RecordChannelAffinity(c, channel.Id)
@@ -470,6 +473,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if newAPIError != nil {
 		return newAPIError
 	}
+	common.SetContextKey(c, constant.ContextKeyChannelMultiKeyRateAware, channel.ChannelInfo.IsMultiKey && channel.ChannelInfo.MultiKeyMode.IsRateLimitAware())
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
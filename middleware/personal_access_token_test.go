@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service/authz"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPersonalAccessTokenTest(t *testing.T) *model.User {
+	t.Helper()
+	setupDashboardAuthMiddlewareTest(t)
+	require.NoError(t, model.DB.AutoMigrate(&model.PersonalAccessToken{}, &model.CasbinRule{}, &model.AuthzRole{}))
+	wasMaster := common.IsMasterNode
+	common.IsMasterNode = true
+	t.Cleanup(func() {
+		common.IsMasterNode = wasMaster
+	})
+	require.NoError(t, authz.Init(model.DB))
+	user := &model.User{
+		Username: "pat-admin", Password: "password-placeholder", Role: common.RoleAdminUser,
+		Status: common.UserStatusEnabled, Group: "default", AuthVersion: 1, AffCode: "middleware-aff-pat-admin",
+	}
+	require.NoError(t, model.DB.Create(user).Error)
+	return user
+}
+
+func createScopedPersonalAccessToken(t *testing.T, user *model.User, token model.PersonalAccessToken) string {
+	t.Helper()
+	token.UserId = user.Id
+	token.Name = "automation"
+	if token.ExpiredTime == 0 {
+		token.ExpiredTime = -1
+	}
+	key, err := model.CreatePersonalAccessToken(&token)
+	require.NoError(t, err)
+	return key
+}
+
+func newPersonalAccessTokenRouter() *gin.Engine {
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/logs", StaffAuth(), RequirePermission(authz.LogRead), ok)
+	router.GET("/channels", StaffAuth(), RequirePermission(authz.ChannelRead), ok)
+	router.GET("/self", UserAuth(), ok)
+	return router
+}
+
+func performPersonalAccessTokenRequest(router http.Handler, path string, key string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+key)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestPersonalAccessTokenIsLimitedToItsScopes(t *testing.T) {
+	user := setupPersonalAccessTokenTest(t)
+	key := createScopedPersonalAccessToken(t, user, model.PersonalAccessToken{Scopes: "log:read"})
+	router := newPersonalAccessTokenRouter()
+
+	assert.Equal(t, http.StatusNoContent, performPersonalAccessTokenRequest(router, "/logs", key).Code)
+	assert.Equal(t, http.StatusForbidden, performPersonalAccessTokenRequest(router, "/channels", key).Code)
+	response := performPersonalAccessTokenRequest(router, "/self", key)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Contains(t, response.Body.String(), "AUTH_TOKEN_SCOPE_DENIED")
+}
+
+func TestPersonalAccessTokenRejectsDisallowedIpAndExpiry(t *testing.T) {
+	user := setupPersonalAccessTokenTest(t)
+	router := newPersonalAccessTokenRouter()
+
+	ipKey := createScopedPersonalAccessToken(t, user, model.PersonalAccessToken{Scopes: "log:read", AllowIps: "10.0.0.0/8"})
+	response := performPersonalAccessTokenRequest(router, "/logs", ipKey)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Contains(t, response.Body.String(), "AUTH_IP_DENIED")
+
+	expiredKey := createScopedPersonalAccessToken(t, user, model.PersonalAccessToken{Scopes: "log:read", ExpiredTime: 1})
+	response = performPersonalAccessTokenRequest(router, "/logs", expiredKey)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Contains(t, response.Body.String(), "AUTH_TOKEN_EXPIRED")
+
+	response = performPersonalAccessTokenRequest(router, "/logs", model.PersonalAccessTokenPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
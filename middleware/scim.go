@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithScimError(c *gin.Context, err *scim.Error) {
+	c.Header("Content-Type", scim.ContentType)
+	c.AbortWithStatusJSON(err.StatusCode(), err)
+}
+
+// ScimAuth 校验身份源调用 SCIM 接口时携带的专用 Bearer 令牌。
+// 该令牌与用户会话、管理令牌相互独立，未启用 SCIM 时接口整体不可用。
+func ScimAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := common.SCIMBearerToken
+		if !common.SCIMEnabled || expected == "" {
+			abortWithScimError(c, scim.NewError(http.StatusNotFound, "", "SCIM provisioning is disabled"))
+			return
+		}
+		header := c.GetHeader("Authorization")
+		if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithScimError(c, scim.NewError(http.StatusUnauthorized, "", "missing bearer token"))
+			return
+		}
+		token := strings.TrimSpace(header[len("Bearer "):])
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithScimError(c, scim.NewError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+		c.Next()
+	}
+}
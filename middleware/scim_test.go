@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScimAuthRequiresConfiguredBearerToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previousEnabled, previousToken := common.SCIMEnabled, common.SCIMBearerToken
+	t.Cleanup(func() {
+		common.SCIMEnabled, common.SCIMBearerToken = previousEnabled, previousToken
+	})
+	router := gin.New()
+	router.GET("/scim/v2/Users", ScimAuth(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	perform := func(authorization string) int {
+		request := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	common.SCIMEnabled, common.SCIMBearerToken = false, "scim-secret-token-0123456789abcdef"
+	assert.Equal(t, http.StatusNotFound, perform("Bearer scim-secret-token-0123456789abcdef"))
+
+	common.SCIMEnabled = true
+	assert.Equal(t, http.StatusUnauthorized, perform(""))
+	assert.Equal(t, http.StatusUnauthorized, perform("Bearer wrong"))
+	assert.Equal(t, http.StatusNoContent, perform("bearer scim-secret-token-0123456789abcdef"))
+}
@@ -105,7 +105,7 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetChannel(group string, model string, retry int, requestPath string) (*Channel, error) {
+func GetChannel(group string, model string, retry int, requestPath string, excluded map[int]bool) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
@@ -122,6 +122,7 @@ func GetChannel(group string, model string, retry int, requestPath string) (*Cha
 		return nil, err
 	}
 	abilities = filterAbilitiesByRequestPathAndModel(abilities, requestPath, model)
+	abilities = untriedAbilities(abilities, excluded)
 	channel := Channel{}
 	if len(abilities) > 0 {
 		// Randomly choose one
@@ -146,6 +147,24 @@ func GetChannel(group string, model string, retry int, requestPath string) (*Cha
 	return &channel, err
 }
 
+// untriedAbilities drops the abilities of channels in excluded, keeping all of
+// them when every channel has been tried.
+func untriedAbilities(abilities []Ability, excluded map[int]bool) []Ability {
+	if len(excluded) == 0 {
+		return abilities
+	}
+	candidates := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if !excluded[ability.ChannelId] {
+			candidates = append(candidates, ability)
+		}
+	}
+	if len(candidates) == 0 {
+		return abilities
+	}
+	return candidates
+}
+
 // filterAbilitiesByRequestPathAndModel restricts candidates by request path and
 // model for the DB (non-memory-cache) selection path. Only Advanced Custom
 // (type 58) channels are path-checked: kept only when one of their routes matches
@@ -22,6 +22,9 @@ const (
 	AuthFlowPurposePasskeyStepUp     = "passkey_step_up"
 	AuthFlowPurposeTelegramBind      = "telegram_bind"
 	AuthFlowPurposeTelegramAssertion = "telegram_assertion"
+	AuthFlowPurposeSamlRequest       = "saml_request"
+	AuthFlowPurposeSamlTicket        = "saml_ticket"
+	AuthFlowPurposeSamlAssertion     = "saml_assertion"
 	AuthFlowIntentLogin              = "login"
 	AuthFlowIntentBind               = "bind"
 	AuthFlowTokenBytes               = 32
@@ -8,6 +8,7 @@ type AuthzRole struct {
 	BuiltIn     bool   `json:"built_in"`
 	Enabled     bool   `json:"enabled"`
 	Sort        int    `json:"sort"`
+	QuotaLimit  int    `json:"quota_limit"`
 	CreatedAt   int64  `json:"created_at" gorm:"autoCreateTime;column:created_at"`
 	UpdatedAt   int64  `json:"updated_at" gorm:"autoUpdateTime;column:updated_at"`
 }
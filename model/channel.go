@@ -12,6 +12,8 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	commonRelay "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/types"
 
@@ -57,6 +59,8 @@ type Channel struct {
 
 	// cache info
 	Keys []string `json:"-" gorm:"-"`
+	// 上游限流响应头的最新观测，仅在渠道详情接口中填充
+	UpstreamRateLimit *ChannelUpstreamRateLimit `json:"upstream_rate_limit,omitempty" gorm:"-"`
 }
 
 type ChannelInfo struct {
@@ -67,6 +71,8 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyRPMLimit       int                   `json:"multi_key_rpm_limit,omitempty"` // 限流感知模式下每个Key的每分钟请求数上限，0表示不限
+	MultiKeyTPMLimit       int                   `json:"multi_key_tpm_limit,omitempty"` // 限流感知模式下每个Key的每分钟Token数上限，0表示不限
 }
 
 type ChannelSortOptions struct {
@@ -276,6 +282,12 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		// Fallback – should not happen, but return first enabled key
 		return keys[enabledIdx[0]], enabledIdx[0], nil
+	case constant.MultiKeyModeHeadroom, constant.MultiKeyModeLRU:
+		selectedIdx, apiErr := channel.selectRateLimitAwareKey(enabledIdx)
+		if apiErr != nil {
+			return "", 0, apiErr
+		}
+		return keys[selectedIdx], selectedIdx, nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
 		return keys[enabledIdx[0]], enabledIdx[0], nil
@@ -802,6 +814,27 @@ func DisableChannelByTag(tag string) error {
 	return err
 }
 
+// UpdateChannelStatusByIds 手动启用或禁用指定渠道，返回实际存在的渠道数量
+func UpdateChannelStatusByIds(ids []int, enabled bool) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	status := common.ChannelStatusManuallyDisabled
+	if enabled {
+		status = common.ChannelStatusEnabled
+	}
+	var affected int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Channel{}).Where("id in (?)", ids).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return tx.Model(&Ability{}).Where("channel_id in (?)", ids).Select("enabled").Update("enabled", enabled).Error
+	})
+	return affected, err
+}
+
 func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint, paramOverride *string, headerOverride *string) error {
 	updateData := Channel{}
 	shouldReCreateAbilities := false
@@ -974,11 +1007,42 @@ func (channel *Channel) ValidateSettings() error {
 			return err
 		}
 	}
+	if channel.Type == constant.ChannelTypeCustomTask {
+		if err := channelOtherSettings.CustomTask.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := commonRelay.ValidateResponseOverride(channelOtherSettings.ResponseOverride); err != nil {
+		return err
+	}
 	if channel.Type == constant.ChannelTypeAdvancedCustom && channelOtherSettings.UpstreamModelUpdateCheckEnabled {
 		if _, ok := channelOtherSettings.AdvancedCustom.ModelListRoute(); !ok {
 			return fmt.Errorf("advanced custom channels require a %s route when upstream model update checks are enabled", dto.AdvancedCustomModelListPath)
 		}
 	}
+	if err := validateChannelUpstreamCost(channelOtherSettings.UpstreamCost); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateChannelUpstreamCost(cost *dto.ChannelUpstreamCost) error {
+	if cost == nil {
+		return nil
+	}
+	if cost.Ratio < 0 {
+		return fmt.Errorf("upstream cost ratio must not be negative")
+	}
+	for modelName, ratio := range cost.ModelRatios {
+		if ratio < 0 {
+			return fmt.Errorf("upstream cost ratio of model %s must not be negative", modelName)
+		}
+	}
+	if expr := strings.TrimSpace(cost.Expr); expr != "" {
+		if _, err := billingexpr.CompileFromCache(expr); err != nil {
+			return fmt.Errorf("invalid upstream cost expr: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -1,8 +1,10 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 	"strings"
@@ -21,6 +23,10 @@ var channelsIDM map[int]*Channel                     // all channels include dis
 // channel2advancedCustomConfig caches parsed Advanced Custom (type 58) configs so
 // path-aware selection avoids re-parsing JSON per request. Refreshed on full sync.
 var channel2advancedCustomConfig map[int]*dto.AdvancedCustomConfig
+
+// channel2upstreamCost caches parsed upstream cost settings for cheapest-first
+// selection. Refreshed on full sync.
+var channel2upstreamCost map[int]*dto.ChannelUpstreamCost
 var channelSyncLock sync.RWMutex
 
 func InitChannelCache() {
@@ -30,15 +36,20 @@ func InitChannelCache() {
 	}
 	newChannelId2channel := make(map[int]*Channel)
 	newChannel2advancedCustomConfig := make(map[int]*dto.AdvancedCustomConfig)
+	newChannel2upstreamCost := make(map[int]*dto.ChannelUpstreamCost)
 	var channels []*Channel
 	DB.Find(&channels)
 	for _, channel := range channels {
 		newChannelId2channel[channel.Id] = channel
+		otherSettings := channel.GetOtherSettings()
 		if channel.Type == constant.ChannelTypeAdvancedCustom {
-			if config := channel.GetOtherSettings().AdvancedCustom; config != nil {
+			if config := otherSettings.AdvancedCustom; config != nil {
 				newChannel2advancedCustomConfig[channel.Id] = config
 			}
 		}
+		if otherSettings.UpstreamCost != nil {
+			newChannel2upstreamCost[channel.Id] = otherSettings.UpstreamCost
+		}
 	}
 	var abilities []*Ability
 	DB.Find(&abilities)
@@ -94,6 +105,7 @@ func InitChannelCache() {
 	}
 	channelsIDM = newChannelId2channel
 	channel2advancedCustomConfig = newChannel2advancedCustomConfig
+	channel2upstreamCost = newChannel2upstreamCost
 	channelSyncLock.Unlock()
 	// Lock ordering: InvalidatePricingCache acquires updatePricingLock, and
 	// GetPricing (holding updatePricingLock) nests channelSyncLock.RLock via
@@ -111,15 +123,131 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int, requestPath string) (*Channel, error) {
+// channelCacheInvalidateTopic 是渠道缓存失效通知的 Redis 频道
+const channelCacheInvalidateTopic = "new-api:channel_cache_invalidate"
+
+// channelCacheNodeId 标识本进程，用于忽略自己发出的失效通知
+var channelCacheNodeId = common.GetUUID()
+
+// BroadcastChannelCacheInvalidation 重建本节点的渠道缓存，并通过 Redis 通知其他节点立即重建，
+// 不必等待下一次定时同步。未启用 Redis 时仅重建本节点缓存
+func BroadcastChannelCacheInvalidation() {
+	InitChannelCache()
+	if !common.RedisEnabled || common.RDB == nil {
+		return
+	}
+	if err := common.RDB.Publish(context.Background(), channelCacheInvalidateTopic, channelCacheNodeId).Err(); err != nil {
+		common.SysError("failed to publish channel cache invalidation: " + err.Error())
+	}
+}
+
+// SubscribeChannelCacheInvalidation 订阅其他节点发出的渠道缓存失效通知，收到后重建本节点缓存。
+// 连接中断期间丢失的通知由 SyncChannelCache 的定时同步兜底
+func SubscribeChannelCacheInvalidation() {
+	if !common.RedisEnabled || common.RDB == nil {
+		return
+	}
+	for {
+		pubsub := common.RDB.Subscribe(context.Background(), channelCacheInvalidateTopic)
+		for msg := range pubsub.Channel() {
+			if msg.Payload == channelCacheNodeId {
+				continue
+			}
+			common.SysLog("channel cache invalidated by another node, syncing channels from database")
+			InitChannelCache()
+		}
+		_ = pubsub.Close()
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// GetRandomSatisfiedChannel picks a channel of the priority tier by weight.
+// Channels in excluded (already tried by this request) are skipped unless
+// every channel of the tier has been tried.
+func GetRandomSatisfiedChannel(group string, model string, retry int, requestPath string, excluded map[int]bool) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetChannel(group, model, retry, requestPath)
+		return GetChannel(group, model, retry, requestPath, excluded)
+	}
+
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+
+	targetChannels, err := getSatisfiedChannelTier(group, model, retry, requestPath)
+	if err != nil || len(targetChannels) == 0 {
+		return nil, err
+	}
+	return pickWeightedChannel(untriedChannels(targetChannels, excluded))
+}
+
+// untriedChannels drops the channels in excluded, keeping the whole tier when
+// all of them have been tried.
+func untriedChannels(channels []*Channel, excluded map[int]bool) []*Channel {
+	if len(excluded) == 0 {
+		return channels
+	}
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !excluded[channel.Id] {
+			candidates = append(candidates, channel)
+		}
+	}
+	if len(candidates) == 0 {
+		// 本层渠道均已尝试过，退回按权重随机
+		return channels
+	}
+	return candidates
+}
+
+// GetCheapestSatisfiedChannel selects within the same priority tier as
+// GetRandomSatisfiedChannel, but prefers the channel with the lowest upstream
+// cost ratio for the model. Channels in excluded (already tried by this
+// request) are skipped, channels without a configured cost rank last, and
+// equally cheap channels are picked by weight. Without the memory cache it
+// falls back to the random database selection.
+func GetCheapestSatisfiedChannel(group string, model string, retry int, requestPath string, excluded map[int]bool) (*Channel, error) {
+	if !common.MemoryCacheEnabled {
+		return GetChannel(group, model, retry, requestPath, excluded)
 	}
 
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
 
+	targetChannels, err := getSatisfiedChannelTier(group, model, retry, requestPath)
+	if err != nil || len(targetChannels) == 0 {
+		return nil, err
+	}
+	return pickWeightedChannel(cheapestChannels(untriedChannels(targetChannels, excluded), model))
+}
+
+// cheapestChannels returns the channels sharing the lowest cost ratio for the
+// model, or all channels when none of them has a cost configured. Caller must
+// hold channelSyncLock (read lock).
+func cheapestChannels(channels []*Channel, model string) []*Channel {
+	var cheapest []*Channel
+	minRatio := 0.0
+	for _, channel := range channels {
+		ratio, ok := channel2upstreamCost[channel.Id].RatioFor(model)
+		if !ok {
+			continue
+		}
+		switch {
+		case cheapest == nil || ratio < minRatio:
+			cheapest = []*Channel{channel}
+			minRatio = ratio
+		case ratio == minRatio:
+			cheapest = append(cheapest, channel)
+		}
+	}
+	if cheapest == nil {
+		return channels
+	}
+	return cheapest
+}
+
+// getSatisfiedChannelTier returns the enabled channels of the priority tier
+// selected by retry. Caller must hold channelSyncLock (read lock).
+func getSatisfiedChannelTier(group string, model string, retry int, requestPath string) ([]*Channel, error) {
 	// First, try to find channels with the exact model name.
 	channels := filterChannelsByRequestPathAndModel(group2model2channels[group][model], requestPath, model)
 
@@ -135,7 +263,7 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 
 	if len(channels) == 1 {
 		if channel, ok := channelsIDM[channels[0]]; ok {
-			return channel, nil
+			return []*Channel{channel}, nil
 		}
 		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
 	}
@@ -160,12 +288,10 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	targetPriority := int64(sortedUniquePriorities[retry])
 
 	// get the priority for the given retry number
-	var sumWeight = 0
 	var targetChannels []*Channel
 	for _, channelId := range channels {
 		if channel, ok := channelsIDM[channelId]; ok {
 			if channel.GetPriority() == targetPriority {
-				sumWeight += channel.GetWeight()
 				targetChannels = append(targetChannels, channel)
 			}
 		} else {
@@ -176,6 +302,20 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	if len(targetChannels) == 0 {
 		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
 	}
+	return targetChannels, nil
+}
+
+// pickWeightedChannel picks one channel at random, proportionally to weight.
+// Channels reported by upstream rate-limit headers as close to exhaustion are
+// down-weighted; if every candidate is exhausted the plain weights are used.
+func pickWeightedChannel(targetChannels []*Channel) (*Channel, error) {
+	if len(targetChannels) == 1 {
+		return targetChannels[0], nil
+	}
+	sumWeight := 0
+	for _, channel := range targetChannels {
+		sumWeight += channel.GetWeight()
+	}
 
 	// smoothing factor and adjustment
 	smoothingFactor := 1
@@ -184,22 +324,39 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	if sumWeight == 0 {
 		// when all channels have weight 0, set sumWeight to the number of channels and set smoothing adjustment to 100
 		// each channel's effective weight = 100
-		sumWeight = len(targetChannels) * 100
 		smoothingAdjustment = 100
 	} else if sumWeight/len(targetChannels) < 10 {
 		// when the average weight is less than 10, set smoothing factor to 100
 		smoothingFactor = 100
 	}
 
-	// Calculate the total weight of all channels up to endIdx
-	totalWeight := sumWeight * smoothingFactor
+	// Calculate the effective weight of each channel, scaled by upstream headroom
+	now := time.Now()
+	weights := make([]int, len(targetChannels))
+	totalWeight := 0
+	steeredWeight := 0
+	for i, channel := range targetChannels {
+		weights[i] = channel.GetWeight()*smoothingFactor + smoothingAdjustment
+		totalWeight += weights[i]
+		if factor := upstreamRateLimitWeightFactor(channel, now); factor < 1 {
+			weights[i] = int(math.Ceil(float64(weights[i]) * factor))
+		}
+		steeredWeight += weights[i]
+	}
+	if steeredWeight > 0 {
+		totalWeight = steeredWeight
+	} else {
+		for i, channel := range targetChannels {
+			weights[i] = channel.GetWeight()*smoothingFactor + smoothingAdjustment
+		}
+	}
 
 	// Generate a random value in the range [0, totalWeight)
 	randomWeight := rand.Intn(totalWeight)
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight()*smoothingFactor + smoothingAdjustment
+	for i, channel := range targetChannels {
+		randomWeight -= weights[i]
 		if randomWeight < 0 {
 			return channel, nil
 		}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCheapestSatisfiedChannel(t *testing.T) {
+	truncateTables(t)
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = true
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = memoryCacheEnabled
+	})
+
+	channels := []*Channel{
+		{Id: 1, Name: "list", Key: "k", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt",
+			Priority: common.GetPointer(int64(10)), OtherSettings: `{"upstream_cost":{"ratio":1}}`},
+		{Id: 2, Name: "discount", Key: "k", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt",
+			Priority: common.GetPointer(int64(10)), OtherSettings: `{"upstream_cost":{"ratio":1,"model_ratios":{"gpt":0.4}}}`},
+		{Id: 3, Name: "unknown", Key: "k", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt",
+			Priority: common.GetPointer(int64(10))},
+		{Id: 4, Name: "cheapest-low-priority", Key: "k", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt",
+			Priority: common.GetPointer(int64(0)), OtherSettings: `{"upstream_cost":{"ratio":0.1}}`},
+	}
+	for _, channel := range channels {
+		require.NoError(t, DB.Create(channel).Error)
+		require.NoError(t, DB.Create(&Ability{Group: "default", Model: "gpt", ChannelId: channel.Id, Enabled: true,
+			Priority: channel.Priority}).Error)
+	}
+	InitChannelCache()
+
+	channel, err := GetCheapestSatisfiedChannel("default", "gpt", 0, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, channel.Id)
+
+	// 已尝试过的渠道被跳过
+	channel, err = GetCheapestSatisfiedChannel("default", "gpt", 0, "", map[int]bool{2: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, channel.Id)
+
+	// 未配置成本的渠道排在最后
+	channel, err = GetCheapestSatisfiedChannel("default", "gpt", 0, "", map[int]bool{1: true, 2: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, channel.Id)
+
+	// 成本排序不跨越优先级
+	channel, err = GetCheapestSatisfiedChannel("default", "gpt", 1, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, channel.Id)
+}
+
+func TestGetRandomSatisfiedChannelSkipsTriedChannels(t *testing.T) {
+	truncateTables(t)
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = memoryCacheEnabled
+	})
+
+	for _, id := range []int{1, 2} {
+		channel := &Channel{Id: id, Name: "tier", Key: "k", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt",
+			Priority: common.GetPointer(int64(10))}
+		require.NoError(t, DB.Create(channel).Error)
+		require.NoError(t, DB.Create(&Ability{Group: "default", Model: "gpt", ChannelId: id, Enabled: true,
+			Priority: channel.Priority}).Error)
+	}
+
+	for _, cached := range []bool{true, false} {
+		common.MemoryCacheEnabled = cached
+		if cached {
+			InitChannelCache()
+		}
+		// 同一优先级内不会再次选中已尝试过的渠道
+		for i := 0; i < 20; i++ {
+			channel, err := GetRandomSatisfiedChannel("default", "gpt", 0, "", map[int]bool{1: true})
+			require.NoError(t, err)
+			assert.Equal(t, 2, channel.Id, "memory cache: %v", cached)
+		}
+		channel, err := GetRandomSatisfiedChannel("default", "gpt", 0, "", map[int]bool{1: true, 2: true})
+		require.NoError(t, err)
+		assert.NotNil(t, channel, "falls back to the whole tier once every channel was tried")
+	}
+}
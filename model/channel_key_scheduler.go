@@ -0,0 +1,463 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/relaykit/types"
+)
+
+// 限流感知的多 Key 调度（headroom / lru 模式）。
+// 每个 Key 记录冷却截止时间、当前分钟的请求数与 Token 数、最近使用时间以及上游返回的剩余额度。
+// 启用 Redis 时状态在所有节点间共享；否则退化为本机内存，仅对当前节点生效。
+// 选中 Key 时通过 claimMultiKey 原子地校验冷却与 RPM 预算并计数，多个节点并发调度时不会超出 RPM；
+// TPM 与上游剩余额度在请求结束后才能得知，只能尽力而为。
+
+const (
+	multiKeyStateTTL        = 24 * time.Hour
+	multiKeyMinuteStateTTL  = 2 * time.Minute
+	multiKeyHeadroomEpsilon = 1e-9
+)
+
+// MultiKeyRuntimeState 是多 Key 渠道中单个 Key 的运行时调度状态
+type MultiKeyRuntimeState struct {
+	Index             int    `json:"index"`
+	CooldownUntil     int64  `json:"cooldown_until,omitempty"`
+	CooldownReason    string `json:"cooldown_reason,omitempty"`
+	Requests          int64  `json:"rpm"`
+	Tokens            int64  `json:"tpm"`
+	LastUsedAt        int64  `json:"last_used_at,omitempty"` // unix ms
+	RemainingRequests *int64 `json:"upstream_remaining_requests,omitempty"`
+	RemainingTokens   *int64 `json:"upstream_remaining_tokens,omitempty"`
+}
+
+// CoolingDown 返回 Key 在 now 时刻是否仍处于冷却中
+func (s *MultiKeyRuntimeState) CoolingDown(now time.Time) bool {
+	return s != nil && s.CooldownUntil > now.Unix()
+}
+
+// multiKeyMemoryState 是未启用 Redis 时的本机状态
+type multiKeyMemoryState struct {
+	cooldownUntil     int64
+	cooldownReason    string
+	minute            int64
+	requests          int64
+	tokens            int64
+	lastUsedAt        int64
+	remainingRequests *int64
+	remainingTokens   *int64
+}
+
+var (
+	multiKeyMemoryStates     = make(map[int]map[int]*multiKeyMemoryState)
+	multiKeyMemoryStatesLock sync.Mutex
+)
+
+func multiKeyRedisEnabled() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+func multiKeyStateRedisKey(channelId int) string {
+	return fmt.Sprintf("multikey:%d", channelId)
+}
+
+func multiKeyMinuteRedisKey(channelId int, minute int64) string {
+	return fmt.Sprintf("multikey:%d:m:%d", channelId, minute)
+}
+
+func multiKeyMinute(now time.Time) int64 {
+	return now.Unix() / 60
+}
+
+// memoryStateLocked 返回 Key 的内存状态并在跨分钟时清零分钟计数，调用方需持有 multiKeyMemoryStatesLock
+func memoryStateLocked(channelId int, index int, now time.Time) *multiKeyMemoryState {
+	channelStates, ok := multiKeyMemoryStates[channelId]
+	if !ok {
+		channelStates = make(map[int]*multiKeyMemoryState)
+		multiKeyMemoryStates[channelId] = channelStates
+	}
+	state, ok := channelStates[index]
+	if !ok {
+		state = &multiKeyMemoryState{}
+		channelStates[index] = state
+	}
+	if minute := multiKeyMinute(now); state.minute != minute {
+		state.minute = minute
+		state.requests = 0
+		state.tokens = 0
+		state.remainingRequests = nil
+		state.remainingTokens = nil
+	}
+	return state
+}
+
+// GetMultiKeyRuntimeStates 读取渠道所有 Key 的调度状态，key index -> state
+func GetMultiKeyRuntimeStates(channelId int) map[int]*MultiKeyRuntimeState {
+	now := time.Now()
+	states := make(map[int]*MultiKeyRuntimeState)
+	getState := func(index int) *MultiKeyRuntimeState {
+		state, ok := states[index]
+		if !ok {
+			state = &MultiKeyRuntimeState{Index: index}
+			states[index] = state
+		}
+		return state
+	}
+	if !multiKeyRedisEnabled() {
+		multiKeyMemoryStatesLock.Lock()
+		defer multiKeyMemoryStatesLock.Unlock()
+		for index := range multiKeyMemoryStates[channelId] {
+			memory := memoryStateLocked(channelId, index, now)
+			state := getState(index)
+			state.CooldownUntil = memory.cooldownUntil
+			state.CooldownReason = memory.cooldownReason
+			state.Requests = memory.requests
+			state.Tokens = memory.tokens
+			state.LastUsedAt = memory.lastUsedAt
+			state.RemainingRequests = memory.remainingRequests
+			state.RemainingTokens = memory.remainingTokens
+		}
+		return states
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pipe := common.RDB.Pipeline()
+	stateCmd := pipe.HGetAll(ctx, multiKeyStateRedisKey(channelId))
+	minuteCmd := pipe.HGetAll(ctx, multiKeyMinuteRedisKey(channelId, multiKeyMinute(now)))
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("failed to load multi-key state of channel #%d: %v", channelId, err))
+		return states
+	}
+	// 字段格式为 <name>:<key index>
+	parseField := func(field string) (string, int, bool) {
+		name, indexStr, ok := strings.Cut(field, ":")
+		if !ok {
+			return "", 0, false
+		}
+		index, err := strconv.Atoi(indexStr)
+		if err != nil {
+			return "", 0, false
+		}
+		return name, index, true
+	}
+	for field, value := range stateCmd.Val() {
+		name, index, ok := parseField(field)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "cd":
+			getState(index).CooldownUntil, _ = strconv.ParseInt(value, 10, 64)
+		case "cdr":
+			getState(index).CooldownReason = value
+		case "lru":
+			getState(index).LastUsedAt, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	for field, value := range minuteCmd.Val() {
+		name, index, ok := parseField(field)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "r":
+			getState(index).Requests = n
+		case "t":
+			getState(index).Tokens = n
+		case "rr":
+			getState(index).RemainingRequests = common.GetPointer(n)
+		case "rt":
+			getState(index).RemainingTokens = common.GetPointer(n)
+		}
+	}
+	return states
+}
+
+// multiKeyClaimScript 原子地占用 Key：未冷却且本分钟请求数未达 RPM 上限时计数加一并刷新最近使用时间
+// KEYS: state key, minute key; ARGV: index, now(秒), now(毫秒), rpm limit, state ttl, minute ttl
+const multiKeyClaimScript = `
+local index = ARGV[1]
+local cooldown = tonumber(redis.call('HGET', KEYS[1], 'cd:' .. index) or '0')
+if cooldown > tonumber(ARGV[2]) then
+	return 0
+end
+local limit = tonumber(ARGV[4])
+if limit > 0 then
+	local requests = tonumber(redis.call('HGET', KEYS[2], 'r:' .. index) or '0')
+	if requests >= limit then
+		return 0
+	end
+end
+redis.call('HINCRBY', KEYS[2], 'r:' .. index, 1)
+redis.call('EXPIRE', KEYS[2], ARGV[6])
+redis.call('HSET', KEYS[1], 'lru:' .. index, ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return 1
+`
+
+// claimMultiKey 占用一次 Key：冷却中或本分钟请求数已达 rpmLimit 时返回 false（可能被其他节点抢先），
+// 否则当前分钟请求数加一并刷新最近使用时间。Redis 出错时退回本机内存限流，仍然遵守 RPM 上限
+func claimMultiKey(channelId int, index int, rpmLimit int, now time.Time) bool {
+	if !multiKeyRedisEnabled() {
+		return claimMultiKeyInMemory(channelId, index, rpmLimit, now)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result, err := common.RDB.Eval(ctx, multiKeyClaimScript,
+		[]string{multiKeyStateRedisKey(channelId), multiKeyMinuteRedisKey(channelId, multiKeyMinute(now))},
+		index, now.Unix(), now.UnixMilli(), rpmLimit,
+		int(multiKeyStateTTL/time.Second), int(multiKeyMinuteStateTTL/time.Second),
+	).Int()
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to claim key #%d of channel #%d, falling back to local limiter: %v", index, channelId, err))
+		return claimMultiKeyInMemory(channelId, index, rpmLimit, now)
+	}
+	return result == 1
+}
+
+// claimMultiKeyInMemory 是 claimMultiKey 的本机实现
+func claimMultiKeyInMemory(channelId int, index int, rpmLimit int, now time.Time) bool {
+	multiKeyMemoryStatesLock.Lock()
+	defer multiKeyMemoryStatesLock.Unlock()
+	state := memoryStateLocked(channelId, index, now)
+	if state.cooldownUntil > now.Unix() {
+		return false
+	}
+	if rpmLimit > 0 && state.requests >= int64(rpmLimit) {
+		return false
+	}
+	state.requests++
+	state.lastUsedAt = now.UnixMilli()
+	return true
+}
+
+// RecordMultiKeyTokens 在结算时把本次请求消耗的 Token 计入 Key 当前分钟的 TPM
+func RecordMultiKeyTokens(channelId int, index int, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	if !multiKeyRedisEnabled() {
+		multiKeyMemoryStatesLock.Lock()
+		defer multiKeyMemoryStatesLock.Unlock()
+		memoryStateLocked(channelId, index, now).tokens += tokens
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	minuteKey := multiKeyMinuteRedisKey(channelId, multiKeyMinute(now))
+	pipe := common.RDB.TxPipeline()
+	pipe.HIncrBy(ctx, minuteKey, fmt.Sprintf("t:%d", index), tokens)
+	pipe.Expire(ctx, minuteKey, multiKeyMinuteStateTTL)
+	_, _ = pipe.Exec(ctx)
+}
+
+// SetMultiKeyUpstreamRemaining 记录上游响应头中的剩余请求数 / Token 数，仅在当前分钟内参与调度
+func SetMultiKeyUpstreamRemaining(channelId int, index int, requests *int64, tokens *int64) {
+	if requests == nil && tokens == nil {
+		return
+	}
+	now := time.Now()
+	if !multiKeyRedisEnabled() {
+		multiKeyMemoryStatesLock.Lock()
+		defer multiKeyMemoryStatesLock.Unlock()
+		state := memoryStateLocked(channelId, index, now)
+		if requests != nil {
+			state.remainingRequests = common.GetPointer(*requests)
+		}
+		if tokens != nil {
+			state.remainingTokens = common.GetPointer(*tokens)
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	minuteKey := multiKeyMinuteRedisKey(channelId, multiKeyMinute(now))
+	pipe := common.RDB.TxPipeline()
+	if requests != nil {
+		pipe.HSet(ctx, minuteKey, fmt.Sprintf("rr:%d", index), *requests)
+	}
+	if tokens != nil {
+		pipe.HSet(ctx, minuteKey, fmt.Sprintf("rt:%d", index), *tokens)
+	}
+	pipe.Expire(ctx, minuteKey, multiKeyMinuteStateTTL)
+	_, _ = pipe.Exec(ctx)
+}
+
+// ParkMultiKey 使 Key 冷却到 until（unix 秒），冷却期间调度会跳过该 Key，但不会禁用它
+func ParkMultiKey(channelId int, index int, until int64, reason string) {
+	if !multiKeyRedisEnabled() {
+		multiKeyMemoryStatesLock.Lock()
+		defer multiKeyMemoryStatesLock.Unlock()
+		state := memoryStateLocked(channelId, index, time.Now())
+		state.cooldownUntil = until
+		state.cooldownReason = reason
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stateKey := multiKeyStateRedisKey(channelId)
+	pipe := common.RDB.TxPipeline()
+	pipe.HSet(ctx, stateKey, fmt.Sprintf("cd:%d", index), until, fmt.Sprintf("cdr:%d", index), reason)
+	pipe.Expire(ctx, stateKey, multiKeyStateTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		common.SysError(fmt.Sprintf("failed to park key #%d of channel #%d: %v", index, channelId, err))
+	}
+}
+
+// ClearMultiKeyCooldown 解除 Key 的冷却；index 为 nil 时解除渠道下所有 Key 的冷却
+func ClearMultiKeyCooldown(channelId int, index *int) error {
+	if !multiKeyRedisEnabled() {
+		multiKeyMemoryStatesLock.Lock()
+		defer multiKeyMemoryStatesLock.Unlock()
+		for i, state := range multiKeyMemoryStates[channelId] {
+			if index == nil || *index == i {
+				state.cooldownUntil = 0
+				state.cooldownReason = ""
+			}
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stateKey := multiKeyStateRedisKey(channelId)
+	if index != nil {
+		return common.RDB.HDel(ctx, stateKey, fmt.Sprintf("cd:%d", *index), fmt.Sprintf("cdr:%d", *index)).Err()
+	}
+	fields, err := common.RDB.HKeys(ctx, stateKey).Result()
+	if err != nil {
+		return err
+	}
+	cooldownFields := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if strings.HasPrefix(field, "cd:") || strings.HasPrefix(field, "cdr:") {
+			cooldownFields = append(cooldownFields, field)
+		}
+	}
+	if len(cooldownFields) == 0 {
+		return nil
+	}
+	return common.RDB.HDel(ctx, stateKey, cooldownFields...).Err()
+}
+
+// ResetMultiKeyRuntimeStates 清空渠道的调度状态；删除 Key 后索引会重新排列，旧状态不再对应原来的 Key
+func ResetMultiKeyRuntimeStates(channelId int) {
+	// Redis 出错时 claimMultiKey 会写入本机状态，这里一并清除
+	multiKeyMemoryStatesLock.Lock()
+	delete(multiKeyMemoryStates, channelId)
+	multiKeyMemoryStatesLock.Unlock()
+	if !multiKeyRedisEnabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	now := time.Now()
+	common.RDB.Del(ctx,
+		multiKeyStateRedisKey(channelId),
+		multiKeyMinuteRedisKey(channelId, multiKeyMinute(now)),
+		multiKeyMinuteRedisKey(channelId, multiKeyMinute(now)-1),
+	)
+}
+
+// multiKeyHeadroom 返回 Key 剩余额度的比例（0~1），取 RPM、TPM 与上游剩余请求数中最紧张的一项；
+// 未配置任何预算时返回 1，此时由最近使用时间决定顺序。
+func multiKeyHeadroom(state *MultiKeyRuntimeState, rpmLimit int, tpmLimit int) float64 {
+	headroom := 1.0
+	if rpmLimit > 0 {
+		headroom = math.Min(headroom, 1-float64(state.Requests)/float64(rpmLimit))
+		if state.RemainingRequests != nil {
+			headroom = math.Min(headroom, float64(*state.RemainingRequests)/float64(rpmLimit))
+		}
+	}
+	if tpmLimit > 0 {
+		headroom = math.Min(headroom, 1-float64(state.Tokens)/float64(tpmLimit))
+		if state.RemainingTokens != nil {
+			headroom = math.Min(headroom, float64(*state.RemainingTokens)/float64(tpmLimit))
+		}
+	}
+	return headroom
+}
+
+// multiKeyAvailable 判断 Key 是否可被调度：未冷却、未超出本分钟预算、上游未报告额度耗尽
+func multiKeyAvailable(state *MultiKeyRuntimeState, rpmLimit int, tpmLimit int, now time.Time) bool {
+	if state.CoolingDown(now) {
+		return false
+	}
+	if rpmLimit > 0 && state.Requests >= int64(rpmLimit) {
+		return false
+	}
+	if tpmLimit > 0 && state.Tokens >= int64(tpmLimit) {
+		return false
+	}
+	if state.RemainingRequests != nil && *state.RemainingRequests <= 0 {
+		return false
+	}
+	if state.RemainingTokens != nil && *state.RemainingTokens <= 0 {
+		return false
+	}
+	return true
+}
+
+// selectRateLimitAwareKey 在启用的 Key 中按 headroom / lru 规则排序，依次尝试原子占用，返回第一个占用成功的 Key。
+// 所有 Key 都在冷却或超出预算时返回 429，该错误不是渠道错误，不会触发自动禁用。
+func (channel *Channel) selectRateLimitAwareKey(enabledIdx []int) (int, *types.NewAPIError) {
+	now := time.Now()
+	states := GetMultiKeyRuntimeStates(channel.Id)
+	rpmLimit := channel.ChannelInfo.MultiKeyRPMLimit
+	tpmLimit := channel.ChannelInfo.MultiKeyTPMLimit
+
+	type candidate struct {
+		index    int
+		headroom float64
+		lastUsed int64
+	}
+	candidates := make([]candidate, 0, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		state, ok := states[idx]
+		if !ok {
+			state = &MultiKeyRuntimeState{Index: idx}
+		}
+		if !multiKeyAvailable(state, rpmLimit, tpmLimit, now) {
+			continue
+		}
+		headroom := 1.0
+		if channel.ChannelInfo.MultiKeyMode == constant.MultiKeyModeHeadroom {
+			headroom = multiKeyHeadroom(state, rpmLimit, tpmLimit)
+		}
+		candidates = append(candidates, candidate{index: idx, headroom: headroom, lastUsed: state.LastUsedAt})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if math.Abs(candidates[i].headroom-candidates[j].headroom) > multiKeyHeadroomEpsilon {
+			return candidates[i].headroom > candidates[j].headroom
+		}
+		return candidates[i].lastUsed < candidates[j].lastUsed
+	})
+	for _, c := range candidates {
+		if claimMultiKey(channel.Id, c.index, rpmLimit, now) {
+			return c.index, nil
+		}
+	}
+	return 0, types.NewErrorWithStatusCode(
+		errors.New("all keys of the channel are cooling down or out of rate limit budget"),
+		types.ErrorCodeMultiKeyRateLimited, http.StatusTooManyRequests)
+}
+
+// IsMultiKeyRateLimitedError 返回错误是否由多 Key 渠道的所有 Key 均被限流导致
+func IsMultiKeyRateLimitedError(err *types.NewAPIError) bool {
+	return err != nil && err.GetErrorCode() == types.ErrorCodeMultiKeyRateLimited
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitAwareChannel(id int, mode constant.MultiKeyMode) *Channel {
+	return &Channel{
+		Id:  id,
+		Key: "k0\nk1\nk2",
+		ChannelInfo: ChannelInfo{
+			IsMultiKey:   true,
+			MultiKeySize: 3,
+			MultiKeyMode: mode,
+		},
+	}
+}
+
+func TestRateLimitAwareKeySelectionSkipsParkedKeys(t *testing.T) {
+	channel := newRateLimitAwareChannel(90001, constant.MultiKeyModeLRU)
+	t.Cleanup(func() { ResetMultiKeyRuntimeStates(channel.Id) })
+
+	// LRU 模式依次轮换所有 Key
+	seen := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		_, idx, err := channel.GetNextEnabledKey()
+		require.Nil(t, err)
+		seen[idx] = true
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Len(t, seen, 3)
+
+	until := time.Now().Add(time.Minute).Unix()
+	ParkMultiKey(channel.Id, 0, until, "upstream 429")
+	ParkMultiKey(channel.Id, 1, until, "upstream 429")
+	for i := 0; i < 3; i++ {
+		key, idx, err := channel.GetNextEnabledKey()
+		require.Nil(t, err)
+		assert.Equal(t, 2, idx)
+		assert.Equal(t, "k2", key)
+	}
+
+	ParkMultiKey(channel.Id, 2, until, "upstream 429")
+	_, _, err := channel.GetNextEnabledKey()
+	require.NotNil(t, err)
+	assert.Equal(t, types.ErrorCodeMultiKeyRateLimited, err.GetErrorCode())
+	assert.False(t, types.IsChannelError(err))
+
+	states := GetMultiKeyRuntimeStates(channel.Id)
+	assert.Equal(t, until, states[1].CooldownUntil)
+	assert.Equal(t, "upstream 429", states[1].CooldownReason)
+
+	require.NoError(t, ClearMultiKeyCooldown(channel.Id, common.GetPointer(1)))
+	_, idx, err := channel.GetNextEnabledKey()
+	require.Nil(t, err)
+	assert.Equal(t, 1, idx)
+}
+
+func TestRateLimitAwareKeySelectionPrefersHeadroom(t *testing.T) {
+	channel := newRateLimitAwareChannel(90002, constant.MultiKeyModeHeadroom)
+	channel.ChannelInfo.MultiKeyRPMLimit = 10
+	channel.ChannelInfo.MultiKeyTPMLimit = 1000
+	t.Cleanup(func() { ResetMultiKeyRuntimeStates(channel.Id) })
+
+	RecordMultiKeyTokens(channel.Id, 0, 900)
+	SetMultiKeyUpstreamRemaining(channel.Id, 1, common.GetPointer(int64(2)), nil)
+	RecordMultiKeyTokens(channel.Id, 2, 300)
+
+	_, idx, err := channel.GetNextEnabledKey()
+	require.Nil(t, err)
+	assert.Equal(t, 2, idx)
+
+	// 超出 TPM 预算的 Key 不再被选中；上游剩余 2/10 请求仍优于已用 900/1000 Token
+	RecordMultiKeyTokens(channel.Id, 2, 700)
+	_, idx, err = channel.GetNextEnabledKey()
+	require.Nil(t, err)
+	assert.Equal(t, 1, idx)
+
+	states := GetMultiKeyRuntimeStates(channel.Id)
+	assert.Equal(t, int64(1), states[1].Requests)
+	assert.Equal(t, int64(1000), states[2].Tokens)
+
+	// 被禁用的 Key 不参与调度
+	channel.ChannelInfo.MultiKeyStatusList = map[int]int{1: common.ChannelStatusManuallyDisabled}
+	_, idx, err = channel.GetNextEnabledKey()
+	require.Nil(t, err)
+	assert.Equal(t, 0, idx)
+}
+
+func TestClaimMultiKeyEnforcesRPMLimit(t *testing.T) {
+	channelId := 90003
+	t.Cleanup(func() { ResetMultiKeyRuntimeStates(channelId) })
+
+	now := time.Now()
+	assert.True(t, claimMultiKey(channelId, 0, 2, now))
+	assert.True(t, claimMultiKey(channelId, 0, 2, now))
+	// 其他节点读取到的旧状态可能仍显示有余量，占用时必须重新校验
+	assert.False(t, claimMultiKey(channelId, 0, 2, now))
+
+	ParkMultiKey(channelId, 1, now.Add(time.Minute).Unix(), "upstream 429")
+	assert.False(t, claimMultiKey(channelId, 1, 0, now))
+	assert.True(t, claimMultiKey(channelId, 2, 0, now))
+}
+
+func TestClaimMultiKeyFallsBackToLocalLimiterWhenRedisFails(t *testing.T) {
+	channelId := 90004
+	t.Cleanup(func() { ResetMultiKeyRuntimeStates(channelId) })
+
+	oldRedisEnabled, oldRDB := common.RedisEnabled, common.RDB
+	common.RedisEnabled = true
+	common.RDB = redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("forced redis failure")
+		},
+		MaxRetries: -1,
+	})
+	t.Cleanup(func() {
+		_ = common.RDB.Close()
+		common.RedisEnabled, common.RDB = oldRedisEnabled, oldRDB
+	})
+
+	now := time.Now()
+	assert.True(t, claimMultiKey(channelId, 0, 2, now))
+	assert.True(t, claimMultiKey(channelId, 0, 2, now))
+	assert.False(t, claimMultiKey(channelId, 0, 2, now), "a Redis outage must not lift the RPM limit")
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 上游限流响应头快照（x-ratelimit-*、anthropic-ratelimit-*）。
+// 每个渠道按 Key 索引保存最近一次响应的剩余额度，单 Key 渠道固定使用索引 0。
+// 快照只保存在本机内存：响应头反映的是上游的全局额度，各节点各自观测即可。
+
+// upstreamRateLimitDefaultTTL 是响应头未给出重置时间时快照的有效期
+const upstreamRateLimitDefaultTTL = time.Minute
+
+// upstreamRateLimitPruneInterval 是清理过期快照的最小间隔
+const upstreamRateLimitPruneInterval = time.Minute
+
+// UpstreamRateLimitSnapshot 是某个 Key 最近一次上游响应中的限流信息，未返回的字段为空
+type UpstreamRateLimitSnapshot struct {
+	LimitRequests     *int64 `json:"limit_requests,omitempty"`
+	RemainingRequests *int64 `json:"remaining_requests,omitempty"`
+	ResetRequestsAt   int64  `json:"reset_requests_at,omitempty"` // unix ms
+	LimitTokens       *int64 `json:"limit_tokens,omitempty"`
+	RemainingTokens   *int64 `json:"remaining_tokens,omitempty"`
+	ResetTokensAt     int64  `json:"reset_tokens_at,omitempty"` // unix ms
+	UpdatedAt         int64  `json:"updated_at"`                // unix ms
+}
+
+// dimensionHeadroom 返回单个维度的剩余比例；快照已过重置时间或无法判断时 ok 为 false
+func (s *UpstreamRateLimitSnapshot) dimensionHeadroom(limit *int64, remaining *int64, resetAt int64, now time.Time) (float64, bool) {
+	if remaining == nil {
+		return 0, false
+	}
+	if resetAt == 0 {
+		resetAt = s.UpdatedAt + upstreamRateLimitDefaultTTL.Milliseconds()
+	}
+	if resetAt <= now.UnixMilli() {
+		return 0, false
+	}
+	if limit != nil && *limit > 0 {
+		return math.Max(float64(*remaining)/float64(*limit), 0), true
+	}
+	if *remaining <= 0 {
+		return 0, true
+	}
+	return 0, false
+}
+
+// expired 返回快照的所有维度是否都已过重置时间，过期快照不再影响渠道选择
+func (s *UpstreamRateLimitSnapshot) expired(now time.Time) bool {
+	expiresAt := s.UpdatedAt + upstreamRateLimitDefaultTTL.Milliseconds()
+	expiresAt = max(expiresAt, s.ResetRequestsAt, s.ResetTokensAt)
+	return expiresAt <= now.UnixMilli()
+}
+
+// Headroom 返回 now 时刻剩余额度的比例（0~1），取请求数与 Token 数中较紧张的一项；
+// 没有有效信息时返回 1
+func (s *UpstreamRateLimitSnapshot) Headroom(now time.Time) float64 {
+	headroom := 1.0
+	if s == nil {
+		return headroom
+	}
+	if h, ok := s.dimensionHeadroom(s.LimitRequests, s.RemainingRequests, s.ResetRequestsAt, now); ok {
+		headroom = math.Min(headroom, h)
+	}
+	if h, ok := s.dimensionHeadroom(s.LimitTokens, s.RemainingTokens, s.ResetTokensAt, now); ok {
+		headroom = math.Min(headroom, h)
+	}
+	return headroom
+}
+
+// ChannelUpstreamRateLimit 是渠道详情接口中返回的上游限流状态
+type ChannelUpstreamRateLimit struct {
+	Headroom float64                            `json:"headroom"`
+	Latest   *UpstreamRateLimitSnapshot         `json:"latest,omitempty"`
+	Keys     map[int]*UpstreamRateLimitSnapshot `json:"keys,omitempty"` // 多 Key 渠道：key index -> 快照
+}
+
+var (
+	upstreamRateLimits         = make(map[int]map[int]*UpstreamRateLimitSnapshot) // channel id -> key index -> 快照
+	upstreamRateLimitsLock     sync.RWMutex
+	upstreamRateLimitsPrunedAt time.Time
+)
+
+// RecordUpstreamRateLimit 保存渠道某个 Key 最新的上游限流快照，并顺带清理已过期的快照，
+// 避免已删除的渠道或 Key 的快照一直留在内存中
+func RecordUpstreamRateLimit(channelId int, keyIndex int, snapshot *UpstreamRateLimitSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	upstreamRateLimitsLock.Lock()
+	defer upstreamRateLimitsLock.Unlock()
+	now := time.Now()
+	if now.Sub(upstreamRateLimitsPrunedAt) >= upstreamRateLimitPruneInterval {
+		pruneUpstreamRateLimitsLocked(now)
+	}
+	keys, ok := upstreamRateLimits[channelId]
+	if !ok {
+		keys = make(map[int]*UpstreamRateLimitSnapshot)
+		upstreamRateLimits[channelId] = keys
+	}
+	keys[keyIndex] = snapshot
+}
+
+// pruneUpstreamRateLimitsLocked 删除已过期的快照，调用方需持有写锁
+func pruneUpstreamRateLimitsLocked(now time.Time) {
+	upstreamRateLimitsPrunedAt = now
+	for channelId, keys := range upstreamRateLimits {
+		for keyIndex, snapshot := range keys {
+			if snapshot.expired(now) {
+				delete(keys, keyIndex)
+			}
+		}
+		if len(keys) == 0 {
+			delete(upstreamRateLimits, channelId)
+		}
+	}
+}
+
+// ClearUpstreamRateLimit 清空渠道的上游限流快照；删除多 Key 渠道的 Key 后索引会重新排列
+func ClearUpstreamRateLimit(channelId int) {
+	upstreamRateLimitsLock.Lock()
+	defer upstreamRateLimitsLock.Unlock()
+	delete(upstreamRateLimits, channelId)
+}
+
+// GetChannelUpstreamHeadroom 返回渠道在 now 时刻的剩余额度比例。
+// 多 Key 渠道取各 Key 的平均值，没有观测到限流信息的 Key 按 1 计算。
+func GetChannelUpstreamHeadroom(channel *Channel, now time.Time) float64 {
+	upstreamRateLimitsLock.RLock()
+	defer upstreamRateLimitsLock.RUnlock()
+	return channelUpstreamHeadroomLocked(channel, upstreamRateLimits[channel.Id], now)
+}
+
+func channelUpstreamHeadroomLocked(channel *Channel, keys map[int]*UpstreamRateLimitSnapshot, now time.Time) float64 {
+	if len(keys) == 0 {
+		return 1
+	}
+	if !channel.ChannelInfo.IsMultiKey || channel.ChannelInfo.MultiKeySize <= 1 {
+		return keys[0].Headroom(now)
+	}
+	total := 0.0
+	for i := 0; i < channel.ChannelInfo.MultiKeySize; i++ {
+		total += keys[i].Headroom(now)
+	}
+	return total / float64(channel.ChannelInfo.MultiKeySize)
+}
+
+// GetChannelUpstreamRateLimit 返回渠道的上游限流状态，没有观测到任何限流信息时返回 nil
+func GetChannelUpstreamRateLimit(channel *Channel) *ChannelUpstreamRateLimit {
+	upstreamRateLimitsLock.RLock()
+	defer upstreamRateLimitsLock.RUnlock()
+	keys := upstreamRateLimits[channel.Id]
+	if len(keys) == 0 {
+		return nil
+	}
+	now := time.Now()
+	result := &ChannelUpstreamRateLimit{
+		Headroom: channelUpstreamHeadroomLocked(channel, keys, now),
+	}
+	for _, snapshot := range keys {
+		if result.Latest == nil || snapshot.UpdatedAt > result.Latest.UpdatedAt {
+			copied := *snapshot
+			result.Latest = &copied
+		}
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		result.Keys = make(map[int]*UpstreamRateLimitSnapshot, len(keys))
+		for index, snapshot := range keys {
+			copied := *snapshot
+			result.Keys[index] = &copied
+		}
+	}
+	return result
+}
+
+// upstreamRateLimitWeightFactor 返回渠道权重的缩放系数：剩余额度低于阈值时按比例降权，
+// 耗尽时为 0；未开启限流引导时恒为 1
+func upstreamRateLimitWeightFactor(channel *Channel, now time.Time) float64 {
+	setting := operation_setting.GetChannelSelectionSetting()
+	if !setting.RateLimitSteeringEnabled {
+		return 1
+	}
+	headroom := GetChannelUpstreamHeadroom(channel, now)
+	if headroom <= 0 {
+		return 0
+	}
+	threshold := setting.RateLimitHeadroomThreshold
+	if threshold <= 0 || headroom >= threshold {
+		return 1
+	}
+	return headroom / threshold
+}
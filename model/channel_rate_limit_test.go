@@ -0,0 +1,110 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamRateLimitSnapshotHeadroom(t *testing.T) {
+	now := time.Now()
+	snapshot := &UpstreamRateLimitSnapshot{
+		LimitRequests:     common.GetPointer(int64(100)),
+		RemainingRequests: common.GetPointer(int64(50)),
+		ResetRequestsAt:   now.Add(time.Minute).UnixMilli(),
+		LimitTokens:       common.GetPointer(int64(1000)),
+		RemainingTokens:   common.GetPointer(int64(100)),
+		ResetTokensAt:     now.Add(time.Second).UnixMilli(),
+		UpdatedAt:         now.UnixMilli(),
+	}
+	assert.InDelta(t, 0.1, snapshot.Headroom(now), 1e-9)
+	// Token 额度重置后只看请求数
+	assert.InDelta(t, 0.5, snapshot.Headroom(now.Add(2*time.Second)), 1e-9)
+	// 全部重置后视为满额
+	assert.Equal(t, 1.0, snapshot.Headroom(now.Add(2*time.Minute)))
+
+	// 只返回剩余数时仅能识别耗尽
+	exhausted := &UpstreamRateLimitSnapshot{RemainingTokens: common.GetPointer(int64(0)), UpdatedAt: now.UnixMilli()}
+	assert.Equal(t, 0.0, exhausted.Headroom(now))
+	assert.Equal(t, 1.0, exhausted.Headroom(now.Add(upstreamRateLimitDefaultTTL)))
+}
+
+func TestPickWeightedChannelAvoidsExhaustedChannels(t *testing.T) {
+	setting := operation_setting.GetChannelSelectionSetting()
+	oldSteering := setting.RateLimitSteeringEnabled
+	setting.RateLimitSteeringEnabled = true
+	t.Cleanup(func() { setting.RateLimitSteeringEnabled = oldSteering })
+
+	full := &Channel{Id: 91001, Weight: common.GetPointer(uint(1))}
+	exhausted := &Channel{Id: 91002, Weight: common.GetPointer(uint(100))}
+	multiKey := &Channel{Id: 91003, ChannelInfo: ChannelInfo{IsMultiKey: true, MultiKeySize: 2}}
+	t.Cleanup(func() {
+		ClearUpstreamRateLimit(full.Id)
+		ClearUpstreamRateLimit(exhausted.Id)
+		ClearUpstreamRateLimit(multiKey.Id)
+	})
+
+	now := time.Now()
+	RecordUpstreamRateLimit(exhausted.Id, 0, &UpstreamRateLimitSnapshot{
+		LimitRequests:     common.GetPointer(int64(100)),
+		RemainingRequests: common.GetPointer(int64(0)),
+		ResetRequestsAt:   now.Add(time.Minute).UnixMilli(),
+		UpdatedAt:         now.UnixMilli(),
+	})
+	for i := 0; i < 20; i++ {
+		channel, err := pickWeightedChannel([]*Channel{full, exhausted})
+		require.NoError(t, err)
+		assert.Equal(t, full.Id, channel.Id)
+	}
+
+	// 所有候选都耗尽时退回普通权重
+	RecordUpstreamRateLimit(full.Id, 0, &UpstreamRateLimitSnapshot{
+		RemainingRequests: common.GetPointer(int64(0)),
+		UpdatedAt:         now.UnixMilli(),
+	})
+	channel, err := pickWeightedChannel([]*Channel{full, exhausted})
+	require.NoError(t, err)
+	assert.NotNil(t, channel)
+
+	// 多 Key 渠道取各 Key 的平均值，未观测的 Key 按满额计算
+	RecordUpstreamRateLimit(multiKey.Id, 1, &UpstreamRateLimitSnapshot{
+		LimitTokens:     common.GetPointer(int64(1000)),
+		RemainingTokens: common.GetPointer(int64(200)),
+		UpdatedAt:       now.UnixMilli(),
+	})
+	assert.InDelta(t, 0.6, GetChannelUpstreamHeadroom(multiKey, now), 1e-9)
+	rateLimit := GetChannelUpstreamRateLimit(multiKey)
+	require.NotNil(t, rateLimit)
+	assert.Len(t, rateLimit.Keys, 1)
+	assert.Equal(t, int64(200), *rateLimit.Latest.RemainingTokens)
+	assert.Nil(t, GetChannelUpstreamRateLimit(&Channel{Id: 91004}))
+}
+
+func TestRecordUpstreamRateLimitPrunesExpiredSnapshots(t *testing.T) {
+	stale := &Channel{Id: 91101}
+	fresh := &Channel{Id: 91102}
+	t.Cleanup(func() {
+		ClearUpstreamRateLimit(stale.Id)
+		ClearUpstreamRateLimit(fresh.Id)
+	})
+
+	now := time.Now()
+	RecordUpstreamRateLimit(stale.Id, 0, &UpstreamRateLimitSnapshot{
+		RemainingRequests: common.GetPointer(int64(0)),
+		UpdatedAt:         now.Add(-2 * upstreamRateLimitDefaultTTL).UnixMilli(),
+	})
+	upstreamRateLimitsLock.Lock()
+	upstreamRateLimitsPrunedAt = time.Time{}
+	upstreamRateLimitsLock.Unlock()
+
+	RecordUpstreamRateLimit(fresh.Id, 0, &UpstreamRateLimitSnapshot{
+		RemainingRequests: common.GetPointer(int64(5)),
+		UpdatedAt:         now.UnixMilli(),
+	})
+	assert.Nil(t, GetChannelUpstreamRateLimit(stale))
+	assert.NotNil(t, GetChannelUpstreamRateLimit(fresh))
+}
@@ -0,0 +1,325 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ChannelScheduleKindRecurring 周期性时间窗口：每次 Cron 命中后生效 DurationMinutes 分钟
+	ChannelScheduleKindRecurring = "recurring"
+	// ChannelScheduleKindMaintenance 一次性维护窗口：[StartTime, EndTime) 内生效，结束后自动停用
+	ChannelScheduleKindMaintenance = "maintenance"
+
+	ChannelScheduleTargetChannel = "channel"
+	ChannelScheduleTargetTag     = "tag"
+
+	ChannelScheduleActionDisable = "disable"
+	ChannelScheduleActionEnable  = "enable"
+	ChannelScheduleActionAdjust  = "adjust" // 调整优先级 / 权重
+)
+
+// ChannelSchedule 渠道时间窗口规则。窗口生效时对目标渠道（单个渠道或整个标签）
+// 启用/禁用或调整优先级、权重，窗口结束后按生效前的快照恢复。
+type ChannelSchedule struct {
+	Id              int     `json:"id"`
+	Name            string  `json:"name" gorm:"type:varchar(64)"`
+	Kind            string  `json:"kind" gorm:"type:varchar(32);default:'recurring'"`
+	TargetType      string  `json:"target_type" gorm:"type:varchar(32)"`
+	ChannelId       int     `json:"channel_id" gorm:"index"`
+	Tag             string  `json:"tag" gorm:"type:varchar(255);index"`
+	Action          string  `json:"action" gorm:"type:varchar(32)"`
+	Priority        *int64  `json:"priority" gorm:"bigint"`
+	Weight          *uint   `json:"weight"`
+	Cron            string  `json:"cron" gorm:"type:varchar(128)"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Timezone        string  `json:"timezone" gorm:"type:varchar(64)"`
+	StartTime       int64   `json:"start_time" gorm:"bigint"`
+	EndTime         int64   `json:"end_time" gorm:"bigint"`
+	Enabled         bool    `json:"enabled" gorm:"index"`
+	Active          bool    `json:"active" gorm:"default:false;index"`
+	Snapshot        string  `json:"-" gorm:"type:text"`
+	LastAppliedTime int64   `json:"last_applied_time" gorm:"bigint"`
+	Remark          *string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedTime     int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime     int64   `json:"updated_time" gorm:"bigint"`
+}
+
+// channelScheduleSnapshot 记录窗口生效前渠道的原始状态，用于窗口结束后恢复
+type channelScheduleSnapshot struct {
+	Status   int    `json:"status"`
+	Priority *int64 `json:"priority"`
+	Weight   *uint  `json:"weight"`
+}
+
+func (s *ChannelSchedule) Insert() error {
+	now := common.GetTimestamp()
+	s.CreatedTime = now
+	s.UpdatedTime = now
+	s.Active = false
+	s.Snapshot = ""
+	defer invalidateSchedulableCache()
+	return DB.Create(s).Error
+}
+
+// Update 更新规则定义，不覆盖运行时状态（active / snapshot）
+func (s *ChannelSchedule) Update() error {
+	s.UpdatedTime = common.GetTimestamp()
+	defer invalidateSchedulableCache()
+	return DB.Model(&ChannelSchedule{}).Where("id = ?", s.Id).
+		Select("name", "target_type", "channel_id", "tag", "action", "priority", "weight",
+			"cron", "duration_minutes", "timezone", "start_time", "end_time", "enabled", "remark", "updated_time").
+		Updates(s).Error
+}
+
+func GetChannelScheduleById(id int) (*ChannelSchedule, error) {
+	var schedule ChannelSchedule
+	if err := DB.First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetChannelSchedules 获取规则列表，channelId / tag 非空时按目标过滤
+func GetChannelSchedules(channelId int, tag string) ([]*ChannelSchedule, error) {
+	var schedules []*ChannelSchedule
+	query := DB.Model(&ChannelSchedule{})
+	if channelId > 0 {
+		query = query.Where("channel_id = ?", channelId)
+	}
+	if tag != "" {
+		query = query.Where("tag = ?", tag)
+	}
+	err := query.Order("id desc").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetSchedulableChannelSchedules 返回需要调度器评估的规则：已启用的，或仍处于生效状态需要恢复的
+func GetSchedulableChannelSchedules() ([]*ChannelSchedule, error) {
+	var schedules []*ChannelSchedule
+	err := DB.Where("enabled = ? OR active = ?", true, true).Order("id asc").Find(&schedules).Error
+	return schedules, err
+}
+
+// schedulableCacheTTL 限制其他节点上的规则变更最多延迟多久被感知
+const schedulableCacheTTL = time.Minute
+
+var schedulableCache struct {
+	sync.Mutex
+	value     bool
+	expiresAt time.Time
+}
+
+// HasSchedulableChannelSchedules 报告是否存在需要调度器评估的规则。结果缓存 schedulableCacheTTL，
+// 避免调度器每次轮询都查询数据库；本节点修改规则时会立即失效
+func HasSchedulableChannelSchedules() bool {
+	schedulableCache.Lock()
+	defer schedulableCache.Unlock()
+	if time.Now().Before(schedulableCache.expiresAt) {
+		return schedulableCache.value
+	}
+	var count int64
+	if err := DB.Model(&ChannelSchedule{}).Where("enabled = ? OR active = ?", true, true).Count(&count).Error; err != nil {
+		return false
+	}
+	schedulableCache.value = count > 0
+	schedulableCache.expiresAt = time.Now().Add(schedulableCacheTTL)
+	return schedulableCache.value
+}
+
+func invalidateSchedulableCache() {
+	schedulableCache.Lock()
+	schedulableCache.expiresAt = time.Time{}
+	schedulableCache.Unlock()
+}
+
+func DeleteChannelScheduleById(id int) error {
+	defer invalidateSchedulableCache()
+	return DB.Delete(&ChannelSchedule{}, id).Error
+}
+
+func (s *ChannelSchedule) targetChannels(tx *gorm.DB) ([]*Channel, error) {
+	var channels []*Channel
+	query := tx.Select("id", "status", "priority", "weight", "tag")
+	switch s.TargetType {
+	case ChannelScheduleTargetTag:
+		query = query.Where("tag = ?", s.Tag)
+	default:
+		query = query.Where("id = ?", s.ChannelId)
+	}
+	err := query.Find(&channels).Error
+	return channels, err
+}
+
+// ApplyChannelSchedule 在单个事务内使规则生效（activate=true）或恢复（activate=false），
+// 同时更新渠道与 abilities。规则行上的 active 字段做条件更新，多节点并发调用时只有一个会成功；
+// 返回 false 表示状态已被其他调用切换，本次未做任何修改。
+func ApplyChannelSchedule(schedule *ChannelSchedule, activate bool) (bool, error) {
+	if schedule == nil {
+		return false, errors.New("schedule is nil")
+	}
+	applied := false
+	snapshotText := ""
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := common.GetTimestamp()
+		var snapshots map[int]channelScheduleSnapshot
+		if activate {
+			channels, err := schedule.targetChannels(tx)
+			if err != nil {
+				return err
+			}
+			snapshots = make(map[int]channelScheduleSnapshot, len(channels))
+			for _, channel := range channels {
+				snapshots[channel.Id] = channelScheduleSnapshot{
+					Status:   channel.Status,
+					Priority: channel.Priority,
+					Weight:   channel.Weight,
+				}
+				if err := schedule.activateChannel(tx, channel); err != nil {
+					return err
+				}
+			}
+		} else {
+			if schedule.Snapshot != "" {
+				if err := common.UnmarshalJsonStr(schedule.Snapshot, &snapshots); err != nil {
+					return fmt.Errorf("invalid channel schedule snapshot: %w", err)
+				}
+			}
+			for channelId, snapshot := range snapshots {
+				if err := schedule.restoreChannel(tx, channelId, snapshot); err != nil {
+					return err
+				}
+			}
+			snapshots = nil
+		}
+
+		if snapshots != nil {
+			data, err := common.Marshal(snapshots)
+			if err != nil {
+				return err
+			}
+			snapshotText = string(data)
+		}
+		updates := map[string]interface{}{
+			"active":            activate,
+			"snapshot":          snapshotText,
+			"last_applied_time": now,
+		}
+		// 一次性维护窗口结束后自动停用
+		if !activate && schedule.Kind == ChannelScheduleKindMaintenance {
+			updates["enabled"] = false
+		}
+		result := tx.Model(&ChannelSchedule{}).Where("id = ? AND active = ?", schedule.Id, !activate).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errChannelScheduleStateChanged
+		}
+		applied = true
+		return nil
+	})
+	if errors.Is(err, errChannelScheduleStateChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	schedule.Active = activate
+	schedule.Snapshot = snapshotText
+	invalidateSchedulableCache()
+	return applied, nil
+}
+
+var errChannelScheduleStateChanged = errors.New("channel schedule state changed concurrently")
+
+func (s *ChannelSchedule) activateChannel(tx *gorm.DB, channel *Channel) error {
+	switch s.Action {
+	case ChannelScheduleActionDisable:
+		if channel.Status != common.ChannelStatusEnabled {
+			return nil
+		}
+		return setChannelScheduleStatus(tx, channel.Id, common.ChannelStatusManuallyDisabled)
+	case ChannelScheduleActionEnable:
+		if channel.Status == common.ChannelStatusEnabled {
+			return nil
+		}
+		return setChannelScheduleStatus(tx, channel.Id, common.ChannelStatusEnabled)
+	case ChannelScheduleActionAdjust:
+		return setChannelSchedulePriorityWeight(tx, channel.Id, s.Priority, s.Weight)
+	}
+	return fmt.Errorf("unknown channel schedule action: %s", s.Action)
+}
+
+// restoreChannel 恢复窗口生效前的值；若管理员在窗口期间手动修改过对应字段，则保留手动修改
+func (s *ChannelSchedule) restoreChannel(tx *gorm.DB, channelId int, snapshot channelScheduleSnapshot) error {
+	var channel Channel
+	err := tx.Select("id", "status", "priority", "weight").First(&channel, "id = ?", channelId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch s.Action {
+	case ChannelScheduleActionDisable:
+		if channel.Status == common.ChannelStatusManuallyDisabled && snapshot.Status == common.ChannelStatusEnabled {
+			return setChannelScheduleStatus(tx, channelId, snapshot.Status)
+		}
+	case ChannelScheduleActionEnable:
+		if channel.Status == common.ChannelStatusEnabled && snapshot.Status != common.ChannelStatusEnabled {
+			return setChannelScheduleStatus(tx, channelId, snapshot.Status)
+		}
+	case ChannelScheduleActionAdjust:
+		var priority *int64
+		var weight *uint
+		if s.Priority != nil && channel.GetPriority() == *s.Priority {
+			priority = common.GetPointer(int64(0))
+			if snapshot.Priority != nil {
+				priority = snapshot.Priority
+			}
+		}
+		if s.Weight != nil && uint(channel.GetWeight()) == *s.Weight {
+			weight = common.GetPointer(uint(0))
+			if snapshot.Weight != nil {
+				weight = snapshot.Weight
+			}
+		}
+		return setChannelSchedulePriorityWeight(tx, channelId, priority, weight)
+	}
+	return nil
+}
+
+func setChannelScheduleStatus(tx *gorm.DB, channelId int, status int) error {
+	if err := tx.Model(&Channel{}).Where("id = ?", channelId).Update("status", status).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Ability{}).Where("channel_id = ?", channelId).Select("enabled").
+		Update("enabled", status == common.ChannelStatusEnabled).Error
+}
+
+func setChannelSchedulePriorityWeight(tx *gorm.DB, channelId int, priority *int64, weight *uint) error {
+	channelUpdates := map[string]interface{}{}
+	abilityUpdates := map[string]interface{}{}
+	if priority != nil {
+		channelUpdates["priority"] = *priority
+		abilityUpdates["priority"] = *priority
+	}
+	if weight != nil {
+		channelUpdates["weight"] = *weight
+		abilityUpdates["weight"] = *weight
+	}
+	if len(channelUpdates) == 0 {
+		return nil
+	}
+	if err := tx.Model(&Channel{}).Where("id = ?", channelId).Updates(channelUpdates).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Ability{}).Where("channel_id = ?", channelId).Updates(abilityUpdates).Error
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyChannelScheduleDisableAndRestore(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Channel{Id: 1, Name: "a", Tag: common.GetPointer("night"), Status: common.ChannelStatusEnabled}).Error)
+	require.NoError(t, DB.Create(&Channel{Id: 2, Name: "b", Tag: common.GetPointer("night"), Status: common.ChannelStatusAutoDisabled}).Error)
+	require.NoError(t, DB.Create(&Ability{Group: "default", Model: "gpt", ChannelId: 1, Enabled: true}).Error)
+
+	schedule := &ChannelSchedule{
+		Name:       "night",
+		TargetType: ChannelScheduleTargetTag,
+		Tag:        "night",
+		Action:     ChannelScheduleActionDisable,
+		Cron:       "0 22 * * *",
+		Enabled:    true,
+	}
+	require.NoError(t, schedule.Insert())
+
+	applied, err := ApplyChannelSchedule(schedule, true)
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	var channel Channel
+	require.NoError(t, DB.First(&channel, 1).Error)
+	assert.Equal(t, common.ChannelStatusManuallyDisabled, channel.Status)
+	var ability Ability
+	require.NoError(t, DB.First(&ability, "channel_id = ?", 1).Error)
+	assert.False(t, ability.Enabled)
+
+	// 重复切换同一状态不生效
+	stale := *schedule
+	stale.Active = false
+	applied, err = ApplyChannelSchedule(&stale, true)
+	require.NoError(t, err)
+	assert.False(t, applied)
+
+	reloaded, err := GetChannelScheduleById(schedule.Id)
+	require.NoError(t, err)
+	applied, err = ApplyChannelSchedule(reloaded, false)
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	require.NoError(t, DB.First(&channel, 1).Error)
+	assert.Equal(t, common.ChannelStatusEnabled, channel.Status)
+	require.NoError(t, DB.First(&ability, "channel_id = ?", 1).Error)
+	assert.True(t, ability.Enabled)
+	// 窗口生效前已被自动禁用的渠道保持原状
+	var untouched Channel
+	require.NoError(t, DB.First(&untouched, 2).Error)
+	assert.Equal(t, common.ChannelStatusAutoDisabled, untouched.Status)
+}
+
+func TestApplyChannelScheduleAdjustKeepsManualChanges(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Channel{Id: 1, Name: "a", Status: common.ChannelStatusEnabled,
+		Priority: common.GetPointer(int64(5)), Weight: common.GetPointer(uint(10))}).Error)
+
+	schedule := &ChannelSchedule{
+		Name:       "off-peak",
+		TargetType: ChannelScheduleTargetChannel,
+		ChannelId:  1,
+		Action:     ChannelScheduleActionAdjust,
+		Priority:   common.GetPointer(int64(100)),
+		Weight:     common.GetPointer(uint(50)),
+		Cron:       "0 1 * * *",
+		Enabled:    true,
+	}
+	require.NoError(t, schedule.Insert())
+	applied, err := ApplyChannelSchedule(schedule, true)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	// 窗口期间管理员手动修改了权重，恢复时应保留
+	require.NoError(t, DB.Model(&Channel{}).Where("id = ?", 1).Update("weight", 20).Error)
+
+	applied, err = ApplyChannelSchedule(schedule, false)
+	require.NoError(t, err)
+	require.True(t, applied)
+
+	var channel Channel
+	require.NoError(t, DB.First(&channel, 1).Error)
+	assert.Equal(t, int64(5), channel.GetPriority())
+	assert.Equal(t, 20, channel.GetWeight())
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// configBundleChannelFields are the channel columns managed by declarative
+// config apply. Accounting and probe fields (used_quota, balance, response
+// time, ...) are never touched so concurrent traffic is not overwritten.
+var configBundleChannelFields = []string{
+	"Type", "Key", "OpenAIOrganization", "TestModel", "Status", "Name", "Weight",
+	"BaseURL", "Other", "Models", "Group", "ModelMapping", "StatusCodeMapping",
+	"Priority", "AutoBan", "Tag", "Setting", "ParamOverride", "HeaderOverride",
+	"Remark", "ChannelInfo", "OtherSettings",
+}
+
+// ConfigBundleChanges is a fully resolved set of writes produced by a config
+// bundle plan.
+type ConfigBundleChanges struct {
+	Creates []*Channel
+	Updates []*Channel
+	Deletes []int
+	Options map[string]string
+	// OperatorId is recorded in the option revision history.
+	OperatorId int
+}
+
+// ApplyConfigBundleChanges writes channels, their abilities and option rows in
+// a single transaction. In-memory options are refreshed only after commit; the
+// caller is responsible for reloading the channel cache.
+func ApplyConfigBundleChanges(changes *ConfigBundleChanges) error {
+	for key, value := range changes.Options {
+		if err := validateOptionValue(key, value); err != nil {
+			return err
+		}
+	}
+	for _, channel := range append(append([]*Channel{}, changes.Creates...), changes.Updates...) {
+		if channel.ChannelInfo.IsMultiKey {
+			channel.ChannelInfo.MultiKeySize = len(channel.GetKeys())
+			for idx := range channel.ChannelInfo.MultiKeyStatusList {
+				if idx >= channel.ChannelInfo.MultiKeySize {
+					delete(channel.ChannelInfo.MultiKeyStatusList, idx)
+				}
+			}
+		}
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range changes.Deletes {
+			if err := tx.Delete(&Channel{Id: id}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("channel_id = ?", id).Delete(&Ability{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, channel := range changes.Updates {
+			if err := tx.Model(channel).Select(configBundleChannelFields).Updates(channel).Error; err != nil {
+				return err
+			}
+			if err := channel.UpdateAbilities(tx); err != nil {
+				return err
+			}
+		}
+		for _, channel := range changes.Creates {
+			if err := tx.Create(channel).Error; err != nil {
+				return err
+			}
+			if err := channel.AddAbilities(tx); err != nil {
+				return err
+			}
+		}
+		return saveOptionsTx(tx, changes.Options, OptionChangeMeta{
+			OperatorId: changes.OperatorId,
+			Source:     OptionChangeSourceConfigApply,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	refreshOptionMap(changes.Options)
+	return nil
+}
@@ -1,8 +1,16 @@
 package model
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
 	"strings"
 	"time"
 
@@ -36,9 +44,54 @@ var supportedAccessPolicyOps = map[string]struct{}{
 	"not_exists":   {},
 }
 
+type claimMappingPayload struct {
+	Name       string               `json:"name"`
+	When       *accessPolicyPayload `json:"when"`
+	Group      string               `json:"group"`
+	Role       string               `json:"role"`
+	AuthzRoles []string             `json:"authz_roles"`
+	Quota      int                  `json:"quota"`
+}
+
+// System roles a claim mapping may assign. Root is never granted or revoked
+// by a mapping.
+const (
+	ClaimMappingRoleUser  = "user"
+	ClaimMappingRoleAdmin = "admin"
+)
+
+// ClaimMappingRoleValue converts a mapping role name to the system role, 0
+// when the name is unknown or empty.
+func ClaimMappingRoleValue(role string) int {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case ClaimMappingRoleUser:
+		return common.RoleCommonUser
+	case ClaimMappingRoleAdmin:
+		return common.RoleAdminUser
+	}
+	return 0
+}
+
+// Custom provider kinds. SAML providers reuse the field mapping, access policy
+// and user_oauth_bindings of OAuth providers; only the login ceremony differs.
+const (
+	CustomOAuthProviderKindOAuth = "oauth"
+	CustomOAuthProviderKindSAML  = "saml"
+)
+
+// Default attribute mapping for SAML providers. "NameID" refers to the subject
+// NameID of the assertion, the others to attribute Name or FriendlyName.
+const (
+	SamlDefaultUserIdField      = "NameID"
+	SamlDefaultUsernameField    = "uid"
+	SamlDefaultDisplayNameField = "displayName"
+	SamlDefaultEmailField       = "email"
+)
+
 // CustomOAuthProvider stores configuration for custom OAuth providers
 type CustomOAuthProvider struct {
 	Id                    int    `json:"id" gorm:"primaryKey"`
+	Kind                  string `json:"kind" gorm:"type:varchar(16);default:'oauth'"`                   // "oauth" or "saml"
 	Name                  string `json:"name" gorm:"type:varchar(64);not null"`                          // Display name, e.g., "GitHub Enterprise"
 	Slug                  string `json:"slug" gorm:"type:varchar(64);uniqueIndex;not null"`              // URL identifier, e.g., "github-enterprise"
 	Icon                  string `json:"icon" gorm:"type:varchar(128);default:''"`                       // Icon name from @lobehub/icons
@@ -61,6 +114,13 @@ type CustomOAuthProvider struct {
 	AuthStyle           int    `json:"auth_style" gorm:"default:0"`                    // 0=auto, 1=params, 2=header (Basic Auth)
 	AccessPolicy        string `json:"access_policy" gorm:"type:text"`                 // JSON policy for access control based on user info
 	AccessDeniedMessage string `json:"access_denied_message" gorm:"type:varchar(512)"` // Custom error message template when access is denied
+	ClaimMappings       string `json:"claim_mappings" gorm:"type:text"`                // JSON rules mapping user info claims to group, role, authz roles and quota
+
+	// SAML options (kind = saml). ClientId doubles as the SP entity ID.
+	SamlIdpMetadataUrl string `json:"saml_idp_metadata_url" gorm:"type:varchar(512)"` // IdP metadata URL, refreshed periodically
+	SamlIdpMetadata    string `json:"saml_idp_metadata" gorm:"type:text"`             // Inline IdP metadata XML, used when no URL is set
+	SamlSpCertificate  string `json:"saml_sp_certificate" gorm:"type:text"`           // PEM certificate used to sign AuthnRequests
+	SamlSpPrivateKey   string `json:"-" gorm:"type:text"`                             // PEM private key of the SP certificate (not returned to frontend)
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -70,6 +130,11 @@ func (CustomOAuthProvider) TableName() string {
 	return "custom_oauth_providers"
 }
 
+// IsSAML reports whether the provider is a SAML 2.0 identity provider
+func (p *CustomOAuthProvider) IsSAML() bool {
+	return p.Kind == CustomOAuthProviderKindSAML
+}
+
 // GetAllCustomOAuthProviders returns all custom OAuth providers
 func GetAllCustomOAuthProviders() ([]*CustomOAuthProvider, error) {
 	var providers []*CustomOAuthProvider
@@ -126,6 +191,10 @@ func DeleteCustomOAuthProvider(id int) error {
 	if err := DB.Where("provider_id = ?", id).Delete(&UserOAuthBinding{}).Error; err != nil {
 		return err
 	}
+	// Entitlements already granted stay with the users; only the ledger goes
+	if err := DB.Where("provider_id = ?", id).Delete(&OAuthClaimGrant{}).Error; err != nil {
+		return err
+	}
 	return DB.Delete(&CustomOAuthProvider{}, id).Error
 }
 
@@ -162,6 +231,39 @@ func validateCustomOAuthProvider(provider *CustomOAuthProvider) error {
 	}
 	provider.Slug = slug
 
+	provider.Kind = strings.ToLower(strings.TrimSpace(provider.Kind))
+	switch provider.Kind {
+	case "", CustomOAuthProviderKindOAuth:
+		provider.Kind = CustomOAuthProviderKindOAuth
+		if err := validateOAuthEndpoints(provider); err != nil {
+			return err
+		}
+	case CustomOAuthProviderKindSAML:
+		if err := validateSamlSettings(provider); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported provider kind: %s", provider.Kind)
+	}
+
+	if strings.TrimSpace(provider.AccessPolicy) != "" {
+		var policy accessPolicyPayload
+		if err := common.UnmarshalJsonStr(provider.AccessPolicy, &policy); err != nil {
+			return errors.New("access_policy must be valid JSON")
+		}
+		if err := validateAccessPolicyPayload(&policy); err != nil {
+			return fmt.Errorf("access_policy is invalid: %w", err)
+		}
+	}
+
+	if err := ValidateClaimMappings(provider.ClaimMappings); err != nil {
+		return fmt.Errorf("claim_mappings is invalid: %w", err)
+	}
+
+	return nil
+}
+
+func validateOAuthEndpoints(provider *CustomOAuthProvider) error {
 	if provider.ClientId == "" {
 		return errors.New("client ID is required")
 	}
@@ -191,19 +293,91 @@ func validateCustomOAuthProvider(provider *CustomOAuthProvider) error {
 	if provider.Scopes == "" {
 		provider.Scopes = "openid profile email"
 	}
-	if strings.TrimSpace(provider.AccessPolicy) != "" {
-		var policy accessPolicyPayload
-		if err := common.UnmarshalJsonStr(provider.AccessPolicy, &policy); err != nil {
-			return errors.New("access_policy must be valid JSON")
+	return nil
+}
+
+// validateSamlSettings checks the IdP metadata source and makes sure the SP has
+// a signing key pair, generating a self-signed one when none is configured.
+func validateSamlSettings(provider *CustomOAuthProvider) error {
+	provider.SamlIdpMetadataUrl = strings.TrimSpace(provider.SamlIdpMetadataUrl)
+	provider.SamlIdpMetadata = strings.TrimSpace(provider.SamlIdpMetadata)
+	if provider.SamlIdpMetadataUrl == "" && provider.SamlIdpMetadata == "" {
+		return errors.New("SAML IdP metadata URL or metadata XML is required")
+	}
+	if provider.SamlIdpMetadataUrl != "" {
+		parsed, err := url.Parse(provider.SamlIdpMetadataUrl)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return errors.New("SAML IdP metadata URL must be an http(s) URL")
 		}
-		if err := validateAccessPolicyPayload(&policy); err != nil {
-			return fmt.Errorf("access_policy is invalid: %w", err)
+	}
+	provider.ClientId = strings.TrimSpace(provider.ClientId)
+
+	provider.SamlSpCertificate = strings.TrimSpace(provider.SamlSpCertificate)
+	provider.SamlSpPrivateKey = strings.TrimSpace(provider.SamlSpPrivateKey)
+	switch {
+	case provider.SamlSpCertificate == "" && provider.SamlSpPrivateKey == "":
+		certificate, privateKey, err := generateSamlKeyPair(provider.Slug)
+		if err != nil {
+			return err
+		}
+		provider.SamlSpCertificate = certificate
+		provider.SamlSpPrivateKey = privateKey
+	case provider.SamlSpCertificate == "" || provider.SamlSpPrivateKey == "":
+		return errors.New("SAML SP certificate and private key must be set together")
+	default:
+		if _, err := tls.X509KeyPair([]byte(provider.SamlSpCertificate), []byte(provider.SamlSpPrivateKey)); err != nil {
+			return fmt.Errorf("SAML SP certificate does not match its private key: %w", err)
 		}
 	}
 
+	if provider.UserIdField == "" {
+		provider.UserIdField = SamlDefaultUserIdField
+	}
+	if provider.UsernameField == "" {
+		provider.UsernameField = SamlDefaultUsernameField
+	}
+	if provider.DisplayNameField == "" {
+		provider.DisplayNameField = SamlDefaultDisplayNameField
+	}
+	if provider.EmailField == "" {
+		provider.EmailField = SamlDefaultEmailField
+	}
 	return nil
 }
 
+// generateSamlKeyPair creates a self-signed RSA certificate for signing
+// AuthnRequests. IdPs pin the certificate from SP metadata, so it is long-lived.
+func generateSamlKeyPair(slug string) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "saml-sp-" + slug},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	return string(certificate), string(privateKey), nil
+}
+
 func validateAccessPolicyPayload(policy *accessPolicyPayload) error {
 	if policy == nil {
 		return errors.New("policy is nil")
@@ -245,3 +419,50 @@ func validateAccessPolicyPayload(policy *accessPolicyPayload) error {
 
 	return nil
 }
+
+// ValidateClaimMappings 校验声明映射规则的结构。保存提供商与登录时解析规则
+// （oauth.ParseClaimMappings）共用这一份校验，两处的规则不会出现分歧。
+func ValidateClaimMappings(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var mappings []claimMappingPayload
+	if err := common.UnmarshalJsonStr(raw, &mappings); err != nil {
+		return errors.New("must be a valid JSON array")
+	}
+	return validateClaimMappingPayloads(mappings)
+}
+
+func validateClaimMappingPayloads(mappings []claimMappingPayload) error {
+	names := make(map[string]struct{}, len(mappings))
+	for index, mapping := range mappings {
+		name := strings.TrimSpace(mapping.Name)
+		if name == "" {
+			return fmt.Errorf("mapping[%d].name is required", index)
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("mapping[%d].name is duplicated: %s", index, name)
+		}
+		names[name] = struct{}{}
+		if mapping.When != nil {
+			if err := validateAccessPolicyPayload(mapping.When); err != nil {
+				return fmt.Errorf("mapping[%d].when: %w", index, err)
+			}
+		}
+		if mapping.Role != "" && ClaimMappingRoleValue(mapping.Role) == 0 {
+			return fmt.Errorf("mapping[%d].role is unsupported: %s", index, mapping.Role)
+		}
+		if mapping.Quota < 0 {
+			return fmt.Errorf("mapping[%d].quota must not be negative", index)
+		}
+		for _, role := range mapping.AuthzRoles {
+			if strings.TrimSpace(role) == "" {
+				return fmt.Errorf("mapping[%d].authz_roles must not contain empty keys", index)
+			}
+		}
+		if strings.TrimSpace(mapping.Group) == "" && mapping.Role == "" && len(mapping.AuthzRoles) == 0 && mapping.Quota == 0 {
+			return fmt.Errorf("mapping[%d] grants nothing", index)
+		}
+	}
+	return nil
+}
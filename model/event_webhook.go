@@ -0,0 +1,232 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+// WebhookSubscription 事件 Webhook 订阅。UserId 为 0 表示管理员创建的系统订阅，可接收全部事件；
+// 用户订阅只接收属于该用户的用户级事件。
+type WebhookSubscription struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	URL         string `json:"url" gorm:"column:url;type:varchar(1024)"`
+	Secret      string `json:"-" gorm:"type:varchar(128)"`
+	EventTypes  string `json:"-" gorm:"type:text"` // JSON 数组，为空表示订阅全部可订阅事件
+	Enabled     bool   `json:"enabled" gorm:"index"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// WebhookDelivery 一次事件投递记录，重试与手动重新投递都基于该记录。
+// 异步任务回调也记录为投递：SubscriptionId 为 0，URL 为任务的回调地址，EventId 为任务 ID。
+type WebhookDelivery struct {
+	Id              int    `json:"id"`
+	SubscriptionId  int    `json:"subscription_id" gorm:"index"`
+	URL             string `json:"url,omitempty" gorm:"column:url;type:varchar(1024)"` // 仅任务回调使用，订阅投递发送到订阅地址
+	UserId          int    `json:"user_id" gorm:"index"`                               // 订阅所属用户，系统订阅为 0
+	EventId         string `json:"event_id" gorm:"type:varchar(64);index"`
+	EventType       string `json:"event_type" gorm:"type:varchar(64);index"`
+	Payload         string `json:"payload" gorm:"type:text"`
+	Status          string `json:"status" gorm:"type:varchar(16);index:idx_webhook_delivery_due,priority:1"`
+	Attempts        int    `json:"attempts" gorm:"default:0"`
+	NextAttemptTime int64  `json:"next_attempt_time" gorm:"bigint;index:idx_webhook_delivery_due,priority:2"`
+	ResponseStatus  int    `json:"response_status" gorm:"default:0"`
+	ResponseBody    string `json:"response_body" gorm:"type:text"`
+	LastError       string `json:"last_error" gorm:"type:text"`
+	DurationMs      int64  `json:"duration_ms" gorm:"bigint;default:0"`
+	RedeliveryOf    int    `json:"redelivery_of" gorm:"default:0"` // 手动重新投递时指向原投递记录
+	CreatedTime     int64  `json:"created_time" gorm:"bigint;index"`
+	UpdatedTime     int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (s *WebhookSubscription) Insert() error {
+	now := common.GetTimestamp()
+	s.CreatedTime = now
+	s.UpdatedTime = now
+	return DB.Create(s).Error
+}
+
+// Update 更新订阅定义，密钥只通过 UpdateSecret 轮换
+func (s *WebhookSubscription) Update() error {
+	s.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&WebhookSubscription{}).Where("id = ? AND user_id = ?", s.Id, s.UserId).
+		Select("name", "url", "event_types", "enabled", "updated_time").
+		Updates(s).Error
+}
+
+func (s *WebhookSubscription) UpdateSecret() error {
+	s.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&WebhookSubscription{}).Where("id = ? AND user_id = ?", s.Id, s.UserId).
+		Select("secret", "updated_time").
+		Updates(s).Error
+}
+
+// GetEventTypeList 返回订阅的事件类型，为空表示全部
+func (s *WebhookSubscription) GetEventTypeList() []string {
+	var eventTypes []string
+	if s.EventTypes == "" {
+		return eventTypes
+	}
+	if err := common.UnmarshalJsonStr(s.EventTypes, &eventTypes); err != nil {
+		common.SysError("failed to decode webhook subscription event types: " + err.Error())
+		return nil
+	}
+	return eventTypes
+}
+
+func (s *WebhookSubscription) SetEventTypeList(eventTypes []string) {
+	if len(eventTypes) == 0 {
+		s.EventTypes = ""
+		return
+	}
+	data, err := common.Marshal(eventTypes)
+	if err != nil {
+		s.EventTypes = ""
+		return
+	}
+	s.EventTypes = string(data)
+}
+
+// GetWebhookSubscription 按所属用户获取订阅，系统订阅使用 userId 0
+func GetWebhookSubscription(id int, userId int) (*WebhookSubscription, error) {
+	var subscription WebhookSubscription
+	if err := DB.Where("id = ? AND user_id = ?", id, userId).First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func GetWebhookSubscriptions(userId int) ([]*WebhookSubscription, error) {
+	var subscriptions []*WebhookSubscription
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func CountWebhookSubscriptions(userId int) (int64, error) {
+	var count int64
+	err := DB.Model(&WebhookSubscription{}).Where("user_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+// GetEventWebhookSubscriptions 返回可能接收某个事件的已启用订阅：系统订阅以及事件所属用户的订阅，
+// 事件类型过滤由调用方完成
+func GetEventWebhookSubscriptions(eventUserId int) ([]*WebhookSubscription, error) {
+	var subscriptions []*WebhookSubscription
+	query := DB.Where("enabled = ?", true)
+	if eventUserId > 0 {
+		query = query.Where("user_id IN ?", []int{0, eventUserId})
+	} else {
+		query = query.Where("user_id = ?", 0)
+	}
+	err := query.Order("id asc").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// DeleteWebhookSubscription 删除订阅及其投递记录
+func DeleteWebhookSubscription(id int, userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userId).Delete(&WebhookSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&WebhookDelivery{}).Error
+	})
+}
+
+func CreateWebhookDeliveries(deliveries []*WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	now := common.GetTimestamp()
+	for _, delivery := range deliveries {
+		delivery.CreatedTime = now
+		delivery.UpdatedTime = now
+		if delivery.Status == "" {
+			delivery.Status = WebhookDeliveryStatusPending
+		}
+		if delivery.NextAttemptTime == 0 {
+			delivery.NextAttemptTime = now
+		}
+	}
+	return DB.Create(&deliveries).Error
+}
+
+// FindDueWebhookDeliveries 返回到期待投递的记录，按到期时间排序
+func FindDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? AND next_attempt_time <= ?", WebhookDeliveryStatusPending, now).
+		Order("next_attempt_time asc, id asc").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func HasDueWebhookDeliveries(now int64) bool {
+	var delivery WebhookDelivery
+	result := DB.Select("id").Where("status = ? AND next_attempt_time <= ?", WebhookDeliveryStatusPending, now).
+		Limit(1).Find(&delivery)
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// SaveWebhookDeliveryAttempt 保存一次投递尝试的结果
+func SaveWebhookDeliveryAttempt(delivery *WebhookDelivery) error {
+	delivery.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&WebhookDelivery{}).Where("id = ?", delivery.Id).
+		Select("status", "attempts", "next_attempt_time", "response_status", "response_body", "last_error", "duration_ms", "updated_time").
+		Updates(delivery).Error
+}
+
+// GetWebhookDelivery 按订阅所属用户获取投递记录，系统订阅使用 userId 0
+func GetWebhookDelivery(id int, userId int) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := DB.Where("id = ? AND user_id = ?", id, userId).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetWebhookDeliveries 分页获取投递记录，subscriptionId / eventType / status 非空时过滤
+func GetWebhookDeliveries(userId int, subscriptionId int, eventType string, status string, pageInfo *common.PageInfo) ([]*WebhookDelivery, int64, error) {
+	query := DB.Model(&WebhookDelivery{}).Where("user_id = ?", userId)
+	if subscriptionId > 0 {
+		query = query.Where("subscription_id = ?", subscriptionId)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*WebhookDelivery
+	err := query.Omit("payload").Order("id desc").
+		Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).
+		Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// DeleteWebhookDeliveriesBefore 清理早于指定时间且不再重试的投递记录
+func DeleteWebhookDeliveriesBefore(timestamp int64) (int64, error) {
+	if timestamp <= 0 {
+		return 0, errors.New("invalid timestamp")
+	}
+	result := DB.Where("created_time < ? AND status <> ?", timestamp, WebhookDeliveryStatusPending).Delete(&WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
@@ -10,7 +10,12 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-const ExternalIdentityProviderTelegram = "telegram"
+const (
+	ExternalIdentityProviderTelegram = "telegram"
+	// ExternalIdentityProviderLDAP links a directory entry to the account
+	// it signs in as.
+	ExternalIdentityProviderLDAP = "ldap"
+)
 
 var ErrExternalIdentityAlreadyClaimed = errors.New("external identity is already claimed")
 
@@ -86,13 +91,13 @@ func releaseAllExternalIdentitiesWithTx(tx *gorm.DB, userId int) error {
 // InitializeExternalIdentityClaims imports legacy Telegram bindings after the
 // claim table is migrated. Existing duplicate ownership fails migration rather
 // than preserving an ambiguous login identity.
-func InitializeExternalIdentityClaims() error {
+func InitializeExternalIdentityClaims(db *gorm.DB) error {
 	var users []User
-	if err := DB.Unscoped().Select("id", "telegram_id").
+	if err := db.Unscoped().Select("id", "telegram_id").
 		Where("telegram_id <> ?", "").Find(&users).Error; err != nil {
 		return err
 	}
-	return DB.Transaction(func(tx *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
 		for _, user := range users {
 			if err := ClaimExternalIdentityWithTx(tx, ExternalIdentityProviderTelegram, user.TelegramId, user.Id); err != nil {
 				return fmt.Errorf("backfill Telegram identity for user %d: %w", user.Id, err)
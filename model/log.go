@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -66,6 +67,7 @@ type Log struct {
 	TokenName         string `json:"token_name" gorm:"index;default:''"`
 	ModelName         string `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
 	Quota             int    `json:"quota" gorm:"default:0"`
+	UpstreamCost      int    `json:"upstream_cost" gorm:"default:0"` // 上游成本（quota 单位），未配置成本定价时为 0
 	PromptTokens      int    `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens  int    `json:"completion_tokens" gorm:"default:0"`
 	UseTime           int    `json:"use_time" gorm:"default:0"`
@@ -116,6 +118,7 @@ func assignDisplayLogIds(logs []*Log, startIdx int) {
 func formatUserLogs(logs []*Log, startIdx int) {
 	for i := range logs {
 		logs[i].ChannelName = ""
+		logs[i].UpstreamCost = 0
 		var otherMap map[string]interface{}
 		otherMap, _ = common.StrToMap(logs[i].Other)
 		if otherMap != nil {
@@ -332,6 +335,7 @@ type RecordConsumeLogParams struct {
 	ModelName        string                 `json:"model_name"`
 	TokenName        string                 `json:"token_name"`
 	Quota            int                    `json:"quota"`
+	UpstreamCost     int                    `json:"upstream_cost"`
 	Content          string                 `json:"content"`
 	TokenId          int                    `json:"token_id"`
 	UseTimeSeconds   int                    `json:"use_time_seconds"`
@@ -368,6 +372,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		TokenName:        params.TokenName,
 		ModelName:        params.ModelName,
 		Quota:            params.Quota,
+		UpstreamCost:     params.UpstreamCost,
 		ChannelId:        params.ChannelId,
 		TokenId:          params.TokenId,
 		UseTime:          params.UseTimeSeconds,
@@ -700,6 +705,69 @@ func CountOldLog(ctx context.Context, targetTimestamp int64) (int64, error) {
 	return total, nil
 }
 
+func consumeLogWindowQuery(ctx context.Context, startTimestamp int64, endTimestamp int64, modelName string) *gorm.DB {
+	tx := LOG_DB.WithContext(ctx).Model(&Log{}).Where("type = ?", LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	return tx
+}
+
+// CountConsumeLogWindow 统计时间窗口内的消费日志数量，modelName 为空时不过滤模型
+func CountConsumeLogWindow(ctx context.Context, startTimestamp int64, endTimestamp int64, modelName string) (int64, error) {
+	var total int64
+	if err := consumeLogWindowQuery(ctx, startTimestamp, endTimestamp, modelName).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetConsumeLogWindowBatch 按 id 递增分批读取时间窗口内的消费日志，afterId 为上一批最后一条的 id
+func GetConsumeLogWindowBatch(ctx context.Context, startTimestamp int64, endTimestamp int64, modelName string, afterId int, limit int) ([]*Log, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var logs []*Log
+	err := consumeLogWindowQuery(ctx, startTimestamp, endTimestamp, modelName).
+		Where("id > ?", afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+// LogCleanupCutoffOptionKey 记录清理过的日志的最晚时间点，早于它的消费记录已不完整
+const LogCleanupCutoffOptionKey = "LogCleanupCutoff"
+
+// recordLogCleanupCutoff 在删除旧日志后推进清理时间点，只增不减
+func recordLogCleanupCutoff(targetTimestamp int64) error {
+	cutoff, err := GetLogCleanupCutoff()
+	if err != nil {
+		return err
+	}
+	if targetTimestamp <= cutoff {
+		return nil
+	}
+	option := Option{Key: LogCleanupCutoffOptionKey, Value: strconv.FormatInt(targetTimestamp, 10)}
+	return DB.Save(&option).Error
+}
+
+// GetLogCleanupCutoff 返回日志清理时间点，从未清理过时为 0
+func GetLogCleanupCutoff() (int64, error) {
+	value, ok, err := GetOptionValue(LogCleanupCutoffOptionKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	cutoff, _ := strconv.ParseInt(value, 10, 64)
+	return cutoff, nil
+}
+
 func DeleteOldLogBatch(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
 	if limit <= 0 {
 		limit = 100
@@ -726,12 +794,17 @@ func DeleteOldLogBatch(ctx context.Context, targetTimestamp int64, limit int) (i
 		).Error; err != nil {
 			return 0, err
 		}
-		return total, nil
+		return total, recordLogCleanupCutoff(targetTimestamp)
 	}
 
 	result := LOG_DB.WithContext(ctx).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&Log{})
 	if nil != result.Error {
 		return 0, result.Error
 	}
+	if result.RowsAffected > 0 {
+		if err := recordLogCleanupCutoff(targetTimestamp); err != nil {
+			return result.RowsAffected, err
+		}
+	}
 	return result.RowsAffected, nil
 }
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	MarginGroupByChannel = "channel"
+	MarginGroupByModel   = "model"
+	MarginGroupByGroup   = "group"
+	MarginGroupByUser    = "user"
+
+	marginReportLimit = 1000
+)
+
+// MarginReportItem 毛利报表的一行：向用户收取的 quota 与上游成本之差。
+// TrackedQuota 为配置了成本定价的请求所收取的 quota，MarginRate 仅基于这部分计算，
+// 避免未配置成本的渠道把毛利率虚高为 100%。
+type MarginReportItem struct {
+	Key             string  `json:"key"`
+	Name            string  `json:"name,omitempty"`
+	Requests        int64   `json:"requests"`
+	TrackedRequests int64   `json:"tracked_requests"`
+	Quota           int64   `json:"quota"`
+	TrackedQuota    int64   `json:"tracked_quota"`
+	UpstreamCost    int64   `json:"upstream_cost"`
+	Margin          int64   `json:"margin"`
+	MarginRate      float64 `json:"margin_rate"`
+}
+
+type MarginReportParams struct {
+	GroupBy        string
+	StartTimestamp int64
+	EndTimestamp   int64
+	ModelName      string
+	Username       string
+	Channel        int
+	Group          string
+}
+
+func marginGroupColumn(groupBy string) (string, error) {
+	switch groupBy {
+	case MarginGroupByChannel:
+		return "channel_id", nil
+	case MarginGroupByModel:
+		return "model_name", nil
+	case MarginGroupByGroup:
+		return logGroupCol, nil
+	case MarginGroupByUser:
+		return "username", nil
+	}
+	return "", fmt.Errorf("invalid group_by: %s", groupBy)
+}
+
+// GetMarginReport 按渠道 / 模型 / 分组 / 用户聚合消费日志的收入与上游成本
+func GetMarginReport(params MarginReportParams) ([]*MarginReportItem, error) {
+	column, err := marginGroupColumn(params.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	type marginRow struct {
+		ChannelId       int
+		DimValue        string
+		Requests        int64
+		TrackedRequests int64
+		Quota           int64
+		TrackedQuota    int64
+		UpstreamCost    int64
+	}
+	dimSelect := column + " AS dim_value"
+	if params.GroupBy == MarginGroupByChannel {
+		dimSelect = "channel_id"
+	}
+	tx := LOG_DB.Table("logs").Select(dimSelect+", count(*) AS requests"+
+		", COALESCE(sum(CASE WHEN upstream_cost > 0 THEN 1 ELSE 0 END), 0) AS tracked_requests"+
+		", COALESCE(sum(quota), 0) AS quota"+
+		", COALESCE(sum(CASE WHEN upstream_cost > 0 THEN quota ELSE 0 END), 0) AS tracked_quota"+
+		", COALESCE(sum(upstream_cost), 0) AS upstream_cost").
+		Where("type = ?", LogTypeConsume)
+	if params.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", params.EndTimestamp)
+	}
+	if tx, err = applyExplicitLogTextFilter(tx, "model_name", params.ModelName); err != nil {
+		return nil, err
+	}
+	if tx, err = applyExplicitLogTextFilter(tx, "username", params.Username); err != nil {
+		return nil, err
+	}
+	if params.Channel != 0 {
+		tx = tx.Where("channel_id = ?", params.Channel)
+	}
+	if params.Group != "" {
+		tx = tx.Where(logGroupCol+" = ?", params.Group)
+	}
+	var rows []marginRow
+	if err := tx.Group(column).Order("quota desc").Limit(marginReportLimit).Scan(&rows).Error; err != nil {
+		common.SysError("failed to query margin report: " + err.Error())
+		return nil, errors.New("查询毛利报表失败")
+	}
+
+	items := make([]*MarginReportItem, 0, len(rows))
+	channelIds := make([]int, 0)
+	for _, row := range rows {
+		item := &MarginReportItem{
+			Key:             row.DimValue,
+			Requests:        row.Requests,
+			TrackedRequests: row.TrackedRequests,
+			Quota:           row.Quota,
+			TrackedQuota:    row.TrackedQuota,
+			UpstreamCost:    row.UpstreamCost,
+			Margin:          row.TrackedQuota - row.UpstreamCost,
+		}
+		if row.TrackedQuota > 0 {
+			item.MarginRate = float64(item.Margin) / float64(row.TrackedQuota)
+		}
+		if params.GroupBy == MarginGroupByChannel {
+			item.Key = strconv.Itoa(row.ChannelId)
+			channelIds = append(channelIds, row.ChannelId)
+		}
+		items = append(items, item)
+	}
+	if len(channelIds) > 0 {
+		var channels []struct {
+			Id   int
+			Name string
+		}
+		if err := DB.Table("channels").Select("id, name").Where("id IN ?", channelIds).Find(&channels).Error; err == nil {
+			names := make(map[string]string, len(channels))
+			for _, channel := range channels {
+				names[strconv.Itoa(channel.Id)] = channel.Name
+			}
+			for _, item := range items {
+				item.Name = names[item.Key]
+			}
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMarginReportByChannel(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Channel{Id: 1, Name: "east"}).Error)
+	require.NoError(t, DB.Create(&Channel{Id: 2, Name: "west"}).Error)
+	for _, log := range []*Log{
+		{Type: LogTypeConsume, ChannelId: 1, ModelName: "gpt-a", Username: "alice", Quota: 1000, UpstreamCost: 600, CreatedAt: 100},
+		{Type: LogTypeConsume, ChannelId: 1, ModelName: "gpt-b", Username: "bob", Quota: 500, CreatedAt: 100},
+		{Type: LogTypeConsume, ChannelId: 2, ModelName: "gpt-a", Username: "alice", Quota: 200, UpstreamCost: 50, CreatedAt: 100},
+		{Type: LogTypeError, ChannelId: 2, ModelName: "gpt-a", Username: "alice", Quota: 999, UpstreamCost: 999, CreatedAt: 100},
+	} {
+		require.NoError(t, createLog(log))
+	}
+
+	items, err := GetMarginReport(MarginReportParams{GroupBy: MarginGroupByChannel})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	assert.Equal(t, "1", items[0].Key)
+	assert.Equal(t, "east", items[0].Name)
+	assert.Equal(t, int64(2), items[0].Requests)
+	assert.Equal(t, int64(1), items[0].TrackedRequests)
+	assert.Equal(t, int64(1500), items[0].Quota)
+	assert.Equal(t, int64(1000), items[0].TrackedQuota)
+	assert.Equal(t, int64(400), items[0].Margin)
+	assert.InDelta(t, 0.4, items[0].MarginRate, 1e-9)
+
+	assert.Equal(t, "2", items[1].Key)
+	assert.Equal(t, int64(150), items[1].Margin)
+
+	items, err = GetMarginReport(MarginReportParams{GroupBy: MarginGroupByUser, ModelName: "gpt-a"})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "alice", items[0].Key)
+	assert.Equal(t, int64(650), items[0].UpstreamCost)
+
+	_, err = GetMarginReport(MarginReportParams{GroupBy: "token"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// schemaMigrations 登记全部编号迁移。版本号在同一 Target 内递增且不可复用，
+// 已发布的迁移不要修改，需要调整时追加新的迁移。
+var schemaMigrations = []Migration{
+	{
+		Version:           1,
+		Name:              "token_model_limits_text",
+		Target:            MigrationTargetMain,
+		BeforeAutoMigrate: true,
+		// SQLite 使用类型亲和性，TEXT 与 VARCHAR 等价，无需迁移
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL: alterColumnTypeStep("tokens", "model_limits", isTextColumn,
+				"ALTER TABLE tokens MODIFY COLUMN model_limits text"),
+			common.DatabaseTypePostgreSQL: alterColumnTypeStep("tokens", "model_limits", isTextColumn,
+				"ALTER TABLE tokens ALTER COLUMN model_limits TYPE text"),
+		},
+		// 已有超过 1024 个字符的取值时拒绝回滚，避免截断数据
+		Down: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL: shrinkColumnStep("tokens", "model_limits", 1024,
+				"ALTER TABLE tokens MODIFY COLUMN model_limits varchar(1024)"),
+			common.DatabaseTypePostgreSQL: shrinkColumnStep("tokens", "model_limits", 1024,
+				"ALTER TABLE tokens ALTER COLUMN model_limits TYPE varchar(1024)"),
+		},
+	},
+	{
+		Version:           2,
+		Name:              "subscription_plan_price_amount_decimal",
+		Target:            MigrationTargetMain,
+		BeforeAutoMigrate: true,
+		// 与旧版启动时的处理一致：转换失败只记录警告，不阻止启动
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL: warnOnlyMigrationStep(alterColumnTypeStep("subscription_plans", "price_amount", isDecimalColumn,
+				"ALTER TABLE subscription_plans MODIFY COLUMN price_amount decimal(10,6) NOT NULL DEFAULT 0")),
+			common.DatabaseTypePostgreSQL: warnOnlyMigrationStep(alterColumnTypeStep("subscription_plans", "price_amount", isDecimalColumn,
+				"ALTER TABLE subscription_plans ALTER COLUMN price_amount TYPE decimal(10,6) USING price_amount::decimal(10,6)")),
+		},
+		Down: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL:      {SQL: []string{"ALTER TABLE subscription_plans MODIFY COLUMN price_amount double NOT NULL DEFAULT 0"}},
+			common.DatabaseTypePostgreSQL: {SQL: []string{"ALTER TABLE subscription_plans ALTER COLUMN price_amount TYPE double precision"}},
+		},
+	},
+	{
+		Version: 3,
+		Name:    "users_auth_version_backfill",
+		Target:  MigrationTargetMain,
+		Up: allMainDialects(MigrationStep{
+			Func:        InitializeUserAuthVersions,
+			Description: "set auth_version = 1 for users without one",
+		}),
+	},
+	{
+		Version: 4,
+		Name:    "external_identity_claims_telegram_backfill",
+		Target:  MigrationTargetMain,
+		Up: allMainDialects(MigrationStep{
+			Func:        InitializeExternalIdentityClaims,
+			Description: "claim the Telegram ids already bound to users",
+		}),
+	},
+	{
+		Version: 5,
+		Name:    "subscription_plans_sqlite",
+		Target:  MigrationTargetMain,
+		// 其他数据库由 AutoMigrate 维护该表
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeSQLite: {
+				Func:        ensureSubscriptionPlanTableSQLite,
+				Description: "create subscription_plans or add its missing columns",
+			},
+		},
+	},
+	{
+		Version: 6,
+		Name:    "subscription_plans_currency_prices_sqlite",
+		Target:  MigrationTargetMain,
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeSQLite: {
+				Func: func(db *gorm.DB) error {
+					if db.Migrator().HasColumn("subscription_plans", "currency_prices") {
+						return nil
+					}
+					return db.Exec("ALTER TABLE subscription_plans ADD COLUMN currency_prices text").Error
+				},
+				Description: "ALTER TABLE subscription_plans ADD COLUMN currency_prices text",
+			},
+		},
+		Down: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeSQLite: {SQL: []string{"ALTER TABLE subscription_plans DROP COLUMN currency_prices"}},
+		},
+	},
+	{
+		// 原 bin/migration_v0.2-v0.3.sql：v0.3 起令牌额度计入用户额度
+		Version:  7,
+		Name:     "legacy_v0.3_token_quota_to_user",
+		Target:   MigrationTargetMain,
+		Baseline: true,
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL: {SQL: []string{"UPDATE users SET quota = quota + (SELECT SUM(remain_quota) FROM tokens WHERE tokens.user_id = users.id)"}},
+		},
+	},
+	{
+		// 原 bin/migration_v0.3-v0.4.sql：v0.4 起按 abilities 表路由
+		Version:  8,
+		Name:     "legacy_v0.4_abilities",
+		Target:   MigrationTargetMain,
+		Baseline: true,
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeMySQL: {SQL: []string{"INSERT INTO abilities (`group`, model, channel_id, enabled) " +
+				"SELECT c.`group`, m.model, c.id, 1 FROM channels c CROSS JOIN (" +
+				"SELECT 'gpt-3.5-turbo' AS model UNION ALL SELECT 'gpt-3.5-turbo-0301' AS model UNION ALL " +
+				"SELECT 'gpt-4' AS model UNION ALL SELECT 'gpt-4-0314' AS model) AS m " +
+				"WHERE c.status = 1 AND NOT EXISTS (SELECT 1 FROM abilities a " +
+				"WHERE a.`group` = c.`group` AND a.model = m.model AND a.channel_id = c.id)"}},
+		},
+	},
+	{
+		Version: 1,
+		Name:    "logs_upstream_cost",
+		Target:  MigrationTargetLog,
+		// 其他数据库由 AutoMigrate 补齐新增的列
+		Up: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeClickHouse: {SQL: []string{"ALTER TABLE logs ADD COLUMN IF NOT EXISTS upstream_cost Int32 DEFAULT 0 AFTER quota"}},
+		},
+		Down: map[common.DatabaseType]MigrationStep{
+			common.DatabaseTypeClickHouse: {SQL: []string{"ALTER TABLE logs DROP COLUMN IF EXISTS upstream_cost"}},
+		},
+	},
+}
+
+func allMainDialects(step MigrationStep) map[common.DatabaseType]MigrationStep {
+	return map[common.DatabaseType]MigrationStep{
+		common.DatabaseTypeSQLite:     step,
+		common.DatabaseTypeMySQL:      step,
+		common.DatabaseTypePostgreSQL: step,
+	}
+}
+
+// warnOnlyMigrationStep 执行步骤，失败时只记录警告，迁移仍视为已执行
+func warnOnlyMigrationStep(step MigrationStep) MigrationStep {
+	description := step.Description
+	if description == "" {
+		description = strings.Join(step.SQL, "; ")
+	}
+	return MigrationStep{
+		Func: func(db *gorm.DB) error {
+			// 嵌套事务在 PostgreSQL 上使用保存点，失败的语句不会中止外层事务
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return runMigrationStep(tx, step)
+			})
+			if err != nil {
+				common.SysLog(fmt.Sprintf("Warning: %s failed: %v", description, err))
+			}
+			return nil
+		},
+		Description: description,
+	}
+}
+
+func isTextColumn(columnType string) bool {
+	return columnType == "text"
+}
+
+func isDecimalColumn(columnType string) bool {
+	return columnType == "numeric" || strings.HasPrefix(columnType, "decimal")
+}
+
+// columnType 读取列的类型：PostgreSQL 返回 data_type（如 text、numeric），
+// MySQL 返回 COLUMN_TYPE（如 text、decimal(10,6)），均为小写
+func columnType(db *gorm.DB, table string, column string) (string, error) {
+	var result string
+	var err error
+	switch db.Dialector.Name() {
+	case "postgres":
+		err = db.Raw(`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, table, column).Scan(&result).Error
+	case "mysql":
+		err = db.Raw(`SELECT COLUMN_TYPE FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`, table, column).Scan(&result).Error
+	default:
+		return "", fmt.Errorf("unsupported dialect %s", db.Dialector.Name())
+	}
+	return strings.ToLower(result), err
+}
+
+// alterColumnTypeStep 修改已有列的类型：表或列不存在、或列已是目标类型时跳过，可重复执行。
+// 读取列类型失败时仍尝试执行，与旧版启动时的处理一致
+func alterColumnTypeStep(table string, column string, isTargetType func(columnType string) bool, statement string) MigrationStep {
+	return MigrationStep{
+		Func: func(db *gorm.DB) error {
+			if !db.Migrator().HasTable(table) || !db.Migrator().HasColumn(table, column) {
+				return nil
+			}
+			current, err := columnType(db, table, column)
+			if err != nil {
+				common.SysLog(fmt.Sprintf("Warning: failed to query metadata for %s.%s: %v", table, column, err))
+			} else if isTargetType(current) {
+				return nil
+			}
+			return db.Exec(statement).Error
+		},
+		Description: statement + " -- skipped when the column already has the target type",
+	}
+}
+
+// shrinkColumnStep 把列改为长度为 maxLength 的类型；已有更长的取值时拒绝执行，避免截断数据。
+// MySQL 的 LENGTH 按字节计算，多字节字符会使检查更保守
+func shrinkColumnStep(table string, column string, maxLength int, statement string) MigrationStep {
+	return MigrationStep{
+		Func: func(db *gorm.DB) error {
+			var count int64
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE LENGTH(%s) > ?", table, column)
+			if err := db.Raw(query, maxLength).Scan(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%d rows of %s have %s longer than %d characters, shrinking the column would truncate them", count, table, column, maxLength)
+			}
+			return db.Exec(statement).Error
+		},
+		Description: statement + " -- refused when existing values are longer than the new size",
+	}
+}
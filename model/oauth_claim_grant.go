@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// OAuthClaimGrant records what the claim mappings of a custom provider granted
+// to a user, so entitlements can be revoked when the claim disappears without
+// touching values an administrator set by hand.
+type OAuthClaimGrant struct {
+	Id            int       `json:"id" gorm:"primaryKey"`
+	UserId        int       `json:"user_id" gorm:"not null;uniqueIndex:ux_claim_grant_user_provider"`
+	ProviderId    int       `json:"provider_id" gorm:"not null;uniqueIndex:ux_claim_grant_user_provider;index"`
+	GrantedGroup  string    `json:"granted_group" gorm:"type:varchar(64);default:''"`  // Group set by a mapping, empty when none applies
+	PreviousGroup string    `json:"previous_group" gorm:"type:varchar(64);default:''"` // Group restored when the mapping no longer applies
+	GrantedRole   int       `json:"granted_role" gorm:"default:0"`
+	PreviousRole  int       `json:"previous_role" gorm:"default:0"`
+	AuthzRoles    string    `json:"authz_roles" gorm:"type:text"`      // JSON array of custom authz roles added by mappings
+	QuotaRules    string    `json:"quota_rules" gorm:"type:text"`      // JSON array of rule names whose quota was already granted
+	Version       int       `json:"version" gorm:"not null;default:0"` // Bumped on every save to detect concurrent logins
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (OAuthClaimGrant) TableName() string {
+	return "oauth_claim_grants"
+}
+
+// GetOAuthClaimGrant returns the grant ledger of a user for a provider. A
+// zero-Id record is returned when nothing has been granted yet.
+func GetOAuthClaimGrant(userId, providerId int) (*OAuthClaimGrant, error) {
+	var grant OAuthClaimGrant
+	err := DB.Where("user_id = ? AND provider_id = ?", userId, providerId).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &OAuthClaimGrant{UserId: userId, ProviderId: providerId}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// SaveOAuthClaimGrant persists the ledger if nobody else changed it since it
+// was read. It returns false when a concurrent login won the race.
+func SaveOAuthClaimGrant(grant *OAuthClaimGrant) (bool, error) {
+	if grant.Id == 0 {
+		if err := DB.Create(grant).Error; err != nil {
+			existing, lookupErr := GetOAuthClaimGrant(grant.UserId, grant.ProviderId)
+			if lookupErr == nil && existing.Id != 0 {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	result := DB.Model(&OAuthClaimGrant{}).
+		Where("id = ? AND version = ?", grant.Id, grant.Version).
+		Updates(map[string]any{
+			"granted_group":  grant.GrantedGroup,
+			"previous_group": grant.PreviousGroup,
+			"granted_role":   grant.GrantedRole,
+			"previous_role":  grant.PreviousRole,
+			"authz_roles":    grant.AuthzRoles,
+			"quota_rules":    grant.QuotaRules,
+			"version":        grant.Version + 1,
+			"updated_at":     time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	grant.Version++
+	return true, nil
+}
+
+// GetAuthzRoleList decodes the custom authz roles added by mappings
+func (g *OAuthClaimGrant) GetAuthzRoleList() []string {
+	return decodeClaimGrantList(g.AuthzRoles)
+}
+
+// SetAuthzRoleList encodes the custom authz roles added by mappings
+func (g *OAuthClaimGrant) SetAuthzRoleList(roles []string) {
+	g.AuthzRoles = encodeClaimGrantList(roles)
+}
+
+// GetQuotaRuleList decodes the rule names whose quota was already granted
+func (g *OAuthClaimGrant) GetQuotaRuleList() []string {
+	return decodeClaimGrantList(g.QuotaRules)
+}
+
+// SetQuotaRuleList encodes the rule names whose quota was already granted
+func (g *OAuthClaimGrant) SetQuotaRuleList(rules []string) {
+	g.QuotaRules = encodeClaimGrantList(rules)
+}
+
+func decodeClaimGrantList(raw string) []string {
+	var list []string
+	if raw == "" {
+		return list
+	}
+	if err := common.UnmarshalJsonStr(raw, &list); err != nil {
+		common.SysError("failed to decode oauth claim grant list: " + err.Error())
+		return nil
+	}
+	return list
+}
+
+func encodeClaimGrantList(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	data, err := common.Marshal(list)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
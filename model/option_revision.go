@@ -0,0 +1,396 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OptionChangeSourceUpdate      = "update"       // 设置页面单项修改
+	OptionChangeSourceBulk        = "bulk"         // 程序内批量修改
+	OptionChangeSourceRollback    = "rollback"     // 回滚历史版本
+	OptionChangeSourceChangeSet   = "change_set"   // 应用暂存的变更集
+	OptionChangeSourceConfigApply = "config_apply" // 声明式配置导入
+
+	OptionChangeSetStatusDraft     = "draft"
+	OptionChangeSetStatusApplied   = "applied"
+	OptionChangeSetStatusDiscarded = "discarded"
+
+	// 敏感设置在历史记录和变更集中以密文保存，带此前缀
+	optionSecretPrefix = "enc:v1:"
+	// 未配置 CRYPTO_SECRET / SESSION_SECRET 时，历史记录中的敏感设置只保存此占位符
+	optionSecretRedacted = "redacted:v1"
+)
+
+var (
+	optionSecretCipherOnce sync.Once
+	optionSecretCipher     *common.PassphraseCipher
+	optionSecretCipherErr  error
+)
+
+// IsSensitiveOptionKey 判断设置项是否保存凭据，这类取值不会以明文返回或写入历史
+func IsSensitiveOptionKey(key string) bool {
+	return strings.HasSuffix(key, "Token") ||
+		strings.HasSuffix(key, "Secret") ||
+		strings.HasSuffix(key, "Key") ||
+		strings.HasSuffix(key, "secret") ||
+		strings.HasSuffix(key, "api_key") ||
+		strings.HasSuffix(key, "password")
+}
+
+func getOptionSecretCipher() (*common.PassphraseCipher, error) {
+	optionSecretCipherOnce.Do(func() {
+		salt := base64.StdEncoding.EncodeToString([]byte("new-api-option-history"))
+		optionSecretCipher, optionSecretCipherErr = common.NewPassphraseCipher(common.CryptoSecret, salt)
+	})
+	return optionSecretCipher, optionSecretCipherErr
+}
+
+// sealOptionValue 加密敏感设置的取值，其他设置原样返回
+func sealOptionValue(key string, value string) (string, error) {
+	if value == "" || !IsSensitiveOptionKey(key) {
+		return value, nil
+	}
+	if !common.CryptoSecretConfigured {
+		return "", fmt.Errorf("option %s: set CRYPTO_SECRET or SESSION_SECRET to store sensitive values", key)
+	}
+	cipher, err := getOptionSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := cipher.Encrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return optionSecretPrefix + sealed, nil
+}
+
+// sealOptionHistoryValue 用于历史记录：未配置固定密钥时密文在重启后无法解密，
+// 此时只保存占位符，不保留敏感设置的历史取值
+func sealOptionHistoryValue(key string, value string) (string, error) {
+	if value != "" && IsSensitiveOptionKey(key) && !common.CryptoSecretConfigured {
+		return optionSecretRedacted, nil
+	}
+	return sealOptionValue(key, value)
+}
+
+// openOptionValue 解密 sealOptionValue 的结果，兼容升级前写入的明文
+func openOptionValue(key string, value string) (string, error) {
+	if !IsSensitiveOptionKey(key) {
+		return value, nil
+	}
+	if value == optionSecretRedacted {
+		return "", fmt.Errorf("option %s: value was not kept in history because CRYPTO_SECRET was not configured", key)
+	}
+	if !strings.HasPrefix(value, optionSecretPrefix) {
+		return value, nil
+	}
+	cipher, err := getOptionSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	plain, err := cipher.Decrypt(strings.TrimPrefix(value, optionSecretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("option %s: cannot decrypt stored value, CRYPTO_SECRET may have changed since it was recorded: %w", key, err)
+	}
+	return plain, nil
+}
+
+// OptionRevision 记录系统设置的一次修改，用于查看历史、对比和回滚。
+// 同一次原子提交（批量修改、变更集、配置导入）中的修改共享 Batch。
+type OptionRevision struct {
+	Id          int    `json:"id"`
+	OptionKey   string `json:"key" gorm:"type:varchar(255);index"`
+	OldValue    string `json:"old_value" gorm:"type:text"`
+	NewValue    string `json:"new_value" gorm:"type:text"`
+	Existed     bool   `json:"existed"` // 修改前该设置是否已有记录
+	Source      string `json:"source" gorm:"type:varchar(32)"`
+	Batch       string `json:"batch" gorm:"type:varchar(64);index"`
+	ChangeSetId int    `json:"change_set_id,omitempty" gorm:"index"`
+	OperatorId  int    `json:"operator_id" gorm:"index"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+// OptionChangeSet 是暂存的多项设置修改，校验后在一个事务中整体应用
+type OptionChangeSet struct {
+	Id          int    `json:"id"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	Values      string `json:"values" gorm:"type:text"` // JSON 对象：设置项 -> 新值
+	Status      string `json:"status" gorm:"type:varchar(32);default:'draft';index"`
+	CreatedBy   int    `json:"created_by"`
+	AppliedBy   int    `json:"applied_by"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+	AppliedTime int64  `json:"applied_time" gorm:"bigint"`
+}
+
+// OptionChangeMeta 描述一次设置修改的来源，写入对应的历史记录
+type OptionChangeMeta struct {
+	OperatorId  int
+	Source      string
+	ChangeSetId int
+}
+
+// PlainValues 返回修改前后的明文取值，敏感设置在数据库中是加密保存的
+func (r *OptionRevision) PlainValues() (oldValue string, newValue string, err error) {
+	if oldValue, err = openOptionValue(r.OptionKey, r.OldValue); err != nil {
+		return "", "", err
+	}
+	if newValue, err = openOptionValue(r.OptionKey, r.NewValue); err != nil {
+		return "", "", err
+	}
+	return oldValue, newValue, nil
+}
+
+// GetValues 解析变更集中的设置项，敏感设置会被解密
+func (s *OptionChangeSet) GetValues() (map[string]string, error) {
+	values := make(map[string]string)
+	if s.Values == "" {
+		return values, nil
+	}
+	if err := common.UnmarshalJsonStr(s.Values, &values); err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		plain, err := openOptionValue(key, value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = plain
+	}
+	return values, nil
+}
+
+// SetValues 写入变更集的设置项，敏感设置以密文保存
+func (s *OptionChangeSet) SetValues(values map[string]string) error {
+	sealed := make(map[string]string, len(values))
+	for key, value := range values {
+		var err error
+		if sealed[key], err = sealOptionValue(key, value); err != nil {
+			return err
+		}
+	}
+	data, err := common.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	s.Values = string(data)
+	return nil
+}
+
+// saveOptionsTx 在事务中写入设置并为取值发生变化的设置项记录历史
+func saveOptionsTx(tx *gorm.DB, values map[string]string, meta OptionChangeMeta) error {
+	batch := common.GetUUID()
+	now := common.GetTimestamp()
+	for key, value := range values {
+		option := Option{Key: key}
+		result := tx.Where(Option{Key: key}).Limit(1).Find(&option)
+		if result.Error != nil {
+			return result.Error
+		}
+		existed := result.RowsAffected > 0
+		if existed && option.Value == value {
+			continue
+		}
+		oldValue := option.Value
+		if !existed {
+			// 数据库中没有记录时内存中的默认值才是实际生效的旧值
+			common.OptionMapRWMutex.RLock()
+			oldValue = common.OptionMap[key]
+			common.OptionMapRWMutex.RUnlock()
+		}
+		option.Value = value
+		if err := tx.Save(&option).Error; err != nil {
+			return err
+		}
+		sealedOld, err := sealOptionHistoryValue(key, oldValue)
+		if err != nil {
+			return err
+		}
+		sealedNew, err := sealOptionHistoryValue(key, value)
+		if err != nil {
+			return err
+		}
+		revision := OptionRevision{
+			OptionKey:   key,
+			OldValue:    sealedOld,
+			NewValue:    sealedNew,
+			Existed:     existed,
+			Source:      meta.Source,
+			Batch:       batch,
+			ChangeSetId: meta.ChangeSetId,
+			OperatorId:  meta.OperatorId,
+			CreatedTime: now,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOptionRevisions 分页获取设置修改历史，key 非空时只返回该设置项
+func GetOptionRevisions(key string, startIdx int, num int) ([]*OptionRevision, int64, error) {
+	var revisions []*OptionRevision
+	var total int64
+	query := DB.Model(&OptionRevision{})
+	if key != "" {
+		query = query.Where("option_key = ?", key)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&revisions).Error
+	return revisions, total, err
+}
+
+func GetOptionRevisionById(id int) (*OptionRevision, error) {
+	var revision OptionRevision
+	if err := DB.First(&revision, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetOptionRevisionsByBatch 返回同一次原子提交中的全部修改
+func GetOptionRevisionsByBatch(batch string) ([]*OptionRevision, error) {
+	var revisions []*OptionRevision
+	err := DB.Where("batch = ?", batch).Order("id asc").Find(&revisions).Error
+	return revisions, err
+}
+
+// GetOptionValue 读取数据库中的设置值；ok 为 false 表示没有该设置项的记录
+func GetOptionValue(key string) (value string, ok bool, err error) {
+	var option Option
+	result := DB.Where(Option{Key: key}).Limit(1).Find(&option)
+	if result.Error != nil {
+		return "", false, result.Error
+	}
+	return option.Value, result.RowsAffected > 0, nil
+}
+
+func CreateOptionChangeSet(changeSet *OptionChangeSet) error {
+	now := common.GetTimestamp()
+	changeSet.Status = OptionChangeSetStatusDraft
+	changeSet.CreatedTime = now
+	changeSet.UpdatedTime = now
+	return DB.Create(changeSet).Error
+}
+
+// UpdateOptionChangeSetDraft 修改草稿状态变更集的说明与内容
+func UpdateOptionChangeSetDraft(changeSet *OptionChangeSet) error {
+	changeSet.UpdatedTime = common.GetTimestamp()
+	result := DB.Model(&OptionChangeSet{}).
+		Where("id = ? AND status = ?", changeSet.Id, OptionChangeSetStatusDraft).
+		Select("description", "values", "updated_time").
+		Updates(changeSet)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("change set is not a draft")
+	}
+	return nil
+}
+
+func GetOptionChangeSetById(id int) (*OptionChangeSet, error) {
+	var changeSet OptionChangeSet
+	if err := DB.First(&changeSet, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &changeSet, nil
+}
+
+func GetOptionChangeSets(status string, startIdx int, num int) ([]*OptionChangeSet, int64, error) {
+	var changeSets []*OptionChangeSet
+	var total int64
+	query := DB.Model(&OptionChangeSet{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&changeSets).Error
+	return changeSets, total, err
+}
+
+// DiscardOptionChangeSet 放弃草稿状态的变更集
+func DiscardOptionChangeSet(id int) error {
+	result := DB.Model(&OptionChangeSet{}).
+		Where("id = ? AND status = ?", id, OptionChangeSetStatusDraft).
+		Updates(map[string]any{"status": OptionChangeSetStatusDiscarded, "updated_time": common.GetTimestamp()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("change set is not a draft")
+	}
+	return nil
+}
+
+// ApplyOptionChangeSet 在一个事务中写入变更集的全部设置并将其标记为已应用。
+// 其他节点在下一次 SyncOptions 时一次性读取全部设置，因此不会看到只应用了一部分的变更集。
+func ApplyOptionChangeSet(id int, operatorId int) (*OptionChangeSet, error) {
+	var changeSet OptionChangeSet
+	var values map[string]string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&changeSet, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if changeSet.Status != OptionChangeSetStatusDraft {
+			return fmt.Errorf("change set is %s", changeSet.Status)
+		}
+		var err error
+		if values, err = changeSet.GetValues(); err != nil {
+			return err
+		}
+		for key, value := range values {
+			if err := validateOptionValue(key, value); err != nil {
+				return err
+			}
+		}
+		now := common.GetTimestamp()
+		result := tx.Model(&OptionChangeSet{}).
+			Where("id = ? AND status = ?", id, OptionChangeSetStatusDraft).
+			Updates(map[string]any{
+				"status":       OptionChangeSetStatusApplied,
+				"applied_by":   operatorId,
+				"applied_time": now,
+				"updated_time": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("change set was applied concurrently")
+		}
+		return saveOptionsTx(tx, values, OptionChangeMeta{
+			OperatorId:  operatorId,
+			Source:      OptionChangeSourceChangeSet,
+			ChangeSetId: id,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshOptionMap(values)
+	changeSet.Status = OptionChangeSetStatusApplied
+	return &changeSet, nil
+}
+
+// refreshOptionMap 在事务提交后更新内存中的设置
+func refreshOptionMap(values map[string]string) {
+	for key, value := range values {
+		if err := updateOptionMap(key, value); err != nil {
+			common.SysError(fmt.Sprintf("failed to apply option %s: %v", key, err))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTestOptionMap(t *testing.T, values map[string]string) {
+	t.Helper()
+	previous := common.OptionMap
+	common.OptionMap = values
+	t.Cleanup(func() {
+		common.OptionMap = previous
+	})
+}
+
+func useCryptoSecretConfigured(t *testing.T, configured bool) {
+	t.Helper()
+	previous := common.CryptoSecretConfigured
+	common.CryptoSecretConfigured = configured
+	t.Cleanup(func() {
+		common.CryptoSecretConfigured = previous
+	})
+}
+
+func TestUpdateOptionByRecordsRevisions(t *testing.T) {
+	truncateTables(t)
+	useTestOptionMap(t, map[string]string{"RevisionTestNotice": "default"})
+
+	meta := OptionChangeMeta{OperatorId: 7, Source: OptionChangeSourceUpdate}
+	require.NoError(t, UpdateOptionBy("RevisionTestNotice", "first", meta))
+	require.NoError(t, UpdateOptionBy("RevisionTestNotice", "first", meta))
+	require.NoError(t, UpdateOptionBy("RevisionTestNotice", "second", meta))
+
+	revisions, total, err := GetOptionRevisions("RevisionTestNotice", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total, "unchanged writes must not create revisions")
+	assert.Equal(t, "first", revisions[0].OldValue)
+	assert.Equal(t, "second", revisions[0].NewValue)
+	assert.True(t, revisions[0].Existed)
+	assert.Equal(t, "default", revisions[1].OldValue, "first write records the in-memory default")
+	assert.False(t, revisions[1].Existed)
+	assert.Equal(t, 7, revisions[1].OperatorId)
+}
+
+func TestApplyOptionChangeSetIsAtomic(t *testing.T) {
+	truncateTables(t)
+	useTestOptionMap(t, map[string]string{"RevisionTestA": "a0", "RevisionTestB": "b0"})
+
+	changeSet := &OptionChangeSet{Description: "rotate", CreatedBy: 1}
+	require.NoError(t, changeSet.SetValues(map[string]string{"RevisionTestA": "a1", "RevisionTestB": "b1"}))
+	require.NoError(t, CreateOptionChangeSet(changeSet))
+
+	applied, err := ApplyOptionChangeSet(changeSet.Id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, OptionChangeSetStatusApplied, applied.Status)
+	assert.Equal(t, "a1", common.OptionMap["RevisionTestA"])
+	assert.Equal(t, "b1", common.OptionMap["RevisionTestB"])
+
+	revisions, total, err := GetOptionRevisions("", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	assert.Equal(t, revisions[0].Batch, revisions[1].Batch)
+	assert.Equal(t, changeSet.Id, revisions[0].ChangeSetId)
+
+	_, err = ApplyOptionChangeSet(changeSet.Id, 2)
+	assert.Error(t, err, "an applied change set cannot be applied again")
+	assert.Error(t, DiscardOptionChangeSet(changeSet.Id))
+}
+
+func TestSensitiveOptionHistoryIsEncrypted(t *testing.T) {
+	truncateTables(t)
+	useCryptoSecretConfigured(t, true)
+	useTestOptionMap(t, map[string]string{"RevisionTestSecret": ""})
+
+	meta := OptionChangeMeta{OperatorId: 1, Source: OptionChangeSourceUpdate}
+	require.NoError(t, UpdateOptionBy("RevisionTestSecret", "sk-first", meta))
+	require.NoError(t, UpdateOptionBy("RevisionTestSecret", "sk-second", meta))
+
+	revisions, _, err := GetOptionRevisions("RevisionTestSecret", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.NotContains(t, revisions[0].OldValue, "sk-first")
+	assert.NotContains(t, revisions[0].NewValue, "sk-second")
+	oldValue, newValue, err := revisions[0].PlainValues()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-first", oldValue)
+	assert.Equal(t, "sk-second", newValue)
+
+	changeSet := &OptionChangeSet{}
+	require.NoError(t, changeSet.SetValues(map[string]string{"RevisionTestSecret": "sk-third", "RevisionTestNotice": "plain"}))
+	assert.NotContains(t, changeSet.Values, "sk-third")
+	assert.Contains(t, changeSet.Values, "plain")
+	values, err := changeSet.GetValues()
+	require.NoError(t, err)
+	assert.Equal(t, "sk-third", values["RevisionTestSecret"])
+}
+
+func TestSensitiveOptionHistoryIsRedactedWithoutConfiguredSecret(t *testing.T) {
+	truncateTables(t)
+	useCryptoSecretConfigured(t, false)
+	useTestOptionMap(t, map[string]string{"RevisionTestSecret": "", "RevisionTestNotice": ""})
+
+	meta := OptionChangeMeta{OperatorId: 1, Source: OptionChangeSourceUpdate}
+	require.NoError(t, UpdateOptionBy("RevisionTestSecret", "sk-first", meta))
+	require.NoError(t, UpdateOptionBy("RevisionTestSecret", "sk-second", meta))
+	assert.Equal(t, "sk-second", common.OptionMap["RevisionTestSecret"])
+
+	revisions, _, err := GetOptionRevisions("RevisionTestSecret", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, optionSecretRedacted, revisions[0].OldValue)
+	assert.Equal(t, optionSecretRedacted, revisions[0].NewValue)
+	_, _, err = revisions[0].PlainValues()
+	assert.ErrorContains(t, err, "CRYPTO_SECRET")
+
+	changeSet := &OptionChangeSet{}
+	assert.Error(t, changeSet.SetValues(map[string]string{"RevisionTestSecret": "sk-third"}),
+		"sensitive values cannot be staged without a configured secret")
+	require.NoError(t, changeSet.SetValues(map[string]string{"RevisionTestNotice": "plain"}))
+}
+
+func TestOpenOptionValueReportsUndecryptableHistory(t *testing.T) {
+	revision := OptionRevision{OptionKey: "RevisionTestSecret", OldValue: optionSecretPrefix + "not-a-ciphertext"}
+	_, _, err := revision.PlainValues()
+	assert.ErrorContains(t, err, "cannot decrypt")
+}
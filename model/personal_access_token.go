@@ -0,0 +1,159 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+const (
+	// PersonalAccessTokenPrefix 用于把管理令牌与旧版 access_token 以及 API 令牌区分开
+	PersonalAccessTokenPrefix = "pat_"
+
+	MaxPersonalAccessTokensPerUser = 20
+
+	// personalAccessTokenTouchInterval 限制最近使用时间的写入频率
+	personalAccessTokenTouchInterval = 60
+)
+
+var (
+	ErrPersonalAccessTokenExpired  = errors.New("personal access token expired")
+	ErrPersonalAccessTokenTooMany  = fmt.Errorf("a user can hold at most %d personal access tokens", MaxPersonalAccessTokensPerUser)
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+)
+
+// PersonalAccessToken 是用户为自动化脚本创建的管理 API 令牌。
+// 只保存密钥的 SHA-256，Scopes 为逗号分隔的 resource:action 权限
+type PersonalAccessToken struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"size:64"`
+	KeyHash      string `json:"-" gorm:"type:char(64);uniqueIndex"`
+	KeyPrefix    string `json:"key_prefix" gorm:"size:16"`
+	Scopes       string `json:"scopes" gorm:"type:text"`
+	AllowIps     string `json:"allow_ips" gorm:"type:text"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"size:64"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+func hashPersonalAccessToken(key string) string {
+	return hex.EncodeToString(common.Sha256Raw([]byte(key)))
+}
+
+func (token *PersonalAccessToken) GetScopes() []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(token.Scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func (token *PersonalAccessToken) GetIpLimits() []string {
+	ipLimits := make([]string, 0)
+	for _, ip := range strings.FieldsFunc(token.AllowIps, func(r rune) bool {
+		return r == '\n' || r == ',' || r == ' '
+	}) {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ipLimits = append(ipLimits, ip)
+		}
+	}
+	return ipLimits
+}
+
+func (token *PersonalAccessToken) IsExpired() bool {
+	return token.ExpiredTime != -1 && token.ExpiredTime < common.GetTimestamp()
+}
+
+// CreatePersonalAccessToken 生成并保存新令牌，返回只展示一次的明文密钥
+func CreatePersonalAccessToken(token *PersonalAccessToken) (string, error) {
+	random, err := common.GenerateRandomCharsKey(40)
+	if err != nil {
+		return "", err
+	}
+	key := PersonalAccessTokenPrefix + random
+	token.KeyHash = hashPersonalAccessToken(key)
+	token.KeyPrefix = key[:len(PersonalAccessTokenPrefix)+6]
+	token.CreatedTime = common.GetTimestamp()
+	token.LastUsedTime = 0
+	token.LastUsedIp = ""
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&PersonalAccessToken{}).Where("user_id = ?", token.UserId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxPersonalAccessTokensPerUser {
+			return ErrPersonalAccessTokenTooMany
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func GetUserPersonalAccessTokens(userId int) ([]*PersonalAccessToken, error) {
+	var tokens []*PersonalAccessToken
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeletePersonalAccessToken 吊销令牌，吊销后立即失效
+func DeletePersonalAccessToken(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// ValidatePersonalAccessToken 按密钥查找令牌；找不到时返回 nil, nil
+func ValidatePersonalAccessToken(key string) (*PersonalAccessToken, error) {
+	if !strings.HasPrefix(key, PersonalAccessTokenPrefix) {
+		return nil, nil
+	}
+	token := &PersonalAccessToken{}
+	err := DB.Where("key_hash = ?", hashPersonalAccessToken(key)).First(token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrDatabase, err)
+	}
+	if token.IsExpired() {
+		return nil, ErrPersonalAccessTokenExpired
+	}
+	return token, nil
+}
+
+// TouchLastUsed 异步记录最近使用时间与来源 IP，同一令牌每分钟最多写一次
+func (token *PersonalAccessToken) TouchLastUsed(ip string) {
+	now := common.GetTimestamp()
+	if now-token.LastUsedTime < personalAccessTokenTouchInterval && token.LastUsedIp == ip {
+		return
+	}
+	id := token.Id
+	db := DB
+	gopool.Go(func() {
+		err := db.Model(&PersonalAccessToken{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"last_used_time": now,
+			"last_used_ip":   ip,
+		}).Error
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to update personal access token %d usage: %s", id, err.Error()))
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaAdjustUsage 记录受额度上限约束的操作者每天已调整的额度总量
+type QuotaAdjustUsage struct {
+	UserId int    `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Day    string `json:"day" gorm:"type:varchar(10);primaryKey"`
+	Amount int64  `json:"amount" gorm:"bigint;not null;default:0"`
+}
+
+// QuotaAdjustDay 返回额度调整计数所属的自然日（UTC）
+func QuotaAdjustDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// ReserveQuotaAdjustment 在当日累计量不超过 limit 时原子地计入 amount，
+// 返回 false 表示超出上限；多个节点同时调整时由条件更新保证总量不超限。
+func ReserveQuotaAdjustment(userId int, day string, amount int, limit int) (bool, error) {
+	if amount <= 0 {
+		return true, nil
+	}
+	if amount > limit {
+		return false, nil
+	}
+	var reserved bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		usage := QuotaAdjustUsage{UserId: userId, Day: day}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&QuotaAdjustUsage{}).
+			Where("user_id = ? AND day = ? AND amount + ? <= ?", userId, day, amount, limit).
+			Update("amount", gorm.Expr("amount + ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		reserved = result.RowsAffected > 0
+		return nil
+	})
+	return reserved, err
+}
+
+// ReleaseQuotaAdjustment 撤销 ReserveQuotaAdjustment 计入的额度，用于调整最终没有执行的情况
+func ReleaseQuotaAdjustment(userId int, day string, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	return DB.Model(&QuotaAdjustUsage{}).
+		Where("user_id = ? AND day = ?", userId, day).
+		Update("amount", gorm.Expr("CASE WHEN amount > ? THEN amount - ? ELSE 0 END", amount, amount)).Error
+}
+
+// GetQuotaAdjustUsage 返回操作者当日已调整的额度总量
+func GetQuotaAdjustUsage(userId int, day string) (int64, error) {
+	var usage QuotaAdjustUsage
+	err := DB.Where("user_id = ? AND day = ?", userId, day).Limit(1).Find(&usage).Error
+	return usage.Amount, err
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveQuotaAdjustmentEnforcesDailyTotal(t *testing.T) {
+	truncateTables(t)
+	day := "2026-01-02"
+
+	ok, err := ReserveQuotaAdjustment(3, day, 600, 1000)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ReserveQuotaAdjustment(3, day, 600, 1000)
+	require.NoError(t, err)
+	assert.False(t, ok, "repeated adjustments must not exceed the daily total")
+
+	ok, err = ReserveQuotaAdjustment(3, "2026-01-03", 600, 1000)
+	require.NoError(t, err)
+	assert.True(t, ok, "the limit resets on the next day")
+
+	require.NoError(t, ReleaseQuotaAdjustment(3, day, 600))
+	ok, err = ReserveQuotaAdjustment(3, day, 1000, 1000)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	used, err := GetQuotaAdjustUsage(3, day)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1000, used)
+}
@@ -0,0 +1,441 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 版本化数据库迁移。
+//
+// 表结构的新增仍由 AutoMigrate 完成；修改列类型、回填数据等 AutoMigrate
+// 无法表达的变更以编号迁移的形式登记在 schemaMigrations 中，按版本号顺序执行，
+// 并记录到 schema_migrations 表。修改已有列类型的迁移标记为 BeforeAutoMigrate，
+// 在 AutoMigrate 之前执行，其余迁移在 AutoMigrate 之后执行。每个迁移按数据库方言
+// 提供执行步骤，可选提供回滚步骤。
+//
+// 迁移状态与锁统一保存在主库中（ClickHouse 日志库不支持事务和唯一约束）。
+
+type MigrationTarget string
+
+const (
+	MigrationTargetMain MigrationTarget = "main"
+	MigrationTargetLog  MigrationTarget = "log"
+)
+
+// MigrationStep 是某个方言下的迁移步骤：先依次执行 SQL，再执行 Func。
+// Func 无法在 dry-run 中展示具体 SQL，因此必须填写 Description。
+type MigrationStep struct {
+	SQL         []string
+	Func        func(db *gorm.DB) error
+	Description string
+}
+
+// Migration 是一个编号迁移。Up 中没有登记的方言视为无需变更；
+// Down 为空的迁移不可回滚。
+//
+// Baseline 迁移是版本化迁移出现之前随旧版本发布的升级脚本，现有数据库早已包含
+// 这些变更，执行时只登记为已执行而不运行步骤，保留在这里用于追溯升级历史。
+//
+// BeforeAutoMigrate 迁移在启动时先于 AutoMigrate 执行，用于修改已有列的类型，
+// 避免 AutoMigrate 按旧的列定义做出错误的变更；表或列尚不存在时应当跳过。
+type Migration struct {
+	Version           int
+	Name              string
+	Target            MigrationTarget
+	Up                map[common.DatabaseType]MigrationStep
+	Down              map[common.DatabaseType]MigrationStep
+	Baseline          bool
+	BeforeAutoMigrate bool
+}
+
+// SchemaMigration 记录已执行的迁移
+type SchemaMigration struct {
+	Target    string `json:"target" gorm:"type:varchar(16);primaryKey"`
+	Version   int    `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string `json:"name" gorm:"type:varchar(128)"`
+	AppliedAt int64  `json:"applied_at" gorm:"bigint"`
+}
+
+// SchemaMigrationLock 保证同一时间只有一个节点执行迁移
+type SchemaMigrationLock struct {
+	Name        string `gorm:"type:varchar(32);primaryKey"`
+	LockedBy    string `gorm:"type:varchar(128)"`
+	LockedUntil int64  `gorm:"bigint"`
+}
+
+// MigrationStatus 是某个迁移在当前数据库上的状态
+type MigrationStatus struct {
+	Version   int             `json:"version"`
+	Name      string          `json:"name"`
+	Target    MigrationTarget `json:"target"`
+	Applied   bool            `json:"applied"`
+	AppliedAt int64           `json:"applied_at,omitempty"`
+}
+
+// SkipAutoSchemaMigrations 为 true 时 InitDB/InitLogDB 不执行待执行的迁移，
+// 供 `newapi admin migrate` 等需要自行控制迁移的命令使用。
+var SkipAutoSchemaMigrations bool
+
+// SkipAutoMigrate 为 true 时 InitDB/InitLogDB 只建立连接，不执行 AutoMigrate 与编号迁移，
+// 供 dry-run 与查看状态等不能修改数据库的命令使用。
+var SkipAutoMigrate bool
+
+const (
+	schemaMigrationLockName  = "schema"
+	schemaMigrationLockLease = 2 * time.Minute
+	schemaMigrationLockWait  = 10 * time.Minute
+)
+
+// schemaMigrationLockRenewInterval 是持有迁移锁期间续租的间隔，远小于租期，
+// 长时间运行的迁移不会因租期到期而被其他节点接管
+var schemaMigrationLockRenewInterval = 30 * time.Second
+
+var ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+
+func migrationDB(target MigrationTarget) (*gorm.DB, common.DatabaseType) {
+	if target == MigrationTargetLog {
+		return LOG_DB, common.LogDatabaseType()
+	}
+	return DB, common.MainDatabaseType()
+}
+
+func migrationsFor(target MigrationTarget) []Migration {
+	var migrations []Migration
+	for _, migration := range schemaMigrations {
+		if migration.Target == target {
+			migrations = append(migrations, migration)
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+func ensureSchemaMigrationTables() error {
+	return DB.AutoMigrate(&SchemaMigration{}, &SchemaMigrationLock{})
+}
+
+func appliedSchemaMigrations(target MigrationTarget) (map[int]SchemaMigration, error) {
+	var rows []SchemaMigration
+	// 只读操作不创建迁移表，表不存在时视为没有执行过任何迁移
+	if !DB.Migrator().HasTable(&SchemaMigration{}) {
+		return map[int]SchemaMigration{}, nil
+	}
+	if err := DB.Where("target = ?", string(target)).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// GetSchemaMigrationStatus 返回目标数据库上全部登记迁移的执行状态
+func GetSchemaMigrationStatus(target MigrationTarget) ([]MigrationStatus, error) {
+	applied, err := appliedSchemaMigrations(target)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, migration := range migrationsFor(target) {
+		row, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Target:    target,
+			Applied:   ok,
+			AppliedAt: row.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// acquireSchemaMigrationLock 获取迁移锁；其他节点持有未过期的锁时等待其释放
+func acquireSchemaMigrationLock(owner string) error {
+	deadline := time.Now().Add(schemaMigrationLockWait)
+	for {
+		now := time.Now()
+		lock := SchemaMigrationLock{
+			Name:        schemaMigrationLockName,
+			LockedBy:    owner,
+			LockedUntil: now.Add(schemaMigrationLockLease).Unix(),
+		}
+		if err := DB.Create(&lock).Error; err == nil {
+			return nil
+		}
+		// 锁已存在：接管已过期的锁（持有者可能在迁移中途崩溃）
+		result := DB.Model(&SchemaMigrationLock{}).
+			Where("name = ? AND locked_until < ?", schemaMigrationLockName, now.Unix()).
+			Updates(map[string]any{"locked_by": owner, "locked_until": lock.LockedUntil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		if now.After(deadline) {
+			return errors.New("timed out waiting for another node to finish schema migrations")
+		}
+		common.SysLog("schema migrations are running on another node, waiting")
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// renewSchemaMigrationLock 在 stop 被调用前定期延长迁移锁的租期
+func renewSchemaMigrationLock(owner string) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(schemaMigrationLockRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			result := DB.Model(&SchemaMigrationLock{}).
+				Where("name = ? AND locked_by = ?", schemaMigrationLockName, owner).
+				Update("locked_until", time.Now().Add(schemaMigrationLockLease).Unix())
+			if result.Error != nil {
+				common.SysError("failed to renew schema migration lock: " + result.Error.Error())
+			} else if result.RowsAffected == 0 {
+				common.SysError("schema migration lock was taken over by another node")
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// lockSchemaMigrations 获取迁移锁并在持有期间续租，返回的 unlock 停止续租并释放锁
+func lockSchemaMigrations() (unlock func(), err error) {
+	owner := schemaMigrationLockOwner()
+	if err := ensureSchemaMigrationTables(); err != nil {
+		return nil, err
+	}
+	if err := acquireSchemaMigrationLock(owner); err != nil {
+		return nil, err
+	}
+	stopRenew := renewSchemaMigrationLock(owner)
+	return func() {
+		stopRenew()
+		releaseSchemaMigrationLock(owner)
+	}, nil
+}
+
+func releaseSchemaMigrationLock(owner string) {
+	err := DB.Where("name = ? AND locked_by = ?", schemaMigrationLockName, owner).Delete(&SchemaMigrationLock{}).Error
+	if err != nil {
+		common.SysError("failed to release schema migration lock: " + err.Error())
+	}
+}
+
+func schemaMigrationLockOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), common.GetRandomString(6))
+}
+
+// supportsTransactionalDDL 报告方言是否能在事务中回滚 DDL。
+// MySQL 的 DDL 会隐式提交，ClickHouse 不支持事务。
+func supportsTransactionalDDL(dbType common.DatabaseType) bool {
+	return dbType == common.DatabaseTypeSQLite || dbType == common.DatabaseTypePostgreSQL
+}
+
+func describeMigrationStep(out io.Writer, direction string, migration Migration, step MigrationStep, ok bool) {
+	fmt.Fprintf(out, "-- %s %s %d_%s\n", direction, migration.Target, migration.Version, migration.Name)
+	if migration.Baseline {
+		fmt.Fprintln(out, "-- baseline migration, recorded without running")
+		return
+	}
+	if !ok {
+		fmt.Fprintln(out, "-- no changes for this database")
+		return
+	}
+	for _, statement := range step.SQL {
+		fmt.Fprintln(out, strings.TrimSpace(statement)+";")
+	}
+	if step.Func != nil {
+		fmt.Fprintf(out, "-- %s\n", step.Description)
+	}
+}
+
+func runMigrationStep(db *gorm.DB, step MigrationStep) error {
+	for _, statement := range step.SQL {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	if step.Func != nil {
+		return step.Func(db)
+	}
+	return nil
+}
+
+// executeSchemaMigration 执行迁移步骤并更新 schema_migrations。
+// 迁移数据库与主库为同一连接且支持事务 DDL 时，两者在同一事务内完成。
+func executeSchemaMigration(migration Migration, step MigrationStep, ok bool, record func(tx *gorm.DB) error) error {
+	db, dbType := migrationDB(migration.Target)
+	if db == DB && supportsTransactionalDDL(dbType) {
+		return DB.Transaction(func(tx *gorm.DB) error {
+			if ok {
+				if err := runMigrationStep(tx, step); err != nil {
+					return err
+				}
+			}
+			return record(tx)
+		})
+	}
+	if ok {
+		if err := runMigrationStep(db, step); err != nil {
+			return err
+		}
+	}
+	return record(DB)
+}
+
+// RunSchemaMigrations 按版本顺序执行目标数据库上尚未执行的迁移。
+// dryRun 为 true 时只把将要执行的 SQL 写入 out，不修改数据库。返回已执行（或将执行）的迁移。
+func RunSchemaMigrations(target MigrationTarget, dryRun bool, out io.Writer) ([]Migration, error) {
+	return runSchemaMigrations(target, dryRun, out, false)
+}
+
+// runSchemaMigrations 执行尚未执行的迁移；beforeAutoMigrateOnly 为 true 时只执行 BeforeAutoMigrate 迁移
+func runSchemaMigrations(target MigrationTarget, dryRun bool, out io.Writer, beforeAutoMigrateOnly bool) ([]Migration, error) {
+	if !dryRun {
+		unlock, err := lockSchemaMigrations()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	// 持有锁之后再读取状态，其他节点可能刚刚完成了迁移
+	applied, err := appliedSchemaMigrations(target)
+	if err != nil {
+		return nil, err
+	}
+	_, dbType := migrationDB(target)
+	var executed []Migration
+	for _, migration := range migrationsFor(target) {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if beforeAutoMigrateOnly && !migration.BeforeAutoMigrate {
+			continue
+		}
+		step, ok := migration.Up[dbType]
+		if dryRun {
+			describeMigrationStep(out, "up", migration, step, ok)
+			executed = append(executed, migration)
+			continue
+		}
+		if migration.Baseline {
+			ok = false
+		}
+		err := executeSchemaMigration(migration, step, ok, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{
+				Target:    string(target),
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: common.GetTimestamp(),
+			}).Error
+		})
+		if err != nil {
+			return executed, fmt.Errorf("migration %s %d_%s failed: %w", target, migration.Version, migration.Name, err)
+		}
+		common.SysLog(fmt.Sprintf("applied %s schema migration %d_%s", target, migration.Version, migration.Name))
+		executed = append(executed, migration)
+	}
+	return executed, nil
+}
+
+// RollbackSchemaMigrations 按版本倒序回滚版本号大于 toVersion 的已执行迁移。
+// 遇到不可回滚的迁移时在修改任何数据之前返回 ErrIrreversibleMigration。
+func RollbackSchemaMigrations(target MigrationTarget, toVersion int, dryRun bool, out io.Writer) ([]Migration, error) {
+	if !dryRun {
+		unlock, err := lockSchemaMigrations()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	applied, err := appliedSchemaMigrations(target)
+	if err != nil {
+		return nil, err
+	}
+	_, dbType := migrationDB(target)
+	migrations := migrationsFor(target)
+	var pending []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= toVersion {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		_, hasUp := migration.Up[dbType]
+		if _, hasDown := migration.Down[dbType]; hasUp && !hasDown && !migration.Baseline {
+			return nil, fmt.Errorf("%w: %s %d_%s", ErrIrreversibleMigration, target, migration.Version, migration.Name)
+		}
+		pending = append(pending, migration)
+	}
+	var rolledBack []Migration
+	for _, migration := range pending {
+		step, ok := migration.Down[dbType]
+		if dryRun {
+			describeMigrationStep(out, "down", migration, step, ok)
+			rolledBack = append(rolledBack, migration)
+			continue
+		}
+		if migration.Baseline {
+			ok = false
+		}
+		err := executeSchemaMigration(migration, step, ok, func(tx *gorm.DB) error {
+			return tx.Where("target = ? AND version = ?", string(target), migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("rollback of %s %d_%s failed: %w", target, migration.Version, migration.Name, err)
+		}
+		common.SysLog(fmt.Sprintf("rolled back %s schema migration %d_%s", target, migration.Version, migration.Name))
+		rolledBack = append(rolledBack, migration)
+	}
+	return rolledBack, nil
+}
+
+// autoRunSchemaMigrations 在启动时执行迁移：beforeAutoMigrate 为 true 时在 AutoMigrate
+// 之前调用，只执行 BeforeAutoMigrate 迁移；之后再次调用时执行其余迁移
+func autoRunSchemaMigrations(target MigrationTarget, beforeAutoMigrate bool) error {
+	// 设置 SCHEMA_MIGRATION_AUTO=false 后需要通过 `newapi admin migrate` 手动执行迁移
+	if SkipAutoSchemaMigrations || !common.GetEnvOrDefaultBool("SCHEMA_MIGRATION_AUTO", true) {
+		if beforeAutoMigrate {
+			return nil
+		}
+		statuses, err := GetSchemaMigrationStatus(target)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if !status.Applied {
+				common.SysLog(fmt.Sprintf("%s schema migration %d_%s is pending, run `newapi admin migrate` to apply it", target, status.Version, status.Name))
+			}
+		}
+		return nil
+	}
+	_, err := runSchemaMigrations(target, false, io.Discard, beforeAutoMigrate)
+	return err
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTestSchemaMigrations(t *testing.T, migrations []Migration) {
+	t.Helper()
+	previous := schemaMigrations
+	schemaMigrations = migrations
+	t.Cleanup(func() {
+		schemaMigrations = previous
+		DB.Exec("DROP TABLE IF EXISTS migration_probe")
+		DB.Exec("DELETE FROM schema_migrations")
+		DB.Exec("DELETE FROM schema_migration_locks")
+	})
+}
+
+func sqliteStep(statements ...string) map[common.DatabaseType]MigrationStep {
+	return map[common.DatabaseType]MigrationStep{common.DatabaseTypeSQLite: {SQL: statements}}
+}
+
+func TestSchemaMigrationsApplyDryRunAndRollback(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 2, Name: "probe_column", Target: MigrationTargetMain,
+			Up:   sqliteStep("ALTER TABLE migration_probe ADD COLUMN note text"),
+			Down: sqliteStep("ALTER TABLE migration_probe DROP COLUMN note"),
+		},
+		{
+			Version: 1, Name: "probe_table", Target: MigrationTargetMain,
+			Up:   sqliteStep("CREATE TABLE migration_probe (id integer)"),
+			Down: sqliteStep("DROP TABLE migration_probe"),
+		},
+	})
+
+	var out bytes.Buffer
+	planned, err := RunSchemaMigrations(MigrationTargetMain, true, &out)
+	require.NoError(t, err)
+	require.Len(t, planned, 2)
+	assert.Contains(t, out.String(), "CREATE TABLE migration_probe (id integer);")
+	assert.False(t, DB.Migrator().HasTable("migration_probe"), "dry-run must not execute SQL")
+
+	applied, err := RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	assert.Equal(t, 1, applied[0].Version, "migrations run in version order")
+	assert.True(t, DB.Migrator().HasColumn("migration_probe", "note"))
+
+	applied, err = RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	rolledBack, err := RollbackSchemaMigrations(MigrationTargetMain, 1, false, io.Discard)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 1)
+	assert.False(t, DB.Migrator().HasColumn("migration_probe", "note"))
+
+	statuses, err := GetSchemaMigrationStatus(MigrationTargetMain)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestSchemaMigrationFailureRollsBackTransaction(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 1, Name: "broken", Target: MigrationTargetMain,
+			Up: sqliteStep("CREATE TABLE migration_probe (id integer)", "ALTER TABLE missing_table ADD COLUMN x text"),
+		},
+	})
+
+	_, err := RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.Error(t, err)
+	assert.False(t, DB.Migrator().HasTable("migration_probe"))
+	statuses, err := GetSchemaMigrationStatus(MigrationTargetMain)
+	require.NoError(t, err)
+	assert.False(t, statuses[0].Applied)
+
+	var locks int64
+	require.NoError(t, DB.Model(&SchemaMigrationLock{}).Count(&locks).Error)
+	assert.Zero(t, locks, "lock must be released after a failure")
+}
+
+func TestSchemaMigrationRollbackRefusesIrreversible(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 1, Name: "probe_table", Target: MigrationTargetMain,
+			Up: sqliteStep("CREATE TABLE migration_probe (id integer)"),
+		},
+	})
+	_, err := RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.NoError(t, err)
+
+	_, err = RollbackSchemaMigrations(MigrationTargetMain, 0, false, io.Discard)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+	assert.True(t, DB.Migrator().HasTable("migration_probe"))
+}
+
+func TestSchemaMigrationLockTakeoverAfterExpiry(t *testing.T) {
+	useTestSchemaMigrations(t, nil)
+	require.NoError(t, ensureSchemaMigrationTables())
+	require.NoError(t, acquireSchemaMigrationLock("node-a"))
+
+	// 过期的锁可以被接管
+	require.NoError(t, DB.Model(&SchemaMigrationLock{}).Where("name = ?", schemaMigrationLockName).
+		Update("locked_until", common.GetTimestamp()-1).Error)
+	require.NoError(t, acquireSchemaMigrationLock("node-b"))
+
+	var lock SchemaMigrationLock
+	require.NoError(t, DB.First(&lock, "name = ?", schemaMigrationLockName).Error)
+	assert.Equal(t, "node-b", lock.LockedBy)
+	releaseSchemaMigrationLock("node-a")
+	require.NoError(t, DB.First(&lock, "name = ?", schemaMigrationLockName).Error, "only the holder can release the lock")
+	releaseSchemaMigrationLock("node-b")
+}
+
+func TestSchemaMigrationBaselineIsRecordedWithoutRunning(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 1, Name: "legacy_probe", Target: MigrationTargetMain, Baseline: true,
+			Up: sqliteStep("CREATE TABLE migration_probe (id integer)"),
+		},
+	})
+
+	applied, err := RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.False(t, DB.Migrator().HasTable("migration_probe"), "baseline migrations must not run")
+
+	rolledBack, err := RollbackSchemaMigrations(MigrationTargetMain, 0, false, io.Discard)
+	require.NoError(t, err, "baseline migrations can be unrecorded")
+	assert.Len(t, rolledBack, 1)
+}
+
+func TestSchemaMigrationWarnOnlyStepDoesNotAbort(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 1, Name: "probe_table", Target: MigrationTargetMain,
+			Up: sqliteStep("CREATE TABLE migration_probe (id integer)"),
+		},
+		{
+			Version: 2, Name: "warn_only", Target: MigrationTargetMain,
+			Up: map[common.DatabaseType]MigrationStep{
+				common.DatabaseTypeSQLite: warnOnlyMigrationStep(MigrationStep{SQL: []string{"ALTER TABLE missing_table ADD COLUMN x text"}}),
+			},
+		},
+	})
+
+	applied, err := RunSchemaMigrations(MigrationTargetMain, false, io.Discard)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.True(t, DB.Migrator().HasTable("migration_probe"))
+}
+
+func TestSchemaMigrationsBeforeAutoMigrateRunFirst(t *testing.T) {
+	useTestSchemaMigrations(t, []Migration{
+		{
+			Version: 1, Name: "probe_table", Target: MigrationTargetMain, BeforeAutoMigrate: true,
+			Up: sqliteStep("CREATE TABLE migration_probe (id integer)"),
+		},
+		{
+			Version: 2, Name: "probe_column", Target: MigrationTargetMain,
+			Up: sqliteStep("ALTER TABLE migration_probe ADD COLUMN note text"),
+		},
+	})
+
+	applied, err := runSchemaMigrations(MigrationTargetMain, false, io.Discard, true)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.True(t, DB.Migrator().HasTable("migration_probe"))
+	assert.False(t, DB.Migrator().HasColumn("migration_probe", "note"), "other migrations wait until after AutoMigrate")
+
+	applied, err = runSchemaMigrations(MigrationTargetMain, false, io.Discard, false)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+}
+
+func TestShrinkColumnStepRefusesToTruncate(t *testing.T) {
+	useTestSchemaMigrations(t, nil)
+	require.NoError(t, DB.Exec("CREATE TABLE migration_probe (id integer, note text)").Error)
+	require.NoError(t, DB.Exec("INSERT INTO migration_probe (id, note) VALUES (1, 'short')").Error)
+	step := shrinkColumnStep("migration_probe", "note", 5, "UPDATE migration_probe SET id = 2")
+
+	require.NoError(t, step.Func(DB))
+	require.NoError(t, DB.Exec("INSERT INTO migration_probe (id, note) VALUES (3, 'too long')").Error)
+	err := step.Func(DB)
+	assert.ErrorContains(t, err, "would truncate")
+	var count int64
+	require.NoError(t, DB.Raw("SELECT COUNT(*) FROM migration_probe WHERE id = 3").Scan(&count).Error)
+	assert.EqualValues(t, 1, count, "the statement must not run when values are too long")
+}
+
+func TestSchemaMigrationLockIsRenewedWhileHeld(t *testing.T) {
+	useTestSchemaMigrations(t, nil)
+	previousInterval := schemaMigrationLockRenewInterval
+	schemaMigrationLockRenewInterval = 10 * time.Millisecond
+	t.Cleanup(func() { schemaMigrationLockRenewInterval = previousInterval })
+
+	unlock, err := lockSchemaMigrations()
+	require.NoError(t, err)
+	require.NoError(t, DB.Model(&SchemaMigrationLock{}).Where("name = ?", schemaMigrationLockName).
+		Update("locked_until", common.GetTimestamp()-1).Error)
+
+	require.Eventually(t, func() bool {
+		var lock SchemaMigrationLock
+		return DB.First(&lock, "name = ?", schemaMigrationLockName).Error == nil && lock.LockedUntil > common.GetTimestamp()
+	}, time.Second, 10*time.Millisecond, "the lease must be renewed while migrations run")
+	takeover := DB.Model(&SchemaMigrationLock{}).
+		Where("name = ? AND locked_until < ?", schemaMigrationLockName, common.GetTimestamp()).
+		Update("locked_by", "node-b")
+	require.NoError(t, takeover.Error)
+	assert.Zero(t, takeover.RowsAffected, "a renewed lock cannot be taken over")
+
+	unlock()
+	var locks int64
+	require.NoError(t, DB.Model(&SchemaMigrationLock{}).Count(&locks).Error)
+	assert.Zero(t, locks)
+}
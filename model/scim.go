@@ -0,0 +1,182 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ExternalIdentityProviderSCIM 标记由 SCIM 身份源下发的 externalId
+const ExternalIdentityProviderSCIM = "scim"
+
+var ErrScimFilterUnsupported = errors.New("unsupported scim filter")
+
+// scimUserFilterColumns 是 SCIM 查询允许过滤的用户列
+var scimUserFilterColumns = map[string]bool{
+	"username":     true,
+	"display_name": true,
+	"email":        true,
+}
+
+// ScimUserFilter 是已经映射到用户列的单个 SCIM 过滤条件
+type ScimUserFilter struct {
+	Column   string
+	Operator string
+	Value    string
+}
+
+// escapeScimLikeValue 转义 LIKE 通配符，与其它查询一样使用 ! 作为 ESCAPE 字符
+func escapeScimLikeValue(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+func (filter *ScimUserFilter) apply(query *gorm.DB) (*gorm.DB, error) {
+	if filter == nil {
+		return query, nil
+	}
+	if !scimUserFilterColumns[filter.Column] {
+		return nil, ErrScimFilterUnsupported
+	}
+	column := "LOWER(" + filter.Column + ")"
+	value := strings.ToLower(filter.Value)
+	switch filter.Operator {
+	case "eq":
+		return query.Where(column+" = ?", value), nil
+	case "ne":
+		return query.Where(column+" <> ?", value), nil
+	case "co":
+		return query.Where(column+" LIKE ? ESCAPE '!'", "%"+escapeScimLikeValue(value)+"%"), nil
+	case "sw":
+		return query.Where(column+" LIKE ? ESCAPE '!'", escapeScimLikeValue(value)+"%"), nil
+	case "ew":
+		return query.Where(column+" LIKE ? ESCAPE '!'", "%"+escapeScimLikeValue(value)), nil
+	case "pr":
+		return query.Where(filter.Column + " <> ''"), nil
+	}
+	return nil, ErrScimFilterUnsupported
+}
+
+// FindScimUsers 按 SCIM 过滤条件分页查询用户，offset 从 0 开始
+func FindScimUsers(filter *ScimUserFilter, offset int, limit int) ([]*User, int64, error) {
+	query, err := filter.apply(DB.Model(&User{}))
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := make([]*User, 0)
+	if limit == 0 {
+		return users, total, nil
+	}
+	err = query.Order("id asc").Offset(offset).Limit(limit).Omit("password", "access_token").Find(&users).Error
+	return users, total, err
+}
+
+// GetUsersByGroup 返回分组内的全部用户，只包含 SCIM 成员列表需要的字段
+func GetUsersByGroup(group string) ([]*User, error) {
+	users := make([]*User, 0)
+	err := DB.Select("id", "username", "display_name", "role").Where(&User{Group: group}).Order("id asc").Find(&users).Error
+	return users, err
+}
+
+// GetUserIdByExternalIdentity 返回外部身份对应的用户；不存在时返回 0
+func GetUserIdByExternalIdentity(provider string, subject string) (int, error) {
+	var claim ExternalIdentityClaim
+	err := DB.Where("provider = ? AND subject = ?", provider, subject).Limit(1).Find(&claim).Error
+	return claim.UserId, err
+}
+
+// GetExternalIdentitySubjects 批量读取用户在指定身份源下的外部标识
+func GetExternalIdentitySubjects(provider string, userIds []int) (map[int]string, error) {
+	subjects := make(map[int]string, len(userIds))
+	if len(userIds) == 0 {
+		return subjects, nil
+	}
+	var claims []ExternalIdentityClaim
+	if err := DB.Where("provider = ? AND user_id IN ?", provider, userIds).Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	for _, claim := range claims {
+		subjects[claim.UserId] = claim.Subject
+	}
+	return subjects, nil
+}
+
+func setScimExternalIdWithTx(tx *gorm.DB, userId int, externalId *string) error {
+	if externalId == nil {
+		return nil
+	}
+	subject := strings.TrimSpace(*externalId)
+	if subject == "" {
+		return ReleaseExternalIdentityWithTx(tx, ExternalIdentityProviderSCIM, userId)
+	}
+	if err := tx.Where("provider = ? AND user_id = ? AND subject <> ?", ExternalIdentityProviderSCIM, userId, subject).
+		Delete(&ExternalIdentityClaim{}).Error; err != nil {
+		return err
+	}
+	return ClaimExternalIdentityWithTx(tx, ExternalIdentityProviderSCIM, subject, userId)
+}
+
+// CreateScimUser 创建由 SCIM 下发的用户，并在同一事务中登记 externalId
+func CreateScimUser(user *User, externalId *string) error {
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.InsertWithTx(tx, 0); err != nil {
+			return err
+		}
+		return setScimExternalIdWithTx(tx, user.Id, externalId)
+	}); err != nil {
+		return err
+	}
+	user.FinishInsert(0)
+	return nil
+}
+
+// UpdateScimUser 保存 SCIM 对用户的修改，不修改本地密码。停用或分组变化会推进
+// AuthVersion 并吊销全部会话，使已签发的会话与令牌立即失效。
+// externalId 为 nil 时保持不变，为空字符串时解除绑定。
+func UpdateScimUser(user *User, externalId *string) error {
+	var previousAuthVersion int64
+	if err := DB.Model(&User{}).Where("id = ?", user.Id).Select("auth_version").Find(&previousAuthVersion).Error; err != nil {
+		return err
+	}
+	user.Email = NormalizeEmail(user.Email)
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return withNormalizedEmailLock(tx, user.Email, func(tx *gorm.DB) error {
+			if err := ensureEmailAvailableWithTx(tx, user.Email, user.Id); err != nil {
+				return err
+			}
+			if err := user.UpdateWithTx(tx, false); err != nil {
+				return err
+			}
+			return setScimExternalIdWithTx(tx, user.Id, externalId)
+		})
+	}); err != nil {
+		return err
+	}
+	if err := updateUserCache(*user); err != nil {
+		return err
+	}
+	if user.AuthVersion > previousAuthVersion {
+		_, err := RevokeAllUserSessions(user.Id, "user_security_changed")
+		return err
+	}
+	return nil
+}
+
+// DeleteScimUser 删除用户并释放其 externalId，便于身份源以后重新下发同一账号
+func DeleteScimUser(user *User) error {
+	if err := user.Delete(); err != nil {
+		return err
+	}
+	return ReleaseExternalIdentityWithTx(DB, ExternalIdentityProviderSCIM, user.Id)
+}
+
+// IsUsernameTakenByOthers 检查用户名是否已被其它账号占用，已删除账号同样占用唯一索引
+func IsUsernameTakenByOthers(username string, excludeUserId int) (bool, error) {
+	var count int64
+	err := DB.Unscoped().Model(&User{}).Where("username = ? AND id <> ?", username, excludeUserId).Count(&count).Error
+	return count > 0, err
+}
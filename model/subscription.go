@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
 	"github.com/samber/hot"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
@@ -153,6 +155,11 @@ type SubscriptionPlan struct {
 	PriceAmount float64 `json:"price_amount" gorm:"type:decimal(10,6);not null;default:0"`
 	Currency    string  `json:"currency" gorm:"type:varchar(8);not null;default:'USD'"`
 
+	// Explicit prices in other currencies, keyed by currency code (missing = use PriceAmount).
+	// Only gateways that charge the order amount (epay, Waffo Pancake) use them;
+	// Stripe and Creem charge the fixed price of their configured product.
+	CurrencyPrices CurrencyPrices `json:"currency_prices" gorm:"type:text"`
+
 	DurationUnit  string `json:"duration_unit" gorm:"type:varchar(16);not null;default:'month'"`
 	DurationValue int    `json:"duration_value" gorm:"type:int;not null;default:1"`
 	CustomSeconds int64  `json:"custom_seconds" gorm:"type:bigint;not null;default:0"`
@@ -210,6 +217,44 @@ func (p *SubscriptionPlan) NormalizeDefaults() {
 	}
 }
 
+// CurrencyPrices maps a currency code to the plan price in that currency
+type CurrencyPrices map[string]float64
+
+func (p *CurrencyPrices) Scan(val interface{}) error {
+	var bytesValue []byte
+	switch v := val.(type) {
+	case []byte:
+		bytesValue = v
+	case string:
+		bytesValue = []byte(v)
+	}
+	if len(bytesValue) == 0 {
+		*p = nil
+		return nil
+	}
+	return common.Unmarshal(bytesValue, p)
+}
+
+func (p CurrencyPrices) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "", nil
+	}
+	b, err := common.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// CurrencyPrice returns the explicit plan price configured for a currency.
+func (p *SubscriptionPlan) CurrencyPrice(currency string) (float64, bool) {
+	price, ok := p.CurrencyPrices[strings.ToUpper(strings.TrimSpace(currency))]
+	if !ok || price <= 0 {
+		return 0, false
+	}
+	return price, true
+}
+
 // Subscription order (payment -> webhook -> create UserSubscription)
 type SubscriptionOrder struct {
 	Id     int     `json:"id"`
@@ -225,6 +270,10 @@ type SubscriptionOrder struct {
 	CompleteTime    int64  `json:"complete_time"`
 
 	ProviderPayload string `json:"provider_payload" gorm:"type:text"`
+
+	// Payment currency and exchange rate snapshot at order creation (1 USD = ExchangeRate Currency)
+	Currency     string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:0"`
 }
 
 func (o *SubscriptionOrder) Insert() error {
@@ -1151,7 +1200,13 @@ func ExpireDueSubscriptions(limit int) (int, error) {
 	}
 	for userId := range userIds {
 		cacheGroup := ""
+		var expired []UserSubscription
 		err := DB.Transaction(func(tx *gorm.DB) error {
+			expired = nil
+			if err := tx.Where("user_id = ? AND status = ? AND end_time > 0 AND end_time <= ?", userId, "active", now).
+				Find(&expired).Error; err != nil {
+				return err
+			}
 			res := tx.Model(&UserSubscription{}).
 				Where("user_id = ? AND status = ? AND end_time > 0 AND end_time <= ?", userId, "active", now).
 				Updates(map[string]interface{}{
@@ -1220,6 +1275,13 @@ func ExpireDueSubscriptions(limit int) (int, error) {
 		if cacheGroup != "" {
 			refreshSubscriptionUserGroupCache(userId, "subscription expiration")
 		}
+		for _, sub := range expired {
+			eventbus.Publish(sub.UserId, eventbus.SubscriptionExpiredPayload{
+				SubscriptionId: sub.Id,
+				PlanId:         sub.PlanId,
+				EndTime:        sub.EndTime,
+			})
+		}
 	}
 	return expiredCount, nil
 }
@@ -1437,6 +1499,7 @@ func ResetDueSubscriptions(limit int) (int, error) {
 		if err != nil || plan == nil {
 			continue
 		}
+		var renewed *UserSubscription
 		err = DB.Transaction(func(tx *gorm.DB) error {
 			var locked UserSubscription
 			if err := lockForUpdate(tx).
@@ -1444,15 +1507,28 @@ func ResetDueSubscriptions(limit int) (int, error) {
 				First(&locked).Error; err != nil {
 				return nil
 			}
+			lastResetTime := locked.LastResetTime
 			if err := maybeResetUserSubscriptionWithPlanTx(tx, &locked, plan, now); err != nil {
 				return err
 			}
+			if locked.LastResetTime != lastResetTime {
+				renewed = &locked
+			}
 			resetCount++
 			return nil
 		})
 		if err != nil {
 			return resetCount, err
 		}
+		if renewed != nil {
+			eventbus.Publish(renewed.UserId, eventbus.SubscriptionRenewedPayload{
+				SubscriptionId: renewed.Id,
+				PlanId:         renewed.PlanId,
+				AmountTotal:    renewed.AmountTotal,
+				NextResetTime:  renewed.NextResetTime,
+				EndTime:        renewed.EndTime,
+			})
+		}
 	}
 	return resetCount, nil
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
 
 	"gorm.io/gorm"
 )
@@ -16,11 +17,16 @@ const (
 	SystemTaskStatusSucceeded SystemTaskStatus = "succeeded"
 	SystemTaskStatusFailed    SystemTaskStatus = "failed"
 
-	SystemTaskTypeLogCleanup     = "log_cleanup"
-	SystemTaskTypeChannelTest    = "channel_test"
-	SystemTaskTypeModelUpdate    = "model_update"
-	SystemTaskTypeMidjourneyPoll = "midjourney_poll"
-	SystemTaskTypeAsyncTaskPoll  = "async_task_poll"
+	SystemTaskTypeLogCleanup      = "log_cleanup"
+	SystemTaskTypeChannelTest     = "channel_test"
+	SystemTaskTypeModelUpdate     = "model_update"
+	SystemTaskTypeMidjourneyPoll  = "midjourney_poll"
+	SystemTaskTypeAsyncTaskPoll   = "async_task_poll"
+	SystemTaskTypeChannelSchedule = "channel_schedule"
+	SystemTaskTypeWebhookDelivery = "webhook_delivery"
+	SystemTaskTypeTaskArtifact    = "task_artifact"
+	SystemTaskTypeBillingReprice  = "billing_reprice"
+	SystemTaskTypeCurrencyRate    = "currency_rate_refresh"
 )
 
 var ErrSystemTaskLockLost = errors.New("system task lock lost")
@@ -354,9 +360,26 @@ func MarkSystemTaskLeaseExpired(taskID string) error {
 			"error":      "task lease expired",
 			"updated_at": common.GetTimestamp(),
 		})
+	if result.Error == nil && result.RowsAffected > 0 {
+		publishSystemTaskFailed(taskID, "task lease expired")
+	}
 	return result.Error
 }
 
+// publishSystemTaskFailed emits system_task.failed for a task that just moved
+// to failed. Failures of the webhook delivery task itself are not published so
+// a broken endpoint cannot feed its own retry queue.
+func publishSystemTaskFailed(taskID string, errorMessage string) {
+	var task SystemTask
+	if err := DB.Select("task_id", "type").Where("task_id = ?", taskID).First(&task).Error; err != nil {
+		return
+	}
+	if task.Type == SystemTaskTypeWebhookDelivery {
+		return
+	}
+	eventbus.Publish(0, eventbus.SystemTaskFailedPayload{TaskId: task.TaskID, TaskType: task.Type, Error: errorMessage})
+}
+
 func ExpireStaleSystemTaskLocks(now int64) error {
 	var locks []*SystemTaskLock
 	if err := DB.Where("locked_until < ?", now).Find(&locks).Error; err != nil {
@@ -402,6 +425,9 @@ func FinishSystemTask(taskID string, lockedBy string, status SystemTaskStatus, r
 	if result.RowsAffected == 0 {
 		return ErrSystemTaskLockLost
 	}
+	if status == SystemTaskStatusFailed {
+		publishSystemTaskFailed(taskID, errorMessage)
+	}
 	return ReleaseSystemTaskLock(taskID, lockedBy)
 }
 
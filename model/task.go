@@ -104,6 +104,8 @@ type TaskPrivateData struct {
 	Key            string `json:"key,omitempty"`
 	UpstreamTaskID string `json:"upstream_task_id,omitempty"` // 上游真实 task ID
 	ResultURL      string `json:"result_url,omitempty"`       // 任务成功后的结果 URL（视频地址等）
+	CallbackURL    string `json:"callback_url,omitempty"`     // 任务进入终态后推送结果的客户端回调地址
+	ArtifactId     int    `json:"artifact_id,omitempty"`      // 结果已转存到本站存储时的 TaskArtifact ID
 	// 计费上下文：用于异步退款/差额结算（轮询阶段读取）
 	BillingSource  string              `json:"billing_source,omitempty"`  // "wallet" 或 "subscription"
 	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
@@ -131,9 +133,18 @@ func (t *Task) GetUpstreamTaskID() string {
 	return t.TaskID
 }
 
-// GetResultURL 获取任务结果 URL（视频地址等）
-// 新数据存在 PrivateData.ResultURL 中；旧数据回退到 FailReason（历史兼容）
+// GetResultURL 获取返回给客户端的任务结果 URL（视频地址等）
+// 结果已转存时返回带签名的本站地址，否则返回上游结果地址
 func (t *Task) GetResultURL() string {
+	if t.PrivateData.ArtifactId > 0 {
+		return TaskArtifactURL(t.TaskID)
+	}
+	return t.GetUpstreamResultURL()
+}
+
+// GetUpstreamResultURL 获取上游返回的任务结果 URL
+// 新数据存在 PrivateData.ResultURL 中；旧数据回退到 FailReason（历史兼容）
+func (t *Task) GetUpstreamResultURL() string {
 	if t.PrivateData.ResultURL != "" {
 		return t.PrivateData.ResultURL
 	}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+const (
+	TaskArtifactStatusPending = "pending"
+	TaskArtifactStatusStored  = "stored"
+	TaskArtifactStatusFailed  = "failed"
+)
+
+// TaskArtifact 异步任务结果在本站存储中的副本。任务成功后创建为 pending，
+// 系统任务下载上游结果后转为 stored，到期后删除存储对象及记录。
+type TaskArtifact struct {
+	Id          int    `json:"id"`
+	TaskId      string `json:"task_id" gorm:"type:varchar(191);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	SourceURL   string `json:"-" gorm:"column:source_url;type:text"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string `json:"-" gorm:"type:varchar(512)"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size" gorm:"bigint;default:0"`
+	Attempts    int    `json:"attempts" gorm:"default:0"`
+	LastError   string `json:"last_error" gorm:"type:text"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+	ExpiresTime int64  `json:"expires_time" gorm:"bigint;index"` // 转存结束（成功或失败）后设置，到期后清理
+}
+
+// CreateTaskArtifact 为任务创建待转存记录，同一任务只会创建一次
+func CreateTaskArtifact(artifact *TaskArtifact) (bool, error) {
+	now := common.GetTimestamp()
+	artifact.Status = TaskArtifactStatusPending
+	artifact.CreatedTime = now
+	artifact.UpdatedTime = now
+	result := DB.Where(TaskArtifact{TaskId: artifact.TaskId}).FirstOrCreate(artifact)
+	return result.RowsAffected > 0, result.Error
+}
+
+func FindPendingTaskArtifacts(limit int) ([]*TaskArtifact, error) {
+	var artifacts []*TaskArtifact
+	err := DB.Where("status = ?", TaskArtifactStatusPending).Order("id asc").Limit(limit).Find(&artifacts).Error
+	return artifacts, err
+}
+
+// FindExpiredTaskArtifacts 返回保留期已过的记录，包括转存失败的记录
+func FindExpiredTaskArtifacts(now int64, limit int) ([]*TaskArtifact, error) {
+	var artifacts []*TaskArtifact
+	err := DB.Where("status <> ? AND expires_time > 0 AND expires_time <= ?", TaskArtifactStatusPending, now).
+		Order("expires_time asc").Limit(limit).Find(&artifacts).Error
+	return artifacts, err
+}
+
+// HasDueTaskArtifacts 是否有待转存或已到期的记录
+func HasDueTaskArtifacts(now int64) bool {
+	var artifact TaskArtifact
+	result := DB.Select("id").
+		Where("status = ? OR (expires_time > 0 AND expires_time <= ?)", TaskArtifactStatusPending, now).
+		Limit(1).Find(&artifact)
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// SumUserTaskArtifactSize 统计用户已占用的存储空间
+func SumUserTaskArtifactSize(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&TaskArtifact{}).Where("user_id = ? AND status = ?", userId, TaskArtifactStatusStored).
+		Select("COALESCE(SUM(size), 0)").Scan(&total).Error
+	return total, err
+}
+
+func SaveTaskArtifact(artifact *TaskArtifact) error {
+	artifact.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&TaskArtifact{}).Where("id = ?", artifact.Id).
+		Select("status", "backend", "storage_key", "content_type", "size", "attempts", "last_error", "expires_time", "updated_time").
+		Updates(artifact).Error
+}
+
+// GetStoredTaskArtifact 获取任务仍在保留期内的转存结果
+func GetStoredTaskArtifact(taskId string) (*TaskArtifact, bool, error) {
+	var artifact TaskArtifact
+	err := DB.Where("task_id = ? AND status = ? AND expires_time > ?", taskId, TaskArtifactStatusStored, common.GetTimestamp()).
+		First(&artifact).Error
+	exist, err := RecordExist(err)
+	if err != nil || !exist {
+		return nil, exist, err
+	}
+	return &artifact, true, nil
+}
+
+func DeleteTaskArtifact(id int) error {
+	return DB.Where("id = ?", id).Delete(&TaskArtifact{}).Error
+}
+
+// SetTaskArtifactId 记录或清除（artifactId 为 0）任务的转存结果。任务已处于终态，不会与轮询并发更新
+func SetTaskArtifactId(task *Task, artifactId int) error {
+	task.PrivateData.ArtifactId = artifactId
+	return DB.Model(&Task{}).Where("id = ?", task.ID).Update("private_data", task.PrivateData).Error
+}
+
+func taskArtifactSignature(taskId string, expires int64) string {
+	return common.GenerateHMAC(fmt.Sprintf("task_artifact:%s:%d", taskId, expires))
+}
+
+// TaskArtifactURL 返回转存结果的签名地址，无需令牌即可在有效期内访问
+func TaskArtifactURL(taskId string) string {
+	expires := time.Now().Add(system_setting.GetTaskArtifactSettings().URLExpire()).Unix()
+	return fmt.Sprintf("%s/v1/videos/%s/artifact?expires=%d&signature=%s",
+		system_setting.ServerAddress, url.PathEscape(taskId), expires, taskArtifactSignature(taskId, expires))
+}
+
+// VerifyTaskArtifactSignature 校验签名地址的参数
+func VerifyTaskArtifactSignature(taskId string, expires string, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid expires")
+	}
+	if expiresAt < time.Now().Unix() {
+		return errors.New("url has expired")
+	}
+	expected := taskArtifactSignature(taskId, expiresAt)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -53,10 +53,22 @@ func TestMain(m *testing.M) {
 		&SubscriptionOrder{},
 		&UserSubscription{},
 		&UserOAuthBinding{},
+		&OAuthClaimGrant{},
 		&PerfMetric{},
 		&SystemInstance{},
 		&SystemTask{},
 		&SystemTaskLock{},
+		&ChannelSchedule{},
+		&WebhookSubscription{},
+		&WebhookDelivery{},
+		&TaskArtifact{},
+		&WasmPlugin{},
+		&WasmPluginVersion{},
+		&Option{},
+		&OptionRevision{},
+		&OptionChangeSet{},
+		&PersonalAccessToken{},
+		&QuotaAdjustUsage{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -76,6 +88,7 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM two_fas")
 		DB.Exec("DELETE FROM tokens")
 		DB.Exec("DELETE FROM user_oauth_bindings")
+		DB.Exec("DELETE FROM oauth_claim_grants")
 		DB.Exec("DELETE FROM users")
 		DB.Exec("DELETE FROM logs")
 		DB.Exec("DELETE FROM channels")
@@ -89,6 +102,17 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM system_instances")
 		DB.Exec("DELETE FROM system_task_locks")
 		DB.Exec("DELETE FROM system_tasks")
+		DB.Exec("DELETE FROM channel_schedules")
+		DB.Exec("DELETE FROM webhook_subscriptions")
+		DB.Exec("DELETE FROM webhook_deliveries")
+		DB.Exec("DELETE FROM task_artifacts")
+		DB.Exec("DELETE FROM wasm_plugins")
+		DB.Exec("DELETE FROM wasm_plugin_versions")
+		DB.Exec("DELETE FROM options")
+		DB.Exec("DELETE FROM option_revisions")
+		DB.Exec("DELETE FROM option_change_sets")
+		DB.Exec("DELETE FROM personal_access_tokens")
+		DB.Exec("DELETE FROM quota_adjust_usages")
 	})
 }
 
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/bytedance/gopkg/util/gopool"
 	"gorm.io/gorm"
@@ -29,6 +30,8 @@ type Token struct {
 	Group              string         `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
 	AutoGroups         string         `json:"-" gorm:"type:text"`
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(1024);default:''"` // 异步任务默认回调地址，请求未指定 callback_url 时使用
+	CallbackSecret     string         `json:"-" gorm:"type:varchar(128);default:''"`             // 任务回调签名密钥，与令牌密钥分开，避免回调接收方拿到可调用接口的凭据
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -58,6 +61,7 @@ func (token *Token) SetAutoGroups(groups []string) error {
 
 func (token *Token) Clean() {
 	token.Key = ""
+	token.CallbackSecret = ""
 }
 
 func MaskTokenKey(key string) string {
@@ -317,7 +321,7 @@ func (token *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() (err error) {
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "auto_groups").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "auto_groups", "callback_url").Updates(token).Error
 	if shouldUpdateRedis(true, err) {
 		if cacheErr := cacheSetToken(*token); cacheErr != nil {
 			common.SysLog("failed to update token cache: " + cacheErr.Error())
@@ -329,6 +333,29 @@ func (token *Token) Update() (err error) {
 	return err
 }
 
+// RotateTokenKey 为令牌生成新的密钥，旧密钥立即失效
+func RotateTokenKey(id int) (*Token, error) {
+	token, err := GetTokenById(id)
+	if err != nil {
+		return nil, err
+	}
+	oldKey := token.Key
+	key, err := common.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Token{}).Where("id = ?", id).Update("key", key).Error; err != nil {
+		return nil, err
+	}
+	if common.RedisEnabled {
+		if err := cacheDeleteToken(oldKey); err != nil {
+			common.SysLog("failed to invalidate token cache after rotation: " + err.Error())
+		}
+	}
+	token.Key = key
+	return token, nil
+}
+
 func (token *Token) SelectUpdate() (err error) {
 	defer func() {
 		if shouldUpdateRedis(true, err) {
@@ -452,14 +479,89 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 }
 
 func decreaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
+	updates := func() map[string]interface{} {
+		return map[string]interface{}{
 			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
 			"used_quota":    gorm.Expr("used_quota + ?", quota),
 			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+		}
+	}
+	if quota <= 0 {
+		return DB.Model(&Token{}).Where("id = ?", id).Updates(updates()).Error
+	}
+	// 绝大多数扣减不会使余额由正变为非正，一条条件 UPDATE 即可完成；
+	// 无限额度令牌同样走这一条，不做耗尽判断
+	result := DB.Model(&Token{}).
+		Where("id = ? AND (unlimited_quota = ? OR remain_quota > ? OR remain_quota <= 0)", id, true, quota).
+		Updates(updates())
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	// 本次扣减会耗尽余额：只有条件命中的那一次扣减发出令牌耗尽事件
+	result = DB.Model(&Token{}).
+		Where("id = ? AND unlimited_quota = ? AND remain_quota > 0 AND remain_quota <= ?", id, false, quota).
+		Updates(updates())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		publishTokenExhausted(id)
+		return nil
+	}
+	// 两次条件之间余额被并发修改（已被其他请求耗尽或被充值），直接扣减
+	return DB.Model(&Token{}).Where("id = ?", id).Updates(updates()).Error
+}
+
+func publishTokenExhausted(id int) {
+	var token Token
+	if err := DB.Select("id", "user_id", "name").Where("id = ?", id).First(&token).Error; err != nil {
+		common.SysLog(fmt.Sprintf("failed to load exhausted token %d: %s", id, err.Error()))
+		return
+	}
+	eventbus.Publish(token.UserId, eventbus.TokenExhaustedPayload{TokenId: token.Id, Name: token.Name})
+}
+
+// GenerateTokenCallbackSecret 生成任务回调签名密钥
+func GenerateTokenCallbackSecret() (string, error) {
+	key, err := common.GenerateRandomCharsKey(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + key, nil
+}
+
+// EnsureTokenCallbackSecret 返回令牌的回调签名密钥，尚未生成时生成一个；
+// 只在密钥为空时写入，并发生成时以先写入的为准
+func EnsureTokenCallbackSecret(token *Token) (string, error) {
+	if token.CallbackSecret != "" {
+		return token.CallbackSecret, nil
+	}
+	secret, err := GenerateTokenCallbackSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := DB.Model(&Token{}).Where("id = ? AND (callback_secret = '' OR callback_secret IS NULL)", token.Id).
+		Update("callback_secret", secret).Error; err != nil {
+		return "", err
+	}
+	if err := DB.Model(&Token{}).Select("callback_secret").Where("id = ?", token.Id).
+		Scan(&token.CallbackSecret).Error; err != nil {
+		return "", err
+	}
+	return token.CallbackSecret, nil
+}
+
+// RotateTokenCallbackSecret 轮换回调签名密钥，尚未发出的回调使用新密钥签名
+func RotateTokenCallbackSecret(token *Token) (string, error) {
+	secret, err := GenerateTokenCallbackSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := DB.Model(&Token{}).Where("id = ?", token.Id).Update("callback_secret", secret).Error; err != nil {
+		return "", err
+	}
+	token.CallbackSecret = secret
+	return secret, nil
 }
 
 // CountUserTokens returns total number of tokens for the given user, used for pagination
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecreaseTokenQuotaPublishesExhaustedOnce(t *testing.T) {
+	truncateTables(t)
+	token := &Token{UserId: 5, Name: "ci", Key: "exhaust-key", Status: common.TokenStatusEnabled, RemainQuota: 100}
+	require.NoError(t, DB.Create(token).Error)
+
+	exhausted := make(chan *eventbus.Event, 4)
+	unsubscribe := eventbus.Subscribe(func(event *eventbus.Event) {
+		if event.Type == eventbus.TokenExhausted {
+			exhausted <- event
+		}
+	})
+	defer unsubscribe()
+
+	require.NoError(t, decreaseTokenQuota(token.Id, 60))
+	require.NoError(t, decreaseTokenQuota(token.Id, 60))
+	require.NoError(t, decreaseTokenQuota(token.Id, 60))
+
+	select {
+	case event := <-exhausted:
+		assert.Equal(t, 5, event.UserId)
+		assert.Equal(t, eventbus.TokenExhaustedPayload{TokenId: token.Id, Name: "ci"}, event.Data)
+	case <-time.After(time.Second):
+		t.Fatal("token.exhausted was not published")
+	}
+	select {
+	case <-exhausted:
+		t.Fatal("token.exhausted was published more than once")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var stored Token
+	require.NoError(t, DB.First(&stored, token.Id).Error)
+	assert.Equal(t, -80, stored.RemainQuota)
+	assert.Equal(t, 180, stored.UsedQuota)
+}
+
+func TestDecreaseTokenQuotaSkipsUnlimitedTokens(t *testing.T) {
+	truncateTables(t)
+	token := &Token{UserId: 6, Name: "unlimited", Key: "unlimited-key", Status: common.TokenStatusEnabled, RemainQuota: 100, UnlimitedQuota: true}
+	require.NoError(t, DB.Create(token).Error)
+
+	exhausted := make(chan *eventbus.Event, 1)
+	unsubscribe := eventbus.Subscribe(func(event *eventbus.Event) {
+		if event.Type == eventbus.TokenExhausted {
+			exhausted <- event
+		}
+	})
+	defer unsubscribe()
+
+	require.NoError(t, decreaseTokenQuota(token.Id, 120))
+
+	select {
+	case <-exhausted:
+		t.Fatal("token.exhausted was published for an unlimited token")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var stored Token
+	require.NoError(t, DB.First(&stored, token.Id).Error)
+	assert.Equal(t, -20, stored.RemainQuota)
+	assert.Equal(t, 120, stored.UsedQuota)
+}
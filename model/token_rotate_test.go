@@ -0,0 +1,68 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateTokenKeyReplacesKey(t *testing.T) {
+	truncateTables(t)
+	token := &Token{UserId: 1, Name: "ops", Key: "old-key", Status: common.TokenStatusEnabled}
+	require.NoError(t, DB.Create(token).Error)
+
+	rotated, err := RotateTokenKey(token.Id)
+	require.NoError(t, err)
+	assert.NotEqual(t, "old-key", rotated.Key)
+
+	_, err = GetTokenByKey("old-key", true)
+	assert.Error(t, err)
+	stored, err := GetTokenByKey(rotated.Key, true)
+	require.NoError(t, err)
+	assert.Equal(t, token.Id, stored.Id)
+}
+
+func TestRecomputeUserUsedQuotaFromConsumeLogs(t *testing.T) {
+	truncateTables(t)
+	user := &User{Username: "recompute", UsedQuota: 999, RequestCount: 99}
+	require.NoError(t, DB.Create(user).Error)
+	require.NoError(t, DB.Create([]*Log{
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 100},
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 50},
+		{UserId: user.Id, Type: LogTypeTopup, Quota: 1000},
+	}).Error)
+
+	count, err := RecomputeUserUsedQuota(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var stored User
+	require.NoError(t, DB.First(&stored, user.Id).Error)
+	assert.Equal(t, 150, stored.UsedQuota)
+	assert.Equal(t, 2, stored.RequestCount)
+}
+
+func TestRecomputeUserUsedQuotaKeepsUsageAfterLogCleanup(t *testing.T) {
+	truncateTables(t)
+	user := &User{Username: "recompute-cleaned", UsedQuota: 999, RequestCount: 99}
+	require.NoError(t, DB.Create(user).Error)
+	require.NoError(t, DB.Create([]*Log{
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 100, CreatedAt: 1},
+		{UserId: user.Id, Type: LogTypeConsume, Quota: 50, CreatedAt: user.CreatedAt + 10},
+	}).Error)
+
+	deleted, err := DeleteOldLogBatch(context.Background(), user.CreatedAt+5, 100)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+
+	_, err = RecomputeUserUsedQuota(user.Id)
+	require.NoError(t, err)
+
+	var stored User
+	require.NoError(t, DB.First(&stored, user.Id).Error)
+	assert.Equal(t, 999, stored.UsedQuota, "cleaned logs must not lower the stored usage")
+	assert.Equal(t, 99, stored.RequestCount)
+}
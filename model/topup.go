@@ -6,6 +6,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
 
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
@@ -22,6 +23,11 @@ type TopUp struct {
 	CreateTime      int64   `json:"create_time"`
 	CompleteTime    int64   `json:"complete_time"`
 	Status          string  `json:"status"`
+	// 下单时的支付币种与汇率快照（1 USD = ExchangeRate Currency），用于对账审计
+	Currency     string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:0"`
+	// 按 Currency 计价的实际扣款金额；为 0 表示下单时不知道该金额（如按 Stripe Price ID 扣款）
+	CurrencyMoney float64 `json:"currency_money" gorm:"default:0"`
 }
 
 const (
@@ -106,6 +112,17 @@ func UpdatePendingTopUpStatus(tradeNo string, expectedPaymentProvider string, ta
 	})
 }
 
+// PublishTopUpCompleted 在充值订单入账后发出充值完成事件
+func PublishTopUpCompleted(topUp *TopUp, quota int) {
+	eventbus.Publish(topUp.UserId, eventbus.TopUpCompletedPayload{
+		TradeNo:       topUp.TradeNo,
+		PaymentMethod: topUp.PaymentMethod,
+		Amount:        topUp.Amount,
+		Money:         topUp.Money,
+		Quota:         quota,
+	})
+}
+
 func Recharge(referenceId string, customerId string, callerIp string) (err error) {
 	if referenceId == "" {
 		return errors.New("未提供支付单号")
@@ -155,6 +172,7 @@ func Recharge(referenceId string, customerId string, callerIp string) (err error
 	}
 
 	RecordTopupLog(topUp.UserId, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%d", logger.FormatQuota(int(quota)), topUp.Amount), callerIp, topUp.PaymentMethod, PaymentMethodStripe)
+	PublishTopUpCompleted(topUp, int(quota))
 
 	return nil
 }
@@ -331,6 +349,7 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 	var quotaToAdd int
 	var payMoney float64
 	var paymentMethod string
+	var completed *TopUp
 
 	err := DB.Transaction(func(tx *gorm.DB) error {
 		topUp := &TopUp{}
@@ -378,6 +397,7 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 		userId = topUp.UserId
 		payMoney = topUp.Money
 		paymentMethod = topUp.PaymentMethod
+		completed = topUp
 		return nil
 	})
 
@@ -387,6 +407,9 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 
 	// 事务外记录日志，避免阻塞
 	RecordTopupLog(userId, fmt.Sprintf("管理员补单成功，充值金额: %v，支付金额：%f", logger.FormatQuota(quotaToAdd), payMoney), callerIp, paymentMethod, "admin")
+	if completed != nil {
+		PublishTopUpCompleted(completed, quotaToAdd)
+	}
 	return nil
 }
 func RechargeCreem(referenceId string, customerEmail string, customerName string, callerIp string) (err error) {
@@ -460,6 +483,7 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 	}
 
 	RecordTopupLog(topUp.UserId, fmt.Sprintf("使用Creem充值成功，充值额度: %v，支付金额：%.2f", quota, topUp.Money), callerIp, topUp.PaymentMethod, PaymentMethodCreem)
+	PublishTopUpCompleted(topUp, int(quota))
 
 	return nil
 }
@@ -522,6 +546,7 @@ func RechargeWaffo(tradeNo string, callerIp string) (err error) {
 
 	if quotaToAdd > 0 {
 		RecordTopupLog(topUp.UserId, fmt.Sprintf("Waffo充值成功，充值额度: %v，支付金额: %.2f", logger.FormatQuota(quotaToAdd), topUp.Money), callerIp, topUp.PaymentMethod, PaymentMethodWaffo)
+		PublishTopUpCompleted(topUp, quotaToAdd)
 	}
 
 	return nil
@@ -583,6 +608,7 @@ func RechargeWaffoPancake(tradeNo string) (err error) {
 
 	if quotaToAdd > 0 {
 		RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("Waffo Pancake充值成功，充值额度: %v，支付金额: %.2f", logger.FormatQuota(quotaToAdd), topUp.Money))
+		PublishTopUpCompleted(topUp, quotaToAdd)
 	}
 
 	return nil
@@ -1125,6 +1125,107 @@ func ResetUserPasswordByEmail(email string, password string) error {
 	return err
 }
 
+func GetUserByUsername(username string) (*User, error) {
+	if username == "" {
+		return nil, errors.New("用户名为空！")
+	}
+	var user User
+	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResetUserPassword 重置密码并重新启用账户，同时使该用户已有的会话全部失效。
+// 供命令行恢复被锁定的管理员账户使用。
+func ResetUserPassword(userId int, password string) error {
+	if password == "" {
+		return errors.New("密码为空！")
+	}
+	hashedPassword, err := common.Password2Hash(password)
+	if err != nil {
+		return err
+	}
+	if err = DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := IncrementUserAuthVersionWithTx(tx, userId); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"password": hashedPassword,
+			"status":   common.UserStatusEnabled,
+		}).Error
+	}); err != nil {
+		return err
+	}
+	if err := PublishUserAuthCache(userId); err != nil {
+		return err
+	}
+	_, err = RevokeAllUserSessions(userId, "password_reset")
+	return err
+}
+
+// RecomputeUserUsedQuota 根据消费日志重新统计用户的已用额度与请求次数；userId 为 0 时处理全部用户。
+// 仅在开启消费日志记录时结果才准确。日志清理过后，清理时间点之前注册的用户的日志已不完整，
+// 这些用户的统计值只会调高不会调低，避免把被清理的消费从已用额度中抹掉。
+func RecomputeUserUsedQuota(userId int) (int, error) {
+	type usage struct {
+		UserId       int
+		UsedQuota    int
+		RequestCount int
+	}
+	var usages []usage
+	query := LOG_DB.Model(&Log{}).
+		Select("user_id, COALESCE(SUM(quota), 0) AS used_quota, COUNT(*) AS request_count").
+		Where("type = ?", LogTypeConsume)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Group("user_id").Scan(&usages).Error; err != nil {
+		return 0, err
+	}
+	usageByUser := make(map[int]usage, len(usages))
+	for _, item := range usages {
+		usageByUser[item.UserId] = item
+	}
+	cutoff, err := GetLogCleanupCutoff()
+	if err != nil {
+		return 0, err
+	}
+	var users []User
+	userQuery := DB.Model(&User{}).Select("id", "used_quota", "request_count", "created_at")
+	if userId != 0 {
+		userQuery = userQuery.Where("id = ?", userId)
+	}
+	if err := userQuery.Find(&users).Error; err != nil {
+		return 0, err
+	}
+	clamped := 0
+	for _, user := range users {
+		item := usageByUser[user.Id]
+		if cutoff > 0 && user.CreatedAt < cutoff {
+			if item.UsedQuota < user.UsedQuota || item.RequestCount < user.RequestCount {
+				clamped++
+			}
+			item.UsedQuota = max(item.UsedQuota, user.UsedQuota)
+			item.RequestCount = max(item.RequestCount, user.RequestCount)
+		}
+		err := DB.Model(&User{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
+			"used_quota":    item.UsedQuota,
+			"request_count": item.RequestCount,
+		}).Error
+		if err != nil {
+			return 0, err
+		}
+		if err := invalidateUserCache(user.Id); err != nil {
+			common.SysError("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	if clamped > 0 {
+		common.SysLog(fmt.Sprintf("logs were cleaned up before %d, kept the stored usage of %d users instead of lowering it", cutoff, clamped))
+	}
+	return len(users), nil
+}
+
 func IsAdmin(userId int) bool {
 	if userId == 0 {
 		return false
@@ -1192,6 +1293,11 @@ func GetUserEmail(id int) (email string, err error) {
 	return email, err
 }
 
+func GetUserTelegramId(id int) (telegramId string, err error) {
+	err = DB.Model(&User{}).Where("id = ?", id).Select("telegram_id").Find(&telegramId).Error
+	return telegramId, err
+}
+
 // GetUserGroup gets group from Redis first, falls back to DB if needed
 func GetUserGroup(id int, fromDB bool) (group string, err error) {
 	defer func() {
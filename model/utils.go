@@ -82,7 +82,12 @@ func batchUpdate() {
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeTokenQuota:
-				err := increaseTokenQuota(key, value)
+				var err error
+				if value < 0 {
+					err = decreaseTokenQuota(key, -value)
+				} else {
+					err = increaseTokenQuota(key, value)
+				}
 				if err != nil {
 					common.SysLog("failed to batch update token quota: " + err.Error())
 				}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// WasmPlugin WASM 插件。每次上传生成一个新版本，ActiveVersion 为当前生效的版本号，0 表示尚无可用版本。
+// 渠道设置和分组配置通过 Name 引用插件
+type WasmPlugin struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description   string `json:"description" gorm:"type:varchar(255)"`
+	Enabled       bool   `json:"enabled" gorm:"index"`
+	FailOpen      bool   `json:"fail_open"` // 插件执行出错时放行原始数据，默认拒绝请求
+	ActiveVersion int    `json:"active_version" gorm:"default:0"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// WasmPluginVersion 插件的一个上传版本，版本号在插件内从 1 递增
+type WasmPluginVersion struct {
+	Id          int    `json:"id"`
+	PluginId    int    `json:"plugin_id" gorm:"uniqueIndex:idx_wasm_plugin_version,priority:1"`
+	Version     int    `json:"version" gorm:"uniqueIndex:idx_wasm_plugin_version,priority:2"`
+	Hooks       string `json:"hooks" gorm:"type:varchar(255)"` // 模块实现的钩子，逗号分隔
+	Size        int64  `json:"size" gorm:"bigint"`
+	Sha256      string `json:"sha256" gorm:"type:varchar(64)"`
+	Note        string `json:"note" gorm:"type:varchar(255)"`
+	Wasm        []byte `json:"-"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (p *WasmPlugin) Insert() error {
+	now := common.GetTimestamp()
+	p.CreatedTime = now
+	p.UpdatedTime = now
+	return DB.Create(p).Error
+}
+
+// Update 更新插件定义，生效版本只通过 ActivateWasmPluginVersion 切换
+func (p *WasmPlugin) Update() error {
+	p.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&WasmPlugin{}).Where("id = ?", p.Id).
+		Select("name", "description", "enabled", "fail_open", "updated_time").
+		Updates(p).Error
+}
+
+func (v *WasmPluginVersion) GetHookList() []string {
+	if v.Hooks == "" {
+		return nil
+	}
+	return strings.Split(v.Hooks, ",")
+}
+
+func GetWasmPlugins() ([]*WasmPlugin, error) {
+	var plugins []*WasmPlugin
+	err := DB.Order("id asc").Find(&plugins).Error
+	return plugins, err
+}
+
+// GetActiveWasmPlugins 返回已启用且有生效版本的插件
+func GetActiveWasmPlugins() ([]*WasmPlugin, error) {
+	var plugins []*WasmPlugin
+	err := DB.Where("enabled = ? AND active_version > ?", true, 0).Order("id asc").Find(&plugins).Error
+	return plugins, err
+}
+
+func GetWasmPluginById(id int) (*WasmPlugin, error) {
+	var plugin WasmPlugin
+	if err := DB.First(&plugin, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &plugin, nil
+}
+
+// WasmPluginNameExists 检查名称是否已被其他插件使用
+func WasmPluginNameExists(name string, excludeId int) (bool, error) {
+	var count int64
+	err := DB.Model(&WasmPlugin{}).Where("name = ? AND id <> ?", name, excludeId).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteWasmPlugin 删除插件及其全部版本
+func DeleteWasmPlugin(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&WasmPlugin{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("plugin_id = ?", id).Delete(&WasmPluginVersion{}).Error
+	})
+}
+
+// CreateWasmPluginVersion 为插件分配下一个版本号并保存，activate 为 true 时同时设为生效版本
+func CreateWasmPluginVersion(version *WasmPluginVersion, activate bool) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var plugin WasmPlugin
+		if err := tx.First(&plugin, "id = ?", version.PluginId).Error; err != nil {
+			return err
+		}
+		var latest int
+		if err := tx.Model(&WasmPluginVersion{}).Where("plugin_id = ?", version.PluginId).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		version.Version = latest + 1
+		version.CreatedTime = common.GetTimestamp()
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+		if !activate {
+			return nil
+		}
+		return tx.Model(&WasmPlugin{}).Where("id = ?", version.PluginId).
+			Updates(map[string]any{"active_version": version.Version, "updated_time": version.CreatedTime}).Error
+	})
+}
+
+// GetWasmPluginVersions 返回插件的全部版本，不含模块内容
+func GetWasmPluginVersions(pluginId int) ([]*WasmPluginVersion, error) {
+	var versions []*WasmPluginVersion
+	err := DB.Omit("wasm").Where("plugin_id = ?", pluginId).Order("version desc").Find(&versions).Error
+	return versions, err
+}
+
+func GetWasmPluginVersion(pluginId int, version int) (*WasmPluginVersion, error) {
+	var pluginVersion WasmPluginVersion
+	if err := DB.Where("plugin_id = ? AND version = ?", pluginId, version).First(&pluginVersion).Error; err != nil {
+		return nil, err
+	}
+	return &pluginVersion, nil
+}
+
+// ActivateWasmPluginVersion 切换插件的生效版本，用于发布与回滚
+func ActivateWasmPluginVersion(pluginId int, version int) error {
+	if _, err := GetWasmPluginVersion(pluginId, version); err != nil {
+		return err
+	}
+	return DB.Model(&WasmPlugin{}).Where("id = ?", pluginId).
+		Updates(map[string]any{"active_version": version, "updated_time": common.GetTimestamp()}).Error
+}
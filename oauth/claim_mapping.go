@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// ClaimMappingRule grants entitlements to users whose user info document
+// matches When. A rule without When applies to every user of the provider.
+type ClaimMappingRule struct {
+	Name       string        `json:"name"`
+	When       *accessPolicy `json:"when,omitempty"`
+	Group      string        `json:"group,omitempty"`
+	Role       string        `json:"role,omitempty"` // "user" or "admin"
+	AuthzRoles []string      `json:"authz_roles,omitempty"`
+	Quota      int           `json:"quota,omitempty"` // Granted once per rule name and user
+}
+
+// ClaimEntitlements is the outcome of evaluating the claim mappings for one
+// login. Group and Role come from the first matching rule that sets them,
+// authz roles are the union of all matching rules.
+type ClaimEntitlements struct {
+	Group        string
+	Role         int
+	AuthzRoles   []string
+	QuotaGrants  map[string]int
+	MatchedRules []string
+}
+
+// IsEmpty reports whether no rule granted anything
+func (e *ClaimEntitlements) IsEmpty() bool {
+	return e.Group == "" && e.Role == 0 && len(e.AuthzRoles) == 0 && len(e.QuotaGrants) == 0
+}
+
+// QuotaRuleNames returns the names of the rules granting quota in a stable order
+func (e *ClaimEntitlements) QuotaRuleNames() []string {
+	names := make([]string, 0, len(e.QuotaGrants))
+	for name := range e.QuotaGrants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseClaimMappings decodes the claim mapping rules of a provider. The rules
+// are validated by model.ValidateClaimMappings, the same check applied when
+// the provider is saved.
+func ParseClaimMappings(raw string) ([]ClaimMappingRule, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if err := model.ValidateClaimMappings(raw); err != nil {
+		return nil, err
+	}
+	var rules []ClaimMappingRule
+	if err := common.UnmarshalJsonStr(raw, &rules); err != nil {
+		return nil, err
+	}
+	for index := range rules {
+		rules[index].Name = strings.TrimSpace(rules[index].Name)
+		rules[index].Group = strings.TrimSpace(rules[index].Group)
+	}
+	return rules, nil
+}
+
+// EvaluateClaimMappings evaluates the rules against the user info document
+// (JSON) returned by the provider.
+func EvaluateClaimMappings(raw string, claims string) (*ClaimEntitlements, error) {
+	rules, err := ParseClaimMappings(raw)
+	if err != nil {
+		return nil, err
+	}
+	entitlements := &ClaimEntitlements{QuotaGrants: map[string]int{}}
+	seenRoles := map[string]bool{}
+	for _, rule := range rules {
+		if matched, _ := evaluateAccessPolicy(claims, rule.When); !matched {
+			continue
+		}
+		entitlements.MatchedRules = append(entitlements.MatchedRules, rule.Name)
+		if entitlements.Group == "" {
+			entitlements.Group = rule.Group
+		}
+		if entitlements.Role == 0 {
+			entitlements.Role = model.ClaimMappingRoleValue(rule.Role)
+		}
+		for _, role := range rule.AuthzRoles {
+			role = strings.TrimSpace(role)
+			if role == "" || seenRoles[role] {
+				continue
+			}
+			seenRoles[role] = true
+			entitlements.AuthzRoles = append(entitlements.AuthzRoles, role)
+		}
+		if rule.Quota > 0 {
+			entitlements.QuotaGrants[rule.Name] = rule.Quota
+		}
+	}
+	return entitlements, nil
+}
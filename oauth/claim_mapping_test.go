@@ -0,0 +1,56 @@
+package oauth
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClaimMappings = `[
+	{"name": "ml", "when": {"conditions": [{"field": "groups", "op": "contains", "value": "ml-team"}]},
+	 "group": "vip", "authz_roles": ["channel-operator"], "quota": 500},
+	{"name": "admins", "when": {"conditions": [{"field": "groups", "op": "contains", "value": "platform"}]},
+	 "group": "svip", "role": "admin", "authz_roles": ["channel-operator", "auditor"]},
+	{"name": "everyone", "authz_roles": ["reader"]}
+]`
+
+func TestEvaluateClaimMappingsMergesMatchingRules(t *testing.T) {
+	entitlements, err := EvaluateClaimMappings(testClaimMappings, `{"groups": ["ml-team", "platform"]}`)
+	require.NoError(t, err)
+
+	// Group and role come from the first matching rule that sets them
+	assert.Equal(t, "vip", entitlements.Group)
+	assert.Equal(t, common.RoleAdminUser, entitlements.Role)
+	assert.Equal(t, []string{"channel-operator", "auditor", "reader"}, entitlements.AuthzRoles)
+	assert.Equal(t, map[string]int{"ml": 500}, entitlements.QuotaGrants)
+	assert.Equal(t, []string{"ml", "admins", "everyone"}, entitlements.MatchedRules)
+}
+
+func TestEvaluateClaimMappingsWithoutMatch(t *testing.T) {
+	entitlements, err := EvaluateClaimMappings(testClaimMappings, `{"groups": ["sales"]}`)
+	require.NoError(t, err)
+	assert.Empty(t, entitlements.Group)
+	assert.Zero(t, entitlements.Role)
+	assert.Equal(t, []string{"reader"}, entitlements.AuthzRoles)
+	assert.False(t, entitlements.IsEmpty())
+
+	entitlements, err = EvaluateClaimMappings("", `{"groups": ["ml-team"]}`)
+	require.NoError(t, err)
+	assert.True(t, entitlements.IsEmpty())
+}
+
+func TestParseClaimMappingsRejectsInvalidRules(t *testing.T) {
+	for _, raw := range []string{
+		`{"name": "not-an-array"}`,
+		`[{"group": "vip"}]`,
+		`[{"name": "bad-role", "role": "root"}]`,
+		`[{"name": "bad-op", "when": {"conditions": [{"field": "groups", "op": "matches", "value": "x"}]}, "group": "vip"}]`,
+		`[{"name": "negative", "quota": -1}]`,
+		`[{"name": "dup", "group": "vip"}, {"name": "dup", "group": "svip"}]`,
+	} {
+		_, err := ParseClaimMappings(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -263,20 +263,8 @@ func (p *GenericOAuthProvider) GetUserInfo(ctx context.Context, token *OAuthToke
 	logger.LogDebug(ctx, "[OAuth-Generic-%s] GetUserInfo success: id=%s, username=%s, name=%s, email=%s",
 		p.config.Slug, userId, username, displayName, email)
 
-	policyRaw := strings.TrimSpace(p.config.AccessPolicy)
-	if policyRaw != "" {
-		policy, err := parseAccessPolicy(policyRaw)
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("[OAuth-Generic-%s] invalid access policy: %s", p.config.Slug, err.Error()))
-			return nil, NewOAuthErrorWithRaw(i18n.MsgOAuthGetUserErr, nil, "invalid access policy configuration")
-		}
-		allowed, failure := evaluateAccessPolicy(bodyStr, policy)
-		if !allowed {
-			message := renderAccessDeniedMessage(p.config.AccessDeniedMessage, p.config.Name, bodyStr, failure)
-			logger.LogWarn(ctx, fmt.Sprintf("[OAuth-Generic-%s] access denied by policy: field=%s op=%s expected=%v current=%v",
-				p.config.Slug, failure.Field, failure.Op, failure.Expected, failure.Current))
-			return nil, &AccessDeniedError{Message: message}
-		}
+	if err := p.checkAccessPolicy(ctx, bodyStr); err != nil {
+		return nil, err
 	}
 
 	return &OAuthUser{
@@ -284,6 +272,7 @@ func (p *GenericOAuthProvider) GetUserInfo(ctx context.Context, token *OAuthToke
 		Username:       username,
 		DisplayName:    displayName,
 		Email:          email,
+		Claims:         bodyStr,
 		Extra: map[string]any{
 			"provider": p.config.Slug,
 		},
@@ -317,6 +306,16 @@ func (p *GenericOAuthProvider) GetProviderId() int {
 	return p.config.Id
 }
 
+// GetClientId returns the client ID shown to the frontend login button
+func (p *GenericOAuthProvider) GetClientId() string {
+	return p.config.ClientId
+}
+
+// GetAuthorizationEndpoint returns the URL the frontend redirects to for login
+func (p *GenericOAuthProvider) GetAuthorizationEndpoint() string {
+	return p.config.AuthorizationEndpoint
+}
+
 func normalizeAuthorizationTokenType(tokenType string) string {
 	tokenType = strings.TrimSpace(tokenType)
 	if tokenType == "" || strings.EqualFold(tokenType, "Bearer") {
@@ -325,6 +324,28 @@ func normalizeAuthorizationTokenType(tokenType string) string {
 	return tokenType
 }
 
+// checkAccessPolicy evaluates the configured access policy against the user
+// info document (JSON) and returns an error when access is denied.
+func (p *GenericOAuthProvider) checkAccessPolicy(ctx context.Context, bodyStr string) error {
+	policyRaw := strings.TrimSpace(p.config.AccessPolicy)
+	if policyRaw == "" {
+		return nil
+	}
+	policy, err := parseAccessPolicy(policyRaw)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("[OAuth-Generic-%s] invalid access policy: %s", p.config.Slug, err.Error()))
+		return NewOAuthErrorWithRaw(i18n.MsgOAuthGetUserErr, nil, "invalid access policy configuration")
+	}
+	allowed, failure := evaluateAccessPolicy(bodyStr, policy)
+	if !allowed {
+		message := renderAccessDeniedMessage(p.config.AccessDeniedMessage, p.config.Name, bodyStr, failure)
+		logger.LogWarn(ctx, fmt.Sprintf("[OAuth-Generic-%s] access denied by policy: field=%s op=%s expected=%v current=%v",
+			p.config.Slug, failure.Field, failure.Op, failure.Expected, failure.Current))
+		return &AccessDeniedError{Message: message}
+	}
+	return nil
+}
+
 // IsGenericProvider returns true for generic providers
 func (p *GenericOAuthProvider) IsGenericProvider() bool {
 	return true
@@ -34,3 +34,22 @@ type Provider interface {
 	// GetProviderPrefix returns the prefix for auto-generated usernames (e.g., "github_")
 	GetProviderPrefix() string
 }
+
+// CustomProvider is implemented by providers configured in the
+// custom_oauth_providers table (generic OAuth and SAML). Their accounts are
+// linked through the user_oauth_bindings table instead of a user column.
+type CustomProvider interface {
+	Provider
+
+	// GetConfig returns the stored provider configuration
+	GetConfig() *model.CustomOAuthProvider
+
+	// GetProviderId returns the provider ID used in user_oauth_bindings
+	GetProviderId() int
+
+	// GetClientId returns the client ID the frontend sends with the login request
+	GetClientId() string
+
+	// GetAuthorizationEndpoint returns the URL the frontend redirects to for login
+	GetAuthorizationEndpoint() string
+}
@@ -56,14 +56,14 @@ func GetAllProviders() map[string]Provider {
 	return result
 }
 
-// GetEnabledCustomProviders returns all enabled custom OAuth providers
-func GetEnabledCustomProviders() []*GenericOAuthProvider {
+// GetEnabledCustomProviders returns all enabled custom OAuth and SAML providers
+func GetEnabledCustomProviders() []CustomProvider {
 	mu.RLock()
 	defer mu.RUnlock()
-	var result []*GenericOAuthProvider
+	var result []CustomProvider
 	for name, provider := range providers {
 		if customProviderSlugs[name] {
-			if gp, ok := provider.(*GenericOAuthProvider); ok && gp.IsEnabled() {
+			if gp, ok := provider.(CustomProvider); ok && gp.IsEnabled() {
 				result = append(result, gp)
 			}
 		}
@@ -105,8 +105,7 @@ func LoadCustomProviders() error {
 
 	// Register each custom provider
 	for _, config := range customProviders {
-		provider := NewGenericOAuthProvider(config)
-		RegisterCustom(config.Slug, provider)
+		RegisterCustom(config.Slug, newCustomProvider(config))
 		common.SysLog("Loaded custom OAuth provider: " + config.Name + " (" + config.Slug + ")")
 	}
 
@@ -121,13 +120,27 @@ func ReloadCustomProviders() error {
 
 // RegisterOrUpdateCustomProvider registers or updates a single custom provider
 func RegisterOrUpdateCustomProvider(config *model.CustomOAuthProvider) {
-	provider := NewGenericOAuthProvider(config)
+	provider := newCustomProvider(config)
 	mu.Lock()
 	defer mu.Unlock()
 	providers[config.Slug] = provider
 	customProviderSlugs[config.Slug] = true
 }
 
+// newCustomProvider creates the provider implementation for the config kind
+func newCustomProvider(config *model.CustomOAuthProvider) CustomProvider {
+	if config.IsSAML() {
+		return NewSamlProvider(config)
+	}
+	return NewGenericOAuthProvider(config)
+}
+
+// GetSamlProvider returns the registered SAML provider for slug, or nil
+func GetSamlProvider(slug string) *SamlProvider {
+	provider, _ := GetProvider(slug).(*SamlProvider)
+	return provider
+}
+
 // UnregisterCustomProvider unregisters a custom provider by slug
 func UnregisterCustomProvider(slug string) {
 	Unregister(slug)
@@ -0,0 +1,430 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	// samlMetadataRefreshInterval controls how often IdP metadata fetched from
+	// a URL is reloaded, so that IdP certificate rollovers are picked up.
+	samlMetadataRefreshInterval = 24 * time.Hour
+	samlMetadataMaxBytes        = 1 << 20
+	samlNameIDField             = "NameID"
+)
+
+// SamlProvider implements SAML 2.0 Web Browser SSO as a service provider.
+// It embeds GenericOAuthProvider so account binding, field mapping and access
+// policies behave exactly like custom OAuth providers; only the login
+// ceremony differs:
+//
+//  1. /api/saml/:slug/login redirects to the IdP with a signed AuthnRequest.
+//  2. The IdP posts the response to /api/saml/:slug/acs, which validates it
+//     and issues a one-time ticket.
+//  3. The browser lands on /oauth/:slug?code=<ticket>&state=<state> and the
+//     regular OAuth callback exchanges the ticket for the mapped attributes.
+type SamlProvider struct {
+	*GenericOAuthProvider
+
+	mu         sync.Mutex
+	idp        *saml.EntityDescriptor
+	idpFetched time.Time
+}
+
+// samlTicketPayload is stored in the ticket AuthFlow between the ACS and the
+// OAuth callback.
+type samlTicketPayload struct {
+	State      string         `json:"state"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// NewSamlProvider creates a SAML provider from config
+func NewSamlProvider(config *model.CustomOAuthProvider) *SamlProvider {
+	return &SamlProvider{GenericOAuthProvider: NewGenericOAuthProvider(config)}
+}
+
+func (p *SamlProvider) baseURL() string {
+	return strings.TrimRight(system_setting.ServerAddress, "/") + "/api/saml/" + p.config.Slug
+}
+
+// MetadataURL is where the SP metadata is published
+func (p *SamlProvider) MetadataURL() string {
+	return p.baseURL() + "/metadata"
+}
+
+// AcsURL is the assertion consumer service endpoint
+func (p *SamlProvider) AcsURL() string {
+	return p.baseURL() + "/acs"
+}
+
+// EntityID returns the configured SP entity ID, defaulting to the metadata URL
+func (p *SamlProvider) EntityID() string {
+	if p.config.ClientId != "" {
+		return p.config.ClientId
+	}
+	return p.MetadataURL()
+}
+
+// GetClientId returns the SP entity ID; the frontend only needs it to be set.
+func (p *SamlProvider) GetClientId() string {
+	return p.EntityID()
+}
+
+// GetAuthorizationEndpoint points the frontend login button at the SAML login
+// endpoint, which accepts the same state parameter as an OAuth authorize URL.
+func (p *SamlProvider) GetAuthorizationEndpoint() string {
+	return p.baseURL() + "/login"
+}
+
+// serviceProvider builds the crewjam service provider. The IdP metadata is
+// only required to start a login or validate a response, not for publishing
+// SP metadata, so it is passed in by the caller.
+func (p *SamlProvider) serviceProvider(idp *saml.EntityDescriptor) (*saml.ServiceProvider, error) {
+	keyPair, err := tls.X509KeyPair([]byte(p.config.SamlSpCertificate), []byte(p.config.SamlSpPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML SP key pair: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("SAML SP private key cannot sign")
+	}
+	metadataURL, err := url.Parse(p.MetadataURL())
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(p.AcsURL())
+	if err != nil {
+		return nil, err
+	}
+	return &saml.ServiceProvider{
+		EntityID:          p.EntityID(),
+		Key:               signer,
+		Certificate:       certificate,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+		HTTPClient:        &http.Client{Timeout: 20 * time.Second},
+	}, nil
+}
+
+// Metadata renders the SP metadata document to register with the IdP.
+func (p *SamlProvider) Metadata() ([]byte, error) {
+	sp, err := p.serviceProvider(nil)
+	if err != nil {
+		return nil, err
+	}
+	descriptor := sp.Metadata()
+	// Only the HTTP-POST binding is implemented by the ACS endpoint.
+	for i := range descriptor.SPSSODescriptors {
+		services := descriptor.SPSSODescriptors[i].AssertionConsumerServices[:0]
+		for _, service := range descriptor.SPSSODescriptors[i].AssertionConsumerServices {
+			if service.Binding == saml.HTTPPostBinding {
+				services = append(services, service)
+			}
+		}
+		descriptor.SPSSODescriptors[i].AssertionConsumerServices = services
+	}
+	return xml.MarshalIndent(descriptor, "", "  ")
+}
+
+// identityProvider returns the IdP metadata, fetching it from the metadata URL
+// when configured. A failed refresh keeps serving the previous metadata.
+func (p *SamlProvider) identityProvider(ctx context.Context) (*saml.EntityDescriptor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config.SamlIdpMetadataUrl == "" {
+		if p.idp == nil {
+			idp, err := ParseSamlIdpMetadata([]byte(p.config.SamlIdpMetadata))
+			if err != nil {
+				return nil, err
+			}
+			p.idp = idp
+		}
+		return p.idp, nil
+	}
+
+	if p.idp != nil && time.Since(p.idpFetched) < samlMetadataRefreshInterval {
+		return p.idp, nil
+	}
+	idp, err := fetchSamlIdpMetadata(ctx, p.config.SamlIdpMetadataUrl)
+	if err != nil {
+		if p.idp != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("[SAML-%s] refresh IdP metadata failed, keeping cached copy: %s", p.config.Slug, err.Error()))
+			p.idpFetched = time.Now()
+			return p.idp, nil
+		}
+		return nil, err
+	}
+	p.idp = idp
+	p.idpFetched = time.Now()
+	return idp, nil
+}
+
+func fetchSamlIdpMetadata(ctx context.Context, metadataURL string) (*saml.EntityDescriptor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Timeout: 20 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch SAML IdP metadata: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch SAML IdP metadata: unexpected status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, samlMetadataMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	return ParseSamlIdpMetadata(body)
+}
+
+// ParseSamlIdpMetadata parses an EntityDescriptor, or the first IdP entity of
+// an EntitiesDescriptor as published by federations and some IdPs.
+func ParseSamlIdpMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	var entity saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &entity); err == nil && len(entity.IDPSSODescriptors) > 0 {
+		return &entity, nil
+	}
+	var entities saml.EntitiesDescriptor
+	if err := xml.Unmarshal(data, &entities); err != nil {
+		return nil, fmt.Errorf("invalid SAML IdP metadata: %w", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, errors.New("invalid SAML IdP metadata: no IDPSSODescriptor found")
+}
+
+// MakeAuthnRequest builds a signed AuthnRequest for the HTTP-Redirect binding.
+// The returned request ID must be kept to validate InResponseTo on the
+// response; redirect builds the IdP URL once the relay state is known.
+func (p *SamlProvider) MakeAuthnRequest(ctx context.Context) (requestID string, redirect func(relayState string) (string, error), err error) {
+	idp, err := p.identityProvider(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	sp, err := p.serviceProvider(idp)
+	if err != nil {
+		return "", nil, err
+	}
+	location := sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return "", nil, errors.New("SAML IdP does not offer an HTTP-Redirect SSO endpoint")
+	}
+	req, err := sp.MakeAuthenticationRequest(location, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", nil, err
+	}
+	return req.ID, func(relayState string) (string, error) {
+		target, err := req.Redirect(url.QueryEscape(relayState), sp)
+		if err != nil {
+			return "", err
+		}
+		return target.String(), nil
+	}, nil
+}
+
+// ParseResponse verifies the signature, audience, recipient, validity window
+// and InResponseTo of a base64 encoded SAMLResponse.
+func (p *SamlProvider) ParseResponse(ctx context.Context, samlResponse string, requestID string) (*saml.Assertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlResponse))
+	if err != nil {
+		return nil, errors.New("SAMLResponse is not valid base64")
+	}
+	idp, err := p.identityProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := p.serviceProvider(idp)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := sp.ParseXMLResponse(raw, []string{requestID}, sp.AcsURL)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("[SAML-%s] invalid response: %s", p.config.Slug, invalid.PrivateErr.Error()))
+		}
+		return nil, err
+	}
+	if assertion.ID == "" {
+		return nil, errors.New("SAML assertion has no ID")
+	}
+	return assertion, nil
+}
+
+// AssertionAttributes flattens an assertion into a JSON-friendly document:
+// the subject NameID under "NameID" and every attribute under both its Name
+// and FriendlyName. Single-valued attributes are strings, others string lists.
+func AssertionAttributes(assertion *saml.Assertion) map[string]any {
+	attributes := make(map[string]any)
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		attributes[samlNameIDField] = strings.TrimSpace(assertion.Subject.NameID.Value)
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			values := make([]string, 0, len(attribute.Values))
+			for _, value := range attribute.Values {
+				if value.NameID != nil {
+					values = append(values, strings.TrimSpace(value.NameID.Value))
+					continue
+				}
+				values = append(values, strings.TrimSpace(value.Value))
+			}
+			var document any = values
+			if len(values) == 1 {
+				document = values[0]
+			}
+			for _, name := range []string{attribute.Name, attribute.FriendlyName} {
+				if name != "" {
+					if _, exists := attributes[name]; !exists {
+						attributes[name] = document
+					}
+				}
+			}
+		}
+	}
+	return attributes
+}
+
+// IssueAssertionTicket stores the validated attributes behind a one-time
+// ticket that the OAuth callback redeems through ExchangeToken. The ticket is
+// tied to the OAuth state so it cannot be replayed into another login.
+func (p *SamlProvider) IssueAssertionTicket(assertion *saml.Assertion, state string, expiresAt time.Time) (string, error) {
+	payload, err := common.Marshal(samlTicketPayload{
+		State:      state,
+		Attributes: AssertionAttributes(assertion),
+	})
+	if err != nil {
+		return "", err
+	}
+	ticket, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose:   model.AuthFlowPurposeSamlTicket,
+		Provider:  p.config.Slug,
+		Payload:   string(payload),
+		ExpiresAt: expiresAt,
+	})
+	return ticket, err
+}
+
+// ExchangeToken redeems the ticket issued by the ACS endpoint. The attribute
+// document travels in AccessToken to GetUserInfo.
+func (p *SamlProvider) ExchangeToken(ctx context.Context, code string, c *gin.Context) (*OAuthToken, error) {
+	if code == "" {
+		return nil, NewOAuthError(i18n.MsgOAuthInvalidCode, nil)
+	}
+	flow, err := model.ConsumeAuthFlow(code, model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeSamlTicket,
+		Provider: p.config.Slug,
+	})
+	if err != nil {
+		return nil, NewOAuthErrorWithRaw(i18n.MsgOAuthInvalidCode, nil, err.Error())
+	}
+	var payload samlTicketPayload
+	if err := common.UnmarshalJsonStr(flow.Payload, &payload); err != nil {
+		return nil, err
+	}
+	if c == nil || payload.State == "" || payload.State != c.Query("state") {
+		return nil, NewOAuthErrorWithRaw(i18n.MsgOAuthInvalidCode, nil, "SAML ticket does not belong to this state")
+	}
+	attributes, err := common.Marshal(payload.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthToken{AccessToken: string(attributes), TokenType: "SAML"}, nil
+}
+
+// GetUserInfo maps the assertion attributes to the local user. Field mappings
+// name a SAML attribute (Name or FriendlyName, case-insensitive) or "NameID";
+// access policies are evaluated on the attribute document like OAuth user info.
+func (p *SamlProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	var attributes map[string]any
+	if err := common.UnmarshalJsonStr(token.AccessToken, &attributes); err != nil {
+		return nil, NewOAuthErrorWithRaw(i18n.MsgOAuthGetUserErr, nil, err.Error())
+	}
+
+	userId := samlAttributeValue(attributes, p.config.UserIdField)
+	if userId == "" {
+		logger.LogError(ctx, fmt.Sprintf("[SAML-%s] GetUserInfo failed: empty user ID (field: %s)", p.config.Slug, p.config.UserIdField))
+		return nil, NewOAuthError(i18n.MsgOAuthUserInfoEmpty, map[string]any{"Provider": p.config.Name})
+	}
+	if err := p.checkAccessPolicy(ctx, token.AccessToken); err != nil {
+		return nil, err
+	}
+
+	return &OAuthUser{
+		ProviderUserID: userId,
+		Username:       samlAttributeValue(attributes, p.config.UsernameField),
+		DisplayName:    samlAttributeValue(attributes, p.config.DisplayNameField),
+		Email:          samlAttributeValue(attributes, p.config.EmailField),
+		Claims:         token.AccessToken,
+		Extra: map[string]any{
+			"provider": p.config.Slug,
+		},
+	}, nil
+}
+
+// samlAttributeValue returns the first value of an attribute, matching the
+// name exactly first and then case-insensitively.
+func samlAttributeValue(attributes map[string]any, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	value, ok := attributes[name]
+	if !ok {
+		for key, candidate := range attributes {
+			if strings.EqualFold(key, name) {
+				value, ok = candidate, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamlProviderMapsAttributesByNameOrFriendlyName(t *testing.T) {
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: " 00u1001 "}},
+		AttributeStatements: []saml.AttributeStatement{{Attributes: []saml.Attribute{
+			{Name: "urn:oid:0.9.2342.19200300.100.1.3", FriendlyName: "mail", Values: []saml.AttributeValue{{Value: "alice@corp.example"}}},
+			{Name: "http://schemas.microsoft.com/identity/claims/displayname", Values: []saml.AttributeValue{{Value: "Alice"}}},
+			{Name: "groups", Values: []saml.AttributeValue{{Value: "staff"}, {Value: "admins"}}},
+		}}},
+	}
+	attributes := AssertionAttributes(assertion)
+	assert.Equal(t, "00u1001", attributes["NameID"])
+	assert.Equal(t, "alice@corp.example", attributes["mail"])
+	assert.Equal(t, "alice@corp.example", attributes["urn:oid:0.9.2342.19200300.100.1.3"])
+	assert.Equal(t, []string{"staff", "admins"}, attributes["groups"])
+
+	document, err := common.Marshal(attributes)
+	require.NoError(t, err)
+	provider := NewSamlProvider(&model.CustomOAuthProvider{
+		Kind:             model.CustomOAuthProviderKindSAML,
+		Slug:             "corp",
+		UserIdField:      "nameid",
+		UsernameField:    "groups",
+		DisplayNameField: "http://schemas.microsoft.com/identity/claims/displayname",
+		EmailField:       "Mail",
+		AccessPolicy:     `{"conditions":[{"field":"groups","op":"contains","value":"staff"}]}`,
+	})
+	user, err := provider.GetUserInfo(context.Background(), &OAuthToken{AccessToken: string(document)})
+	require.NoError(t, err)
+	assert.Equal(t, "00u1001", user.ProviderUserID)
+	assert.Equal(t, "staff", user.Username)
+	assert.Equal(t, "Alice", user.DisplayName)
+	assert.Equal(t, "alice@corp.example", user.Email)
+
+	provider.GetConfig().AccessPolicy = `{"conditions":[{"field":"groups","op":"contains","value":"contractors"}]}`
+	_, err = provider.GetUserInfo(context.Background(), &OAuthToken{AccessToken: string(document)})
+	var denied *AccessDeniedError
+	assert.ErrorAs(t, err, &denied)
+}
+
+func TestParseSamlIdpMetadataAcceptsEntitiesDescriptor(t *testing.T) {
+	metadata := `<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata">
+  <EntityDescriptor entityID="https://sp.example.com"><SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/></EntityDescriptor>
+  <EntityDescriptor entityID="https://idp.example.com">
+    <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+    </IDPSSODescriptor>
+  </EntityDescriptor>
+</EntitiesDescriptor>`
+	entity, err := ParseSamlIdpMetadata([]byte(metadata))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", entity.EntityID)
+
+	_, err = ParseSamlIdpMetadata([]byte(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="x"/>`))
+	assert.Error(t, err)
+}
@@ -20,6 +20,9 @@ type OAuthUser struct {
 	DisplayName string
 	// Email is the email from the OAuth provider
 	Email string
+	// Claims is the raw user info document (JSON) of custom providers, used to
+	// evaluate claim mappings after the user is resolved
+	Claims string
 	// Extra contains any additional provider-specific data
 	Extra map[string]any
 }
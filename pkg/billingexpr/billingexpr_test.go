@@ -2,6 +2,7 @@ package billingexpr_test
 
 import (
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/QuantumNous/new-api/pkg/billingexpr"
@@ -988,6 +989,47 @@ func TestLen_ZeroDefaultsToZero(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Simulate: full trace for admin testing
+// ---------------------------------------------------------------------------
+
+func TestSimulate_Trace(t *testing.T) {
+	exprStr := `param("service_tier") == "fast" && has(header("anthropic-beta"), "fast") ? tier("fast", p * 6 + c * 30) : tier("standard", p * 3 + c * 15)`
+	request := billingexpr.RequestInput{
+		Headers: map[string]string{"Anthropic-Beta": "fast-mode-2026-02-01"},
+		Body:    []byte(`{"service_tier":"fast"}`),
+	}
+	result, err := billingexpr.Simulate(exprStr, billingexpr.TokenParams{P: 1000, C: 100, CR: 50}, request, 500000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MatchedTier != "fast" || math.Abs(result.Cost-9000) > 1e-6 {
+		t.Errorf("tier = %q cost = %f, want fast 9000", result.MatchedTier, result.Cost)
+	}
+	if len(result.Vars) != 2 || result.Vars["p"] != 1000 || result.Vars["c"] != 100 {
+		t.Errorf("vars = %v, want only p and c", result.Vars)
+	}
+	var funcs []string
+	for _, call := range result.Calls {
+		funcs = append(funcs, call.Func)
+	}
+	if strings.Join(funcs, ",") != "param,header,has,tier" {
+		t.Errorf("calls = %v, want param,header,has,tier", funcs)
+	}
+	if result.Calls[1].Result != "fast-mode-2026-02-01" {
+		t.Errorf("header result = %v", result.Calls[1].Result)
+	}
+	if math.Abs(result.QuotaBeforeGroup-4500) > 1e-6 || result.QuotaAfterGroup != 9000 {
+		t.Errorf("quota = %f / %d, want 4500 / 9000", result.QuotaBeforeGroup, result.QuotaAfterGroup)
+	}
+}
+
+func TestSimulate_CompileError(t *testing.T) {
+	if _, err := billingexpr.Simulate(`p +`, billingexpr.TokenParams{}, billingexpr.RequestInput{}, 500000, 1); err == nil {
+		t.Error("expected compile error")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Benchmarks: compile vs cached execution
 // ---------------------------------------------------------------------------
@@ -49,8 +49,17 @@ func RunExprByHashWithRequest(exprStr, hash string, params TokenParams, request
 }
 
 func runProgram(prog *vm.Program, params TokenParams, request RequestInput) (float64, TraceResult, error) {
+	return runProgramWithRecorder(prog, params, request, nil)
+}
+
+// runProgramWithRecorder executes prog and, when record is non-nil, reports
+// every helper call (tier, header, param, has and the time helpers) in order.
+func runProgramWithRecorder(prog *vm.Program, params TokenParams, request RequestInput, record func(name string, result interface{}, args ...interface{})) (float64, TraceResult, error) {
 	trace := TraceResult{}
 	headers := normalizeHeaders(request.Headers)
+	if record == nil {
+		record = func(string, interface{}, ...interface{}) {}
+	}
 
 	env := map[string]interface{}{
 		"p":     params.P,
@@ -66,33 +75,29 @@ func runProgram(prog *vm.Program, params TokenParams, request RequestInput) (flo
 		"tier": func(name string, value float64) float64 {
 			trace.MatchedTier = name
 			trace.Cost = value
+			record("tier", value, name, value)
 			return value
 		},
 		"header": func(key string) string {
-			return headers[strings.ToLower(strings.TrimSpace(key))]
+			value := headers[strings.ToLower(strings.TrimSpace(key))]
+			record("header", value, key)
+			return value
 		},
 		"param": func(path string) interface{} {
-			path = strings.TrimSpace(path)
-			if path == "" || len(request.Body) == 0 {
-				return nil
-			}
-			result := gjson.GetBytes(request.Body, path)
-			if !result.Exists() {
-				return nil
-			}
-			return result.Value()
+			value := lookupParam(request.Body, path)
+			record("param", value, path)
+			return value
 		},
 		"has": func(source interface{}, substr string) bool {
-			if source == nil || substr == "" {
-				return false
-			}
-			return strings.Contains(fmt.Sprint(source), substr)
+			ok := source != nil && substr != "" && strings.Contains(fmt.Sprint(source), substr)
+			record("has", ok, source, substr)
+			return ok
 		},
-		"hour":    func(tz string) int { return timeInZone(tz).Hour() },
-		"minute":  func(tz string) int { return timeInZone(tz).Minute() },
-		"weekday": func(tz string) int { return int(timeInZone(tz).Weekday()) },
-		"month":   func(tz string) int { return int(timeInZone(tz).Month()) },
-		"day":     func(tz string) int { return timeInZone(tz).Day() },
+		"hour":    timeHelper("hour", request.Time, record, func(t time.Time) int { return t.Hour() }),
+		"minute":  timeHelper("minute", request.Time, record, func(t time.Time) int { return t.Minute() }),
+		"weekday": timeHelper("weekday", request.Time, record, func(t time.Time) int { return int(t.Weekday()) }),
+		"month":   timeHelper("month", request.Time, record, func(t time.Time) int { return int(t.Month()) }),
+		"day":     timeHelper("day", request.Time, record, func(t time.Time) int { return t.Day() }),
 		"max":     math.Max,
 		"min":     math.Min,
 		"abs":     math.Abs,
@@ -111,16 +116,40 @@ func runProgram(prog *vm.Program, params TokenParams, request RequestInput) (flo
 	return f, trace, nil
 }
 
-func timeInZone(tz string) time.Time {
+func lookupParam(body []byte, path string) interface{} {
+	path = strings.TrimSpace(path)
+	if path == "" || len(body) == 0 {
+		return nil
+	}
+	result := gjson.GetBytes(body, path)
+	if !result.Exists() {
+		return nil
+	}
+	return result.Value()
+}
+
+func timeHelper(name string, at time.Time, record func(string, interface{}, ...interface{}), field func(time.Time) int) func(string) int {
+	return func(tz string) int {
+		value := field(timeInZone(at, tz))
+		record(name, value, tz)
+		return value
+	}
+}
+
+// timeInZone returns at (now when zero) in tz, falling back to UTC.
+func timeInZone(at time.Time, tz string) time.Time {
+	if at.IsZero() {
+		at = time.Now()
+	}
 	tz = strings.TrimSpace(tz)
 	if tz == "" {
-		return time.Now().UTC()
+		return at.UTC()
 	}
 	loc, err := time.LoadLocation(tz)
 	if err != nil {
-		return time.Now().UTC()
+		return at.UTC()
 	}
-	return time.Now().In(loc)
+	return at.In(loc)
 }
 
 func normalizeHeaders(headers map[string]string) map[string]string {
@@ -0,0 +1,85 @@
+package billingexpr
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/expr-lang/expr"
+)
+
+// SimulationCall is one helper invocation recorded while simulating an
+// expression, in evaluation order.
+type SimulationCall struct {
+	Func   string        `json:"func"`
+	Args   []interface{} `json:"args"`
+	Result interface{}   `json:"result"`
+}
+
+// SimulationResult is the full trace of a simulated evaluation: which token
+// variables the expression reads, every helper call, the raw expression
+// output and the quota it converts to.
+type SimulationResult struct {
+	Version          int                `json:"version"`
+	Vars             map[string]float64 `json:"vars"`
+	Calls            []SimulationCall   `json:"calls"`
+	Cost             float64            `json:"cost"`
+	MatchedTier      string             `json:"matched_tier"`
+	GroupRatio       float64            `json:"group_ratio"`
+	QuotaBeforeGroup float64            `json:"quota_before_group"`
+	QuotaAfterGroup  int                `json:"quota_after_group"`
+	Clamped          bool               `json:"clamped"`
+}
+
+// Simulate evaluates an expression against the given token params and request
+// and returns a full trace. Unlike RunExpr it compiles without touching the
+// program cache, so admins can try candidate expressions freely before saving.
+func Simulate(exprStr string, params TokenParams, request RequestInput, quotaPerUnit, groupRatio float64) (SimulationResult, error) {
+	version, body := ParseExprVersion(exprStr)
+	prog, err := expr.Compile(body, expr.Env(getCompileEnv(version)), expr.AsFloat64())
+	if err != nil {
+		return SimulationResult{}, fmt.Errorf("expr compile error: %w", err)
+	}
+
+	result := SimulationResult{
+		Version:    version,
+		Vars:       map[string]float64{},
+		Calls:      []SimulationCall{},
+		GroupRatio: groupRatio,
+	}
+	values := tokenParamValues(params)
+	for name := range extractUsedVars(prog) {
+		if value, ok := values[name]; ok {
+			result.Vars[name] = value
+		}
+	}
+
+	cost, trace, err := runProgramWithRecorder(prog, params, request, func(name string, value interface{}, args ...interface{}) {
+		result.Calls = append(result.Calls, SimulationCall{Func: name, Args: args, Result: value})
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Cost = cost
+	result.MatchedTier = trace.MatchedTier
+	result.QuotaBeforeGroup = quotaConversion(cost, &BillingSnapshot{ExprVersion: version, QuotaPerUnit: quotaPerUnit})
+	afterGroup, clamp := common.QuotaRoundChecked(result.QuotaBeforeGroup * groupRatio)
+	result.QuotaAfterGroup = afterGroup
+	result.Clamped = clamp != nil
+	return result, nil
+}
+
+func tokenParamValues(params TokenParams) map[string]float64 {
+	return map[string]float64{
+		"p":     params.P,
+		"c":     params.C,
+		"len":   params.Len,
+		"cr":    params.CR,
+		"cc":    params.CC,
+		"cc1h":  params.CC1h,
+		"img":   params.Img,
+		"img_o": params.ImgO,
+		"ai":    params.AI,
+		"ao":    params.AO,
+	}
+}
@@ -3,6 +3,7 @@ package billingexpr
 import (
 	"crypto/sha256"
 	"fmt"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 )
@@ -10,6 +11,9 @@ import (
 type RequestInput struct {
 	Headers map[string]string
 	Body    []byte
+	// Time is what the time helpers read. Zero means now; replaying a past
+	// request sets it to the time the request was made.
+	Time time.Time
 }
 
 // TokenParams holds all token dimensions passed into an Expr evaluation.
@@ -0,0 +1,99 @@
+// Package eventbus is an in-process publish/subscribe bus for typed domain
+// events. Publishers never wait for subscribers: every event is dispatched on
+// a worker goroutine, so emitting an event from a hot path (quota deduction,
+// payment callbacks) costs one allocation and a channel send at most.
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// Event is the envelope delivered to subscribers and serialized into webhook
+// payloads. Data holds the typed payload of the event.
+type Event struct {
+	ID         string  `json:"id"`
+	Type       Type    `json:"type"`
+	Version    int     `json:"version"`
+	OccurredAt int64   `json:"occurred_at"`
+	UserId     int     `json:"user_id,omitempty"` // Owner of the event, 0 for system-wide events
+	Data       Payload `json:"data"`
+}
+
+// Handler receives published events. Handlers run on a worker goroutine and
+// must not assume any ordering between events.
+type Handler func(event *Event)
+
+var (
+	pool = gopool.NewPool("gopool.EventBus", 64, gopool.NewConfig())
+
+	subscribersMu sync.RWMutex
+	subscribers   = map[int]Handler{}
+	nextHandlerID int
+)
+
+func init() {
+	pool.SetPanicHandler(func(_ context.Context, i interface{}) {
+		common.SysError(fmt.Sprintf("panic in event bus subscriber: %v", i))
+	})
+}
+
+// Subscribe registers a handler for every event and returns a function that
+// removes it again.
+func Subscribe(handler Handler) func() {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	nextHandlerID++
+	id := nextHandlerID
+	subscribers[id] = handler
+	return func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+		delete(subscribers, id)
+	}
+}
+
+// NewEvent wraps a payload into an envelope without publishing it.
+func NewEvent(userId int, payload Payload) *Event {
+	eventType := payload.EventType()
+	version := 1
+	if definition, ok := Lookup(eventType); ok {
+		version = definition.Version
+	}
+	return &Event{
+		ID:         "evt_" + common.GetUUID(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: time.Now().Unix(),
+		UserId:     userId,
+		Data:       payload,
+	}
+}
+
+// Publish emits an event owned by userId (0 for system-wide events) to all
+// subscribers asynchronously.
+func Publish(userId int, payload Payload) {
+	if payload == nil {
+		return
+	}
+	subscribersMu.RLock()
+	handlers := make([]Handler, 0, len(subscribers))
+	for _, handler := range subscribers {
+		handlers = append(handlers, handler)
+	}
+	subscribersMu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+	event := NewEvent(userId, payload)
+	for _, handler := range handlers {
+		h := handler
+		pool.Go(func() { h(event) })
+	}
+}
@@ -0,0 +1,56 @@
+package eventbus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishDeliversTypedEnvelope(t *testing.T) {
+	received := make(chan *Event, 1)
+	unsubscribe := Subscribe(func(event *Event) { received <- event })
+	defer unsubscribe()
+
+	Publish(7, TokenExhaustedPayload{TokenId: 3, Name: "ci"})
+
+	select {
+	case event := <-received:
+		assert.Equal(t, TokenExhausted, event.Type)
+		assert.Equal(t, 1, event.Version)
+		assert.Equal(t, 7, event.UserId)
+		assert.NotEmpty(t, event.ID)
+		assert.Equal(t, TokenExhaustedPayload{TokenId: 3, Name: "ci"}, event.Data)
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	received := make(chan *Event, 1)
+	unsubscribe := Subscribe(func(event *Event) { received <- event })
+	unsubscribe()
+
+	Publish(0, ChannelEnabledPayload{ChannelId: 1})
+
+	select {
+	case <-received:
+		t.Fatal("unsubscribed handler received an event")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDefinitionsCoverEveryPayload(t *testing.T) {
+	payloads := []Payload{
+		ChannelAutoDisabledPayload{}, ChannelEnabledPayload{}, TokenCreatedPayload{}, TokenExhaustedPayload{},
+		TopUpCompletedPayload{}, SubscriptionRenewedPayload{}, SubscriptionExpiredPayload{},
+		TaskFinishedPayload{}, SystemTaskFailedPayload{},
+	}
+	require.Len(t, Definitions(), len(payloads))
+	for _, payload := range payloads {
+		definition, ok := Lookup(payload.EventType())
+		assert.True(t, ok, payload.EventType())
+		assert.NotEmpty(t, definition.Scope)
+	}
+}
@@ -0,0 +1,151 @@
+package eventbus
+
+import "sort"
+
+// Type identifies an event. Types are part of the public webhook contract and
+// must never be renamed; incompatible payload changes bump the version of the
+// definition instead.
+type Type string
+
+const (
+	ChannelAutoDisabled Type = "channel.auto_disabled"
+	ChannelEnabled      Type = "channel.enabled"
+	TokenCreated        Type = "token.created"
+	TokenExhausted      Type = "token.exhausted"
+	TopUpCompleted      Type = "topup.completed"
+	SubscriptionRenewed Type = "subscription.renewed"
+	SubscriptionExpired Type = "subscription.expired"
+	TaskFinished        Type = "task.finished"
+	SystemTaskFailed    Type = "system_task.failed"
+)
+
+// Scope decides who may subscribe to an event type.
+type Scope string
+
+const (
+	// ScopeUser events belong to one user; the owner and administrators may subscribe.
+	ScopeUser Scope = "user"
+	// ScopeSystem events concern the whole deployment; only administrators may subscribe.
+	ScopeSystem Scope = "system"
+)
+
+// Definition describes an event type and the current schema version of its payload.
+type Definition struct {
+	Type        Type   `json:"type"`
+	Version     int    `json:"version"`
+	Scope       Scope  `json:"scope"`
+	Description string `json:"description"`
+}
+
+var definitions = map[Type]Definition{
+	ChannelAutoDisabled: {Type: ChannelAutoDisabled, Version: 1, Scope: ScopeSystem, Description: "A channel was disabled automatically after failing requests or tests."},
+	ChannelEnabled:      {Type: ChannelEnabled, Version: 1, Scope: ScopeSystem, Description: "An automatically disabled channel was enabled again."},
+	TokenCreated:        {Type: TokenCreated, Version: 1, Scope: ScopeUser, Description: "An API token was created."},
+	TokenExhausted:      {Type: TokenExhausted, Version: 1, Scope: ScopeUser, Description: "The remaining quota of an API token reached zero."},
+	TopUpCompleted:      {Type: TopUpCompleted, Version: 1, Scope: ScopeUser, Description: "A top-up order was paid and credited."},
+	SubscriptionRenewed: {Type: SubscriptionRenewed, Version: 1, Scope: ScopeUser, Description: "The quota of a subscription was reset for a new period."},
+	SubscriptionExpired: {Type: SubscriptionExpired, Version: 1, Scope: ScopeUser, Description: "A subscription reached its end time and expired."},
+	TaskFinished:        {Type: TaskFinished, Version: 1, Scope: ScopeUser, Description: "An asynchronous generation task succeeded or failed."},
+	SystemTaskFailed:    {Type: SystemTaskFailed, Version: 1, Scope: ScopeSystem, Description: "A background system task failed."},
+}
+
+// Definitions returns all event definitions sorted by type.
+func Definitions() []Definition {
+	list := make([]Definition, 0, len(definitions))
+	for _, definition := range definitions {
+		list = append(list, definition)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// Lookup returns the definition of an event type.
+func Lookup(eventType Type) (Definition, bool) {
+	definition, ok := definitions[eventType]
+	return definition, ok
+}
+
+// Payload is implemented by the typed data of every event.
+type Payload interface {
+	EventType() Type
+}
+
+type ChannelAutoDisabledPayload struct {
+	ChannelId   int    `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	Reason      string `json:"reason"`
+}
+
+func (ChannelAutoDisabledPayload) EventType() Type { return ChannelAutoDisabled }
+
+type ChannelEnabledPayload struct {
+	ChannelId   int    `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+}
+
+func (ChannelEnabledPayload) EventType() Type { return ChannelEnabled }
+
+type TokenCreatedPayload struct {
+	TokenId        int    `json:"token_id"`
+	Name           string `json:"name"`
+	RemainQuota    int    `json:"remain_quota"`
+	UnlimitedQuota bool   `json:"unlimited_quota"`
+	ExpiredTime    int64  `json:"expired_time"` // -1 means never
+}
+
+func (TokenCreatedPayload) EventType() Type { return TokenCreated }
+
+type TokenExhaustedPayload struct {
+	TokenId int    `json:"token_id"`
+	Name    string `json:"name"`
+}
+
+func (TokenExhaustedPayload) EventType() Type { return TokenExhausted }
+
+type TopUpCompletedPayload struct {
+	TradeNo       string  `json:"trade_no"`
+	PaymentMethod string  `json:"payment_method"`
+	Amount        int64   `json:"amount"`
+	Money         float64 `json:"money"`
+	Quota         int     `json:"quota"` // Quota credited to the user
+}
+
+func (TopUpCompletedPayload) EventType() Type { return TopUpCompleted }
+
+type SubscriptionRenewedPayload struct {
+	SubscriptionId int   `json:"subscription_id"`
+	PlanId         int   `json:"plan_id"`
+	AmountTotal    int64 `json:"amount_total"`
+	NextResetTime  int64 `json:"next_reset_time"`
+	EndTime        int64 `json:"end_time"`
+}
+
+func (SubscriptionRenewedPayload) EventType() Type { return SubscriptionRenewed }
+
+type SubscriptionExpiredPayload struct {
+	SubscriptionId int   `json:"subscription_id"`
+	PlanId         int   `json:"plan_id"`
+	EndTime        int64 `json:"end_time"`
+}
+
+func (SubscriptionExpiredPayload) EventType() Type { return SubscriptionExpired }
+
+type TaskFinishedPayload struct {
+	TaskId     string `json:"task_id"`
+	Platform   string `json:"platform"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	FailReason string `json:"fail_reason,omitempty"`
+	Quota      int    `json:"quota"`
+	FinishTime int64  `json:"finish_time"`
+}
+
+func (TaskFinishedPayload) EventType() Type { return TaskFinished }
+
+type SystemTaskFailedPayload struct {
+	TaskId   string `json:"task_id"`
+	TaskType string `json:"task_type"`
+	Error    string `json:"error"`
+}
+
+func (SystemTaskFailedPayload) EventType() Type { return SystemTaskFailed }
@@ -0,0 +1,81 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores objects as files below Root.
+type Local struct {
+	Root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{Root: root}
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file next to the target and renames it, so
+// readers never see a partially written object.
+func (l *Local) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return io.ErrUnexpectedEOF
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
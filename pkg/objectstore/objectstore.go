@@ -0,0 +1,36 @@
+// Package objectstore stores opaque objects under slash separated keys on
+// local disk or in an S3-compatible bucket.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Open when no object exists under the key.
+var ErrNotFound = errors.New("object not found")
+
+// Store is implemented by every storage backend. Delete of a missing object
+// is not an error.
+type Store interface {
+	// Put stores size bytes read from body. body is read more than once by
+	// backends that hash the payload before sending it.
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// cleanKey rejects keys that are empty, absolute or escape their root.
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", errors.New("invalid object key")
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.New("invalid object key")
+	}
+	return cleaned, nil
+}
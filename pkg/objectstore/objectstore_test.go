@@ -0,0 +1,117 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanKeyRejectsEscapes(t *testing.T) {
+	for _, key := range []string{"", "/abs", "../x", "a/../../x", "a//b", `a\b`, "."} {
+		_, err := cleanKey(key)
+		assert.Error(t, err, key)
+	}
+	key, err := cleanKey("tasks/7/task_abc")
+	require.NoError(t, err)
+	assert.Equal(t, "tasks/7/task_abc", key)
+}
+
+func testStoreRoundTrip(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	payload := []byte("video bytes")
+	require.NoError(t, store.Put(ctx, "tasks/7/task_abc", bytes.NewReader(payload), int64(len(payload)), "video/mp4"))
+
+	reader, err := store.Open(ctx, "tasks/7/task_abc")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, payload, got)
+
+	require.NoError(t, store.Delete(ctx, "tasks/7/task_abc"))
+	require.NoError(t, store.Delete(ctx, "tasks/7/task_abc"))
+	_, err = store.Open(ctx, "tasks/7/task_abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	testStoreRoundTrip(t, NewLocal(t.TempDir()))
+}
+
+func TestLocalPutRejectsShortBody(t *testing.T) {
+	store := NewLocal(t.TempDir())
+	err := store.Put(context.Background(), "a", strings.NewReader("abc"), 10, "")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = store.Open(context.Background(), "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// fakeS3 keeps objects in memory and checks that requests are signed.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	paths   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.URL.EscapedPath())
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3RoundTripPathStyle(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "artifacts",
+		AccessKeyId:     "AKID",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}, server.Client())
+	require.NoError(t, err)
+	testStoreRoundTrip(t, store)
+	assert.Equal(t, "/artifacts/tasks/7/task_abc", fake.paths[0])
+}
+
+func TestS3VirtualHostedURL(t *testing.T) {
+	store, err := NewS3(S3Config{Endpoint: "https://s3.example.com", Bucket: "b", AccessKeyId: "a", SecretAccessKey: "s"}, nil)
+	require.NoError(t, err)
+	objectURL, err := store.objectURL("tasks/1/a b")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.s3.example.com/tasks/1/a%20b", objectURL)
+
+	_, err = NewS3(S3Config{Endpoint: "s3.example.com", Bucket: "b", AccessKeyId: "a", SecretAccessKey: "s"}, nil)
+	assert.Error(t, err)
+}
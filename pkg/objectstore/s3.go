@@ -0,0 +1,169 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body, used for GET and DELETE.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config describes an S3-compatible bucket. Endpoint is the service URL,
+// e.g. https://s3.us-east-1.amazonaws.com or a MinIO / R2 endpoint.
+// PathStyle addresses the bucket as a path segment instead of a subdomain,
+// which most self-hosted implementations require.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+// S3 talks to the S3 REST API directly with SigV4 signed requests.
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	signer   *v4.Signer
+}
+
+// NewS3 validates config. A nil client uses http.DefaultClient.
+func NewS3(config S3Config, client *http.Client) (*S3, error) {
+	if config.Bucket == "" || config.AccessKeyId == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("s3 bucket and credentials are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, errors.New("s3 endpoint must be an http or https URL")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3{
+		config:   config,
+		endpoint: endpoint,
+		client:   client,
+		// S3 signs the path as sent instead of escaping it a second time
+		signer: v4.NewSigner(func(options *v4.SignerOptions) {
+			options.DisableURIPathEscaping = true
+		}),
+	}, nil
+}
+
+func (s *S3) objectURL(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	escapedKey := strings.Join(segments, "/")
+	objectURL := *s.endpoint
+	prefix := strings.TrimSuffix(objectURL.Path, "/")
+	if s.config.PathStyle {
+		objectURL.Path = prefix + "/" + s.config.Bucket + "/" + key
+		objectURL.RawPath = prefix + "/" + url.PathEscape(s.config.Bucket) + "/" + escapedKey
+	} else {
+		objectURL.Host = s.config.Bucket + "." + objectURL.Host
+		objectURL.Path = prefix + "/" + key
+		objectURL.RawPath = prefix + "/" + escapedKey
+	}
+	return objectURL.String(), nil
+}
+
+func (s *S3) do(ctx context.Context, method string, key string, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	credentials := aws.Credentials{AccessKeyID: s.config.AccessKeyId, SecretAccessKey: s.config.SecretAccessKey}
+	if err := s.signer.SignHTTP(ctx, credentials, req, payloadHash, "s3", s.config.Region, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, hex.EncodeToString(hash.Sum(nil)), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,109 @@
+package wasmplugin
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+const hostModuleName = "newapi"
+
+const (
+	maxLogLines    = 32
+	maxLogLineSize = 1024
+	maxRejectSize  = 1024
+)
+
+type invocationKey struct{}
+
+// invocation is the host side state of a single hook call.
+type invocation struct {
+	metadata map[string]any
+	logs     []string
+	rejected string
+}
+
+func currentInvocation(ctx context.Context) *invocation {
+	call, _ := ctx.Value(invocationKey{}).(*invocation)
+	return call
+}
+
+func instantiateHostModule(ctx context.Context, runtime wazero.Runtime) error {
+	i32 := api.ValueTypeI32
+	_, err := runtime.NewHostModuleBuilder(hostModuleName).
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(hostMetadata), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i32}).
+		WithParameterNames("key_ptr", "key_len", "buf_ptr", "buf_cap").
+		Export("metadata").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(hostLog), []api.ValueType{i32, i32}, nil).
+		WithParameterNames("ptr", "len").
+		Export("log").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(hostReject), []api.ValueType{i32, i32}, nil).
+		WithParameterNames("ptr", "len").
+		Export("reject").
+		Instantiate(ctx)
+	return err
+}
+
+// readString copies a guest string, truncated to limit bytes.
+func readString(mod api.Module, ptr, length uint32, limit uint32) (string, bool) {
+	length = min(length, limit)
+	data, ok := mod.Memory().Read(ptr, length)
+	if !ok {
+		return "", false
+	}
+	return string(data), true
+}
+
+func hostMetadata(ctx context.Context, mod api.Module, stack []uint64) {
+	keyPtr, keyLen := api.DecodeU32(stack[0]), api.DecodeU32(stack[1])
+	bufPtr, bufCap := api.DecodeU32(stack[2]), api.DecodeU32(stack[3])
+	stack[0] = api.EncodeI32(-1)
+
+	call := currentInvocation(ctx)
+	key, ok := readString(mod, keyPtr, keyLen, math.MaxUint32)
+	if call == nil || !ok {
+		return
+	}
+	var value any = call.metadata
+	if key != "" {
+		if value, ok = call.metadata[key]; !ok {
+			return
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil || len(data) > math.MaxInt32 {
+		return
+	}
+	if uint32(len(data)) <= bufCap && !mod.Memory().Write(bufPtr, data) {
+		return
+	}
+	stack[0] = api.EncodeI32(int32(len(data)))
+}
+
+func hostLog(ctx context.Context, mod api.Module, stack []uint64) {
+	call := currentInvocation(ctx)
+	if call == nil || len(call.logs) >= maxLogLines {
+		return
+	}
+	if line, ok := readString(mod, api.DecodeU32(stack[0]), api.DecodeU32(stack[1]), maxLogLineSize); ok {
+		call.logs = append(call.logs, line)
+	}
+}
+
+func hostReject(ctx context.Context, mod api.Module, stack []uint64) {
+	call := currentInvocation(ctx)
+	if call == nil {
+		return
+	}
+	message, ok := readString(mod, api.DecodeU32(stack[0]), api.DecodeU32(stack[1]), maxRejectSize)
+	if !ok || message == "" {
+		message = "rejected by plugin"
+	}
+	call.rejected = message
+}
@@ -0,0 +1,308 @@
+// Package wasmplugin runs sandboxed WebAssembly plugins that transform relay
+// payloads. Plugins run on a pure Go runtime with bounded memory and time.
+// Instances are pooled per compiled module so that hooks called for every
+// stream chunk do not pay for instantiation each time. An instance keeps its
+// memory between invocations, so plugins must not rely on globals being
+// reset; instances are discarded after a failure and after a bounded number
+// of invocations, which also bounds memory a plugin never frees.
+//
+// A plugin module must export its linear memory as "memory" and an
+// allocator "alloc(size i32) i32". Every hook it implements is exported
+// under the hook name with the signature "(ptr i32, len i32) i64": the host
+// writes the JSON payload into memory obtained from alloc and calls the
+// hook, which returns 0 to keep the payload or the location of the
+// replacement packed as ptr<<32 | len.
+//
+// Modules may import the WASI preview1 functions (without file system,
+// arguments or environment) and the host functions of the "newapi" module:
+//
+//	metadata(key_ptr, key_len, buf_ptr, buf_cap i32) i32
+//	    Writes the JSON value of a request metadata key, or the whole
+//	    metadata object for an empty key, into the buffer and returns its
+//	    length. Nothing is written when the value exceeds buf_cap, so the
+//	    caller can retry with a larger buffer. Returns -1 for unknown keys.
+//	log(ptr, len i32)
+//	    Records a log line for the invocation.
+//	reject(ptr, len i32)
+//	    Rejects the request with the given message. The hook result is
+//	    ignored afterwards.
+package wasmplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	HookPreConversion  = "pre_conversion"
+	HookPostConversion = "post_conversion"
+	HookResponseChunk  = "response_chunk"
+	HookFinalUsage     = "final_usage"
+)
+
+// Hooks lists every hook in the order they run during a request.
+var Hooks = []string{HookPreConversion, HookPostConversion, HookResponseChunk, HookFinalUsage}
+
+const (
+	exportMemory = "memory"
+	exportAlloc  = "alloc"
+)
+
+const (
+	// maxIdleInstances caps the instances a module keeps for reuse.
+	maxIdleInstances = 16
+	// maxInstanceUses is the number of invocations after which an instance
+	// is replaced by a fresh one.
+	maxInstanceUses = 1024
+)
+
+// ErrTimeout is returned when an invocation exceeds its time limit.
+var ErrTimeout = errors.New("plugin exceeded its time limit")
+
+// Limits bound the resources of every plugin instance.
+type Limits struct {
+	// MemoryPages caps linear memory in 64 KiB pages.
+	MemoryPages uint32
+	// Timeout bounds a single hook invocation including instantiation.
+	Timeout time.Duration
+}
+
+// Runtime compiles and runs plugin modules under fixed limits.
+type Runtime struct {
+	runtime wazero.Runtime
+	limits  Limits
+}
+
+// NewRuntime creates a runtime with the host API and WASI registered.
+func NewRuntime(ctx context.Context, limits Limits) (*Runtime, error) {
+	config := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(limits.MemoryPages)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	if err := instantiateHostModule(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	return &Runtime{runtime: runtime, limits: limits}, nil
+}
+
+func (r *Runtime) Limits() Limits {
+	return r.limits
+}
+
+// Close releases the runtime and every module compiled with it.
+func (r *Runtime) Close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
+
+// Module is a compiled plugin binary and its pool of idle instances.
+type Module struct {
+	runtime  *Runtime
+	compiled wazero.CompiledModule
+	hooks    []string
+
+	mu     sync.Mutex
+	idle   []*instance
+	closed bool
+}
+
+// instance is a pooled instantiation of a module.
+type instance struct {
+	module api.Module
+	uses   int
+}
+
+// Compile validates binary against the plugin ABI and compiles it.
+func (r *Runtime) Compile(ctx context.Context, binary []byte) (*Module, error) {
+	compiled, err := r.runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wasm module: %w", err)
+	}
+	module := &Module{runtime: r, compiled: compiled}
+	if err := module.validate(); err != nil {
+		_ = compiled.Close(ctx)
+		return nil, err
+	}
+	return module, nil
+}
+
+func (m *Module) validate() error {
+	for _, definition := range m.compiled.ImportedFunctions() {
+		moduleName, name, _ := definition.Import()
+		if moduleName != hostModuleName && moduleName != wasi_snapshot_preview1.ModuleName {
+			return fmt.Errorf("plugin imports unsupported function %s.%s", moduleName, name)
+		}
+	}
+	if _, ok := m.compiled.ExportedMemories()[exportMemory]; !ok {
+		return fmt.Errorf("plugin must export its memory as %q", exportMemory)
+	}
+	exports := m.compiled.ExportedFunctions()
+	alloc, ok := exports[exportAlloc]
+	if !ok || !hasSignature(alloc, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		return fmt.Errorf("plugin must export %s(size i32) i32", exportAlloc)
+	}
+	for _, hook := range Hooks {
+		definition, ok := exports[hook]
+		if !ok {
+			continue
+		}
+		if !hasSignature(definition, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}) {
+			return fmt.Errorf("hook %s must have the signature (ptr i32, len i32) i64", hook)
+		}
+		m.hooks = append(m.hooks, hook)
+	}
+	if len(m.hooks) == 0 {
+		return errors.New("plugin exports no hooks")
+	}
+	return nil
+}
+
+func hasSignature(definition api.FunctionDefinition, params []api.ValueType, results []api.ValueType) bool {
+	return slices.Equal(definition.ParamTypes(), params) && slices.Equal(definition.ResultTypes(), results)
+}
+
+// Hooks returns the hooks the module implements.
+func (m *Module) Hooks() []string {
+	return m.hooks
+}
+
+func (m *Module) Has(hook string) bool {
+	return slices.Contains(m.hooks, hook)
+}
+
+// Close releases the idle instances and the compiled module. Instances still
+// in use are released when their invocation returns.
+func (m *Module) Close(ctx context.Context) error {
+	m.mu.Lock()
+	idle := m.idle
+	m.idle, m.closed = nil, true
+	m.mu.Unlock()
+	for _, inst := range idle {
+		_ = inst.module.Close(ctx)
+	}
+	return m.compiled.Close(ctx)
+}
+
+// take returns an idle instance, or nil when a new one must be created.
+func (m *Module) take() *instance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.idle) == 0 {
+		return nil
+	}
+	inst := m.idle[len(m.idle)-1]
+	m.idle = m.idle[:len(m.idle)-1]
+	return inst
+}
+
+// put returns an instance that completed an invocation to the pool.
+func (m *Module) put(inst *instance) {
+	inst.uses++
+	m.mu.Lock()
+	if !m.closed && len(m.idle) < maxIdleInstances && inst.uses < maxInstanceUses {
+		m.idle = append(m.idle, inst)
+		inst = nil
+	}
+	m.mu.Unlock()
+	if inst != nil {
+		_ = inst.module.Close(context.Background())
+	}
+}
+
+// Result is the outcome of one hook invocation. Output is nil when the
+// plugin kept the payload unchanged.
+type Result struct {
+	Output   []byte
+	Rejected string
+	Logs     []string
+}
+
+// Invoke runs hook on payload in a pooled instance. Hooks the module does not
+// implement leave the payload unchanged.
+func (m *Module) Invoke(ctx context.Context, hook string, payload []byte, metadata map[string]any) (*Result, error) {
+	if !m.Has(hook) {
+		return &Result{}, nil
+	}
+	if m.runtime.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.runtime.limits.Timeout)
+		defer cancel()
+	}
+
+	inst := m.take()
+	result, err := m.invokeOn(ctx, inst, hook, payload, metadata)
+	if err != nil && inst != nil && ctx.Err() == nil {
+		// A reused instance may have run out of memory the plugin never
+		// freed, so the invocation is retried once on a fresh instance
+		result, err = m.invokeOn(ctx, nil, hook, payload, metadata)
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// invokeOn runs hook on inst, or on a new instance when inst is nil. The
+// instance goes back to the pool on success and is closed on failure.
+func (m *Module) invokeOn(ctx context.Context, inst *instance, hook string, payload []byte, metadata map[string]any) (*Result, error) {
+	call := &invocation{metadata: metadata}
+	ctx = context.WithValue(ctx, invocationKey{}, call)
+	if inst == nil {
+		config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+		module, err := m.runtime.runtime.InstantiateModule(ctx, m.compiled, config)
+		if err != nil {
+			return nil, fmt.Errorf("instantiate plugin: %w", err)
+		}
+		inst = &instance{module: module}
+	}
+	result, err := invoke(ctx, inst.module, hook, payload, call)
+	if err != nil {
+		_ = inst.module.Close(context.Background())
+		return nil, err
+	}
+	m.put(inst)
+	return result, nil
+}
+
+func invoke(ctx context.Context, instance api.Module, hook string, payload []byte, call *invocation) (*Result, error) {
+	allocated, err := instance.ExportedFunction(exportAlloc).Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin alloc: %w", err)
+	}
+	ptr := uint32(allocated[0])
+	if !instance.Memory().Write(ptr, payload) {
+		return nil, errors.New("plugin alloc returned memory out of range")
+	}
+	returned, err := instance.ExportedFunction(hook).Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", hook, err)
+	}
+
+	result := &Result{Rejected: call.rejected, Logs: call.logs}
+	if call.rejected != "" || returned[0] == 0 {
+		return result, nil
+	}
+	outPtr, outLen := uint32(returned[0]>>32), uint32(returned[0])
+	output, ok := instance.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s returned memory out of range", hook)
+	}
+	// output is a view into the instance memory, which the next invocation reuses
+	result.Output = append([]byte{}, output...)
+	return result, nil
+}
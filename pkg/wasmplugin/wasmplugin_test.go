@@ -0,0 +1,220 @@
+package wasmplugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The test modules are assembled by hand so the tests need no toolchain.
+
+func uleb(n uint64) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func sleb(n int64) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && b&0x40 == 0) || (n == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	out := uleb(uint64(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func name(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func section(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(payload)))...), payload...)
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func i32Const(n int64) []byte { return append([]byte{0x41}, sleb(n)...) }
+func i64Const(n int64) []byte { return append([]byte{0x42}, sleb(n)...) }
+
+const (
+	testInputOffset  = 1024
+	testKeyOffset    = 2048
+	testRejectOffset = 2064
+	testOutputOffset = 4096
+)
+
+// testModule builds a plugin with memory of minPages pages implementing:
+//   - post_conversion: returns the metadata value of "model"
+//   - response_chunk: logs its input and keeps it unchanged
+//   - pre_conversion: rejects with "blocked"
+//   - final_usage: loops forever
+func testModule(minPages uint64) []byte {
+	const (
+		typeMetadata = iota
+		typeLog
+		typeAlloc
+		typeHook
+	)
+	types := section(1, vec(
+		[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00},
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e},
+	))
+	imports := section(2, vec(
+		cat(name(hostModuleName), name("metadata"), []byte{0x00, typeMetadata}),
+		cat(name(hostModuleName), name("log"), []byte{0x00, typeLog}),
+		cat(name(hostModuleName), name("reject"), []byte{0x00, typeLog}),
+	))
+	functions := section(3, vec([]byte{typeAlloc}, []byte{typeHook}, []byte{typeHook}, []byte{typeHook}, []byte{typeHook}))
+	memory := section(5, vec(cat([]byte{0x00}, uleb(minPages))))
+	exports := section(7, vec(
+		cat(name(exportMemory), []byte{0x02, 0x00}),
+		cat(name(exportAlloc), []byte{0x00, 0x03}),
+		cat(name(HookPostConversion), []byte{0x00, 0x04}),
+		cat(name(HookResponseChunk), []byte{0x00, 0x05}),
+		cat(name(HookPreConversion), []byte{0x00, 0x06}),
+		cat(name(HookFinalUsage), []byte{0x00, 0x07}),
+	))
+
+	body := func(locals []byte, code ...[]byte) []byte {
+		fn := cat(append([][]byte{locals}, code...)...)
+		fn = append(fn, 0x0b)
+		return append(uleb(uint64(len(fn))), fn...)
+	}
+	noLocals := []byte{0x00}
+	code := section(10, vec(
+		body(noLocals, i32Const(testInputOffset)),
+		body([]byte{0x01, 0x01, 0x7f},
+			i32Const(testKeyOffset), i32Const(5), i32Const(testOutputOffset), i32Const(256),
+			[]byte{0x10, 0x00, 0x21, 0x02},
+			i64Const(testOutputOffset), i64Const(32), []byte{0x86},
+			[]byte{0x20, 0x02, 0xad, 0x84},
+		),
+		body(noLocals, []byte{0x20, 0x00, 0x20, 0x01, 0x10, 0x01}, i64Const(0)),
+		body(noLocals, i32Const(testRejectOffset), i32Const(7), []byte{0x10, 0x02}, i64Const(0)),
+		body(noLocals, []byte{0x03, 0x40, 0x0c, 0x00, 0x0b}, i64Const(0)),
+	))
+	data := section(11, vec(
+		cat([]byte{0x00}, i32Const(testKeyOffset), []byte{0x0b}, name("model")),
+		cat([]byte{0x00}, i32Const(testRejectOffset), []byte{0x0b}, name("blocked")),
+	))
+	return cat([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		types, imports, functions, memory, exports, code, data)
+}
+
+func newTestRuntime(t *testing.T, limits Limits) *Runtime {
+	t.Helper()
+	runtime, err := NewRuntime(context.Background(), limits)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = runtime.Close(context.Background()) })
+	return runtime
+}
+
+func TestModuleHooks(t *testing.T) {
+	runtime := newTestRuntime(t, Limits{MemoryPages: 16, Timeout: time.Second})
+	module, err := runtime.Compile(context.Background(), testModule(1))
+	require.NoError(t, err)
+	assert.Equal(t, Hooks, module.Hooks())
+	ctx := context.Background()
+
+	result, err := module.Invoke(ctx, HookPostConversion, []byte(`{}`), map[string]any{"model": "gpt-4o"})
+	require.NoError(t, err)
+	assert.Equal(t, `"gpt-4o"`, string(result.Output))
+
+	result, err = module.Invoke(ctx, HookResponseChunk, []byte(`{"id":"chunk"}`), nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.Output)
+	assert.Equal(t, []string{`{"id":"chunk"}`}, result.Logs)
+
+	result, err = module.Invoke(ctx, HookPreConversion, []byte(`{}`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "blocked", result.Rejected)
+	assert.Nil(t, result.Output)
+
+	start := time.Now()
+	_, err = module.Invoke(ctx, HookFinalUsage, []byte(`{}`), nil)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestModuleUnknownMetadata(t *testing.T) {
+	runtime := newTestRuntime(t, Limits{MemoryPages: 16, Timeout: time.Second})
+	module, err := runtime.Compile(context.Background(), testModule(1))
+	require.NoError(t, err)
+
+	// metadata returns -1 for the missing key, which the test plugin turns
+	// into an out of range result
+	_, err = module.Invoke(context.Background(), HookPostConversion, []byte(`{}`), map[string]any{})
+	assert.ErrorContains(t, err, "out of range")
+}
+
+func TestCompileRejectsInvalidModules(t *testing.T) {
+	runtime := newTestRuntime(t, Limits{MemoryPages: 1, Timeout: time.Second})
+
+	_, err := runtime.Compile(context.Background(), []byte("not wasm"))
+	assert.ErrorContains(t, err, "invalid wasm module")
+
+	_, err = runtime.Compile(context.Background(), testModule(2))
+	assert.Error(t, err, "memory above the limit must be rejected")
+
+	empty := cat([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(5, vec([]byte{0x00, 0x01})),
+		section(7, vec(cat(name(exportMemory), []byte{0x02, 0x00}))))
+	_, err = runtime.Compile(context.Background(), empty)
+	assert.ErrorContains(t, err, "must export alloc")
+}
+
+func TestModuleReusesInstances(t *testing.T) {
+	runtime := newTestRuntime(t, Limits{MemoryPages: 16, Timeout: time.Second})
+	module, err := runtime.Compile(context.Background(), testModule(1))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := module.Invoke(ctx, HookResponseChunk, []byte(`{"id":"chunk"}`), nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`{"id":"chunk"}`}, result.Logs, "logs do not carry over between invocations")
+	}
+	require.Len(t, module.idle, 1, "sequential invocations share one instance")
+	assert.Equal(t, 3, module.idle[0].uses)
+
+	// A failed instance is discarded instead of going back to the pool
+	_, err = module.Invoke(ctx, HookFinalUsage, []byte(`{}`), nil)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Empty(t, module.idle)
+
+	result, err := module.Invoke(ctx, HookPostConversion, []byte(`{}`), map[string]any{"model": "gpt-4o"})
+	require.NoError(t, err)
+	assert.Equal(t, `"gpt-4o"`, string(result.Output))
+	require.Len(t, module.idle, 1)
+
+	require.NoError(t, module.Close(ctx))
+	assert.Empty(t, module.idle)
+}
@@ -61,6 +61,11 @@ func AlphaSearchHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError
 		}
 	}
 
+	jsonData, newAPIError = applyPostConversionPlugins(c, info, jsonData)
+	if newAPIError != nil {
+		return newAPIError
+	}
+
 	logger.LogDebug(c, "requestBody: %s", jsonData)
 	body, closer, err := relaycommon.NewOutboundJSONBody(jsonData)
 	if err != nil {
@@ -74,7 +79,7 @@ func AlphaSearchHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError
 	}
 	adaptor.Init(info)
 
-	resp, err := adaptor.DoRequest(c, info, body)
+	resp, err := doUpstreamRequest(c, adaptor, info, body)
 	if err != nil {
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
@@ -86,6 +91,7 @@ func AlphaSearchHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError
 	}
 	defer httpResp.Body.Close()
 
+	applyResponseOverride(c, info, httpResp)
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
 		newAPIError = service.RelayErrorHandler(c.Request.Context(), httpResp, false)
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -310,7 +310,8 @@ func applyHeaderOverrideToRequest(req *http.Request, headerOverride map[string]s
 	}
 }
 
-func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+// NewApiRequest 构建发往上游的 JSON 请求（URL、渠道请求头与 Header Override），不发送
+func NewApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Request, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
@@ -333,6 +334,14 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 		return nil, err
 	}
 	applyHeaderOverrideToRequest(req, headerOverride)
+	return req, nil
+}
+
+func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	req, err := NewApiRequest(a, c, info, requestBody)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := doRequest(c, req, info)
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
@@ -509,13 +518,21 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		))
 	}
 
+	// 首 token 超时从请求发出时开始计时，等待响应头的时间同样计入
+	var firstTokenTimer *time.Timer
+	if firstTokenTimeout := helper.BeginStreamFirstTokenTimeout(c, info); firstTokenTimeout > 0 {
+		ctx, cancel := context.WithCancel(req.Context())
+		firstTokenTimer = time.AfterFunc(firstTokenTimeout, cancel)
+		req = req.WithContext(ctx)
+	}
+
 	var stopPinger context.CancelFunc
 	var pingerDone <-chan struct{}
 	if info.IsStream {
 		helper.SetEventStreamHeaders(c)
-		// 处理流式请求的 ping 保活
+		// 处理流式请求的 ping 保活；首 token 超时生效时不发送，保证超时后仍可换渠道重试
 		generalSettings := operation_setting.GetGeneralSetting()
-		if generalSettings.PingIntervalEnabled && !info.DisablePing {
+		if generalSettings.PingIntervalEnabled && !info.DisablePing && firstTokenTimer == nil {
 			pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
 			stopPinger, pingerDone = startPingKeepAlive(c, pingInterval)
 			// 使用defer确保在任何情况下都能停止ping goroutine
@@ -530,6 +547,14 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	}
 
 	resp, err := relayClient.Do(req)
+	if firstTokenTimer != nil && !firstTokenTimer.Stop() {
+		// 计时器已触发，请求已被取消，响应体即使已到达也无法继续读取
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		logger.LogWarn(c, "upstream sent no response headers before the first token timeout")
+		return nil, helper.NewStreamFirstTokenTimeoutError(errors.New("no response headers received from upstream"))
+	}
 	if err != nil {
 		logger.LogError(c, "do request failed: "+err.Error())
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
@@ -552,6 +577,7 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	if upID := resp.Header.Get(common2.RequestIdKey); upID != "" {
 		c.Set(common2.UpstreamRequestIdKey, upID)
 	}
+	service.ObserveUpstreamRateLimit(c, info, resp)
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
@@ -0,0 +1,60 @@
+package channel
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRequestFirstTokenTimeoutCoversResponseHeaders(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldSeconds := setting.FirstTokenTimeoutSeconds
+	setting.FirstTokenTimeoutSeconds = 1
+	oldRetryTimes := common.RetryTimes
+	common.RetryTimes = 1
+	t.Cleanup(func() {
+		setting.FirstTokenTimeoutSeconds = oldSeconds
+		common.RetryTimes = oldRetryTimes
+	})
+
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req, err := http.NewRequest(http.MethodPost, upstream.URL, bytes.NewReader([]byte(`{}`)))
+	require.NoError(t, err)
+	info := &relaycommon.RelayInfo{IsStream: true, ChannelMeta: &relaycommon.ChannelMeta{}}
+
+	start := time.Now()
+	resp, err := doRequest(c, req, info)
+	assert.Nil(t, resp)
+	var newAPIError *types.NewAPIError
+	require.True(t, errors.As(err, &newAPIError))
+	assert.Equal(t, types.ErrorCodeStreamFirstTokenTimeout, newAPIError.GetErrorCode())
+	assert.Less(t, time.Since(start), 3*time.Second, "waiting for headers counts against the first-token timeout")
+	assert.False(t, info.FirstTokenDeadline.IsZero())
+	assert.Empty(t, recorder.Body.String(), "nothing may be written before failover")
+}
@@ -134,6 +134,10 @@ func baiduStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 			sr.Error(err)
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return newAPIError, nil
+	}
+
 	service.CloseResponseBodyGracefully(resp)
 	return nil, usage
 }
@@ -206,6 +206,10 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 			sr.Stop(err)
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return nil, newAPIError
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -255,6 +255,10 @@ func difyStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 			sr.Error(err)
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return nil, newAPIError
+	}
+
 	helper.Done(c)
 	if usage.TotalTokens == 0 {
 		usage = service.ResponseText2Usage(c, responseText, info.UpstreamModelName, info.GetEstimatePromptTokens())
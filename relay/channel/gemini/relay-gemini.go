@@ -186,6 +186,9 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			sr.Stop(fmt.Errorf("gemini callback stopped"))
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return nil, newAPIError
+	}
 
 	if !hasBillableUsageMetadata {
 		if info.ReceivedResponseCount > 0 {
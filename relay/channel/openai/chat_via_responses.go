@@ -306,6 +306,9 @@ func OaiResponsesToChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo
 			}
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return nil, newAPIError
+	}
 
 	if streamErr != nil {
 		return nil, streamErr
@@ -146,6 +146,16 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 			}
 		}
 	})
+	if newAPIError := helper.StreamFirstTokenTimeoutError(info); newAPIError != nil {
+		return nil, newAPIError
+	}
+	if newAPIError := helper.InterruptStreamForContinuation(c, info, responseTextBuilder.String(), toolCount); newAPIError != nil {
+		// 补发被暂存的最后一个分片，续写内容将拼接在其后
+		if lastStreamData != "" {
+			_ = HandleStreamFormat(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent)
+		}
+		return nil, newAPIError
+	}
 
 	// 对音频模型，从倒数第二个stream data中提取usage信息
 	if isAudioModel && secondLastStreamData != "" {
@@ -176,6 +186,8 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 		if shouldSendLastResp {
 			_ = sendStreamData(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent)
 		}
+		// 中断后无法续写：按已输出部分计费，并以错误事件告知客户端
+		helper.StreamInterruptedErrorData(c, info)
 	}
 
 	if !containStreamUsage {
@@ -0,0 +1,300 @@
+package customtask
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	taskdto "github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const ChannelName = "custom_task"
+
+var taskStatuses = map[string]string{
+	dto.CustomTaskStatusSubmitted:  model.TaskStatusSubmitted,
+	dto.CustomTaskStatusQueued:     model.TaskStatusQueued,
+	dto.CustomTaskStatusInProgress: model.TaskStatusInProgress,
+	dto.CustomTaskStatusSuccess:    model.TaskStatusSuccess,
+	dto.CustomTaskStatusFailure:    model.TaskStatusFailure,
+}
+
+// TaskAdaptor drives an async task API described by the channel's
+// custom_task settings instead of provider specific code.
+type TaskAdaptor struct {
+	config  *dto.CustomTaskConfig
+	baseURL string
+	apiKey  string
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.config = info.ChannelOtherSettings.CustomTask
+	a.baseURL = info.ChannelBaseUrl
+	a.apiKey = info.ApiKey
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *taskdto.TaskError {
+	if a.config == nil {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("custom_task settings are missing"), "invalid_channel_config", http.StatusInternalServerError)
+	}
+	return relaycommon.ValidateBasicTaskRequest(c, info, constant.TaskActionGenerate)
+}
+
+func (a *TaskAdaptor) EstimateBilling(c *gin.Context, info *relaycommon.RelayInfo) map[string]float64 {
+	if a.config.Billing == nil || len(a.config.Billing.RequestRatios) == 0 {
+		return nil
+	}
+	req, err := relaycommon.GetTaskRequest(c)
+	if err != nil {
+		return nil
+	}
+	context, err := submitContext(info.UpstreamModelName, req)
+	if err != nil {
+		return nil
+	}
+	return extractRatios(a.config.Billing.RequestRatios, context)
+}
+
+// AdjustBillingOnSubmit replaces estimated ratios with those reported in the
+// submit response.
+func (a *TaskAdaptor) AdjustBillingOnSubmit(info *relaycommon.RelayInfo, taskData []byte) map[string]float64 {
+	if a.config.Billing == nil {
+		return nil
+	}
+	submitted := extractRatios(a.config.Billing.SubmitRatios, string(taskData))
+	if len(submitted) == 0 {
+		return nil
+	}
+	ratios := make(map[string]float64)
+	for name, ratio := range info.PriceData.OtherRatios() {
+		ratios[name] = ratio
+	}
+	for name, ratio := range submitted {
+		ratios[name] = ratio
+	}
+	return ratios
+}
+
+func (a *TaskAdaptor) AdjustBillingOnComplete(_ *model.Task, _ *relaycommon.TaskInfo) int {
+	return 0
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	context, err := submitContext(info.UpstreamModelName, relaycommon.TaskSubmitReq{})
+	if err != nil {
+		return "", err
+	}
+	return a.withQueryAuth(renderURL(a.baseURL, a.config.Submit.Path, context), a.apiKey)
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	a.setHeaders(req, a.config.Submit, info.ApiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetTaskRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	body, err := a.RenderSubmitBody(info.UpstreamModelName, req)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(body), nil
+}
+
+// RenderSubmitBody renders the submit body for req. Without a body template
+// the client request is forwarded with the upstream model name.
+func (a *TaskAdaptor) RenderSubmitBody(modelName string, req relaycommon.TaskSubmitReq) ([]byte, error) {
+	context, err := submitContext(modelName, req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(a.config.Submit.BodyTemplate) != "" {
+		return renderBody(a.config.Submit.BodyTemplate, context)
+	}
+	return []byte(gjson.Get(context, "request").Raw), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	fullRequestURL, err := a.BuildRequestURL(info)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(a.config.SubmitMethod(), fullRequestURL, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+	channel.ApplyUpstreamBodyMetadata(req, requestBody)
+	if err := a.BuildRequestHeader(c, req, info); err != nil {
+		return nil, fmt.Errorf("setup request header failed: %w", err)
+	}
+	return channel.DoRequest(c, req, info)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *taskdto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	taskID = gjson.GetBytes(responseBody, a.config.Response.TaskIdPath).String()
+	if taskID == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("no task id at %s in upstream response: %s", a.config.Response.TaskIdPath, responseBody),
+			"invalid_response", http.StatusInternalServerError)
+		return
+	}
+
+	ov := dto.NewOpenAIVideo()
+	ov.ID = info.PublicTaskID
+	ov.TaskID = info.PublicTaskID
+	ov.CreatedAt = time.Now().Unix()
+	ov.Model = info.OriginModelName
+	c.JSON(http.StatusOK, ov)
+	return taskID, responseBody, nil
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	if a.config == nil {
+		return nil, fmt.Errorf("custom_task settings are missing")
+	}
+	taskID, ok := body["task_id"].(string)
+	if !ok || taskID == "" {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	context, err := common.Marshal(map[string]any{dto.CustomTaskPlaceholderTaskId: taskID})
+	if err != nil {
+		return nil, err
+	}
+	fetchURL, err := a.withQueryAuth(renderURL(baseUrl, a.config.Fetch.Path, string(context)), key)
+	if err != nil {
+		return nil, err
+	}
+	var requestBody io.Reader
+	if strings.TrimSpace(a.config.Fetch.BodyTemplate) != "" {
+		rendered, err := renderBody(a.config.Fetch.BodyTemplate, string(context))
+		if err != nil {
+			return nil, err
+		}
+		requestBody = bytes.NewReader(rendered)
+	}
+	req, err := http.NewRequest(a.config.FetchMethod(), fetchURL, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	a.setHeaders(req, a.config.Fetch, key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	mapping := a.config.Response
+	upstreamStatus := gjson.GetBytes(respBody, mapping.StatusPath).String()
+	status, ok := taskStatuses[mapping.StatusMapping[upstreamStatus]]
+	if !ok {
+		return nil, fmt.Errorf("unknown task status %q at %s", upstreamStatus, mapping.StatusPath)
+	}
+
+	taskInfo := &relaycommon.TaskInfo{Status: status}
+	if mapping.ProgressPath != "" {
+		taskInfo.Progress = normalizeProgress(gjson.GetBytes(respBody, mapping.ProgressPath))
+	}
+	switch status {
+	case model.TaskStatusSuccess:
+		taskInfo.Url = gjson.GetBytes(respBody, mapping.ResultURLPath).String()
+	case model.TaskStatusFailure:
+		if mapping.FailReasonPath != "" {
+			taskInfo.Reason = gjson.GetBytes(respBody, mapping.FailReasonPath).String()
+		}
+		if taskInfo.Reason == "" {
+			taskInfo.Reason = fmt.Sprintf("upstream task status: %s", upstreamStatus)
+		}
+	}
+	return taskInfo, nil
+}
+
+func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
+	return common.Marshal(originTask.ToOpenAIVideo())
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return nil
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+// ============================
+// helpers
+// ============================
+
+// submitContext is the placeholder context of submit templates. The
+// callback URL is handled by the gateway and never sent upstream.
+func submitContext(modelName string, req relaycommon.TaskSubmitReq) (string, error) {
+	req.CallbackURL = ""
+	req.Model = modelName
+	context, err := common.Marshal(map[string]any{
+		dto.CustomTaskPlaceholderModel: modelName,
+		"request":                      req,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(context), nil
+}
+
+func (a *TaskAdaptor) setHeaders(req *http.Request, endpoint dto.CustomTaskEndpoint, apiKey string) {
+	if req.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range endpoint.Headers {
+		req.Header.Set(strings.TrimSpace(name), value)
+	}
+	auth := a.config.Auth
+	if auth == nil {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		return
+	}
+	if strings.TrimSpace(auth.Type) == dto.AdvancedCustomAuthTypeHeader {
+		req.Header.Set(strings.TrimSpace(auth.Name), applyAuthTemplate(auth.Value, apiKey))
+	}
+}
+
+func (a *TaskAdaptor) withQueryAuth(rawURL string, apiKey string) (string, error) {
+	auth := a.config.Auth
+	if auth == nil || strings.TrimSpace(auth.Type) != dto.AdvancedCustomAuthTypeQuery {
+		return rawURL, nil
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsedURL.Query()
+	query.Set(strings.TrimSpace(auth.Name), applyAuthTemplate(auth.Value, apiKey))
+	parsedURL.RawQuery = query.Encode()
+	return parsedURL.String(), nil
+}
+
+func applyAuthTemplate(template string, apiKey string) string {
+	return strings.ReplaceAll(template, "{api_key}", apiKey)
+}
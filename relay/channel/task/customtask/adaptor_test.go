@@ -0,0 +1,177 @@
+package customtask
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	hosttypes "github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdaptor(config *dto.CustomTaskConfig) *TaskAdaptor {
+	adaptor := &TaskAdaptor{}
+	adaptor.Init(&relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{
+		ChannelBaseUrl:       "https://tasks.example.com",
+		ApiKey:               "sk-upstream",
+		ChannelOtherSettings: dto.ChannelOtherSettings{CustomTask: config},
+	}})
+	return adaptor
+}
+
+func testConfig() *dto.CustomTaskConfig {
+	return &dto.CustomTaskConfig{
+		Submit: dto.CustomTaskEndpoint{
+			Path:         "/v1/{{model}}/generate",
+			Headers:      map[string]string{"X-Api-Version": "2"},
+			BodyTemplate: `{"input":{"text":"{{request.prompt}}","seconds":"{{request.duration}}","seed":"{{request.metadata.seed}}"},"label":"video of {{request.prompt}}"}`,
+		},
+		Fetch: dto.CustomTaskEndpoint{Path: "/v1/tasks/{{task_id}}"},
+		Response: dto.CustomTaskResponseMapping{
+			TaskIdPath:     "data.id",
+			StatusPath:     "data.state",
+			ProgressPath:   "data.percent",
+			ResultURLPath:  "data.outputs.0.url",
+			FailReasonPath: "data.error.message",
+			StatusMapping: map[string]string{
+				"pending": dto.CustomTaskStatusQueued,
+				"running": dto.CustomTaskStatusInProgress,
+				"done":    dto.CustomTaskStatusSuccess,
+				"error":   dto.CustomTaskStatusFailure,
+			},
+		},
+		Billing: &dto.CustomTaskBilling{
+			RequestRatios: map[string]dto.CustomTaskRatio{
+				"seconds": {Path: "request.duration", Min: 1, Max: 20},
+				"size":    {Path: "request.size", Values: map[string]float64{"720p": 1, "1080p": 1.5}},
+			},
+			SubmitRatios: map[string]dto.CustomTaskRatio{"seconds": {Path: "data.seconds", Min: 1, Max: 20}},
+		},
+	}
+}
+
+func TestCustomTaskRenderSubmitBody(t *testing.T) {
+	adaptor := newTestAdaptor(testConfig())
+	body, err := adaptor.RenderSubmitBody("video-pro", relaycommon.TaskSubmitReq{Prompt: "a cat", Duration: 8})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"input":{"text":"a cat","seconds":8},"label":"video of a cat"}`, string(body))
+
+	url, err := adaptor.BuildRequestURL(&relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "video-pro"}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://tasks.example.com/v1/video-pro/generate", url)
+
+	// Without a template the client request is forwarded with the upstream model
+	passThrough := testConfig()
+	passThrough.Submit.BodyTemplate = ""
+	body, err = newTestAdaptor(passThrough).RenderSubmitBody("video-pro", relaycommon.TaskSubmitReq{
+		Prompt: "a dog", Model: "alias", CallbackURL: "https://client.example/hook",
+	})
+	require.NoError(t, err)
+	var sent map[string]any
+	require.NoError(t, common.Unmarshal(body, &sent))
+	assert.Equal(t, "video-pro", sent["model"])
+	assert.Equal(t, "a dog", sent["prompt"])
+	assert.NotContains(t, sent, "callback_url")
+}
+
+func TestCustomTaskSubmitAndBilling(t *testing.T) {
+	adaptor := newTestAdaptor(testConfig())
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/video/generations", bytes.NewReader(nil))
+	c.Set("task_request", relaycommon.TaskSubmitReq{Prompt: "a cat", Duration: 8, Size: "1080p"})
+	info := &relaycommon.RelayInfo{
+		ChannelMeta:   &relaycommon.ChannelMeta{UpstreamModelName: "video-pro"},
+		TaskRelayInfo: &relaycommon.TaskRelayInfo{PublicTaskID: "task_public"},
+	}
+
+	assert.Equal(t, map[string]float64{"seconds": 8, "size": 1.5}, adaptor.EstimateBilling(c, info))
+
+	resp := &http.Response{Body: io.NopCloser(bytes.NewReader([]byte(`{"data":{"id":"up_123","seconds":"10"}}`)))}
+	taskID, taskData, taskErr := adaptor.DoResponse(c, resp, info)
+	require.Nil(t, taskErr)
+	assert.Equal(t, "up_123", taskID)
+
+	info.PriceData = hosttypes.PriceData{}
+	info.PriceData.AddOtherRatio("seconds", 8)
+	info.PriceData.AddOtherRatio("size", 1.5)
+	assert.Equal(t, map[string]float64{"seconds": 10, "size": 1.5}, adaptor.AdjustBillingOnSubmit(info, taskData))
+
+	// Client and upstream values outside the configured bounds are clamped
+	c.Set("task_request", relaycommon.TaskSubmitReq{Prompt: "a cat", Duration: -5, Size: "1080p"})
+	assert.Equal(t, map[string]float64{"seconds": 1, "size": 1.5}, adaptor.EstimateBilling(c, info))
+	assert.Equal(t, map[string]float64{"seconds": 20, "size": 1.5},
+		adaptor.AdjustBillingOnSubmit(info, []byte(`{"data":{"id":"up_123","seconds":"1e9"}}`)))
+
+	// Numeric ratios without a max are ignored
+	unbounded := testConfig()
+	unbounded.Billing.RequestRatios["seconds"] = dto.CustomTaskRatio{Path: "request.duration"}
+	assert.Equal(t, map[string]float64{"size": 1.5}, newTestAdaptor(unbounded).EstimateBilling(c, info))
+
+	resp = &http.Response{Body: io.NopCloser(bytes.NewReader([]byte(`{"data":{}}`)))}
+	_, _, taskErr = adaptor.DoResponse(c, resp, info)
+	require.NotNil(t, taskErr)
+}
+
+func TestCustomTaskFetchAndParse(t *testing.T) {
+	var gotPath, gotAuth, gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("X-Key")
+		gotQuery = r.URL.Query().Get("key")
+		_, _ = w.Write([]byte(`{"data":{"state":"done","percent":100,"outputs":[{"url":"https://cdn.example.com/v.mp4"}]}}`))
+	}))
+	defer server.Close()
+
+	config := testConfig()
+	config.Auth = &dto.AdvancedCustomRouteAuth{Type: dto.AdvancedCustomAuthTypeHeader, Name: "X-Key", Value: "Token {api_key}"}
+	adaptor := newTestAdaptor(config)
+	resp, err := adaptor.FetchTask(server.URL, "sk-poll", map[string]any{"task_id": "up/123"}, "")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/tasks/up%2F123", gotPath)
+	assert.Equal(t, "Token sk-poll", gotAuth)
+	assert.Empty(t, gotQuery)
+
+	taskInfo, err := adaptor.ParseTaskResult(body)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusSuccess, taskInfo.Status)
+	assert.Equal(t, "100%", taskInfo.Progress)
+	assert.Equal(t, "https://cdn.example.com/v.mp4", taskInfo.Url)
+
+	config.Auth = &dto.AdvancedCustomRouteAuth{Type: dto.AdvancedCustomAuthTypeQuery, Name: "key", Value: "{api_key}"}
+	resp, err = newTestAdaptor(config).FetchTask(server.URL, "sk-poll", map[string]any{"task_id": "up_1"}, "")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "sk-poll", gotQuery)
+}
+
+func TestCustomTaskParseTaskResultStatuses(t *testing.T) {
+	adaptor := newTestAdaptor(testConfig())
+
+	taskInfo, err := adaptor.ParseTaskResult([]byte(`{"data":{"state":"running","percent":"42.5"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusInProgress, taskInfo.Status)
+	assert.Equal(t, "42%", taskInfo.Progress)
+
+	taskInfo, err = adaptor.ParseTaskResult([]byte(`{"data":{"state":"error","error":{"message":"content policy"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusFailure, taskInfo.Status)
+	assert.Equal(t, "content policy", taskInfo.Reason)
+
+	taskInfo, err = adaptor.ParseTaskResult([]byte(`{"data":{"state":"error"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "upstream task status: error", taskInfo.Reason)
+
+	_, err = adaptor.ParseTaskResult([]byte(`{"data":{"state":"paused"}}`))
+	assert.ErrorContains(t, err, `unknown task status "paused"`)
+}
@@ -0,0 +1,137 @@
+package customtask
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/tidwall/gjson"
+)
+
+// renderString replaces {{path}} placeholders with values from the context
+// JSON. escape is applied to each substituted value.
+func renderString(template string, context string, escape func(string) string) string {
+	return dto.CustomTaskPlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		path := dto.CustomTaskPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		value := gjson.Get(context, path).String()
+		if escape != nil {
+			value = escape(value)
+		}
+		return value
+	})
+}
+
+// renderURL renders an endpoint path and resolves it against baseURL.
+func renderURL(baseURL string, path string, context string) string {
+	rendered := renderString(strings.TrimSpace(path), context, url.PathEscape)
+	if strings.HasPrefix(rendered, "/") {
+		return strings.TrimSuffix(baseURL, "/") + rendered
+	}
+	return rendered
+}
+
+// renderBody renders a JSON body template. A string that consists of a single
+// placeholder takes the value with its JSON type and is dropped from objects
+// when the value is missing or null.
+func renderBody(template string, context string) ([]byte, error) {
+	var document any
+	if err := common.UnmarshalJsonStr(template, &document); err != nil {
+		return nil, fmt.Errorf("invalid body_template: %w", err)
+	}
+	rendered, _ := renderValue(document, context)
+	return common.Marshal(rendered)
+}
+
+func renderValue(value any, context string) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			rendered, ok := renderValue(item, context)
+			if !ok {
+				delete(v, key)
+				continue
+			}
+			v[key] = rendered
+		}
+		return v, true
+	case []any:
+		for i, item := range v {
+			v[i], _ = renderValue(item, context)
+		}
+		return v, true
+	case string:
+		if match := dto.CustomTaskPlaceholderPattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			result := gjson.Get(context, match[1])
+			if !result.Exists() || result.Type == gjson.Null {
+				return nil, false
+			}
+			return result.Value(), true
+		}
+		return renderString(v, context, nil), true
+	default:
+		return v, true
+	}
+}
+
+// extractRatios reads billing ratios from body, skipping missing values.
+// Numeric values are clamped to the configured bounds; configurations saved
+// before bounds existed have no max and their numeric values are ignored.
+func extractRatios(ratios map[string]dto.CustomTaskRatio, body string) map[string]float64 {
+	if len(ratios) == 0 {
+		return nil
+	}
+	extracted := make(map[string]float64, len(ratios))
+	for name, ratio := range ratios {
+		result := gjson.Get(body, ratio.Path)
+		if !result.Exists() {
+			continue
+		}
+		if len(ratio.Values) > 0 {
+			if value, ok := ratio.Values[result.String()]; ok {
+				extracted[name] = value
+			}
+			continue
+		}
+		var value float64
+		switch result.Type {
+		case gjson.Number:
+			value = result.Float()
+		case gjson.String:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(result.Str), 64)
+			if err != nil {
+				continue
+			}
+			value = parsed
+		default:
+			continue
+		}
+		if ratio.Max <= 0 || math.IsNaN(value) {
+			continue
+		}
+		extracted[name] = min(max(value, ratio.Min), ratio.Max)
+	}
+	return extracted
+}
+
+// normalizeProgress converts a 0-100 number or a percentage string into the
+// "NN%" form stored on tasks.
+func normalizeProgress(result gjson.Result) string {
+	switch result.Type {
+	case gjson.Number:
+		return fmt.Sprintf("%d%%", int(result.Float()))
+	case gjson.String:
+		progress := strings.TrimSpace(result.Str)
+		if progress == "" || strings.HasSuffix(progress, "%") {
+			return progress
+		}
+		if value, err := strconv.ParseFloat(progress, 64); err == nil {
+			return fmt.Sprintf("%d%%", int(value))
+		}
+	}
+	return ""
+}
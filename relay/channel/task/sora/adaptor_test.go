@@ -39,3 +39,23 @@ func TestSoraBuildRequestBodyReturnsReplayablePassThroughBody(t *testing.T) {
 	require.NoError(t, replayBody.Close())
 	assert.Equal(t, payload, replay)
 }
+
+func TestSoraBuildRequestBodyDropsCallbackURL(t *testing.T) {
+	payload := []byte(`{"model":"sora-2","prompt":"a cat","callback_url":"https://client.example/hook"}`)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/videos", bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	defer common.CleanupBodyStorage(c)
+
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "sora-2-upstream"}}
+	body, err := (&TaskAdaptor{}).BuildRequestBody(c, info)
+	require.NoError(t, err)
+	sent, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var sentMap map[string]any
+	require.NoError(t, common.Unmarshal(sent, &sentMap))
+	assert.Equal(t, "sora-2-upstream", sentMap["model"])
+	assert.Equal(t, "a cat", sentMap["prompt"])
+	assert.NotContains(t, sentMap, "callback_url")
+}
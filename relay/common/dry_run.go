@@ -0,0 +1,12 @@
+package common
+
+import "net/http"
+
+// DryRunCapture 记录预演请求最终构建出的上游请求
+type DryRunCapture struct {
+	Captured bool
+	Method   string
+	URL      string
+	Header   http.Header
+	Body     []byte
+}
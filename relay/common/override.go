@@ -44,6 +44,8 @@ var paramOverrideSensitivePathPrefixes = []string{
 
 type paramOverrideAuditRecorder struct {
 	lines []string
+	// all 为 true 时记录全部操作，而不只是敏感路径上的操作
+	all bool
 }
 
 type ConditionOperation struct {
@@ -183,7 +185,10 @@ func ApplyParamOverrideWithRelayInfo(jsonData []byte, info *RelayInfo) ([]byte,
 
 	overrideCtx := BuildParamOverrideContext(info)
 	var recorder *paramOverrideAuditRecorder
-	if shouldEnableParamOverrideAudit(paramOverride) {
+	if info != nil && info.DryRun != nil {
+		recorder = &paramOverrideAuditRecorder{all: true}
+		overrideCtx[paramOverrideContextAuditRecorder] = recorder
+	} else if shouldEnableParamOverrideAudit(paramOverride) {
 		recorder = &paramOverrideAuditRecorder{}
 		overrideCtx[paramOverrideContextAuditRecorder] = recorder
 	}
@@ -244,6 +249,9 @@ func (r *paramOverrideAuditRecorder) recordOperation(mode, path, from, to string
 	if r == nil {
 		return
 	}
+	if !r.all && !shouldAuditOperation(mode, path, from, to) {
+		return
+	}
 	line := buildParamOverrideAuditLine(mode, path, from, to, value)
 	if line == "" {
 		return
@@ -299,10 +307,6 @@ func buildParamOverrideAuditLine(mode, path, from, to string, value interface{})
 	from = strings.TrimSpace(from)
 	to = strings.TrimSpace(to)
 
-	if !shouldAuditOperation(mode, path, from, to) {
-		return ""
-	}
-
 	switch mode {
 	case "set":
 		if path == "" {
@@ -724,12 +728,17 @@ func escapeSjsonLiteralKey(key string) string {
 // 直接读写 []byte，每个操作只会产生一份新 buffer。
 func applyOperations(jsonData []byte, operations []ParamOperation, conditionContext map[string]interface{}) ([]byte, error) {
 	context := ensureContextMap(conditionContext)
-	auditRecorder := getParamOverrideAuditRecorder(context)
 	contextJSON, err := marshalContextJSON(context)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal condition context: %v", err)
 	}
+	return applyOperationsWithContextJSON(jsonData, operations, context, contextJSON)
+}
 
+// applyOperationsWithContextJSON 与 applyOperations 相同，但复用已序列化的上下文，
+// 供逐个 SSE 事件执行的响应覆盖避免重复序列化
+func applyOperationsWithContextJSON(jsonData []byte, operations []ParamOperation, context map[string]interface{}, contextJSON string) ([]byte, error) {
+	auditRecorder := getParamOverrideAuditRecorder(context)
 	result := jsonData
 	for _, op := range operations {
 		// 检查条件是否满足
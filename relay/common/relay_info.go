@@ -148,6 +148,8 @@ type RelayInfo struct {
 	RuntimeHeadersOverride                map[string]interface{}
 	UseRuntimeHeadersOverride             bool
 	ParamOverrideAudit                    []string
+	// DryRun 非空时为预演请求：流程执行到发往上游之前，最终请求记录在此而不发送
+	DryRun *DryRunCapture
 
 	PriceData hosttypes.PriceData
 
@@ -172,6 +174,13 @@ type RelayInfo struct {
 	FinalRequestRelayFormat types.RelayFormat
 
 	StreamStatus *StreamStatus
+	// FirstTokenDeadline is when the first-token timeout of the current
+	// attempt expires. It is set when the request is dispatched upstream, so
+	// the time spent waiting for response headers counts; zero when disabled.
+	FirstTokenDeadline time.Time
+	// StreamContinuation is non-nil once a stream has been interrupted after
+	// the first token and is being continued on another channel.
+	StreamContinuation *StreamContinuation
 
 	// convOptions caches the converter settings snapshot (see ConvOptions).
 	convOptions *convmeta.Options
@@ -838,6 +847,7 @@ type TaskSubmitReq struct {
 	Duration       int                    `json:"duration,omitempty"`
 	Seconds        string                 `json:"seconds,omitempty"`
 	InputReference string                 `json:"input_reference,omitempty"`
+	CallbackURL    string                 `json:"callback_url,omitempty"` // 任务结束后推送结果的客户端地址，由本站发送而非上游
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -0,0 +1,262 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 响应覆盖使用与 param override 相同的 operations 结构改写上游响应：
+// 非流式响应与错误响应整体处理，流式响应逐个 SSE 事件（data 行）处理。
+// 除只作用于 JSON 的操作外，另支持两种仅用于响应的操作：
+//   - drop：条件满足时丢弃当前 SSE 事件；必须以 is_stream 为 true 作为必要条件，只作用于成功的流式响应
+//   - set_from_context：将上下文中 from 指定的值写入 path，例如把 model 改回 original_model
+//
+// 条件除读取响应体外，还可读取 BuildParamOverrideContext 提供的上下文，
+// 以及 response_status_code、is_stream 两个响应字段。
+//
+// 覆盖在各渠道解析用量之前执行，因此规则不能改写用量字段：校验时拒绝路径中含 usage 的操作，
+// 执行时若通配符等间接改动了用量则恢复上游原值，带用量的事件也不会被 drop。
+const (
+	responseOverrideModeDrop           = "drop"
+	responseOverrideModeSetFromContext = "set_from_context"
+)
+
+// responseOverrideUsageKeys 是各上游格式中用量对象的字段名，路径中出现这些字段的操作会被拒绝
+var responseOverrideUsageKeys = map[string]bool{
+	"usage":         true,
+	"usageMetadata": true,
+}
+
+// responseOverrideUsagePaths 是 OpenAI、Claude、Responses 与 Gemini 响应中用量对象的位置
+var responseOverrideUsagePaths = []string{"usage", "message.usage", "response.usage", "usageMetadata"}
+
+var responseOverrideBodyModes = map[string]bool{
+	"delete":        true,
+	"set":           true,
+	"move":          true,
+	"copy":          true,
+	"prepend":       true,
+	"append":        true,
+	"trim_prefix":   true,
+	"trim_suffix":   true,
+	"ensure_prefix": true,
+	"ensure_suffix": true,
+	"trim_space":    true,
+	"to_lower":      true,
+	"to_upper":      true,
+	"replace":       true,
+	"regex_replace": true,
+	"prune_objects": true,
+}
+
+// ResponseOverride 是解析后的渠道响应覆盖规则，按请求创建一次，可重复用于每个 SSE 事件
+type ResponseOverride struct {
+	operations  []ParamOperation
+	context     map[string]interface{}
+	contextJSON string
+	// streamEvents 表示逐个处理成功流式响应的 SSE 事件，只有此时 drop 才会生效
+	streamEvents bool
+}
+
+// ValidateResponseOverride 校验渠道设置中的 response_override，仅接受 operations 格式
+func ValidateResponseOverride(override map[string]interface{}) error {
+	if len(override) == 0 {
+		return nil
+	}
+	if len(buildLegacyParamOverride(override)) > 0 {
+		return fmt.Errorf("response_override only supports operations")
+	}
+	operations, ok := tryParseOperations(override)
+	if !ok {
+		return fmt.Errorf("response_override operations are invalid")
+	}
+	for i, op := range operations {
+		switch {
+		case responseOverrideBodyModes[op.Mode]:
+			if touchesResponseUsage(op.Path) || touchesResponseUsage(op.From) || touchesResponseUsage(op.To) {
+				return fmt.Errorf("response_override operation %d: usage fields cannot be overridden", i)
+			}
+		case op.Mode == responseOverrideModeDrop:
+			if !isStreamOnlyOperation(op) {
+				return fmt.Errorf("response_override operation %d: drop requires an is_stream = true condition", i)
+			}
+		case op.Mode == responseOverrideModeSetFromContext:
+			if strings.TrimSpace(op.Path) == "" || strings.TrimSpace(op.From) == "" {
+				return fmt.Errorf("response_override operation %d: set_from_context requires path and from", i)
+			}
+			if touchesResponseUsage(op.Path) {
+				return fmt.Errorf("response_override operation %d: usage fields cannot be overridden", i)
+			}
+		default:
+			return fmt.Errorf("response_override operation %d: unsupported mode %q", i, op.Mode)
+		}
+	}
+	return nil
+}
+
+// touchesResponseUsage 判断路径是否指向用量对象或其中的字段
+func touchesResponseUsage(path string) bool {
+	for _, segment := range strings.Split(path, ".") {
+		if responseOverrideUsageKeys[strings.TrimSpace(segment)] {
+			return true
+		}
+	}
+	return false
+}
+
+// isStreamOnlyOperation 判断操作的条件是否要求 is_stream 为 true，
+// 保证 drop 不会作用于非流式响应体
+func isStreamOnlyOperation(op ParamOperation) bool {
+	if len(op.Conditions) > 1 && strings.ToUpper(op.Logic) != "AND" {
+		return false
+	}
+	for _, condition := range op.Conditions {
+		if strings.TrimSpace(condition.Path) != "is_stream" || condition.Invert || condition.PassMissingKey {
+			continue
+		}
+		if value, ok := condition.Value.(bool); ok && value && (condition.Mode == "" || condition.Mode == "full") {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreResponseUsage 把被间接改动的用量对象恢复为上游原值，保证计费读取到的用量不受覆盖影响
+func restoreResponseUsage(original []byte, result []byte) ([]byte, error) {
+	var err error
+	for _, path := range responseOverrideUsagePaths {
+		before := gjson.GetBytes(original, path)
+		after := gjson.GetBytes(result, path)
+		if before.Raw == after.Raw {
+			continue
+		}
+		if before.Exists() {
+			result, err = sjson.SetRawBytes(result, path, []byte(before.Raw))
+		} else {
+			result, err = sjson.DeleteBytes(result, path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+	return result, nil
+}
+
+// ResponseUsageEqual 判断两个响应体或 SSE 事件中的用量对象是否一致，
+// 用于确认在用量解析之前运行的改写没有改动计费用量
+func ResponseUsageEqual(original []byte, result []byte) bool {
+	for _, path := range responseOverrideUsagePaths {
+		if gjson.GetBytes(original, path).Raw != gjson.GetBytes(result, path).Raw {
+			return false
+		}
+	}
+	return true
+}
+
+// carriesResponseUsage 判断事件是否带有用量，这类事件即使满足 drop 条件也要保留
+func carriesResponseUsage(data []byte) bool {
+	for _, path := range responseOverrideUsagePaths {
+		if gjson.GetBytes(data, path).Exists() {
+			return true
+		}
+	}
+	return false
+}
+
+// NewResponseOverride 按渠道设置创建响应覆盖，未配置时返回 nil。
+// statusCode 为上游响应状态码，可在条件中通过 response_status_code 匹配错误响应
+func NewResponseOverride(info *RelayInfo, statusCode int) (*ResponseOverride, error) {
+	if info == nil || info.ChannelMeta == nil || len(info.ChannelOtherSettings.ResponseOverride) == 0 {
+		return nil, nil
+	}
+	if err := ValidateResponseOverride(info.ChannelOtherSettings.ResponseOverride); err != nil {
+		return nil, err
+	}
+	operations, _ := tryParseOperations(info.ChannelOtherSettings.ResponseOverride)
+	context := ensureContextMap(BuildParamOverrideContext(info))
+	context["response_status_code"] = statusCode
+	context["is_stream"] = info.IsStream
+	contextJSON, err := marshalContextJSON(context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal condition context: %v", err)
+	}
+	return &ResponseOverride{
+		operations:   operations,
+		context:      context,
+		contextJSON:  contextJSON,
+		streamEvents: info.IsStream && statusCode == http.StatusOK,
+	}, nil
+}
+
+// Apply 改写一个响应体或 SSE 事件。drop 为 true 时调用方应丢弃该事件；
+// 非 JSON 数据（如 [DONE]、二进制响应）原样返回，用量对象始终保持上游原值
+func (o *ResponseOverride) Apply(data []byte) (result []byte, drop bool, err error) {
+	if o == nil || !gjson.ValidBytes(data) {
+		return data, false, nil
+	}
+	result = data
+	pending := make([]ParamOperation, 0, len(o.operations))
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		next, err := applyOperationsWithContextJSON(result, pending, o.context, o.contextJSON)
+		if err != nil {
+			return err
+		}
+		result = next
+		pending = pending[:0]
+		return nil
+	}
+
+	for _, op := range o.operations {
+		if responseOverrideBodyModes[op.Mode] {
+			pending = append(pending, op)
+			continue
+		}
+		if err := flush(); err != nil {
+			return data, false, err
+		}
+		ok, err := checkConditions(result, o.contextJSON, op.Conditions, op.Logic)
+		if err != nil {
+			return data, false, err
+		}
+		if !ok {
+			continue
+		}
+		switch op.Mode {
+		case responseOverrideModeDrop:
+			if o.streamEvents && !carriesResponseUsage(data) {
+				return nil, true, nil
+			}
+		case responseOverrideModeSetFromContext:
+			value := gjson.Get(o.contextJSON, op.From)
+			if !value.Exists() {
+				continue
+			}
+			paths, err := resolveOperationPaths(result, processNegativeIndex(result, op.Path))
+			if err != nil {
+				return data, false, err
+			}
+			for _, path := range paths {
+				if op.KeepOrigin && gjson.GetBytes(result, path).Exists() {
+					continue
+				}
+				if result, err = sjson.SetBytes(result, path, value.Value()); err != nil {
+					return data, false, fmt.Errorf("operation %s failed: %w", op.Mode, err)
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return data, false, err
+	}
+	if result, err = restoreResponseUsage(data, result); err != nil {
+		return data, false, err
+	}
+	return result, false, nil
+}
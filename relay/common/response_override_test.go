@@ -0,0 +1,137 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	common2 "github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newResponseOverrideTestInfo(t *testing.T, override string) *RelayInfo {
+	t.Helper()
+	var overrideMap map[string]any
+	require.NoError(t, common2.UnmarshalJsonStr(override, &overrideMap))
+	return &RelayInfo{
+		OriginModelName: "gpt-4o",
+		IsStream:        true,
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName:    "vendor/gpt-4o-2024",
+			ChannelOtherSettings: dto.ChannelOtherSettings{ResponseOverride: overrideMap},
+		},
+	}
+}
+
+func TestResponseOverrideRewritesStreamEvents(t *testing.T) {
+	// Restore the requested model name, fix a broken finish_reason and drop vendor events
+	info := newResponseOverrideTestInfo(t, `{"operations":[
+		{"mode":"drop","logic":"AND","conditions":[{"path":"is_stream","mode":"full","value":true},{"path":"object","mode":"full","value":"vendor.heartbeat"}]},
+		{"path":"model","mode":"set_from_context","from":"original_model"},
+		{"path":"choices.*.finish_reason","mode":"set","value":"stop","conditions":[{"path":"choices.0.finish_reason","mode":"full","value":"eos"}]},
+		{"path":"vendor_trace","mode":"delete"}
+	]}`)
+	override, err := NewResponseOverride(info, http.StatusOK)
+	require.NoError(t, err)
+	require.NotNil(t, override)
+
+	out, drop, err := override.Apply([]byte(`{"object":"chat.completion.chunk","model":"vendor/gpt-4o-2024","vendor_trace":"x","choices":[{"index":0,"finish_reason":"eos"}]}`))
+	require.NoError(t, err)
+	require.False(t, drop)
+	assertJSONEqual(t, `{"object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"finish_reason":"stop"}]}`, string(out))
+
+	_, drop, err = override.Apply([]byte(`{"object":"vendor.heartbeat"}`))
+	require.NoError(t, err)
+	require.True(t, drop)
+
+	out, drop, err = override.Apply([]byte(`[DONE]`))
+	require.NoError(t, err)
+	require.False(t, drop)
+	require.Equal(t, `[DONE]`, string(out))
+}
+
+func TestResponseOverrideMapsErrorBodies(t *testing.T) {
+	info := newResponseOverrideTestInfo(t, `{"operations":[
+		{"mode":"move","from":"msg","to":"error.message","conditions":[{"path":"response_status_code","mode":"gte","value":400}]},
+		{"path":"error.type","mode":"set","value":"upstream_error","conditions":[{"path":"response_status_code","mode":"gte","value":400}]}
+	]}`)
+
+	override, err := NewResponseOverride(info, http.StatusTooManyRequests)
+	require.NoError(t, err)
+	out, _, err := override.Apply([]byte(`{"msg":"slow down"}`))
+	require.NoError(t, err)
+	assertJSONEqual(t, `{"error":{"message":"slow down","type":"upstream_error"}}`, string(out))
+
+	override, err = NewResponseOverride(info, http.StatusOK)
+	require.NoError(t, err)
+	out, _, err = override.Apply([]byte(`{"msg":"ok"}`))
+	require.NoError(t, err)
+	assertJSONEqual(t, `{"msg":"ok"}`, string(out))
+}
+
+func TestResponseOverrideNotConfigured(t *testing.T) {
+	override, err := NewResponseOverride(&RelayInfo{ChannelMeta: &ChannelMeta{}}, http.StatusOK)
+	require.NoError(t, err)
+	require.Nil(t, override)
+
+	out, drop, err := override.Apply([]byte(`{"a":1}`))
+	require.NoError(t, err)
+	require.False(t, drop)
+	require.Equal(t, `{"a":1}`, string(out))
+}
+
+func TestValidateResponseOverride(t *testing.T) {
+	valid := map[string]any{"operations": []any{
+		map[string]any{"path": "model", "mode": "set_from_context", "from": "original_model"},
+		map[string]any{"mode": "drop", "conditions": []any{map[string]any{"path": "is_stream", "mode": "full", "value": true}}},
+	}}
+	require.NoError(t, ValidateResponseOverride(valid))
+	require.NoError(t, ValidateResponseOverride(nil))
+
+	for name, override := range map[string]map[string]any{
+		"legacy":             {"model": "gpt-4o"},
+		"header mode":        {"operations": []any{map[string]any{"path": "X-Test", "mode": "set_header", "value": "1"}}},
+		"return error":       {"operations": []any{map[string]any{"mode": "return_error", "value": "blocked"}}},
+		"missing from":       {"operations": []any{map[string]any{"path": "model", "mode": "set_from_context"}}},
+		"missing operations": {"operations": "drop"},
+		"unguarded drop":     {"operations": []any{map[string]any{"mode": "drop"}}},
+		"drop with or logic": {"operations": []any{map[string]any{"mode": "drop", "conditions": []any{
+			map[string]any{"path": "is_stream", "mode": "full", "value": true},
+			map[string]any{"path": "object", "mode": "full", "value": "x"},
+		}}}},
+		"set usage":        {"operations": []any{map[string]any{"path": "usage.total_tokens", "mode": "set", "value": 0}}},
+		"move usage":       {"operations": []any{map[string]any{"mode": "move", "from": "usage", "to": "vendor_usage"}}},
+		"gemini usage":     {"operations": []any{map[string]any{"path": "usageMetadata", "mode": "delete"}}},
+		"usage to context": {"operations": []any{map[string]any{"path": "message.usage", "mode": "set_from_context", "from": "original_model"}}},
+	} {
+		require.Error(t, ValidateResponseOverride(override), name)
+	}
+}
+
+func TestResponseOverrideKeepsUpstreamUsage(t *testing.T) {
+	// Wildcards pass validation but must not change the usage billing reads
+	info := newResponseOverrideTestInfo(t, `{"operations":[
+		{"path":"*.completion_tokens","mode":"set","value":0},
+		{"mode":"drop","conditions":[{"path":"is_stream","mode":"full","value":true}]}
+	]}`)
+	override, err := NewResponseOverride(info, http.StatusOK)
+	require.NoError(t, err)
+
+	out, drop, err := override.Apply([]byte(`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":7}}`))
+	require.NoError(t, err)
+	require.False(t, drop, "events carrying usage are never dropped")
+	assertJSONEqual(t, `{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":7}}`, string(out))
+
+	_, drop, err = override.Apply([]byte(`{"choices":[{"index":0}]}`))
+	require.NoError(t, err)
+	require.True(t, drop)
+
+	// Whole bodies are never dropped, even for stream requests that failed
+	override, err = NewResponseOverride(info, http.StatusBadRequest)
+	require.NoError(t, err)
+	out, drop, err = override.Apply([]byte(`{"error":"bad"}`))
+	require.NoError(t, err)
+	require.False(t, drop)
+	assertJSONEqual(t, `{"error":"bad"}`, string(out))
+}
@@ -0,0 +1,36 @@
+package common
+
+import "strings"
+
+// StreamContinuation carries the assistant output already streamed to the
+// client when an upstream stream dies mid-way, so the next attempt can be
+// issued with that output as prefill and spliced into the same SSE stream.
+type StreamContinuation struct {
+	// PartialText is the accumulated assistant text of all interrupted attempts.
+	PartialText strings.Builder
+	// PartialCompletionTokens is the completion usage already produced by the
+	// interrupted attempts; it is added to the usage of the final attempt.
+	PartialCompletionTokens int
+	// Count is the number of continuations issued so far.
+	Count int
+}
+
+// Active reports whether the current attempt is continuing an interrupted stream.
+func (s *StreamContinuation) Active() bool {
+	return s != nil && s.PartialText.Len() > 0
+}
+
+// Prefill returns the assistant text to append as prefill for the next attempt.
+func (s *StreamContinuation) Prefill() string {
+	if s == nil {
+		return ""
+	}
+	return s.PartialText.String()
+}
+
+// Record appends the output of an interrupted attempt.
+func (s *StreamContinuation) Record(text string, completionTokens int) {
+	s.PartialText.WriteString(text)
+	s.PartialCompletionTokens += completionTokens
+	s.Count++
+}
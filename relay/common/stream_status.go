@@ -19,6 +19,9 @@ const (
 	StreamEndReasonEOF         StreamEndReason = "eof"
 	StreamEndReasonPanic       StreamEndReason = "panic"
 	StreamEndReasonPingFail    StreamEndReason = "ping_fail"
+	// StreamEndReasonFirstTokenTimeout marks a stream aborted before any data
+	// reached the client, so the request can be retried on another channel.
+	StreamEndReasonFirstTokenTimeout StreamEndReason = "first_token_timeout"
 )
 
 const maxStreamErrorEntries = 20
@@ -94,6 +97,17 @@ func (s *StreamStatus) IsNormalEnd() bool {
 		s.EndReason == StreamEndReasonHandlerStop
 }
 
+// IsInterrupted reports whether the upstream stream broke off mid-way
+// (idle timeout or read error) rather than finishing or being abandoned by
+// the client.
+func (s *StreamStatus) IsInterrupted() bool {
+	if s == nil {
+		return false
+	}
+	return s.EndReason == StreamEndReasonTimeout ||
+		s.EndReason == StreamEndReasonScannerErr
+}
+
 func (s *StreamStatus) Summary() string {
 	if s == nil {
 		return "StreamStatus<nil>"
@@ -0,0 +1,42 @@
+package relay
+
+import (
+	"errors"
+	"io"
+
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrDryRunCaptured 预演请求在发往上游之前结束时返回，最终请求记录在 info.DryRun 中
+var ErrDryRunCaptured = errors.New("dry run: upstream request captured, not sent")
+
+// doUpstreamRequest 发送上游请求。预演请求只按通用方式构建最终请求并记录，
+// 不经过 adaptor.DoRequest，以免 SDK 类渠道绕过拦截直接发出请求
+func doUpstreamRequest(c *gin.Context, adaptor channel.Adaptor, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	if info.DryRun == nil {
+		return adaptor.DoRequest(c, info, requestBody)
+	}
+	req, err := channel.NewApiRequest(adaptor, c, info, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	*info.DryRun = relaycommon.DryRunCapture{
+		Captured: true,
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Header:   req.Header.Clone(),
+		Body:     body,
+	}
+	return nil, ErrDryRunCaptured
+}
@@ -70,6 +70,10 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	if newAPIError := applyPreConversionPlugins(c, info, request); newAPIError != nil {
+		return newAPIError
+	}
+
 	if model_setting.GetGeminiSettings().ThinkingAdapterEnabled {
 		if isNoThinkingRequest(request) {
 			// check is thinking
@@ -162,6 +166,11 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 			}
 		}
 
+		jsonData, newAPIError = applyPostConversionPlugins(c, info, jsonData)
+		if newAPIError != nil {
+			return newAPIError
+		}
+
 		logger.LogDebug(c, "Gemini request body: %s", jsonData)
 
 		body, closer, err := relaycommon.NewOutboundJSONBody(jsonData)
@@ -173,7 +182,7 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		requestBody = body
 	}
 
-	resp, err := adaptor.DoRequest(c, info, requestBody)
+	resp, err := doUpstreamRequest(c, adaptor, info, requestBody)
 	if err != nil {
 		logger.LogError(c, "Do gemini request failed: "+err.Error())
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
@@ -185,12 +194,14 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 	if resp != nil {
 		httpResp = resp.(*http.Response)
 		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		applyResponseOverride(c, info, httpResp)
 		if httpResp.StatusCode != http.StatusOK {
 			newAPIError = service.RelayErrorHandler(c.Request.Context(), httpResp, false)
 			// reset status code 重置状态码
 			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 			return newAPIError
 		}
+		applyResponsePlugins(c, info, httpResp)
 	}
 
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
@@ -267,6 +278,10 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 			return newAPIErrorFromParamOverride(err)
 		}
 	}
+	jsonData, newAPIError = applyPostConversionPlugins(c, info, jsonData)
+	if newAPIError != nil {
+		return newAPIError
+	}
 	logger.LogDebug(c, "Gemini embedding request body: %s", jsonData)
 	body, closer, err := relaycommon.NewOutboundJSONBody(jsonData)
 	if err != nil {
@@ -276,7 +291,7 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 	jsonData = nil
 	requestBody = body
 
-	resp, err := adaptor.DoRequest(c, info, requestBody)
+	resp, err := doUpstreamRequest(c, adaptor, info, requestBody)
 	if err != nil {
 		logger.LogError(c, "Do gemini request failed: "+err.Error())
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
@@ -286,11 +301,13 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 	var httpResp *http.Response
 	if resp != nil {
 		httpResp = resp.(*http.Response)
+		applyResponseOverride(c, info, httpResp)
 		if httpResp.StatusCode != http.StatusOK {
 			newAPIError = service.RelayErrorHandler(c.Request.Context(), httpResp, false)
 			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 			return newAPIError
 		}
+		applyResponsePlugins(c, info, httpResp)
 	}
 
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
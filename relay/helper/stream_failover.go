@@ -0,0 +1,229 @@
+package helper
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFirstTokenTimeout returns the first-token timeout for this stream, or
+// 0 when failover is impossible because bytes were already sent or the relay
+// mode streams non-text payloads.
+func streamFirstTokenTimeout(c *gin.Context, info *relaycommon.RelayInfo) time.Duration {
+	if c == nil || c.Writer == nil || c.Writer.Written() {
+		return 0
+	}
+	switch info.RelayMode {
+	case relayconstant.RelayModeAudioSpeech,
+		relayconstant.RelayModeImagesGenerations,
+		relayconstant.RelayModeImagesEdits:
+		return 0
+	}
+	// 没有剩余的重试次数时，超时中断只会让请求失败，不如继续等待上游
+	if !streamRetryAvailable(c, info) {
+		return 0
+	}
+	return operation_setting.GetStreamFailoverSetting().FirstTokenTimeout()
+}
+
+// BeginStreamFirstTokenTimeout arms the first-token timeout when a stream
+// request is dispatched upstream and returns its duration, or 0 when it does
+// not apply. The caller must give up waiting for response headers once it
+// elapses; StreamScannerHandler waits for the remaining time only.
+func BeginStreamFirstTokenTimeout(c *gin.Context, info *relaycommon.RelayInfo) time.Duration {
+	if info == nil {
+		return 0
+	}
+	info.FirstTokenDeadline = time.Time{}
+	if !info.IsStream {
+		return 0
+	}
+	timeout := streamFirstTokenTimeout(c, info)
+	if timeout > 0 {
+		info.FirstTokenDeadline = time.Now().Add(timeout)
+	}
+	return timeout
+}
+
+// streamFirstTokenWait returns how long StreamScannerHandler may still wait
+// for the first token. Streams dispatched through BeginStreamFirstTokenTimeout
+// wait until its deadline; other callers start the timer here.
+func streamFirstTokenWait(c *gin.Context, info *relaycommon.RelayInfo) time.Duration {
+	timeout := streamFirstTokenTimeout(c, info)
+	if timeout <= 0 || info.FirstTokenDeadline.IsZero() {
+		return timeout
+	}
+	// 截止时间已过也要返回正数，让计时器立即触发而不是关闭超时
+	return max(time.Until(info.FirstTokenDeadline), time.Millisecond)
+}
+
+// NewStreamFirstTokenTimeoutError returns the retryable error for an attempt
+// whose upstream did not even send response headers before the deadline.
+func NewStreamFirstTokenTimeoutError(err error) *types.NewAPIError {
+	return types.NewOpenAIError(fmt.Errorf("stream first token timeout: %w", err), types.ErrorCodeStreamFirstTokenTimeout, http.StatusGatewayTimeout)
+}
+
+// streamRetryAvailable reports whether the relay loop will actually retry a
+// stream failover error on another channel.
+func streamRetryAvailable(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if info == nil || info.RetryIndex >= common.RetryTimes {
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return true
+}
+
+// StreamFirstTokenTimeoutError returns a retryable error when the stream was
+// aborted by the first-token timeout. Stream handlers must check it right
+// after StreamScannerHandler and before writing any trailing data.
+func StreamFirstTokenTimeoutError(info *relaycommon.RelayInfo) *types.NewAPIError {
+	if info == nil || info.StreamStatus == nil ||
+		info.StreamStatus.EndReason != relaycommon.StreamEndReasonFirstTokenTimeout {
+		return nil
+	}
+	err := info.StreamStatus.EndError
+	if err == nil {
+		err = fmt.Errorf("no token received from upstream")
+	}
+	return NewStreamFirstTokenTimeoutError(err)
+}
+
+// IsStreamFailoverError reports whether err was produced by the stream
+// failover logic and may be retried even after the stream started.
+func IsStreamFailoverError(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	code := err.GetErrorCode()
+	return code == types.ErrorCodeStreamFirstTokenTimeout || code == types.ErrorCodeStreamInterrupted
+}
+
+// streamContinuationSupported reports whether the client format supports
+// continuing an interrupted stream on another channel. Only OpenAI chat
+// completions do: the continuation is spliced into the client stream as
+// further chat chunks. Claude, Gemini and Responses streams carry event
+// state (message and content block boundaries, response and item ids) that
+// a second upstream cannot resume, so those streams only fail over before
+// the first token and end with an error when interrupted later.
+func streamContinuationSupported(info *relaycommon.RelayInfo) bool {
+	switch info.RelayFormat {
+	case types.RelayFormatOpenAI:
+		return info.RelayMode == relayconstant.RelayModeChatCompletions
+	default:
+		return false
+	}
+}
+
+// isContinuableStream reports whether the stream is an interrupted OpenAI chat
+// stream covered by the continuation setting.
+func isContinuableStream(info *relaycommon.RelayInfo) bool {
+	if !operation_setting.GetStreamFailoverSetting().ContinuationEnabled || info == nil || !info.IsStream {
+		return false
+	}
+	if !streamContinuationSupported(info) {
+		return false
+	}
+	return info.StreamStatus.IsInterrupted()
+}
+
+// canContinueStream reports whether an interrupted OpenAI chat stream can be
+// re-issued on another channel with its partial output as prefill.
+func canContinueStream(c *gin.Context, info *relaycommon.RelayInfo, partialText string, toolCount int) bool {
+	setting := operation_setting.GetStreamFailoverSetting()
+	if !isContinuableStream(info) || !streamRetryAvailable(c, info) {
+		return false
+	}
+	if partialText == "" || toolCount > 0 {
+		return false
+	}
+	// 透传模式无法改写请求体，无法追加 prefill
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled ||
+		(info.ChannelMeta != nil && info.ChannelSetting.PassThroughBodyEnabled) {
+		return false
+	}
+	if info.StreamContinuation != nil && info.StreamContinuation.Count >= setting.MaxContinuations {
+		return false
+	}
+	return true
+}
+
+// InterruptStreamForContinuation records the partial assistant output of an
+// interrupted chat stream and returns a retryable error so the relay loop
+// continues the completion on another channel. It returns nil when the
+// stream ended normally or continuation is not applicable.
+func InterruptStreamForContinuation(c *gin.Context, info *relaycommon.RelayInfo, partialText string, toolCount int) *types.NewAPIError {
+	if !canContinueStream(c, info, partialText, toolCount) {
+		return nil
+	}
+	if info.StreamContinuation == nil {
+		info.StreamContinuation = &relaycommon.StreamContinuation{}
+	}
+	completionTokens := service.ResponseText2Usage(c, partialText, info.UpstreamModelName, 0).CompletionTokens
+	info.StreamContinuation.Record(partialText, completionTokens)
+	logger.LogWarn(c, fmt.Sprintf("stream interrupted after %d chars (%s), continuing on another channel", len(partialText), info.StreamStatus.Summary()))
+	return types.NewOpenAIError(fmt.Errorf("upstream stream interrupted: %s", info.StreamStatus.Summary()), types.ErrorCodeStreamInterrupted, http.StatusBadGateway)
+}
+
+// StreamInterruptedErrorData ends an interrupted chat stream that could not be
+// continued with an SSE error event, so the client does not mistake the
+// partial output for a complete answer. The caller still bills the partial
+// usage and sends [DONE] afterwards.
+func StreamInterruptedErrorData(c *gin.Context, info *relaycommon.RelayInfo) {
+	if !isContinuableStream(info) {
+		return
+	}
+	err := types.NewOpenAIError(fmt.Errorf("upstream stream interrupted: %s", info.StreamStatus.Summary()), types.ErrorCodeStreamInterrupted, http.StatusBadGateway)
+	_ = ObjectData(c, gin.H{"error": err.ToOpenAIError()})
+}
+
+// SettleInterruptedStream bills the output already streamed to the client
+// when every continuation attempt failed, instead of refunding the whole
+// pre-consumed quota.
+func SettleInterruptedStream(c *gin.Context, info *relaycommon.RelayInfo) {
+	if info == nil || !info.StreamContinuation.Active() {
+		return
+	}
+	promptTokens := info.GetEstimatePromptTokens()
+	completionTokens := info.StreamContinuation.PartialCompletionTokens
+	service.PostTextConsumeQuota(c, info, &dto.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}, nil)
+}
+
+// ApplyStreamContinuationPrefill appends the output already streamed to the
+// client as a trailing assistant message so the upstream continues from it.
+func ApplyStreamContinuationPrefill(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) {
+	if info == nil || request == nil || !info.StreamContinuation.Active() || !streamContinuationSupported(info) {
+		return
+	}
+	request.Messages = append(request.Messages, dto.Message{
+		Role:    "assistant",
+		Content: info.StreamContinuation.Prefill(),
+	})
+}
+
+// MergeStreamContinuationUsage adds the completion tokens produced by
+// interrupted attempts to the usage of the attempt that finished the stream.
+func MergeStreamContinuationUsage(info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if info == nil || usage == nil || !info.StreamContinuation.Active() {
+		return
+	}
+	usage.CompletionTokens += info.StreamContinuation.PartialCompletionTokens
+	usage.TotalTokens += info.StreamContinuation.PartialCompletionTokens
+}
@@ -0,0 +1,160 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withRetryTimes(t *testing.T, retryTimes int) {
+	t.Helper()
+	old := common.RetryTimes
+	common.RetryTimes = retryTimes
+	t.Cleanup(func() { common.RetryTimes = old })
+}
+
+func TestInterruptStreamForContinuation(t *testing.T) {
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldEnabled := setting.ContinuationEnabled
+	oldMax := setting.MaxContinuations
+	setting.ContinuationEnabled = true
+	setting.MaxContinuations = 1
+	t.Cleanup(func() {
+		setting.ContinuationEnabled = oldEnabled
+		setting.MaxContinuations = oldMax
+	})
+	withRetryTimes(t, 2)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	info := &relaycommon.RelayInfo{
+		IsStream:     true,
+		RelayFormat:  types.RelayFormatOpenAI,
+		RelayMode:    relayconstant.RelayModeChatCompletions,
+		StreamStatus: relaycommon.NewStreamStatus(),
+		ChannelMeta:  &relaycommon.ChannelMeta{UpstreamModelName: "gpt-4o"},
+	}
+	info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonTimeout, nil)
+
+	newAPIError := InterruptStreamForContinuation(c, info, "Hello, wor", 0)
+	require.NotNil(t, newAPIError)
+	assert.True(t, IsStreamFailoverError(newAPIError))
+	require.True(t, info.StreamContinuation.Active())
+	assert.Equal(t, 1, info.StreamContinuation.Count)
+
+	request := &dto.GeneralOpenAIRequest{Messages: []dto.Message{{Role: "user", Content: "hi"}}}
+	ApplyStreamContinuationPrefill(info, request)
+	require.Len(t, request.Messages, 2)
+	assert.Equal(t, "assistant", request.Messages[1].Role)
+	assert.Equal(t, "Hello, wor", request.Messages[1].StringContent())
+
+	usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	MergeStreamContinuationUsage(info, usage)
+	assert.Equal(t, 5+info.StreamContinuation.PartialCompletionTokens, usage.CompletionTokens)
+
+	// 达到续写次数上限后不再续写
+	assert.Nil(t, InterruptStreamForContinuation(c, info, "ld", 0))
+}
+
+func TestInterruptStreamForContinuation_NotApplicable(t *testing.T) {
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldEnabled := setting.ContinuationEnabled
+	setting.ContinuationEnabled = true
+	t.Cleanup(func() { setting.ContinuationEnabled = oldEnabled })
+	withRetryTimes(t, 2)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	info := &relaycommon.RelayInfo{
+		IsStream:     true,
+		RelayFormat:  types.RelayFormatOpenAI,
+		RelayMode:    relayconstant.RelayModeChatCompletions,
+		StreamStatus: relaycommon.NewStreamStatus(),
+		ChannelMeta:  &relaycommon.ChannelMeta{},
+	}
+	info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonDone, nil)
+	assert.Nil(t, InterruptStreamForContinuation(c, info, "complete", 0))
+
+	info.StreamStatus = relaycommon.NewStreamStatus()
+	info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonScannerErr, nil)
+	assert.Nil(t, InterruptStreamForContinuation(c, info, "partial", 1), "tool calls cannot be continued")
+	assert.Nil(t, info.StreamContinuation)
+}
+
+func TestInterruptStreamForContinuation_NoRetryLeft(t *testing.T) {
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldEnabled := setting.ContinuationEnabled
+	setting.ContinuationEnabled = true
+	t.Cleanup(func() { setting.ContinuationEnabled = oldEnabled })
+	withRetryTimes(t, 1)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	info := &relaycommon.RelayInfo{
+		IsStream:     true,
+		RelayFormat:  types.RelayFormatOpenAI,
+		RelayMode:    relayconstant.RelayModeChatCompletions,
+		StreamStatus: relaycommon.NewStreamStatus(),
+		ChannelMeta:  &relaycommon.ChannelMeta{},
+		RetryIndex:   1,
+	}
+	info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonTimeout, nil)
+
+	// 最后一次尝试中断时不再续写，而是以 SSE 错误事件结束流
+	assert.Nil(t, InterruptStreamForContinuation(c, info, "partial", 0))
+	assert.Nil(t, info.StreamContinuation)
+
+	StreamInterruptedErrorData(c, info)
+	assert.Contains(t, recorder.Body.String(), "data: {\"error\":")
+	assert.Contains(t, recorder.Body.String(), string(types.ErrorCodeStreamInterrupted))
+
+	// 指定渠道的请求不会重试
+	info.RetryIndex = 0
+	c.Set("specific_channel_id", "1")
+	assert.Nil(t, InterruptStreamForContinuation(c, info, "partial", 0))
+}
+
+func TestStreamContinuationOnlyForOpenAIChat(t *testing.T) {
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldEnabled := setting.ContinuationEnabled
+	setting.ContinuationEnabled = true
+	t.Cleanup(func() { setting.ContinuationEnabled = oldEnabled })
+	withRetryTimes(t, 2)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+
+	for _, format := range []types.RelayFormat{types.RelayFormatClaude, types.RelayFormatGemini, types.RelayFormatOpenAIResponses} {
+		info := &relaycommon.RelayInfo{
+			IsStream:     true,
+			RelayFormat:  format,
+			RelayMode:    relayconstant.RelayModeChatCompletions,
+			StreamStatus: relaycommon.NewStreamStatus(),
+			ChannelMeta:  &relaycommon.ChannelMeta{},
+		}
+		info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonTimeout, nil)
+		assert.Nil(t, InterruptStreamForContinuation(c, info, "partial", 0), format)
+		assert.Nil(t, info.StreamContinuation, format)
+
+		// A continuation recorded elsewhere is never applied to another format
+		info.StreamContinuation = &relaycommon.StreamContinuation{}
+		info.StreamContinuation.Record("partial", 1)
+		request := &dto.GeneralOpenAIRequest{Messages: []dto.Message{{Role: "user", Content: "hi"}}}
+		ApplyStreamContinuationPrefill(info, request)
+		assert.Len(t, request.Messages, 1, format)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/wasmplugin"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -96,6 +97,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		wg          sync.WaitGroup // 用于等待所有 goroutine 退出
 		cleanupOnce sync.Once
 		stopOnce    sync.Once
+		// firstTokenDelivered / firstTokenExpired are guarded by writeMutex so the
+		// first-token timeout can never race with the first write to the client.
+		firstTokenDelivered bool
+		firstTokenExpired   bool
 	)
 
 	stop := func() {
@@ -115,11 +120,22 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		pingTicker = time.NewTicker(pingInterval)
 	}
 
+	// 首 token 超时：在任何字节写给客户端之前触发时，允许上层换渠道重试。
+	// 计时从请求发出时开始，这里只等待剩余的时间
+	var firstTokenTimeoutC <-chan time.Time
+	firstTokenTimeout := streamFirstTokenWait(c, info)
+	if firstTokenTimeout > 0 {
+		firstTokenTimer := time.NewTimer(firstTokenTimeout)
+		defer firstTokenTimer.Stop()
+		firstTokenTimeoutC = firstTokenTimer.C
+	}
+
 	logger.LogDebug(c, "relay timeout seconds: %d", common.RelayTimeout)
 	logger.LogDebug(c, "relay max idle conns: %d", common.RelayMaxIdleConns)
 	logger.LogDebug(c, "relay max idle conns per host: %d", common.RelayMaxIdleConnsPerHost)
 	logger.LogDebug(c, "streaming timeout seconds: %d", int64(streamingTimeout.Seconds()))
 	logger.LogDebug(c, "ping interval seconds: %d", int64(pingInterval.Seconds()))
+	logger.LogDebug(c, "first token timeout seconds: %d", int64(firstTokenTimeout.Seconds()))
 
 	cleanup := func() {
 		cleanupOnce.Do(func() {
@@ -172,6 +188,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 					func() {
 						writeMutex.Lock()
 						defer writeMutex.Unlock()
+						// 首 token 到达前不发送 ping，保证超时后仍可透明重试
+						if firstTokenTimeout > 0 && !firstTokenDelivered {
+							return
+						}
 						ExtendWriteDeadline(c)
 						err = PingData(c)
 					}()
@@ -197,6 +217,13 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	}
 
 	dataChan := make(chan string, 10)
+	// 渠道配置了响应覆盖时，每个上游 SSE 事件先按规则改写或丢弃
+	responseOverride, err := relaycommon.NewResponseOverride(info, http.StatusOK)
+	if err != nil {
+		logger.LogError(c, "invalid response override: "+err.Error())
+	}
+	// 绑定了 WASM 插件时，每个上游数据块先交给 response_chunk 钩子处理
+	chunkPlugins := service.HasWasmPlugins(info)
 
 	wg.Add(1)
 	gopool.Go(func() {
@@ -211,13 +238,32 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		sr := newStreamResult(info.StreamStatus)
 		for data := range dataChan {
 			sr.reset()
+			if responseOverride != nil {
+				output, drop, err := responseOverride.Apply([]byte(data))
+				if err != nil {
+					logger.LogError(c, "failed to apply response override: "+err.Error())
+				} else if drop {
+					continue
+				} else {
+					data = string(output)
+				}
+			}
+			if chunkPlugins {
+				data = string(service.RunWasmPluginStreamHook(c, info, wasmplugin.HookResponseChunk, []byte(data)))
+			}
+			expired := false
 			func() {
 				writeMutex.Lock()
 				defer writeMutex.Unlock()
+				if firstTokenExpired {
+					expired = true
+					return
+				}
+				firstTokenDelivered = true
 				ExtendWriteDeadline(c)
 				dataHandler(data, sr)
 			}()
-			if sr.IsStopped() {
+			if expired || sr.IsStopped() {
 				return
 			}
 		}
@@ -290,15 +336,32 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	})
 
 	// 主循环等待完成或超时
-	select {
-	case <-ticker.C:
-		info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonTimeout, nil)
-	case <-stopChan:
-		// EndReason already set by the goroutine that triggered stopChan
-	case <-c.Request.Context().Done():
-		// 客户端断开：立即 cleanup 关闭上游 resp.Body，解除 scanner 阻塞并让上游停止生成，
-		// 避免为已放弃的请求继续消费上游 token。
-		info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonClientGone, c.Request.Context().Err())
+wait:
+	for {
+		select {
+		case <-firstTokenTimeoutC:
+			firstTokenTimeoutC = nil
+			writeMutex.Lock()
+			if !firstTokenDelivered {
+				firstTokenExpired = true
+			}
+			expired := firstTokenExpired
+			writeMutex.Unlock()
+			if !expired {
+				continue
+			}
+			info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonFirstTokenTimeout,
+				fmt.Errorf("no token received within %d seconds", int64(firstTokenTimeout.Seconds())))
+		case <-ticker.C:
+			info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonTimeout, nil)
+		case <-stopChan:
+			// EndReason already set by the goroutine that triggered stopChan
+		case <-c.Request.Context().Done():
+			// 客户端断开：立即 cleanup 关闭上游 resp.Body，解除 scanner 阻塞并让上游停止生成，
+			// 避免为已放弃的请求继续消费上游 token。
+			info.StreamStatus.SetEndReason(relaycommon.StreamEndReasonClientGone, c.Request.Context().Err())
+		}
+		break wait
 	}
 
 	cleanup()
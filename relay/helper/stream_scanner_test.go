@@ -571,3 +571,114 @@ func TestStreamScannerHandler_StreamStatus_ReplacesPreInitialized(t *testing.T)
 	assert.Equal(t, relaycommon.StreamEndReasonDone, info.StreamStatus.EndReason)
 	assert.Equal(t, 0, info.StreamStatus.TotalErrorCount())
 }
+
+func TestStreamScannerHandler_StreamStatus_FirstTokenTimeout(t *testing.T) {
+	// Not parallel: modifies global stream failover setting
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldSeconds := setting.FirstTokenTimeoutSeconds
+	setting.FirstTokenTimeoutSeconds = 1
+	t.Cleanup(func() { setting.FirstTokenTimeoutSeconds = oldSeconds })
+	withRetryTimes(t, 1)
+
+	pr, pw := io.Pipe()
+	go func() {
+		time.Sleep(2 * time.Second)
+		fmt.Fprint(pw, "data: {\"id\":1}\n")
+		pw.Close()
+	}()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	resp := &http.Response{Body: pr}
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}
+
+	var called atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		StreamScannerHandler(c, resp, info, func(data string, sr *StreamResult) {
+			called.Store(true)
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for first token timeout")
+	}
+
+	assert.False(t, called.Load(), "handler must not run after first token timeout")
+	assert.Empty(t, recorder.Body.String(), "nothing may be written before failover")
+	assert.Equal(t, relaycommon.StreamEndReasonFirstTokenTimeout, info.StreamStatus.EndReason)
+	assert.NotNil(t, StreamFirstTokenTimeoutError(info))
+}
+
+func TestStreamScannerHandler_FirstTokenTimeoutDisarmedAfterFirstToken(t *testing.T) {
+	// Not parallel: modifies global stream failover setting
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldSeconds := setting.FirstTokenTimeoutSeconds
+	setting.FirstTokenTimeoutSeconds = 1
+	t.Cleanup(func() { setting.FirstTokenTimeoutSeconds = oldSeconds })
+	withRetryTimes(t, 1)
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer pw.Close()
+		fmt.Fprint(pw, "data: chunk_0\n")
+		time.Sleep(1500 * time.Millisecond)
+		fmt.Fprint(pw, "data: chunk_1\n")
+		fmt.Fprint(pw, "data: [DONE]\n")
+	}()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	resp := &http.Response{Body: pr}
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}
+
+	var count atomic.Int64
+	StreamScannerHandler(c, resp, info, func(data string, sr *StreamResult) {
+		count.Add(1)
+	})
+
+	assert.Equal(t, int64(2), count.Load())
+	assert.Equal(t, relaycommon.StreamEndReasonDone, info.StreamStatus.EndReason)
+	assert.Nil(t, StreamFirstTokenTimeoutError(info))
+}
+
+func TestStreamScannerHandler_FirstTokenTimeoutStartsAtDispatch(t *testing.T) {
+	// Not parallel: modifies global stream failover setting
+	setting := operation_setting.GetStreamFailoverSetting()
+	oldSeconds := setting.FirstTokenTimeoutSeconds
+	setting.FirstTokenTimeoutSeconds = 1
+	t.Cleanup(func() { setting.FirstTokenTimeoutSeconds = oldSeconds })
+	withRetryTimes(t, 1)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{IsStream: true, ChannelMeta: &relaycommon.ChannelMeta{}}
+
+	require.Equal(t, time.Second, BeginStreamFirstTokenTimeout(c, info))
+	require.False(t, info.FirstTokenDeadline.IsZero())
+	// Simulate an upstream that took most of the timeout to send its headers
+	info.FirstTokenDeadline = time.Now().Add(200 * time.Millisecond)
+
+	pr, pw := io.Pipe()
+	go func() {
+		time.Sleep(600 * time.Millisecond)
+		fmt.Fprint(pw, "data: {\"id\":1}\n")
+		pw.Close()
+	}()
+
+	var called atomic.Bool
+	StreamScannerHandler(c, &http.Response{Body: pr}, info, func(data string, sr *StreamResult) {
+		called.Store(true)
+	})
+
+	assert.False(t, called.Load(), "the scanner only waits for the time left since dispatch")
+	assert.Equal(t, relaycommon.StreamEndReasonFirstTokenTimeout, info.StreamStatus.EndReason)
+}
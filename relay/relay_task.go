@@ -27,6 +27,7 @@ type TaskSubmitResult struct {
 	TaskData       []byte
 	Platform       constant.TaskPlatform
 	Quota          int
+	CallbackURL    string
 	//PerCallPrice   types.PriceData
 }
 
@@ -158,6 +159,10 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 	if taskErr := adaptor.ValidateRequestAndSetAction(c, info); taskErr != nil {
 		return nil, taskErr
 	}
+	callbackURL, taskErr := resolveTaskCallbackURL(c)
+	if taskErr != nil {
+		return nil, taskErr
+	}
 
 	// 2. 确定模型名称
 	modelName := info.OriginModelName
@@ -256,9 +261,26 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 		TaskData:       taskData,
 		Platform:       platform,
 		Quota:          finalQuota,
+		CallbackURL:    callbackURL,
 	}, nil
 }
 
+// resolveTaskCallbackURL 取请求中的 callback_url，未指定时使用令牌的默认回调地址。
+// 不使用通用请求格式的适配器（如 Suno）只会使用令牌默认地址。
+func resolveTaskCallbackURL(c *gin.Context) (string, *dto.TaskError) {
+	callbackURL := c.GetString("token_callback_url")
+	if req, err := relaycommon.GetTaskRequest(c); err == nil && req.CallbackURL != "" {
+		callbackURL = strings.TrimSpace(req.CallbackURL)
+	}
+	if callbackURL == "" {
+		return "", nil
+	}
+	if err := service.ValidateTaskCallbackURL(callbackURL); err != nil {
+		return "", service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
+	return callbackURL, nil
+}
+
 // recalcQuotaFromRatios 根据 adjustedRatios 重新计算 quota。
 // 公式: baseQuota × ∏(ratio) — 其中 baseQuota 是不含 OtherRatios 的基础额度。
 func recalcQuotaFromRatios(info *relaycommon.RelayInfo, ratios map[string]float64) (int, bool) {
@@ -335,7 +357,7 @@ func sunoFetchRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *dto.Ta
 			return
 		}
 		for _, task := range taskModels {
-			tasks = append(tasks, TaskModel2Dto(task))
+			tasks = append(tasks, service.TaskModel2Dto(task))
 		}
 	} else {
 		tasks = make([]any, 0)
@@ -363,7 +385,7 @@ func sunoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *dt
 
 	respBody, err = common.Marshal(dto.TaskResponse[any]{
 		Code: "success",
-		Data: TaskModel2Dto(originTask),
+		Data: service.TaskModel2Dto(originTask),
 	})
 	return
 }
@@ -416,7 +438,7 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 	// 通用 TaskDto 格式
 	respBody, err = common.Marshal(dto.TaskResponse[any]{
 		Code: "success",
-		Data: TaskModel2Dto(originTask),
+		Data: service.TaskModel2Dto(originTask),
 	})
 	if err != nil {
 		taskResp = service.TaskErrorWrapper(err, "marshal_response_failed", http.StatusInternalServerError)
@@ -546,28 +568,3 @@ func mapTaskStatusToSimple(status model.TaskStatus) string {
 		return "processing"
 	}
 }
-
-func TaskModel2Dto(task *model.Task) *dto.TaskDto {
-	return &dto.TaskDto{
-		ID:         task.ID,
-		CreatedAt:  task.CreatedAt,
-		UpdatedAt:  task.UpdatedAt,
-		TaskID:     task.TaskID,
-		Platform:   string(task.Platform),
-		UserId:     task.UserId,
-		Group:      task.Group,
-		ChannelId:  task.ChannelId,
-		Quota:      task.Quota,
-		Action:     task.Action,
-		Status:     string(task.Status),
-		FailReason: task.FailReason,
-		ResultURL:  task.GetResultURL(),
-		SubmitTime: task.SubmitTime,
-		StartTime:  task.StartTime,
-		FinishTime: task.FinishTime,
-		Progress:   task.Progress,
-		Properties: task.Properties,
-		Username:   task.Username,
-		Data:       task.Data,
-	}
-}
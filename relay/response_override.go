@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyResponseOverride 在状态码检查之前按渠道的响应覆盖规则改写非流式响应与错误响应，
+// 使错误体映射在 RelayErrorHandler 解析之前生效；成功的流式响应在 StreamScannerHandler 中逐个事件处理
+func applyResponseOverride(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) {
+	if resp == nil || resp.Body == nil || (info.IsStream && resp.StatusCode == http.StatusOK) {
+		return
+	}
+	override, err := relaycommon.NewResponseOverride(info, resp.StatusCode)
+	if err != nil {
+		logger.LogError(c, "invalid response override: "+err.Error())
+		return
+	}
+	if override == nil {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	// 整体响应不会被 drop，用量对象由 Apply 保持上游原值，之后渠道处理函数照常解析计费用量
+	output, _, err := override.Apply(body)
+	if err != nil {
+		logger.LogError(c, "failed to apply response override: "+err.Error())
+	} else {
+		body = output
+	}
+	replaceResponseBody(resp, body)
+}
+
+func replaceResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+}
@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseOverrideKeepsBillingUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var override map[string]any
+	require.NoError(t, common.UnmarshalJsonStr(`{"operations":[
+		{"path":"model","mode":"set_from_context","from":"original_model"},
+		{"path":"*.completion_tokens","mode":"set","value":0},
+		{"path":"*.prompt_tokens","mode":"delete"}
+	]}`, &override))
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName:    "vendor/gpt-4o-2024",
+			ChannelOtherSettings: dto.ChannelOtherSettings{ResponseOverride: override},
+		},
+	}
+	body := `{"id":"chatcmpl-1","object":"chat.completion","model":"vendor/gpt-4o-2024",` +
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	applyResponseOverride(c, info, resp)
+	usage, apiErr := openai.OpenaiHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.NotNil(t, usage)
+	assert.Equal(t, 12, usage.PromptTokens)
+	assert.Equal(t, 34, usage.CompletionTokens)
+	assert.Equal(t, 46, usage.TotalTokens)
+
+	var written map[string]any
+	require.NoError(t, common.Unmarshal(w.Body.Bytes(), &written))
+	assert.Equal(t, "gpt-4o", written["model"], "the client still sees the overridden body")
+}
@@ -0,0 +1,75 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/wasmplugin"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 透传请求体时不解析请求，不执行 pre_conversion / post_conversion 钩子
+
+// applyPreConversionPlugins 在转换为上游格式前，由绑定的插件改写客户端请求
+func applyPreConversionPlugins[T any](c *gin.Context, info *relaycommon.RelayInfo, request *T) *types.NewAPIError {
+	if !service.HasWasmPlugins(info) {
+		return nil
+	}
+	data, err := common.Marshal(request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+	}
+	output, changed, err := service.RunWasmPluginHook(c, info, wasmplugin.HookPreConversion, data)
+	if err != nil {
+		return newAPIErrorFromWasmPlugin(err)
+	}
+	if !changed {
+		return nil
+	}
+	var replaced T
+	if err := common.Unmarshal(output, &replaced); err != nil {
+		return types.NewError(fmt.Errorf("wasm plugin returned an invalid request: %w", err), types.ErrorCodeWasmPluginFailed, types.ErrOptionWithSkipRetry())
+	}
+	*request = replaced
+	return nil
+}
+
+// applyPostConversionPlugins 在参数覆盖之后，由绑定的插件改写发往上游的请求体
+func applyPostConversionPlugins(c *gin.Context, info *relaycommon.RelayInfo, jsonData []byte) ([]byte, *types.NewAPIError) {
+	output, _, err := service.RunWasmPluginHook(c, info, wasmplugin.HookPostConversion, jsonData)
+	if err != nil {
+		return nil, newAPIErrorFromWasmPlugin(err)
+	}
+	return output, nil
+}
+
+// applyResponsePlugins 非流式响应整体作为一个 response_chunk 交给插件处理，
+// 流式响应在 StreamScannerHandler 中逐块处理
+func applyResponsePlugins(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) {
+	if resp == nil || resp.Body == nil || info.IsStream || !service.HasWasmPlugins(info) {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	replaceResponseBody(resp, service.RunWasmPluginStreamHook(c, info, wasmplugin.HookResponseChunk, body))
+}
+
+func newAPIErrorFromWasmPlugin(err error) *types.NewAPIError {
+	var pluginErr *service.WasmPluginError
+	if errors.As(err, &pluginErr) && pluginErr.Rejected {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeWasmPluginRejected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return types.NewError(err, types.ErrorCodeWasmPluginFailed, types.ErrOptionWithSkipRetry())
+}
@@ -85,6 +85,36 @@ type ChannelOtherSettings struct {
 	UpstreamModelUpdateLastRemovedModels  []string              `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
 	UpstreamModelUpdateIgnoredModels      []string              `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
 	AdvancedCustom                        *AdvancedCustomConfig `json:"advanced_custom,omitempty"`
+	CustomTask                            *CustomTaskConfig     `json:"custom_task,omitempty"`
+	UpstreamCost                          *ChannelUpstreamCost  `json:"upstream_cost,omitempty"`     // 上游成本定价，用于毛利统计
+	WasmPlugins                           []string              `json:"wasm_plugins,omitempty"`      // 按顺序执行的 WASM 插件名称
+	ResponseOverride                      map[string]any        `json:"response_override,omitempty"` // 响应覆盖规则，结构同 param override 的 operations
+}
+
+// ChannelUpstreamCost 渠道上游成本定价。结算时按此计算本次请求的上游成本（quota 单位），
+// 与向用户收取的 quota 一同写入日志。Expr 优先于倍率；均未配置时不记录成本。
+type ChannelUpstreamCost struct {
+	// Ratio 成本倍率：上游成本 = 模型基础价格（不含分组倍率）× Ratio，
+	// 同时作为“最便宜渠道优先”模式下的排序依据
+	Ratio float64 `json:"ratio,omitempty"`
+	// ModelRatios 按模型覆盖 Ratio
+	ModelRatios map[string]float64 `json:"model_ratios,omitempty"`
+	// Expr 成本计费表达式，语法与分段计费表达式相同（系数为 $/1M tokens）
+	Expr string `json:"expr,omitempty"`
+}
+
+// RatioFor 返回指定模型的成本倍率，未配置时 ok 为 false
+func (c *ChannelUpstreamCost) RatioFor(model string) (ratio float64, ok bool) {
+	if c == nil {
+		return 0, false
+	}
+	if ratio, ok = c.ModelRatios[model]; ok {
+		return ratio, true
+	}
+	if c.Ratio > 0 {
+		return c.Ratio, true
+	}
+	return 0, false
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
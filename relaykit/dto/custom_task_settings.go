@@ -0,0 +1,266 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Statuses a custom task status_mapping may map upstream values to.
+const (
+	CustomTaskStatusSubmitted  = "submitted"
+	CustomTaskStatusQueued     = "queued"
+	CustomTaskStatusInProgress = "in_progress"
+	CustomTaskStatusSuccess    = "success"
+	CustomTaskStatusFailure    = "failure"
+)
+
+// Placeholders available to custom task templates. Any other placeholder is
+// a path into the client request, e.g. {{request.prompt}} or
+// {{request.metadata.seed}}.
+const (
+	CustomTaskPlaceholderModel  = "model"
+	CustomTaskPlaceholderTaskId = "task_id"
+	customTaskRequestPrefix     = "request"
+)
+
+// CustomTaskPlaceholderPattern matches {{path}} placeholders in templates.
+var CustomTaskPlaceholderPattern = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// CustomTaskConfig declares an asynchronous task API for the Custom Task
+// channel type. The submit endpoint creates the upstream task and the fetch
+// endpoint is polled until the status mapping reports success or failure.
+// Response paths use gjson syntax.
+type CustomTaskConfig struct {
+	Submit   CustomTaskEndpoint        `json:"submit"`
+	Fetch    CustomTaskEndpoint        `json:"fetch"`
+	Auth     *AdvancedCustomRouteAuth  `json:"auth,omitempty"` // defaults to Authorization: Bearer {api_key}
+	Response CustomTaskResponseMapping `json:"response"`
+	Billing  *CustomTaskBilling        `json:"billing,omitempty"`
+	// TestTaskId is an existing upstream task fetched by the channel test, so
+	// the fetch endpoint and mappings can be checked without submitting.
+	TestTaskId string `json:"test_task_id,omitempty"`
+}
+
+// CustomTaskEndpoint is a full URL or a path below the channel base URL.
+// BodyTemplate is a JSON document whose strings may contain placeholders; a
+// string that is exactly one placeholder is replaced by the value with its
+// JSON type, and dropped when the value is missing. Without a template the
+// submit endpoint receives the client request with the upstream model.
+type CustomTaskEndpoint struct {
+	Method       string            `json:"method,omitempty"` // submit defaults to POST, fetch to GET
+	Path         string            `json:"path"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyTemplate string            `json:"body_template,omitempty"`
+}
+
+// CustomTaskResponseMapping locates task fields in upstream responses.
+// TaskIdPath applies to the submit response, the rest to the fetch response.
+type CustomTaskResponseMapping struct {
+	TaskIdPath     string `json:"task_id_path"`
+	StatusPath     string `json:"status_path"`
+	ProgressPath   string `json:"progress_path,omitempty"`
+	ResultURLPath  string `json:"result_url_path"`
+	FailReasonPath string `json:"fail_reason_path,omitempty"`
+	// StatusMapping maps upstream status values to CustomTaskStatus* values.
+	// Unmapped values are reported as errors and polled again.
+	StatusMapping map[string]string `json:"status_mapping"`
+}
+
+// CustomTaskBilling extracts OtherRatios multiplied into the model price.
+// RequestRatios are read from the placeholder context at submit time;
+// SubmitRatios are read from the submit response and replace them.
+type CustomTaskBilling struct {
+	RequestRatios map[string]CustomTaskRatio `json:"request_ratios,omitempty"`
+	SubmitRatios  map[string]CustomTaskRatio `json:"submit_ratios,omitempty"`
+}
+
+// CustomTaskRatio reads a number at Path, or looks the value up in Values
+// when set (e.g. {"720p": 1, "1080p": 1.5}). Missing values add no ratio.
+// Numbers come from the client or the upstream, so they are clamped to
+// [Min, Max]; Max is required when Values is not set.
+type CustomTaskRatio struct {
+	Path   string             `json:"path"`
+	Values map[string]float64 `json:"values,omitempty"`
+	Min    float64            `json:"min,omitempty"`
+	Max    float64            `json:"max,omitempty"`
+}
+
+func (c *CustomTaskConfig) SubmitMethod() string {
+	return customTaskMethod(c.Submit.Method, "POST")
+}
+
+func (c *CustomTaskConfig) FetchMethod() string {
+	return customTaskMethod(c.Fetch.Method, "GET")
+}
+
+func customTaskMethod(method string, fallback string) string {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		return fallback
+	}
+	return method
+}
+
+func (c *CustomTaskConfig) Validate() error {
+	if c == nil {
+		return fmt.Errorf("custom_task is required")
+	}
+	if err := validateCustomTaskEndpoint("submit", c.Submit, c.SubmitMethod()); err != nil {
+		return err
+	}
+	if customTaskHasPlaceholder(c.Submit.BodyTemplate, CustomTaskPlaceholderTaskId) {
+		return fmt.Errorf("custom_task.submit cannot reference {{%s}}", CustomTaskPlaceholderTaskId)
+	}
+	for _, placeholder := range customTaskPlaceholders(c.Submit.Path) {
+		if placeholder != CustomTaskPlaceholderModel {
+			return fmt.Errorf("custom_task.submit.path only supports {{%s}}, got {{%s}}", CustomTaskPlaceholderModel, placeholder)
+		}
+	}
+	if err := validateCustomTaskEndpoint("fetch", c.Fetch, c.FetchMethod()); err != nil {
+		return err
+	}
+	if !customTaskHasPlaceholder(c.Fetch.Path, CustomTaskPlaceholderTaskId) &&
+		!customTaskHasPlaceholder(c.Fetch.BodyTemplate, CustomTaskPlaceholderTaskId) {
+		return fmt.Errorf("custom_task.fetch must reference {{%s}} in path or body_template", CustomTaskPlaceholderTaskId)
+	}
+	for _, placeholder := range customTaskPlaceholders(c.Fetch.Path + c.Fetch.BodyTemplate) {
+		if placeholder != CustomTaskPlaceholderTaskId {
+			return fmt.Errorf("custom_task.fetch only supports {{%s}}, got {{%s}}", CustomTaskPlaceholderTaskId, placeholder)
+		}
+	}
+	if auth := c.Auth; auth != nil {
+		switch strings.TrimSpace(auth.Type) {
+		case AdvancedCustomAuthTypeNone:
+		case AdvancedCustomAuthTypeHeader, AdvancedCustomAuthTypeQuery:
+			if strings.TrimSpace(auth.Name) == "" || strings.TrimSpace(auth.Value) == "" {
+				return fmt.Errorf("custom_task.auth.name and custom_task.auth.value are required")
+			}
+		default:
+			return fmt.Errorf("custom_task.auth.type is invalid: %s", auth.Type)
+		}
+	}
+
+	response := c.Response
+	if strings.TrimSpace(response.TaskIdPath) == "" {
+		return fmt.Errorf("custom_task.response.task_id_path is required")
+	}
+	if strings.TrimSpace(response.StatusPath) == "" {
+		return fmt.Errorf("custom_task.response.status_path is required")
+	}
+	if strings.TrimSpace(response.ResultURLPath) == "" {
+		return fmt.Errorf("custom_task.response.result_url_path is required")
+	}
+	hasSuccess, hasFailure := false, false
+	for value, status := range response.StatusMapping {
+		switch status {
+		case CustomTaskStatusSuccess:
+			hasSuccess = true
+		case CustomTaskStatusFailure:
+			hasFailure = true
+		case CustomTaskStatusSubmitted, CustomTaskStatusQueued, CustomTaskStatusInProgress:
+		default:
+			return fmt.Errorf("custom_task.response.status_mapping[%q] is invalid: %s", value, status)
+		}
+	}
+	if !hasSuccess || !hasFailure {
+		return fmt.Errorf("custom_task.response.status_mapping must map at least one value to %s and one to %s", CustomTaskStatusSuccess, CustomTaskStatusFailure)
+	}
+
+	if c.Billing != nil {
+		if err := validateCustomTaskRatios("request_ratios", c.Billing.RequestRatios); err != nil {
+			return err
+		}
+		if err := validateCustomTaskRatios("submit_ratios", c.Billing.SubmitRatios); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCustomTaskEndpoint(name string, endpoint CustomTaskEndpoint, method string) error {
+	if method != "GET" && method != "POST" && method != "PUT" {
+		return fmt.Errorf("custom_task.%s.method must be GET, POST or PUT", name)
+	}
+	path := strings.TrimSpace(endpoint.Path)
+	if path == "" {
+		return fmt.Errorf("custom_task.%s.path is required", name)
+	}
+	if strings.HasPrefix(path, "/") {
+		if strings.HasPrefix(path, "//") {
+			return fmt.Errorf("custom_task.%s.path must be a full URL or a path starting with /", name)
+		}
+	} else {
+		parsedURL, err := url.Parse(CustomTaskPlaceholderPattern.ReplaceAllString(path, "x"))
+		if err != nil || parsedURL.Host == "" || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return fmt.Errorf("custom_task.%s.path must be a full URL or a path starting with /", name)
+		}
+	}
+	for header := range endpoint.Headers {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("custom_task.%s.headers contains an empty name", name)
+		}
+	}
+	if strings.TrimSpace(endpoint.BodyTemplate) != "" {
+		if method == "GET" {
+			return fmt.Errorf("custom_task.%s.body_template is not allowed for GET", name)
+		}
+		var template map[string]any
+		if err := json.Unmarshal([]byte(endpoint.BodyTemplate), &template); err != nil {
+			return fmt.Errorf("custom_task.%s.body_template must be a JSON object: %v", name, err)
+		}
+	}
+	for _, placeholder := range customTaskPlaceholders(endpoint.Path + endpoint.BodyTemplate) {
+		if placeholder == "" {
+			return fmt.Errorf("custom_task.%s contains an empty placeholder", name)
+		}
+		if placeholder != CustomTaskPlaceholderModel && placeholder != CustomTaskPlaceholderTaskId &&
+			placeholder != customTaskRequestPrefix && !strings.HasPrefix(placeholder, customTaskRequestPrefix+".") {
+			return fmt.Errorf("custom_task.%s placeholder {{%s}} is unknown", name, placeholder)
+		}
+	}
+	return nil
+}
+
+func validateCustomTaskRatios(name string, ratios map[string]CustomTaskRatio) error {
+	for key, ratio := range ratios {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("custom_task.billing.%s contains an empty name", name)
+		}
+		if strings.TrimSpace(ratio.Path) == "" {
+			return fmt.Errorf("custom_task.billing.%s[%q].path is required", name, key)
+		}
+		for value, multiplier := range ratio.Values {
+			if multiplier < 0 {
+				return fmt.Errorf("custom_task.billing.%s[%q].values[%q] must not be negative", name, key, value)
+			}
+		}
+		if len(ratio.Values) == 0 && ratio.Max <= 0 {
+			return fmt.Errorf("custom_task.billing.%s[%q].max is required for numeric ratios", name, key)
+		}
+		if ratio.Min < 0 || (ratio.Max > 0 && ratio.Min > ratio.Max) {
+			return fmt.Errorf("custom_task.billing.%s[%q].min must be between 0 and max", name, key)
+		}
+	}
+	return nil
+}
+
+func customTaskPlaceholders(template string) []string {
+	matches := CustomTaskPlaceholderPattern.FindAllStringSubmatch(template, -1)
+	placeholders := make([]string, 0, len(matches))
+	for _, match := range matches {
+		placeholders = append(placeholders, match[1])
+	}
+	return placeholders
+}
+
+func customTaskHasPlaceholder(template string, placeholder string) bool {
+	for _, name := range customTaskPlaceholders(template) {
+		if name == placeholder {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validCustomTaskConfig() *CustomTaskConfig {
+	return &CustomTaskConfig{
+		Submit: CustomTaskEndpoint{
+			Path:         "/v1/{{model}}/generate",
+			BodyTemplate: `{"prompt":"{{request.prompt}}","seconds":"{{request.duration}}"}`,
+		},
+		Fetch: CustomTaskEndpoint{Path: "/v1/tasks/{{task_id}}"},
+		Response: CustomTaskResponseMapping{
+			TaskIdPath:    "data.id",
+			StatusPath:    "data.state",
+			ResultURLPath: "data.video.url",
+			StatusMapping: map[string]string{"running": CustomTaskStatusInProgress, "done": CustomTaskStatusSuccess, "error": CustomTaskStatusFailure},
+		},
+		Billing: &CustomTaskBilling{
+			RequestRatios: map[string]CustomTaskRatio{"seconds": {Path: "request.duration", Max: 60}},
+		},
+	}
+}
+
+func TestCustomTaskValidate(t *testing.T) {
+	require.NoError(t, validCustomTaskConfig().Validate())
+
+	fullURL := validCustomTaskConfig()
+	fullURL.Fetch.Path = "https://tasks.example.com/query"
+	fullURL.Fetch.Method = "post"
+	fullURL.Fetch.BodyTemplate = `{"id":"{{task_id}}"}`
+	require.NoError(t, fullURL.Validate())
+	assert.Equal(t, "POST", fullURL.FetchMethod())
+
+	tests := []struct {
+		name   string
+		mutate func(*CustomTaskConfig)
+		errMsg string
+	}{
+		{name: "missing submit path", mutate: func(c *CustomTaskConfig) { c.Submit.Path = "" }, errMsg: "submit.path is required"},
+		{name: "relative URL", mutate: func(c *CustomTaskConfig) { c.Submit.Path = "v1/generate" }, errMsg: "full URL or a path"},
+		{name: "fetch without task id", mutate: func(c *CustomTaskConfig) { c.Fetch.Path = "/v1/tasks" }, errMsg: "must reference {{task_id}}"},
+		{name: "fetch with request placeholder", mutate: func(c *CustomTaskConfig) { c.Fetch.Path = "/v1/tasks/{{task_id}}/{{request.prompt}}" }, errMsg: "fetch only supports"},
+		{name: "submit path with request placeholder", mutate: func(c *CustomTaskConfig) { c.Submit.Path = "/v1/{{request.size}}" }, errMsg: "submit.path only supports"},
+		{name: "unknown placeholder", mutate: func(c *CustomTaskConfig) { c.Submit.BodyTemplate = `{"a":"{{prompt}}"}` }, errMsg: "is unknown"},
+		{name: "body template not JSON", mutate: func(c *CustomTaskConfig) { c.Submit.BodyTemplate = `prompt={{request.prompt}}` }, errMsg: "must be a JSON object"},
+		{name: "body on GET", mutate: func(c *CustomTaskConfig) { c.Fetch.BodyTemplate = `{"id":"{{task_id}}"}` }, errMsg: "not allowed for GET"},
+		{name: "bad method", mutate: func(c *CustomTaskConfig) { c.Submit.Method = "DELETE" }, errMsg: "method must be"},
+		{name: "missing task id path", mutate: func(c *CustomTaskConfig) { c.Response.TaskIdPath = "" }, errMsg: "task_id_path is required"},
+		{name: "missing result url path", mutate: func(c *CustomTaskConfig) { c.Response.ResultURLPath = "" }, errMsg: "result_url_path is required"},
+		{name: "invalid mapped status", mutate: func(c *CustomTaskConfig) { c.Response.StatusMapping["x"] = "finished" }, errMsg: "is invalid"},
+		{name: "no failure status", mutate: func(c *CustomTaskConfig) { delete(c.Response.StatusMapping, "error") }, errMsg: "at least one value"},
+		{name: "auth without name", mutate: func(c *CustomTaskConfig) { c.Auth = &AdvancedCustomRouteAuth{Type: AdvancedCustomAuthTypeHeader} }, errMsg: "auth.name"},
+		{name: "ratio without path", mutate: func(c *CustomTaskConfig) { c.Billing.SubmitRatios = map[string]CustomTaskRatio{"size": {}} }, errMsg: "path is required"},
+		{name: "numeric ratio without max", mutate: func(c *CustomTaskConfig) {
+			c.Billing.RequestRatios["seconds"] = CustomTaskRatio{Path: "request.duration"}
+		}, errMsg: "max is required"},
+		{name: "ratio min above max", mutate: func(c *CustomTaskConfig) {
+			c.Billing.RequestRatios["seconds"] = CustomTaskRatio{Path: "request.duration", Min: 5, Max: 2}
+		}, errMsg: "between 0 and max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validCustomTaskConfig()
+			tt.mutate(config)
+			err := config.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	var missing *CustomTaskConfig
+	assert.EqualError(t, missing.Validate(), "custom_task is required")
+}
@@ -10,12 +10,19 @@ type UserSetting struct {
 	GotifyUrl                        string  `json:"gotify_url,omitempty"`                           // GotifyUrl Gotify服务器地址
 	GotifyToken                      string  `json:"gotify_token,omitempty"`                         // GotifyToken Gotify应用令牌
 	GotifyPriority                   int     `json:"gotify_priority"`                                // GotifyPriority Gotify消息优先级
+	SlackWebhookUrl                  string  `json:"slack_webhook_url,omitempty"`                    // SlackWebhookUrl Slack Incoming Webhook地址
+	FeishuWebhookUrl                 string  `json:"feishu_webhook_url,omitempty"`                   // FeishuWebhookUrl 飞书/Lark机器人地址
+	FeishuSecret                     string  `json:"feishu_secret,omitempty"`                        // FeishuSecret 飞书/Lark机器人签名密钥
+	DingTalkWebhookUrl               string  `json:"dingtalk_webhook_url,omitempty"`                 // DingTalkWebhookUrl 钉钉机器人地址
+	DingTalkSecret                   string  `json:"dingtalk_secret,omitempty"`                      // DingTalkSecret 钉钉机器人加签密钥
+	WeComWebhookUrl                  string  `json:"wecom_webhook_url,omitempty"`                    // WeComWebhookUrl 企业微信群机器人地址
 	UpstreamModelUpdateNotifyEnabled bool    `json:"upstream_model_update_notify_enabled,omitempty"` // 是否接收上游模型更新定时检测通知（仅管理员）
 	AcceptUnsetRatioModel            bool    `json:"accept_unset_model_ratio_model,omitempty"`       // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	RecordIpLog                      bool    `json:"record_ip_log,omitempty"`                        // 是否记录请求和错误日志IP
 	SidebarModules                   string  `json:"sidebar_modules,omitempty"`                      // SidebarModules 左侧边栏模块配置
 	BillingPreference                string  `json:"billing_preference,omitempty"`                   // BillingPreference 扣费策略（订阅/钱包）
 	Language                         string  `json:"language,omitempty"`                             // Language 用户语言偏好 (zh, en)
+	Currency                         string  `json:"currency,omitempty"`                             // Currency 用户展示与支付货币，为空时使用站点默认货币
 }
 
 var (
@@ -23,4 +30,10 @@ var (
 	NotifyTypeWebhook = "webhook" // Webhook
 	NotifyTypeBark    = "bark"    // Bark 推送
 	NotifyTypeGotify  = "gotify"  // Gotify 推送
+
+	NotifyTypeSlack    = "slack"    // Slack Incoming Webhook
+	NotifyTypeFeishu   = "feishu"   // 飞书/Lark 机器人
+	NotifyTypeDingTalk = "dingtalk" // 钉钉机器人
+	NotifyTypeWeCom    = "wecom"    // 企业微信群机器人
+	NotifyTypeTelegram = "telegram" // Telegram 机器人
 )
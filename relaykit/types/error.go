@@ -43,13 +43,15 @@ const (
 	ErrorCodeViolationFeeGrokCSAM   ErrorCode = "violation_fee.grok.csam"
 
 	// new api error
-	ErrorCodeCountTokenFailed   ErrorCode = "count_token_failed"
-	ErrorCodeModelPriceError    ErrorCode = "model_price_error"
-	ErrorCodeInvalidApiType     ErrorCode = "invalid_api_type"
-	ErrorCodeJsonMarshalFailed  ErrorCode = "json_marshal_failed"
-	ErrorCodeDoRequestFailed    ErrorCode = "do_request_failed"
-	ErrorCodeGetChannelFailed   ErrorCode = "get_channel_failed"
-	ErrorCodeGenRelayInfoFailed ErrorCode = "gen_relay_info_failed"
+	ErrorCodeCountTokenFailed    ErrorCode = "count_token_failed"
+	ErrorCodeModelPriceError     ErrorCode = "model_price_error"
+	ErrorCodeInvalidApiType      ErrorCode = "invalid_api_type"
+	ErrorCodeJsonMarshalFailed   ErrorCode = "json_marshal_failed"
+	ErrorCodeDoRequestFailed     ErrorCode = "do_request_failed"
+	ErrorCodeGetChannelFailed    ErrorCode = "get_channel_failed"
+	ErrorCodeGenRelayInfoFailed  ErrorCode = "gen_relay_info_failed"
+	ErrorCodeMultiKeyRateLimited ErrorCode = "multi_key_rate_limited"
+	ErrorCodeWasmPluginFailed    ErrorCode = "wasm_plugin_failed"
 
 	// channel error
 	ErrorCodeChannelNoAvailableKey        ErrorCode = "channel:no_available_key"
@@ -66,17 +68,20 @@ const (
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
 
 	// request error
-	ErrorCodeBadRequestBody ErrorCode = "bad_request_body"
+	ErrorCodeBadRequestBody     ErrorCode = "bad_request_body"
+	ErrorCodeWasmPluginRejected ErrorCode = "wasm_plugin_rejected"
 
 	// response error
-	ErrorCodeReadResponseBodyFailed ErrorCode = "read_response_body_failed"
-	ErrorCodeBadResponseStatusCode  ErrorCode = "bad_response_status_code"
-	ErrorCodeBadResponse            ErrorCode = "bad_response"
-	ErrorCodeBadResponseBody        ErrorCode = "bad_response_body"
-	ErrorCodeEmptyResponse          ErrorCode = "empty_response"
-	ErrorCodeAwsInvokeError         ErrorCode = "aws_invoke_error"
-	ErrorCodeModelNotFound          ErrorCode = "model_not_found"
-	ErrorCodePromptBlocked          ErrorCode = "prompt_blocked"
+	ErrorCodeReadResponseBodyFailed  ErrorCode = "read_response_body_failed"
+	ErrorCodeBadResponseStatusCode   ErrorCode = "bad_response_status_code"
+	ErrorCodeBadResponse             ErrorCode = "bad_response"
+	ErrorCodeBadResponseBody         ErrorCode = "bad_response_body"
+	ErrorCodeEmptyResponse           ErrorCode = "empty_response"
+	ErrorCodeAwsInvokeError          ErrorCode = "aws_invoke_error"
+	ErrorCodeModelNotFound           ErrorCode = "model_not_found"
+	ErrorCodePromptBlocked           ErrorCode = "prompt_blocked"
+	ErrorCodeStreamFirstTokenTimeout ErrorCode = "stream_first_token_timeout"
+	ErrorCodeStreamInterrupted       ErrorCode = "stream_interrupted"
 
 	// sql error
 	ErrorCodeQueryDataError  ErrorCode = "query_data_error"
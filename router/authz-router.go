@@ -1,19 +1,33 @@
 package router
 
 import (
+	"net/http"
+
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service/authz"
 
 	"github.com/gin-gonic/gin"
 )
 
 // registerAuthzRoutes mounts the authorization API under its own /authz
 // namespace. GET /authz/catalog returns the permission schema (resources,
-// actions, and role baselines) used by the client permission editor.
+// actions, and role baselines) used by the client permission editor; the
+// remaining routes manage custom roles and their assignment to users.
 func registerAuthzRoutes(apiRouter *gin.RouterGroup) {
 	authzRoute := apiRouter.Group("/authz")
-	authzRoute.Use(middleware.AdminAuth())
+	authzRoute.Use(middleware.StaffAuth())
 	{
 		authzRoute.GET("/catalog", controller.GetPermissionCatalog)
 	}
+	handlePermissionRoutes(authzRoute, authzPermissionRoutes)
+}
+
+var authzPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/roles", permission: authz.RoleManage, handler: controller.GetAuthzRoles},
+	{method: http.MethodPost, path: "/roles", permission: authz.RoleManage, handler: controller.CreateAuthzRole},
+	{method: http.MethodPut, path: "/roles/:key", permission: authz.RoleManage, handler: controller.UpdateAuthzRole},
+	{method: http.MethodDelete, path: "/roles/:key", permission: authz.RoleManage, handler: controller.DeleteAuthzRole},
+	{method: http.MethodGet, path: "/users/:id/roles", permission: authz.RoleManage, handler: controller.GetUserAuthzRoles},
+	{method: http.MethodPut, path: "/users/:id/roles", permission: authz.RoleManage, handler: controller.UpdateUserAuthzRoles},
 }
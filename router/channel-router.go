@@ -9,16 +9,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type permissionRoute struct {
-	method     string
-	path       string
-	permission authz.Permission
-	handler    gin.HandlerFunc
-}
-
 func registerChannelRoutes(apiRouter *gin.RouterGroup) {
 	channelRoute := apiRouter.Group("/channel")
-	channelRoute.Use(middleware.AdminAuth())
+	channelRoute.Use(middleware.StaffAuth())
 
 	channelRoute.POST("/:id/key",
 		middleware.RootAuth(),
@@ -28,12 +21,7 @@ func registerChannelRoutes(apiRouter *gin.RouterGroup) {
 		controller.GetChannelKey,
 	)
 
-	for _, route := range channelPermissionRoutes {
-		channelRoute.Handle(route.method, route.path,
-			middleware.RequirePermission(route.permission),
-			route.handler,
-		)
-	}
+	handlePermissionRoutes(channelRoute, channelPermissionRoutes)
 }
 
 var channelPermissionRoutes = []permissionRoute{
@@ -42,9 +30,11 @@ var channelPermissionRoutes = []permissionRoute{
 	{method: http.MethodGet, path: "/models", permission: authz.ChannelRead, handler: controller.ChannelListModels},
 	{method: http.MethodGet, path: "/models_enabled", permission: authz.ChannelRead, handler: controller.EnabledListModels},
 	{method: http.MethodGet, path: "/ops", permission: authz.ChannelRead, handler: controller.GetChannelOps},
+	{method: http.MethodGet, path: "/schedule", permission: authz.ChannelRead, handler: controller.GetChannelSchedules},
 	{method: http.MethodGet, path: "/:id", permission: authz.ChannelRead, handler: controller.GetChannel},
 	{method: http.MethodGet, path: "/test", permission: authz.ChannelOperate, handler: controller.TestAllChannels},
 	{method: http.MethodGet, path: "/test/:id", permission: authz.ChannelOperate, handler: controller.TestChannel},
+	{method: http.MethodPost, path: "/:id/dry-run", permission: authz.ChannelOperate, handler: controller.DryRunChannel},
 	{method: http.MethodGet, path: "/update_balance", permission: authz.ChannelOperate, handler: controller.UpdateAllChannelsBalance},
 	{method: http.MethodGet, path: "/update_balance/:id", permission: authz.ChannelOperate, handler: controller.UpdateChannelBalance},
 	{method: http.MethodPost, path: "/", permission: authz.ChannelSensitiveWrite, handler: controller.AddChannel},
@@ -75,5 +65,9 @@ var channelPermissionRoutes = []permissionRoute{
 	{method: http.MethodPost, path: "/upstream_updates/apply", permission: authz.ChannelWrite, handler: controller.ApplyChannelUpstreamModelUpdates},
 	{method: http.MethodPost, path: "/upstream_updates/apply_all", permission: authz.ChannelWrite, handler: controller.ApplyAllChannelUpstreamModelUpdates},
 	{method: http.MethodPost, path: "/upstream_updates/detect", permission: authz.ChannelOperate, handler: controller.DetectChannelUpstreamModelUpdates},
+	{method: http.MethodPost, path: "/schedule", permission: authz.ChannelOperate, handler: controller.CreateChannelSchedule},
+	{method: http.MethodPut, path: "/schedule", permission: authz.ChannelOperate, handler: controller.UpdateChannelSchedule},
+	{method: http.MethodDelete, path: "/schedule/:id", permission: authz.ChannelOperate, handler: controller.DeleteChannelSchedule},
+	{method: http.MethodPost, path: "/:id/maintenance", permission: authz.ChannelOperate, handler: controller.CreateChannelMaintenance},
 	{method: http.MethodPost, path: "/upstream_updates/detect_all", permission: authz.ChannelOperate, handler: controller.DetectAllChannelUpstreamModelUpdates},
 }
@@ -0,0 +1,182 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service/authz"
+	"github.com/gin-gonic/gin"
+)
+
+type permissionRoute struct {
+	method     string
+	path       string
+	permission authz.Permission
+	handler    gin.HandlerFunc
+}
+
+// handlePermissionRoutes mounts routes that are guarded by a single authz
+// permission. The group is expected to use middleware.StaffAuth so users that
+// only hold a custom role can reach them.
+func handlePermissionRoutes(group *gin.RouterGroup, routes []permissionRoute) {
+	for _, route := range routes {
+		group.Handle(route.method, route.path,
+			middleware.RequirePermission(route.permission),
+			route.handler,
+		)
+	}
+}
+
+// ManageUser dispatches several actions; it checks the permission of the
+// requested action itself, so the route only requires read access.
+var userPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.UserRead, handler: controller.GetAllUsers},
+	{method: http.MethodGet, path: "/topup", permission: authz.TopUpRead, handler: controller.GetAllTopUps},
+	{method: http.MethodPost, path: "/topup/complete", permission: authz.TopUpWrite, handler: controller.AdminCompleteTopUp},
+	{method: http.MethodGet, path: "/search", permission: authz.UserRead, handler: controller.SearchUsers},
+	{method: http.MethodGet, path: "/:id/oauth/bindings", permission: authz.UserRead, handler: controller.GetUserOAuthBindingsByAdmin},
+	{method: http.MethodDelete, path: "/:id/oauth/bindings/:provider_id", permission: authz.UserWrite, handler: controller.UnbindCustomOAuthByAdmin},
+	{method: http.MethodDelete, path: "/:id/bindings/:binding_type", permission: authz.UserWrite, handler: controller.AdminClearUserBinding},
+	{method: http.MethodGet, path: "/:id", permission: authz.UserRead, handler: controller.GetUser},
+	{method: http.MethodPost, path: "/", permission: authz.UserManage, handler: controller.CreateUser},
+	{method: http.MethodPost, path: "/manage", permission: authz.UserRead, handler: controller.ManageUser},
+	{method: http.MethodPut, path: "/", permission: authz.UserWrite, handler: controller.UpdateUser},
+	{method: http.MethodDelete, path: "/:id", permission: authz.UserManage, handler: controller.DeleteUser},
+	{method: http.MethodDelete, path: "/:id/reset_passkey", permission: authz.UserWrite, handler: controller.AdminResetPasskey},
+	{method: http.MethodGet, path: "/:id/tokens", permission: authz.TokenRead, handler: controller.GetUserTokensByAdmin},
+	{method: http.MethodDelete, path: "/:id/tokens/:token_id", permission: authz.TokenWrite, handler: controller.DeleteUserTokenByAdmin},
+	{method: http.MethodGet, path: "/2fa/stats", permission: authz.UserRead, handler: controller.Admin2FAStats},
+	{method: http.MethodDelete, path: "/:id/2fa", permission: authz.UserWrite, handler: controller.AdminDisable2FA},
+}
+
+var subscriptionAdminPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/plans", permission: authz.SubscriptionRead, handler: controller.AdminListSubscriptionPlans},
+	{method: http.MethodPost, path: "/plans", permission: authz.SubscriptionWrite, handler: controller.AdminCreateSubscriptionPlan},
+	{method: http.MethodPut, path: "/plans/:id", permission: authz.SubscriptionWrite, handler: controller.AdminUpdateSubscriptionPlan},
+	{method: http.MethodPatch, path: "/plans/:id", permission: authz.SubscriptionWrite, handler: controller.AdminUpdateSubscriptionPlanStatus},
+	{method: http.MethodPost, path: "/bind", permission: authz.SubscriptionWrite, handler: controller.AdminBindSubscription},
+	{method: http.MethodPost, path: "/plans/:id/subscriptions/reset", permission: authz.SubscriptionWrite, handler: controller.AdminResetPlanSubscriptions},
+	{method: http.MethodGet, path: "/users/:id/subscriptions", permission: authz.SubscriptionRead, handler: controller.AdminListUserSubscriptions},
+	{method: http.MethodPost, path: "/users/:id/subscriptions", permission: authz.SubscriptionWrite, handler: controller.AdminCreateUserSubscription},
+	{method: http.MethodPost, path: "/users/:id/subscriptions/reset", permission: authz.SubscriptionWrite, handler: controller.AdminResetUserSubscriptionsByPlan},
+	{method: http.MethodPost, path: "/user_subscriptions/:id/invalidate", permission: authz.SubscriptionWrite, handler: controller.AdminInvalidateUserSubscription},
+	{method: http.MethodDelete, path: "/user_subscriptions/:id", permission: authz.SubscriptionWrite, handler: controller.AdminDeleteUserSubscription},
+}
+
+var optionPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.OptionRead, handler: controller.GetOptions},
+	{method: http.MethodPut, path: "/", permission: authz.OptionWrite, handler: controller.UpdateOption},
+	{method: http.MethodGet, path: "/revisions", permission: authz.OptionRead, handler: controller.GetOptionRevisions},
+	{method: http.MethodGet, path: "/revisions/:id", permission: authz.OptionRead, handler: controller.GetOptionRevision},
+	{method: http.MethodPost, path: "/revisions/:id/rollback", permission: authz.OptionWrite, handler: controller.RollbackOptionRevision},
+	{method: http.MethodGet, path: "/change_sets", permission: authz.OptionRead, handler: controller.GetOptionChangeSets},
+	{method: http.MethodPost, path: "/change_sets", permission: authz.OptionWrite, handler: controller.CreateOptionChangeSet},
+	{method: http.MethodGet, path: "/change_sets/:id", permission: authz.OptionRead, handler: controller.GetOptionChangeSet},
+	{method: http.MethodPut, path: "/change_sets/:id", permission: authz.OptionWrite, handler: controller.UpdateOptionChangeSet},
+	{method: http.MethodPost, path: "/change_sets/:id/apply", permission: authz.OptionWrite, handler: controller.ApplyOptionChangeSet},
+	{method: http.MethodDelete, path: "/change_sets/:id", permission: authz.OptionWrite, handler: controller.DiscardOptionChangeSet},
+	{method: http.MethodPost, path: "/ldap/test", permission: authz.OptionWrite, handler: controller.TestLDAPConnection},
+	{method: http.MethodPost, path: "/payment_compliance", permission: authz.OptionWrite, handler: controller.ConfirmPaymentCompliance},
+	{method: http.MethodGet, path: "/channel_affinity_cache", permission: authz.OptionRead, handler: controller.GetChannelAffinityCacheStats},
+	{method: http.MethodDelete, path: "/channel_affinity_cache", permission: authz.OptionWrite, handler: controller.ClearChannelAffinityCache},
+	{method: http.MethodPost, path: "/rest_model_ratio", permission: authz.OptionWrite, handler: controller.ResetModelRatio},
+	{method: http.MethodPost, path: "/billing_expr/simulate", permission: authz.OptionRead, handler: controller.SimulateBillingExpr},
+	{method: http.MethodGet, path: "/waffo-pancake/catalog", permission: authz.OptionRead, handler: controller.ListWaffoPancakeCatalog},
+	{method: http.MethodPost, path: "/waffo-pancake/pair", permission: authz.OptionWrite, handler: controller.CreateWaffoPancakePair},
+	{method: http.MethodPost, path: "/waffo-pancake/save", permission: authz.OptionWrite, handler: controller.SaveWaffoPancake},
+	{method: http.MethodPost, path: "/waffo-pancake/subscription-product", permission: authz.OptionWrite, handler: controller.CreateWaffoPancakeSubscriptionProduct},
+	{method: http.MethodGet, path: "/waffo-pancake/subscription-product-options", permission: authz.OptionRead, handler: controller.ListWaffoPancakeSubscriptionProductOptions},
+}
+
+var redemptionPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.RedemptionRead, handler: controller.GetAllRedemptions},
+	{method: http.MethodGet, path: "/search", permission: authz.RedemptionRead, handler: controller.SearchRedemptions},
+	{method: http.MethodGet, path: "/:id", permission: authz.RedemptionRead, handler: controller.GetRedemption},
+	{method: http.MethodPost, path: "/", permission: authz.RedemptionWrite, handler: controller.AddRedemption},
+	{method: http.MethodPut, path: "/", permission: authz.RedemptionWrite, handler: controller.UpdateRedemption},
+	{method: http.MethodDelete, path: "/invalid", permission: authz.RedemptionWrite, handler: controller.DeleteInvalidRedemption},
+	{method: http.MethodDelete, path: "/:id", permission: authz.RedemptionWrite, handler: controller.DeleteRedemption},
+}
+
+var systemTaskPermissionRoutes = []permissionRoute{
+	{method: http.MethodPost, path: "/log-cleanup", permission: authz.SystemTaskWrite, handler: controller.CreateLogCleanupSystemTask},
+	{method: http.MethodPost, path: "/billing-reprice", permission: authz.SystemTaskWrite, handler: controller.CreateBillingRepriceSystemTask},
+	{method: http.MethodPost, path: "/currency-rate-refresh", permission: authz.SystemTaskWrite, handler: controller.RefreshCurrencyRatesSystemTask},
+	{method: http.MethodGet, path: "/list", permission: authz.SystemTaskRead, handler: controller.ListSystemTasks},
+	{method: http.MethodGet, path: "/current", permission: authz.SystemTaskRead, handler: controller.GetCurrentSystemTask},
+	{method: http.MethodGet, path: "/:task_id", permission: authz.SystemTaskRead, handler: controller.GetSystemTask},
+}
+
+var systemWebhookPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/events", permission: authz.WebhookRead, handler: controller.GetSystemWebhookEvents},
+	{method: http.MethodGet, path: "/subscriptions", permission: authz.WebhookRead, handler: controller.GetSystemWebhookSubscriptions},
+	{method: http.MethodPost, path: "/subscriptions", permission: authz.WebhookWrite, handler: controller.CreateSystemWebhookSubscription},
+	{method: http.MethodPut, path: "/subscriptions/:id", permission: authz.WebhookWrite, handler: controller.UpdateSystemWebhookSubscription},
+	{method: http.MethodPost, path: "/subscriptions/:id/secret", permission: authz.WebhookWrite, handler: controller.RotateSystemWebhookSubscriptionSecret},
+	{method: http.MethodDelete, path: "/subscriptions/:id", permission: authz.WebhookWrite, handler: controller.DeleteSystemWebhookSubscription},
+	{method: http.MethodGet, path: "/deliveries", permission: authz.WebhookRead, handler: controller.GetSystemWebhookDeliveries},
+	{method: http.MethodGet, path: "/deliveries/:id", permission: authz.WebhookRead, handler: controller.GetSystemWebhookDelivery},
+	{method: http.MethodPost, path: "/deliveries/:id/redeliver", permission: authz.WebhookWrite, handler: controller.RedeliverSystemWebhookDelivery},
+}
+
+var wasmPluginPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.WasmPluginRead, handler: controller.GetWasmPlugins},
+	{method: http.MethodPost, path: "/", permission: authz.WasmPluginWrite, handler: controller.CreateWasmPlugin},
+	{method: http.MethodPut, path: "/:id", permission: authz.WasmPluginWrite, handler: controller.UpdateWasmPlugin},
+	{method: http.MethodDelete, path: "/:id", permission: authz.WasmPluginWrite, handler: controller.DeleteWasmPlugin},
+	{method: http.MethodGet, path: "/:id/versions", permission: authz.WasmPluginRead, handler: controller.GetWasmPluginVersions},
+	{method: http.MethodPost, path: "/:id/versions", permission: authz.WasmPluginWrite, handler: controller.UploadWasmPluginVersion},
+	{method: http.MethodPost, path: "/:id/versions/:version/activate", permission: authz.WasmPluginWrite, handler: controller.ActivateWasmPluginVersion},
+	{method: http.MethodPost, path: "/:id/test", permission: authz.WasmPluginWrite, handler: controller.TestWasmPlugin},
+}
+
+var prefillGroupPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.ModelRead, handler: controller.GetPrefillGroups},
+	{method: http.MethodPost, path: "/", permission: authz.ModelWrite, handler: controller.CreatePrefillGroup},
+	{method: http.MethodPut, path: "/", permission: authz.ModelWrite, handler: controller.UpdatePrefillGroup},
+	{method: http.MethodDelete, path: "/:id", permission: authz.ModelWrite, handler: controller.DeletePrefillGroup},
+}
+
+var vendorPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/", permission: authz.ModelRead, handler: controller.GetAllVendors},
+	{method: http.MethodGet, path: "/search", permission: authz.ModelRead, handler: controller.SearchVendors},
+	{method: http.MethodGet, path: "/:id", permission: authz.ModelRead, handler: controller.GetVendorMeta},
+	{method: http.MethodPost, path: "/", permission: authz.ModelWrite, handler: controller.CreateVendorMeta},
+	{method: http.MethodPut, path: "/", permission: authz.ModelWrite, handler: controller.UpdateVendorMeta},
+	{method: http.MethodDelete, path: "/:id", permission: authz.ModelWrite, handler: controller.DeleteVendorMeta},
+}
+
+var modelPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/sync_upstream/preview", permission: authz.ModelRead, handler: controller.SyncUpstreamPreview},
+	{method: http.MethodPost, path: "/sync_upstream", permission: authz.ModelWrite, handler: controller.SyncUpstreamModels},
+	{method: http.MethodGet, path: "/missing", permission: authz.ModelRead, handler: controller.GetMissingModels},
+	{method: http.MethodGet, path: "/", permission: authz.ModelRead, handler: controller.GetAllModelsMeta},
+	{method: http.MethodGet, path: "/search", permission: authz.ModelRead, handler: controller.SearchModelsMeta},
+	{method: http.MethodGet, path: "/:id", permission: authz.ModelRead, handler: controller.GetModelMeta},
+	{method: http.MethodPost, path: "/", permission: authz.ModelWrite, handler: controller.CreateModelMeta},
+	{method: http.MethodPut, path: "/", permission: authz.ModelWrite, handler: controller.UpdateModelMeta},
+	{method: http.MethodDelete, path: "/:id", permission: authz.ModelWrite, handler: controller.DeleteModelMeta},
+}
+
+var deploymentPermissionRoutes = []permissionRoute{
+	{method: http.MethodGet, path: "/settings", permission: authz.DeploymentRead, handler: controller.GetModelDeploymentSettings},
+	{method: http.MethodPost, path: "/settings/test-connection", permission: authz.DeploymentRead, handler: controller.TestIoNetConnection},
+	{method: http.MethodGet, path: "/", permission: authz.DeploymentRead, handler: controller.GetAllDeployments},
+	{method: http.MethodGet, path: "/search", permission: authz.DeploymentRead, handler: controller.SearchDeployments},
+	{method: http.MethodPost, path: "/test-connection", permission: authz.DeploymentRead, handler: controller.TestIoNetConnection},
+	{method: http.MethodGet, path: "/hardware-types", permission: authz.DeploymentRead, handler: controller.GetHardwareTypes},
+	{method: http.MethodGet, path: "/locations", permission: authz.DeploymentRead, handler: controller.GetLocations},
+	{method: http.MethodGet, path: "/available-replicas", permission: authz.DeploymentRead, handler: controller.GetAvailableReplicas},
+	{method: http.MethodPost, path: "/price-estimation", permission: authz.DeploymentRead, handler: controller.GetPriceEstimation},
+	{method: http.MethodGet, path: "/check-name", permission: authz.DeploymentRead, handler: controller.CheckClusterNameAvailability},
+	{method: http.MethodPost, path: "/", permission: authz.DeploymentWrite, handler: controller.CreateDeployment},
+	{method: http.MethodGet, path: "/:id", permission: authz.DeploymentRead, handler: controller.GetDeployment},
+	{method: http.MethodGet, path: "/:id/logs", permission: authz.DeploymentRead, handler: controller.GetDeploymentLogs},
+	{method: http.MethodGet, path: "/:id/containers", permission: authz.DeploymentRead, handler: controller.ListDeploymentContainers},
+	{method: http.MethodGet, path: "/:id/containers/:container_id", permission: authz.DeploymentRead, handler: controller.GetContainerDetails},
+	{method: http.MethodPut, path: "/:id", permission: authz.DeploymentWrite, handler: controller.UpdateDeployment},
+	{method: http.MethodPut, path: "/:id/name", permission: authz.DeploymentWrite, handler: controller.UpdateDeploymentName},
+	{method: http.MethodPost, path: "/:id/extend", permission: authz.DeploymentWrite, handler: controller.ExtendDeployment},
+	{method: http.MethodDelete, path: "/:id", permission: authz.DeploymentWrite, handler: controller.DeleteDeployment},
+}
@@ -0,0 +1,38 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetScimRouter registers the SCIM 2.0 provisioning API used by identity
+// providers. It is authenticated by the dedicated SCIM bearer token instead of
+// dashboard sessions.
+func SetScimRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(middleware.RouteTag("scim"))
+	scimRouter.Use(middleware.BodyStorageCleanup())
+	scimRouter.Use(middleware.GlobalAPIRateLimit())
+	scimRouter.Use(middleware.DisableCache())
+	scimRouter.Use(middleware.ScimAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.ScimServiceProviderConfig)
+		scimRouter.GET("/ResourceTypes", controller.ScimResourceTypes)
+
+		scimRouter.GET("/Users", controller.ListScimUsers)
+		scimRouter.POST("/Users", controller.CreateScimUser)
+		scimRouter.GET("/Users/:id", controller.GetScimUser)
+		scimRouter.PUT("/Users/:id", controller.ReplaceScimUser)
+		scimRouter.PATCH("/Users/:id", controller.PatchScimUser)
+		scimRouter.DELETE("/Users/:id", controller.DeleteScimUser)
+
+		scimRouter.GET("/Groups", controller.ListScimGroups)
+		scimRouter.POST("/Groups", controller.CreateScimGroup)
+		scimRouter.GET("/Groups/:id", controller.GetScimGroup)
+		scimRouter.PUT("/Groups/:id", controller.ReplaceScimGroup)
+		scimRouter.PATCH("/Groups/:id", controller.PatchScimGroup)
+		scimRouter.DELETE("/Groups/:id", controller.DeleteScimGroup)
+	}
+}
@@ -16,6 +16,13 @@ func SetVideoRouter(router *gin.Engine) {
 		videoProxyRouter.GET("/videos/:task_id/content", controller.VideoProxy)
 	}
 
+	// Re-hosted task results: authorized by the signed URL instead of a token
+	videoArtifactRouter := router.Group("/v1")
+	videoArtifactRouter.Use(middleware.RouteTag("relay"))
+	{
+		videoArtifactRouter.GET("/videos/:task_id/artifact", controller.VideoArtifact)
+	}
+
 	videoV1Router := router.Group("/v1")
 	videoV1Router.Use(middleware.RouteTag("relay"))
 	videoV1Router.Use(middleware.TokenAuth(), middleware.Distribute())
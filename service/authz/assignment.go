@@ -1,20 +1,48 @@
 package authz
 
-import "github.com/QuantumNous/new-api/common"
+import (
+	"strings"
 
-// resolveSubjectRoles returns the role keys assigned to a subject. The mapping
-// is derived from the caller's system role.
+	"github.com/QuantumNous/new-api/common"
+)
+
+// resolveSubjectRoles returns the role keys assigned to a subject: the built-in
+// role derived from the caller's system role plus any custom roles assigned to
+// the user.
 var resolveSubjectRoles = func(userID int, systemRole int) []string {
+	var roles []string
 	switch {
 	case systemRole >= common.RoleRootUser:
-		return []string{BuiltInRoleRoot}
+		roles = append(roles, BuiltInRoleRoot)
 	case systemRole >= common.RoleAdminUser:
-		return []string{BuiltInRoleAdmin}
-	default:
-		return nil
+		roles = append(roles, BuiltInRoleAdmin)
 	}
+	return append(roles, UserRoleKeys(userID)...)
 }
 
 // managedRoleKey is the role whose baseline per-user overrides are expressed
 // relative to.
 const managedRoleKey = BuiltInRoleAdmin
+
+// UserRoleKeys returns the enabled custom roles assigned to a user.
+func UserRoleKeys(userID int) []string {
+	e := currentEnforcer()
+	if e == nil {
+		return nil
+	}
+	assignments, err := e.GetFilteredGroupingPolicy(0, UserSubject(userID))
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(assignments))
+	for _, assignment := range assignments {
+		if len(assignment) < 2 {
+			continue
+		}
+		key := strings.TrimPrefix(assignment[1], RoleSubject(""))
+		if _, ok := customRoleSpec(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
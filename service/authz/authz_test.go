@@ -97,15 +97,13 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 
 	assert.True(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelWrite))
-	assert.Equal(t, PermissionsMap{
-		ResourceChannel: {
-			ActionRead:           true,
-			ActionOperate:        true,
-			ActionWrite:          false,
-			ActionSensitiveWrite: true,
-			ActionSecretView:     false,
-		},
-	}, ExplicitUserPermissions(42))
+	assert.Equal(t, map[string]bool{
+		ActionRead:           true,
+		ActionOperate:        true,
+		ActionWrite:          false,
+		ActionSensitiveWrite: true,
+		ActionSecretView:     false,
+	}, ExplicitUserPermissions(42)[ResourceChannel])
 	assert.Equal(t, PermissionsMap{
 		ResourceChannel: {
 			ActionSensitiveWrite: true,
@@ -125,15 +123,13 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 		ActionSecretView:     false,
 	}}))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
-	assert.Equal(t, PermissionsMap{
-		ResourceChannel: {
-			ActionRead:           true,
-			ActionOperate:        true,
-			ActionWrite:          true,
-			ActionSensitiveWrite: false,
-			ActionSecretView:     false,
-		},
-	}, ExplicitUserPermissions(42))
+	assert.Equal(t, map[string]bool{
+		ActionRead:           true,
+		ActionOperate:        true,
+		ActionWrite:          true,
+		ActionSensitiveWrite: false,
+		ActionSecretView:     false,
+	}, ExplicitUserPermissions(42)[ResourceChannel])
 	assert.Empty(t, ExplicitUserOverrides(42))
 }
 
@@ -227,3 +223,117 @@ func TestCapabilitiesUseCatalogShape(t *testing.T) {
 	assert.False(t, capabilities[ResourceChannel][ActionSensitiveWrite])
 	assert.False(t, capabilities[ResourceChannel][ActionSecretView])
 }
+
+func TestCustomRoleGrantsCommonUser(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateCustomRole(CustomRole{
+		Key:        "support",
+		Name:       "Support agent",
+		QuotaLimit: 1000,
+		Grants: PermissionsMap{
+			ResourceUser: {ActionRead: true, ActionQuota: true, ActionManage: false},
+			ResourceLog:  {ActionRead: true},
+			"unknown":    {ActionRead: true},
+		},
+	}))
+	assert.ErrorIs(t, CreateCustomRole(CustomRole{Key: "support", Name: "Again"}), ErrRoleExists)
+	assert.Error(t, CreateCustomRole(CustomRole{Key: BuiltInRoleAdmin, Name: "Admin"}))
+
+	assert.False(t, Can(5, common.RoleCommonUser, UserRead))
+	require.NoError(t, SetUserRoles(5, []string{"support"}))
+	assert.Equal(t, []string{"support"}, UserRoleKeys(5))
+	assert.True(t, Can(5, common.RoleCommonUser, UserRead))
+	assert.True(t, Can(5, common.RoleCommonUser, LogRead))
+	assert.False(t, Can(5, common.RoleCommonUser, UserManage))
+	assert.False(t, Can(5, common.RoleCommonUser, ChannelRead))
+
+	limit, ok := QuotaAdjustLimit(5, common.RoleCommonUser)
+	assert.True(t, ok)
+	assert.Equal(t, 1000, limit)
+	limit, ok = QuotaAdjustLimit(6, common.RoleAdminUser)
+	assert.True(t, ok)
+	assert.Equal(t, 0, limit)
+	_, ok = QuotaAdjustLimit(7, common.RoleCommonUser)
+	assert.False(t, ok)
+
+	require.NoError(t, UpdateCustomRole(CustomRole{
+		Key:    "support",
+		Name:   "Support agent",
+		Grants: PermissionsMap{ResourceUser: {ActionRead: true}},
+	}))
+	assert.False(t, Can(5, common.RoleCommonUser, LogRead))
+	_, ok = QuotaAdjustLimit(5, common.RoleCommonUser)
+	assert.False(t, ok)
+
+	require.NoError(t, DeleteCustomRole("support"))
+	assert.Empty(t, UserRoleKeys(5))
+	assert.False(t, Can(5, common.RoleCommonUser, UserRead))
+	var count int64
+	require.NoError(t, db.Model(&model.CasbinRule{}).Where("v0 = ? OR v1 = ?", UserSubject(5), RoleSubject("support")).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestCoversCapabilitiesRequiresSubset(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+	require.NoError(t, CreateCustomRole(CustomRole{
+		Key:    "support",
+		Name:   "Support agent",
+		Grants: PermissionsMap{ResourceUser: {ActionRead: true, ActionWrite: true}},
+	}))
+	require.NoError(t, CreateCustomRole(CustomRole{
+		Key:    "auditor",
+		Name:   "Auditor",
+		Grants: PermissionsMap{ResourceLog: {ActionRead: true}},
+	}))
+	require.NoError(t, SetUserRoles(5, []string{"support"}))
+	require.NoError(t, SetUserRoles(6, []string{"auditor"}))
+
+	assert.True(t, CoversCapabilities(5, common.RoleCommonUser, 7, common.RoleCommonUser))
+	assert.False(t, CoversCapabilities(5, common.RoleCommonUser, 6, common.RoleCommonUser),
+		"a support agent must not manage a user holding permissions it lacks")
+	require.NoError(t, SetUserRoles(6, []string{"support"}))
+	assert.True(t, CoversCapabilities(5, common.RoleCommonUser, 6, common.RoleCommonUser))
+}
+
+func TestSetUserRolesRejectsUnknownRole(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	assert.ErrorIs(t, SetUserRoles(5, []string{BuiltInRoleAdmin}), ErrRoleNotFound)
+	assert.ErrorIs(t, SetUserRoles(5, []string{"missing"}), ErrRoleNotFound)
+	assert.Empty(t, UserRoleKeys(5))
+}
+
+func TestClearUserAuthorizationRemovesRoleAssignments(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+	require.NoError(t, CreateCustomRole(CustomRole{
+		Key:    "auditor",
+		Name:   "Auditor",
+		Grants: PermissionsMap{ResourceLog: {ActionRead: true}},
+	}))
+	require.NoError(t, SetUserRoles(8, []string{"auditor"}))
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return ClearUserAuthorizationInTx(tx, 8)
+	}))
+	require.NoError(t, ReloadPolicy())
+
+	assert.False(t, Can(8, common.RoleCommonUser, LogRead))
+}
+
+func TestAdminBaselineCoversMigratedResources(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	for _, permission := range []Permission{UserRead, UserQuota, TokenRead, LogRead, RedemptionWrite, TopUpWrite, SubscriptionWrite, ModelWrite, DeploymentWrite} {
+		assert.True(t, Can(2, common.RoleAdminUser, permission), permission)
+	}
+	for _, permission := range []Permission{OptionRead, OptionWrite, SystemTaskWrite, RoleManage} {
+		assert.False(t, Can(2, common.RoleAdminUser, permission), permission)
+		assert.True(t, Can(1, common.RoleRootUser, permission), permission)
+	}
+}
@@ -0,0 +1,289 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/model"
+	"gorm.io/gorm"
+)
+
+var (
+	customRolesMu sync.RWMutex
+	customRoles   []RoleSpec
+)
+
+var customRoleKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+)
+
+// CustomRole is the editable definition of a role created by an operator.
+// Grants lists the permissions of the role; entries that are false or unknown
+// are ignored.
+type CustomRole struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Sort        int            `json:"sort"`
+	QuotaLimit  int            `json:"quota_limit"`
+	Grants      PermissionsMap `json:"grants"`
+}
+
+func currentPolicyDB() *gorm.DB {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	return policyDB
+}
+
+// loadCustomRoles refreshes the in-memory copy of the enabled custom roles.
+func loadCustomRoles(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	var rows []model.AuthzRole
+	if err := db.Where("built_in = ? AND enabled = ?", false, true).Order("sort asc, id asc").Find(&rows).Error; err != nil {
+		return err
+	}
+	specs := make([]RoleSpec, 0, len(rows))
+	for _, row := range rows {
+		if isBuiltInRole(row.Key) {
+			continue
+		}
+		specs = append(specs, RoleSpec{
+			Key:         row.Key,
+			Name:        row.Name,
+			Description: row.Description,
+			Sort:        row.Sort,
+			QuotaLimit:  row.QuotaLimit,
+		})
+	}
+	customRolesMu.Lock()
+	customRoles = specs
+	customRolesMu.Unlock()
+	return nil
+}
+
+func customRoleSpecs() []RoleSpec {
+	customRolesMu.RLock()
+	defer customRolesMu.RUnlock()
+	return append([]RoleSpec(nil), customRoles...)
+}
+
+func customRoleSpec(roleKey string) (RoleSpec, bool) {
+	customRolesMu.RLock()
+	defer customRolesMu.RUnlock()
+	for _, spec := range customRoles {
+		if spec.Key == roleKey {
+			return spec, true
+		}
+	}
+	return RoleSpec{}, false
+}
+
+func (role *CustomRole) validate() error {
+	role.Key = strings.TrimSpace(role.Key)
+	role.Name = strings.TrimSpace(role.Name)
+	if !customRoleKeyPattern.MatchString(role.Key) {
+		return fmt.Errorf("invalid role key %q: use lowercase letters, digits, '-' and '_'", role.Key)
+	}
+	if isBuiltInRole(role.Key) {
+		return fmt.Errorf("role %s is built in and cannot be changed", role.Key)
+	}
+	if role.Name == "" {
+		return errors.New("role name is required")
+	}
+	if role.QuotaLimit < 0 {
+		return errors.New("quota limit cannot be negative")
+	}
+	return nil
+}
+
+// CreateCustomRole stores a new custom role together with its grants.
+func CreateCustomRole(role CustomRole) error {
+	return saveCustomRole(role, true)
+}
+
+// UpdateCustomRole replaces the definition and grants of an existing custom
+// role. Users keep their assignment to the role.
+func UpdateCustomRole(role CustomRole) error {
+	return saveCustomRole(role, false)
+}
+
+func saveCustomRole(role CustomRole, create bool) error {
+	if err := role.validate(); err != nil {
+		return err
+	}
+	db := currentPolicyDB()
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing model.AuthzRole
+		if err := tx.Where(&model.AuthzRole{Key: role.Key}).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if create && existing.Id != 0 {
+			return ErrRoleExists
+		}
+		if !create && existing.Id == 0 {
+			return ErrRoleNotFound
+		}
+		if create {
+			if err := tx.Create(&model.AuthzRole{
+				Key:         role.Key,
+				Name:        role.Name,
+				Description: role.Description,
+				Enabled:     true,
+				Sort:        role.Sort,
+				QuotaLimit:  role.QuotaLimit,
+			}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"name":        role.Name,
+			"description": role.Description,
+			"sort":        role.Sort,
+			"quota_limit": role.QuotaLimit,
+		}).Error; err != nil {
+			return err
+		}
+		subject := RoleSubject(role.Key)
+		if err := tx.Where("ptype = ? AND v0 = ?", "p", subject).Delete(&model.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		rules := make([]model.CasbinRule, 0)
+		for _, permission := range grantedPermissions(role.Grants) {
+			rules = append(rules, newRule("p", []string{subject, permission.Resource, permission.Action, EffectAllow}))
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(&rules).Error
+	})
+	if err != nil {
+		return err
+	}
+	return ReloadPolicy()
+}
+
+// DeleteCustomRole removes a custom role, its grants and every assignment of
+// the role to users.
+func DeleteCustomRole(roleKey string) error {
+	if isBuiltInRole(roleKey) {
+		return fmt.Errorf("role %s is built in and cannot be deleted", roleKey)
+	}
+	db := currentPolicyDB()
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(&model.AuthzRole{Key: roleKey}).Where("built_in = ?", false).Delete(&model.AuthzRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		subject := RoleSubject(roleKey)
+		if err := tx.Where("ptype = ? AND v0 = ?", "p", subject).Delete(&model.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("ptype = ? AND v1 = ?", "g", subject).Delete(&model.CasbinRule{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return ReloadPolicy()
+}
+
+// SetUserRoles replaces the custom roles assigned to a user. Built-in roles
+// follow the system role of the user and cannot be assigned here.
+func SetUserRoles(userID int, roleKeys []string) error {
+	seen := make(map[string]bool, len(roleKeys))
+	keys := make([]string, 0, len(roleKeys))
+	for _, key := range roleKeys {
+		if seen[key] {
+			continue
+		}
+		if _, ok := customRoleSpec(key); !ok {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, key)
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	db := currentPolicyDB()
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := clearUserRolesInTx(tx, userID); err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		rules := make([]model.CasbinRule, 0, len(keys))
+		for _, key := range keys {
+			rules = append(rules, newRule("g", []string{UserSubject(userID), RoleSubject(key)}))
+		}
+		return tx.Create(&rules).Error
+	})
+	if err != nil {
+		return err
+	}
+	return ReloadPolicy()
+}
+
+func clearUserRolesInTx(tx *gorm.DB, userID int) error {
+	return tx.Where("ptype = ? AND v0 = ?", "g", UserSubject(userID)).Delete(&model.CasbinRule{}).Error
+}
+
+// QuotaAdjustLimit reports whether the subject may adjust user quota and the
+// largest total adjustment allowed per day; a limit of 0 means unlimited.
+// Built-in roles are never limited. Among custom roles granting the permission
+// the most generous limit applies, and a grant that only comes from a
+// per-user override is not limited.
+func QuotaAdjustLimit(userID int, systemRole int) (int, bool) {
+	if !Can(userID, systemRole, UserQuota) {
+		return 0, false
+	}
+	e := currentEnforcer()
+	limit := 0
+	for _, role := range resolveSubjectRoles(userID, systemRole) {
+		spec, ok := roleSpec(role)
+		if !ok {
+			continue
+		}
+		if spec.BuiltIn {
+			return 0, true
+		}
+		if e == nil || !roleBaselineAllows(e, role, UserQuota) {
+			continue
+		}
+		if spec.QuotaLimit == 0 {
+			return 0, true
+		}
+		if spec.QuotaLimit > limit {
+			limit = spec.QuotaLimit
+		}
+	}
+	return limit, true
+}
+
+func grantedPermissions(grants PermissionsMap) []Permission {
+	permissions := make([]Permission, 0)
+	for _, permission := range AllPermissions() {
+		if grants[permission.Resource][permission.Action] {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
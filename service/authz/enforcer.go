@@ -14,6 +14,7 @@ import (
 var (
 	enforcerMu sync.RWMutex
 	enforcer   *casbin.SyncedEnforcer
+	policyDB   *gorm.DB
 )
 
 const modelText = `
@@ -23,6 +24,9 @@ r = sub, obj, act
 [policy_definition]
 p = sub, obj, act, eft
 
+[role_definition]
+g = _, _
+
 [policy_effect]
 e = some(where (p.eft == allow))
 
@@ -52,8 +56,12 @@ func Init(db *gorm.DB) error {
 
 	enforcerMu.Lock()
 	enforcer = e
+	policyDB = db
 	enforcerMu.Unlock()
 
+	if err := loadCustomRoles(db); err != nil {
+		return err
+	}
 	if !common.IsMasterNode {
 		return nil
 	}
@@ -72,6 +80,9 @@ func ReloadPolicy() error {
 	if enforcer == nil {
 		return fmt.Errorf("authz enforcer is not initialized")
 	}
+	if err := loadCustomRoles(policyDB); err != nil {
+		return err
+	}
 	return enforcer.LoadPolicy()
 }
 
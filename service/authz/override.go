@@ -90,12 +90,21 @@ func ClearUserPermissionsInTx(tx *gorm.DB, userID int) error {
 	return nil
 }
 
+// ClearUserAuthorization removes the per-user overrides and the custom role
+// assignments of a user.
 func ClearUserAuthorization(userID int) error {
-	return ClearUserPermissions(userID)
+	if err := ClearUserPermissions(userID); err != nil {
+		return err
+	}
+	_, err := currentEnforcer().RemoveFilteredGroupingPolicy(0, UserSubject(userID))
+	return err
 }
 
 func ClearUserAuthorizationInTx(tx *gorm.DB, userID int) error {
-	return ClearUserPermissionsInTx(tx, userID)
+	if err := ClearUserPermissionsInTx(tx, userID); err != nil {
+		return err
+	}
+	return clearUserRolesInTx(tx, userID)
 }
 
 // ExplicitUserPermissions returns the effective permission matrix for the
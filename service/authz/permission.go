@@ -1,6 +1,10 @@
 package authz
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // Permission identifies a single action on a resource.
 type Permission struct {
@@ -8,6 +12,21 @@ type Permission struct {
 	Action   string
 }
 
+// String formats the permission as a resource:action scope.
+func (p Permission) String() string {
+	return p.Resource + ":" + p.Action
+}
+
+// ParsePermission parses a resource:action scope into a registered permission.
+func ParsePermission(scope string) (Permission, error) {
+	resource, action, ok := strings.Cut(strings.TrimSpace(scope), ":")
+	permission := Permission{Resource: resource, Action: action}
+	if !ok || !isKnownPermission(permission) {
+		return Permission{}, fmt.Errorf("unknown permission scope %q", scope)
+	}
+	return permission, nil
+}
+
 // PermissionsMap is a resource -> action -> allowed lookup.
 type PermissionsMap map[string]map[string]bool
 
@@ -50,6 +50,18 @@ func Capabilities(userID int, systemRole int) PermissionsMap {
 	return result
 }
 
+// CoversCapabilities reports whether the actor holds every permission granted
+// to the target, so managing the target cannot be used to reach permissions
+// the actor does not have.
+func CoversCapabilities(actorID int, actorRole int, targetID int, targetRole int) bool {
+	for _, permission := range AllPermissions() {
+		if Can(targetID, targetRole, permission) && !Can(actorID, actorRole, permission) {
+			return false
+		}
+	}
+	return true
+}
+
 func roleBaselineAllows(e *casbin.SyncedEnforcer, roleKey string, permission Permission) bool {
 	effect, ok := explicitSubjectEffect(e, RoleSubject(roleKey), permission)
 	return ok && effect == EffectAllow
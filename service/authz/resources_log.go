@@ -0,0 +1,20 @@
+package authz
+
+const ResourceLog = "log"
+
+var LogRead = Permission{Resource: ResourceLog, Action: ActionRead}
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceLog,
+		LabelKey: "Logs and Statistics",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read logs",
+				DescriptionKey: "View the usage logs, task records and quota statistics of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package authz
+
+const (
+	ResourceModel      = "model"
+	ResourceDeployment = "deployment"
+)
+
+var (
+	ModelRead  = Permission{Resource: ResourceModel, Action: ActionRead}
+	ModelWrite = Permission{Resource: ResourceModel, Action: ActionWrite}
+
+	DeploymentRead  = Permission{Resource: ResourceDeployment, Action: ActionRead}
+	DeploymentWrite = Permission{Resource: ResourceDeployment, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceModel,
+		LabelKey: "Models and Pricing",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read models",
+				DescriptionKey: "View model metadata, vendors, groups and prefill groups.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit models",
+				DescriptionKey: "Edit model metadata, vendors and prefill groups, and sync models from upstream.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceDeployment,
+		LabelKey: "Deployments",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read deployments",
+				DescriptionKey: "View model deployments, their logs and containers.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Manage deployments",
+				DescriptionKey: "Create, update, extend and delete model deployments.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
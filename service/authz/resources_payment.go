@@ -0,0 +1,75 @@
+package authz
+
+const (
+	ResourceRedemption   = "redemption"
+	ResourceTopUp        = "topup"
+	ResourceSubscription = "subscription"
+)
+
+var (
+	RedemptionRead  = Permission{Resource: ResourceRedemption, Action: ActionRead}
+	RedemptionWrite = Permission{Resource: ResourceRedemption, Action: ActionWrite}
+
+	TopUpRead  = Permission{Resource: ResourceTopUp, Action: ActionRead}
+	TopUpWrite = Permission{Resource: ResourceTopUp, Action: ActionWrite}
+
+	SubscriptionRead  = Permission{Resource: ResourceSubscription, Action: ActionRead}
+	SubscriptionWrite = Permission{Resource: ResourceSubscription, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceRedemption,
+		LabelKey: "Redemption Codes",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read redemption codes",
+				DescriptionKey: "View and search redemption codes.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit redemption codes",
+				DescriptionKey: "Create, edit and delete redemption codes.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceTopUp,
+		LabelKey: "Top-ups",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read top-ups",
+				DescriptionKey: "View the top-up orders of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Complete top-ups",
+				DescriptionKey: "Manually mark pending top-up orders as paid.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSubscription,
+		LabelKey: "Subscriptions",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read subscriptions",
+				DescriptionKey: "View subscription plans and the subscriptions of users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Manage subscriptions",
+				DescriptionKey: "Edit plans and bind, reset, invalidate or delete user subscriptions.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -0,0 +1,105 @@
+package authz
+
+const (
+	ResourceOption     = "option"
+	ResourceSystemTask = "system_task"
+	ResourceRole       = "role"
+	ResourceWebhook    = "webhook"
+	ResourceWasmPlugin = "wasm_plugin"
+)
+
+var (
+	OptionRead  = Permission{Resource: ResourceOption, Action: ActionRead}
+	OptionWrite = Permission{Resource: ResourceOption, Action: ActionWrite}
+
+	SystemTaskRead  = Permission{Resource: ResourceSystemTask, Action: ActionRead}
+	SystemTaskWrite = Permission{Resource: ResourceSystemTask, Action: ActionWrite}
+
+	RoleManage = Permission{Resource: ResourceRole, Action: ActionManage}
+
+	WebhookRead  = Permission{Resource: ResourceWebhook, Action: ActionRead}
+	WebhookWrite = Permission{Resource: ResourceWebhook, Action: ActionWrite}
+
+	WasmPluginRead  = Permission{Resource: ResourceWasmPlugin, Action: ActionRead}
+	WasmPluginWrite = Permission{Resource: ResourceWasmPlugin, Action: ActionWrite}
+)
+
+// The resources below were root only before they were modelled here, so no
+// built-in role except root receives them by default.
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceOption,
+		LabelKey: "System Settings",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read settings",
+				DescriptionKey: "View system settings, their revision history and change sets.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit settings",
+				DescriptionKey: "Change system settings, roll back revisions and apply change sets.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSystemTask,
+		LabelKey: "System Tasks",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read system tasks",
+				DescriptionKey: "View maintenance tasks such as log cleanup.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Start system tasks",
+				DescriptionKey: "Start maintenance tasks such as log cleanup.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceRole,
+		LabelKey: "Roles",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionManage,
+				LabelKey:       "Manage roles",
+				DescriptionKey: "Create custom roles and assign them to users. Holders can grant themselves any permission.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceWebhook,
+		LabelKey: "System Webhooks",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read system webhooks",
+				DescriptionKey: "View system webhook subscriptions and their delivery log.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit system webhooks",
+				DescriptionKey: "Create, change and delete system webhook subscriptions and redeliver events.",
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceWasmPlugin,
+		LabelKey: "WASM Plugins",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read WASM plugins",
+				DescriptionKey: "View WASM plugins and their versions and run test invocations.",
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit WASM plugins",
+				DescriptionKey: "Create, change and delete WASM plugins, upload versions and switch the active version.",
+			},
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package authz
+
+const (
+	ResourceUser  = "user"
+	ResourceToken = "token"
+
+	ActionQuota  = "quota"
+	ActionManage = "manage"
+)
+
+var (
+	UserRead   = Permission{Resource: ResourceUser, Action: ActionRead}
+	UserWrite  = Permission{Resource: ResourceUser, Action: ActionWrite}
+	UserQuota  = Permission{Resource: ResourceUser, Action: ActionQuota}
+	UserManage = Permission{Resource: ResourceUser, Action: ActionManage}
+
+	TokenRead  = Permission{Resource: ResourceToken, Action: ActionRead}
+	TokenWrite = Permission{Resource: ResourceToken, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceUser,
+		LabelKey: "User Management",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read users",
+				DescriptionKey: "View user lists, details, bindings and two-factor statistics.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit users",
+				DescriptionKey: "Edit profiles, enable or disable users, and clear bindings, passkeys or two-factor authentication.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionQuota,
+				LabelKey:       "Adjust user quota",
+				DescriptionKey: "Add, subtract or override user quota, up to the quota limit of the role.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionManage,
+				LabelKey:       "Manage user accounts",
+				DescriptionKey: "Create, delete and demote users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceToken,
+		LabelKey: "Token Management",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read user tokens",
+				DescriptionKey: "View the API tokens of other users without their keys.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Revoke user tokens",
+				DescriptionKey: "Delete API tokens that belong to other users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -6,7 +6,8 @@ const (
 )
 
 // RoleSpec describes a role. A superuser role is allowed every permission
-// without an explicit policy entry.
+// without an explicit policy entry. QuotaLimit caps the total user quota a
+// subject may adjust through the role per day; 0 leaves it unlimited.
 type RoleSpec struct {
 	Key         string
 	Name        string
@@ -14,6 +15,7 @@ type RoleSpec struct {
 	BuiltIn     bool
 	Superuser   bool
 	Sort        int
+	QuotaLimit  int
 }
 
 var builtInRoles = []RoleSpec{
@@ -37,34 +39,50 @@ var builtInRoles = []RoleSpec{
 
 // RoleDescriptor exposes a role together with its baseline grant matrix.
 type RoleDescriptor struct {
-	Key       string         `json:"key"`
-	Name      string         `json:"name"`
-	BuiltIn   bool           `json:"built_in"`
-	Superuser bool           `json:"superuser"`
-	Grants    PermissionsMap `json:"grants"`
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	BuiltIn     bool           `json:"built_in"`
+	Superuser   bool           `json:"superuser"`
+	QuotaLimit  int            `json:"quota_limit"`
+	Grants      PermissionsMap `json:"grants"`
 }
 
-// Roles returns the role descriptors with their baseline grants.
+// Roles returns the built-in and custom role descriptors with their baseline
+// grants.
 func Roles() []RoleDescriptor {
-	result := make([]RoleDescriptor, 0, len(builtInRoles))
-	for _, spec := range builtInRoles {
+	specs := append(append([]RoleSpec(nil), builtInRoles...), customRoleSpecs()...)
+	result := make([]RoleDescriptor, 0, len(specs))
+	for _, spec := range specs {
 		result = append(result, RoleDescriptor{
-			Key:       spec.Key,
-			Name:      spec.Name,
-			BuiltIn:   spec.BuiltIn,
-			Superuser: spec.Superuser,
-			Grants:    roleGrants(spec),
+			Key:         spec.Key,
+			Name:        spec.Name,
+			Description: spec.Description,
+			BuiltIn:     spec.BuiltIn,
+			Superuser:   spec.Superuser,
+			QuotaLimit:  spec.QuotaLimit,
+			Grants:      roleGrants(spec),
 		})
 	}
 	return result
 }
 
+// roleGrants returns the grant matrix of a role. Built-in baselines come from
+// the registry, custom roles only have the policies stored for them.
 func roleGrants(spec RoleSpec) PermissionsMap {
+	e := currentEnforcer()
 	grants := make(PermissionsMap, len(registry))
 	for _, resource := range registry {
 		actions := make(map[string]bool, len(resource.Actions))
 		for _, action := range resource.Actions {
-			actions[action.Action] = spec.Superuser || actionHasRole(action, spec.Key)
+			allowed := spec.Superuser || actionHasRole(action, spec.Key)
+			if !spec.BuiltIn {
+				allowed = e != nil && roleBaselineAllows(e, spec.Key, Permission{
+					Resource: resource.Resource,
+					Action:   action.Action,
+				})
+			}
+			actions[action.Action] = allowed
 		}
 		grants[resource.Resource] = actions
 	}
@@ -77,7 +95,16 @@ func roleSpec(roleKey string) (RoleSpec, bool) {
 			return spec, true
 		}
 	}
-	return RoleSpec{}, false
+	return customRoleSpec(roleKey)
+}
+
+func isBuiltInRole(roleKey string) bool {
+	for _, spec := range builtInRoles {
+		if spec.Key == roleKey {
+			return true
+		}
+	}
+	return false
 }
 
 func isSuperuserRole(roleKey string) bool {
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+)
+
+const (
+	billingRepriceBatchSize = 500
+	// billingRepriceMaxUsers caps the per-user breakdown kept in the task
+	// result; users are ranked by the absolute revenue delta.
+	billingRepriceMaxUsers = 100
+)
+
+// BillingRepricePayload describes a what-if repricing run over a window of
+// consume logs. Candidate maps are keyed by model name (group ratios by group
+// name); models absent from every map keep the pricing recorded on the log.
+type BillingRepricePayload struct {
+	StartTimestamp  int64              `json:"start_timestamp"`
+	EndTimestamp    int64              `json:"end_timestamp"`
+	ModelName       string             `json:"model_name,omitempty"`
+	BillingExpr     map[string]string  `json:"billing_expr,omitempty"`
+	ModelRatio      map[string]float64 `json:"model_ratio,omitempty"`
+	CompletionRatio map[string]float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      map[string]float64 `json:"cache_ratio,omitempty"`
+	ModelPrice      map[string]float64 `json:"model_price,omitempty"`
+	GroupRatio      map[string]float64 `json:"group_ratio,omitempty"`
+}
+
+// BillingRepriceDelta is the old/new revenue of one model or one user.
+type BillingRepriceDelta struct {
+	Model    string `json:"model,omitempty"`
+	UserId   int    `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Requests int64  `json:"requests"`
+	OldQuota int64  `json:"old_quota"`
+	NewQuota int64  `json:"new_quota"`
+	Delta    int64  `json:"delta"`
+}
+
+type BillingRepriceResult struct {
+	Scanned  int64                 `json:"scanned"`
+	Repriced int64                 `json:"repriced"`
+	Skipped  int64                 `json:"skipped"`
+	OldQuota int64                 `json:"old_quota"`
+	NewQuota int64                 `json:"new_quota"`
+	Delta    int64                 `json:"delta"`
+	Models   []BillingRepriceDelta `json:"models"`
+	Users    []BillingRepriceDelta `json:"users"`
+}
+
+type billingRepriceHandler struct{}
+
+func (billingRepriceHandler) Type() string { return model.SystemTaskTypeBillingReprice }
+
+func (billingRepriceHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	runBillingRepriceTask(ctx, task, runnerID)
+}
+
+func init() {
+	RegisterSystemTaskHandler(billingRepriceHandler{})
+}
+
+func ValidateBillingRepricePayload(payload *BillingRepricePayload) error {
+	if payload.StartTimestamp <= 0 || payload.EndTimestamp <= payload.StartTimestamp {
+		return errors.New("a valid start_timestamp and end_timestamp are required")
+	}
+	if len(payload.BillingExpr)+len(payload.ModelRatio)+len(payload.CompletionRatio)+
+		len(payload.CacheRatio)+len(payload.ModelPrice)+len(payload.GroupRatio) == 0 {
+		return errors.New("at least one candidate expression or ratio is required")
+	}
+	for modelName, exprStr := range payload.BillingExpr {
+		if err := billing_setting.SmokeTestExpr(exprStr); err != nil {
+			return fmt.Errorf("billing_expr[%s]: %w", modelName, err)
+		}
+	}
+	for name, ratios := range map[string]map[string]float64{
+		"model_ratio":      payload.ModelRatio,
+		"completion_ratio": payload.CompletionRatio,
+		"cache_ratio":      payload.CacheRatio,
+		"model_price":      payload.ModelPrice,
+		"group_ratio":      payload.GroupRatio,
+	} {
+		for key, value := range ratios {
+			if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+				return fmt.Errorf("%s[%s] must be a non-negative number", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// StartBillingRepriceTask enqueues a repricing run. The returned bool is false
+// when another repricing run is still active and was returned instead.
+func StartBillingRepriceTask(payload BillingRepricePayload) (*model.SystemTask, bool, error) {
+	if err := ValidateBillingRepricePayload(&payload); err != nil {
+		return nil, false, err
+	}
+	return EnqueueSystemTask(model.SystemTaskTypeBillingReprice, payload)
+}
+
+func runBillingRepriceTask(ctx context.Context, task *model.SystemTask, runnerID string) {
+	payload := BillingRepricePayload{}
+	if err := task.DecodePayload(&payload); err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	if err := ValidateBillingRepricePayload(&payload); err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	total, err := model.CountConsumeLogWindow(ctx, payload.StartTimestamp, payload.EndTimestamp, payload.ModelName)
+	if err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+
+	report := NewSystemTaskProgressReporter(task, runnerID)
+	acc := newBillingRepriceAccumulator()
+	afterId := 0
+	for {
+		if ctx.Err() != nil {
+			logSystemTaskLockError(ctx, task, model.ErrSystemTaskLockLost)
+			return
+		}
+		logs, err := model.GetConsumeLogWindowBatch(ctx, payload.StartTimestamp, payload.EndTimestamp, payload.ModelName, afterId, billingRepriceBatchSize)
+		if err != nil {
+			failSystemTask(task, runnerID, err)
+			return
+		}
+		if len(logs) == 0 {
+			break
+		}
+		for _, log := range logs {
+			// A log whose expression cannot be replayed is counted as skipped
+			// rather than failing the whole run.
+			newQuota, ok, err := repriceConsumeLog(log, &payload)
+			acc.add(log, newQuota, ok && err == nil)
+		}
+		afterId = logs[len(logs)-1].Id
+		report(int(acc.result.Scanned), int(max(total, acc.result.Scanned)))
+	}
+	report(int(acc.result.Scanned), int(acc.result.Scanned))
+
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, acc.finish(), ""); err != nil {
+		logSystemTaskLockError(ctx, task, err)
+	}
+}
+
+// billingLogPricing is the pricing a consume log was (or would be) charged
+// with. Exactly one of expr, modelPrice > 0 or the ratios is in effect.
+type billingLogPricing struct {
+	expr               string
+	modelPrice         float64
+	modelRatio         float64
+	completionRatio    float64
+	cacheRatio         float64
+	cacheCreationRatio float64
+	groupRatio         float64
+}
+
+// repriceConsumeLog returns the quota the log would have been charged under
+// the candidate pricing. Only the token charge is recomputed: the difference
+// between candidate and recorded pricing is applied to the charged quota, so
+// surcharges the log does not fully record (tool calls, audio, other ratios)
+// carry over unchanged. ok is false when the log holds no pricing to replay.
+func repriceConsumeLog(log *model.Log, payload *BillingRepricePayload) (newQuota int, ok bool, err error) {
+	other, _ := common.StrToMap(log.Other)
+	recorded, ok := recordedBillingLogPricing(other)
+	if !ok {
+		return log.Quota, false, nil
+	}
+	candidate := candidateBillingLogPricing(recorded, log, other, payload)
+	if candidate == recorded {
+		return log.Quota, true, nil
+	}
+
+	usage := billingLogUsage(log, other)
+	billedAt := time.Unix(log.CreatedAt, 0)
+	recordedQuota, err := recorded.quota(usage, billedAt)
+	if err != nil {
+		return log.Quota, false, err
+	}
+	candidateQuota, err := candidate.quota(usage, billedAt)
+	if err != nil {
+		return log.Quota, false, err
+	}
+	newQuota = log.Quota + common.QuotaRound(candidateQuota-recordedQuota)
+	if newQuota < 0 {
+		newQuota = 0
+	}
+	return newQuota, true, nil
+}
+
+func recordedBillingLogPricing(other map[string]interface{}) (billingLogPricing, bool) {
+	groupRatio, ok := otherFloat(other, "group_ratio")
+	if !ok {
+		return billingLogPricing{}, false
+	}
+	pricing := billingLogPricing{
+		groupRatio:         groupRatio,
+		modelPrice:         otherFloatOr(other, "model_price", -1),
+		modelRatio:         otherFloatOr(other, "model_ratio", 0),
+		completionRatio:    otherFloatOr(other, "completion_ratio", 1),
+		cacheRatio:         otherFloatOr(other, "cache_ratio", 1),
+		cacheCreationRatio: otherFloatOr(other, "cache_creation_ratio", 1),
+	}
+	if mode, _ := other["billing_mode"].(string); mode == billing_setting.BillingModeTieredExpr {
+		encoded, _ := other["expr_b64"].(string)
+		exprBytes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(exprBytes) == 0 {
+			return billingLogPricing{}, false
+		}
+		pricing.expr = string(exprBytes)
+		return pricing, true
+	}
+	if _, ok := other["model_ratio"]; !ok && pricing.modelPrice <= 0 {
+		return billingLogPricing{}, false
+	}
+	return pricing, true
+}
+
+func candidateBillingLogPricing(recorded billingLogPricing, log *model.Log, other map[string]interface{}, payload *BillingRepricePayload) billingLogPricing {
+	candidate := recorded
+	if exprStr, ok := payload.BillingExpr[log.ModelName]; ok {
+		candidate.expr = exprStr
+	} else {
+		ratioChanged := false
+		if value, ok := payload.ModelRatio[log.ModelName]; ok {
+			candidate.modelRatio, candidate.modelPrice, ratioChanged = value, -1, true
+		}
+		if value, ok := payload.CompletionRatio[log.ModelName]; ok {
+			candidate.completionRatio, ratioChanged = value, true
+		}
+		if value, ok := payload.CacheRatio[log.ModelName]; ok {
+			candidate.cacheRatio, ratioChanged = value, true
+		}
+		if value, ok := payload.ModelPrice[log.ModelName]; ok {
+			candidate.modelPrice, ratioChanged = value, true
+		}
+		if ratioChanged {
+			candidate.expr = ""
+		}
+	}
+	// A user-specific group ratio overrides the group ratio, so candidate
+	// group ratios only apply to logs charged with the plain group ratio.
+	if userGroupRatio := otherFloatOr(other, "user_group_ratio", -1); userGroupRatio < 0 {
+		if value, ok := payload.GroupRatio[log.Group]; ok {
+			candidate.groupRatio = value
+		}
+	}
+	return candidate
+}
+
+// quota recomputes the token charge of a log. Time helpers in expressions
+// read billedAt, the time the log was written, so time-of-day pricing is
+// replayed as it applied to the original request.
+func (p billingLogPricing) quota(usage *dto.Usage, billedAt time.Time) (float64, error) {
+	isClaude := usage.UsageSemantic == "anthropic"
+	if p.expr != "" {
+		params := BuildTieredTokenParams(usage, isClaude, billingexpr.UsedVars(p.expr))
+		result, err := billingexpr.ComputeTieredQuotaWithRequest(&billingexpr.BillingSnapshot{
+			ExprString:   p.expr,
+			ExprHash:     billingexpr.ExprHashString(p.expr),
+			GroupRatio:   p.groupRatio,
+			QuotaPerUnit: common.QuotaPerUnit,
+			ExprVersion:  billingexpr.ExprVersion(p.expr),
+		}, params, billingexpr.RequestInput{Time: billedAt})
+		if err != nil {
+			return 0, err
+		}
+		return result.ActualQuotaBeforeGroup * p.groupRatio, nil
+	}
+	if p.modelPrice > 0 {
+		return p.modelPrice * common.QuotaPerUnit * p.groupRatio, nil
+	}
+
+	cacheTokens := float64(usage.PromptTokensDetails.CachedTokens)
+	cacheCreationTokens := float64(usage.PromptTokensDetails.CacheCreationTokensTotal())
+	baseTokens := float64(usage.PromptTokens)
+	if !isClaude {
+		baseTokens -= cacheTokens + cacheCreationTokens
+	}
+	if baseTokens < 0 {
+		baseTokens = 0
+	}
+	tokens := baseTokens + cacheTokens*p.cacheRatio + cacheCreationTokens*p.cacheCreationRatio +
+		float64(usage.CompletionTokens)*p.completionRatio
+	return tokens * p.modelRatio * p.groupRatio, nil
+}
+
+func billingLogUsage(log *model.Log, other map[string]interface{}) *dto.Usage {
+	usage := &dto.Usage{
+		PromptTokens:     log.PromptTokens,
+		CompletionTokens: log.CompletionTokens,
+	}
+	usage.PromptTokensDetails.CachedTokens = int(otherFloatOr(other, "cache_tokens", 0))
+	usage.PromptTokensDetails.CachedCreationTokens = int(otherFloatOr(other, "cache_creation_tokens", 0))
+	if isClaude, _ := other["claude"].(bool); isClaude {
+		usage.UsageSemantic = "anthropic"
+		usage.ClaudeCacheCreation5mTokens = int(otherFloatOr(other, "cache_creation_tokens_5m", 0))
+		usage.ClaudeCacheCreation1hTokens = int(otherFloatOr(other, "cache_creation_tokens_1h", 0))
+	}
+	return usage
+}
+
+func otherFloat(other map[string]interface{}, key string) (float64, bool) {
+	switch value := other[key].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+func otherFloatOr(other map[string]interface{}, key string, fallback float64) float64 {
+	if value, ok := otherFloat(other, key); ok {
+		return value
+	}
+	return fallback
+}
+
+type billingRepriceAccumulator struct {
+	result BillingRepriceResult
+	models map[string]*BillingRepriceDelta
+	users  map[int]*BillingRepriceDelta
+}
+
+func newBillingRepriceAccumulator() *billingRepriceAccumulator {
+	return &billingRepriceAccumulator{
+		models: map[string]*BillingRepriceDelta{},
+		users:  map[int]*BillingRepriceDelta{},
+	}
+}
+
+func (a *billingRepriceAccumulator) add(log *model.Log, newQuota int, ok bool) {
+	a.result.Scanned++
+	if !ok {
+		a.result.Skipped++
+		return
+	}
+	if newQuota != log.Quota {
+		a.result.Repriced++
+	}
+	modelDelta := a.models[log.ModelName]
+	if modelDelta == nil {
+		modelDelta = &BillingRepriceDelta{Model: log.ModelName}
+		a.models[log.ModelName] = modelDelta
+	}
+	userDelta := a.users[log.UserId]
+	if userDelta == nil {
+		userDelta = &BillingRepriceDelta{UserId: log.UserId, Username: log.Username}
+		a.users[log.UserId] = userDelta
+	}
+	a.result.OldQuota += int64(log.Quota)
+	a.result.NewQuota += int64(newQuota)
+	a.result.Delta = a.result.NewQuota - a.result.OldQuota
+	for _, delta := range []*BillingRepriceDelta{modelDelta, userDelta} {
+		delta.Requests++
+		delta.OldQuota += int64(log.Quota)
+		delta.NewQuota += int64(newQuota)
+		delta.Delta = delta.NewQuota - delta.OldQuota
+	}
+}
+
+func (a *billingRepriceAccumulator) finish() BillingRepriceResult {
+	result := a.result
+	result.Models = sortedBillingRepriceDeltas(a.models, 0)
+	result.Users = sortedBillingRepriceDeltas(a.users, billingRepriceMaxUsers)
+	return result
+}
+
+// sortedBillingRepriceDeltas orders rows by the absolute delta, largest first,
+// and keeps at most limit rows when limit is positive.
+func sortedBillingRepriceDeltas[K comparable](rows map[K]*BillingRepriceDelta, limit int) []BillingRepriceDelta {
+	sorted := make([]BillingRepriceDelta, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		di, dj := abs64(sorted[i].Delta), abs64(sorted[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+		if sorted[i].Model != sorted[j].Model {
+			return sorted[i].Model < sorted[j].Model
+		}
+		return sorted[i].UserId < sorted[j].UserId
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
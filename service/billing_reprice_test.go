@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func billingRepriceLog(userId int, username string, group string, modelName string, quota int, other map[string]interface{}) *model.Log {
+	return &model.Log{
+		UserId:           userId,
+		Username:         username,
+		Group:            group,
+		ModelName:        modelName,
+		Type:             model.LogTypeConsume,
+		CreatedAt:        1_700_000_100,
+		Quota:            quota,
+		PromptTokens:     1000,
+		CompletionTokens: 500,
+		Other:            common.MapToJsonStr(other),
+	}
+}
+
+func ratioLogOther(modelRatio, completionRatio, groupRatio, userGroupRatio float64) map[string]interface{} {
+	return map[string]interface{}{
+		"model_ratio":      modelRatio,
+		"completion_ratio": completionRatio,
+		"group_ratio":      groupRatio,
+		"user_group_ratio": userGroupRatio,
+		"model_price":      -1,
+		"cache_tokens":     0,
+		"cache_ratio":      1,
+	}
+}
+
+func TestRepriceConsumeLog(t *testing.T) {
+	payload := &BillingRepricePayload{
+		ModelRatio: map[string]float64{"gpt-a": 2},
+		GroupRatio: map[string]float64{"vip": 1},
+		BillingExpr: map[string]string{
+			"tiered": `tier("base", p * 4 + c * 4)`,
+		},
+	}
+
+	// Ratio change: (1000 + 500*2) * 2 instead of * 1
+	newQuota, ok, err := repriceConsumeLog(billingRepriceLog(1, "alice", "default", "gpt-a", 2000, ratioLogOther(1, 2, 1, -1)), payload)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 4000, newQuota)
+
+	// Group ratio change keeps the unrecorded surcharge (quota 2500 vs 1000 from tokens)
+	newQuota, ok, err = repriceConsumeLog(billingRepriceLog(2, "bob", "vip", "gpt-b", 2500, ratioLogOther(1, 2, 0.5, -1)), payload)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 3500, newQuota)
+
+	// A user-specific group ratio wins over the candidate group ratio
+	newQuota, ok, err = repriceConsumeLog(billingRepriceLog(2, "bob", "vip", "gpt-b", 1000, ratioLogOther(1, 2, 0.5, 0.5)), payload)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1000, newQuota)
+
+	// Tiered expression replaced by the candidate expression
+	other := ratioLogOther(0, 0, 1, -1)
+	other["billing_mode"] = "tiered_expr"
+	other["expr_b64"] = base64.StdEncoding.EncodeToString([]byte(`tier("base", p * 2 + c * 4)`))
+	oldQuota := common.QuotaRound(4000 / 1_000_000.0 * common.QuotaPerUnit)
+	newQuota, ok, err = repriceConsumeLog(billingRepriceLog(1, "alice", "default", "tiered", oldQuota, other), payload)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, common.QuotaRound(6000/1_000_000.0*common.QuotaPerUnit), newQuota)
+
+	// Time helpers read the log time (22:15 UTC), not the time of the replay
+	payload.BillingExpr["tiered"] = `tier("base", (hour("UTC") == 22 ? 1 : 3) * (p * 4 + c * 4))`
+	newQuota, ok, err = repriceConsumeLog(billingRepriceLog(1, "alice", "default", "tiered", oldQuota, other), payload)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, common.QuotaRound(6000/1_000_000.0*common.QuotaPerUnit), newQuota)
+
+	// Logs without recorded pricing cannot be replayed
+	_, ok, err = repriceConsumeLog(billingRepriceLog(1, "alice", "default", "gpt-a", 10, map[string]interface{}{}), payload)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBillingRepriceTaskAggregatesDeltas(t *testing.T) {
+	truncate(t)
+	logs := []*model.Log{
+		billingRepriceLog(1, "alice", "default", "gpt-a", 2000, ratioLogOther(1, 2, 1, -1)),
+		billingRepriceLog(2, "bob", "default", "gpt-a", 2000, ratioLogOther(1, 2, 1, -1)),
+		billingRepriceLog(2, "bob", "default", "gpt-b", 700, ratioLogOther(1, 2, 1, -1)),
+		billingRepriceLog(2, "bob", "default", "gpt-b", 30, nil),
+	}
+	for _, log := range logs {
+		require.NoError(t, model.LOG_DB.Create(log).Error)
+	}
+	outside := billingRepriceLog(1, "alice", "default", "gpt-a", 2000, ratioLogOther(1, 2, 1, -1))
+	outside.CreatedAt = 1_600_000_000
+	require.NoError(t, model.LOG_DB.Create(outside).Error)
+
+	_, _, err := StartBillingRepriceTask(BillingRepricePayload{StartTimestamp: 1_700_000_000})
+	require.Error(t, err)
+
+	task, created, err := StartBillingRepriceTask(BillingRepricePayload{
+		StartTimestamp: 1_700_000_000,
+		EndTimestamp:   1_700_001_000,
+		ModelRatio:     map[string]float64{"gpt-a": 1.5},
+	})
+	require.NoError(t, err)
+	require.True(t, created)
+	claimed, ok, err := model.ClaimSystemTask(task.ID, task.Type, "runner-a", common.GetTimestamp()+60)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	runBillingRepriceTask(context.Background(), claimed, "runner-a")
+
+	finished, err := model.GetSystemTaskByTaskID(task.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, model.SystemTaskStatusSucceeded, finished.Status, finished.Error)
+	var result BillingRepriceResult
+	require.NoError(t, common.UnmarshalJsonStr(finished.Result, &result))
+
+	assert.Equal(t, int64(4), result.Scanned)
+	assert.Equal(t, int64(2), result.Repriced)
+	assert.Equal(t, int64(1), result.Skipped)
+	assert.Equal(t, int64(4700), result.OldQuota)
+	assert.Equal(t, int64(6700), result.NewQuota)
+	assert.Equal(t, int64(2000), result.Delta)
+
+	require.Len(t, result.Models, 2)
+	assert.Equal(t, BillingRepriceDelta{Model: "gpt-a", Requests: 2, OldQuota: 4000, NewQuota: 6000, Delta: 2000}, result.Models[0])
+	assert.Equal(t, BillingRepriceDelta{Model: "gpt-b", Requests: 1, OldQuota: 700, NewQuota: 700}, result.Models[1])
+	require.Len(t, result.Users, 2)
+	assert.Equal(t, "alice", result.Users[0].Username)
+	assert.Equal(t, int64(1000), result.Users[0].Delta)
+	assert.Equal(t, int64(2700), result.Users[1].OldQuota)
+}
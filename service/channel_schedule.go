@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/samber/lo"
+)
+
+// maxChannelScheduleDuration bounds a recurring window so evaluating it stays cheap.
+const maxChannelScheduleDuration = 7 * 24 * time.Hour
+
+type ChannelScheduleRunSummary struct {
+	Evaluated   int `json:"evaluated"`
+	Activated   int `json:"activated"`
+	Deactivated int `json:"deactivated"`
+	Failed      int `json:"failed"`
+}
+
+// ValidateChannelSchedule normalizes and validates a schedule definition.
+func ValidateChannelSchedule(schedule *model.ChannelSchedule) error {
+	if schedule == nil {
+		return errors.New("schedule is required")
+	}
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	if schedule.Kind == "" {
+		schedule.Kind = model.ChannelScheduleKindRecurring
+	}
+	switch schedule.TargetType {
+	case model.ChannelScheduleTargetChannel:
+		if schedule.ChannelId <= 0 {
+			return errors.New("channel_id is required")
+		}
+		schedule.Tag = ""
+	case model.ChannelScheduleTargetTag:
+		schedule.Tag = strings.TrimSpace(schedule.Tag)
+		if schedule.Tag == "" {
+			return errors.New("tag is required")
+		}
+		schedule.ChannelId = 0
+	default:
+		return fmt.Errorf("invalid target_type: %s", schedule.TargetType)
+	}
+	switch schedule.Action {
+	case model.ChannelScheduleActionDisable, model.ChannelScheduleActionEnable:
+		schedule.Priority = nil
+		schedule.Weight = nil
+	case model.ChannelScheduleActionAdjust:
+		if schedule.Priority == nil && schedule.Weight == nil {
+			return errors.New("priority or weight is required for adjust action")
+		}
+	default:
+		return fmt.Errorf("invalid action: %s", schedule.Action)
+	}
+	if _, err := loadChannelScheduleLocation(schedule.Timezone); err != nil {
+		return err
+	}
+	switch schedule.Kind {
+	case model.ChannelScheduleKindRecurring:
+		if _, err := common.ParseCronExpression(schedule.Cron); err != nil {
+			return err
+		}
+		duration := time.Duration(schedule.DurationMinutes) * time.Minute
+		if duration <= 0 || duration > maxChannelScheduleDuration {
+			return fmt.Errorf("duration_minutes must be between 1 and %d", int(maxChannelScheduleDuration/time.Minute))
+		}
+	case model.ChannelScheduleKindMaintenance:
+		if schedule.StartTime <= 0 || schedule.EndTime <= schedule.StartTime {
+			return errors.New("end_time must be after start_time")
+		}
+	default:
+		return fmt.Errorf("invalid kind: %s", schedule.Kind)
+	}
+	return nil
+}
+
+func loadChannelScheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return loc, nil
+}
+
+// ChannelScheduleShouldBeActive reports whether the schedule window covers now.
+// Disabled schedules are never active, so disabling one restores its channels.
+func ChannelScheduleShouldBeActive(schedule *model.ChannelSchedule, now time.Time) (bool, error) {
+	if schedule == nil || !schedule.Enabled {
+		return false, nil
+	}
+	switch schedule.Kind {
+	case model.ChannelScheduleKindMaintenance:
+		ts := now.Unix()
+		return ts >= schedule.StartTime && ts < schedule.EndTime, nil
+	default:
+		loc, err := loadChannelScheduleLocation(schedule.Timezone)
+		if err != nil {
+			return false, err
+		}
+		cron, err := common.ParseCronExpression(schedule.Cron)
+		if err != nil {
+			return false, err
+		}
+		duration := time.Duration(schedule.DurationMinutes) * time.Minute
+		if duration > maxChannelScheduleDuration {
+			duration = maxChannelScheduleDuration
+		}
+		return cron.ActiveWithin(now.In(loc), duration), nil
+	}
+}
+
+// RunChannelSchedulesOnce evaluates every schedule and applies or reverts the
+// ones whose window state changed. Each transition is one DB transaction; the
+// channel cache is rebuilt afterwards and other nodes are told to rebuild theirs.
+func RunChannelSchedulesOnce(ctx context.Context) ChannelScheduleRunSummary {
+	summary := ChannelScheduleRunSummary{}
+	schedules, err := model.GetSchedulableChannelSchedules()
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("channel schedule query failed: %v", err))
+		summary.Failed++
+		return summary
+	}
+	now := time.Now()
+	changed := false
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			break
+		}
+		summary.Evaluated++
+		shouldBeActive, err := ChannelScheduleShouldBeActive(schedule, now)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("channel schedule #%d evaluation failed: %v", schedule.Id, err))
+			summary.Failed++
+			continue
+		}
+		if shouldBeActive == schedule.Active {
+			continue
+		}
+		applied, err := model.ApplyChannelSchedule(schedule, shouldBeActive)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("channel schedule #%d apply failed: %v", schedule.Id, err))
+			summary.Failed++
+			continue
+		}
+		if !applied {
+			continue
+		}
+		changed = true
+		if shouldBeActive {
+			summary.Activated++
+		} else {
+			summary.Deactivated++
+		}
+		logger.LogInfo(ctx, fmt.Sprintf("channel schedule #%d (%s) %s: action=%s target=%s",
+			schedule.Id, schedule.Name, lo.Ternary(shouldBeActive, "activated", "deactivated"), schedule.Action, ChannelScheduleTargetString(schedule)))
+	}
+	if changed {
+		model.BroadcastChannelCacheInvalidation()
+	}
+	return summary
+}
+
+// DeactivateChannelSchedule restores the channels of an active schedule
+// immediately, e.g. before it is deleted.
+func DeactivateChannelSchedule(schedule *model.ChannelSchedule) error {
+	if schedule == nil || !schedule.Active {
+		return nil
+	}
+	applied, err := model.ApplyChannelSchedule(schedule, false)
+	if err != nil {
+		return err
+	}
+	if applied {
+		model.BroadcastChannelCacheInvalidation()
+	}
+	return nil
+}
+
+// ChannelScheduleTargetString renders the schedule target for logs and audit records.
+func ChannelScheduleTargetString(schedule *model.ChannelSchedule) string {
+	if schedule.TargetType == model.ChannelScheduleTargetTag {
+		return "tag:" + schedule.Tag
+	}
+	return fmt.Sprintf("channel:%d", schedule.ChannelId)
+}
@@ -2,11 +2,13 @@ package service
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -115,7 +117,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry, param.RequestPath)
+			channel, _ = getSatisfiedChannel(param, autoGroup, priorityRetry)
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -153,10 +155,40 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			break
 		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry(), param.RequestPath)
+		channel, err = getSatisfiedChannel(param, param.TokenGroup, param.GetRetry())
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
 	}
 	return channel, selectGroup, nil
 }
+
+// getSatisfiedChannel picks a channel of the given priority tier, using the
+// cheapest-first strategy when enabled and weighted random otherwise. Channels
+// already used by this request, such as a multi-key channel whose keys are all
+// rate limited, are skipped while the tier has other candidates.
+func getSatisfiedChannel(param *RetryParam, group string, priorityRetry int) (*model.Channel, error) {
+	tried := triedChannelIds(param.Ctx)
+	if !operation_setting.GetChannelSelectionSetting().CheapestFirstEnabled {
+		return model.GetRandomSatisfiedChannel(group, param.ModelName, priorityRetry, param.RequestPath, tried)
+	}
+	return model.GetCheapestSatisfiedChannel(group, param.ModelName, priorityRetry, param.RequestPath, tried)
+}
+
+// triedChannelIds returns the channels already used by this request.
+func triedChannelIds(c *gin.Context) map[int]bool {
+	if c == nil {
+		return nil
+	}
+	useChannel := c.GetStringSlice("use_channel")
+	if len(useChannel) == 0 {
+		return nil
+	}
+	tried := make(map[int]bool, len(useChannel))
+	for _, id := range useChannel {
+		if channelId, err := strconv.Atoi(id); err == nil {
+			tried[channelId] = true
+		}
+	}
+	return tried
+}
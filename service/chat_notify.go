@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+// telegramAPIBaseURL Telegram Bot API 地址，测试时替换为本地服务
+var telegramAPIBaseURL = "https://api.telegram.org"
+
+var (
+	notifyLineBreakRegexp = regexp.MustCompile(`(?i)<br\s*/?>`)
+	notifyLinkRegexp      = regexp.MustCompile(`(?is)<a\s[^>]*href=["']([^"']+)["'][^>]*>(.*?)</a>`)
+	notifyTagRegexp       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// plainNotifyContent 处理占位符并把邮件用的 HTML 内容转换为纯文本，链接保留为 "文字 (地址)"
+func plainNotifyContent(data dto.Notify) string {
+	content := data.Content
+	for _, value := range data.Values {
+		content = strings.Replace(content, dto.ContentValueParam, fmt.Sprintf("%v", value), 1)
+	}
+	content = notifyLineBreakRegexp.ReplaceAllString(content, "\n")
+	content = notifyLinkRegexp.ReplaceAllStringFunc(content, func(link string) string {
+		match := notifyLinkRegexp.FindStringSubmatch(link)
+		text := strings.TrimSpace(notifyTagRegexp.ReplaceAllString(match[2], ""))
+		if text == "" || text == match[1] {
+			return match[1]
+		}
+		return fmt.Sprintf("%s (%s)", text, match[1])
+	})
+	content = notifyTagRegexp.ReplaceAllString(content, "")
+	return strings.TrimSpace(html.UnescapeString(content))
+}
+
+// feishuCardTemplate 按通知类型选择飞书卡片标题颜色
+func feishuCardTemplate(notifyType string) string {
+	switch notifyType {
+	case dto.NotifyTypeQuotaExceed:
+		return "orange"
+	case dto.NotifyTypeChannelTest:
+		return "red"
+	default:
+		return "blue"
+	}
+}
+
+// signFeishu 飞书/Lark 机器人签名：以 timestamp+"\n"+secret 为密钥对空串做 HMAC-SHA256
+func signFeishu(secret string, timestamp int64) string {
+	h := hmac.New(sha256.New, []byte(strconv.FormatInt(timestamp, 10)+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// signDingTalk 钉钉机器人加签：以 secret 为密钥对 timestamp+"\n"+secret 做 HMAC-SHA256
+func signDingTalk(secret string, timestampMs int64) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestampMs, 10) + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// postChatNotify 以 JSON 发送机器人消息，状态码正常时把响应体交给 check 检查平台错误码
+func postChatNotify(platform string, webhookURL string, payload any, check func(body []byte) error) error {
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %v", platform, err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json; charset=utf-8",
+	}
+	resp, err := doWebhookRequest(context.Background(), webhookURL, headers, payloadBytes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s request failed with status code: %d", platform, resp.StatusCode)
+	}
+	if check == nil {
+		return nil
+	}
+	return check(body)
+}
+
+// checkErrCode 检查钉钉、企业微信的 errcode/errmsg 响应
+func checkErrCode(platform string) func(body []byte) error {
+	return func(body []byte) error {
+		var result struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := common.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse %s response: %v", platform, err)
+		}
+		if result.ErrCode != 0 {
+			return fmt.Errorf("%s request failed: %d %s", platform, result.ErrCode, result.ErrMsg)
+		}
+		return nil
+	}
+}
+
+func sendSlackNotify(webhookURL string, data dto.Notify) error {
+	content := plainNotifyContent(data)
+	payload := map[string]any{
+		// text 用于通知预览，blocks 用于消息正文
+		"text": data.Title,
+		"blocks": []map[string]any{
+			{
+				"type": "header",
+				"text": map[string]any{"type": "plain_text", "text": data.Title},
+			},
+			{
+				"type": "section",
+				"text": map[string]any{"type": "mrkdwn", "text": content},
+			},
+		},
+	}
+	// Slack Incoming Webhook 成功时返回纯文本 ok，失败时返回非 2xx
+	return postChatNotify("slack", webhookURL, payload, nil)
+}
+
+func sendFeishuNotify(webhookURL string, secret string, data dto.Notify) error {
+	payload := map[string]any{
+		"msg_type": "interactive",
+		"card": map[string]any{
+			"header": map[string]any{
+				"title":    map[string]any{"tag": "plain_text", "content": data.Title},
+				"template": feishuCardTemplate(data.Type),
+			},
+			"elements": []map[string]any{
+				{"tag": "markdown", "content": plainNotifyContent(data)},
+			},
+		},
+	}
+	if secret != "" {
+		timestamp := time.Now().Unix()
+		payload["timestamp"] = strconv.FormatInt(timestamp, 10)
+		payload["sign"] = signFeishu(secret, timestamp)
+	}
+	return postChatNotify("feishu", webhookURL, payload, func(body []byte) error {
+		var result struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if err := common.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse feishu response: %v", err)
+		}
+		if result.Code != 0 {
+			return fmt.Errorf("feishu request failed: %d %s", result.Code, result.Msg)
+		}
+		return nil
+	})
+}
+
+func sendDingTalkNotify(webhookURL string, secret string, data dto.Notify) error {
+	finalURL := webhookURL
+	if secret != "" {
+		timestamp := time.Now().UnixMilli()
+		separator := "?"
+		if strings.Contains(finalURL, "?") {
+			separator = "&"
+		}
+		finalURL += fmt.Sprintf("%stimestamp=%d&sign=%s", separator, timestamp, url.QueryEscape(signDingTalk(secret, timestamp)))
+	}
+	payload := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]any{
+			"title": data.Title,
+			"text":  fmt.Sprintf("### %s\n\n%s", data.Title, markdownLineBreaks(plainNotifyContent(data))),
+		},
+	}
+	return postChatNotify("dingtalk", finalURL, payload, checkErrCode("dingtalk"))
+}
+
+func sendWeComNotify(webhookURL string, data dto.Notify) error {
+	payload := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]any{
+			"content": fmt.Sprintf("### %s\n%s", data.Title, plainNotifyContent(data)),
+		},
+	}
+	return postChatNotify("wecom", webhookURL, payload, checkErrCode("wecom"))
+}
+
+func sendTelegramNotify(botToken string, chatId string, data dto.Notify) error {
+	payload := map[string]any{
+		"chat_id":                  chatId,
+		"text":                     fmt.Sprintf("<b>%s</b>\n\n%s", html.EscapeString(data.Title), html.EscapeString(plainNotifyContent(data))),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	apiURL := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(telegramAPIBaseURL, "/"), botToken)
+	err := postChatNotify("telegram", apiURL, payload, func(body []byte) error {
+		var result struct {
+			Ok          bool   `json:"ok"`
+			Description string `json:"description"`
+		}
+		if err := common.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse telegram response: %v", err)
+		}
+		if !result.Ok {
+			return fmt.Errorf("telegram request failed: %s", result.Description)
+		}
+		return nil
+	})
+	if err != nil {
+		// 错误信息可能包含请求地址，避免把机器人令牌写入日志
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), botToken, "***"))
+	}
+	return nil
+}
+
+// markdownLineBreaks 钉钉 markdown 需要两个空格加换行才会换行
+func markdownLineBreaks(content string) string {
+	return strings.ReplaceAll(content, "\n", "  \n")
+}
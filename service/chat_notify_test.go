@@ -0,0 +1,128 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chatNotifyRequest struct {
+	path  string
+	query map[string]string
+	body  map[string]any
+}
+
+// newChatNotifyServer records each request and answers with the given JSON body.
+func newChatNotifyServer(t *testing.T, response string) (*httptest.Server, *[]chatNotifyRequest) {
+	t.Helper()
+	allowLocalWebhooks(t)
+	var requests []chatNotifyRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		require.NoError(t, common.Unmarshal(raw, &body))
+		query := map[string]string{}
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+		requests = append(requests, chatNotifyRequest{path: r.URL.Path, query: query, body: body})
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var testChatNotify = dto.NewNotify(dto.NotifyTypeQuotaExceed, "额度提醒",
+	"{{value}}，剩余 {{value}}。<br/>充值链接：<a href='{{value}}'>{{value}}</a>",
+	[]interface{}{"您的额度即将用尽", "$1.00", "https://example.com/wallet", "https://example.com/wallet"})
+
+func TestPlainNotifyContentStripsHTML(t *testing.T) {
+	assert.Equal(t, "您的额度即将用尽，剩余 $1.00。\n充值链接：https://example.com/wallet", plainNotifyContent(testChatNotify))
+	assert.Equal(t, "a\nopen (https://x.test) & b", plainNotifyContent(dto.Notify{
+		Content: "<p>a</p><BR><a href=\"https://x.test\"><b>open</b></a> &amp; b",
+	}))
+}
+
+func TestSendSlackNotifyUsesBlocks(t *testing.T) {
+	server, requests := newChatNotifyServer(t, "ok")
+	require.NoError(t, sendSlackNotify(server.URL, testChatNotify))
+	require.Len(t, *requests, 1)
+	blocks := (*requests)[0].body["blocks"].([]any)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "header", blocks[0].(map[string]any)["type"])
+	section := blocks[1].(map[string]any)["text"].(map[string]any)
+	assert.Equal(t, "mrkdwn", section["type"])
+	assert.Contains(t, section["text"], "https://example.com/wallet")
+}
+
+func TestSendFeishuNotifySignsCard(t *testing.T) {
+	server, requests := newChatNotifyServer(t, `{"code":0,"msg":"success"}`)
+	require.NoError(t, sendFeishuNotify(server.URL, "feishu-secret", testChatNotify))
+	body := (*requests)[0].body
+	assert.Equal(t, "interactive", body["msg_type"])
+	header := body["card"].(map[string]any)["header"].(map[string]any)
+	assert.Equal(t, "orange", header["template"])
+	timestamp, err := strconv.ParseInt(body["timestamp"].(string), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, signFeishu("feishu-secret", timestamp), body["sign"])
+
+	// Feishu reports failures in the body with HTTP 200
+	server, _ = newChatNotifyServer(t, `{"code":19021,"msg":"sign match fail"}`)
+	err = sendFeishuNotify(server.URL, "wrong", testChatNotify)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sign match fail")
+}
+
+func TestSendDingTalkNotifySignsURL(t *testing.T) {
+	server, requests := newChatNotifyServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	require.NoError(t, sendDingTalkNotify(server.URL+"/robot/send?access_token=abc", "SEC123", testChatNotify))
+	request := (*requests)[0]
+	assert.Equal(t, "abc", request.query["access_token"])
+	timestamp, err := strconv.ParseInt(request.query["timestamp"], 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, signDingTalk("SEC123", timestamp), request.query["sign"])
+	assert.Equal(t, "markdown", request.body["msgtype"])
+
+	server, _ = newChatNotifyServer(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	assert.Error(t, sendDingTalkNotify(server.URL, "SEC123", testChatNotify))
+}
+
+func TestSendWeComNotifyChecksErrCode(t *testing.T) {
+	server, requests := newChatNotifyServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	require.NoError(t, sendWeComNotify(server.URL, testChatNotify))
+	markdown := (*requests)[0].body["markdown"].(map[string]any)
+	assert.Contains(t, markdown["content"], "### 额度提醒")
+
+	server, _ = newChatNotifyServer(t, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	assert.Error(t, sendWeComNotify(server.URL, testChatNotify))
+}
+
+func TestSendTelegramNotifyEscapesHTMLAndHidesToken(t *testing.T) {
+	server, requests := newChatNotifyServer(t, `{"ok":true}`)
+	originalBaseURL := telegramAPIBaseURL
+	t.Cleanup(func() { telegramAPIBaseURL = originalBaseURL })
+	telegramAPIBaseURL = server.URL
+
+	notify := dto.NewNotify(dto.NotifyTypeChannelTest, "<渠道>", "a < b", nil)
+	require.NoError(t, sendTelegramNotify("123:token", "42", notify))
+	request := (*requests)[0]
+	assert.Equal(t, "/bot123:token/sendMessage", request.path)
+	assert.Equal(t, "42", request.body["chat_id"])
+	assert.Equal(t, "HTML", request.body["parse_mode"])
+	assert.Equal(t, "<b>&lt;渠道&gt;</b>\n\na &lt; b", request.body["text"])
+
+	server, _ = newChatNotifyServer(t, `{"ok":false,"description":"chat not found"}`)
+	telegramAPIBaseURL = server.URL
+	err := sendTelegramNotify("123:token", "42", notify)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "chat not found")
+	assert.NotContains(t, err.Error(), "123:token")
+}
@@ -0,0 +1,736 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 声明式配置文档：渠道、分组、倍率与选定的系统设置可导出为 YAML/JSON，
+// 在其他环境中先 diff 预览再原子地 apply。渠道按名称匹配，密钥只以引用或加密形式导出。
+
+// ConfigBundleVersion 是当前配置文档的格式版本
+const ConfigBundleVersion = 1
+
+const (
+	ConfigSecretModeReference = "reference" // 密钥导出为引用，导入时保留目标环境中的密钥
+	ConfigSecretModeEncrypted = "encrypted" // 密钥使用口令加密导出
+
+	configSecretRefKeep      = "keep"
+	configSecretRefEnvPrefix = "env:"
+	// configSecretEnvNamePrefix 是 env: 引用允许读取的环境变量名前缀
+	configSecretEnvNamePrefix = "NEWAPI_CHANNEL_KEY_"
+)
+
+// configBundleGroupOptions 分组相关的系统设置，文档字段 -> 设置项
+var configBundleGroupOptions = map[string]string{
+	"ratio":          "GroupRatio",
+	"group_ratio":    "GroupGroupRatio",
+	"user_usable":    "UserUsableGroups",
+	"special_usable": "group_ratio_setting.group_special_usable_group",
+}
+
+// configBundleRatioOptions 倍率相关的系统设置，文档字段 -> 设置项
+var configBundleRatioOptions = map[string]string{
+	"model_ratio":            "ModelRatio",
+	"model_price":            "ModelPrice",
+	"completion_ratio":       "CompletionRatio",
+	"cache_ratio":            "CacheRatio",
+	"create_cache_ratio":     "CreateCacheRatio",
+	"image_ratio":            "ImageRatio",
+	"audio_ratio":            "AudioRatio",
+	"audio_completion_ratio": "AudioCompletionRatio",
+}
+
+// configBundleOptionShapes 分组设置的取值结构，用于导入时校验；倍率设置均为 模型 -> 数值
+var configBundleOptionShapes = map[string]func() any{
+	"GroupRatio":       func() any { return &map[string]float64{} },
+	"GroupGroupRatio":  func() any { return &map[string]map[string]float64{} },
+	"UserUsableGroups": func() any { return &map[string]string{} },
+	"group_ratio_setting.group_special_usable_group": func() any { return &map[string]map[string]string{} },
+}
+
+// ConfigBundle 是声明式配置文档。省略的部分不受管理：例如没有 channels 时不会创建、修改或删除任何渠道。
+type ConfigBundle struct {
+	Version    int                   `json:"version" yaml:"version"`
+	ExportedAt int64                 `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	SecretSalt string                `json:"secret_salt,omitempty" yaml:"secret_salt,omitempty"` // 加密密钥的 scrypt salt
+	Channels   []ConfigBundleChannel `json:"channels,omitempty" yaml:"channels,omitempty"`
+	Groups     map[string]any        `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Ratios     map[string]any        `json:"ratios,omitempty" yaml:"ratios,omitempty"`
+	Options    map[string]string     `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// ConfigBundleSecret 是渠道密钥的表示：ref 为 "keep"（保留目标环境中的密钥）或 "env:NAME"
+// （读取服务端环境变量，NAME 必须以 NEWAPI_CHANNEL_KEY_ 开头），
+// encrypted 为口令加密后的密文，value 为明文（仅导入时接受，导出永远不会输出）
+type ConfigBundleSecret struct {
+	Ref       string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Encrypted string `json:"encrypted,omitempty" yaml:"encrypted,omitempty"`
+	Value     string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+type ConfigBundleMultiKey struct {
+	Mode     constant.MultiKeyMode `json:"mode" yaml:"mode"`
+	RPMLimit int                   `json:"rpm_limit,omitempty" yaml:"rpm_limit,omitempty"`
+	TPMLimit int                   `json:"tpm_limit,omitempty" yaml:"tpm_limit,omitempty"`
+}
+
+// ConfigBundleChannel 是文档中的一个渠道，按 name 与已有渠道匹配。
+// JSON 类字段（model_mapping、setting 等）以结构化形式书写，也可以直接写 JSON 字符串。
+type ConfigBundleChannel struct {
+	Name               string                `json:"name" yaml:"name"`
+	Type               int                   `json:"type" yaml:"type"`
+	Key                *ConfigBundleSecret   `json:"key,omitempty" yaml:"key,omitempty"`
+	Status             *int                  `json:"status,omitempty" yaml:"status,omitempty"`
+	BaseURL            string                `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	OpenAIOrganization string                `json:"openai_organization,omitempty" yaml:"openai_organization,omitempty"`
+	TestModel          string                `json:"test_model,omitempty" yaml:"test_model,omitempty"`
+	Models             []string              `json:"models" yaml:"models"`
+	Groups             []string              `json:"groups" yaml:"groups"`
+	Tag                string                `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Priority           int64                 `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Weight             uint                  `json:"weight,omitempty" yaml:"weight,omitempty"`
+	AutoBan            *bool                 `json:"auto_ban,omitempty" yaml:"auto_ban,omitempty"`
+	Remark             string                `json:"remark,omitempty" yaml:"remark,omitempty"`
+	Other              string                `json:"other,omitempty" yaml:"other,omitempty"`
+	ModelMapping       any                   `json:"model_mapping,omitempty" yaml:"model_mapping,omitempty"`
+	StatusCodeMapping  any                   `json:"status_code_mapping,omitempty" yaml:"status_code_mapping,omitempty"`
+	Setting            any                   `json:"setting,omitempty" yaml:"setting,omitempty"`
+	ParamOverride      any                   `json:"param_override,omitempty" yaml:"param_override,omitempty"`
+	HeaderOverride     any                   `json:"header_override,omitempty" yaml:"header_override,omitempty"`
+	Settings           any                   `json:"settings,omitempty" yaml:"settings,omitempty"`
+	MultiKey           *ConfigBundleMultiKey `json:"multi_key,omitempty" yaml:"multi_key,omitempty"`
+}
+
+type ConfigBundleExportOptions struct {
+	Secrets    string   `json:"secrets"`    // reference（默认）| encrypted
+	Passphrase string   `json:"passphrase"` // secrets=encrypted 时必填
+	Options    []string `json:"options"`    // 额外导出的系统设置项
+}
+
+type ConfigBundleApplyOptions struct {
+	Passphrase string `json:"passphrase"` // 文档中含加密密钥时必填
+	Prune      bool   `json:"prune"`      // 删除文档中不存在的渠道
+	OperatorId int    `json:"-"`          // 写入设置修改历史
+}
+
+// ConfigBundleChange 是 diff 中的一项变更；update 时 fields 列出变化的字段（不含取值，避免泄露密钥）
+type ConfigBundleChange struct {
+	Kind   string   `json:"kind"`   // channel | group | ratio | option
+	Action string   `json:"action"` // create | update | delete
+	Name   string   `json:"name"`
+	Id     int      `json:"id,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+type ConfigBundlePlan struct {
+	Changes []ConfigBundleChange `json:"changes"`
+	Creates int                  `json:"creates"`
+	Updates int                  `json:"updates"`
+	Deletes int                  `json:"deletes"`
+
+	writes *model.ConfigBundleChanges
+}
+
+func (plan *ConfigBundlePlan) add(change ConfigBundleChange) {
+	plan.Changes = append(plan.Changes, change)
+	switch change.Action {
+	case "create":
+		plan.Creates++
+	case "update":
+		plan.Updates++
+	case "delete":
+		plan.Deletes++
+	}
+}
+
+// ParseConfigBundle 解析 YAML 或 JSON 格式的配置文档
+func ParseConfigBundle(data []byte) (*ConfigBundle, error) {
+	bundle := &ConfigBundle{}
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("invalid config document: %w", err)
+	}
+	if bundle.Version <= 0 {
+		return nil, errors.New("config document is missing version")
+	}
+	if bundle.Version > ConfigBundleVersion {
+		return nil, fmt.Errorf("unsupported config document version %d", bundle.Version)
+	}
+	return bundle, nil
+}
+
+// MarshalConfigBundle 按 format（yaml | json）序列化配置文档
+func MarshalConfigBundle(bundle *ConfigBundle, format string) ([]byte, error) {
+	switch format {
+	case "", "yaml", "yml":
+		return yaml.Marshal(bundle)
+	case "json":
+		return json.MarshalIndent(bundle, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// configBundleJSONValue 把数据库中的 JSON 字符串转为文档中的结构化值，非 JSON 内容原样保留
+func configBundleJSONValue(value string) any {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var decoded any
+	if err := common.UnmarshalJsonStr(value, &decoded); err == nil {
+		switch decoded.(type) {
+		case map[string]any, []any:
+			return decoded
+		}
+	}
+	return value
+}
+
+// configBundleJSONString 是 configBundleJSONValue 的逆操作
+func configBundleJSONString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		data, err := common.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// canonicalJSON 返回 JSON 的规范形式（键排序、去除空白），非 JSON 内容原样返回
+func canonicalJSON(value string) string {
+	var decoded any
+	if err := common.UnmarshalJsonStr(value, &decoded); err != nil {
+		return value
+	}
+	data, err := common.Marshal(decoded)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+func configBundleOptionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func configBundleOptionValue(key string) string {
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	return common.OptionMap[key]
+}
+
+// OptionExists 判断设置项是否已注册
+func OptionExists(key string) bool {
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	_, ok := common.OptionMap[key]
+	return ok
+}
+
+// configBundleOptionAllowed 返回设置项能否出现在文档的 options 中
+func configBundleOptionAllowed(key string) error {
+	if model.IsSensitiveOptionKey(key) || IsPaymentComplianceOptionKey(key) || key == "theme.frontend" {
+		return fmt.Errorf("option %s cannot be managed by config documents", key)
+	}
+	for _, managed := range []map[string]string{configBundleGroupOptions, configBundleRatioOptions} {
+		for section, optionKey := range managed {
+			if optionKey == key {
+				return fmt.Errorf("option %s must be set in the %s section", key, section)
+			}
+		}
+	}
+	if !OptionExists(key) {
+		return fmt.Errorf("unknown option %s", key)
+	}
+	return nil
+}
+
+// ExportConfigBundle 导出当前的渠道、分组、倍率以及 opts.Options 指定的系统设置
+func ExportConfigBundle(opts ConfigBundleExportOptions) (*ConfigBundle, error) {
+	bundle := &ConfigBundle{
+		Version:    ConfigBundleVersion,
+		ExportedAt: common.GetTimestamp(),
+		Channels:   []ConfigBundleChannel{},
+		Groups:     make(map[string]any),
+		Ratios:     make(map[string]any),
+	}
+	var cipher *common.PassphraseCipher
+	switch opts.Secrets {
+	case "", ConfigSecretModeReference:
+	case ConfigSecretModeEncrypted:
+		var err error
+		if cipher, err = common.NewPassphraseCipher(opts.Passphrase, ""); err != nil {
+			return nil, err
+		}
+		bundle.SecretSalt = cipher.Salt()
+	default:
+		return nil, fmt.Errorf("unsupported secrets mode %q", opts.Secrets)
+	}
+
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Id < channels[j].Id })
+	for _, channel := range channels {
+		entry, err := exportConfigBundleChannel(channel, cipher)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", channel.Name, err)
+		}
+		bundle.Channels = append(bundle.Channels, entry)
+	}
+	for section, optionKey := range configBundleGroupOptions {
+		if value := configBundleJSONValue(configBundleOptionValue(optionKey)); value != nil {
+			bundle.Groups[section] = value
+		}
+	}
+	for section, optionKey := range configBundleRatioOptions {
+		if value := configBundleJSONValue(configBundleOptionValue(optionKey)); value != nil {
+			bundle.Ratios[section] = value
+		}
+	}
+	if len(opts.Options) > 0 {
+		bundle.Options = make(map[string]string, len(opts.Options))
+		for _, key := range opts.Options {
+			if err := configBundleOptionAllowed(key); err != nil {
+				return nil, err
+			}
+			bundle.Options[key] = configBundleOptionValue(key)
+		}
+	}
+	return bundle, nil
+}
+
+func exportConfigBundleChannel(channel *model.Channel, cipher *common.PassphraseCipher) (ConfigBundleChannel, error) {
+	entry := ConfigBundleChannel{
+		Name:              channel.Name,
+		Type:              channel.Type,
+		Key:               &ConfigBundleSecret{Ref: configSecretRefKeep},
+		BaseURL:           derefString(channel.BaseURL),
+		Models:            channel.GetModels(),
+		Groups:            channel.GetGroups(),
+		Tag:               channel.GetTag(),
+		Priority:          channel.GetPriority(),
+		Weight:            uint(channel.GetWeight()),
+		AutoBan:           common.GetPointer(channel.GetAutoBan()),
+		Other:             channel.Other,
+		ModelMapping:      configBundleJSONValue(channel.GetModelMapping()),
+		StatusCodeMapping: configBundleJSONValue(channel.GetStatusCodeMapping()),
+		Settings:          configBundleJSONValue(channel.OtherSettings),
+	}
+	if cipher != nil {
+		encrypted, err := cipher.Encrypt(channel.Key)
+		if err != nil {
+			return entry, err
+		}
+		entry.Key = &ConfigBundleSecret{Encrypted: encrypted}
+	}
+	if IsManageableChannelStatus(channel.Status) {
+		entry.Status = common.GetPointer(channel.Status)
+	}
+	if channel.OpenAIOrganization != nil {
+		entry.OpenAIOrganization = *channel.OpenAIOrganization
+	}
+	if channel.TestModel != nil {
+		entry.TestModel = *channel.TestModel
+	}
+	if channel.Remark != nil {
+		entry.Remark = *channel.Remark
+	}
+	if channel.Setting != nil {
+		entry.Setting = configBundleJSONValue(*channel.Setting)
+	}
+	if channel.ParamOverride != nil {
+		entry.ParamOverride = configBundleJSONValue(*channel.ParamOverride)
+	}
+	if channel.HeaderOverride != nil {
+		entry.HeaderOverride = configBundleJSONValue(*channel.HeaderOverride)
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		entry.MultiKey = &ConfigBundleMultiKey{
+			Mode:     channel.ChannelInfo.MultiKeyMode,
+			RPMLimit: channel.ChannelInfo.MultiKeyRPMLimit,
+			TPMLimit: channel.ChannelInfo.MultiKeyTPMLimit,
+		}
+	}
+	return entry, nil
+}
+
+// resolveConfigBundleSecret 返回密钥明文；keep 为 true 表示沿用目标环境中已有的密钥
+func resolveConfigBundleSecret(secret *ConfigBundleSecret, cipher func() (*common.PassphraseCipher, error)) (value string, keep bool, err error) {
+	switch {
+	case secret == nil || secret.Ref == configSecretRefKeep:
+		return "", true, nil
+	case strings.HasPrefix(secret.Ref, configSecretRefEnvPrefix):
+		name := strings.TrimPrefix(secret.Ref, configSecretRefEnvPrefix)
+		// 只允许读取专用前缀的环境变量，避免通过渠道密钥读出 SQL_DSN、SESSION_SECRET 等服务端密钥
+		if !strings.HasPrefix(name, configSecretEnvNamePrefix) || len(name) == len(configSecretEnvNamePrefix) {
+			return "", false, fmt.Errorf("key reference %q must name an environment variable starting with %s", secret.Ref, configSecretEnvNamePrefix)
+		}
+		value = os.Getenv(name)
+		if value == "" {
+			return "", false, fmt.Errorf("environment variable %s referenced by key is empty", name)
+		}
+		return value, false, nil
+	case secret.Ref != "":
+		return "", false, fmt.Errorf("unsupported key reference %q", secret.Ref)
+	case secret.Encrypted != "":
+		c, err := cipher()
+		if err != nil {
+			return "", false, err
+		}
+		value, err = c.Decrypt(secret.Encrypted)
+		return value, false, err
+	case secret.Value != "":
+		return secret.Value, false, nil
+	}
+	return "", true, nil
+}
+
+// buildConfigBundleChannel 在 base（已有渠道的副本或新渠道）上应用文档中的取值
+func buildConfigBundleChannel(base *model.Channel, entry ConfigBundleChannel, key string, keepKey bool) (*model.Channel, error) {
+	channel := base
+	channel.Name = entry.Name
+	channel.Type = entry.Type
+	if !keepKey {
+		channel.Key = key
+	}
+	if entry.Status != nil {
+		if !IsManageableChannelStatus(*entry.Status) {
+			return nil, fmt.Errorf("unsupported status %d", *entry.Status)
+		}
+		channel.Status = *entry.Status
+	}
+	channel.BaseURL = common.GetPointer(entry.BaseURL)
+	channel.OpenAIOrganization = configBundleOptionalString(entry.OpenAIOrganization)
+	channel.TestModel = configBundleOptionalString(entry.TestModel)
+	channel.Models = strings.Join(entry.Models, ",")
+	channel.Group = strings.Join(entry.Groups, ",")
+	if channel.Group == "" {
+		channel.Group = "default"
+	}
+	channel.Tag = configBundleOptionalString(entry.Tag)
+	channel.Priority = common.GetPointer(entry.Priority)
+	channel.Weight = common.GetPointer(entry.Weight)
+	autoBan := 1
+	if entry.AutoBan != nil && !*entry.AutoBan {
+		autoBan = 0
+	}
+	channel.AutoBan = &autoBan
+	channel.Remark = configBundleOptionalString(entry.Remark)
+	channel.Other = entry.Other
+
+	jsonFields := []struct {
+		name   string
+		value  any
+		target **string
+	}{
+		{"model_mapping", entry.ModelMapping, &channel.ModelMapping},
+		{"status_code_mapping", entry.StatusCodeMapping, &channel.StatusCodeMapping},
+		{"setting", entry.Setting, &channel.Setting},
+		{"param_override", entry.ParamOverride, &channel.ParamOverride},
+		{"header_override", entry.HeaderOverride, &channel.HeaderOverride},
+	}
+	for _, field := range jsonFields {
+		value, err := configBundleJSONString(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.name, err)
+		}
+		*field.target = configBundleOptionalString(value)
+	}
+	settings, err := configBundleJSONString(entry.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("settings: %w", err)
+	}
+	channel.OtherSettings = settings
+
+	if entry.MultiKey != nil {
+		channel.ChannelInfo.IsMultiKey = true
+		channel.ChannelInfo.MultiKeyMode = entry.MultiKey.Mode
+		channel.ChannelInfo.MultiKeyRPMLimit = max(entry.MultiKey.RPMLimit, 0)
+		channel.ChannelInfo.MultiKeyTPMLimit = max(entry.MultiKey.TPMLimit, 0)
+	} else {
+		channel.ChannelInfo.IsMultiKey = false
+		channel.ChannelInfo.MultiKeySize = 0
+		channel.ChannelInfo.MultiKeyStatusList = nil
+		channel.ChannelInfo.MultiKeyDisabledReason = nil
+		channel.ChannelInfo.MultiKeyDisabledTime = nil
+	}
+	return channel, nil
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// configBundleChannelChanges 返回 desired 相对 existing 变化的字段
+func configBundleChannelChanges(existing *model.Channel, desired *model.Channel) []string {
+	var fields []string
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	jsonChanged := func(a, b string) bool { return canonicalJSON(a) != canonicalJSON(b) }
+	check("type", existing.Type != desired.Type)
+	check("key", existing.Key != desired.Key)
+	check("status", existing.Status != desired.Status)
+	check("base_url", derefString(existing.BaseURL) != derefString(desired.BaseURL))
+	check("openai_organization", derefString(existing.OpenAIOrganization) != derefString(desired.OpenAIOrganization))
+	check("test_model", derefString(existing.TestModel) != derefString(desired.TestModel))
+	check("models", existing.Models != desired.Models)
+	check("groups", existing.Group != desired.Group)
+	check("tag", existing.GetTag() != desired.GetTag())
+	check("priority", existing.GetPriority() != desired.GetPriority())
+	check("weight", existing.GetWeight() != desired.GetWeight())
+	check("auto_ban", existing.GetAutoBan() != desired.GetAutoBan())
+	check("remark", derefString(existing.Remark) != derefString(desired.Remark))
+	check("other", existing.Other != desired.Other)
+	check("model_mapping", jsonChanged(derefString(existing.ModelMapping), derefString(desired.ModelMapping)))
+	check("status_code_mapping", jsonChanged(derefString(existing.StatusCodeMapping), derefString(desired.StatusCodeMapping)))
+	check("setting", jsonChanged(derefString(existing.Setting), derefString(desired.Setting)))
+	check("param_override", jsonChanged(derefString(existing.ParamOverride), derefString(desired.ParamOverride)))
+	check("header_override", jsonChanged(derefString(existing.HeaderOverride), derefString(desired.HeaderOverride)))
+	check("settings", jsonChanged(existing.OtherSettings, desired.OtherSettings))
+	check("multi_key", existing.ChannelInfo.IsMultiKey != desired.ChannelInfo.IsMultiKey ||
+		existing.ChannelInfo.MultiKeyMode != desired.ChannelInfo.MultiKeyMode ||
+		existing.ChannelInfo.MultiKeyRPMLimit != desired.ChannelInfo.MultiKeyRPMLimit ||
+		existing.ChannelInfo.MultiKeyTPMLimit != desired.ChannelInfo.MultiKeyTPMLimit)
+	return fields
+}
+
+// ChangedJSONKeys 返回两个 JSON 对象之间新增、删除或取值变化的顶层键
+func ChangedJSONKeys(oldValue string, newValue string) []string {
+	var oldMap, newMap map[string]any
+	if common.UnmarshalJsonStr(oldValue, &oldMap) != nil || common.UnmarshalJsonStr(newValue, &newMap) != nil {
+		return nil
+	}
+	var keys []string
+	for key, value := range newMap {
+		oldItem, ok := oldMap[key]
+		if !ok {
+			keys = append(keys, key)
+			continue
+		}
+		oldJSON, _ := common.Marshal(oldItem)
+		newJSON, _ := common.Marshal(value)
+		if string(oldJSON) != string(newJSON) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range oldMap {
+		if _, ok := newMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// planConfigBundleOptions 校验分组、倍率与系统设置并记录变化
+func planConfigBundleOptions(plan *ConfigBundlePlan, kind string, values map[string]any, sections map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		optionKey, ok := sections[name]
+		if !ok {
+			return fmt.Errorf("unknown %s entry %s", kind, name)
+		}
+		value, err := configBundleJSONString(values[name])
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		var target any = &map[string]float64{}
+		if newShape, ok := configBundleOptionShapes[optionKey]; ok {
+			target = newShape()
+		}
+		if err := common.UnmarshalJsonStr(value, target); err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		if err := ValidateOptionUpdate(optionKey, value); err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		current := configBundleOptionValue(optionKey)
+		if canonicalJSON(current) == canonicalJSON(value) {
+			continue
+		}
+		plan.writes.Options[optionKey] = value
+		plan.add(ConfigBundleChange{Kind: kind, Action: "update", Name: name, Fields: ChangedJSONKeys(current, value)})
+	}
+	return nil
+}
+
+// PlanConfigBundle 计算把文档应用到当前环境所需的变更，并完成与界面操作相同的校验，不写入任何数据
+func PlanConfigBundle(bundle *ConfigBundle, opts ConfigBundleApplyOptions) (*ConfigBundlePlan, error) {
+	plan := &ConfigBundlePlan{
+		Changes: []ConfigBundleChange{},
+		writes:  &model.ConfigBundleChanges{Options: make(map[string]string)},
+	}
+	var passphraseCipher *common.PassphraseCipher
+	getCipher := func() (*common.PassphraseCipher, error) {
+		if passphraseCipher != nil {
+			return passphraseCipher, nil
+		}
+		if bundle.SecretSalt == "" {
+			return nil, errors.New("config document has encrypted keys but no secret_salt")
+		}
+		var err error
+		passphraseCipher, err = common.NewPassphraseCipher(opts.Passphrase, bundle.SecretSalt)
+		return passphraseCipher, err
+	}
+
+	if bundle.Channels != nil {
+		if err := planConfigBundleChannels(plan, bundle.Channels, opts.Prune, getCipher); err != nil {
+			return nil, err
+		}
+	}
+	if err := planConfigBundleOptions(plan, "group", bundle.Groups, configBundleGroupOptions); err != nil {
+		return nil, err
+	}
+	if err := planConfigBundleOptions(plan, "ratio", bundle.Ratios, configBundleRatioOptions); err != nil {
+		return nil, err
+	}
+	optionKeys := make([]string, 0, len(bundle.Options))
+	for key := range bundle.Options {
+		optionKeys = append(optionKeys, key)
+	}
+	sort.Strings(optionKeys)
+	for _, key := range optionKeys {
+		value := bundle.Options[key]
+		if err := configBundleOptionAllowed(key); err != nil {
+			return nil, err
+		}
+		if err := ValidateOptionUpdate(key, value); err != nil {
+			return nil, fmt.Errorf("option %s: %w", key, err)
+		}
+		if configBundleOptionValue(key) == value {
+			continue
+		}
+		plan.writes.Options[key] = value
+		plan.add(ConfigBundleChange{Kind: "option", Action: "update", Name: key})
+	}
+	return plan, nil
+}
+
+func planConfigBundleChannels(plan *ConfigBundlePlan, entries []ConfigBundleChannel, prune bool, getCipher func() (*common.PassphraseCipher, error)) error {
+	existingChannels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		return err
+	}
+	existingByName := make(map[string][]*model.Channel, len(existingChannels))
+	for _, channel := range existingChannels {
+		existingByName[channel.Name] = append(existingByName[channel.Name], channel)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.Name) == "" {
+			return errors.New("every channel in the config document needs a name")
+		}
+		if seen[entry.Name] {
+			return fmt.Errorf("channel name %s appears more than once in the config document", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		key, keepKey, err := resolveConfigBundleSecret(entry.Key, getCipher)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", entry.Name, err)
+		}
+		matches := existingByName[entry.Name]
+		switch len(matches) {
+		case 0:
+			if keepKey {
+				return fmt.Errorf("channel %s does not exist yet, its key must be given as value, encrypted or env reference", entry.Name)
+			}
+			channel, err := buildConfigBundleChannel(&model.Channel{Status: common.ChannelStatusEnabled}, entry, key, false)
+			if err != nil {
+				return fmt.Errorf("channel %s: %w", entry.Name, err)
+			}
+			if err := ValidateChannel(channel, true); err != nil {
+				return fmt.Errorf("channel %s: %w", entry.Name, err)
+			}
+			channel.CreatedTime = common.GetTimestamp()
+			plan.writes.Creates = append(plan.writes.Creates, channel)
+			plan.add(ConfigBundleChange{Kind: "channel", Action: "create", Name: entry.Name})
+		case 1:
+			existing := matches[0]
+			base := *existing
+			channel, err := buildConfigBundleChannel(&base, entry, key, keepKey)
+			if err != nil {
+				return fmt.Errorf("channel %s: %w", entry.Name, err)
+			}
+			fields := configBundleChannelChanges(existing, channel)
+			if len(fields) == 0 {
+				continue
+			}
+			if err := ValidateChannel(channel, false); err != nil {
+				return fmt.Errorf("channel %s: %w", entry.Name, err)
+			}
+			plan.writes.Updates = append(plan.writes.Updates, channel)
+			plan.add(ConfigBundleChange{Kind: "channel", Action: "update", Name: entry.Name, Id: existing.Id, Fields: fields})
+		default:
+			return fmt.Errorf("%d existing channels are named %s, rename them before applying", len(matches), entry.Name)
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, channel := range existingChannels {
+		if seen[channel.Name] {
+			continue
+		}
+		plan.writes.Deletes = append(plan.writes.Deletes, channel.Id)
+		plan.add(ConfigBundleChange{Kind: "channel", Action: "delete", Name: channel.Name, Id: channel.Id})
+	}
+	return nil
+}
+
+// ApplyConfigBundle 计算并在一个事务中写入全部变更，随后刷新渠道缓存
+func ApplyConfigBundle(bundle *ConfigBundle, opts ConfigBundleApplyOptions) (*ConfigBundlePlan, error) {
+	plan, err := PlanConfigBundle(bundle, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Changes) == 0 {
+		return plan, nil
+	}
+	plan.writes.OperatorId = opts.OperatorId
+	if err := model.ApplyConfigBundleChanges(plan.writes); err != nil {
+		return nil, err
+	}
+	channelsChanged := slices.ContainsFunc(plan.Changes, func(change ConfigBundleChange) bool {
+		return change.Kind == "channel"
+	})
+	if channelsChanged {
+		model.InitChannelCache()
+		ResetProxyClientCache()
+	}
+	return plan, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigBundleRoundTrip(t *testing.T) {
+	truncate(t)
+	db := model.DB
+	require.NoError(t, (&model.Channel{
+		Name: "primary", Type: 1, Key: "sk-primary", Status: common.ChannelStatusEnabled,
+		Models: "gpt-4o,gpt-4o-mini", Group: "default", ParamOverride: common.GetPointer(`{"temperature":0.2}`),
+	}).Insert())
+	require.NoError(t, (&model.Channel{
+		Name: "legacy", Type: 1, Key: "sk-legacy", Status: common.ChannelStatusEnabled,
+		Models: "gpt-3.5-turbo", Group: "default",
+	}).Insert())
+
+	bundle, err := ExportConfigBundle(ConfigBundleExportOptions{Secrets: ConfigSecretModeEncrypted, Passphrase: "s3cret"})
+	require.NoError(t, err)
+	content, err := MarshalConfigBundle(bundle, "yaml")
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "sk-primary")
+
+	// 原样导入不产生变更
+	parsed, err := ParseConfigBundle(content)
+	require.NoError(t, err)
+	plan, err := PlanConfigBundle(parsed, ConfigBundleApplyOptions{Passphrase: "s3cret"})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+
+	// 错误的口令无法解密密钥
+	parsed.Channels = append(parsed.Channels, ConfigBundleChannel{
+		Name: "secondary", Type: 1, Models: []string{"gpt-4o"}, Groups: []string{"default", "vip"},
+		Key: &ConfigBundleSecret{Value: "sk-secondary"},
+	})
+	_, err = PlanConfigBundle(parsed, ConfigBundleApplyOptions{Passphrase: "wrong"})
+	require.Error(t, err)
+
+	parsed.Channels[0].Models = []string{"gpt-4o"}
+	parsed.Channels[0].Key = &ConfigBundleSecret{Ref: "keep"}
+	parsed.Channels = append(parsed.Channels[:1], parsed.Channels[2:]...)
+	plan, err = PlanConfigBundle(parsed, ConfigBundleApplyOptions{Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, plan.Creates)
+	assert.Equal(t, 1, plan.Updates)
+	assert.Equal(t, 1, plan.Deletes)
+	assert.Equal(t, []string{"models"}, plan.Changes[0].Fields)
+
+	// 新渠道不能使用 keep 引用
+	_, err = PlanConfigBundle(&ConfigBundle{Version: ConfigBundleVersion, Channels: []ConfigBundleChannel{
+		{Name: "new", Type: 1, Models: []string{"gpt-4o"}, Key: &ConfigBundleSecret{Ref: "keep"}},
+	}}, ConfigBundleApplyOptions{})
+	require.Error(t, err)
+
+	_, err = ApplyConfigBundle(parsed, ConfigBundleApplyOptions{Prune: true})
+	require.NoError(t, err)
+
+	var channels []model.Channel
+	require.NoError(t, db.Order("id").Find(&channels).Error)
+	require.Len(t, channels, 2)
+	assert.Equal(t, "primary", channels[0].Name)
+	assert.Equal(t, "sk-primary", channels[0].Key)
+	assert.Equal(t, "gpt-4o", channels[0].Models)
+	assert.JSONEq(t, `{"temperature":0.2}`, *channels[0].ParamOverride)
+	assert.Equal(t, "secondary", channels[1].Name)
+	assert.Equal(t, "sk-secondary", channels[1].Key)
+
+	var abilities []model.Ability
+	require.NoError(t, db.Order("channel_id").Find(&abilities).Error)
+	require.Len(t, abilities, 3)
+	assert.Equal(t, channels[0].Id, abilities[0].ChannelId)
+}
+
+func TestResolveConfigBundleSecretEnvAllowlist(t *testing.T) {
+	t.Setenv("NEWAPI_CHANNEL_KEY_PRIMARY", "sk-from-env")
+	t.Setenv("SESSION_SECRET", "server-secret")
+	noCipher := func() (*common.PassphraseCipher, error) { return nil, nil }
+
+	value, keep, err := resolveConfigBundleSecret(&ConfigBundleSecret{Ref: "env:NEWAPI_CHANNEL_KEY_PRIMARY"}, noCipher)
+	require.NoError(t, err)
+	assert.False(t, keep)
+	assert.Equal(t, "sk-from-env", value)
+
+	for _, ref := range []string{"env:SESSION_SECRET", "env:SQL_DSN", "env:NEWAPI_CHANNEL_KEY_"} {
+		_, _, err = resolveConfigBundleSecret(&ConfigBundleSecret{Ref: ref}, noCipher)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestPlanConfigBundleAppliesOptionGuards(t *testing.T) {
+	truncate(t)
+	previous := common.OptionMap
+	common.OptionMap = map[string]string{"TurnstileCheckEnabled": "false"}
+	t.Cleanup(func() { common.OptionMap = previous })
+	oldSiteKey := common.TurnstileSiteKey
+	common.TurnstileSiteKey = ""
+	t.Cleanup(func() { common.TurnstileSiteKey = oldSiteKey })
+
+	// 与设置编辑器一样，缺少 Site Key 时不能开启 Turnstile
+	_, err := PlanConfigBundle(&ConfigBundle{Version: ConfigBundleVersion, Options: map[string]string{
+		"TurnstileCheckEnabled": "true",
+	}}, ConfigBundleApplyOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Turnstile")
+}
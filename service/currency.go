@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// currencyRateResponseLimit caps the rate API body; real responses are a few KB.
+const currencyRateResponseLimit = 1 << 20
+
+// CurrencyRateRefreshResult summarizes one exchange rate refresh run.
+type CurrencyRateRefreshResult struct {
+	Source  string             `json:"source"`
+	Updated int                `json:"updated"`
+	Rates   map[string]float64 `json:"rates"`
+}
+
+// PricingCurrencyPrice is a model price listed in a display currency, before
+// group ratios. Token prices are per 1M tokens; ModelPrice is per request.
+type PricingCurrencyPrice struct {
+	InputPrice  *float64 `json:"input_price,omitempty"`
+	OutputPrice *float64 `json:"output_price,omitempty"`
+	CachePrice  *float64 `json:"cache_price,omitempty"`
+	ModelPrice  *float64 `json:"model_price,omitempty"`
+}
+
+// GetUserPreferredCurrency returns the currency the user asked for: an
+// explicitly requested code first, then the saved user preference. It reports
+// false when neither names a configured currency.
+func GetUserPreferredCurrency(userId int, requested string) (operation_setting.CurrencyDefinition, bool) {
+	if currency, ok := operation_setting.GetCurrency(requested); ok {
+		return currency, true
+	}
+	if userId > 0 {
+		if userSetting, err := model.GetUserSetting(userId, false); err == nil {
+			if currency, ok := operation_setting.GetCurrency(userSetting.Currency); ok {
+				return currency, true
+			}
+		}
+	}
+	return operation_setting.CurrencyDefinition{}, false
+}
+
+// ResolveUserCurrency is GetUserPreferredCurrency falling back to the site
+// default currency, for display purposes.
+func ResolveUserCurrency(userId int, requested string) operation_setting.CurrencyDefinition {
+	if currency, ok := GetUserPreferredCurrency(userId, requested); ok {
+		return currency
+	}
+	return operation_setting.ResolveCurrency("")
+}
+
+// BuildPricingCurrencyPrices converts the USD pricing list into the given
+// currency. Models billed by a tiered expression are left to the expression
+// and omitted here.
+func BuildPricingCurrencyPrices(pricing []model.Pricing, currency operation_setting.CurrencyDefinition) map[string]PricingCurrencyPrice {
+	prices := make(map[string]PricingCurrencyPrice, len(pricing))
+	// a ratio of 1 charges 1 quota per token, i.e. 1M tokens cost 1e6/QuotaPerUnit USD
+	usdPerMillion := 1_000_000 / common.QuotaPerUnit
+	for _, item := range pricing {
+		if item.BillingMode == billing_setting.BillingModeTieredExpr {
+			continue
+		}
+		var price PricingCurrencyPrice
+		if item.QuotaType == 1 {
+			price.ModelPrice = common.GetPointer(operation_setting.ConvertFromUSD(item.ModelPrice, currency))
+		} else {
+			input := operation_setting.ConvertFromUSD(item.ModelRatio*usdPerMillion, currency)
+			price.InputPrice = common.GetPointer(input)
+			price.OutputPrice = common.GetPointer(input * item.CompletionRatio)
+			if item.CacheRatio != nil {
+				price.CachePrice = common.GetPointer(input * *item.CacheRatio)
+			}
+		}
+		prices[item.ModelName] = price
+	}
+	return prices
+}
+
+// RefreshCurrencyRates pulls USD-based rates from the configured endpoint and
+// persists them for every currency whose source is "auto". Manual currencies
+// are never touched.
+func RefreshCurrencyRates(ctx context.Context) (*CurrencyRateRefreshResult, error) {
+	currencySetting := operation_setting.GetCurrencySetting()
+	refreshURL := strings.TrimSpace(currencySetting.RefreshURL)
+	if refreshURL == "" {
+		return nil, errors.New("currency refresh url is not configured")
+	}
+	rates, err := fetchCurrencyRates(ctx, refreshURL)
+	if err != nil {
+		return nil, err
+	}
+	currencies, updated := operation_setting.ApplyCurrencyRates(currencySetting.Currencies, rates, common.GetTimestamp())
+	result := &CurrencyRateRefreshResult{Source: refreshURL, Updated: updated, Rates: map[string]float64{}}
+	for _, currency := range currencies {
+		if currency.Source == operation_setting.CurrencyRateSourceAuto {
+			result.Rates[operation_setting.NormalizeCurrencyCode(currency.Code)] = currency.Rate
+		}
+	}
+	if updated == 0 {
+		return result, nil
+	}
+	value, err := common.Marshal(currencies)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.UpdateOptionsBulk(map[string]string{operation_setting.CurrencyOptionKey: string(value)}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type currencyRateResponse struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+func fetchCurrencyRates(ctx context.Context, refreshURL string) (map[string]float64, error) {
+	if err := ValidateSSRFProtectedFetchURL(refreshURL); err != nil {
+		return nil, fmt.Errorf("request reject: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, refreshURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := GetSSRFProtectedHTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch currency rates: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("currency rate endpoint returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, currencyRateResponseLimit))
+	if err != nil {
+		return nil, err
+	}
+	var parsed currencyRateResponse
+	if err := common.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid currency rate response: %v", err)
+	}
+	if len(parsed.Rates) == 0 {
+		return nil, errors.New("currency rate response contains no rates")
+	}
+	rates := make(map[string]float64, len(parsed.Rates))
+	for code, rate := range parsed.Rates {
+		rates[operation_setting.NormalizeCurrencyCode(code)] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshCurrencyRatesUpdatesAutoCurrencies(t *testing.T) {
+	truncate(t)
+	allowLocalWebhooks(t)
+	if common.OptionMap == nil {
+		common.OptionMap = make(map[string]string)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":"success","rates":{"USD":1,"cny":7.25,"EUR":0.93}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	setting := operation_setting.GetCurrencySetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.RefreshURL = server.URL
+	setting.Currencies = []operation_setting.CurrencyDefinition{
+		{Code: "USD", Rate: 1, Source: operation_setting.CurrencyRateSourceManual},
+		{Code: "CNY", Rate: 7, Source: operation_setting.CurrencyRateSourceAuto},
+		{Code: "EUR", Rate: 0.9, Source: operation_setting.CurrencyRateSourceManual},
+	}
+
+	result, err := RefreshCurrencyRates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, map[string]float64{"CNY": 7.25}, result.Rates)
+
+	cny, ok := operation_setting.GetCurrency("CNY")
+	require.True(t, ok)
+	assert.Equal(t, 7.25, cny.Rate)
+	eur, ok := operation_setting.GetCurrency("EUR")
+	require.True(t, ok)
+	assert.Equal(t, 0.9, eur.Rate)
+
+	var option model.Option
+	require.NoError(t, model.DB.Where("key = ?", operation_setting.CurrencyOptionKey).First(&option).Error)
+	assert.Contains(t, option.Value, "7.25")
+}
+
+func TestRefreshCurrencyRatesRejectsBadResponse(t *testing.T) {
+	allowLocalWebhooks(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"rates":{}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	setting := operation_setting.GetCurrencySetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.RefreshURL = server.URL
+
+	_, err := RefreshCurrencyRates(context.Background())
+	assert.Error(t, err)
+}
+
+func TestBuildPricingCurrencyPrices(t *testing.T) {
+	cacheRatio := 0.5
+	pricing := []model.Pricing{
+		{ModelName: "ratio", QuotaType: 0, ModelRatio: 1, CompletionRatio: 2, CacheRatio: &cacheRatio},
+		{ModelName: "fixed", QuotaType: 1, ModelPrice: 0.1},
+		{ModelName: "tiered", BillingMode: billing_setting.BillingModeTieredExpr},
+	}
+	currency := operation_setting.CurrencyDefinition{Code: "CNY", Rate: 7}
+
+	prices := BuildPricingCurrencyPrices(pricing, currency)
+
+	require.Len(t, prices, 2)
+	input := 1_000_000 / common.QuotaPerUnit * 7
+	ratio := prices["ratio"]
+	require.NotNil(t, ratio.InputPrice)
+	assert.InDelta(t, input, *ratio.InputPrice, 1e-9)
+	assert.InDelta(t, input*2, *ratio.OutputPrice, 1e-9)
+	assert.InDelta(t, input*0.5, *ratio.CachePrice, 1e-9)
+	assert.Nil(t, ratio.ModelPrice)
+	require.NotNil(t, prices["fixed"].ModelPrice)
+	assert.InDelta(t, 0.7, *prices["fixed"].ModelPrice, 1e-9)
+}
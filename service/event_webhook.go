@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+const (
+	webhookDeliveryBatchSize   = 100
+	webhookDeliveryMaxPerRun   = 1000
+	webhookDeliveryConcurrency = 8
+	webhookResponseBodyLimit   = 2048
+	webhookRetryBaseDelay      = 30 * time.Second
+	webhookRetryMaxDelay       = 6 * time.Hour
+	webhookPruneInterval       = time.Hour
+)
+
+type WebhookDeliveryRunSummary struct {
+	Attempted int   `json:"attempted"`
+	Succeeded int   `json:"succeeded"`
+	Retrying  int   `json:"retrying"`
+	Failed    int   `json:"failed"`
+	Pruned    int64 `json:"pruned"`
+}
+
+var (
+	eventWebhookDispatcherOnce sync.Once
+	webhookPruneMu             sync.Mutex
+	lastWebhookPrune           time.Time
+)
+
+// StartEventWebhookDispatcher subscribes webhook subscriptions to the event
+// bus. Every node records deliveries for the events it emits; the master's
+// system task runner sends them.
+func StartEventWebhookDispatcher() {
+	eventWebhookDispatcherOnce.Do(func() {
+		eventbus.Subscribe(func(event *eventbus.Event) {
+			created, err := DispatchWebhookEvent(event)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to record webhook deliveries for event %s: %s", event.Type, err.Error()))
+				return
+			}
+			if created == 0 {
+				return
+			}
+			if _, _, err := EnqueueSystemTask(model.SystemTaskTypeWebhookDelivery, nil); err != nil {
+				common.SysError("failed to enqueue webhook delivery task: " + err.Error())
+			}
+		})
+	})
+}
+
+// DispatchWebhookEvent records one pending delivery per subscription that
+// accepts the event and returns how many were created. System subscriptions
+// receive every event, user subscriptions only user-scoped events of their
+// owner.
+func DispatchWebhookEvent(event *eventbus.Event) (int, error) {
+	definition, ok := eventbus.Lookup(event.Type)
+	if !ok {
+		return 0, fmt.Errorf("unknown event type: %s", event.Type)
+	}
+	eventUserId := 0
+	if definition.Scope == eventbus.ScopeUser && system_setting.GetEventWebhookSettings().UserSubscriptionsEnabled {
+		eventUserId = event.UserId
+	}
+	subscriptions, err := model.GetEventWebhookSubscriptions(eventUserId)
+	if err != nil {
+		return 0, err
+	}
+	var deliveries []*model.WebhookDelivery
+	var payload string
+	for _, subscription := range subscriptions {
+		eventTypes := subscription.GetEventTypeList()
+		if len(eventTypes) > 0 && !slices.Contains(eventTypes, string(event.Type)) {
+			continue
+		}
+		if payload == "" {
+			data, err := common.Marshal(event)
+			if err != nil {
+				return 0, err
+			}
+			payload = string(data)
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			UserId:         subscription.UserId,
+			EventId:        event.ID,
+			EventType:      string(event.Type),
+			Payload:        payload,
+		})
+	}
+	if err := model.CreateWebhookDeliveries(deliveries); err != nil {
+		return 0, err
+	}
+	return len(deliveries), nil
+}
+
+// RedeliverWebhook queues a new delivery with the original payload. The
+// original record is kept so the log shows every attempt.
+func RedeliverWebhook(original *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	if original.Status == model.WebhookDeliveryStatusPending {
+		return nil, errors.New("delivery is still pending")
+	}
+	if original.SubscriptionId > 0 {
+		if _, err := model.GetWebhookSubscription(original.SubscriptionId, original.UserId); err != nil {
+			return nil, err
+		}
+	}
+	redelivery := &model.WebhookDelivery{
+		SubscriptionId: original.SubscriptionId,
+		UserId:         original.UserId,
+		URL:            original.URL,
+		EventId:        original.EventId,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		RedeliveryOf:   original.Id,
+	}
+	if err := model.CreateWebhookDeliveries([]*model.WebhookDelivery{redelivery}); err != nil {
+		return nil, err
+	}
+	if _, _, err := EnqueueSystemTask(model.SystemTaskTypeWebhookDelivery, nil); err != nil {
+		common.SysError("failed to enqueue webhook delivery task: " + err.Error())
+	}
+	return redelivery, nil
+}
+
+// RunWebhookDeliveriesOnce sends due deliveries until none are left, the run
+// limit is reached or ctx is cancelled, then prunes old finished deliveries.
+func RunWebhookDeliveriesOnce(ctx context.Context) WebhookDeliveryRunSummary {
+	summary := WebhookDeliveryRunSummary{}
+	var mu sync.Mutex
+	subscriptions := map[int]*model.WebhookSubscription{}
+	for summary.Attempted < webhookDeliveryMaxPerRun && ctx.Err() == nil {
+		deliveries, err := model.FindDueWebhookDeliveries(common.GetTimestamp(), webhookDeliveryBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("webhook delivery query failed: %v", err))
+			break
+		}
+		if len(deliveries) == 0 {
+			break
+		}
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, webhookDeliveryConcurrency)
+		for _, delivery := range deliveries {
+			target, targetErr := resolveWebhookDeliveryTarget(subscriptions, delivery)
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(delivery *model.WebhookDelivery, target *webhookDeliveryTarget, targetErr error) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				attemptWebhookDelivery(ctx, target, targetErr, delivery)
+				if err := model.SaveWebhookDeliveryAttempt(delivery); err != nil {
+					logger.LogWarn(ctx, fmt.Sprintf("failed to save webhook delivery %d: %v", delivery.Id, err))
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				summary.Attempted++
+				switch delivery.Status {
+				case model.WebhookDeliveryStatusSucceeded:
+					summary.Succeeded++
+				case model.WebhookDeliveryStatusFailed:
+					summary.Failed++
+				default:
+					summary.Retrying++
+				}
+			}(delivery, target, targetErr)
+		}
+		wg.Wait()
+	}
+	summary.Pruned = pruneWebhookDeliveries()
+	return summary
+}
+
+// webhookDeliveryTarget is where a delivery is sent and the secret it is
+// signed with.
+type webhookDeliveryTarget struct {
+	url    string
+	secret string
+}
+
+// resolveWebhookDeliveryTarget returns the target of a subscription delivery
+// or a task callback. Subscriptions are cached in subscriptions for the run.
+func resolveWebhookDeliveryTarget(subscriptions map[int]*model.WebhookSubscription, delivery *model.WebhookDelivery) (*webhookDeliveryTarget, error) {
+	if delivery.SubscriptionId == 0 {
+		return resolveTaskCallbackTarget(delivery)
+	}
+	subscription, ok := subscriptions[delivery.SubscriptionId]
+	if !ok {
+		var err error
+		subscription, err = model.GetWebhookSubscription(delivery.SubscriptionId, delivery.UserId)
+		if err != nil {
+			subscription = nil
+		}
+		subscriptions[delivery.SubscriptionId] = subscription
+	}
+	if subscription == nil || !subscription.Enabled {
+		return nil, errors.New("subscription is disabled or deleted")
+	}
+	return &webhookDeliveryTarget{url: subscription.URL, secret: subscription.Secret}, nil
+}
+
+// attemptWebhookDelivery sends one attempt and records the outcome on the
+// delivery. Deliveries whose target cannot be resolved, such as those of
+// deleted or disabled subscriptions, fail immediately.
+func attemptWebhookDelivery(ctx context.Context, target *webhookDeliveryTarget, targetErr error, delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	delivery.LastError = ""
+	if targetErr != nil {
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		delivery.LastError = targetErr.Error()
+		return
+	}
+
+	settings := system_setting.GetEventWebhookSettings()
+	requestCtx, cancel := context.WithTimeout(ctx, settings.Timeout())
+	defer cancel()
+	start := time.Now()
+	statusCode, body, err := sendWebhookEvent(requestCtx, target, delivery)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.ResponseStatus = statusCode
+	delivery.ResponseBody = body
+	if err == nil && (statusCode < 200 || statusCode >= 300) {
+		err = fmt.Errorf("webhook request failed with status code: %d", statusCode)
+	}
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSucceeded
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= settings.GetMaxAttempts() {
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		return
+	}
+	delivery.Status = model.WebhookDeliveryStatusPending
+	delivery.NextAttemptTime = time.Now().Add(webhookRetryDelay(delivery.Attempts)).Unix()
+}
+
+func sendWebhookEvent(ctx context.Context, target *webhookDeliveryTarget, delivery *model.WebhookDelivery) (int, string, error) {
+	var envelope struct {
+		Version int `json:"version"`
+	}
+	_ = common.UnmarshalJsonStr(delivery.Payload, &envelope)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body := []byte(delivery.Payload)
+	headers := map[string]string{
+		"Content-Type":            "application/json",
+		"User-Agent":              "new-api-webhook/1",
+		"X-Webhook-Event":         delivery.EventType,
+		"X-Webhook-Event-Version": strconv.Itoa(envelope.Version),
+		"X-Webhook-Event-Id":      delivery.EventId,
+		"X-Webhook-Delivery":      strconv.Itoa(delivery.Id),
+		"X-Webhook-Timestamp":     timestamp,
+		"X-Webhook-Signature":     SignWebhookEvent(target.secret, timestamp, body),
+	}
+	resp, err := doWebhookRequest(ctx, target.url, headers, body)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyLimit))
+	return resp.StatusCode, string(responseBody), nil
+}
+
+// SignWebhookEvent returns the X-Webhook-Signature value of an event
+// delivery: "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers should recompute it and reject stale timestamps.
+func SignWebhookEvent(secret string, timestamp string, body []byte) string {
+	signed := make([]byte, 0, len(timestamp)+1+len(body))
+	signed = append(signed, timestamp...)
+	signed = append(signed, '.')
+	signed = append(signed, body...)
+	return "v1=" + generateSignature(secret, signed)
+}
+
+// webhookRetryDelay doubles the delay after every failed attempt, starting at
+// 30 seconds and capped at 6 hours.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMaxDelay {
+			return webhookRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+func pruneWebhookDeliveries() int64 {
+	retentionDays := system_setting.GetEventWebhookSettings().RetentionDays
+	if retentionDays <= 0 {
+		return 0
+	}
+	webhookPruneMu.Lock()
+	if time.Since(lastWebhookPrune) < webhookPruneInterval {
+		webhookPruneMu.Unlock()
+		return 0
+	}
+	lastWebhookPrune = time.Now()
+	webhookPruneMu.Unlock()
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).Unix()
+	pruned, err := model.DeleteWebhookDeliveriesBefore(cutoff)
+	if err != nil {
+		common.SysError("failed to prune webhook deliveries: " + err.Error())
+	}
+	return pruned
+}
+
+// GenerateWebhookSecret returns a new signing secret for a subscription.
+func GenerateWebhookSecret() (string, error) {
+	key, err := common.GenerateRandomCharsKey(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + key, nil
+}
+
+// ValidateWebhookSubscription normalizes a subscription before it is saved.
+// Only system subscriptions may listen to system-scoped events.
+func ValidateWebhookSubscription(subscription *model.WebhookSubscription, eventTypes []string) error {
+	subscription.Name = strings.TrimSpace(subscription.Name)
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	if subscription.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(subscription.Name) > 64 {
+		return errors.New("name is too long")
+	}
+	parsed, err := url.ParseRequestURI(subscription.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	if len(subscription.URL) > 1024 {
+		return errors.New("url is too long")
+	}
+	if !system_setting.EnableWorker() {
+		if err := ValidateSSRFProtectedFetchURL(subscription.URL); err != nil {
+			return fmt.Errorf("url is not allowed: %v", err)
+		}
+	}
+	normalized := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if eventType == "" || slices.Contains(normalized, eventType) {
+			continue
+		}
+		definition, ok := eventbus.Lookup(eventbus.Type(eventType))
+		if !ok {
+			return fmt.Errorf("unknown event type: %s", eventType)
+		}
+		if subscription.UserId != 0 && definition.Scope != eventbus.ScopeUser {
+			return fmt.Errorf("event type %s is not available to user subscriptions", eventType)
+		}
+		normalized = append(normalized, eventType)
+	}
+	subscription.SetEventTypeList(normalized)
+	return nil
+}
+
+// SubscribableWebhookEvents lists the event definitions a subscription owner
+// may subscribe to.
+func SubscribableWebhookEvents(system bool) []eventbus.Definition {
+	var definitions []eventbus.Definition
+	for _, definition := range eventbus.Definitions() {
+		if system || definition.Scope == eventbus.ScopeUser {
+			definitions = append(definitions, definition)
+		}
+	}
+	return definitions
+}
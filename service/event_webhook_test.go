@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/eventbus"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowLocalWebhooks lets deliveries reach an httptest server on loopback.
+func allowLocalWebhooks(t *testing.T) {
+	t.Helper()
+	fetchSetting := system_setting.GetFetchSetting()
+	originalFetchSetting := *fetchSetting
+	originalHTTPClient := httpClient
+	t.Cleanup(func() {
+		*fetchSetting = originalFetchSetting
+		httpClient = originalHTTPClient
+	})
+	fetchSetting.EnableSSRFProtection = false
+	httpClient = &http.Client{}
+}
+
+func seedWebhookSubscription(t *testing.T, userId int, url string, eventTypes ...string) *model.WebhookSubscription {
+	t.Helper()
+	subscription := &model.WebhookSubscription{UserId: userId, Name: "hook", URL: url, Secret: "whsec_test", Enabled: true}
+	subscription.SetEventTypeList(eventTypes)
+	require.NoError(t, subscription.Insert())
+	return subscription
+}
+
+func webhookDeliveriesOf(t *testing.T, subscriptionId int) []*model.WebhookDelivery {
+	t.Helper()
+	var deliveries []*model.WebhookDelivery
+	require.NoError(t, model.DB.Where("subscription_id = ?", subscriptionId).Order("id asc").Find(&deliveries).Error)
+	return deliveries
+}
+
+func TestDispatchWebhookEventFiltersByScopeOwnerAndType(t *testing.T) {
+	truncate(t)
+	system := seedWebhookSubscription(t, 0, "https://example.com/system")
+	systemFiltered := seedWebhookSubscription(t, 0, "https://example.com/filtered", string(eventbus.ChannelAutoDisabled))
+	owner := seedWebhookSubscription(t, 7, "https://example.com/owner")
+	other := seedWebhookSubscription(t, 8, "https://example.com/other")
+
+	created, err := DispatchWebhookEvent(eventbus.NewEvent(7, eventbus.TokenExhaustedPayload{TokenId: 1}))
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+	assert.Len(t, webhookDeliveriesOf(t, system.Id), 1)
+	assert.Len(t, webhookDeliveriesOf(t, owner.Id), 1)
+	assert.Empty(t, webhookDeliveriesOf(t, systemFiltered.Id))
+	assert.Empty(t, webhookDeliveriesOf(t, other.Id))
+
+	// System-scoped events never reach user subscriptions
+	created, err = DispatchWebhookEvent(eventbus.NewEvent(7, eventbus.ChannelAutoDisabledPayload{ChannelId: 1}))
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+	assert.Len(t, webhookDeliveriesOf(t, systemFiltered.Id), 1)
+	assert.Len(t, webhookDeliveriesOf(t, owner.Id), 1)
+}
+
+func TestRunWebhookDeliveriesSignsAndRetriesWithBackoff(t *testing.T) {
+	truncate(t)
+	allowLocalWebhooks(t)
+	settings := system_setting.GetEventWebhookSettings()
+	originalSettings := *settings
+	t.Cleanup(func() { *settings = originalSettings })
+	settings.MaxAttempts = 2
+
+	var received []*http.Request
+	var bodies [][]byte
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	subscription := seedWebhookSubscription(t, 0, server.URL)
+	_, err := DispatchWebhookEvent(eventbus.NewEvent(0, eventbus.ChannelEnabledPayload{ChannelId: 3}))
+	require.NoError(t, err)
+
+	summary := RunWebhookDeliveriesOnce(context.Background())
+	assert.Equal(t, 1, summary.Retrying)
+	require.Len(t, received, 1)
+	request := received[0]
+	assert.Equal(t, string(eventbus.ChannelEnabled), request.Header.Get("X-Webhook-Event"))
+	assert.Equal(t, "1", request.Header.Get("X-Webhook-Event-Version"))
+	assert.Equal(t, SignWebhookEvent("whsec_test", request.Header.Get("X-Webhook-Timestamp"), bodies[0]),
+		request.Header.Get("X-Webhook-Signature"))
+
+	delivery := webhookDeliveriesOf(t, subscription.Id)[0]
+	assert.Equal(t, model.WebhookDeliveryStatusPending, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+	assert.InDelta(t, time.Now().Add(30*time.Second).Unix(), delivery.NextAttemptTime, 2)
+
+	// The retry is not due yet; once it is, the last allowed attempt fails for good
+	assert.Zero(t, RunWebhookDeliveriesOnce(context.Background()).Attempted)
+	require.NoError(t, model.DB.Model(delivery).Update("next_attempt_time", time.Now().Unix()).Error)
+	summary = RunWebhookDeliveriesOnce(context.Background())
+	assert.Equal(t, 1, summary.Failed)
+	delivery = webhookDeliveriesOf(t, subscription.Id)[0]
+	assert.Equal(t, model.WebhookDeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+
+	// A manual redelivery resends the same payload as a new delivery
+	status = http.StatusOK
+	redelivery, err := RedeliverWebhook(delivery)
+	require.NoError(t, err)
+	assert.Equal(t, delivery.Id, redelivery.RedeliveryOf)
+	summary = RunWebhookDeliveriesOnce(context.Background())
+	assert.Equal(t, 1, summary.Succeeded)
+	require.Len(t, bodies, 3)
+	assert.Equal(t, bodies[0], bodies[2])
+}
+
+func TestWebhookRetryDelayIsCapped(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, 2*time.Minute, webhookRetryDelay(3))
+	assert.Equal(t, 6*time.Hour, webhookRetryDelay(20))
+}
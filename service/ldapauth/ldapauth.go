@@ -0,0 +1,402 @@
+// Package ldapauth authenticates users against an LDAP or Active Directory
+// server: a service bind locates the user entry, a bind as that entry checks
+// the password, and group membership gates access. Account provisioning is
+// left to the caller.
+package ldapauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// maxSubjectLength matches the subject column of external identity claims;
+// longer identifiers are stored as a digest.
+const maxSubjectLength = 128
+
+var (
+	// ErrInvalidCredentials covers both an unknown login name and a wrong
+	// password so the two cannot be told apart by the caller's response.
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	// ErrUserNotFound is only returned by TestConnection; Authenticate
+	// reports a missing entry as ErrInvalidCredentials.
+	ErrUserNotFound  = errors.New("ldap: no entry matches the user filter")
+	ErrAmbiguousUser = errors.New("ldap: user filter matched more than one entry")
+	ErrGroupDenied   = errors.New("ldap: user is not a member of a permitted group")
+)
+
+// Entry is a directory user mapped through the configured attributes.
+type Entry struct {
+	DN          string   `json:"dn"`
+	Subject     string   `json:"subject"`
+	Username    string   `json:"username"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+	Groups      []string `json:"groups"`
+}
+
+// Authenticate verifies username and password against the directory and
+// returns the mapped entry. It fails with ErrGroupDenied when RequiredGroups
+// is set and the user belongs to none of them.
+func Authenticate(ctx context.Context, settings system_setting.LDAPSettings, username string, password string) (*Entry, error) {
+	username = strings.TrimSpace(username)
+	// Most directories treat a simple bind with an empty password as an
+	// anonymous bind that "succeeds", so it must be rejected here.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	conn, err := Dial(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := serviceBind(conn, settings); err != nil {
+		return nil, err
+	}
+	raw, err := findUser(conn, settings, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(raw.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("bind as user: %w", err)
+	}
+
+	entry := mapEntry(settings, raw, username)
+	if strings.TrimSpace(settings.GroupFilter) != "" {
+		// The user may not be allowed to read group entries; search them as
+		// the service account again.
+		if err := serviceBind(conn, settings); err != nil {
+			return nil, err
+		}
+		groups, err := searchGroups(conn, settings, raw.DN, username)
+		if err != nil {
+			return nil, err
+		}
+		entry.Groups = mergeGroups(entry.Groups, groups)
+	}
+	if !GroupsAllowed(settings, entry.Groups) {
+		return entry, ErrGroupDenied
+	}
+	return entry, nil
+}
+
+// TestConnection dials the server, performs the service bind and reads the
+// base DN. When username is not empty the user is looked up as during login,
+// without checking a password, and the mapped entry is returned.
+func TestConnection(ctx context.Context, settings system_setting.LDAPSettings, username string) (*Entry, error) {
+	conn, err := Dial(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := serviceBind(conn, settings); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Search(ldap.NewSearchRequest(
+		settings.BaseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, timeLimit(settings), false,
+		"(objectClass=*)", []string{"1.1"}, nil,
+	)); err != nil {
+		return nil, fmt.Errorf("read base DN: %w", err)
+	}
+
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, nil
+	}
+	raw, err := findUser(conn, settings, username)
+	if err != nil {
+		return nil, err
+	}
+	entry := mapEntry(settings, raw, username)
+	if strings.TrimSpace(settings.GroupFilter) != "" {
+		groups, err := searchGroups(conn, settings, raw.DN, username)
+		if err != nil {
+			return nil, err
+		}
+		entry.Groups = mergeGroups(entry.Groups, groups)
+	}
+	return entry, nil
+}
+
+// Dial connects to ServerURL and upgrades the connection with StartTLS when
+// configured. ldaps:// connections are verified with the same TLS settings.
+func Dial(ctx context.Context, settings system_setting.LDAPSettings) (*ldap.Conn, error) {
+	serverURL, err := url.Parse(strings.TrimSpace(settings.ServerURL))
+	if err != nil || serverURL.Host == "" {
+		return nil, errors.New("ldap: server URL is invalid")
+	}
+	switch serverURL.Scheme {
+	case "ldap", "ldaps":
+	default:
+		return nil, errors.New("ldap: server URL must use ldap:// or ldaps://")
+	}
+	if settings.StartTLS && serverURL.Scheme == "ldaps" {
+		return nil, errors.New("ldap: StartTLS cannot be combined with ldaps://")
+	}
+	tlsConfig, err := buildTLSConfig(settings, serverURL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := settings.Timeout()
+	dialer := &net.Dialer{Timeout: timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	conn, err := ldap.DialURL(serverURL.String(), ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if settings.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func buildTLSConfig(settings system_setting.LDAPSettings, serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: settings.InsecureSkipVerify, // #nosec G402 -- admin-controlled option for directories with self-signed certificates.
+	}
+	if pemData := strings.TrimSpace(settings.CACertificate); pemData != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(pemData)) {
+			return nil, errors.New("ldap: CA certificate is not valid PEM")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func serviceBind(conn *ldap.Conn, settings system_setting.LDAPSettings) error {
+	bindDN := strings.TrimSpace(settings.BindDN)
+	if bindDN == "" {
+		// Without a service account the directory is searched anonymously.
+		return conn.UnauthenticatedBind("")
+	}
+	if err := conn.Bind(bindDN, settings.BindPassword); err != nil {
+		return fmt.Errorf("service bind: %w", err)
+	}
+	return nil
+}
+
+func timeLimit(settings system_setting.LDAPSettings) int {
+	return int(settings.Timeout().Seconds())
+}
+
+func userAttributes(settings system_setting.LDAPSettings) []string {
+	attributes := make([]string, 0, 5)
+	for _, name := range []string{
+		settings.IdAttribute,
+		settings.UsernameAttribute,
+		settings.DisplayNameAttribute,
+		settings.EmailAttribute,
+		settings.GroupAttribute,
+	} {
+		if name = strings.TrimSpace(name); name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	if len(attributes) == 0 {
+		// "1.1" requests no attributes (RFC 4511), only the DN.
+		attributes = append(attributes, "1.1")
+	}
+	return attributes
+}
+
+func findUser(conn *ldap.Conn, settings system_setting.LDAPSettings, username string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(settings.UserFilter, "{username}", ldap.EscapeFilter(username))
+	result, err := conn.Search(ldap.NewSearchRequest(
+		settings.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, timeLimit(settings), false,
+		filter, userAttributes(settings), nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrAmbiguousUser
+		}
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("search user: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, ErrAmbiguousUser
+	}
+}
+
+func searchGroups(conn *ldap.Conn, settings system_setting.LDAPSettings, userDN string, username string) ([]string, error) {
+	baseDN := strings.TrimSpace(settings.GroupBaseDN)
+	if baseDN == "" {
+		baseDN = settings.BaseDN
+	}
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(userDN),
+		"{username}", ldap.EscapeFilter(username),
+	).Replace(settings.GroupFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, timeLimit(settings), false,
+		filter, []string{"1.1"}, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("search groups: %w", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+func mapEntry(settings system_setting.LDAPSettings, raw *ldap.Entry, username string) *Entry {
+	entry := &Entry{
+		DN:          raw.DN,
+		Subject:     subjectOf(settings, raw),
+		Username:    firstValue(raw, settings.UsernameAttribute),
+		DisplayName: firstValue(raw, settings.DisplayNameAttribute),
+		Email:       firstValue(raw, settings.EmailAttribute),
+		Groups:      []string{},
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+	if name := strings.TrimSpace(settings.GroupAttribute); name != "" {
+		entry.Groups = mergeGroups(entry.Groups, raw.GetEqualFoldAttributeValues(name))
+	}
+	return entry
+}
+
+func firstValue(raw *ldap.Entry, attribute string) string {
+	attribute = strings.TrimSpace(attribute)
+	if attribute == "" {
+		return ""
+	}
+	for _, value := range raw.GetEqualFoldAttributeValues(attribute) {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// subjectOf returns the stable identifier linked to the local account.
+// Binary values such as objectGUID are hex encoded; DNs are normalized so
+// that case or spacing differences between servers map to one account.
+func subjectOf(settings system_setting.LDAPSettings, raw *ldap.Entry) string {
+	subject := ""
+	if attribute := strings.TrimSpace(settings.IdAttribute); attribute != "" {
+		if values := raw.GetEqualFoldRawAttributeValues(attribute); len(values) > 0 && len(values[0]) > 0 {
+			value := values[0]
+			if !utf8.Valid(value) || strings.IndexFunc(string(value), unicode.IsControl) >= 0 {
+				subject = hex.EncodeToString(value)
+			} else {
+				subject = strings.TrimSpace(string(value))
+			}
+		}
+	}
+	if subject == "" {
+		subject = raw.DN
+		if dn, err := ldap.ParseDN(raw.DN); err == nil {
+			subject = dn.String()
+		}
+		subject = strings.ToLower(subject)
+	}
+	if len(subject) > maxSubjectLength {
+		digest := sha256.Sum256([]byte(subject))
+		subject = "sha256:" + hex.EncodeToString(digest[:])
+	}
+	return subject
+}
+
+func mergeGroups(groups []string, more []string) []string {
+	for _, group := range more {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range groups {
+			if strings.EqualFold(existing, group) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// GroupsAllowed reports whether groups satisfy RequiredGroups. An empty
+// RequiredGroups admits every directory user.
+func GroupsAllowed(settings system_setting.LDAPSettings, groups []string) bool {
+	required := 0
+	for _, want := range settings.RequiredGroups {
+		want = strings.TrimSpace(want)
+		if want == "" {
+			continue
+		}
+		required++
+		for _, group := range groups {
+			if groupMatches(group, want) {
+				return true
+			}
+		}
+	}
+	return required == 0
+}
+
+// groupMatches compares a group DN with a required group given either as a
+// DN or as the value of the group's leading RDN, e.g. "staff" for
+// "cn=staff,ou=groups,dc=example,dc=org".
+func groupMatches(group string, want string) bool {
+	if strings.EqualFold(group, want) {
+		return true
+	}
+	groupDN, err := ldap.ParseDN(group)
+	if err != nil || len(groupDN.RDNs) == 0 {
+		return false
+	}
+	if wantDN, err := ldap.ParseDN(want); err == nil && len(wantDN.RDNs) > 0 {
+		return groupDN.EqualFold(wantDN)
+	}
+	for _, attribute := range groupDN.RDNs[0].Attributes {
+		if strings.EqualFold(attribute.Value, want) {
+			return true
+		}
+	}
+	return false
+}
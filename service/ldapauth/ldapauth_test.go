@@ -0,0 +1,374 @@
+package ldapauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBaseDN    = "dc=example,dc=org"
+	testServiceDN = "cn=svc,ou=system,dc=example,dc=org"
+)
+
+// testDirectory is a minimal in-process LDAP server that evaluates search
+// filters properly, unlike gldap's testdirectory which matches on DNs only.
+type testDirectory struct {
+	entries   []*gldap.Entry
+	passwords map[string]string
+	tls       *tls.Config
+}
+
+func (d *testDirectory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer func() { _ = w.Write(resp) }()
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+	if m.UserName == "" && m.Password == "" {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	if password, ok := d.passwords[strings.ToLower(m.UserName)]; ok && password == string(m.Password) {
+		resp.SetResultCode(gldap.ResultSuccess)
+	}
+}
+
+func (d *testDirectory) startTLS(w *gldap.ResponseWriter, r *gldap.Request) {
+	res := r.NewExtendedResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	res.SetResponseName(gldap.ExtendedOperationStartTLS)
+	if err := w.Write(res); err != nil {
+		return
+	}
+	_ = r.StartTLS(d.tls)
+}
+
+func (d *testDirectory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	done := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer func() { _ = w.Write(done) }()
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		done.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+	filter, err := ldap.CompileFilter(m.Filter)
+	if err != nil {
+		done.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+	baseDN := strings.ToLower(m.BaseDN)
+	baseFound := false
+	var matches []*gldap.Entry
+	for _, entry := range d.entries {
+		dn := strings.ToLower(entry.DN)
+		if dn == baseDN {
+			baseFound = true
+		}
+		inScope := dn == baseDN
+		if m.Scope == gldap.WholeSubtree {
+			inScope = inScope || strings.HasSuffix(dn, ","+baseDN)
+		}
+		if inScope && matchFilter(filter, entry) {
+			matches = append(matches, entry)
+		}
+	}
+	if !baseFound {
+		done.SetResultCode(gldap.ResultNoSuchObject)
+		return
+	}
+	for i, entry := range matches {
+		if m.SizeLimit > 0 && int64(i) >= m.SizeLimit {
+			done.SetResultCode(gldap.ResultSizeLimitExceeded)
+			return
+		}
+		result := r.NewSearchResponseEntry(entry.DN)
+		for _, attribute := range entry.Attributes {
+			result.AddAttribute(attribute.Name, attribute.Values)
+		}
+		if err := w.Write(result); err != nil {
+			return
+		}
+	}
+}
+
+func matchFilter(filter *ber.Packet, entry *gldap.Entry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchFilter(filter.Children[0], entry)
+	case ldap.FilterPresent:
+		attribute := filter.Data.String()
+		return strings.EqualFold(attribute, "objectClass") || len(entry.GetAttributeValues(attribute)) > 0
+	case ldap.FilterEqualityMatch:
+		attribute, value := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for _, candidate := range entryValues(entry, attribute) {
+			if strings.EqualFold(candidate, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func entryValues(entry *gldap.Entry, attribute string) []string {
+	for _, candidate := range entry.Attributes {
+		if strings.EqualFold(candidate.Name, attribute) {
+			return candidate.Values
+		}
+	}
+	return nil
+}
+
+type testServer struct {
+	settings system_setting.LDAPSettings
+	caPEM    string
+}
+
+// startTestDirectory serves the directory on a local port, over ldaps:// when
+// useLDAPS is set and with StartTLS support otherwise.
+func startTestDirectory(t *testing.T, useLDAPS bool) *testServer {
+	t.Helper()
+	serverTLS, caPEM := newTestServerTLS(t)
+	guid := string([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff})
+	directory := &testDirectory{
+		tls: serverTLS,
+		entries: []*gldap.Entry{
+			gldap.NewEntry(testBaseDN, map[string][]string{"objectClass": {"domain"}}),
+			gldap.NewEntry("ou=system,dc=example,dc=org", map[string][]string{"objectClass": {"organizationalUnit"}}),
+			gldap.NewEntry("ou=people,dc=example,dc=org", map[string][]string{"objectClass": {"organizationalUnit"}}),
+			gldap.NewEntry("ou=contractors,dc=example,dc=org", map[string][]string{"objectClass": {"organizationalUnit"}}),
+			gldap.NewEntry("ou=groups,dc=example,dc=org", map[string][]string{"objectClass": {"organizationalUnit"}}),
+			gldap.NewEntry(testServiceDN, map[string][]string{"objectClass": {"person"}}),
+			gldap.NewEntry("uid=alice,ou=people,dc=example,dc=org", map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"alice"},
+				"displayName": {"Alice Liddell"},
+				"mail":        {"alice@example.org"},
+				"objectGUID":  {guid},
+				"memberOf":    {"cn=engineering,ou=groups,dc=example,dc=org"},
+			}),
+			gldap.NewEntry("uid=bob,ou=people,dc=example,dc=org", map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"bob"},
+				"mail":        {"bob@example.org"},
+			}),
+			gldap.NewEntry("uid=twin,ou=people,dc=example,dc=org", map[string][]string{"objectClass": {"person"}, "uid": {"twin"}}),
+			gldap.NewEntry("uid=twin,ou=contractors,dc=example,dc=org", map[string][]string{"objectClass": {"person"}, "uid": {"twin"}}),
+			gldap.NewEntry("cn=staff,ou=groups,dc=example,dc=org", map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"member":      {"uid=bob,ou=people,dc=example,dc=org"},
+			}),
+		},
+		passwords: map[string]string{
+			testServiceDN:                           "service-secret",
+			"uid=alice,ou=people,dc=example,dc=org": "alice-password",
+			"uid=bob,ou=people,dc=example,dc=org":   "bob-password",
+		},
+	}
+
+	server, err := gldap.NewServer(gldap.WithLogger(hclog.NewNullLogger()))
+	require.NoError(t, err)
+	mux, err := gldap.NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(directory.bind))
+	require.NoError(t, mux.Search(directory.search))
+	require.NoError(t, mux.ExtendedOperation(directory.startTLS, gldap.ExtendedOperationStartTLS))
+	require.NoError(t, server.Router(mux))
+
+	port := testdirectory.FreePort(t)
+	var runOptions []gldap.Option
+	scheme := "ldap"
+	if useLDAPS {
+		runOptions = append(runOptions, gldap.WithTLSConfig(serverTLS))
+		scheme = "ldaps"
+	}
+	go func() { _ = server.Run(fmt.Sprintf("127.0.0.1:%d", port), runOptions...) }()
+	t.Cleanup(func() { _ = server.Stop() })
+	for !server.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	settings := *system_setting.GetLDAPSettings()
+	settings.Enabled = true
+	settings.ServerURL = fmt.Sprintf("%s://127.0.0.1:%d", scheme, port)
+	settings.BindDN = testServiceDN
+	settings.BindPassword = "service-secret"
+	settings.BaseDN = testBaseDN
+	settings.TimeoutSeconds = 5
+	return &testServer{settings: settings, caPEM: caPEM}
+}
+
+func newTestServerTLS(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAuthenticateMapsEntryAndVerifiesPassword(t *testing.T) {
+	server := startTestDirectory(t, false)
+	settings := server.settings
+	settings.IdAttribute = "objectGUID"
+	ctx := context.Background()
+
+	entry, err := Authenticate(ctx, settings, " alice ", "alice-password")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=org", entry.DN)
+	assert.Equal(t, "00112233445566778899aabbccddeeff", entry.Subject)
+	assert.Equal(t, "alice", entry.Username)
+	assert.Equal(t, "Alice Liddell", entry.DisplayName)
+	assert.Equal(t, "alice@example.org", entry.Email)
+	assert.Equal(t, []string{"cn=engineering,ou=groups,dc=example,dc=org"}, entry.Groups)
+
+	for name, credentials := range map[string][2]string{
+		"wrong password":   {"alice", "bob-password"},
+		"unknown user":     {"mallory", "alice-password"},
+		"empty password":   {"alice", ""},
+		"filter injection": {"*", "alice-password"},
+	} {
+		_, err := Authenticate(ctx, settings, credentials[0], credentials[1])
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+	}
+
+	_, err = Authenticate(ctx, settings, "twin", "anything")
+	assert.ErrorIs(t, err, ErrAmbiguousUser)
+
+	// Without an id attribute the normalized DN links the account.
+	settings.IdAttribute = ""
+	entry, err = Authenticate(ctx, settings, "bob", "bob-password")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=bob,ou=people,dc=example,dc=org", entry.Subject)
+}
+
+func TestAuthenticateEnforcesRequiredGroups(t *testing.T) {
+	server := startTestDirectory(t, false)
+	settings := server.settings
+	settings.GroupFilter = "(&(objectClass=groupOfNames)(member={dn}))"
+	settings.GroupBaseDN = "ou=groups,dc=example,dc=org"
+	ctx := context.Background()
+
+	// memberOf on the entry and the group search are combined.
+	settings.RequiredGroups = []string{"staff"}
+	entry, err := Authenticate(ctx, settings, "bob", "bob-password")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cn=staff,ou=groups,dc=example,dc=org"}, entry.Groups)
+
+	_, err = Authenticate(ctx, settings, "alice", "alice-password")
+	assert.ErrorIs(t, err, ErrGroupDenied)
+
+	settings.RequiredGroups = []string{"CN=Engineering, OU=Groups, DC=example, DC=org"}
+	_, err = Authenticate(ctx, settings, "alice", "alice-password")
+	assert.NoError(t, err)
+	_, err = Authenticate(ctx, settings, "bob", "bob-password")
+	assert.ErrorIs(t, err, ErrGroupDenied)
+}
+
+func TestDialVerifiesServerCertificate(t *testing.T) {
+	ctx := context.Background()
+	for _, useLDAPS := range []bool{false, true} {
+		server := startTestDirectory(t, useLDAPS)
+		settings := server.settings
+		settings.StartTLS = !useLDAPS
+
+		// The self-signed test CA is not in the system roots.
+		_, err := Authenticate(ctx, settings, "alice", "alice-password")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidCredentials)
+
+		settings.CACertificate = server.caPEM
+		_, err = Authenticate(ctx, settings, "alice", "alice-password")
+		assert.NoError(t, err, settings.ServerURL)
+	}
+
+	server := startTestDirectory(t, true)
+	settings := server.settings
+	settings.StartTLS = true
+	_, err := Dial(ctx, settings)
+	assert.Error(t, err)
+}
+
+func TestTestConnectionReportsMappedUser(t *testing.T) {
+	server := startTestDirectory(t, false)
+	settings := server.settings
+	ctx := context.Background()
+
+	entry, err := TestConnection(ctx, settings, "")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	entry, err = TestConnection(ctx, settings, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.org", entry.Email)
+
+	_, err = TestConnection(ctx, settings, "mallory")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	settings.BindPassword = "wrong"
+	_, err = TestConnection(ctx, settings, "")
+	assert.ErrorContains(t, err, "service bind")
+
+	settings = server.settings
+	settings.BaseDN = "dc=missing,dc=org"
+	_, err = TestConnection(ctx, settings, "")
+	assert.ErrorContains(t, err, "read base DN")
+}
+
+func TestGroupsAllowed(t *testing.T) {
+	groups := []string{"cn=staff,ou=groups,dc=example,dc=org", "CN=Domain Admins,CN=Users,DC=corp,DC=example"}
+	assert.True(t, GroupsAllowed(system_setting.LDAPSettings{}, nil))
+	assert.True(t, GroupsAllowed(system_setting.LDAPSettings{RequiredGroups: []string{" "}}, nil))
+	assert.True(t, GroupsAllowed(system_setting.LDAPSettings{RequiredGroups: []string{"domain admins"}}, groups))
+	assert.True(t, GroupsAllowed(system_setting.LDAPSettings{RequiredGroups: []string{"contractors", "STAFF"}}, groups))
+	assert.False(t, GroupsAllowed(system_setting.LDAPSettings{RequiredGroups: []string{"users"}}, groups))
+	assert.False(t, GroupsAllowed(system_setting.LDAPSettings{RequiredGroups: []string{"cn=staff,ou=other,dc=example,dc=org"}}, groups))
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 上游 429 未给出等待时长时的默认冷却时间
+	defaultMultiKeyCooldown = time.Minute
+	maxMultiKeyCooldown     = 24 * time.Hour
+)
+
+// isRateLimitAwareMultiKey 返回当前请求使用的是否为限流感知模式（headroom / lru）多 Key 渠道的某个 Key
+func isRateLimitAwareMultiKey(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	return c != nil && info.HasChannelMeta() && info.ChannelIsMultiKey &&
+		common.GetContextKeyBool(c, constant.ContextKeyChannelMultiKeyRateAware)
+}
+
+// observeMultiKeyRateLimit 根据上游响应更新 Key 的调度状态：
+// 429 时按 Retry-After / x-ratelimit-reset-* 冷却该 Key，其余响应记录上游返回的剩余额度。
+func observeMultiKeyRateLimit(c *gin.Context, info *relaycommon.RelayInfo, statusCode int, rateLimit UpstreamRateLimit, now time.Time) {
+	if !isRateLimitAwareMultiKey(c, info) {
+		return
+	}
+	channelId, keyIndex := info.ChannelId, info.ChannelMultiKeyIndex
+	if statusCode == http.StatusTooManyRequests {
+		cooldown := rateLimit.CooldownDuration()
+		if cooldown <= 0 {
+			cooldown = defaultMultiKeyCooldown
+		}
+		cooldown = min(cooldown, maxMultiKeyCooldown)
+		until := now.Unix() + int64(math.Ceil(cooldown.Seconds()))
+		model.ParkMultiKey(channelId, keyIndex, until, fmt.Sprintf("upstream 429, cooling down for %s", cooldown.Round(time.Second)))
+		logger.LogWarn(c, fmt.Sprintf("channel #%d key #%d rate limited by upstream, cooling down for %s", channelId, keyIndex, cooldown))
+		return
+	}
+	if rateLimit.RemainingRequests == nil && rateLimit.RemainingTokens == nil {
+		return
+	}
+	gopool.Go(func() {
+		model.SetMultiKeyUpstreamRemaining(channelId, keyIndex, rateLimit.RemainingRequests, rateLimit.RemainingTokens)
+	})
+}
+
+// ShouldParkInsteadOfDisable 返回该错误是否已通过冷却 Key 处理：限流感知模式下的 429 只冷却 Key，不禁用渠道或 Key
+func ShouldParkInsteadOfDisable(c *gin.Context, err *types.NewAPIError) bool {
+	return err != nil && err.StatusCode == http.StatusTooManyRequests && c != nil &&
+		common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) &&
+		common.GetContextKeyBool(c, constant.ContextKeyChannelMultiKeyRateAware)
+}
+
+// recordMultiKeyTokenUsage 在结算时把请求消耗的 Token 计入所用 Key 的 TPM
+func recordMultiKeyTokenUsage(c *gin.Context, info *relaycommon.RelayInfo, tokens int) {
+	if tokens <= 0 || !isRateLimitAwareMultiKey(c, info) {
+		return
+	}
+	channelId, keyIndex := info.ChannelId, info.ChannelMultiKeyIndex
+	gopool.Go(func() {
+		model.RecordMultiKeyTokens(channelId, keyIndex, int64(tokens))
+	})
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/console_setting"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// IsPaymentComplianceOptionKey 判断设置项是否为支付合规确认字段，这些字段只能通过专用接口修改
+func IsPaymentComplianceOptionKey(key string) bool {
+	return strings.HasPrefix(key, "payment_setting.compliance_")
+}
+
+func isPositiveOptionValue(value string) bool {
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil {
+		return intValue > 0
+	}
+	floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil && floatValue > 0
+}
+
+// ValidateOptionFormat checks option values that have a structured format.
+// Shared by the option editor and declarative config apply.
+func ValidateOptionFormat(key string, value string) error {
+	switch key {
+	case "GroupRatio":
+		return ratio_setting.CheckGroupRatio(value)
+	case "gemini.safety_settings":
+		return model_setting.ValidateGeminiSafetySettings(value)
+	case "claude.default_max_tokens":
+		return model_setting.ValidateClaudeDefaultMaxTokens(value)
+	case operation_setting.ToolPriceOptionKey:
+		return operation_setting.ValidateToolPricesJSON(value)
+	case "ModelRequestRateLimitGroup":
+		return setting.CheckModelRequestRateLimitGroup(value)
+	case "AutomaticDisableStatusCodes", "AutomaticRetryStatusCodes":
+		_, err := operation_setting.ParseHTTPStatusCodeRanges(value)
+		return err
+	case "console_setting.api_info":
+		return console_setting.ValidateConsoleSettings(value, "ApiInfo")
+	case "console_setting.announcements":
+		return console_setting.ValidateConsoleSettings(value, "Announcements")
+	case "console_setting.faq":
+		return console_setting.ValidateConsoleSettings(value, "FAQ")
+	case "console_setting.uptime_kuma_groups":
+		return console_setting.ValidateConsoleSettings(value, "UptimeKumaGroups")
+	}
+	return nil
+}
+
+// ErrPaymentComplianceRequired 表示开启邀请奖励前需要先完成支付合规确认
+var ErrPaymentComplianceRequired = errors.New("payment compliance required")
+
+// ValidateOptionUpdate 校验一次设置项写入。设置编辑器、配置文档应用、历史回滚与变更集共用这套规则，
+// 任何写入设置的入口都不能绕过
+func ValidateOptionUpdate(key string, value string) error {
+	switch key {
+	case "QuotaForInviter", "QuotaForInvitee":
+		if isPositiveOptionValue(value) && !operation_setting.IsPaymentComplianceConfirmed() {
+			return ErrPaymentComplianceRequired
+		}
+	default:
+		if IsPaymentComplianceOptionKey(key) {
+			return errors.New("合规确认字段不允许通过通用设置接口修改")
+		}
+	}
+	switch key {
+	case "GitHubOAuthEnabled":
+		if value == "true" && common.GitHubClientId == "" {
+			return errors.New("无法启用 GitHub OAuth，请先填入 GitHub Client Id 以及 GitHub Client Secret！")
+		}
+	case "discord.enabled":
+		if value == "true" && system_setting.GetDiscordSettings().ClientId == "" {
+			return errors.New("无法启用 Discord OAuth，请先填入 Discord Client Id 以及 Discord Client Secret！")
+		}
+	case "oidc.enabled":
+		if value == "true" && system_setting.GetOIDCSettings().ClientId == "" {
+			return errors.New("无法启用 OIDC 登录，请先填入 OIDC Client Id 以及 OIDC Client Secret！")
+		}
+	case "ldap.enabled":
+		if value == "true" && (strings.TrimSpace(system_setting.GetLDAPSettings().ServerURL) == "" || strings.TrimSpace(system_setting.GetLDAPSettings().BaseDN) == "") {
+			return errors.New("无法启用 LDAP 登录，请先填入 LDAP 服务器地址以及 Base DN！")
+		}
+	case "LinuxDOOAuthEnabled":
+		if value == "true" && common.LinuxDOClientId == "" {
+			return errors.New("无法启用 LinuxDO OAuth，请先填入 LinuxDO Client Id 以及 LinuxDO Client Secret！")
+		}
+	case "EmailDomainRestrictionEnabled":
+		if value == "true" && len(common.EmailDomainWhitelist) == 0 {
+			return errors.New("无法启用邮箱域名限制，请先填入限制的邮箱域名！")
+		}
+	case "WeChatAuthEnabled":
+		if value == "true" && common.WeChatServerAddress == "" {
+			return errors.New("无法启用微信登录，请先填入微信登录相关配置信息！")
+		}
+	case "TurnstileCheckEnabled":
+		if value == "true" && common.TurnstileSiteKey == "" {
+			return errors.New("无法启用 Turnstile 校验，请先填入 Turnstile 校验相关配置信息！")
+		}
+	case "TelegramOAuthEnabled":
+		if value == "true" && common.TelegramBotToken == "" {
+			return errors.New("无法启用 Telegram OAuth，请先填入 Telegram Bot Token！")
+		}
+	case "SCIMEnabled":
+		if value == "true" && len(common.SCIMBearerToken) < 32 {
+			return errors.New("无法启用 SCIM 同步，请先填入至少 32 位的 SCIM Bearer Token！")
+		}
+	case "theme.frontend":
+		if value != "default" {
+			return errors.New("Classic 前端已移除，主题只能设置为 default")
+		}
+	case "ImageRatio":
+		if err := validateRatioMapJSON(value); err != nil {
+			return errors.New("图片倍率设置失败: " + err.Error())
+		}
+	case "AudioRatio":
+		if err := validateRatioMapJSON(value); err != nil {
+			return errors.New("音频倍率设置失败: " + err.Error())
+		}
+	case "AudioCompletionRatio":
+		if err := validateRatioMapJSON(value); err != nil {
+			return errors.New("音频补全倍率设置失败: " + err.Error())
+		}
+	case "CreateCacheRatio":
+		if err := validateRatioMapJSON(value); err != nil {
+			return errors.New("缓存创建倍率设置失败: " + err.Error())
+		}
+	case "StripeCurrencyUnitPrices":
+		if err := setting.ValidateStripeCurrencyUnitPrices(value); err != nil {
+			return errors.New("Stripe 货币单价设置失败: " + err.Error())
+		}
+	}
+	return ValidateOptionFormat(key, value)
+}
+
+// validateRatioMapJSON 校验模型 -> 倍率的 JSON 映射，实际生效在保存设置时完成
+func validateRatioMapJSON(value string) error {
+	var ratios map[string]float64
+	return common.UnmarshalJsonStr(value, &ratios)
+}
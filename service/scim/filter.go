@@ -0,0 +1,122 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Filter operators supported by ParseFilter.
+const (
+	OpEqual      = "eq"
+	OpNotEqual   = "ne"
+	OpContains   = "co"
+	OpStartsWith = "sw"
+	OpEndsWith   = "ew"
+	OpPresent    = "pr"
+)
+
+// Filter is a single attribute expression such as `userName eq "alice"`.
+// Logical operators and grouping are not supported; identity providers only
+// send single expressions when looking up resources.
+type Filter struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+var filterPattern = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9_.:\-]*)\s+([A-Za-z]{2})(?:\s+(.+?))?\s*$`)
+
+func invalidFilter(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, "invalidFilter", format, args...)
+}
+
+// ParseFilter parses a filter expression. An empty expression yields nil.
+// Attribute names are returned lower-cased with any schema URN removed.
+func ParseFilter(expr string) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	matches := filterPattern.FindStringSubmatch(expr)
+	if matches == nil {
+		return nil, invalidFilter("unsupported filter %q", expr)
+	}
+	filter := &Filter{
+		Attribute: NormalizeAttribute(matches[1]),
+		Operator:  strings.ToLower(matches[2]),
+	}
+	switch filter.Operator {
+	case OpPresent:
+		if matches[3] != "" {
+			return nil, invalidFilter("unsupported filter %q", expr)
+		}
+		return filter, nil
+	case OpEqual, OpNotEqual, OpContains, OpStartsWith, OpEndsWith:
+	default:
+		return nil, invalidFilter("unsupported filter operator %q", matches[2])
+	}
+	value, err := parseFilterValue(matches[3])
+	if err != nil {
+		return nil, invalidFilter("invalid filter value in %q", expr)
+	}
+	filter.Value = value
+	return filter, nil
+}
+
+// parseFilterValue accepts a JSON string, boolean or number literal.
+func parseFilterValue(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, `"`) {
+		var value string
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	var literal any
+	if err := json.Unmarshal([]byte(raw), &literal); err != nil {
+		return "", err
+	}
+	switch literal.(type) {
+	case bool, float64:
+		return strings.ToLower(raw), nil
+	}
+	return "", invalidFilter("unsupported filter value %q", raw)
+}
+
+// Match reports whether an attribute value satisfies the filter. String
+// comparisons are case-insensitive, matching the caseExact=false default.
+func (f *Filter) Match(value string) bool {
+	actual := strings.ToLower(value)
+	expected := strings.ToLower(f.Value)
+	switch f.Operator {
+	case OpEqual:
+		return actual == expected
+	case OpNotEqual:
+		return actual != expected
+	case OpContains:
+		return strings.Contains(actual, expected)
+	case OpStartsWith:
+		return strings.HasPrefix(actual, expected)
+	case OpEndsWith:
+		return strings.HasSuffix(actual, expected)
+	case OpPresent:
+		return value != ""
+	}
+	return false
+}
+
+// NormalizeAttribute lower-cases an attribute path and strips the core
+// schema URN prefix, so "urn:...:User:userName" and "USERNAME" both become
+// "username".
+func NormalizeAttribute(attr string) string {
+	attr = strings.TrimSpace(attr)
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(attr) > len(schema) && strings.EqualFold(attr[:len(schema)+1], schema+":") {
+			attr = attr[len(schema)+1:]
+			break
+		}
+	}
+	return strings.ToLower(attr)
+}
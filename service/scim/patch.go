@@ -0,0 +1,351 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	PatchAdd     = "add"
+	PatchReplace = "replace"
+	PatchRemove  = "remove"
+)
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func invalidPath(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, "invalidPath", format, args...)
+}
+
+// normalizeOp accepts the capitalised operation names some identity
+// providers send ("Replace", "Add").
+func (op PatchOperation) normalizeOp() (string, error) {
+	name := strings.ToLower(strings.TrimSpace(op.Op))
+	switch name {
+	case PatchAdd, PatchReplace, PatchRemove:
+		return name, nil
+	}
+	return "", InvalidValue("unsupported patch operation %q", op.Op)
+}
+
+// patchPath is a parsed attribute path: attr[filter].sub
+type patchPath struct {
+	attr   string
+	filter *Filter
+	sub    string
+}
+
+func parsePatchPath(path string) (patchPath, error) {
+	path = strings.TrimSpace(path)
+	if open := strings.Index(path, "["); open >= 0 {
+		end := strings.LastIndex(path, "]")
+		if end < open {
+			return patchPath{}, invalidPath("invalid path %q", path)
+		}
+		filter, err := ParseFilter(path[open+1 : end])
+		if err != nil || filter == nil {
+			return patchPath{}, invalidPath("invalid path filter in %q", path)
+		}
+		return patchPath{
+			attr:   NormalizeAttribute(path[:open]),
+			filter: filter,
+			sub:    strings.ToLower(strings.TrimPrefix(path[end+1:], ".")),
+		}, nil
+	}
+	path = NormalizeAttribute(path)
+	result := patchPath{attr: path}
+	if dot := strings.Index(path, "."); dot >= 0 {
+		result.attr = path[:dot]
+		result.sub = path[dot+1:]
+	}
+	return result, nil
+}
+
+// decodeString accepts a JSON string and, for robustness, bare numbers and
+// booleans.
+func decodeString(raw json.RawMessage) (string, error) {
+	var value any
+	if len(raw) == 0 {
+		return "", nil
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", InvalidValue("invalid value: %v", err)
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64, bool:
+		return strings.TrimSpace(string(raw)), nil
+	}
+	return "", InvalidValue("expected a string value")
+}
+
+// decodeBool accepts JSON booleans and the "True"/"False" strings sent by
+// some identity providers.
+func decodeBool(raw json.RawMessage) (bool, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false, InvalidValue("invalid value: %v", err)
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return false, InvalidValue("expected a boolean value")
+}
+
+// ApplyUserPatch applies PATCH operations to a user resource in place.
+// Attributes the gateway does not store (addresses, phone numbers, extension
+// schemas and so on) are accepted and ignored so that identity providers
+// sending their full attribute mapping keep working.
+func ApplyUserPatch(user *User, operations []PatchOperation) error {
+	for _, operation := range operations {
+		op, err := operation.normalizeOp()
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(operation.Path) == "" {
+			if op == PatchRemove {
+				return NewError(http.StatusBadRequest, "noTarget", "remove requires a path")
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return InvalidValue("patch without a path requires an object value")
+			}
+			for attr, value := range values {
+				path, err := parsePatchPath(attr)
+				if err != nil {
+					return err
+				}
+				if err := applyUserAttribute(user, op, path, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		path, err := parsePatchPath(operation.Path)
+		if err != nil {
+			return err
+		}
+		if err := applyUserAttribute(user, op, path, operation.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyUserAttribute(user *User, op string, path patchPath, value json.RawMessage) error {
+	if op == PatchRemove {
+		value = nil
+	}
+	switch path.attr {
+	case "active":
+		if op == PatchRemove {
+			return NewError(http.StatusBadRequest, "mutability", "active cannot be removed")
+		}
+		active, err := decodeBool(value)
+		if err != nil {
+			return err
+		}
+		user.Active = &active
+	case "username":
+		if op == PatchRemove {
+			return NewError(http.StatusBadRequest, "mutability", "userName cannot be removed")
+		}
+		userName, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		user.UserName = userName
+	case "displayname":
+		displayName, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		user.DisplayName = displayName
+	case "externalid":
+		externalId, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		user.ExternalId = externalId
+	case "password":
+		password, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		user.Password = password
+	case "name":
+		return applyUserName(user, path.sub, value)
+	case "emails":
+		return applyUserEmails(user, op, path, value)
+	}
+	return nil
+}
+
+func applyUserName(user *User, sub string, value json.RawMessage) error {
+	if user.Name == nil {
+		user.Name = &Name{}
+	}
+	if sub == "" {
+		name := Name{}
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &name); err != nil {
+				return InvalidValue("invalid name value")
+			}
+		}
+		user.Name = &name
+		return nil
+	}
+	text, err := decodeString(value)
+	if err != nil {
+		return err
+	}
+	switch sub {
+	case "formatted":
+		user.Name.Formatted = text
+	case "givenname":
+		user.Name.GivenName = text
+	case "familyname":
+		user.Name.FamilyName = text
+	}
+	return nil
+}
+
+// applyUserEmails supports replacing the whole list as well as the
+// `emails[type eq "work"].value` form. The gateway keeps a single address, so
+// any targeted email becomes the primary one.
+func applyUserEmails(user *User, op string, path patchPath, value json.RawMessage) error {
+	if op == PatchRemove {
+		user.Emails = nil
+		return nil
+	}
+	if path.filter != nil || path.sub != "" {
+		email, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		user.Emails = []Email{{Value: email, Primary: true}}
+		return nil
+	}
+	var emails []Email
+	if err := json.Unmarshal(value, &emails); err != nil {
+		return InvalidValue("emails must be an array")
+	}
+	user.Emails = emails
+	return nil
+}
+
+// GroupPatch is the membership change described by a group PATCH request.
+// When ReplaceMembers is set, Members is the complete new member list and
+// Add/Remove are applied on top of it.
+type GroupPatch struct {
+	ReplaceMembers bool
+	Members        []string
+	Add            []string
+	Remove         []string
+	DisplayName    string
+}
+
+// ParseGroupPatch converts PATCH operations on a group into a GroupPatch.
+func ParseGroupPatch(operations []PatchOperation) (*GroupPatch, error) {
+	patch := &GroupPatch{}
+	for _, operation := range operations {
+		op, err := operation.normalizeOp()
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(operation.Path) == "" {
+			if op == PatchRemove {
+				return nil, NewError(http.StatusBadRequest, "noTarget", "remove requires a path")
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return nil, InvalidValue("patch without a path requires an object value")
+			}
+			for attr, value := range values {
+				if err := patch.apply(op, patchPath{attr: NormalizeAttribute(attr)}, value); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		path, err := parsePatchPath(operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := patch.apply(op, path, operation.Value); err != nil {
+			return nil, err
+		}
+	}
+	return patch, nil
+}
+
+func (patch *GroupPatch) apply(op string, path patchPath, value json.RawMessage) error {
+	switch path.attr {
+	case "displayname":
+		if op == PatchRemove {
+			return NewError(http.StatusBadRequest, "mutability", "displayName cannot be removed")
+		}
+		name, err := decodeString(value)
+		if err != nil {
+			return err
+		}
+		patch.DisplayName = name
+	case "members":
+		if path.filter != nil {
+			if op != PatchRemove || path.filter.Attribute != "value" || path.filter.Operator != OpEqual {
+				return invalidPath("only remove with members[value eq \"id\"] is supported")
+			}
+			patch.Remove = append(patch.Remove, path.filter.Value)
+			return nil
+		}
+		var members []Member
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return InvalidValue("members must be an array")
+			}
+		}
+		ids := make([]string, 0, len(members))
+		for _, member := range members {
+			ids = append(ids, member.Value)
+		}
+		switch op {
+		case PatchAdd:
+			patch.Add = append(patch.Add, ids...)
+		case PatchReplace:
+			patch.ReplaceMembers = true
+			patch.Members = ids
+			patch.Add = nil
+			patch.Remove = nil
+		case PatchRemove:
+			if len(value) == 0 {
+				patch.ReplaceMembers = true
+				patch.Members = nil
+				patch.Add = nil
+				patch.Remove = nil
+			} else {
+				patch.Remove = append(patch.Remove, ids...)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+// Package scim implements the protocol side of the SCIM 2.0 provisioning API
+// (RFC 7643 / RFC 7644): resource shapes, error responses, filters and PATCH
+// operations. Persistence is left to the caller.
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+
+	// ContentType is the media type of every SCIM response.
+	ContentType = "application/scim+json"
+
+	DefaultPageSize = 100
+	MaxPageSize     = 500
+)
+
+// Error is a SCIM error response. It doubles as a Go error so handlers can
+// return it from helpers and render it in one place.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+
+	code int
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e *Error) StatusCode() int {
+	return e.code
+}
+
+// NewError builds a SCIM error; scimType may be empty.
+func NewError(status int, scimType string, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+		code:     status,
+	}
+}
+
+func InvalidValue(format string, args ...any) *Error {
+	return NewError(http.StatusBadRequest, "invalidValue", format, args...)
+}
+
+func NotFound(format string, args ...any) *Error {
+	return NewError(http.StatusNotFound, "", format, args...)
+}
+
+func Conflict(format string, args ...any) *Error {
+	return NewError(http.StatusConflict, "uniqueness", format, args...)
+}
+
+// Meta is the common resource metadata.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// GroupRef is the read-only group membership listed on a user.
+type GroupRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is the core User resource. Password is accepted so that identity providers
+// sending it are not rejected, but it is never stored or rendered.
+type User struct {
+	Schemas     []string   `json:"schemas"`
+	Id          string     `json:"id,omitempty"`
+	ExternalId  string     `json:"externalId,omitempty"`
+	UserName    string     `json:"userName"`
+	Name        *Name      `json:"name,omitempty"`
+	DisplayName string     `json:"displayName,omitempty"`
+	Password    string     `json:"password,omitempty"`
+	Active      *bool      `json:"active,omitempty"`
+	Emails      []Email    `json:"emails,omitempty"`
+	Groups      []GroupRef `json:"groups,omitempty"`
+	Meta        *Meta      `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email, or the first one when none is
+// flagged as primary.
+func (u *User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return strings.TrimSpace(email.Value)
+		}
+	}
+	if len(u.Emails) > 0 {
+		return strings.TrimSpace(u.Emails[0].Value)
+	}
+	return ""
+}
+
+// ResolvedDisplayName prefers displayName and falls back to the name parts.
+func (u *User) ResolvedDisplayName() string {
+	if name := strings.TrimSpace(u.DisplayName); name != "" {
+		return name
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if name := strings.TrimSpace(u.Name.Formatted); name != "" {
+		return name
+	}
+	return strings.TrimSpace(strings.TrimSpace(u.Name.GivenName) + " " + strings.TrimSpace(u.Name.FamilyName))
+}
+
+// IsActive treats a missing active attribute as active, as IdPs omit it on
+// creation.
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	ExternalId  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// MemberIds returns the member values of the group.
+func (g *Group) MemberIds() []string {
+	ids := make([]string, 0, len(g.Members))
+	for _, member := range g.Members {
+		ids = append(ids, member.Value)
+	}
+	return ids
+}
+
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+func NewListResponse(resources []any, total int64, startIndex int) *ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// ParsePagination reads the 1-based startIndex and count query parameters,
+// clamping them to sane bounds as RFC 7644 section 3.4.2.4 allows.
+func ParsePagination(startIndex string, count string) (int, int) {
+	start, err := strconv.Atoi(startIndex)
+	if err != nil || start < 1 {
+		start = 1
+	}
+	size, err := strconv.Atoi(count)
+	if err != nil {
+		size = DefaultPageSize
+	}
+	if size < 0 {
+		size = 0
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return start, size
+}
+
+// ServiceProviderConfig advertises the supported protocol features.
+func ServiceProviderConfig() map[string]any {
+	return map[string]any{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": MaxPageSize},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with the SCIM bearer token configured by the administrator",
+			"primary":     true,
+		}},
+	}
+}
+
+// ResourceTypes describes the User and Group endpoints.
+func ResourceTypes() []any {
+	return []any{
+		map[string]any{
+			"schemas":  []string{SchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   SchemaUser,
+		},
+		map[string]any{
+			"schemas":  []string{SchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   SchemaGroup,
+		},
+	}
+}
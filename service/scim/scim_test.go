@@ -0,0 +1,102 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter(`userName eq "Alice"`)
+	require.NoError(t, err)
+	assert.Equal(t, &Filter{Attribute: "username", Operator: OpEqual, Value: "Alice"}, filter)
+	assert.True(t, filter.Match("alice"))
+
+	filter, err = ParseFilter(`urn:ietf:params:scim:schemas:core:2.0:User:emails.value SW "ops@"`)
+	require.NoError(t, err)
+	assert.Equal(t, "emails.value", filter.Attribute)
+	assert.Equal(t, OpStartsWith, filter.Operator)
+
+	filter, err = ParseFilter("externalId pr")
+	require.NoError(t, err)
+	assert.Equal(t, OpPresent, filter.Operator)
+
+	filter, err = ParseFilter("")
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	for _, expr := range []string{
+		`userName eq "a" and active eq true`,
+		`userName gt "a"`,
+		`userName eq alice`,
+	} {
+		_, err := ParseFilter(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	start, count := ParsePagination("", "")
+	assert.Equal(t, 1, start)
+	assert.Equal(t, DefaultPageSize, count)
+
+	start, count = ParsePagination("0", "100000")
+	assert.Equal(t, 1, start)
+	assert.Equal(t, MaxPageSize, count)
+}
+
+func decodePatch(t *testing.T, body string) []PatchOperation {
+	t.Helper()
+	var request PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &request))
+	return request.Operations
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	active := true
+	user := &User{UserName: "alice", Active: &active, Emails: []Email{{Value: "alice@example.com", Primary: true}}}
+	operations := decodePatch(t, `{"Operations":[
+		{"op":"Replace","path":"active","value":"False"},
+		{"op":"replace","path":"emails[type eq \"work\"].value","value":"alice@corp.example.com"},
+		{"op":"add","value":{"displayName":"Alice A","name.givenName":"Alice","title":"Engineer"}},
+		{"op":"add","path":"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department","value":"Ops"}
+	]}`)
+	require.NoError(t, ApplyUserPatch(user, operations))
+
+	assert.False(t, user.IsActive())
+	assert.Equal(t, "alice@corp.example.com", user.PrimaryEmail())
+	assert.Equal(t, "Alice A", user.ResolvedDisplayName())
+	assert.Equal(t, "Alice", user.Name.GivenName)
+
+	err := ApplyUserPatch(user, decodePatch(t, `{"Operations":[{"op":"remove","path":"userName"}]}`))
+	assert.Error(t, err)
+	err = ApplyUserPatch(user, decodePatch(t, `{"Operations":[{"op":"move","path":"userName","value":"x"}]}`))
+	assert.Error(t, err)
+}
+
+func TestParseGroupPatch(t *testing.T) {
+	patch, err := ParseGroupPatch(decodePatch(t, `{"Operations":[
+		{"op":"add","path":"members","value":[{"value":"3"},{"value":"4"}]},
+		{"op":"remove","path":"members[value eq \"5\"]"}
+	]}`))
+	require.NoError(t, err)
+	assert.False(t, patch.ReplaceMembers)
+	assert.Equal(t, []string{"3", "4"}, patch.Add)
+	assert.Equal(t, []string{"5"}, patch.Remove)
+
+	patch, err = ParseGroupPatch(decodePatch(t, `{"Operations":[
+		{"op":"add","path":"members","value":[{"value":"3"}]},
+		{"op":"replace","value":{"members":[{"value":"7"}]}}
+	]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.ReplaceMembers)
+	assert.Equal(t, []string{"7"}, patch.Members)
+	assert.Empty(t, patch.Add)
+
+	patch, err = ParseGroupPatch(decodePatch(t, `{"Operations":[{"op":"remove","path":"members"}]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.ReplaceMembers)
+	assert.Empty(t, patch.Members)
+}
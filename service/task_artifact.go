@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+const (
+	taskArtifactBatchSize       = 20
+	taskArtifactMaxAttempts     = 3
+	taskArtifactDownloadTimeout = 10 * time.Minute
+)
+
+// TaskArtifactRunSummary is the result recorded on a task_artifact system
+// task row.
+type TaskArtifactRunSummary struct {
+	Stored   int `json:"stored"`
+	Failed   int `json:"failed"`
+	Retrying int `json:"retrying"`
+	Expired  int `json:"expired"`
+}
+
+// errTaskArtifactRejected marks failures that retrying cannot fix, such as a
+// result above the size limit or the user's quota.
+var errTaskArtifactRejected = errors.New("task artifact rejected")
+
+// queueTaskArtifact records a pending artifact for a task that just
+// succeeded with a direct upstream result URL. It reports whether one was
+// queued; the task callback is then sent once the artifact is processed, so
+// clients receive the gateway URL instead of the upstream one.
+func queueTaskArtifact(task *model.Task) bool {
+	if !system_setting.GetTaskArtifactSettings().Enabled || task.Status != model.TaskStatusSuccess {
+		return false
+	}
+	sourceURL := strings.TrimSpace(task.GetUpstreamResultURL())
+	if !isRehostableTaskResultURL(sourceURL) {
+		return false
+	}
+	artifact := &model.TaskArtifact{
+		TaskId:    task.TaskID,
+		UserId:    task.UserId,
+		SourceURL: sourceURL,
+	}
+	created, err := model.CreateTaskArtifact(artifact)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to record artifact of task %s: %s", task.TaskID, err.Error()))
+		return false
+	}
+	if !created {
+		return false
+	}
+	if _, _, err := EnqueueSystemTask(model.SystemTaskTypeTaskArtifact, nil); err != nil {
+		common.SysError("failed to enqueue task artifact task: " + err.Error())
+	}
+	return true
+}
+
+// isRehostableTaskResultURL reports whether url points at upstream storage.
+// Inline data URLs and the gateway's own proxy URLs are left alone.
+func isRehostableTaskResultURL(resultURL string) bool {
+	parsed, err := url.Parse(resultURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	serverAddress := strings.TrimSuffix(system_setting.ServerAddress, "/")
+	return serverAddress == "" || !strings.HasPrefix(resultURL, serverAddress+"/")
+}
+
+// RunTaskArtifactsOnce downloads one batch of pending artifacts and deletes
+// artifacts past their retention. Artifacts that fail are retried on later
+// runs up to taskArtifactMaxAttempts times, after which the task keeps its
+// upstream URL.
+func RunTaskArtifactsOnce(ctx context.Context) TaskArtifactRunSummary {
+	summary := TaskArtifactRunSummary{}
+	artifacts, err := model.FindPendingTaskArtifacts(taskArtifactBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task artifact query failed: %v", err))
+	}
+	for _, artifact := range artifacts {
+		if ctx.Err() != nil {
+			break
+		}
+		processTaskArtifact(ctx, artifact)
+		switch artifact.Status {
+		case model.TaskArtifactStatusStored:
+			summary.Stored++
+		case model.TaskArtifactStatusFailed:
+			summary.Failed++
+		default:
+			summary.Retrying++
+		}
+	}
+	if ctx.Err() == nil {
+		summary.Expired = deleteExpiredTaskArtifacts(ctx)
+	}
+	return summary
+}
+
+func processTaskArtifact(ctx context.Context, artifact *model.TaskArtifact) {
+	settings := system_setting.GetTaskArtifactSettings()
+	artifact.Attempts++
+	err := storeTaskArtifact(ctx, settings, artifact)
+	now := time.Now()
+	switch {
+	case err == nil:
+		artifact.Status = model.TaskArtifactStatusStored
+		artifact.LastError = ""
+	case errors.Is(err, errTaskArtifactRejected) || artifact.Attempts >= taskArtifactMaxAttempts:
+		artifact.Status = model.TaskArtifactStatusFailed
+		artifact.LastError = err.Error()
+	default:
+		artifact.LastError = err.Error()
+	}
+	if artifact.Status != model.TaskArtifactStatusPending {
+		artifact.ExpiresTime = now.Add(settings.Retention()).Unix()
+	}
+	if err := model.SaveTaskArtifact(artifact); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("failed to save task artifact %d: %v", artifact.Id, err))
+		return
+	}
+	if artifact.Status == model.TaskArtifactStatusPending {
+		return
+	}
+
+	task, exist, err := model.GetByTaskId(artifact.UserId, artifact.TaskId)
+	if err != nil || !exist {
+		return
+	}
+	if artifact.Status == model.TaskArtifactStatusStored {
+		if err := model.SetTaskArtifactId(task, artifact.Id); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to link artifact %d to task %s: %v", artifact.Id, task.TaskID, err))
+		}
+	}
+	// the callback was held back by queueTaskArtifact until now
+	enqueueTaskCallback(task)
+}
+
+// storeTaskArtifact downloads the upstream result into a temporary file and
+// uploads it to the configured backend.
+func storeTaskArtifact(ctx context.Context, settings *system_setting.TaskArtifactSettings, artifact *model.TaskArtifact) error {
+	store, err := taskArtifactStore(settings.Backend)
+	if err != nil {
+		return err
+	}
+	file, size, contentType, err := downloadTaskArtifact(ctx, artifact.SourceURL, settings.MaxFileSize())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	if quota := settings.UserQuota(); quota > 0 {
+		used, err := model.SumUserTaskArtifactSize(artifact.UserId)
+		if err != nil {
+			return err
+		}
+		if used+size > quota {
+			return fmt.Errorf("%w: user storage quota of %d MB exceeded", errTaskArtifactRejected, settings.UserQuotaMB)
+		}
+	}
+
+	key := fmt.Sprintf("tasks/%d/%s", artifact.UserId, artifact.TaskId)
+	if err := store.Put(ctx, key, file, size, contentType); err != nil {
+		return err
+	}
+	artifact.Backend = settings.Backend
+	artifact.StorageKey = key
+	artifact.Size = size
+	artifact.ContentType = contentType
+	return nil
+}
+
+func downloadTaskArtifact(ctx context.Context, sourceURL string, maxSize int64) (*os.File, int64, string, error) {
+	if err := ValidateSSRFProtectedFetchURL(sourceURL); err != nil {
+		return nil, 0, "", fmt.Errorf("%w: %v", errTaskArtifactRejected, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, taskArtifactDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	resp, err := GetSSRFProtectedHTTPClient().Do(req)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, "", fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, 0, "", fmt.Errorf("%w: result exceeds %d bytes", errTaskArtifactRejected, maxSize)
+	}
+
+	file, err := os.CreateTemp("", "task-artifact-*")
+	if err != nil {
+		return nil, 0, "", err
+	}
+	size, err := io.Copy(file, io.LimitReader(resp.Body, maxSize+1))
+	if err == nil && size > maxSize {
+		err = fmt.Errorf("%w: result exceeds %d bytes", errTaskArtifactRejected, maxSize)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, "", err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return file, size, contentType, nil
+}
+
+// deleteExpiredTaskArtifacts removes artifacts past their retention. Tasks
+// whose artifact is removed fall back to their upstream result URL.
+func deleteExpiredTaskArtifacts(ctx context.Context) int {
+	artifacts, err := model.FindExpiredTaskArtifacts(common.GetTimestamp(), taskArtifactBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("expired task artifact query failed: %v", err))
+		return 0
+	}
+	deleted := 0
+	for _, artifact := range artifacts {
+		if artifact.Status == model.TaskArtifactStatusStored {
+			store, err := taskArtifactStore(artifact.Backend)
+			if err == nil {
+				err = store.Delete(ctx, artifact.StorageKey)
+			}
+			if err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("failed to delete task artifact %d: %v", artifact.Id, err))
+				continue
+			}
+			task, exist, err := model.GetByTaskId(artifact.UserId, artifact.TaskId)
+			if err == nil && exist && task.PrivateData.ArtifactId == artifact.Id {
+				if err := model.SetTaskArtifactId(task, 0); err != nil {
+					logger.LogWarn(ctx, fmt.Sprintf("failed to unlink artifact %d from task %s: %v", artifact.Id, task.TaskID, err))
+					continue
+				}
+			}
+		}
+		if err := model.DeleteTaskArtifact(artifact.Id); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to delete task artifact %d: %v", artifact.Id, err))
+			continue
+		}
+		deleted++
+	}
+	return deleted
+}
+
+// taskArtifactStore builds the store for backend from the current settings.
+func taskArtifactStore(backend string) (objectstore.Store, error) {
+	settings := system_setting.GetTaskArtifactSettings()
+	switch backend {
+	case system_setting.TaskArtifactBackendLocal, "":
+		if settings.LocalPath == "" {
+			return nil, errors.New("task artifact local path is not configured")
+		}
+		return objectstore.NewLocal(settings.LocalPath), nil
+	case system_setting.TaskArtifactBackendS3:
+		return objectstore.NewS3(objectstore.S3Config{
+			Endpoint:        settings.S3Endpoint,
+			Region:          settings.S3Region,
+			Bucket:          settings.S3Bucket,
+			AccessKeyId:     settings.S3AccessKeyId,
+			SecretAccessKey: settings.S3AccessSecret,
+			PathStyle:       settings.S3PathStyle,
+		}, GetHttpClient())
+	default:
+		return nil, fmt.Errorf("unknown task artifact backend %q", backend)
+	}
+}
+
+// OpenTaskArtifact returns the stored artifact of a task and its content. It
+// returns objectstore.ErrNotFound when the task has no artifact within
+// retention.
+func OpenTaskArtifact(ctx context.Context, taskId string) (*model.TaskArtifact, io.ReadCloser, error) {
+	artifact, exist, err := model.GetStoredTaskArtifact(taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exist {
+		return nil, nil, objectstore.ErrNotFound
+	}
+	store, err := taskArtifactStore(artifact.Backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := store.Open(ctx, artifact.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return artifact, body, nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/objectstore"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableTaskArtifacts(t *testing.T) *system_setting.TaskArtifactSettings {
+	t.Helper()
+	allowLocalWebhooks(t)
+	settings := system_setting.GetTaskArtifactSettings()
+	original := *settings
+	t.Cleanup(func() { *settings = original })
+	settings.Enabled = true
+	settings.Backend = system_setting.TaskArtifactBackendLocal
+	settings.LocalPath = t.TempDir()
+	return settings
+}
+
+func seedArtifactTask(t *testing.T, taskID string, userID int, resultURL string, callbackURL string) *model.Task {
+	t.Helper()
+	task := &model.Task{
+		TaskID:   taskID,
+		UserId:   userID,
+		Status:   model.TaskStatusSuccess,
+		Progress: "100%",
+		PrivateData: model.TaskPrivateData{
+			ResultURL:   resultURL,
+			CallbackURL: callbackURL,
+		},
+	}
+	require.NoError(t, model.DB.Create(task).Error)
+	return task
+}
+
+func TestFinishedTaskResultIsRehosted(t *testing.T) {
+	truncate(t)
+	enableTaskArtifacts(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		_, _ = w.Write([]byte("video-bytes"))
+	}))
+	defer upstream.Close()
+	var callback []byte
+	client := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callback, _ = io.ReadAll(r.Body)
+	}))
+	defer client.Close()
+
+	token := &model.Token{UserId: 5, Name: "artifact", Key: "artifactkey", Status: common.TokenStatusEnabled}
+	require.NoError(t, model.DB.Create(token).Error)
+	task := seedArtifactTask(t, "task_artifact", 5, upstream.URL+"/result.mp4", client.URL)
+	task.PrivateData.TokenId = token.Id
+	require.NoError(t, model.DB.Save(task).Error)
+
+	publishTaskFinished(task)
+	// The callback waits for the artifact
+	assert.Equal(t, 0, RunWebhookDeliveriesOnce(context.Background()).Attempted)
+
+	summary := RunTaskArtifactsOnce(context.Background())
+	assert.Equal(t, 1, summary.Stored)
+
+	stored, exist, err := model.GetByTaskId(5, "task_artifact")
+	require.NoError(t, err)
+	require.True(t, exist)
+	require.NotZero(t, stored.PrivateData.ArtifactId)
+	resultURL := stored.GetResultURL()
+	assert.True(t, strings.HasPrefix(resultURL, system_setting.ServerAddress+"/v1/videos/task_artifact/artifact?"))
+
+	artifact, body, err := OpenTaskArtifact(context.Background(), "task_artifact")
+	require.NoError(t, err)
+	content, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "video-bytes", string(content))
+	assert.Equal(t, "video/mp4", artifact.ContentType)
+	assert.EqualValues(t, len("video-bytes"), artifact.Size)
+
+	assert.Equal(t, 1, RunWebhookDeliveriesOnce(context.Background()).Succeeded)
+	var payload TaskCallbackPayload
+	require.NoError(t, common.Unmarshal(callback, &payload))
+	assert.True(t, strings.HasPrefix(payload.Data.ResultURL, system_setting.ServerAddress+"/v1/videos/task_artifact/artifact?"))
+}
+
+func TestTaskArtifactOverQuotaKeepsUpstreamURL(t *testing.T) {
+	truncate(t)
+	settings := enableTaskArtifacts(t)
+	settings.UserQuotaMB = 1
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("small"))
+	}))
+	defer upstream.Close()
+
+	require.NoError(t, model.DB.Create(&model.TaskArtifact{
+		TaskId: "task_old", UserId: 6, Status: model.TaskArtifactStatusStored, Size: 1 << 20,
+		ExpiresTime: common.GetTimestamp() + 3600,
+	}).Error)
+	task := seedArtifactTask(t, "task_over_quota", 6, upstream.URL+"/result.mp4", "")
+
+	publishTaskFinished(task)
+	summary := RunTaskArtifactsOnce(context.Background())
+	assert.Equal(t, 1, summary.Failed)
+
+	var artifact model.TaskArtifact
+	require.NoError(t, model.DB.Where("task_id = ?", "task_over_quota").First(&artifact).Error)
+	assert.Equal(t, model.TaskArtifactStatusFailed, artifact.Status)
+	assert.Equal(t, 1, artifact.Attempts)
+	assert.Contains(t, artifact.LastError, "quota")
+
+	stored, _, err := model.GetByTaskId(6, "task_over_quota")
+	require.NoError(t, err)
+	assert.Zero(t, stored.PrivateData.ArtifactId)
+	assert.Equal(t, upstream.URL+"/result.mp4", stored.GetResultURL())
+}
+
+func TestExpiredTaskArtifactsAreDeleted(t *testing.T) {
+	truncate(t)
+	settings := enableTaskArtifacts(t)
+
+	store := objectstore.NewLocal(settings.LocalPath)
+	require.NoError(t, store.Put(context.Background(), "tasks/7/task_expired", strings.NewReader("old"), 3, "video/mp4"))
+	artifact := &model.TaskArtifact{
+		TaskId: "task_expired", UserId: 7, Status: model.TaskArtifactStatusStored, Backend: system_setting.TaskArtifactBackendLocal,
+		StorageKey: "tasks/7/task_expired", Size: 3, ExpiresTime: common.GetTimestamp() - 1,
+	}
+	require.NoError(t, model.DB.Create(artifact).Error)
+	task := seedArtifactTask(t, "task_expired", 7, "https://cdn.example.com/expired.mp4", "")
+	require.NoError(t, model.SetTaskArtifactId(task, artifact.Id))
+	assert.True(t, model.HasDueTaskArtifacts(common.GetTimestamp()))
+
+	_, _, err := OpenTaskArtifact(context.Background(), "task_expired")
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+
+	summary := RunTaskArtifactsOnce(context.Background())
+	assert.Equal(t, 1, summary.Expired)
+	assert.False(t, model.HasDueTaskArtifacts(common.GetTimestamp()))
+
+	_, err = store.Open(context.Background(), "tasks/7/task_expired")
+	assert.ErrorIs(t, err, objectstore.ErrNotFound)
+	stored, _, err := model.GetByTaskId(7, "task_expired")
+	require.NoError(t, err)
+	assert.Zero(t, stored.PrivateData.ArtifactId)
+	assert.Equal(t, "https://cdn.example.com/expired.mp4", stored.GetResultURL())
+}
+
+func TestTaskArtifactSignature(t *testing.T) {
+	resultURL := model.TaskArtifactURL("task_sig")
+	parsed, err := http.NewRequest(http.MethodGet, resultURL, nil)
+	require.NoError(t, err)
+	query := parsed.URL.Query()
+	assert.NoError(t, model.VerifyTaskArtifactSignature("task_sig", query.Get("expires"), query.Get("signature")))
+	assert.Error(t, model.VerifyTaskArtifactSignature("task_other", query.Get("expires"), query.Get("signature")))
+	assert.Error(t, model.VerifyTaskArtifactSignature("task_sig", "1", query.Get("signature")))
+	assert.Error(t, model.VerifyTaskArtifactSignature("task_sig", query.Get("expires"), "forged"))
+}
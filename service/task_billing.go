@@ -53,14 +53,15 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 	}
 	attachQuotaSaturation(c, info, other)
 	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
-		ChannelId: info.ChannelId,
-		ModelName: info.OriginModelName,
-		TokenName: tokenName,
-		Quota:     info.PriceData.Quota,
-		Content:   logContent,
-		TokenId:   info.TokenId,
-		Group:     info.UsingGroup,
-		Other:     other,
+		ChannelId:    info.ChannelId,
+		ModelName:    info.OriginModelName,
+		TokenName:    tokenName,
+		Quota:        info.PriceData.Quota,
+		UpstreamCost: applyUpstreamCost(info, info.OriginModelName, info.PriceData.Quota, nil, other),
+		Content:      logContent,
+		TokenId:      info.TokenId,
+		Group:        info.UsingGroup,
+		Other:        other,
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, info.PriceData.Quota)
 	model.UpdateChannelUsedQuota(info.ChannelId, info.PriceData.Quota)
@@ -45,10 +45,18 @@ func TestMain(m *testing.M) {
 		&model.Token{},
 		&model.Log{},
 		&model.Channel{},
+		&model.Ability{},
 		&model.TopUp{},
 		&model.UserSubscription{},
 		&model.SystemTask{},
 		&model.SystemTaskLock{},
+		&model.WebhookSubscription{},
+		&model.WebhookDelivery{},
+		&model.TaskArtifact{},
+		&model.WasmPlugin{},
+		&model.WasmPluginVersion{},
+		&model.Option{},
+		&model.OptionRevision{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -68,10 +76,18 @@ func truncate(t *testing.T) {
 		model.DB.Exec("DELETE FROM tokens")
 		model.DB.Exec("DELETE FROM logs")
 		model.DB.Exec("DELETE FROM channels")
+		model.DB.Exec("DELETE FROM abilities")
 		model.DB.Exec("DELETE FROM top_ups")
 		model.DB.Exec("DELETE FROM user_subscriptions")
 		model.DB.Exec("DELETE FROM system_task_locks")
 		model.DB.Exec("DELETE FROM system_tasks")
+		model.DB.Exec("DELETE FROM webhook_subscriptions")
+		model.DB.Exec("DELETE FROM webhook_deliveries")
+		model.DB.Exec("DELETE FROM task_artifacts")
+		model.DB.Exec("DELETE FROM wasm_plugins")
+		model.DB.Exec("DELETE FROM wasm_plugin_versions")
+		model.DB.Exec("DELETE FROM options")
+		model.DB.Exec("DELETE FROM option_revisions")
 	})
 }
 
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// TaskCallbackEventType is the X-Webhook-Event of task callbacks. Callbacks
+// are recorded in the webhook delivery log without a subscription, so they
+// share its retries, redelivery and retention.
+const TaskCallbackEventType = "task.callback"
+
+const taskCallbackURLMaxLength = 1024
+
+// TaskCallbackPayload is POSTed to a task's callback URL once the task
+// succeeded or failed. Data has the same shape as the task fetch endpoints.
+type TaskCallbackPayload struct {
+	Event     string       `json:"event"`
+	Version   int          `json:"version"`
+	TaskId    string       `json:"task_id"`
+	Status    string       `json:"status"`
+	Timestamp int64        `json:"timestamp"`
+	Data      *dto.TaskDto `json:"data"`
+}
+
+// ValidateTaskCallbackURL checks a client supplied callback URL. The SSRF
+// check is repeated on every delivery since DNS may change in between.
+func ValidateTaskCallbackURL(callbackURL string) error {
+	if len(callbackURL) > taskCallbackURLMaxLength {
+		return fmt.Errorf("callback_url must be at most %d characters", taskCallbackURLMaxLength)
+	}
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+	if system_setting.EnableWorker() {
+		return nil
+	}
+	return ValidateSSRFProtectedFetchURL(callbackURL)
+}
+
+// enqueueTaskCallback records a callback delivery for a finished task that
+// has a callback URL. Like publishTaskFinished it must only be called by the
+// caller that moved the task into its terminal status.
+func enqueueTaskCallback(task *model.Task) {
+	if task.PrivateData.CallbackURL == "" {
+		return
+	}
+	payload, err := common.Marshal(TaskCallbackPayload{
+		Event:     TaskCallbackEventType,
+		Version:   1,
+		TaskId:    task.TaskID,
+		Status:    string(task.Status),
+		Timestamp: common.GetTimestamp(),
+		Data:      TaskModel2Dto(task),
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to marshal callback of task %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	delivery := &model.WebhookDelivery{
+		UserId:    task.UserId,
+		URL:       task.PrivateData.CallbackURL,
+		EventId:   task.TaskID,
+		EventType: TaskCallbackEventType,
+		Payload:   string(payload),
+	}
+	if err := model.CreateWebhookDeliveries([]*model.WebhookDelivery{delivery}); err != nil {
+		common.SysError(fmt.Sprintf("failed to record callback of task %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	if _, _, err := EnqueueSystemTask(model.SystemTaskTypeWebhookDelivery, nil); err != nil {
+		common.SysError("failed to enqueue webhook delivery task: " + err.Error())
+	}
+}
+
+// resolveTaskCallbackTarget signs callbacks with the callback secret of the
+// token that submitted the task, never with the API key itself, so the
+// receiver cannot call the API with it. Tokens created before callback
+// secrets existed get one on their first callback; a rotated secret takes
+// effect for pending retries.
+func resolveTaskCallbackTarget(delivery *model.WebhookDelivery) (*webhookDeliveryTarget, error) {
+	task, exist, err := model.GetByTaskId(delivery.UserId, delivery.EventId)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, errors.New("task not found")
+	}
+	if task.PrivateData.TokenId <= 0 {
+		return nil, errors.New("task has no token to sign the callback with")
+	}
+	token, err := model.GetTokenById(task.PrivateData.TokenId)
+	if err != nil {
+		return nil, errors.New("the token that submitted the task was deleted")
+	}
+	secret, err := model.EnsureTokenCallbackSecret(token)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookDeliveryTarget{
+		url:    delivery.URL,
+		secret: secret,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTaskCallbackURL(t *testing.T) {
+	assert.NoError(t, ValidateTaskCallbackURL("https://93.184.216.34/hooks/task"))
+	assert.Error(t, ValidateTaskCallbackURL("ftp://example.com/hook"))
+	assert.Error(t, ValidateTaskCallbackURL("/relative/hook"))
+	assert.Error(t, ValidateTaskCallbackURL("https://93.184.216.34/"+string(make([]byte, taskCallbackURLMaxLength))))
+	// Private addresses are rejected while SSRF protection is on
+	assert.Error(t, ValidateTaskCallbackURL("http://127.0.0.1:8080/hook"))
+}
+
+func TestFinishedTaskCallbackIsSignedWithTokenCallbackSecret(t *testing.T) {
+	truncate(t)
+	allowLocalWebhooks(t)
+
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	token := &model.Token{UserId: 3, Name: "cb", Key: "callbackkey", Status: common.TokenStatusEnabled}
+	require.NoError(t, model.DB.Create(token).Error)
+	task := &model.Task{
+		TaskID:   "task_callback",
+		UserId:   3,
+		Status:   model.TaskStatusSuccess,
+		Progress: "100%",
+		PrivateData: model.TaskPrivateData{
+			TokenId:     token.Id,
+			CallbackURL: server.URL,
+			ResultURL:   "https://cdn.example.com/video.mp4",
+		},
+	}
+	require.NoError(t, model.DB.Create(task).Error)
+	silent := &model.Task{TaskID: "task_silent", UserId: 3, Status: model.TaskStatusFailure}
+	require.NoError(t, model.DB.Create(silent).Error)
+
+	publishTaskFinished(task)
+	publishTaskFinished(silent)
+	summary := RunWebhookDeliveriesOnce(context.Background())
+	assert.Equal(t, 1, summary.Succeeded)
+
+	require.NotNil(t, body)
+	assert.Equal(t, TaskCallbackEventType, headers.Get("X-Webhook-Event"))
+	assert.Equal(t, "task_callback", headers.Get("X-Webhook-Event-Id"))
+	// The API key never signs callbacks; the token gets its own secret
+	stored, err := model.GetTokenById(token.Id)
+	require.NoError(t, err)
+	require.NotEmpty(t, stored.CallbackSecret)
+	assert.NotContains(t, stored.CallbackSecret, "callbackkey")
+	assert.Equal(t, SignWebhookEvent(stored.CallbackSecret, headers.Get("X-Webhook-Timestamp"), body),
+		headers.Get("X-Webhook-Signature"))
+	var payload TaskCallbackPayload
+	require.NoError(t, common.Unmarshal(body, &payload))
+	assert.Equal(t, "task_callback", payload.TaskId)
+	assert.Equal(t, model.TaskStatusSuccess, payload.Status)
+	assert.Equal(t, "https://cdn.example.com/video.mp4", payload.Data.ResultURL)
+
+	var deliveries []*model.WebhookDelivery
+	require.NoError(t, model.DB.Where("event_type = ?", TaskCallbackEventType).Find(&deliveries).Error)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	assert.Zero(t, delivery.SubscriptionId)
+	assert.Equal(t, 3, delivery.UserId)
+	assert.Equal(t, server.URL, delivery.URL)
+
+	// A callback cannot be signed once the submitting token is gone
+	require.NoError(t, model.DB.Unscoped().Delete(token).Error)
+	_, err = RedeliverWebhook(delivery)
+	require.NoError(t, err)
+	summary = RunWebhookDeliveriesOnce(context.Background())
+	assert.Equal(t, 1, summary.Failed)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+// upstreamCostResult is the upstream cost of one request in quota units.
+type upstreamCostResult struct {
+	Cost  int
+	Ratio float64
+	Expr  bool
+}
+
+// calculateUpstreamCost computes what the request cost upstream, in quota
+// units. The expression is used when configured and usage is known (params
+// non-nil); otherwise the cost ratio is applied to the charged quota with the
+// group ratio removed. ok is false when the channel has no cost pricing or
+// the cost cannot be derived (e.g. a free group hides the base price).
+func calculateUpstreamCost(relayInfo *relaycommon.RelayInfo, modelName string, quota int, params *billingexpr.TokenParams) (result upstreamCostResult, ok bool) {
+	if !relayInfo.HasChannelMeta() {
+		return result, false
+	}
+	cost := relayInfo.ChannelOtherSettings.UpstreamCost
+	if cost == nil {
+		return result, false
+	}
+	if expr := strings.TrimSpace(cost.Expr); expr != "" && params != nil {
+		request := billingexpr.RequestInput{}
+		if relayInfo.BillingRequestInput != nil {
+			request = *relayInfo.BillingRequestInput
+		}
+		rawCost, _, err := billingexpr.RunExprWithRequest(expr, *params, request)
+		if err != nil {
+			common.SysError(fmt.Sprintf("channel #%d upstream cost expr run failed: %v", relayInfo.ChannelId, err))
+			return result, false
+		}
+		// 与分段计费一致：表达式系数为 $/1M tokens
+		return upstreamCostResult{
+			Cost: billingexpr.QuotaRound(rawCost / 1_000_000 * common.QuotaPerUnit),
+			Expr: true,
+		}, true
+	}
+	ratio, ok := cost.RatioFor(modelName)
+	if !ok {
+		return result, false
+	}
+	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
+	if groupRatio <= 0 {
+		return result, false
+	}
+	return upstreamCostResult{
+		Cost:  billingexpr.QuotaRound(float64(quota) / groupRatio * ratio),
+		Ratio: ratio,
+	}, true
+}
+
+// applyUpstreamCost computes the upstream cost and records how it was derived
+// in the log's admin-only info, since the cost ratio is operator data. It
+// returns 0 when no cost is tracked.
+func applyUpstreamCost(relayInfo *relaycommon.RelayInfo, modelName string, quota int, params *billingexpr.TokenParams, other map[string]interface{}) int {
+	result, ok := calculateUpstreamCost(relayInfo, modelName, quota, params)
+	if !ok {
+		return 0
+	}
+	if other != nil {
+		adminInfo, ok := other["admin_info"].(map[string]interface{})
+		if !ok || adminInfo == nil {
+			adminInfo = make(map[string]interface{})
+			other["admin_info"] = adminInfo
+		}
+		if result.Expr {
+			adminInfo["upstream_cost_expr"] = true
+		} else {
+			adminInfo["upstream_cost_ratio"] = result.Ratio
+		}
+	}
+	return result.Cost
+}
+
+// upstreamCostTokenParams builds expression params for the channel's cost
+// expression, or nil when the channel has none.
+func upstreamCostTokenParams(relayInfo *relaycommon.RelayInfo, usage *dto.Usage, isClaudeUsageSemantic bool) *billingexpr.TokenParams {
+	if !relayInfo.HasChannelMeta() || usage == nil {
+		return nil
+	}
+	cost := relayInfo.ChannelOtherSettings.UpstreamCost
+	if cost == nil || strings.TrimSpace(cost.Expr) == "" {
+		return nil
+	}
+	params := BuildTieredTokenParams(usage, isClaudeUsageSemantic, billingexpr.UsedVars(cost.Expr))
+	return &params
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	hosttypes "github.com/QuantumNous/new-api/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpstreamCostRelayInfo(cost *dto.ChannelUpstreamCost, groupRatio float64) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o",
+		PriceData: hosttypes.PriceData{
+			GroupRatioInfo: hosttypes.GroupRatioInfo{GroupRatio: groupRatio},
+		},
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelId:            7,
+			ChannelOtherSettings: dto.ChannelOtherSettings{UpstreamCost: cost},
+		},
+	}
+}
+
+func TestCalculateUpstreamCostRatio(t *testing.T) {
+	info := newUpstreamCostRelayInfo(&dto.ChannelUpstreamCost{
+		Ratio:       0.5,
+		ModelRatios: map[string]float64{"gpt-4o": 0.3},
+	}, 2)
+
+	// 收取 1000（含 2 倍分组倍率）→ 基础价格 500 → 成本 150
+	result, ok := calculateUpstreamCost(info, "gpt-4o", 1000, nil)
+	require.True(t, ok)
+	assert.Equal(t, 150, result.Cost)
+	assert.Equal(t, 0.3, result.Ratio)
+
+	result, ok = calculateUpstreamCost(info, "gpt-4o-mini", 1000, nil)
+	require.True(t, ok)
+	assert.Equal(t, 250, result.Cost)
+
+	// 免费分组无法推算基础价格
+	_, ok = calculateUpstreamCost(newUpstreamCostRelayInfo(&dto.ChannelUpstreamCost{Ratio: 0.5}, 0), "gpt-4o", 0, nil)
+	assert.False(t, ok)
+
+	_, ok = calculateUpstreamCost(newUpstreamCostRelayInfo(nil, 1), "gpt-4o", 1000, nil)
+	assert.False(t, ok)
+}
+
+func TestCalculateUpstreamCostExpr(t *testing.T) {
+	info := newUpstreamCostRelayInfo(&dto.ChannelUpstreamCost{
+		Ratio: 0.5,
+		Expr:  "p * 2 + c * 8",
+	}, 1)
+	params := upstreamCostTokenParams(info, &dto.Usage{PromptTokens: 1000, CompletionTokens: 500}, false)
+	require.NotNil(t, params)
+
+	other := map[string]interface{}{}
+	cost := applyUpstreamCost(info, "gpt-4o", 99999, params, other)
+	// (1000*2 + 500*8) / 1M * QuotaPerUnit
+	assert.Equal(t, common.QuotaRound(6000.0/1_000_000*common.QuotaPerUnit), cost)
+	adminInfo, ok := other["admin_info"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, true, adminInfo["upstream_cost_expr"])
+	assert.NotContains(t, other, "upstream_cost_expr")
+
+	// 无 usage 时退回倍率
+	cost = applyUpstreamCost(info, "gpt-4o", 1000, nil, other)
+	assert.Equal(t, 500, cost)
+	assert.Equal(t, 0.5, adminInfo["upstream_cost_ratio"])
+}
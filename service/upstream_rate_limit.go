@@ -0,0 +1,163 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpstreamRateLimit 是从上游响应头解析出的限流信息，未返回的字段为 nil / 0。
+// 兼容 OpenAI 风格（x-ratelimit-*）、Anthropic 风格（anthropic-ratelimit-*）以及标准 Retry-After。
+type UpstreamRateLimit struct {
+	RetryAfter        time.Duration
+	LimitRequests     *int64
+	LimitTokens       *int64
+	RemainingRequests *int64
+	RemainingTokens   *int64
+	ResetRequests     time.Duration
+	ResetTokens       time.Duration
+}
+
+// HasInfo 返回响应头中是否包含任何限流信息
+func (r UpstreamRateLimit) HasInfo() bool {
+	return r.RetryAfter > 0 || r.LimitRequests != nil || r.LimitTokens != nil ||
+		r.RemainingRequests != nil || r.RemainingTokens != nil ||
+		r.ResetRequests > 0 || r.ResetTokens > 0
+}
+
+// CooldownDuration 返回 429 之后应等待的时长：优先 Retry-After，其次取已耗尽维度的重置时间，
+// 都没有时取两个重置时间中较长者；响应头未给出任何时长时返回 0。
+func (r UpstreamRateLimit) CooldownDuration() time.Duration {
+	if r.RetryAfter > 0 {
+		return r.RetryAfter
+	}
+	var cooldown time.Duration
+	exhausted := false
+	if r.RemainingRequests != nil && *r.RemainingRequests <= 0 {
+		cooldown = max(cooldown, r.ResetRequests)
+		exhausted = true
+	}
+	if r.RemainingTokens != nil && *r.RemainingTokens <= 0 {
+		cooldown = max(cooldown, r.ResetTokens)
+		exhausted = true
+	}
+	if exhausted && cooldown > 0 {
+		return cooldown
+	}
+	return max(r.ResetRequests, r.ResetTokens)
+}
+
+// Snapshot 转换为渠道选择使用的限流快照；响应头中没有额度信息时返回 nil
+func (r UpstreamRateLimit) Snapshot(now time.Time) *model.UpstreamRateLimitSnapshot {
+	if r.LimitRequests == nil && r.LimitTokens == nil && r.RemainingRequests == nil && r.RemainingTokens == nil {
+		return nil
+	}
+	snapshot := &model.UpstreamRateLimitSnapshot{
+		LimitRequests:     r.LimitRequests,
+		RemainingRequests: r.RemainingRequests,
+		LimitTokens:       r.LimitTokens,
+		RemainingTokens:   r.RemainingTokens,
+		UpdatedAt:         now.UnixMilli(),
+	}
+	if r.ResetRequests > 0 {
+		snapshot.ResetRequestsAt = now.Add(r.ResetRequests).UnixMilli()
+	}
+	if r.ResetTokens > 0 {
+		snapshot.ResetTokensAt = now.Add(r.ResetTokens).UnixMilli()
+	}
+	return snapshot
+}
+
+// ObserveUpstreamRateLimit 记录上游响应头中的限流信息：保存渠道（多 Key 渠道按 Key）的剩余额度快照
+// 供渠道选择降权使用，并更新限流感知多 Key 渠道的 Key 调度状态
+func ObserveUpstreamRateLimit(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) {
+	if resp == nil || !info.HasChannelMeta() {
+		return
+	}
+	now := time.Now()
+	rateLimit := ParseUpstreamRateLimit(resp.Header, now)
+	if snapshot := rateLimit.Snapshot(now); snapshot != nil {
+		keyIndex := 0
+		if info.ChannelIsMultiKey {
+			keyIndex = info.ChannelMultiKeyIndex
+		}
+		model.RecordUpstreamRateLimit(info.ChannelId, keyIndex, snapshot)
+	}
+	observeMultiKeyRateLimit(c, info, resp.StatusCode, rateLimit, now)
+}
+
+// ParseUpstreamRateLimit 解析上游响应头中的限流信息
+func ParseUpstreamRateLimit(header http.Header, now time.Time) UpstreamRateLimit {
+	result := UpstreamRateLimit{}
+	if header == nil {
+		return result
+	}
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			result.RetryAfter = time.Duration(v * float64(time.Millisecond))
+		}
+	}
+	if result.RetryAfter == 0 {
+		result.RetryAfter = parseRateLimitReset(header.Get("Retry-After"), now)
+	}
+	result.LimitRequests = firstRateLimitInt(header, "x-ratelimit-limit-requests", "anthropic-ratelimit-requests-limit")
+	result.LimitTokens = firstRateLimitInt(header, "x-ratelimit-limit-tokens", "anthropic-ratelimit-tokens-limit")
+	result.RemainingRequests = firstRateLimitInt(header, "x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining")
+	result.RemainingTokens = firstRateLimitInt(header, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining")
+	result.ResetRequests = firstRateLimitReset(header, now, "x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset")
+	result.ResetTokens = firstRateLimitReset(header, now, "x-ratelimit-reset-tokens", "anthropic-ratelimit-tokens-reset")
+	return result
+}
+
+func firstRateLimitInt(header http.Header, names ...string) *int64 {
+	for _, name := range names {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return &v
+		}
+	}
+	return nil
+}
+
+func firstRateLimitReset(header http.Header, now time.Time, names ...string) time.Duration {
+	for _, name := range names {
+		if d := parseRateLimitReset(header.Get(name), now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parseRateLimitReset 支持秒数（"20"、"0.5"）、Go 时长（"1s"、"6m0s"、"20ms"）、
+// RFC3339 时间戳以及 HTTP 日期
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return max(d, 0)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
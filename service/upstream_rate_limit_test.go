@@ -0,0 +1,65 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpstreamRateLimitOpenAIHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-ratelimit-limit-requests", "60")
+	header.Set("x-ratelimit-remaining-requests", "0")
+	header.Set("x-ratelimit-remaining-tokens", "149000")
+	header.Set("x-ratelimit-reset-requests", "6m0s")
+	header.Set("x-ratelimit-reset-tokens", "20ms")
+
+	rateLimit := ParseUpstreamRateLimit(header, time.Now())
+	require.NotNil(t, rateLimit.LimitRequests)
+	assert.Equal(t, int64(60), *rateLimit.LimitRequests)
+	require.NotNil(t, rateLimit.RemainingRequests)
+	assert.Equal(t, int64(0), *rateLimit.RemainingRequests)
+	assert.Equal(t, 6*time.Minute, rateLimit.ResetRequests)
+	assert.Equal(t, 20*time.Millisecond, rateLimit.ResetTokens)
+	// 请求数已耗尽，按请求数的重置时间冷却
+	assert.Equal(t, 6*time.Minute, rateLimit.CooldownDuration())
+
+	header.Set("Retry-After", "12")
+	assert.Equal(t, 12*time.Second, ParseUpstreamRateLimit(header, time.Now()).CooldownDuration())
+	header.Set("retry-after-ms", "1500")
+	assert.Equal(t, 1500*time.Millisecond, ParseUpstreamRateLimit(header, time.Now()).CooldownDuration())
+}
+
+func TestParseUpstreamRateLimitAnthropicHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Set("anthropic-ratelimit-tokens-remaining", "0")
+	header.Set("anthropic-ratelimit-tokens-reset", now.Add(45*time.Second).Format(time.RFC3339))
+
+	rateLimit := ParseUpstreamRateLimit(header, now)
+	assert.True(t, rateLimit.HasInfo())
+	assert.Equal(t, 45*time.Second, rateLimit.CooldownDuration())
+
+	assert.False(t, ParseUpstreamRateLimit(http.Header{}, now).HasInfo())
+	assert.Equal(t, time.Duration(0), ParseUpstreamRateLimit(http.Header{}, now).CooldownDuration())
+}
+
+func TestUpstreamRateLimitSnapshot(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	header.Set("Retry-After", "5")
+	assert.Nil(t, ParseUpstreamRateLimit(header, now).Snapshot(now))
+
+	header.Set("anthropic-ratelimit-requests-limit", "50")
+	header.Set("anthropic-ratelimit-requests-remaining", "10")
+	header.Set("anthropic-ratelimit-requests-reset", now.Add(30*time.Second).UTC().Format(time.RFC3339))
+	snapshot := ParseUpstreamRateLimit(header, now).Snapshot(now)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, int64(10), *snapshot.RemainingRequests)
+	assert.Greater(t, snapshot.ResetRequestsAt, now.UnixMilli())
+	assert.Zero(t, snapshot.ResetTokensAt)
+	assert.InDelta(t, 0.2, snapshot.Headroom(now), 1e-9)
+}
@@ -100,6 +100,41 @@ func NotifyUser(userId int, userEmail string, userSetting dto.UserSetting, data
 			return nil
 		}
 		return sendGotifyNotify(gotifyUrl, gotifyToken, userSetting.GotifyPriority, data)
+	case dto.NotifyTypeSlack:
+		if userSetting.SlackWebhookUrl == "" {
+			common.SysLog(fmt.Sprintf("user %d has no slack webhook url, skip sending slack", userId))
+			return nil
+		}
+		return sendSlackNotify(userSetting.SlackWebhookUrl, data)
+	case dto.NotifyTypeFeishu:
+		if userSetting.FeishuWebhookUrl == "" {
+			common.SysLog(fmt.Sprintf("user %d has no feishu webhook url, skip sending feishu", userId))
+			return nil
+		}
+		return sendFeishuNotify(userSetting.FeishuWebhookUrl, userSetting.FeishuSecret, data)
+	case dto.NotifyTypeDingTalk:
+		if userSetting.DingTalkWebhookUrl == "" {
+			common.SysLog(fmt.Sprintf("user %d has no dingtalk webhook url, skip sending dingtalk", userId))
+			return nil
+		}
+		return sendDingTalkNotify(userSetting.DingTalkWebhookUrl, userSetting.DingTalkSecret, data)
+	case dto.NotifyTypeWeCom:
+		if userSetting.WeComWebhookUrl == "" {
+			common.SysLog(fmt.Sprintf("user %d has no wecom webhook url, skip sending wecom", userId))
+			return nil
+		}
+		return sendWeComNotify(userSetting.WeComWebhookUrl, data)
+	case dto.NotifyTypeTelegram:
+		// 只发送给用户绑定的Telegram账号
+		chatId, err := model.GetUserTelegramId(userId)
+		if err != nil {
+			return err
+		}
+		if chatId == "" || common.TelegramBotToken == "" {
+			common.SysLog(fmt.Sprintf("user %d has no telegram chat or bot is not configured, skip sending telegram", userId))
+			return nil
+		}
+		return sendTelegramNotify(common.TelegramBotToken, chatId, data)
 	}
 	return nil
 }
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/wasmplugin"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/tidwall/gjson"
+)
+
+var wasmPluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// WasmPluginError is returned by the request hooks when a plugin rejected the
+// request or failed and is not configured to fail open.
+type WasmPluginError struct {
+	Plugin   string
+	Rejected bool
+	Err      error
+}
+
+func (e *WasmPluginError) Error() string {
+	if e.Rejected {
+		return fmt.Sprintf("request rejected by plugin %s: %s", e.Plugin, e.Err.Error())
+	}
+	return fmt.Sprintf("plugin %s failed: %s", e.Plugin, e.Err.Error())
+}
+
+func (e *WasmPluginError) Unwrap() error {
+	return e.Err
+}
+
+// loadedWasmPlugin is the compiled active version of an enabled plugin. err is
+// set when the version could not be compiled so that fail closed plugins
+// keep rejecting requests instead of being skipped silently.
+type loadedWasmPlugin struct {
+	plugin *model.WasmPlugin
+	module *wasmplugin.Module
+	err    error
+}
+
+// wasmPluginGeneration is the runtime and modules published by one reload.
+// inflight counts the invocations still using it; modules and runtimes
+// retired by a later reload are only closed once it drains.
+type wasmPluginGeneration struct {
+	runtime  *wasmplugin.Runtime
+	plugins  map[string]*loadedWasmPlugin
+	inflight sync.WaitGroup
+}
+
+type wasmPluginRegistry struct {
+	mu      sync.RWMutex
+	current *wasmPluginGeneration
+}
+
+var (
+	wasmPlugins          wasmPluginRegistry
+	wasmPluginReloadLock sync.Mutex
+	wasmPluginLoadOnce   sync.Once
+)
+
+// acquire returns the current generation, which stays open until release is
+// called. The generation is nil before the first successful reload.
+func (r *wasmPluginRegistry) acquire() (generation *wasmPluginGeneration, release func()) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.current == nil {
+		return nil, func() {}
+	}
+	r.current.inflight.Add(1)
+	return r.current, r.current.inflight.Done
+}
+
+func (g *wasmPluginGeneration) get(name string) *loadedWasmPlugin {
+	if g == nil {
+		return nil
+	}
+	return g.plugins[name]
+}
+
+// ReloadWasmPlugins compiles the active versions of enabled plugins. Modules
+// whose version did not change are reused; a change of the memory or time
+// limits recreates the runtime and recompiles everything.
+func ReloadWasmPlugins() error {
+	plugins, err := model.GetActiveWasmPlugins()
+	if err != nil {
+		return err
+	}
+	settings := system_setting.GetWasmPluginSettings()
+	limits := wasmplugin.Limits{MemoryPages: settings.MemoryPages(), Timeout: settings.Timeout()}
+	ctx := context.Background()
+
+	wasmPluginReloadLock.Lock()
+	defer wasmPluginReloadLock.Unlock()
+
+	wasmPlugins.mu.RLock()
+	previous := wasmPlugins.current
+	wasmPlugins.mu.RUnlock()
+	var runtime *wasmplugin.Runtime
+	var current map[string]*loadedWasmPlugin
+	if previous != nil {
+		runtime, current = previous.runtime, previous.plugins
+	}
+	var staleRuntime *wasmplugin.Runtime
+	if runtime == nil || runtime.Limits() != limits {
+		newRuntime, err := wasmplugin.NewRuntime(ctx, limits)
+		if err != nil {
+			return err
+		}
+		staleRuntime, runtime, current = runtime, newRuntime, nil
+	}
+
+	loaded := make(map[string]*loadedWasmPlugin, len(plugins))
+	for _, plugin := range plugins {
+		if existing := current[plugin.Name]; existing != nil && existing.err == nil &&
+			existing.plugin.Id == plugin.Id && existing.plugin.ActiveVersion == plugin.ActiveVersion {
+			loaded[plugin.Name] = &loadedWasmPlugin{plugin: plugin, module: existing.module}
+			continue
+		}
+		module, err := compileWasmPluginVersion(ctx, runtime, plugin.Id, plugin.ActiveVersion)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to load wasm plugin %s version %d: %s", plugin.Name, plugin.ActiveVersion, err.Error()))
+		}
+		loaded[plugin.Name] = &loadedWasmPlugin{plugin: plugin, module: module, err: err}
+	}
+
+	wasmPlugins.mu.Lock()
+	wasmPlugins.current = &wasmPluginGeneration{runtime: runtime, plugins: loaded}
+	wasmPlugins.mu.Unlock()
+
+	var retired []*wasmplugin.Module
+	for name, existing := range current {
+		if existing.module != nil && (loaded[name] == nil || loaded[name].module != existing.module) {
+			retired = append(retired, existing.module)
+		}
+	}
+	if previous != nil && (len(retired) > 0 || staleRuntime != nil) {
+		// No new invocation can acquire the previous generation once it is
+		// replaced, so waiting for it cannot race with acquire
+		go func() {
+			previous.inflight.Wait()
+			for _, module := range retired {
+				_ = module.Close(ctx)
+			}
+			if staleRuntime != nil {
+				_ = staleRuntime.Close(ctx)
+			}
+		}()
+	}
+	return nil
+}
+
+func compileWasmPluginVersion(ctx context.Context, runtime *wasmplugin.Runtime, pluginId int, version int) (*wasmplugin.Module, error) {
+	pluginVersion, err := model.GetWasmPluginVersion(pluginId, version)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.Compile(ctx, pluginVersion.Wasm)
+}
+
+func ensureWasmPluginsLoaded() {
+	wasmPluginLoadOnce.Do(func() {
+		if err := ReloadWasmPlugins(); err != nil {
+			common.SysError("failed to load wasm plugins: " + err.Error())
+		}
+	})
+}
+
+// StartWasmPluginSync periodically reloads plugins so that changes made on
+// another node, and changes of the sandbox limits, are picked up.
+func StartWasmPluginSync(frequency int) {
+	if frequency <= 0 {
+		return
+	}
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		if err := ReloadWasmPlugins(); err != nil {
+			common.SysError("failed to reload wasm plugins: " + err.Error())
+		}
+	}
+}
+
+// wasmPluginNames returns the plugins bound to the request: those of the
+// using group first, then those of the channel, without duplicates.
+func wasmPluginNames(info *relaycommon.RelayInfo) []string {
+	settings := system_setting.GetWasmPluginSettings()
+	if info == nil || !settings.Enabled {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]bool)
+	add := func(candidates []string) {
+		for _, name := range candidates {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(settings.GroupPlugins[info.UsingGroup])
+	if info.ChannelMeta != nil {
+		add(info.ChannelOtherSettings.WasmPlugins)
+	}
+	return names
+}
+
+// HasWasmPlugins reports whether any plugin is bound to the request, letting
+// callers skip encoding payloads for nothing.
+func HasWasmPlugins(info *relaycommon.RelayInfo) bool {
+	return len(wasmPluginNames(info)) > 0
+}
+
+func wasmPluginMetadata(info *relaycommon.RelayInfo, hook string) map[string]any {
+	metadata := map[string]any{
+		"hook":            hook,
+		"request_id":      info.RequestId,
+		"user_id":         info.UserId,
+		"token_id":        info.TokenId,
+		"group":           info.UsingGroup,
+		"user_group":      info.UserGroup,
+		"original_model":  info.OriginModelName,
+		"request_path":    info.RequestURLPath,
+		"is_stream":       info.IsStream,
+		"retry_index":     info.RetryIndex,
+		"is_channel_test": info.IsChannelTest,
+	}
+	if info.ChannelMeta != nil {
+		metadata["channel_id"] = info.ChannelId
+		metadata["channel_type"] = info.ChannelType
+		metadata["model"] = info.UpstreamModelName
+	}
+	return metadata
+}
+
+// RunWasmPluginHook passes payload through hook of every plugin bound to the
+// request, in order. changed reports whether any plugin replaced the payload.
+// Rejections, and failures of plugins that do not fail open, are returned as
+// *WasmPluginError.
+func RunWasmPluginHook(ctx context.Context, info *relaycommon.RelayInfo, hook string, payload []byte) (output []byte, changed bool, err error) {
+	names := wasmPluginNames(info)
+	if len(names) == 0 {
+		return payload, false, nil
+	}
+	ensureWasmPluginsLoaded()
+	generation, release := wasmPlugins.acquire()
+	defer release()
+
+	var metadata map[string]any
+	for _, name := range names {
+		loaded := generation.get(name)
+		if loaded == nil {
+			// Unknown and disabled plugins are skipped
+			continue
+		}
+		if loaded.err == nil && !loaded.module.Has(hook) {
+			continue
+		}
+		if metadata == nil {
+			metadata = wasmPluginMetadata(info, hook)
+		}
+		result, err := invokeWasmPlugin(ctx, loaded, hook, payload, metadata)
+		if err != nil {
+			if loaded.plugin.FailOpen {
+				logger.LogWarn(ctx, fmt.Sprintf("wasm plugin %s %s failed, keeping payload: %s", name, hook, err.Error()))
+				continue
+			}
+			return nil, false, &WasmPluginError{Plugin: name, Err: err}
+		}
+		for _, line := range result.Logs {
+			logger.LogInfo(ctx, fmt.Sprintf("wasm plugin %s: %s", name, line))
+		}
+		if result.Rejected != "" {
+			return nil, false, &WasmPluginError{Plugin: name, Rejected: true, Err: errors.New(result.Rejected)}
+		}
+		if len(result.Output) > 0 {
+			payload = result.Output
+			changed = true
+		}
+	}
+	return payload, changed, nil
+}
+
+func invokeWasmPlugin(ctx context.Context, loaded *loadedWasmPlugin, hook string, payload []byte, metadata map[string]any) (*wasmplugin.Result, error) {
+	if loaded.err != nil {
+		return nil, loaded.err
+	}
+	result, err := loaded.module.Invoke(ctx, hook, payload, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Output) > 0 && !isWasmPluginPayload(result.Output) {
+		return nil, fmt.Errorf("%s returned invalid JSON", hook)
+	}
+	return result, nil
+}
+
+// RunWasmPluginStreamHook runs a response hook whose payload is already on
+// its way to the client. Errors and rejections cannot stop the response at
+// this point, so they are logged and the payload is kept.
+//
+// The hook runs before the channel handler reads the usage from the chunk,
+// so output that changes the usage is discarded as well. Plugins adjust
+// billing through final_usage, where the result is validated.
+func RunWasmPluginStreamHook(ctx context.Context, info *relaycommon.RelayInfo, hook string, payload []byte) []byte {
+	output, changed, err := RunWasmPluginHook(ctx, info, hook, payload)
+	if err != nil {
+		logger.LogError(ctx, err.Error())
+		return payload
+	}
+	if changed && !relaycommon.ResponseUsageEqual(payload, output) {
+		logger.LogError(ctx, fmt.Sprintf("wasm plugin %s output changes the usage, keeping the chunk", hook))
+		return payload
+	}
+	return output
+}
+
+// applyFinalUsagePlugins lets plugins adjust the usage before it is billed.
+func applyFinalUsagePlugins(ctx context.Context, info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if usage == nil || !HasWasmPlugins(info) {
+		return
+	}
+	data, err := common.Marshal(usage)
+	if err != nil {
+		return
+	}
+	output, changed, err := RunWasmPluginHook(ctx, info, wasmplugin.HookFinalUsage, data)
+	if err != nil {
+		logger.LogError(ctx, err.Error())
+		return
+	}
+	if !changed {
+		return
+	}
+	var adjusted dto.Usage
+	if err := common.Unmarshal(output, &adjusted); err != nil {
+		logger.LogError(ctx, "wasm plugin returned invalid usage: "+err.Error())
+		return
+	}
+	if err := validateAdjustedUsage(usage, &adjusted, output); err != nil {
+		logger.LogError(ctx, "wasm plugin returned invalid usage: "+err.Error())
+		return
+	}
+	*usage = adjusted
+}
+
+// validateAdjustedUsage keeps a plugin from billing negative usage or from
+// zeroing out a request that consumed tokens.
+func validateAdjustedUsage(original *dto.Usage, adjusted *dto.Usage, output []byte) error {
+	if hasNegativeNumber(gjson.ParseBytes(output)) {
+		return errors.New("usage must not be negative")
+	}
+	tokens := func(usage *dto.Usage) int {
+		return usage.PromptTokens + usage.CompletionTokens + usage.InputTokens + usage.OutputTokens
+	}
+	if tokens(original) > 0 && tokens(adjusted) == 0 {
+		return errors.New("usage must not be zeroed")
+	}
+	return nil
+}
+
+func hasNegativeNumber(result gjson.Result) bool {
+	switch {
+	case result.Type == gjson.Number:
+		return result.Float() < 0
+	case result.IsObject() || result.IsArray():
+		negative := false
+		result.ForEach(func(_, value gjson.Result) bool {
+			negative = hasNegativeNumber(value)
+			return !negative
+		})
+		return negative
+	}
+	return false
+}
+
+// isWasmPluginPayload reports whether data is a JSON object or array, the
+// only payloads hooks exchange.
+func isWasmPluginPayload(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && gjson.ValidBytes(trimmed)
+}
+
+// ValidateWasmPlugin normalizes and checks a plugin definition.
+func ValidateWasmPlugin(plugin *model.WasmPlugin) error {
+	plugin.Name = strings.TrimSpace(plugin.Name)
+	plugin.Description = strings.TrimSpace(plugin.Description)
+	if !wasmPluginNamePattern.MatchString(plugin.Name) {
+		return errors.New("plugin name must be 1-64 lowercase letters, digits, '-' or '_'")
+	}
+	if len(plugin.Description) > 255 {
+		return errors.New("plugin description is too long")
+	}
+	exists, err := model.WasmPluginNameExists(plugin.Name, plugin.Id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("plugin %s already exists", plugin.Name)
+	}
+	return nil
+}
+
+// CreateWasmPluginVersion validates binary against the plugin ABI and the
+// current sandbox limits and stores it as the next version of the plugin.
+func CreateWasmPluginVersion(ctx context.Context, pluginId int, binary []byte, note string, activate bool) (*model.WasmPluginVersion, error) {
+	module, release, err := compileWasmPlugin(ctx, binary)
+	if err != nil {
+		return nil, err
+	}
+	hooks := module.Hooks()
+	_ = module.Close(ctx)
+	release()
+
+	sum := sha256.Sum256(binary)
+	version := &model.WasmPluginVersion{
+		PluginId: pluginId,
+		Hooks:    strings.Join(hooks, ","),
+		Size:     int64(len(binary)),
+		Sha256:   hex.EncodeToString(sum[:]),
+		Note:     strings.TrimSpace(note),
+		Wasm:     binary,
+	}
+	if len(version.Note) > 255 {
+		return nil, errors.New("version note is too long")
+	}
+	if err := model.CreateWasmPluginVersion(version, activate); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// compileWasmPlugin compiles binary with the runtime used for requests. The
+// returned release must be called once the module is closed.
+func compileWasmPlugin(ctx context.Context, binary []byte) (*wasmplugin.Module, func(), error) {
+	ensureWasmPluginsLoaded()
+	generation, release := wasmPlugins.acquire()
+	if generation == nil || generation.runtime == nil {
+		release()
+		return nil, nil, errors.New("wasm plugin runtime is not available")
+	}
+	module, err := generation.runtime.Compile(ctx, binary)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return module, release, nil
+}
+
+// WasmPluginTestResult is the outcome of a test invocation.
+type WasmPluginTestResult struct {
+	Output     string   `json:"output"`
+	Changed    bool     `json:"changed"`
+	Rejected   string   `json:"rejected,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Logs       []string `json:"logs"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// TestWasmPluginVersion invokes hook of a stored version on payload with the
+// given metadata, under the same limits as live requests.
+func TestWasmPluginVersion(ctx context.Context, pluginVersion *model.WasmPluginVersion, hook string, payload []byte, metadata map[string]any) (*WasmPluginTestResult, error) {
+	if !isWasmPluginPayload(payload) {
+		return nil, errors.New("payload must be a JSON object or array")
+	}
+	module, release, err := compileWasmPlugin(ctx, pluginVersion.Wasm)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer module.Close(ctx)
+	if !module.Has(hook) {
+		return nil, fmt.Errorf("version %d does not implement hook %s", pluginVersion.Version, hook)
+	}
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	metadata["hook"] = hook
+
+	start := time.Now()
+	result, err := module.Invoke(ctx, hook, payload, metadata)
+	testResult := &WasmPluginTestResult{Output: string(payload), Logs: []string{}, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		testResult.Error = err.Error()
+		return testResult, nil
+	}
+	testResult.Logs = append(testResult.Logs, result.Logs...)
+	testResult.Rejected = result.Rejected
+	if len(result.Output) > 0 {
+		testResult.Output = string(result.Output)
+		testResult.Changed = true
+		if !isWasmPluginPayload(result.Output) {
+			testResult.Error = fmt.Sprintf("%s returned invalid JSON", hook)
+		}
+	}
+	return testResult, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/wasmplugin"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWasmPlugin is the binary of the module below. It rejects every request
+// before conversion, replaces the converted request and reports fixed usage.
+//
+//	(module
+//	  (import "newapi" "reject" (func $reject (param i32 i32)))
+//	  (memory (export "memory") 1)
+//	  (func (export "alloc") (param i32) (result i32) (i32.const 1024))
+//	  (func (export "pre_conversion") (param i32 i32) (result i64)
+//	    (call $reject (i32.const 2200) (i32.const 7)) (i64.const 0))
+//	  (func (export "post_conversion") (param i32 i32) (result i64)
+//	    (i64.or (i64.shl (i64.const 2048) (i64.const 32)) (i64.const 24)))
+//	  (func (export "final_usage") (param i32 i32) (result i64)
+//	    (i64.or (i64.shl (i64.const 2100) (i64.const 32)) (i64.const 58)))
+//	  (data (i32.const 2048) "{\"model\":\"plugin-model\"}")
+//	  (data (i32.const 2100) "{\"prompt_tokens\":1,\"completion_tokens\":2,\"total_tokens\":3}")
+//	  (data (i32.const 2200) "blocked"))
+var testWasmPlugin = []byte("" +
+	"\x00\x61\x73\x6d\x01\x00\x00\x00\x01\x11\x03\x60\x02\x7f\x7f\x00" +
+	"\x60\x01\x7f\x01\x7f\x60\x02\x7f\x7f\x01\x7e\x02\x11\x01\x06\x6e" +
+	"\x65\x77\x61\x70\x69\x06\x72\x65\x6a\x65\x63\x74\x00\x00\x03\x05" +
+	"\x04\x01\x02\x02\x02\x05\x03\x01\x00\x01\x07\x43\x05\x06\x6d\x65" +
+	"\x6d\x6f\x72\x79\x02\x00\x05\x61\x6c\x6c\x6f\x63\x00\x01\x0e\x70" +
+	"\x72\x65\x5f\x63\x6f\x6e\x76\x65\x72\x73\x69\x6f\x6e\x00\x02\x0f" +
+	"\x70\x6f\x73\x74\x5f\x63\x6f\x6e\x76\x65\x72\x73\x69\x6f\x6e\x00" +
+	"\x03\x0b\x66\x69\x6e\x61\x6c\x5f\x75\x73\x61\x67\x65\x00\x04\x0a" +
+	"\x2b\x04\x05\x00\x41\x80\x08\x0b\x0b\x00\x41\x98\x11\x41\x07\x10" +
+	"\x00\x42\x00\x0b\x0b\x00\x42\x80\x10\x42\x20\x86\x42\x18\x84\x0b" +
+	"\x0b\x00\x42\xb4\x10\x42\x20\x86\x42\x3a\x84\x0b\x0b\x6c\x03\x00" +
+	"\x41\x80\x10\x0b\x18\x7b\x22\x6d\x6f\x64\x65\x6c\x22\x3a\x22\x70" +
+	"\x6c\x75\x67\x69\x6e\x2d\x6d\x6f\x64\x65\x6c\x22\x7d\x00\x41\xb4" +
+	"\x10\x0b\x3a\x7b\x22\x70\x72\x6f\x6d\x70\x74\x5f\x74\x6f\x6b\x65" +
+	"\x6e\x73\x22\x3a\x31\x2c\x22\x63\x6f\x6d\x70\x6c\x65\x74\x69\x6f" +
+	"\x6e\x5f\x74\x6f\x6b\x65\x6e\x73\x22\x3a\x32\x2c\x22\x74\x6f\x74" +
+	"\x61\x6c\x5f\x74\x6f\x6b\x65\x6e\x73\x22\x3a\x33\x7d\x00\x41\x98" +
+	"\x11\x0b\x07\x62\x6c\x6f\x63\x6b\x65\x64")
+
+func seedWasmPlugin(t *testing.T, name string) *model.WasmPlugin {
+	t.Helper()
+	plugin := &model.WasmPlugin{Name: name, Enabled: true}
+	require.NoError(t, ValidateWasmPlugin(plugin))
+	require.NoError(t, plugin.Insert())
+	_, err := CreateWasmPluginVersion(context.Background(), plugin.Id, testWasmPlugin, "", true)
+	require.NoError(t, err)
+	require.NoError(t, ReloadWasmPlugins())
+	t.Cleanup(func() {
+		_ = model.DB.Exec("DELETE FROM wasm_plugins").Error
+		_ = model.DB.Exec("DELETE FROM wasm_plugin_versions").Error
+		_ = ReloadWasmPlugins()
+	})
+	return plugin
+}
+
+func wasmPluginRelayInfo(group string, plugins ...string) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		UsingGroup: group,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelOtherSettings: dto.ChannelOtherSettings{WasmPlugins: plugins},
+		},
+	}
+}
+
+func TestRunWasmPluginHookWithoutBindings(t *testing.T) {
+	payload := []byte(`{"model":"gpt"}`)
+	output, changed, err := RunWasmPluginHook(context.Background(), wasmPluginRelayInfo("default"), wasmplugin.HookPostConversion, payload)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, payload, output)
+}
+
+func TestRunWasmPluginHookChannelBinding(t *testing.T) {
+	truncate(t)
+	seedWasmPlugin(t, "rewrite")
+	info := wasmPluginRelayInfo("default", "missing", "rewrite")
+
+	output, changed, err := RunWasmPluginHook(context.Background(), info, wasmplugin.HookPostConversion, []byte(`{"model":"gpt"}`))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.JSONEq(t, `{"model":"plugin-model"}`, string(output))
+
+	_, _, err = RunWasmPluginHook(context.Background(), info, wasmplugin.HookPreConversion, []byte(`{}`))
+	var pluginErr *WasmPluginError
+	require.True(t, errors.As(err, &pluginErr))
+	assert.True(t, pluginErr.Rejected)
+	assert.Equal(t, "rewrite", pluginErr.Plugin)
+	assert.Contains(t, err.Error(), "blocked")
+
+	// Stream hooks cannot reject a response already on its way, errors are only logged
+	payload := []byte(`{"id":"1"}`)
+	assert.Equal(t, payload, RunWasmPluginStreamHook(context.Background(), info, wasmplugin.HookResponseChunk, payload))
+}
+
+func TestRunWasmPluginStreamHookKeepsChunkUsage(t *testing.T) {
+	truncate(t)
+	seedWasmPlugin(t, "usage")
+	info := wasmPluginRelayInfo("default", "usage")
+
+	// final_usage of the test plugin stands in for a chunk hook that replaces
+	// the chunk: output that drops the usage object is discarded
+	payload := []byte(`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":20}}`)
+	assert.Equal(t, payload, RunWasmPluginStreamHook(context.Background(), info, wasmplugin.HookFinalUsage, payload))
+
+	output := RunWasmPluginStreamHook(context.Background(), info, wasmplugin.HookFinalUsage, []byte(`{"id":"1"}`))
+	assert.JSONEq(t, `{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}`, string(output))
+}
+
+func TestRunWasmPluginHookGroupBindingAndDisabled(t *testing.T) {
+	truncate(t)
+	plugin := seedWasmPlugin(t, "usage")
+	settings := system_setting.GetWasmPluginSettings()
+	original := settings.GroupPlugins
+	settings.GroupPlugins = map[string][]string{"vip": {"usage"}}
+	t.Cleanup(func() { settings.GroupPlugins = original })
+
+	usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30}
+	applyFinalUsagePlugins(context.Background(), wasmPluginRelayInfo("vip"), usage)
+	assert.Equal(t, 1, usage.PromptTokens)
+	assert.Equal(t, 2, usage.CompletionTokens)
+	assert.Equal(t, 3, usage.TotalTokens)
+
+	usage = &dto.Usage{PromptTokens: 10}
+	applyFinalUsagePlugins(context.Background(), wasmPluginRelayInfo("default"), usage)
+	assert.Equal(t, 10, usage.PromptTokens)
+
+	plugin.Enabled = false
+	require.NoError(t, plugin.Update())
+	require.NoError(t, ReloadWasmPlugins())
+	output, changed, err := RunWasmPluginHook(context.Background(), wasmPluginRelayInfo("vip"), wasmplugin.HookPostConversion, []byte(`{}`))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, `{}`, string(output))
+}
+
+func TestValidateWasmPluginName(t *testing.T) {
+	truncate(t)
+	seedWasmPlugin(t, "taken")
+	assert.Error(t, ValidateWasmPlugin(&model.WasmPlugin{Name: "Bad Name"}))
+	assert.Error(t, ValidateWasmPlugin(&model.WasmPlugin{Name: "taken"}))
+	assert.NoError(t, ValidateWasmPlugin(&model.WasmPlugin{Name: "strip-pii_2"}))
+}
+
+func TestValidateAdjustedUsage(t *testing.T) {
+	original := &dto.Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30}
+	valid := []byte(`{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}`)
+	assert.NoError(t, validateAdjustedUsage(original, &dto.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}, valid))
+
+	negative := []byte(`{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3,"prompt_tokens_details":{"cached_tokens":-5}}`)
+	assert.Error(t, validateAdjustedUsage(original, &dto.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}, negative))
+
+	zeroed := []byte(`{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}`)
+	assert.Error(t, validateAdjustedUsage(original, &dto.Usage{}, zeroed))
+	// Nothing to zero out when the request had no tokens
+	assert.NoError(t, validateAdjustedUsage(&dto.Usage{}, &dto.Usage{}, zeroed))
+}
+
+func TestReloadWasmPluginsDrainsInflightGeneration(t *testing.T) {
+	truncate(t)
+	seedWasmPlugin(t, "drain")
+	generation, release := wasmPlugins.acquire()
+	require.NotNil(t, generation)
+
+	// A change of the limits replaces the runtime while the generation is held
+	settings := system_setting.GetWasmPluginSettings()
+	original := settings.TimeoutMs
+	settings.TimeoutMs = int(settings.Timeout().Milliseconds()) + 50
+	t.Cleanup(func() {
+		settings.TimeoutMs = original
+		_ = ReloadWasmPlugins()
+	})
+	require.NoError(t, ReloadWasmPlugins())
+
+	result, err := generation.get("drain").module.Invoke(context.Background(), wasmplugin.HookPostConversion, []byte(`{}`), map[string]any{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"plugin-model"}`, string(result.Output))
+	release()
+}
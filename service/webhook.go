@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -53,72 +54,60 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
-	// 创建 HTTP 请求
-	var req *http.Request
-	var resp *http.Response
-
-	if system_setting.EnableWorker() {
-		// 构建worker请求数据
-		workerReq := &WorkerRequest{
-			URL:    webhookURL,
-			Key:    system_setting.WorkerValidKey,
-			Method: http.MethodPost,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body: payloadBytes,
-		}
-
-		// 如果有secret，添加签名到headers
-		if secret != "" {
-			signature := generateSignature(secret, payloadBytes)
-			workerReq.Headers["X-Webhook-Signature"] = signature
-			workerReq.Headers["Authorization"] = "Bearer " + secret
-		}
-
-		resp, err = DoWorkerRequest(workerReq)
-		if err != nil {
-			return fmt.Errorf("failed to send webhook request through worker: %v", err)
-		}
-		defer resp.Body.Close()
-
-		// 检查响应状态
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
-		}
-	} else {
-		// SSRF防护：验证Webhook URL（非Worker模式）
-		if err := ValidateSSRFProtectedFetchURL(webhookURL); err != nil {
-			return fmt.Errorf("request reject: %v", err)
-		}
-
-		req, err = http.NewRequest(http.MethodPost, webhookURL, bytes.NewBuffer(payloadBytes))
-		if err != nil {
-			return fmt.Errorf("failed to create webhook request: %v", err)
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	// 如果有 secret，生成签名
+	if secret != "" {
+		headers["X-Webhook-Signature"] = generateSignature(secret, payloadBytes)
+		if system_setting.EnableWorker() {
+			headers["Authorization"] = "Bearer " + secret
 		}
+	}
 
-		// 设置请求头
-		req.Header.Set("Content-Type", "application/json")
+	resp, err := doWebhookRequest(context.Background(), webhookURL, headers, payloadBytes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-		// 如果有 secret，生成签名
-		if secret != "" {
-			signature := generateSignature(secret, payloadBytes)
-			req.Header.Set("X-Webhook-Signature", signature)
-		}
+	// 检查响应状态
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
 
-		// 发送请求
-		client := GetSSRFProtectedHTTPClient()
-		resp, err = client.Do(req)
+// doWebhookRequest 发送 webhook POST 请求：启用 Worker 时经 Worker 转发，否则直接发送并做 SSRF 校验
+func doWebhookRequest(ctx context.Context, webhookURL string, headers map[string]string, body []byte) (*http.Response, error) {
+	if system_setting.EnableWorker() {
+		resp, err := DoWorkerRequest(&WorkerRequest{
+			URL:     webhookURL,
+			Key:     system_setting.WorkerValidKey,
+			Method:  http.MethodPost,
+			Headers: headers,
+			Body:    body,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to send webhook request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		// 检查响应状态
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+			return nil, fmt.Errorf("failed to send webhook request through worker: %v", err)
 		}
+		return resp, nil
 	}
 
-	return nil
+	// SSRF防护：验证Webhook URL（非Worker模式）
+	if err := ValidateSSRFProtectedFetchURL(webhookURL); err != nil {
+		return nil, fmt.Errorf("request reject: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := GetSSRFProtectedHTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send webhook request: %v", err)
+	}
+	return resp, nil
 }
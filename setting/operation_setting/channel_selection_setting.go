@@ -0,0 +1,31 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ChannelSelectionSetting 渠道选择策略配置
+type ChannelSelectionSetting struct {
+	// 同一优先级内优先选择上游成本最低的渠道（按渠道成本倍率），
+	// 本次请求已失败的渠道会被跳过；关闭时按权重随机
+	CheapestFirstEnabled bool `json:"cheapest_first_enabled"`
+	// 根据上游返回的限流响应头（x-ratelimit-*、anthropic-ratelimit-*）
+	// 降低剩余额度接近耗尽的渠道的权重，额度重置后自动恢复
+	RateLimitSteeringEnabled bool `json:"rate_limit_steering_enabled"`
+	// 剩余额度比例低于该阈值时开始按比例降权（0~1），剩余额度为 0 时不再选中该渠道
+	RateLimitHeadroomThreshold float64 `json:"rate_limit_headroom_threshold"`
+}
+
+// 默认配置
+var channelSelectionSetting = ChannelSelectionSetting{
+	CheapestFirstEnabled:       false,
+	RateLimitSteeringEnabled:   false,
+	RateLimitHeadroomThreshold: 0.1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_selection_setting", &channelSelectionSetting)
+}
+
+func GetChannelSelectionSetting() *ChannelSelectionSetting {
+	return &channelSelectionSetting
+}
@@ -0,0 +1,229 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 汇率来源
+const (
+	CurrencyRateSourceManual = "manual" // 手动维护，自动刷新时保持不变
+	CurrencyRateSourceAuto   = "auto"   // 由汇率刷新任务更新
+	// 汇率取自运营设置中的 USDExchangeRate，与额度的人民币展示使用同一个汇率
+	CurrencyRateSourceUSDExchangeRate = "usd_exchange_rate"
+)
+
+const (
+	BaseCurrency = "USD"
+
+	CurrencyOptionKey = "currency_setting.currencies"
+)
+
+// CurrencyDefinition 描述一种可用于展示与支付的货币
+type CurrencyDefinition struct {
+	Code      string  `json:"code"`       // ISO 4217 代码，例如 CNY、EUR
+	Name      string  `json:"name"`       // 展示名称
+	Symbol    string  `json:"symbol"`     // 货币符号
+	Rate      float64 `json:"rate"`       // 汇率：1 USD = Rate 单位该货币，来源为 usd_exchange_rate 时不使用
+	Source    string  `json:"source"`     // 汇率来源：manual / auto / usd_exchange_rate
+	UpdatedAt int64   `json:"updated_at"` // 汇率最后更新时间
+}
+
+// CurrencySetting 多币种配置：额度内部仍以 USD 计价，展示、定价与支付时按汇率换算
+type CurrencySetting struct {
+	Currencies []CurrencyDefinition `json:"currencies"`
+	// 用户未设置货币时使用的默认展示/支付货币
+	DefaultCurrency string `json:"default_currency"`
+	// 是否由系统任务定时刷新 source=auto 的货币汇率
+	AutoRefreshEnabled bool `json:"auto_refresh_enabled"`
+	// 汇率接口地址，返回以 USD 为基准的 {"rates": {"CNY": 7.1, ...}}
+	RefreshURL             string `json:"refresh_url"`
+	RefreshIntervalMinutes int    `json:"refresh_interval_minutes"`
+	// 各支付网关的结算货币，例如 {"epay": "CNY", "stripe": "USD"}
+	ProviderCurrencies map[string]string `json:"provider_currencies"`
+}
+
+// 默认配置：人民币汇率沿用 USDExchangeRate，不另外维护一份默认汇率
+var currencySetting = CurrencySetting{
+	Currencies: []CurrencyDefinition{
+		{Code: "USD", Name: "US Dollar", Symbol: "$", Rate: 1, Source: CurrencyRateSourceManual},
+		{Code: "CNY", Name: "人民币", Symbol: "¥", Source: CurrencyRateSourceUSDExchangeRate},
+	},
+	DefaultCurrency:        BaseCurrency,
+	AutoRefreshEnabled:     false,
+	RefreshURL:             "https://open.er-api.com/v6/latest/USD",
+	RefreshIntervalMinutes: 360,
+	ProviderCurrencies: map[string]string{
+		"epay":          "CNY",
+		"stripe":        "USD",
+		"waffo_pancake": "USD",
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+// NormalizeCurrencyCode 统一货币代码格式（去空格并转大写）
+func NormalizeCurrencyCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// GetCurrencies 返回已配置的货币列表，USD 始终可用
+func GetCurrencies() []CurrencyDefinition {
+	currencies := make([]CurrencyDefinition, 0, len(currencySetting.Currencies)+1)
+	hasBase := false
+	for _, currency := range currencySetting.Currencies {
+		if NormalizeCurrencyCode(currency.Code) == BaseCurrency {
+			hasBase = true
+		}
+		currency.Rate = currencyRate(currency)
+		currencies = append(currencies, currency)
+	}
+	if !hasBase {
+		currencies = append([]CurrencyDefinition{baseCurrencyDefinition()}, currencies...)
+	}
+	return currencies
+}
+
+// GetCurrency 按代码查找货币定义，汇率无效的货币视为未配置
+func GetCurrency(code string) (CurrencyDefinition, bool) {
+	code = NormalizeCurrencyCode(code)
+	if code == "" {
+		return CurrencyDefinition{}, false
+	}
+	for _, currency := range currencySetting.Currencies {
+		if NormalizeCurrencyCode(currency.Code) != code {
+			continue
+		}
+		currency.Rate = currencyRate(currency)
+		if currency.Rate > 0 {
+			currency.Code = code
+			return currency, true
+		}
+	}
+	if code == BaseCurrency {
+		return baseCurrencyDefinition(), true
+	}
+	return CurrencyDefinition{}, false
+}
+
+// currencyRate 返回货币的生效汇率，来源为 usd_exchange_rate 时读取 USDExchangeRate
+func currencyRate(currency CurrencyDefinition) float64 {
+	if currency.Source == CurrencyRateSourceUSDExchangeRate {
+		return USDExchangeRate
+	}
+	return currency.Rate
+}
+
+// ResolveCurrency 依次尝试给定货币与默认货币，均未配置时回退到 USD
+func ResolveCurrency(code string) CurrencyDefinition {
+	if currency, ok := GetCurrency(code); ok {
+		return currency
+	}
+	if currency, ok := GetCurrency(currencySetting.DefaultCurrency); ok {
+		return currency
+	}
+	return baseCurrencyDefinition()
+}
+
+// GetProviderCurrency 返回支付网关的结算货币，未配置时为 USD
+func GetProviderCurrency(provider string) string {
+	if code := NormalizeCurrencyCode(currencySetting.ProviderCurrencies[provider]); code != "" {
+		return code
+	}
+	return BaseCurrency
+}
+
+// ConvertFromUSD 将 USD 金额换算为指定货币
+func ConvertFromUSD(amount float64, currency CurrencyDefinition) float64 {
+	return amount * currency.Rate
+}
+
+// ConvertCurrency 在两种已配置货币之间换算金额，任一货币未配置时返回 false
+func ConvertCurrency(amount float64, from string, to string) (float64, bool) {
+	fromCurrency, ok := GetCurrency(from)
+	if !ok {
+		return 0, false
+	}
+	toCurrency, ok := GetCurrency(to)
+	if !ok {
+		return 0, false
+	}
+	if fromCurrency.Code == toCurrency.Code {
+		return amount, true
+	}
+	return amount / fromCurrency.Rate * toCurrency.Rate, true
+}
+
+// ApplyCurrencyRates 使用以 USD 为基准的汇率表更新 source=auto 的货币，返回新的货币列表与更新数量
+func ApplyCurrencyRates(currencies []CurrencyDefinition, rates map[string]float64, now int64) ([]CurrencyDefinition, int) {
+	updated := make([]CurrencyDefinition, len(currencies))
+	copy(updated, currencies)
+	changed := 0
+	for i, currency := range updated {
+		if currency.Source != CurrencyRateSourceAuto {
+			continue
+		}
+		code := NormalizeCurrencyCode(currency.Code)
+		rate, ok := rates[code]
+		if code == BaseCurrency {
+			rate, ok = 1, true
+		}
+		if !ok || rate <= 0 {
+			continue
+		}
+		updated[i].Rate = rate
+		updated[i].UpdatedAt = now
+		changed++
+	}
+	return updated, changed
+}
+
+// ValidateCurrenciesJSON 校验货币列表配置
+func ValidateCurrenciesJSON(value string) error {
+	var currencies []CurrencyDefinition
+	if err := common.UnmarshalJsonStr(value, &currencies); err != nil {
+		return fmt.Errorf("货币配置格式错误: %w", err)
+	}
+	seen := make(map[string]struct{}, len(currencies))
+	for _, currency := range currencies {
+		code := NormalizeCurrencyCode(currency.Code)
+		if len(code) != 3 {
+			return fmt.Errorf("货币代码无效: %q", currency.Code)
+		}
+		if _, ok := seen[code]; ok {
+			return fmt.Errorf("货币代码重复: %s", code)
+		}
+		seen[code] = struct{}{}
+		switch currency.Source {
+		case "", CurrencyRateSourceManual, CurrencyRateSourceAuto:
+		case CurrencyRateSourceUSDExchangeRate:
+			if code != "CNY" {
+				return fmt.Errorf("只有 CNY 可以使用 %s 汇率来源", CurrencyRateSourceUSDExchangeRate)
+			}
+			continue
+		default:
+			return fmt.Errorf("货币 %s 的汇率来源无效: %q", code, currency.Source)
+		}
+		if currency.Rate <= 0 {
+			return fmt.Errorf("货币 %s 的汇率必须大于 0", code)
+		}
+		if code == BaseCurrency && currency.Rate != 1 {
+			return fmt.Errorf("基准货币 %s 的汇率必须为 1", BaseCurrency)
+		}
+	}
+	return nil
+}
+
+func baseCurrencyDefinition() CurrencyDefinition {
+	return CurrencyDefinition{Code: BaseCurrency, Name: "US Dollar", Symbol: "$", Rate: 1, Source: CurrencyRateSourceManual}
+}
@@ -0,0 +1,110 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withCurrencySetting(t *testing.T, setting CurrencySetting) {
+	t.Helper()
+	orig := currencySetting
+	t.Cleanup(func() { currencySetting = orig })
+	currencySetting = setting
+}
+
+func TestGetCurrency(t *testing.T) {
+	withCurrencySetting(t, CurrencySetting{
+		Currencies: []CurrencyDefinition{
+			{Code: "cny", Symbol: "¥", Rate: 7},
+			{Code: "JPY", Rate: 0},
+		},
+		DefaultCurrency: "CNY",
+	})
+
+	currency, ok := GetCurrency(" cny ")
+	require.True(t, ok)
+	assert.Equal(t, "CNY", currency.Code)
+	assert.Equal(t, 7.0, currency.Rate)
+
+	// USD is always available even when not listed
+	currency, ok = GetCurrency("usd")
+	require.True(t, ok)
+	assert.Equal(t, 1.0, currency.Rate)
+
+	_, ok = GetCurrency("JPY")
+	assert.False(t, ok, "currencies without a valid rate are unavailable")
+	_, ok = GetCurrency("")
+	assert.False(t, ok)
+
+	assert.Equal(t, "CNY", ResolveCurrency("GBP").Code)
+	assert.Equal(t, "USD", GetCurrencies()[0].Code)
+}
+
+func TestConvertCurrency(t *testing.T) {
+	withCurrencySetting(t, CurrencySetting{
+		Currencies: []CurrencyDefinition{
+			{Code: "USD", Rate: 1},
+			{Code: "CNY", Rate: 7},
+			{Code: "EUR", Rate: 0.5},
+		},
+	})
+
+	amount, ok := ConvertCurrency(14, "CNY", "USD")
+	require.True(t, ok)
+	assert.InDelta(t, 2, amount, 1e-9)
+
+	amount, ok = ConvertCurrency(14, "CNY", "EUR")
+	require.True(t, ok)
+	assert.InDelta(t, 1, amount, 1e-9)
+
+	_, ok = ConvertCurrency(1, "CNY", "GBP")
+	assert.False(t, ok)
+}
+
+func TestApplyCurrencyRates(t *testing.T) {
+	currencies := []CurrencyDefinition{
+		{Code: "USD", Rate: 1, Source: CurrencyRateSourceAuto},
+		{Code: "CNY", Rate: 7, Source: CurrencyRateSourceManual},
+		{Code: "EUR", Rate: 0.9, Source: CurrencyRateSourceAuto},
+		{Code: "GBP", Rate: 0.8, Source: CurrencyRateSourceAuto},
+	}
+
+	updated, changed := ApplyCurrencyRates(currencies, map[string]float64{"USD": 2, "CNY": 7.2, "EUR": 0.95, "GBP": 0}, 100)
+
+	assert.Equal(t, 2, changed)
+	assert.Equal(t, 1.0, updated[0].Rate, "base currency stays at 1")
+	assert.Equal(t, 7.0, updated[1].Rate, "manual currencies are not refreshed")
+	assert.Equal(t, 0.95, updated[2].Rate)
+	assert.Equal(t, int64(100), updated[2].UpdatedAt)
+	assert.Equal(t, 0.8, updated[3].Rate, "invalid rates are ignored")
+	assert.Equal(t, 0.9, currencies[2].Rate, "input slice is not modified")
+}
+
+func TestValidateCurrenciesJSON(t *testing.T) {
+	assert.NoError(t, ValidateCurrenciesJSON(`[{"code":"usd","rate":1},{"code":"CNY","rate":7.1,"source":"auto"}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`{}`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"YUAN","rate":7}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"CNY","rate":7},{"code":"cny","rate":7}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"CNY","rate":0}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"USD","rate":2}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"CNY","rate":7,"source":"bank"}]`))
+}
+
+func TestCurrencyRateFollowsUSDExchangeRate(t *testing.T) {
+	withCurrencySetting(t, CurrencySetting{
+		Currencies: []CurrencyDefinition{{Code: "CNY", Rate: 5, Source: CurrencyRateSourceUSDExchangeRate}},
+	})
+	orig := USDExchangeRate
+	t.Cleanup(func() { USDExchangeRate = orig })
+	USDExchangeRate = 7.1
+
+	currency, ok := GetCurrency("CNY")
+	require.True(t, ok)
+	assert.Equal(t, 7.1, currency.Rate)
+	assert.Equal(t, 7.1, GetCurrencies()[1].Rate)
+
+	assert.NoError(t, ValidateCurrenciesJSON(`[{"code":"CNY","source":"usd_exchange_rate"}]`))
+	assert.Error(t, ValidateCurrenciesJSON(`[{"code":"EUR","source":"usd_exchange_rate"}]`))
+}
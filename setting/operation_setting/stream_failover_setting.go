@@ -0,0 +1,42 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// StreamFailoverSetting 流式请求故障转移配置
+type StreamFailoverSetting struct {
+	// 首 token 超时（秒），0 表示关闭；从请求发往上游时开始计时，超时且尚未向客户端写出任何字节时换渠道重试
+	FirstTokenTimeoutSeconds int `json:"first_token_timeout_seconds"`
+	// 流中途中断时，是否携带已输出内容作为 prefill 到其他渠道续写。
+	// 仅支持 OpenAI Chat Completions 格式；Claude、Gemini、Responses 等格式中断时以错误事件结束
+	ContinuationEnabled bool `json:"continuation_enabled"`
+	// 单次请求最多续写次数
+	MaxContinuations int `json:"max_continuations"`
+}
+
+// 默认配置
+var streamFailoverSetting = StreamFailoverSetting{
+	FirstTokenTimeoutSeconds: 0,
+	ContinuationEnabled:      false,
+	MaxContinuations:         1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_failover_setting", &streamFailoverSetting)
+}
+
+func GetStreamFailoverSetting() *StreamFailoverSetting {
+	return &streamFailoverSetting
+}
+
+// FirstTokenTimeout 返回首 token 超时时长，未启用时返回 0
+func (s *StreamFailoverSetting) FirstTokenTimeout() time.Duration {
+	if s == nil || s.FirstTokenTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(s.FirstTokenTimeoutSeconds) * time.Second
+}
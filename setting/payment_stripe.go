@@ -1,8 +1,52 @@
 package setting
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
 var StripeApiSecret = ""
 var StripeWebhookSecret = ""
 var StripePriceId = ""
 var StripeUnitPrice = 8.0
 var StripeMinTopUp = 1
 var StripePromotionCodesEnabled = false
+
+// StripeCurrencyUnitPrices opts currencies into inline Stripe pricing, e.g.
+// {"EUR": 7.5}. Top-ups in a listed currency are charged at that unit price;
+// every other currency is charged through StripePriceId.
+var StripeCurrencyUnitPrices = "{}"
+
+// GetStripeCurrencyUnitPrice returns the inline unit price configured for currency.
+func GetStripeCurrencyUnitPrice(currency string) (float64, bool) {
+	var prices map[string]float64
+	if err := common.UnmarshalJsonStr(StripeCurrencyUnitPrices, &prices); err != nil {
+		return 0, false
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	for code, price := range prices {
+		if strings.ToUpper(strings.TrimSpace(code)) == currency && price > 0 {
+			return price, true
+		}
+	}
+	return 0, false
+}
+
+// ValidateStripeCurrencyUnitPrices checks the StripeCurrencyUnitPrices option.
+func ValidateStripeCurrencyUnitPrices(value string) error {
+	var prices map[string]float64
+	if err := common.UnmarshalJsonStr(value, &prices); err != nil {
+		return err
+	}
+	for code, price := range prices {
+		if len(strings.TrimSpace(code)) != 3 {
+			return fmt.Errorf("invalid currency code %q", code)
+		}
+		if price <= 0 {
+			return fmt.Errorf("unit price of %s must be greater than 0", code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package system_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// EventWebhookSettings configures delivery of typed events to webhook
+// subscriptions. Administrators can always manage system subscriptions;
+// UserSubscriptionsEnabled decides whether users may register their own.
+type EventWebhookSettings struct {
+	UserSubscriptionsEnabled bool `json:"user_subscriptions_enabled"`
+	MaxSubscriptionsPerUser  int  `json:"max_subscriptions_per_user"`
+
+	// A delivery is retried with exponential backoff until it succeeds or
+	// MaxAttempts attempts were made, after which it is marked failed.
+	MaxAttempts    int `json:"max_attempts"`
+	TimeoutSeconds int `json:"timeout_seconds"`
+
+	// Finished deliveries older than RetentionDays are removed.
+	RetentionDays int `json:"retention_days"`
+}
+
+var defaultEventWebhookSettings = EventWebhookSettings{
+	UserSubscriptionsEnabled: true,
+	MaxSubscriptionsPerUser:  10,
+	MaxAttempts:              8,
+	TimeoutSeconds:           10,
+	RetentionDays:            14,
+}
+
+func init() {
+	config.GlobalConfig.Register("event_webhook", &defaultEventWebhookSettings)
+}
+
+func GetEventWebhookSettings() *EventWebhookSettings {
+	return &defaultEventWebhookSettings
+}
+
+// GetMaxAttempts returns the attempt limit, falling back to the default.
+func (s *EventWebhookSettings) GetMaxAttempts() int {
+	if s.MaxAttempts <= 0 {
+		return 8
+	}
+	return s.MaxAttempts
+}
+
+// Timeout bounds a single delivery attempt.
+func (s *EventWebhookSettings) Timeout() time.Duration {
+	if s.TimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
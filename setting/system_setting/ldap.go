@@ -0,0 +1,91 @@
+package system_setting
+
+import (
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// LDAPSettings configures directory login. The service account described by
+// BindDN/BindPassword locates the user entry and resolves group membership;
+// the user's own password is only ever checked by binding as that entry.
+type LDAPSettings struct {
+	Enabled     bool   `json:"enabled"`
+	DisplayName string `json:"display_name"`
+
+	// ServerURL is an ldap:// or ldaps:// URL. StartTLS upgrades a plain
+	// ldap:// connection before any credentials are sent.
+	ServerURL          string `json:"server_url"`
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	// CACertificate is an optional PEM bundle used instead of the system
+	// roots to verify the server, typically an internal CA.
+	CACertificate  string `json:"ca_certificate"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+
+	// UserFilter locates the entry for a login name; {username} is replaced
+	// with the escaped value the user typed.
+	BaseDN     string `json:"base_dn"`
+	UserFilter string `json:"user_filter"`
+
+	// IdAttribute holds the stable identifier linked to the local account,
+	// e.g. entryUUID or objectGUID. The entry DN is used when it is empty.
+	IdAttribute          string `json:"id_attribute"`
+	UsernameAttribute    string `json:"username_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+
+	// Group membership is read from GroupAttribute on the user entry and,
+	// when GroupFilter is set, from a search under GroupBaseDN where {dn} and
+	// {username} are replaced with the escaped user DN and login name.
+	GroupAttribute string `json:"group_attribute"`
+	GroupBaseDN    string `json:"group_base_dn"`
+	GroupFilter    string `json:"group_filter"`
+	// RequiredGroups gates login: the user must belong to at least one of
+	// them, given either as a full DN or as the group's common name.
+	RequiredGroups []string `json:"required_groups"`
+
+	// AutoRegister creates a local account on first login. Without it only
+	// directory users already linked to an account can sign in. It is off by
+	// default so enabling LDAP never opens registration to the whole
+	// directory; pair it with RequiredGroups to limit who can sign up.
+	AutoRegister bool `json:"auto_register"`
+}
+
+var defaultLDAPSettings = LDAPSettings{
+	TimeoutSeconds:       10,
+	UserFilter:           "(uid={username})",
+	UsernameAttribute:    "uid",
+	DisplayNameAttribute: "displayName",
+	EmailAttribute:       "mail",
+	GroupAttribute:       "memberOf",
+	RequiredGroups:       []string{},
+}
+
+func init() {
+	config.GlobalConfig.Register("ldap", &defaultLDAPSettings)
+}
+
+func GetLDAPSettings() *LDAPSettings {
+	return &defaultLDAPSettings
+}
+
+// GetEffectiveDisplayName returns the label shown on the login form.
+func (s *LDAPSettings) GetEffectiveDisplayName() string {
+	if trimmed := strings.TrimSpace(s.DisplayName); trimmed != "" {
+		return trimmed
+	}
+	return "LDAP"
+}
+
+// Timeout bounds dialing and every individual LDAP operation.
+func (s *LDAPSettings) Timeout() time.Duration {
+	if s.TimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
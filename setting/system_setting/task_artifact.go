@@ -0,0 +1,91 @@
+package system_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	TaskArtifactBackendLocal = "local"
+	TaskArtifactBackendS3    = "s3"
+)
+
+// TaskArtifactSettings configures re-hosting of finished async task results.
+// When enabled, results returned by upstream as direct URLs are downloaded
+// into the selected backend and handed out as signed gateway URLs instead.
+type TaskArtifactSettings struct {
+	Enabled bool   `json:"enabled"`
+	Backend string `json:"backend"`
+
+	// LocalPath is the directory used by the local backend.
+	LocalPath string `json:"local_path"`
+
+	S3Endpoint     string `json:"s3_endpoint"`
+	S3Region       string `json:"s3_region"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3AccessKeyId  string `json:"s3_access_key_id"`
+	S3AccessSecret string `json:"s3_access_secret"`
+	S3PathStyle    bool   `json:"s3_path_style"`
+
+	// Results larger than MaxFileSizeMB, or that would take a user above
+	// UserQuotaMB of stored artifacts, keep their upstream URL. 0 means no
+	// per-user limit.
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	UserQuotaMB   int `json:"user_quota_mb"`
+
+	// Artifacts are deleted RetentionDays after they were stored; signed
+	// URLs stay valid for URLExpireMinutes after they were issued.
+	RetentionDays    int `json:"retention_days"`
+	URLExpireMinutes int `json:"url_expire_minutes"`
+}
+
+var defaultTaskArtifactSettings = TaskArtifactSettings{
+	Backend:          TaskArtifactBackendLocal,
+	LocalPath:        "./data/task_artifacts",
+	S3Region:         "us-east-1",
+	MaxFileSizeMB:    512,
+	UserQuotaMB:      2048,
+	RetentionDays:    7,
+	URLExpireMinutes: 60,
+}
+
+func init() {
+	config.GlobalConfig.Register("task_artifact", &defaultTaskArtifactSettings)
+}
+
+func GetTaskArtifactSettings() *TaskArtifactSettings {
+	return &defaultTaskArtifactSettings
+}
+
+// MaxFileSize returns the size limit in bytes, falling back to 512 MB.
+func (s *TaskArtifactSettings) MaxFileSize() int64 {
+	if s.MaxFileSizeMB <= 0 {
+		return 512 << 20
+	}
+	return int64(s.MaxFileSizeMB) << 20
+}
+
+// UserQuota returns the per-user limit in bytes, 0 meaning unlimited.
+func (s *TaskArtifactSettings) UserQuota() int64 {
+	if s.UserQuotaMB <= 0 {
+		return 0
+	}
+	return int64(s.UserQuotaMB) << 20
+}
+
+// Retention returns how long artifacts are kept, falling back to 7 days.
+func (s *TaskArtifactSettings) Retention() time.Duration {
+	if s.RetentionDays <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.RetentionDays) * 24 * time.Hour
+}
+
+// URLExpire returns the lifetime of signed URLs, falling back to one hour.
+func (s *TaskArtifactSettings) URLExpire() time.Duration {
+	if s.URLExpireMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.URLExpireMinutes) * time.Minute
+}
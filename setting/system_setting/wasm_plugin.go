@@ -0,0 +1,66 @@
+package system_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// WasmPluginSettings configures the sandbox of WASM plugin hooks. Plugins
+// are bound to channels in the channel settings and to groups here; group
+// plugins run before channel plugins.
+type WasmPluginSettings struct {
+	Enabled bool `json:"enabled"`
+
+	// Every plugin instance is limited to MaxMemoryMB of linear memory and a
+	// single hook invocation to TimeoutMs, after which it is aborted.
+	MaxMemoryMB int `json:"max_memory_mb"`
+	TimeoutMs   int `json:"timeout_ms"`
+
+	// MaxBinarySizeMB limits uploaded plugin binaries.
+	MaxBinarySizeMB int `json:"max_binary_size_mb"`
+
+	// GroupPlugins maps a user group to the names of the plugins applied to
+	// its requests.
+	GroupPlugins map[string][]string `json:"group_plugins"`
+}
+
+var defaultWasmPluginSettings = WasmPluginSettings{
+	Enabled:         true,
+	MaxMemoryMB:     32,
+	TimeoutMs:       100,
+	MaxBinarySizeMB: 16,
+	GroupPlugins:    map[string][]string{},
+}
+
+func init() {
+	config.GlobalConfig.Register("wasm_plugin", &defaultWasmPluginSettings)
+}
+
+func GetWasmPluginSettings() *WasmPluginSettings {
+	return &defaultWasmPluginSettings
+}
+
+// MemoryPages returns the memory limit in 64 KiB pages, falling back to 32 MB.
+func (s *WasmPluginSettings) MemoryPages() uint32 {
+	if s.MaxMemoryMB <= 0 {
+		return 32 * 16
+	}
+	return uint32(min(s.MaxMemoryMB, 4096)) * 16
+}
+
+// Timeout bounds a single hook invocation, falling back to 100 ms.
+func (s *WasmPluginSettings) Timeout() time.Duration {
+	if s.TimeoutMs <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(s.TimeoutMs) * time.Millisecond
+}
+
+// MaxBinarySize returns the upload limit in bytes, falling back to 16 MB.
+func (s *WasmPluginSettings) MaxBinarySize() int64 {
+	if s.MaxBinarySizeMB <= 0 {
+		return 16 << 20
+	}
+	return int64(s.MaxBinarySizeMB) << 20
+}